@@ -0,0 +1,364 @@
+// Package client is a Go client of the HTTP APIs served by the issuer and
+// relay components, so that the integrators don't have to build the requests
+// by hand:
+//
+//	import client "github.com/iden3/go-iden3-core/clients/go"
+//
+// A Client covers the centrauth authentication, the credential refresh and
+// revocation status (credrefresh), the claim proofs of the published states
+// (idenpuboffchainwriter), the DID documents (did) and the jobs (jobs).  All
+// the calls take a context, are retried with the Config.Retry policy on
+// network errors and retryable statuses, and validate the response before
+// returning it.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/centrauth"
+	"github.com/iden3/go-iden3-core/components/credrefresh"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/components/jobs"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/did"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/retry"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// maxResponseLen is the maximum length of the response bodies read.
+const maxResponseLen = 16 * 1024 * 1024
+
+var (
+	// ErrInvalidResponse is used when a response can't be decoded or
+	// lacks a required field.
+	ErrInvalidResponse = errors.New("invalid response")
+	// ErrNotAuthenticated is used when a call that requires a session is
+	// made before Authenticate.
+	ErrNotAuthenticated = errors.New("the client is not authenticated")
+)
+
+// Error is a failed response of the API.
+type Error struct {
+	StatusCode int
+	// Code is the error code of the response, if the endpoint has codes
+	// (like credrefresh).
+	Code    string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("request failed with status %v", e.StatusCode)
+	}
+	return fmt.Sprintf("request failed with status %v: %v", e.StatusCode, e.Message)
+}
+
+// Config is the configuration of a Client.
+type Config struct {
+	// Timeout is the timeout of each attempt of a call.
+	Timeout time.Duration
+	// Retry is the retry policy of the calls.
+	Retry retry.Policy
+}
+
+// ConfigDefault is a default configuration of a Client.
+var ConfigDefault = Config{
+	Timeout: 30 * time.Second,
+	Retry: retry.Policy{
+		MaxAttempts: 3,
+		BackoffMin:  500 * time.Millisecond,
+		BackoffMax:  5 * time.Second,
+		Jitter:      0.2,
+	},
+}
+
+// Client is a client of the issuer and relay HTTP APIs served at a base URL.
+type Client struct {
+	url        string
+	cfg        Config
+	httpClient *http.Client
+	token      string
+}
+
+// New creates a Client of the APIs served at baseURL.  If httpClient is nil,
+// http.DefaultClient is used.
+func New(baseURL string, cfg Config, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{url: strings.TrimSuffix(baseURL, "/"), cfg: cfg, httpClient: httpClient}
+}
+
+// SetToken sets the session token sent in the Authorization header, as
+// obtained with Authenticate.
+func (c *Client) SetToken(token string) {
+	c.token = token
+}
+
+// Token returns the session token of the Client.
+func (c *Client) Token() string {
+	return c.token
+}
+
+// do sends a request to path with the JSON body in (if not nil) and decodes
+// the JSON response into out (if not nil), retrying the failed attempts.
+func (c *Client) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body []byte
+	if in != nil {
+		var err error
+		if body, err = json.Marshal(in); err != nil {
+			return err
+		}
+	}
+	return c.cfg.Retry.Do(ctx, func() error {
+		return c.attempt(ctx, method, path, body, in != nil, out)
+	})
+}
+
+func (c *Client) attempt(ctx context.Context, method, path string, body []byte, hasBody bool, out interface{}) error {
+	if c.cfg.Timeout != 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.cfg.Timeout)
+		defer cancel()
+	}
+	var bodyReader io.Reader
+	if hasBody {
+		bodyReader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, c.url+path, bodyReader)
+	if err != nil {
+		return retry.Permanent(err)
+	}
+	req = req.WithContext(ctx)
+	if hasBody {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	resBody, err := ioutil.ReadAll(io.LimitReader(res.Body, maxResponseLen))
+	if err != nil {
+		return err
+	}
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		resErr := &Error{StatusCode: res.StatusCode}
+		var e struct {
+			Code  string `json:"code"`
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(resBody, &e) == nil {
+			resErr.Code, resErr.Message = e.Code, e.Error
+		}
+		if retry.RetryableStatus(res.StatusCode) {
+			return resErr
+		}
+		return retry.Permanent(resErr)
+	}
+	if out == nil {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(res.Header.Get("Content-Type"))
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return retry.Permanent(fmt.Errorf("%w: unexpected Content-Type %v", ErrInvalidResponse, mediaType))
+	}
+	if err := json.Unmarshal(resBody, out); err != nil {
+		return retry.Permanent(fmt.Errorf("%w: %v", ErrInvalidResponse, err))
+	}
+	return nil
+}
+
+// invalid returns an ErrInvalidResponse for the missing field.
+func invalid(field string) error {
+	return fmt.Errorf("%w: missing %v", ErrInvalidResponse, field)
+}
+
+// Nonce requests a new authentication nonce.
+func (c *Client) Nonce(ctx context.Context) (*centrauth.NonceResponse, error) {
+	var res centrauth.NonceResponse
+	if err := c.do(ctx, http.MethodGet, centrauth.PathNonce, nil, &res); err != nil {
+		return nil, err
+	}
+	if res.Nonce == "" {
+		return nil, invalid("nonce")
+	}
+	return &res, nil
+}
+
+// SignFunc signs msg with prefix with the key authorized by the credKSign of
+// the identity, like issuer.Issuer.SignBinary.
+type SignFunc func(prefix, msg []byte) (*babyjub.SignatureComp, error)
+
+// Authenticate authenticates the identity id with a new nonce signed with
+// sign, and sets the obtained session token in the Client.  The call with
+// the signed nonce is not retried, as the nonce is consumed by the first
+// attempt.
+func (c *Client) Authenticate(ctx context.Context, id *core.ID, credKSign *proof.CredentialExistence,
+	sign SignFunc) (*centrauth.AuthResponse, error) {
+	nonce, err := c.Nonce(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := sign(centrauth.SigPrefixAuth, []byte(nonce.Nonce))
+	if err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(centrauth.AuthRequest{Id: id, Nonce: nonce.Nonce, CredKSign: credKSign, Signature: sig})
+	if err != nil {
+		return nil, err
+	}
+	var res centrauth.AuthResponse
+	if err := c.attempt(ctx, http.MethodPost, centrauth.PathAuth, body, true, &res); err != nil {
+		if retry.IsPermanent(err) {
+			err = errors.Unwrap(err)
+		}
+		return nil, err
+	}
+	if res.Token == "" {
+		return nil, invalid("token")
+	}
+	c.SetToken(res.Token)
+	return &res, nil
+}
+
+// RefreshCredentialExistence requests the existence credential of the claim
+// at hIndex in the last identity state of the issuer.  The errors of the
+// issuer are returned like credrefresh.Client does.
+func (c *Client) RefreshCredentialExistence(ctx context.Context, hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	var credExist proof.CredentialExistence
+	err := c.do(ctx, http.MethodPost, credrefresh.PathRefresh, credrefresh.RefreshRequest{HIndex: hIndex}, &credExist)
+	var resErr *Error
+	if errors.As(err, &resErr) {
+		switch resErr.Code {
+		case credrefresh.CodeRevoked:
+			return nil, issuer.ErrClaimRevoked
+		case credrefresh.CodeNotFound:
+			return nil, issuer.ErrClaimNotFound
+		case credrefresh.CodeNotPublished:
+			return nil, issuer.ErrClaimNotFoundStateOnChain
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if credExist.Claim == nil || credExist.MtpClaim == nil || credExist.IdenStateData.IdenState == nil {
+		return nil, invalid("credential fields")
+	}
+	return &credExist, nil
+}
+
+// RevocationStatus is the status of an issued claim.
+type RevocationStatus string
+
+const (
+	// StatusValid is the status of a claim that is published and not
+	// revoked.
+	StatusValid RevocationStatus = "valid"
+	// StatusRevoked is the status of a revoked claim.
+	StatusRevoked RevocationStatus = "revoked"
+	// StatusNotPublished is the status of an issued claim that is not
+	// yet in the identity state on chain.
+	StatusNotPublished RevocationStatus = "not_published"
+	// StatusNotFound is the status of a claim that was never issued.
+	StatusNotFound RevocationStatus = "not_found"
+)
+
+// RevocationStatus returns the status of the claim at hIndex in the last
+// identity state of the issuer.
+func (c *Client) RevocationStatus(ctx context.Context, hIndex *merkletree.Hash) (RevocationStatus, error) {
+	_, err := c.RefreshCredentialExistence(ctx, hIndex)
+	switch err {
+	case nil:
+		return StatusValid, nil
+	case issuer.ErrClaimRevoked:
+		return StatusRevoked, nil
+	case issuer.ErrClaimNotFoundStateOnChain:
+		return StatusNotPublished, nil
+	case issuer.ErrClaimNotFound:
+		return StatusNotFound, nil
+	default:
+		return "", err
+	}
+}
+
+// ClaimProof requests the proof of the claim at hIndex in the published
+// identity state idenState, or in the last published state if idenState is
+// nil.  The proof is verified against the claims tree root of the response.
+func (c *Client) ClaimProof(ctx context.Context, hIndex, idenState *merkletree.Hash) (*idenpuboffchainwriter.ClaimProof, error) {
+	path := "/claims/" + hIndex.Hex() + "/proof"
+	if idenState != nil {
+		path += "?" + url.Values{"state": []string{idenState.Hex()}}.Encode()
+	}
+	var claimProof idenpuboffchainwriter.ClaimProof
+	if err := c.do(ctx, http.MethodGet, path, nil, &claimProof); err != nil {
+		return nil, err
+	}
+	if claimProof.Mtp == nil {
+		return nil, invalid("mtp")
+	}
+	if idenState != nil && !claimProof.IdenState.Equals(idenState) {
+		return nil, fmt.Errorf("%w: proof of a different state", ErrInvalidResponse)
+	}
+	if !core.IdenState(&claimProof.ClaimsTreeRoot, &claimProof.RevocationsTreeRoot,
+		&claimProof.RootsTreeRoot).Equals(&claimProof.IdenState) {
+		return nil, fmt.Errorf("%w: the tree roots don't match the state", ErrInvalidResponse)
+	}
+	return &claimProof, nil
+}
+
+// DIDDocument requests the DID document of the identity of the domain, or of
+// id if it's not nil.
+func (c *Client) DIDDocument(ctx context.Context, id *core.ID) (*did.Document, error) {
+	path := did.PathWellKnown
+	if id != nil {
+		path = "/" + did.DID(id)
+	}
+	var doc did.Document
+	if err := c.do(ctx, http.MethodGet, path, nil, &doc); err != nil {
+		return nil, err
+	}
+	if id != nil && doc.ID != did.DID(id) {
+		return nil, fmt.Errorf("%w: document of a different identity", ErrInvalidResponse)
+	}
+	return &doc, nil
+}
+
+// Job requests the status of the job id.
+func (c *Client) Job(ctx context.Context, id string) (*jobs.Job, error) {
+	var job jobs.Job
+	if err := c.do(ctx, http.MethodGet, jobs.PathJobs+"/"+url.PathEscape(id), nil, &job); err != nil {
+		return nil, err
+	}
+	if job.ID != id {
+		return nil, fmt.Errorf("%w: status of a different job", ErrInvalidResponse)
+	}
+	return &job, nil
+}
+
+// CancelJob cancels the job id.
+func (c *Client) CancelJob(ctx context.Context, id string) (*jobs.Job, error) {
+	var job jobs.Job
+	if err := c.do(ctx, http.MethodDelete, jobs.PathJobs+"/"+url.PathEscape(id), nil, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
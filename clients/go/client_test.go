@@ -0,0 +1,154 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/credrefresh"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/components/jobs"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-core/utils/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfig = Config{
+	Timeout: 5 * time.Second,
+	Retry:   retry.Policy{MaxAttempts: 3, BackoffMin: time.Millisecond, BackoffMax: time.Millisecond},
+}
+
+func writeJSON(t *testing.T, w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	require.Nil(t, json.NewEncoder(w).Encode(v))
+}
+
+// refresher is a credrefresh.Refresher that returns the error of the first
+// byte of hIndex, or a credential if there is none.
+type refresher map[byte]error
+
+func (r refresher) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	if err, ok := r[hIndex[0]]; ok {
+		return nil, err
+	}
+	id := core.NewID(core.TypeBJP0, [27]byte{1})
+	return &proof.CredentialExistence{
+		Id:            &id,
+		IdenStateData: proof.IdenStateData{BlockN: 2, BlockTs: 3, IdenState: &merkletree.Hash{4}},
+		MtpClaim:      &merkletree.Proof{Existence: true},
+		Claim:         &merkletree.Entry{},
+	}, nil
+}
+
+func TestRevocationStatus(t *testing.T) {
+	server := httptest.NewServer(credrefresh.Handler(refresher{
+		1: issuer.ErrClaimRevoked,
+		2: issuer.ErrClaimNotFound,
+		3: issuer.ErrClaimNotFoundStateOnChain,
+	}))
+	defer server.Close()
+	c := New(server.URL+"/", testConfig, nil)
+
+	credExist, err := c.RefreshCredentialExistence(context.Background(), &merkletree.Hash{7})
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), credExist.IdenStateData.BlockN)
+
+	for hIndex, expected := range map[byte]RevocationStatus{
+		7: StatusValid,
+		1: StatusRevoked,
+		2: StatusNotFound,
+		3: StatusNotPublished,
+	} {
+		status, err := c.RevocationStatus(context.Background(), &merkletree.Hash{hIndex})
+		require.Nil(t, err)
+		assert.Equal(t, expected, status)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		credrefresh.Handler(refresher{}).ServeHTTP(w, req)
+	}))
+	defer server.Close()
+	c := New(server.URL, testConfig, nil)
+
+	_, err := c.RefreshCredentialExistence(context.Background(), &merkletree.Hash{7})
+	require.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// The client errors are not retried.
+	atomic.StoreInt32(&calls, 10)
+	_, err = c.Job(context.Background(), "job")
+	var resErr *Error
+	require.True(t, errors.As(err, &resErr))
+	assert.Equal(t, http.StatusNotFound, resErr.StatusCode)
+	assert.Equal(t, int32(11), atomic.LoadInt32(&calls))
+}
+
+func TestClaimProofValidation(t *testing.T) {
+	claimProof := idenpuboffchainwriter.ClaimProof{
+		ClaimsTreeRoot: merkletree.Hash{1},
+		Mtp:            &merkletree.Proof{Existence: true},
+	}
+	claimProof.IdenState = *core.IdenState(&claimProof.ClaimsTreeRoot,
+		&claimProof.RevocationsTreeRoot, &claimProof.RootsTreeRoot)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("state") != "" {
+			// Proof whose roots don't match the state.
+			bad := claimProof
+			bad.ClaimsTreeRoot = merkletree.Hash{2}
+			writeJSON(t, w, &bad)
+			return
+		}
+		writeJSON(t, w, &claimProof)
+	}))
+	defer server.Close()
+	c := New(server.URL, testConfig, nil)
+
+	res, err := c.ClaimProof(context.Background(), &merkletree.Hash{3}, nil)
+	require.Nil(t, err)
+	assert.Equal(t, claimProof.IdenState, res.IdenState)
+
+	_, err = c.ClaimProof(context.Background(), &merkletree.Hash{3}, &claimProof.IdenState)
+	assert.True(t, errors.Is(err, ErrInvalidResponse))
+}
+
+func TestJob(t *testing.T) {
+	m, err := jobs.New(db.NewMemoryStorage(), clock.Real)
+	require.Nil(t, err)
+	job, err := m.Start("test", func(ctx context.Context, progress jobs.ProgressFunc) (interface{}, error) {
+		return 42, nil
+	})
+	require.Nil(t, err)
+	_, err = m.Wait(context.Background(), job.ID)
+	require.Nil(t, err)
+	server := httptest.NewServer(jobs.Handler(m))
+	defer server.Close()
+	c := New(server.URL, testConfig, nil)
+
+	res, err := c.Job(context.Background(), job.ID)
+	require.Nil(t, err)
+	assert.Equal(t, jobs.StatusSucceeded, res.Status)
+	assert.Equal(t, "42", string(res.Result))
+
+	_, err = c.CancelJob(context.Background(), job.ID)
+	var resErr *Error
+	require.True(t, errors.As(err, &resErr))
+	assert.Equal(t, http.StatusConflict, resErr.StatusCode)
+}
@@ -0,0 +1,145 @@
+// benchcmp compares two outputs of `go test -bench` and fails if any
+// benchmark present in both got slower than the allowed threshold.
+//
+// Usage:
+//
+//	benchcmp [-threshold 10] old.txt new.txt
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// benchResults maps the name of each benchmark to its mean ns/op.
+type benchResults map[string]float64
+
+// parseBench parses the output of `go test -bench`.  When a benchmark
+// appears several times (as with -count), the mean of the runs is used.
+func parseBench(r io.Reader) (benchResults, error) {
+	sums := map[string]float64{}
+	counts := map[string]int{}
+	pkg := ""
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "pkg:" {
+			pkg = fields[1]
+			continue
+		}
+		if len(fields) < 4 || !strings.HasPrefix(fields[0], "Benchmark") {
+			continue
+		}
+		for i := 2; i+1 < len(fields); i += 2 {
+			if fields[i+1] != "ns/op" {
+				continue
+			}
+			nsOp, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid ns/op in %q: %w", scanner.Text(), err)
+			}
+			name := trimProcs(fields[0])
+			if pkg != "" {
+				name = pkg + "." + name
+			}
+			sums[name] += nsOp
+			counts[name]++
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	results := benchResults{}
+	for name, sum := range sums {
+		results[name] = sum / float64(counts[name])
+	}
+	return results, nil
+}
+
+// trimProcs removes the -GOMAXPROCS suffix from the benchmark name, so that
+// results from machines with different number of CPUs can be compared.
+func trimProcs(name string) string {
+	i := strings.LastIndexByte(name, '-')
+	if i == -1 {
+		return name
+	}
+	if _, err := strconv.Atoi(name[i+1:]); err != nil {
+		return name
+	}
+	return name[:i]
+}
+
+// delta is the change of a benchmark between two runs.
+type delta struct {
+	Name     string
+	Old, New float64
+	// Percent is the change of ns/op in percentage.
+	Percent float64
+}
+
+// compare returns the change of the benchmarks present in both old and new,
+// sorted by name.
+func compare(old, new benchResults) []delta {
+	deltas := []delta{}
+	for name, o := range old {
+		n, ok := new[name]
+		if !ok {
+			continue
+		}
+		deltas = append(deltas, delta{Name: name, Old: o, New: n, Percent: (n - o) / o * 100})
+	}
+	sort.Slice(deltas, func(i, j int) bool { return deltas[i].Name < deltas[j].Name })
+	return deltas
+}
+
+func parseFile(path string) (benchResults, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseBench(f)
+}
+
+func main() {
+	threshold := flag.Float64("threshold", 10, "maximum allowed slowdown in percentage")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags] old.txt new.txt\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	old, err := parseFile(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	new, err := parseFile(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	regressions := 0
+	for _, d := range compare(old, new) {
+		mark := ""
+		if d.Percent > *threshold {
+			mark = " REGRESSION"
+			regressions++
+		}
+		fmt.Printf("%-80s %14.0f %14.0f %+8.2f%%%s\n", d.Name, d.Old, d.New, d.Percent, mark)
+	}
+	if regressions > 0 {
+		fmt.Fprintf(os.Stderr, "%d benchmarks are more than %.2f%% slower\n", regressions, *threshold)
+		os.Exit(1)
+	}
+}
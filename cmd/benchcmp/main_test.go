@@ -0,0 +1,52 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const benchOld = `goos: linux
+goarch: amd64
+pkg: github.com/iden3/go-iden3-core/merkletree
+BenchmarkTreeAdd/levels=40/leaves=1000-8         	      32	  40000000 ns/op	   12000 B/op	     300 allocs/op
+BenchmarkTreeAdd/levels=40/leaves=1000-8         	      32	  20000000 ns/op	   12000 B/op	     300 allocs/op
+BenchmarkTreeDump/levels=40/leaves=1000-8        	       1	2000000000 ns/op
+PASS
+pkg: github.com/iden3/go-iden3-core/core/claims
+BenchmarkClaimBasicHash-8	     300	   3500000 ns/op
+ok  	github.com/iden3/go-iden3-core/core/claims	2.1s
+`
+
+const benchNew = `pkg: github.com/iden3/go-iden3-core/merkletree
+BenchmarkTreeAdd/levels=40/leaves=1000-4         	      32	  33000000 ns/op
+BenchmarkTreeDump/levels=40/leaves=1000-4        	       1	1000000000 ns/op
+pkg: github.com/iden3/go-iden3-core/core/claims
+BenchmarkClaimBasicEntry-4	  100000	      1000 ns/op
+`
+
+func TestParseBench(t *testing.T) {
+	results, err := parseBench(strings.NewReader(benchOld))
+	require.Nil(t, err)
+	assert.Equal(t, benchResults{
+		"github.com/iden3/go-iden3-core/merkletree.BenchmarkTreeAdd/levels=40/leaves=1000":  30000000,
+		"github.com/iden3/go-iden3-core/merkletree.BenchmarkTreeDump/levels=40/leaves=1000": 2000000000,
+		"github.com/iden3/go-iden3-core/core/claims.BenchmarkClaimBasicHash":                3500000,
+	}, results)
+}
+
+func TestCompare(t *testing.T) {
+	old, err := parseBench(strings.NewReader(benchOld))
+	require.Nil(t, err)
+	new, err := parseBench(strings.NewReader(benchNew))
+	require.Nil(t, err)
+
+	deltas := compare(old, new)
+	require.Equal(t, 2, len(deltas))
+	assert.Equal(t, "github.com/iden3/go-iden3-core/merkletree.BenchmarkTreeAdd/levels=40/leaves=1000", deltas[0].Name)
+	assert.InDelta(t, 10.0, deltas[0].Percent, 1e-9)
+	assert.Equal(t, "github.com/iden3/go-iden3-core/merkletree.BenchmarkTreeDump/levels=40/leaves=1000", deltas[1].Name)
+	assert.InDelta(t, -50.0, deltas[1].Percent, 1e-9)
+}
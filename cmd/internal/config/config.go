@@ -0,0 +1,254 @@
+// Package config loads the typed configuration of the service binaries in
+// layers, each one overriding the previous:
+//
+//  1. The defaults in the `default` struct tags.
+//  2. A JSON configuration file.
+//  3. Environment variables: PREFIX_SECTION_FIELD, like ISSUER_SERVER_ADDR.
+//  4. Command line flags: -section.field, like -server.addr.
+//
+// The keys of the fields are the names in their `json` tags, or their
+// lowercased names.  After loading, every struct of the configuration that
+// implements Validator is validated.
+package config
+
+import (
+	"encoding"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlagConfigFile is the flag that overrides the configuration file path.
+const FlagConfigFile = "config"
+
+// Validator is implemented by the configuration structs that check their
+// values after loading.
+type Validator interface {
+	Validate() error
+}
+
+// Error is an error in the value of a configuration key.
+type Error struct {
+	Key string
+	Err error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("config: %v: %v", e.Key, e.Err)
+}
+
+// Load loads cfg, a pointer to a configuration struct.  file is the path of
+// the JSON configuration file, which can be empty or be overridden by the
+// -config flag in args.  envPrefix is the prefix of the environment
+// variables, and args are the command line arguments without the program
+// name.
+func Load(cfg interface{}, file, envPrefix string, args []string) error {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config: cfg must be a pointer to a struct")
+	}
+	root := v.Elem()
+
+	// 1. Defaults
+	if err := walk(root, "", func(key string, field reflect.Value, tag reflect.StructTag) error {
+		if def, ok := tag.Lookup("default"); ok {
+			if err := setString(field, def); err != nil {
+				return &Error{Key: key, Err: fmt.Errorf("invalid default %q: %v", def, err)}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// Flags are parsed before reading the file to get the -config flag,
+	// but applied after the environment variables.
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	fs.StringVar(&file, FlagConfigFile, file, "configuration file `PATH`")
+	flags := map[string]*string{}
+	if err := walk(root, "", func(key string, field reflect.Value, tag reflect.StructTag) error {
+		flags[key] = fs.String(key, "", tag.Get("usage"))
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("config: %v", err)
+	}
+
+	// 2. File
+	if file != "" {
+		content, err := ioutil.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("config: %v", err)
+		}
+		dec := json.NewDecoder(strings.NewReader(string(content)))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(cfg); err != nil {
+			return fmt.Errorf("config: %v: %v", file, err)
+		}
+	}
+
+	// 3. Environment
+	if err := walk(root, "", func(key string, field reflect.Value, tag reflect.StructTag) error {
+		name := EnvName(envPrefix, key)
+		if value, ok := os.LookupEnv(name); ok {
+			if err := setString(field, value); err != nil {
+				return &Error{Key: key, Err: fmt.Errorf("invalid %v: %v", name, err)}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// 4. Flags
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if err := walk(root, "", func(key string, field reflect.Value, tag reflect.StructTag) error {
+		if set[key] {
+			if err := setString(field, *flags[key]); err != nil {
+				return &Error{Key: key, Err: fmt.Errorf("invalid flag -%v: %v", key, err)}
+			}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	return validate(root, "")
+}
+
+// EnvName returns the name of the environment variable of key.
+func EnvName(envPrefix, key string) string {
+	name := strings.ToUpper(strings.Replace(key, ".", "_", -1))
+	if envPrefix == "" {
+		return name
+	}
+	return strings.ToUpper(envPrefix) + "_" + name
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// isLeaf tells if a value of type t is set from a single string.
+func isLeaf(t reflect.Type) bool {
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		return true
+	}
+	return t.Kind() != reflect.Struct
+}
+
+// fieldKey returns the key of the struct field f, and false if the field is
+// ignored.
+func fieldKey(f reflect.StructField) (string, bool) {
+	if f.PkgPath != "" {
+		return "", false
+	}
+	name := strings.Split(f.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return "", false
+	} else if name == "" {
+		name = strings.ToLower(f.Name)
+	}
+	return name, true
+}
+
+// walk calls fn for every leaf field of the struct v, with its dotted key.
+func walk(v reflect.Value, prefix string, fn func(key string, field reflect.Value, tag reflect.StructTag) error) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldKey(f)
+		if !ok {
+			continue
+		}
+		key := prefix + name
+		if isLeaf(f.Type) {
+			if err := fn(key, v.Field(i), f.Tag); err != nil {
+				return err
+			}
+		} else if err := walk(v.Field(i), key+".", fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate calls Validate on v and all its nested structs that implement
+// Validator, the inner ones first.
+func validate(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, ok := fieldKey(f)
+		if !ok || isLeaf(f.Type) {
+			continue
+		}
+		if err := validate(v.Field(i), prefix+name+"."); err != nil {
+			return err
+		}
+	}
+	if validator, ok := v.Addr().Interface().(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			key := strings.TrimSuffix(prefix, ".")
+			if key == "" {
+				return fmt.Errorf("config: %v", err)
+			}
+			return &Error{Key: key, Err: err}
+		}
+	}
+	return nil
+}
+
+// setString sets the field from its string representation.
+func setString(field reflect.Value, s string) error {
+	if u, ok := field.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+	if field.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, err := strconv.ParseInt(s, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(i)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, err := strconv.ParseUint(s, 0, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(u)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(s, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	default:
+		return fmt.Errorf("unsupported type %v", field.Type())
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	f, err := ioutil.TempFile("", "config*.json")
+	require.Nil(t, err)
+	defer f.Close()
+	_, err = f.WriteString(content)
+	require.Nil(t, err)
+	return f.Name()
+}
+
+const testIssuerConfig = `{
+	"server": {"addr": "0.0.0.0:9000"},
+	"storage": {"path": "/var/lib/issuer/db"},
+	"keyStore": {"path": "/var/lib/issuer/keystore", "passwordFile": "/run/secrets/pass"},
+	"web3": {"url": "http://localhost:8545"},
+	"contracts": {"idenStates": "0xe0fbce58cfaa72812103f003adce3f284fe5fc7c"},
+	"publishInterval": "5m"
+}`
+
+func TestLoadLayers(t *testing.T) {
+	file := writeConfigFile(t, testIssuerConfig)
+	defer os.Remove(file)
+
+	var cfg Issuer
+	require.Nil(t, Load(&cfg, file, "issuer", nil))
+	assert.Equal(t, "0.0.0.0:9000", cfg.Server.Addr)
+	assert.Equal(t, 5*time.Minute, cfg.PublishInterval.Duration)
+	assert.Equal(t, uint64(10), cfg.Web3.MaxBlockLag) // default
+	assert.Equal(t, common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c"), cfg.Contracts.IdenStates)
+
+	// The environment overrides the file, and the flags the environment
+	os.Setenv("ISSUER_SERVER_ADDR", "127.0.0.1:9001")
+	os.Setenv("ISSUER_WEB3_MAXBLOCKLAG", "20")
+	defer os.Unsetenv("ISSUER_SERVER_ADDR")
+	defer os.Unsetenv("ISSUER_WEB3_MAXBLOCKLAG")
+	cfg = Issuer{}
+	require.Nil(t, Load(&cfg, file, "issuer", []string{"-server.addr", "127.0.0.1:9002", "-publishInterval=1h"}))
+	assert.Equal(t, "127.0.0.1:9002", cfg.Server.Addr)
+	assert.Equal(t, uint64(20), cfg.Web3.MaxBlockLag)
+	assert.Equal(t, time.Hour, cfg.PublishInterval.Duration)
+
+	// The -config flag overrides the file
+	cfg = Issuer{}
+	require.Nil(t, Load(&cfg, "/nonexistent", "", []string{"-config", file}))
+	assert.Equal(t, "/var/lib/issuer/db", cfg.Storage.Path)
+}
+
+func TestLoadErrors(t *testing.T) {
+	file := writeConfigFile(t, testIssuerConfig)
+	defer os.Remove(file)
+
+	var cfg Issuer
+	err := Load(&cfg, file, "", []string{"-web3.maxBlockLag", "many"})
+	require.NotNil(t, err)
+	assert.Equal(t, "web3.maxBlockLag", err.(*Error).Key)
+
+	err = Load(&cfg, file, "", []string{"-server.addr", "nohostport"})
+	require.NotNil(t, err)
+	assert.Equal(t, "server", err.(*Error).Key)
+
+	err = Load(&cfg, file, "", []string{"-unknown", "1"})
+	assert.NotNil(t, err)
+
+	fileUnknown := writeConfigFile(t, `{"unknown": 1}`)
+	defer os.Remove(fileUnknown)
+	err = Load(&cfg, fileUnknown, "", nil)
+	assert.NotNil(t, err)
+
+	// Missing required values
+	var centrAuth CentrAuth
+	err = Load(&centrAuth, "", "", []string{"-storage.path", "/db", "-keyStore.path", "/ks",
+		"-keyStore.passwordFile", "/pass", "-web3.url", "http://localhost:8545"})
+	require.NotNil(t, err)
+	assert.Equal(t, "config: domain is required", err.Error())
+	assert.Equal(t, 24*time.Hour, centrAuth.SessionTimeout.Duration)
+}
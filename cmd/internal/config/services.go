@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// Duration is a time.Duration that is written in the configuration as a
+// string, like "30s".
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a duration like "30s".
+func (d *Duration) UnmarshalText(text []byte) error {
+	duration, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
+
+// MarshalText serializes the duration as a string like "30s".
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.Duration.String()), nil
+}
+
+// Server is the configuration of an HTTP or gRPC server.
+type Server struct {
+	Addr string `json:"addr" default:"localhost:8000" usage:"listen address"`
+}
+
+// Validate checks that Addr is a host:port.
+func (s *Server) Validate() error {
+	if _, _, err := net.SplitHostPort(s.Addr); err != nil {
+		return fmt.Errorf("invalid addr %q: %v", s.Addr, err)
+	}
+	return nil
+}
+
+// Storage is the configuration of a leveldb storage.
+type Storage struct {
+	Path string `json:"path" usage:"leveldb storage directory"`
+}
+
+// Validate checks that Path is set.
+func (s *Storage) Validate() error {
+	if s.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	return nil
+}
+
+// KeyStore is the configuration of a babyjub keystore.
+type KeyStore struct {
+	Path string `json:"path" usage:"keystore file"`
+	// PasswordFile is the file with the password of the keys, which is
+	// not written in the configuration.
+	PasswordFile string `json:"passwordFile" usage:"file with the keystore password"`
+}
+
+// Validate checks that the Path and PasswordFile are set.
+func (k *KeyStore) Validate() error {
+	if k.Path == "" {
+		return fmt.Errorf("path is required")
+	}
+	if k.PasswordFile == "" {
+		return fmt.Errorf("passwordFile is required")
+	}
+	return nil
+}
+
+// Web3 is the configuration of the connection to an ethereum node.
+type Web3 struct {
+	Url string `json:"url" usage:"ethereum node RPC URL"`
+	// MaxBlockLag is the number of blocks the node can be behind the
+	// network before it's considered out of sync.
+	MaxBlockLag uint64 `json:"maxBlockLag" default:"10" usage:"maximum blocks behind the network"`
+}
+
+// Validate checks that Url is a valid URL.
+func (w *Web3) Validate() error {
+	if w.Url == "" {
+		return fmt.Errorf("url is required")
+	}
+	if _, err := url.ParseRequestURI(w.Url); err != nil {
+		return fmt.Errorf("invalid url %q: %v", w.Url, err)
+	}
+	return nil
+}
+
+// Contracts are the addresses of the smart contracts used by the services.
+type Contracts struct {
+	IdenStates common.Address `json:"idenStates" usage:"address of the identity states contract"`
+}
+
+// Validate checks that the addresses are set.
+func (c *Contracts) Validate() error {
+	if c.IdenStates == (common.Address{}) {
+		return fmt.Errorf("idenStates is required")
+	}
+	return nil
+}
+
+// Issuer is the configuration of the issuer server.
+type Issuer struct {
+	Server    Server    `json:"server"`
+	Storage   Storage   `json:"storage"`
+	KeyStore  KeyStore  `json:"keyStore"`
+	Web3      Web3      `json:"web3"`
+	Contracts Contracts `json:"contracts"`
+	// PublishInterval is the period of the publication of the identity
+	// state.
+	PublishInterval Duration `json:"publishInterval" default:"10m" usage:"identity state publication period"`
+}
+
+// Relay is the configuration of the relay server.
+type Relay struct {
+	Server    Server     `json:"server"`
+	Admin     RelayAdmin `json:"admin"`
+	Storage   Storage    `json:"storage"`
+	KeyStore  KeyStore   `json:"keyStore"`
+	Web3      Web3       `json:"web3"`
+	Contracts Contracts  `json:"contracts"`
+}
+
+// RelayAdmin is the configuration of the admin API of the relay.
+type RelayAdmin struct {
+	Addr string `json:"addr" default:"localhost:8001" usage:"admin API listen address"`
+	// TokenFile is the file with the token of the admin API, which is not
+	// written in the configuration.
+	TokenFile string `json:"tokenFile" usage:"file with the admin API token"`
+}
+
+// Validate checks that Addr is a host:port and that TokenFile is set.
+func (a *RelayAdmin) Validate() error {
+	if _, _, err := net.SplitHostPort(a.Addr); err != nil {
+		return fmt.Errorf("invalid addr %q: %v", a.Addr, err)
+	}
+	if a.TokenFile == "" {
+		return fmt.Errorf("tokenFile is required")
+	}
+	return nil
+}
+
+// CentrAuth is the configuration of the centralized authentication server.
+type CentrAuth struct {
+	Server   Server   `json:"server"`
+	Storage  Storage  `json:"storage"`
+	KeyStore KeyStore `json:"keyStore"`
+	Web3     Web3     `json:"web3"`
+	// Domain is the domain of the authentication requests.
+	Domain string `json:"domain" usage:"domain of the authentication requests"`
+	// SessionTimeout is the lifetime of the authenticated sessions.
+	SessionTimeout Duration `json:"sessionTimeout" default:"24h" usage:"authenticated session lifetime"`
+}
+
+// Validate checks that Domain is set.
+func (c *CentrAuth) Validate() error {
+	if c.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+	return nil
+}
@@ -0,0 +1,390 @@
+// Package bulkimport issues claims in bulk from a CSV or JSONL file of claim
+// data, mapping each row through an issuer.ClaimTemplate.  The claims are
+// issued in a single batch with issuer.Issuer.IssueFromTemplateBatch and the
+// identity state is published once at the end.  The result of each row is
+// reported with the hIndex of the issued claim, which is the reference used
+// to request its credential (see credrefresh), or the error of the row.
+//
+// In a CSV file the first line is the header with the names of the template
+// fields, and in a JSONL file each line is a JSON object with the fields.  In
+// both, the field ColumnSubject is the ID of the subject of the claim.  The
+// import is available as an HTTP endpoint:
+//
+//	POST /claims/import?template=<id>
+//	Content-Type: text/csv | application/x-ndjson
+//
+// and as the "claims import" CLI subcommand (see Commands).  With HandlerJobs,
+// the import can be run in the background as a job followed at /jobs/<id>.
+package bulkimport
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/iden3/go-iden3-core/components/jobs"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/merkletree"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathImport is the path of the import endpoint.
+const PathImport = "/claims/import"
+
+// ColumnSubject is the column of the CSV files, or the key of the JSONL
+// objects, with the ID of the subject of the claim.
+const ColumnSubject = "subject"
+
+// Formats of the import files.
+const (
+	FormatCSV   = "csv"
+	FormatJSONL = "jsonl"
+)
+
+// MaxRows is the maximum number of rows of an import.
+const MaxRows = 100000
+
+var (
+	ErrUnknownFormat = errors.New("unknown import format")
+	ErrTooManyRows   = fmt.Errorf("the import has more than %d rows", MaxRows)
+)
+
+// Issuer issues batches of claims from templates, satisfied by issuer.Issuer.
+type Issuer interface {
+	IssueFromTemplateBatch(templateID string, batch []issuer.TemplateData) ([]merkletree.Entrier, []error, error)
+	PublishState() (*issuer.PublishStateResult, error)
+	ClaimTemplate(templateID string) (*issuer.ClaimTemplate, error)
+}
+
+// Row is a row of an import file.
+type Row struct {
+	issuer.TemplateData
+	// Err is the error parsing the row, if any.
+	Err error
+}
+
+// parseSubject sets the subject of row from the ColumnSubject value v.
+func (row *Row) parseSubject(v interface{}) {
+	if v == nil || v == "" {
+		return
+	}
+	s, ok := v.(string)
+	if !ok {
+		row.Err = fmt.Errorf("invalid %v", ColumnSubject)
+		return
+	}
+	id, err := core.IDFromString(s)
+	if err != nil {
+		row.Err = fmt.Errorf("invalid %v: %v", ColumnSubject, err)
+		return
+	}
+	row.Subject = &id
+}
+
+// ReadCSV reads the rows of a CSV file.  The empty values are left out of the
+// data, so they are handled as missing fields.
+func ReadCSV(r io.Reader) ([]Row, error) {
+	reader := csv.NewReader(r)
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	reader.FieldsPerRecord = len(header)
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{TemplateData: issuer.TemplateData{Data: map[string]interface{}{}}}
+		for i, v := range record {
+			if header[i] == ColumnSubject {
+				row.parseSubject(v)
+			} else if v != "" {
+				row.Data[header[i]] = v
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// ReadJSONL reads the rows of a JSONL file.  The empty lines are skipped.
+func ReadJSONL(r io.Reader) ([]Row, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1024*1024)
+	var rows []Row
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(rows) == MaxRows {
+			return nil, ErrTooManyRows
+		}
+		row := Row{TemplateData: issuer.TemplateData{Data: map[string]interface{}{}}}
+		dec := json.NewDecoder(bytes.NewReader(line))
+		dec.UseNumber()
+		if err := dec.Decode(&row.Data); err != nil {
+			row.Err = fmt.Errorf("invalid JSON: %v", err)
+		} else {
+			row.parseSubject(row.Data[ColumnSubject])
+			delete(row.Data, ColumnSubject)
+		}
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// ReadRows reads the rows of a file in format.
+func ReadRows(r io.Reader, format string) ([]Row, error) {
+	switch format {
+	case FormatCSV:
+		return ReadCSV(r)
+	case FormatJSONL:
+		return ReadJSONL(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+}
+
+// Result is the result of the import of a row.
+type Result struct {
+	// Row is the number of the row in the file, starting at 1 and not
+	// counting the CSV header.
+	Row int `json:"row"`
+	// HIndex is the hIndex of the issued claim.
+	HIndex *merkletree.Hash `json:"hIndex,omitempty"`
+	Error  string           `json:"error,omitempty"`
+}
+
+// Report is the result of an import.
+type Report struct {
+	Results []Result `json:"results"`
+	Issued  int      `json:"issued"`
+	Failed  int      `json:"failed"`
+	// PublishStatus is the status of the publication of the identity
+	// state after issuing the claims, see issuer.PublishStateStatus.  It's
+	// empty if no claim was issued.
+	PublishStatus string           `json:"publishStatus,omitempty"`
+	IdenState     *merkletree.Hash `json:"idenState,omitempty"`
+	// PublishError is the error publishing the identity state.  The
+	// claims are issued regardless, and will be published with the next
+	// identity state.
+	PublishError string `json:"publishError,omitempty"`
+}
+
+// ChunkSize is the number of claims issued at once by ImportProgress between
+// progress reports.
+const ChunkSize = 500
+
+// Import issues the claims of rows with the template templateID, and
+// publishes the identity state once if any claim was issued.  The error is
+// only returned if the batch couldn't be issued at all.
+func Import(is Issuer, templateID string, rows []Row) (*Report, error) {
+	return ImportProgress(context.Background(), is, templateID, rows, nil)
+}
+
+// ImportProgress is like Import, but issues the claims in chunks of ChunkSize,
+// calling progress (if not nil) with the number of processed rows after each
+// chunk, and stops when ctx is done.  When ctx is done, the partial Report of
+// the processed rows is returned with ctx.Err(), and the identity state is
+// not published: the claims already issued will be published with the next
+// identity state.
+func ImportProgress(ctx context.Context, is Issuer, templateID string, rows []Row,
+	progress func(done, total int)) (*Report, error) {
+	if progress == nil {
+		progress = func(int, int) {}
+	}
+	report := Report{Results: make([]Result, len(rows))}
+	var batch []issuer.TemplateData
+	var batchRows []int
+	issue := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		issued, errs, err := is.IssueFromTemplateBatch(templateID, batch)
+		if err != nil {
+			return err
+		}
+		for j, i := range batchRows {
+			if issued[j] != nil {
+				report.Results[i].HIndex = issued[j].Entry().HIndex()
+			}
+			if errs[j] != nil {
+				report.Results[i].Error = errs[j].Error()
+			}
+		}
+		batch, batchRows = batch[:0], batchRows[:0]
+		return nil
+	}
+	progress(0, len(rows))
+	var errCtx error
+	for i, row := range rows {
+		report.Results[i].Row = i + 1
+		if row.Err != nil {
+			report.Results[i].Error = row.Err.Error()
+		} else {
+			batch = append(batch, row.TemplateData)
+			batchRows = append(batchRows, i)
+		}
+		if len(batch) == ChunkSize || i == len(rows)-1 {
+			if err := issue(); err != nil {
+				return nil, err
+			}
+			progress(i+1, len(rows))
+			if errCtx = ctx.Err(); errCtx != nil {
+				report.Results = report.Results[:i+1]
+				break
+			}
+		}
+	}
+	for _, result := range report.Results {
+		if result.HIndex != nil {
+			report.Issued++
+		}
+		if result.Error != "" {
+			report.Failed++
+		}
+	}
+	if errCtx != nil {
+		return &report, errCtx
+	}
+	if report.Issued != 0 {
+		res, err := is.PublishState()
+		if err != nil {
+			report.PublishError = err.Error()
+		} else {
+			report.PublishStatus = res.Status.String()
+			report.IdenState = res.IdenState
+		}
+	}
+	return &report, nil
+}
+
+// WriteResults writes the results of an import in format: a CSV file with the
+// columns row, hIndex and error, or a JSONL file with a Result per line.
+func WriteResults(w io.Writer, format string, results []Result) error {
+	switch format {
+	case FormatCSV:
+		writer := csv.NewWriter(w)
+		if err := writer.Write([]string{"row", "hIndex", "error"}); err != nil {
+			return err
+		}
+		for _, result := range results {
+			hIndex := ""
+			if result.HIndex != nil {
+				hIndex = result.HIndex.Hex()
+			}
+			if err := writer.Write([]string{strconv.Itoa(result.Row), hIndex, result.Error}); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	case FormatJSONL:
+		enc := json.NewEncoder(w)
+		for i := range results {
+			if err := enc.Encode(&results[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return ErrUnknownFormat
+	}
+}
+
+// Error is the body of a failed import response.
+type Error = httpjson.Error
+
+// formatContentTypes are the formats of the import by Content-Type.
+var formatContentTypes = map[string]string{
+	"text/csv":             FormatCSV,
+	"application/x-ndjson": FormatJSONL,
+	"application/jsonl":    FormatJSONL,
+}
+
+// Handler returns an http.Handler that serves the import endpoint with is.
+// The response is the JSON Report of the import.
+func Handler(is Issuer) http.Handler {
+	return HandlerJobs(is, nil)
+}
+
+// JobKind is the kind of the jobs of the asynchronous imports.
+const JobKind = "claims-import"
+
+// HandlerJobs is like Handler, but the imports requested with the query
+// parameter async=true are run as a job of m, and the response is the JSON
+// jobs.Job with status 202.  The result of the job is the Report of the
+// import.  If m is nil, the async parameter is ignored.
+func HandlerJobs(is Issuer, m *jobs.Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathImport, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		templateID := req.URL.Query().Get("template")
+		if templateID == "" {
+			httpjson.WriteError(w, http.StatusBadRequest, "missing template")
+			return
+		}
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		format, ok := formatContentTypes[mediaType]
+		if !ok {
+			httpjson.WriteError(w, http.StatusUnsupportedMediaType, "unsupported Content-Type "+mediaType)
+			return
+		}
+		rows, err := ReadRows(req.Body, format)
+		if err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid "+format+": "+err.Error())
+			return
+		}
+		if m != nil && req.URL.Query().Get("async") == "true" {
+			if _, err := is.ClaimTemplate(templateID); err == issuer.ErrTemplateNotFound {
+				httpjson.WriteError(w, http.StatusNotFound, err.Error())
+				return
+			}
+			job, err := m.Start(JobKind, func(ctx context.Context, progress jobs.ProgressFunc) (interface{}, error) {
+				return ImportProgress(ctx, is, templateID, rows, progress)
+			})
+			if err != nil {
+				log.WithError(err).Error("Start import job")
+				httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+				return
+			}
+			httpjson.Write(w, http.StatusAccepted, job)
+			return
+		}
+		report, err := Import(is, templateID, rows)
+		if err == issuer.ErrTemplateNotFound {
+			httpjson.WriteError(w, http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
+			log.WithError(err).Error("Import")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, report)
+	})
+	return mux
+}
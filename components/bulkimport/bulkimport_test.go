@@ -0,0 +1,218 @@
+package bulkimport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/components/jobs"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/urfave/cli.v1"
+)
+
+const templates = `[{
+  "id": "membership",
+  "claimType": "acme.membership",
+  "subject": "index:0",
+  "fields": [
+    {"name": "level", "slot": "index:1", "encoding": "string"},
+    {"name": "points", "slot": "data:0", "encoding": "int", "optional": true}
+  ]
+}]`
+
+var (
+	subject0 = core.NewID(core.TypeBJP0, [27]byte{1})
+	subject1 = core.NewID(core.TypeBJP0, [27]byte{2})
+)
+
+func newIssuer(t *testing.T) *issuer.Issuer {
+	idenPubOnChain := idenpubonchain.New()
+	demo, err := issuer.NewDemoOnChain([]byte("bulkimport"), idenPubOnChain)
+	require.Nil(t, err)
+	is := demo.Issuer
+	ts, err := issuer.ParseClaimTemplates([]byte(templates))
+	require.Nil(t, err)
+	require.Nil(t, is.SetClaimTemplates(ts...))
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	// A single identity state is published for the whole import
+	idenPubOnChain.On("InitState", is.ID(), mock.Anything, mock.Anything, []byte(nil), []byte(nil), mock.Anything).
+		Return(ethTx, nil).Once()
+	return is
+}
+
+func TestReadRows(t *testing.T) {
+	rows, err := ReadCSV(strings.NewReader("subject,level,points\n" +
+		subject0.String() + ",gold,10\n" +
+		"invalid,gold,10\n" +
+		",silver,\n"))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(rows))
+	assert.Equal(t, Row{TemplateData: issuer.TemplateData{Subject: &subject0,
+		Data: map[string]interface{}{"level": "gold", "points": "10"}}}, rows[0])
+	assert.NotNil(t, rows[1].Err)
+	assert.Equal(t, Row{TemplateData: issuer.TemplateData{Data: map[string]interface{}{"level": "silver"}}}, rows[2])
+
+	_, err = ReadCSV(strings.NewReader("subject,level\n" + subject0.String() + "\n"))
+	assert.NotNil(t, err)
+
+	rows, err = ReadJSONL(strings.NewReader(`{"subject": "` + subject0.String() + `", "level": "gold", "points": 10}` +
+		"\n\n{invalid\n" + `{"level": "silver"}` + "\n"))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(rows))
+	assert.Equal(t, Row{TemplateData: issuer.TemplateData{Subject: &subject0,
+		Data: map[string]interface{}{"level": "gold", "points": json.Number("10")}}}, rows[0])
+	assert.NotNil(t, rows[1].Err)
+	assert.Nil(t, rows[2].Err)
+
+	_, err = ReadRows(strings.NewReader(""), "xml")
+	assert.Equal(t, ErrUnknownFormat, err)
+}
+
+func TestImport(t *testing.T) {
+	is := newIssuer(t)
+	rows, err := ReadJSONL(strings.NewReader(
+		`{"subject": "` + subject0.String() + `", "level": "gold", "points": 10}` + "\n" +
+			`{"level": "gold"}` + "\n" +
+			`{"subject": "invalid", "level": "gold"}` + "\n" +
+			`{"subject": "` + subject1.String() + `", "level": "silver"}` + "\n"))
+	require.Nil(t, err)
+
+	_, err = Import(is, "unknown", rows)
+	assert.Equal(t, issuer.ErrTemplateNotFound, err)
+
+	report, err := Import(is, "membership", rows)
+	require.Nil(t, err)
+	assert.Equal(t, 2, report.Issued)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, issuer.PublishStateSubmitted.String(), report.PublishStatus)
+	idenState, _ := is.State()
+	assert.Equal(t, idenState, report.IdenState)
+	for i, result := range report.Results {
+		assert.Equal(t, i+1, result.Row)
+	}
+	for _, i := range []int{0, 3} {
+		require.NotNil(t, report.Results[i].HIndex)
+		_, err := is.ClaimByHIndex(report.Results[i].HIndex)
+		require.Nil(t, err)
+	}
+	assert.Equal(t, issuer.ErrTemplateSubjectUnset.Error(), report.Results[1].Error)
+	assert.Contains(t, report.Results[2].Error, "invalid subject")
+
+	var out bytes.Buffer
+	require.Nil(t, WriteResults(&out, FormatCSV, report.Results))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, 5, len(lines))
+	assert.Equal(t, "row,hIndex,error", lines[0])
+	assert.Equal(t, "1,"+report.Results[0].HIndex.Hex()+",", lines[1])
+	out.Reset()
+	require.Nil(t, WriteResults(&out, FormatJSONL, report.Results))
+	var result Result
+	require.Nil(t, json.Unmarshal([]byte(strings.Split(out.String(), "\n")[3]), &result))
+	assert.Equal(t, report.Results[3], result)
+}
+
+func TestHandler(t *testing.T) {
+	is := newIssuer(t)
+	server := httptest.NewServer(Handler(is))
+	defer server.Close()
+
+	post := func(query, contentType, body string) *http.Response {
+		res, err := http.Post(server.URL+PathImport+query, contentType, strings.NewReader(body))
+		require.Nil(t, err)
+		return res
+	}
+	csvBody := "subject,level\n" + subject0.String() + ",gold\n" + subject1.String() + ",gold\n"
+	res := post("?template=membership", "text/csv; charset=utf-8", csvBody)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var report Report
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&report))
+	assert.Equal(t, 2, report.Issued)
+	assert.Equal(t, 0, report.Failed)
+
+	for _, test := range []struct {
+		query, contentType string
+		status             int
+	}{
+		{"", "text/csv", http.StatusBadRequest},
+		{"?template=membership", "text/plain", http.StatusUnsupportedMediaType},
+		{"?template=unknown", "text/csv", http.StatusNotFound},
+	} {
+		res := post(test.query, test.contentType, csvBody)
+		res.Body.Close()
+		assert.Equal(t, test.status, res.StatusCode, test)
+	}
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "bulkimport")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "members.csv")
+	resultsPath := filepath.Join(dir, "results.csv")
+	require.Nil(t, ioutil.WriteFile(filePath, []byte("subject,level\n"+subject0.String()+",gold\n,gold\n"), 0644))
+
+	is := newIssuer(t)
+	app := cli.NewApp()
+	var out bytes.Buffer
+	app.Writer = &out
+	app.Commands = Commands(func(c *cli.Context) (Issuer, error) { return is, nil })
+
+	assert.NotNil(t, app.Run([]string{"app", "claims", "import", filePath, resultsPath}))
+	require.Nil(t, app.Run([]string{"app", "claims", "import", "--template", "membership", filePath, resultsPath}))
+	assert.True(t, strings.HasPrefix(out.String(), "issued 1 claims, 1 failed\n"))
+	results, err := ioutil.ReadFile(resultsPath)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(results)), "\n")
+	require.Equal(t, 3, len(lines))
+	assert.Equal(t, "2,,"+issuer.ErrTemplateSubjectUnset.Error(), lines[2])
+}
+
+func TestHandlerJobs(t *testing.T) {
+	is := newIssuer(t)
+	m, err := jobs.New(db.NewMemoryStorage(), clock.Real)
+	require.Nil(t, err)
+	server := httptest.NewServer(HandlerJobs(is, m))
+	defer server.Close()
+
+	csvBody := "subject,level\n" + subject0.String() + ",gold\n,gold\n"
+	res, err := http.Post(server.URL+PathImport+"?template=membership&async=true", "text/csv", strings.NewReader(csvBody))
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusAccepted, res.StatusCode)
+	var job jobs.Job
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&job))
+	assert.Equal(t, JobKind, job.Kind)
+
+	finished, err := m.Wait(context.Background(), job.ID)
+	require.Nil(t, err)
+	assert.Equal(t, jobs.StatusSucceeded, finished.Status)
+	assert.Equal(t, 100, finished.Progress)
+	assert.Equal(t, 2, finished.Total)
+	var report Report
+	require.Nil(t, json.Unmarshal(finished.Result, &report))
+	assert.Equal(t, 1, report.Issued)
+	assert.Equal(t, 1, report.Failed)
+
+	res, err = http.Post(server.URL+PathImport+"?template=unknown&async=true", "text/csv", strings.NewReader(csvBody))
+	require.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusNotFound, res.StatusCode)
+}
@@ -0,0 +1,105 @@
+package bulkimport
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/urfave/cli.v1"
+)
+
+// LoadIssuerFunc builds the Issuer used by the CLI subcommands from the
+// command context.
+type LoadIssuerFunc func(c *cli.Context) (Issuer, error)
+
+// Commands returns the bulk import CLI subcommands, to be registered in a
+// cli.App.
+func Commands(loadIssuer LoadIssuerFunc) []cli.Command {
+	return []cli.Command{
+		{
+			Name:  "claims",
+			Usage: "manage the issued claims",
+			Subcommands: []cli.Command{
+				{
+					Name:      "import",
+					Usage:     "issue the claims of a CSV or JSONL file with a template",
+					ArgsUsage: "FILE RESULTS",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "template",
+							Usage: "issue the claims with the template `ID`",
+						},
+						cli.StringFlag{
+							Name:  "format",
+							Usage: "`FORMAT` of FILE and RESULTS: csv or jsonl (default: from the FILE extension)",
+						},
+					},
+					Action: cmdImport(loadIssuer),
+				},
+			},
+		},
+	}
+}
+
+// formatFromPath returns the format of the file at path by its extension.
+func formatFromPath(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		return FormatCSV
+	case ".jsonl", ".ndjson":
+		return FormatJSONL
+	default:
+		return ""
+	}
+}
+
+func cmdImport(loadIssuer LoadIssuerFunc) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if c.NArg() != 2 {
+			return fmt.Errorf("expected 2 arguments, got %v", c.NArg())
+		}
+		if c.String("template") == "" {
+			return fmt.Errorf("the template is required")
+		}
+		format := c.String("format")
+		if format == "" {
+			format = formatFromPath(c.Args().Get(0))
+		}
+		file, err := os.Open(c.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		rows, err := ReadRows(file, format)
+		if err != nil {
+			return err
+		}
+		is, err := loadIssuer(c)
+		if err != nil {
+			return err
+		}
+		report, err := Import(is, c.String("template"), rows)
+		if err != nil {
+			return err
+		}
+		results, err := os.Create(c.Args().Get(1))
+		if err != nil {
+			return err
+		}
+		if err := WriteResults(results, format, report.Results); err != nil {
+			results.Close()
+			return err
+		}
+		if err := results.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "issued %v claims, %v failed\n", report.Issued, report.Failed)
+		if report.PublishError != "" {
+			return fmt.Errorf("publish identity state: %v", report.PublishError)
+		} else if report.PublishStatus != "" {
+			fmt.Fprintf(c.App.Writer, "identity state %v: %v\n", report.IdenState.Hex(), report.PublishStatus)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,403 @@
+// Package centrauth implements a centralized authentication service where an
+// identity proves the control of one of its keys by signing a nonce, and
+// gets in exchange a session token to access other endpoints:
+//
+//	GET /auth/nonce
+//	{"nonce": "<hex>", "expiration": <unix>}
+//
+//	POST /auth
+//	{"id": "<id>", "nonce": "<hex>", "credKSign": {...}, "signature": [...]}
+//	{"token": "<token>", "expiration": <unix>}
+//
+// The signature is made with the key of the ClaimAuthorizeKSignBabyJub in
+// credKSign over SigPrefixAuth|nonce (see issuer.Issuer.SignBinary).
+//
+// The lifecycle of a nonce is: it's created by the nonce endpoint and stored
+// with an expiration of Config.NonceTTL; it's marked as used by the first
+// auth request that uses it, whether the signature is valid or not, so that it
+// can't be replayed; and the nonces are deleted once they expire by
+// NonceStore.Prune, which NonceStore.New runs every Config.NonceTTL.  As the
+// nonce endpoint is unauthenticated, the pending nonces are limited to
+// Config.MaxPendingNonces, after which the endpoint fails with 503 until some
+// expire.  The session tokens are by default opaque, authenticated
+// with a key of the service and valid for Config.SessionTTL, or JWTs whose
+// subject is the identity (see JWT), which other backends can verify without
+// knowing about iden3.  The downstream endpoints require a session with
+// Middleware.
+package centrauth
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-core/utils/noncestore"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathNonce is the path of the endpoint that returns a new nonce.
+const PathNonce = "/auth/nonce"
+
+// PathAuth is the path of the endpoint that authenticates an identity.
+const PathAuth = "/auth"
+
+// SigPrefixAuth is the prefix of the signed nonces.
+var SigPrefixAuth = []byte("centrauth:")
+
+// nonceLen is the length in bytes of the nonces.
+const nonceLen = 32
+
+var (
+	// ErrNonceNotFound is returned when a nonce is unknown, already used
+	// or expired.
+	ErrNonceNotFound = errors.New("nonce not found, used or expired")
+	// ErrTooManyNonces is returned when a new nonce is requested while
+	// the limit of pending nonces is reached.
+	ErrTooManyNonces  = errors.New("too many pending nonces")
+	ErrSessionInvalid = errors.New("invalid session token")
+	ErrSessionExpired = errors.New("session token expired")
+)
+
+// Config is the configuration of the Service.
+type Config struct {
+	// NonceTTL is the time a nonce can be used since it's created.
+	NonceTTL time.Duration
+	// SessionTTL is the time a session token is valid since it's issued.
+	SessionTTL time.Duration
+	// MaxPendingNonces is the maximum number of created nonces that have
+	// not expired, used or not.  0 means no limit.
+	MaxPendingNonces int
+}
+
+// ConfigDefault is the default configuration of the Service.
+var ConfigDefault = Config{NonceTTL: 5 * time.Minute, SessionTTL: 24 * time.Hour,
+	MaxPendingNonces: 100000}
+
+// NonceStore keeps in a storage the nonces that have been created, with their
+// expiration.  The expired nonces are pruned by New at most every ttl.
+type NonceStore struct {
+	store *noncestore.Store
+	ttl   time.Duration
+	clock clock.Clock
+	// mutex serializes the News, so that the expired nonces are pruned
+	// once when the limit is reached.
+	mutex     sync.Mutex
+	lastPrune time.Time
+}
+
+// NewNonceStore creates a new NonceStore that stores the nonces in storage
+// with an expiration of ttl.
+func NewNonceStore(storage db.Storage, ttl time.Duration, clk clock.Clock) *NonceStore {
+	return &NonceStore{
+		store: noncestore.New(storage),
+		ttl:   ttl,
+		clock: clk,
+	}
+}
+
+// SetMaxPending limits the nonces that have not expired to max, after which
+// New returns ErrTooManyNonces.  0 means no limit.
+func (s *NonceStore) SetMaxPending(max int) {
+	s.store.SetMax(max)
+}
+
+// New creates and stores a new random nonce, and returns it with its
+// expiration.  It prunes the expired nonces if they were last pruned more
+// than ttl ago, or if the limit of pending nonces is reached, in which case it
+// returns ErrTooManyNonces if none expired.
+func (s *NonceStore) New() (string, time.Time, error) {
+	var nonce [nonceLen]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return "", time.Time{}, err
+	}
+	now := s.clock.Now()
+	expiration := time.Unix(now.Add(s.ttl).Unix(), 0)
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if now.Sub(s.lastPrune) >= s.ttl {
+		if _, err := s.prune(now); err != nil {
+			return "", time.Time{}, err
+		}
+	}
+	err := s.store.Issue(nonce[:], expiration)
+	if err == noncestore.ErrFull {
+		if _, err := s.prune(now); err != nil {
+			return "", time.Time{}, err
+		}
+		err = s.store.Issue(nonce[:], expiration)
+	}
+	if err == noncestore.ErrFull {
+		return "", time.Time{}, ErrTooManyNonces
+	} else if err != nil {
+		return "", time.Time{}, err
+	}
+	return hex.EncodeToString(nonce[:]), expiration, nil
+}
+
+// Use marks nonce as used so that it can't be used again.  It returns
+// ErrNonceNotFound if nonce is unknown, already used or expired.
+func (s *NonceStore) Use(nonce string) error {
+	nonceBytes, err := hex.DecodeString(nonce)
+	if err != nil || len(nonceBytes) != nonceLen {
+		return ErrNonceNotFound
+	}
+	_, err = s.store.Use(nonceBytes, s.clock.Now())
+	switch err {
+	case noncestore.ErrUnknown, noncestore.ErrUsed, noncestore.ErrExpired:
+		return ErrNonceNotFound
+	}
+	return err
+}
+
+// Prune deletes the expired nonces and returns how many were deleted.
+func (s *NonceStore) Prune() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.prune(s.clock.Now())
+}
+
+func (s *NonceStore) prune(now time.Time) (int, error) {
+	s.lastPrune = now
+	return s.store.Prune(now)
+}
+
+// Sessions issues and verifies opaque session tokens, made of the identity
+// and the expiration authenticated with HMAC-SHA256.
+type Sessions struct {
+	key   []byte
+	ttl   time.Duration
+	clock clock.Clock
+}
+
+// NewSessions creates a new Sessions that authenticates the tokens with key
+// and issues them with an expiration of ttl.
+func NewSessions(key []byte, ttl time.Duration, clk clock.Clock) *Sessions {
+	return &Sessions{key: key, ttl: ttl, clock: clk}
+}
+
+func (s *Sessions) mac(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Issue returns a new session token of id, and its expiration.
+func (s *Sessions) Issue(id *core.ID) (string, time.Time, error) {
+	expiration := s.clock.Now().Add(s.ttl).Unix()
+	payload := make([]byte, len(id)+8)
+	copy(payload, id[:])
+	binary.BigEndian.PutUint64(payload[len(id):], uint64(expiration))
+	return base64.RawURLEncoding.EncodeToString(payload) + "." +
+		base64.RawURLEncoding.EncodeToString(s.mac(payload)), time.Unix(expiration, 0), nil
+}
+
+// Verify returns the identity of a session token issued by s, or
+// ErrSessionInvalid or ErrSessionExpired.
+func (s *Sessions) Verify(token string) (*core.ID, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return nil, ErrSessionInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrSessionInvalid
+	}
+	var id core.ID
+	if len(payload) != len(id)+8 || !hmac.Equal(mac, s.mac(payload)) {
+		return nil, ErrSessionInvalid
+	}
+	copy(id[:], payload)
+	if s.clock.Now().Unix() >= int64(binary.BigEndian.Uint64(payload[len(id):])) {
+		return nil, ErrSessionExpired
+	}
+	return &id, nil
+}
+
+// Tokens issues and verifies the session tokens of the identities, satisfied
+// by Sessions and JWT.
+type Tokens interface {
+	// Issue returns a new session token of id, and its expiration.
+	Issue(id *core.ID) (string, time.Time, error)
+	// Verify returns the identity of a session token, or an error
+	// wrapping ErrSessionInvalid or ErrSessionExpired.
+	Verify(token string) (*core.ID, error)
+}
+
+// SignatureVerifier verifies the signatures of the identities, satisfied by
+// sigverify.SigVerifier, which also checks that the key is not revoked.  A
+// verifier.Verifier must have a verifier.RevocationChecker (see
+// verifier.Verifier.SetRevocationChecker) so that a revoked key can't
+// authenticate.
+type SignatureVerifier interface {
+	VerifySignature(id *core.ID, credKSign *proof.CredentialExistence,
+		prefix, msg []byte, sig *babyjub.SignatureComp) error
+}
+
+// NonceResponse is the body of the nonce endpoint response.
+type NonceResponse struct {
+	Nonce      string `json:"nonce"`
+	Expiration int64  `json:"expiration"`
+}
+
+// AuthRequest is the body of an auth request.
+type AuthRequest struct {
+	Id        *core.ID                   `json:"id"`
+	Nonce     string                     `json:"nonce"`
+	CredKSign *proof.CredentialExistence `json:"credKSign"`
+	Signature *babyjub.SignatureComp     `json:"signature"`
+}
+
+// AuthResponse is the body of the auth endpoint response.
+type AuthResponse struct {
+	Token      string `json:"token"`
+	Expiration int64  `json:"expiration"`
+}
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// Service authenticates identities and issues them session tokens.
+type Service struct {
+	Nonces   *NonceStore
+	Tokens   Tokens
+	verifier SignatureVerifier
+}
+
+// New creates a new Service that stores the nonces in storage, issues opaque
+// session tokens (see Sessions) signed with key and verifies the signatures of
+// the identities with verifier.
+func New(cfg Config, storage db.Storage, key []byte, verifier SignatureVerifier, clk clock.Clock) *Service {
+	return NewWithTokens(cfg, storage, NewSessions(key, cfg.SessionTTL, clk), verifier, clk)
+}
+
+// NewWithTokens creates a new Service like New that issues the session tokens
+// with tokens, like a JWT.  cfg.SessionTTL is not used.
+func NewWithTokens(cfg Config, storage db.Storage, tokens Tokens, verifier SignatureVerifier, clk clock.Clock) *Service {
+	nonces := NewNonceStore(storage, cfg.NonceTTL, clk)
+	nonces.SetMaxPending(cfg.MaxPendingNonces)
+	return &Service{
+		Nonces:   nonces,
+		Tokens:   tokens,
+		verifier: verifier,
+	}
+}
+
+// Auth authenticates the identity of req and returns a new session token and
+// its expiration.  The nonce of req is used even if the authentication fails.
+func (s *Service) Auth(req *AuthRequest) (string, time.Time, error) {
+	if err := s.Nonces.Use(req.Nonce); err != nil {
+		return "", time.Time{}, err
+	}
+	if err := s.verifier.VerifySignature(req.Id, req.CredKSign, SigPrefixAuth,
+		[]byte(req.Nonce), req.Signature); err != nil {
+		return "", time.Time{}, err
+	}
+	return s.Tokens.Issue(req.Id)
+}
+
+// Handler returns an http.Handler that serves the nonce and auth endpoints.
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathNonce, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		nonce, expiration, err := s.Nonces.New()
+		if err == ErrTooManyNonces {
+			w.Header().Set("Retry-After", "60")
+			httpjson.WriteError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		} else if err != nil {
+			log.WithError(err).Error("NonceStore.New")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, NonceResponse{Nonce: nonce, Expiration: expiration.Unix()})
+	})
+	mux.HandleFunc(PathAuth, s.handleAuth)
+	return mux
+}
+
+func (s *Service) handleAuth(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var authReq AuthRequest
+	if err := json.NewDecoder(req.Body).Decode(&authReq); err != nil {
+		httpjson.WriteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+		return
+	} else if authReq.Id == nil || authReq.CredKSign == nil || authReq.Signature == nil {
+		httpjson.WriteError(w, http.StatusBadRequest, "missing id, credKSign or signature")
+		return
+	}
+	token, expiration, err := s.Auth(&authReq)
+	if err == ErrNonceNotFound {
+		httpjson.WriteError(w, http.StatusUnauthorized, err.Error())
+		return
+	} else if err != nil {
+		log.WithError(err).WithField("id", authReq.Id).Info("Authentication failed")
+		httpjson.WriteError(w, http.StatusUnauthorized, "authentication failed: "+err.Error())
+		return
+	}
+	httpjson.Write(w, http.StatusOK, AuthResponse{Token: token, Expiration: expiration.Unix()})
+}
+
+type contextKey struct{}
+
+// IDFromContext returns the identity authenticated by Service.Middleware.
+func IDFromContext(ctx context.Context) (*core.ID, bool) {
+	id, ok := ctx.Value(contextKey{}).(*core.ID)
+	return id, ok
+}
+
+// Middleware returns an http.Handler that requires a valid session token in
+// the header "Authorization: Bearer <token>" and calls next with the
+// authenticated identity in the request context (see IDFromContext).
+func (s *Service) Middleware(next http.Handler) http.Handler {
+	return Middleware(s.Tokens, next)
+}
+
+// Middleware returns an http.Handler that requires a session token verified
+// by tokens in the header "Authorization: Bearer <token>" and calls next with
+// the authenticated identity in the request context (see IDFromContext).  It
+// allows the backends that only verify the tokens, like a JWT without the
+// signing key, to require an authenticated identity.
+func Middleware(tokens Tokens, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Bearer ") {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpjson.WriteError(w, http.StatusUnauthorized, "missing session token")
+			return
+		}
+		id, err := tokens.Verify(strings.TrimPrefix(auth, "Bearer "))
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpjson.WriteError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), contextKey{}, id)))
+	})
+}
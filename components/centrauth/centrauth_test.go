@@ -0,0 +1,221 @@
+package centrauth
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sigVerifier is a SignatureVerifier that accepts the signatures of sk for
+// any identity.
+type sigVerifier struct {
+	sk *babyjub.PrivateKey
+}
+
+func (v *sigVerifier) VerifySignature(id *core.ID, credKSign *proof.CredentialExistence,
+	prefix, msg []byte, sig *babyjub.SignatureComp) error {
+	if v.sk.SignPoseidon(v.hash(prefix, msg)).Compress() != *sig {
+		return errors.New("invalid signature")
+	}
+	return nil
+}
+
+func (v *sigVerifier) hash(prefix, msg []byte) *big.Int {
+	h := sha256.Sum256(append(append([]byte{}, prefix...), msg...))
+	return new(big.Int).SetBytes(h[:31])
+}
+
+func (v *sigVerifier) sign(msg []byte) *babyjub.SignatureComp {
+	sig := v.sk.SignPoseidon(v.hash(SigPrefixAuth, msg)).Compress()
+	return &sig
+}
+
+func TestNonceStore(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	nonces := NewNonceStore(db.NewMemoryStorage(), time.Minute, clk)
+
+	nonce0, expiration, err := nonces.New()
+	require.Nil(t, err)
+	assert.Equal(t, time.Unix(1060, 0), expiration)
+	nonce1, _, err := nonces.New()
+	require.Nil(t, err)
+	assert.NotEqual(t, nonce0, nonce1)
+
+	require.Nil(t, nonces.Use(nonce0))
+	assert.Equal(t, ErrNonceNotFound, nonces.Use(nonce0))
+	assert.Equal(t, ErrNonceNotFound, nonces.Use("invalid"))
+
+	clk.Advance(time.Minute)
+	nonce2, _, err := nonces.New()
+	require.Nil(t, err)
+	// The expired nonce1 was pruned by New.
+	n, err := nonces.Prune()
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	assert.Equal(t, ErrNonceNotFound, nonces.Use(nonce1))
+	require.Nil(t, nonces.Use(nonce2))
+}
+
+func TestNonceStoreMaxPending(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	storage := db.NewMemoryStorage()
+	nonces := NewNonceStore(storage, time.Minute, clk)
+	nonce0, _, err := nonces.New()
+	require.Nil(t, err)
+
+	// The pending nonces in storage are counted.
+	nonces = NewNonceStore(storage, time.Minute, clk)
+	nonces.SetMaxPending(2)
+	_, _, err = nonces.New()
+	require.Nil(t, err)
+	_, _, err = nonces.New()
+	assert.Equal(t, ErrTooManyNonces, err)
+
+	// The used nonces are kept until they expire.
+	require.Nil(t, nonces.Use(nonce0))
+	_, _, err = nonces.New()
+	assert.Equal(t, ErrTooManyNonces, err)
+
+	// The expired nonces are pruned to make room.
+	clk.Advance(time.Minute)
+	_, _, err = nonces.New()
+	require.Nil(t, err)
+}
+
+func TestSessions(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	sessions := NewSessions([]byte("key"), time.Hour, clk)
+	id := core.NewID(core.TypeBJP0, [27]byte{1})
+
+	token, expiration, err := sessions.Issue(&id)
+	require.Nil(t, err)
+	assert.Equal(t, time.Unix(4600, 0), expiration)
+	id2, err := sessions.Verify(token)
+	require.Nil(t, err)
+	assert.Equal(t, id, *id2)
+
+	_, err = NewSessions([]byte("other"), time.Hour, clk).Verify(token)
+	assert.Equal(t, ErrSessionInvalid, err)
+	_, err = sessions.Verify("invalid")
+	assert.Equal(t, ErrSessionInvalid, err)
+
+	clk.Advance(time.Hour)
+	_, err = sessions.Verify(token)
+	assert.Equal(t, ErrSessionExpired, err)
+}
+
+func auth(t *testing.T, url string, authReq *AuthRequest) (*http.Response, *AuthResponse) {
+	body, err := json.Marshal(authReq)
+	require.Nil(t, err)
+	res, err := http.Post(url+PathAuth, "application/json", bytes.NewReader(body))
+	require.Nil(t, err)
+	defer res.Body.Close()
+	var authRes AuthResponse
+	if res.StatusCode == http.StatusOK {
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&authRes))
+	}
+	return res, &authRes
+}
+
+func TestService(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	verifier := &sigVerifier{sk: &babyjub.PrivateKey{1}}
+	s := New(ConfigDefault, db.NewMemoryStorage(), []byte("key"), verifier, clk)
+	id := core.NewID(core.TypeBJP0, [27]byte{1})
+
+	mux := http.NewServeMux()
+	mux.Handle(PathAuth, s.Handler())
+	mux.Handle(PathNonce, s.Handler())
+	mux.Handle("/private", s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		id, ok := IDFromContext(req.Context())
+		require.True(t, ok)
+		w.Write([]byte(id.String()))
+	})))
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	newNonce := func() string {
+		res, err := http.Get(server.URL + PathNonce)
+		require.Nil(t, err)
+		defer res.Body.Close()
+		var nonceRes NonceResponse
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&nonceRes))
+		assert.Equal(t, int64(1000+5*60), nonceRes.Expiration)
+		return nonceRes.Nonce
+	}
+
+	// A nonce can't be used after a failed authentication
+	nonce := newNonce()
+	authReq := AuthRequest{Id: &id, Nonce: nonce, CredKSign: &proof.CredentialExistence{},
+		Signature: verifier.sign([]byte("other"))}
+	res, _ := auth(t, server.URL, &authReq)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	authReq.Signature = verifier.sign([]byte(nonce))
+	res, _ = auth(t, server.URL, &authReq)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	// Nor replayed
+	nonce = newNonce()
+	authReq.Nonce, authReq.Signature = nonce, verifier.sign([]byte(nonce))
+	res, authRes := auth(t, server.URL, &authReq)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Equal(t, int64(1000+24*60*60), authRes.Expiration)
+	res, _ = auth(t, server.URL, &authReq)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+
+	get := func(token string) int {
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/private", nil)
+		require.Nil(t, err)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, get(authRes.Token))
+	assert.Equal(t, http.StatusUnauthorized, get(""))
+	assert.Equal(t, http.StatusUnauthorized, get(authRes.Token+"0"))
+
+	// Expired nonces and sessions
+	nonce = newNonce()
+	clk.Advance(ConfigDefault.NonceTTL)
+	authReq.Nonce, authReq.Signature = nonce, verifier.sign([]byte(nonce))
+	res, _ = auth(t, server.URL, &authReq)
+	assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	clk.Advance(ConfigDefault.SessionTTL)
+	assert.Equal(t, http.StatusUnauthorized, get(authRes.Token))
+}
+
+func TestServiceMaxPendingNonces(t *testing.T) {
+	cfg := ConfigDefault
+	cfg.MaxPendingNonces = 1
+	s := New(cfg, db.NewMemoryStorage(), []byte("key"), &sigVerifier{sk: &babyjub.PrivateKey{1}},
+		clock.NewFake(time.Unix(1000, 0)))
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	getNonce := func() int {
+		res, err := http.Get(server.URL + PathNonce)
+		require.Nil(t, err)
+		res.Body.Close()
+		return res.StatusCode
+	}
+	assert.Equal(t, http.StatusOK, getNonce())
+	assert.Equal(t, http.StatusServiceUnavailable, getNonce())
+}
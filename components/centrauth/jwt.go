@@ -0,0 +1,220 @@
+package centrauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/utils/clock"
+)
+
+// Algorithms of the JWT signatures.
+const (
+	JWTAlgHS256 = "HS256"
+	JWTAlgES256 = "ES256"
+)
+
+var (
+	ErrJWTSigningKeyNotSet = errors.New("the JWT signing key is not set")
+)
+
+// JWTConfig is the configuration of the JWTs.
+type JWTConfig struct {
+	// Issuer is the "iss" claim of the JWTs.  If not empty, the verified
+	// JWTs must have it.
+	Issuer string
+	// Audience is the "aud" claim of the JWTs.  If not empty, the verified
+	// JWTs must have it.
+	Audience string
+	// TTL is the time a JWT is valid since it's issued.
+	TTL time.Duration
+}
+
+// JWTClaims are the claims of the JWTs, where the subject is the identity.
+type JWTClaims struct {
+	Issuer    string `json:"iss,omitempty"`
+	Subject   string `json:"sub"`
+	Audience  string `json:"aud,omitempty"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Typ string `json:"typ"`
+}
+
+// JWT issues and verifies session tokens as standard JSON Web Tokens
+// (RFC 7519) signed with HMAC-SHA256 (HS256) or ECDSA P-256 (ES256), so that
+// the web backends can consume the iden3 authentications with any JWT
+// library.
+type JWT struct {
+	cfg     JWTConfig
+	alg     string
+	hmacKey []byte
+	sk      *ecdsa.PrivateKey
+	pk      *ecdsa.PublicKey
+	clock   clock.Clock
+}
+
+// NewJWTHS256 creates a new JWT that signs and verifies with the HMAC key.
+func NewJWTHS256(cfg JWTConfig, key []byte, clk clock.Clock) *JWT {
+	return &JWT{cfg: cfg, alg: JWTAlgHS256, hmacKey: key, clock: clk}
+}
+
+// NewJWTES256 creates a new JWT that signs with the P-256 key sk.
+func NewJWTES256(cfg JWTConfig, sk *ecdsa.PrivateKey, clk clock.Clock) *JWT {
+	return &JWT{cfg: cfg, alg: JWTAlgES256, sk: sk, pk: &sk.PublicKey, clock: clk}
+}
+
+// NewJWTES256Verifier creates a new JWT that only verifies with the P-256
+// public key pk.  Issue returns ErrJWTSigningKeyNotSet.
+func NewJWTES256Verifier(cfg JWTConfig, pk *ecdsa.PublicKey, clk clock.Clock) *JWT {
+	return &JWT{cfg: cfg, alg: JWTAlgES256, pk: pk, clock: clk}
+}
+
+func (j *JWT) sign(signingInput []byte) ([]byte, error) {
+	switch j.alg {
+	case JWTAlgHS256:
+		mac := hmac.New(sha256.New, j.hmacKey)
+		mac.Write(signingInput)
+		return mac.Sum(nil), nil
+	case JWTAlgES256:
+		if j.sk == nil {
+			return nil, ErrJWTSigningKeyNotSet
+		}
+		h := sha256.Sum256(signingInput)
+		r, s, err := ecdsa.Sign(rand.Reader, j.sk, h[:])
+		if err != nil {
+			return nil, err
+		}
+		// r and s are encoded as 32 byte big endian integers
+		sig := make([]byte, 64)
+		rBytes, sBytes := r.Bytes(), s.Bytes()
+		copy(sig[32-len(rBytes):32], rBytes)
+		copy(sig[64-len(sBytes):], sBytes)
+		return sig, nil
+	default:
+		return nil, fmt.Errorf("unsupported JWT algorithm %v", j.alg)
+	}
+}
+
+func (j *JWT) verify(signingInput, sig []byte) bool {
+	switch j.alg {
+	case JWTAlgHS256:
+		mac := hmac.New(sha256.New, j.hmacKey)
+		mac.Write(signingInput)
+		return hmac.Equal(sig, mac.Sum(nil))
+	case JWTAlgES256:
+		if len(sig) != 64 || j.pk.Curve != elliptic.P256() {
+			return false
+		}
+		h := sha256.Sum256(signingInput)
+		return ecdsa.Verify(j.pk, h[:], new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:]))
+	default:
+		return false
+	}
+}
+
+func encodeSegment(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Issue returns a new JWT of id, and its expiration.
+func (j *JWT) Issue(id *core.ID) (string, time.Time, error) {
+	now := j.clock.Now().Unix()
+	expiration := now + int64(j.cfg.TTL/time.Second)
+	header, err := encodeSegment(jwtHeader{Alg: j.alg, Typ: "JWT"})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	claims, err := encodeSegment(JWTClaims{
+		Issuer:    j.cfg.Issuer,
+		Subject:   id.String(),
+		Audience:  j.cfg.Audience,
+		IssuedAt:  now,
+		ExpiresAt: expiration,
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	signingInput := header + "." + claims
+	sig, err := j.sign([]byte(signingInput))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(sig), time.Unix(expiration, 0), nil
+}
+
+// Claims returns the claims of a JWT after verifying its signature,
+// algorithm, issuer, audience and expiration.
+func (j *JWT) Claims(token string) (*JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrSessionInvalid
+	}
+	var header jwtHeader
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, err
+	}
+	// The algorithm is fixed by the key, never by the header
+	if header.Alg != j.alg {
+		return nil, fmt.Errorf("%w: unexpected algorithm %v", ErrSessionInvalid, header.Alg)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !j.verify([]byte(parts[0]+"."+parts[1]), sig) {
+		return nil, ErrSessionInvalid
+	}
+	var claims JWTClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if j.cfg.Issuer != "" && claims.Issuer != j.cfg.Issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %v", ErrSessionInvalid, claims.Issuer)
+	}
+	if j.cfg.Audience != "" && claims.Audience != j.cfg.Audience {
+		return nil, fmt.Errorf("%w: unexpected audience %v", ErrSessionInvalid, claims.Audience)
+	}
+	if j.clock.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrSessionExpired
+	}
+	return &claims, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return ErrSessionInvalid
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionInvalid, err)
+	}
+	return nil
+}
+
+// Verify returns the identity of the subject of a JWT.
+func (j *JWT) Verify(token string) (*core.ID, error) {
+	claims, err := j.Claims(token)
+	if err != nil {
+		return nil, err
+	}
+	id, err := core.IDFromString(claims.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid subject: %v", ErrSessionInvalid, err)
+	}
+	return &id, nil
+}
@@ -0,0 +1,109 @@
+package centrauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWT(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	cfg := JWTConfig{Issuer: "https://auth.example.com", Audience: "backend", TTL: time.Hour}
+	id := core.NewID(core.TypeBJP0, [27]byte{1})
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+
+	hs256 := NewJWTHS256(cfg, []byte("key"), clk)
+	es256 := NewJWTES256(cfg, sk, clk)
+	es256Verifier := NewJWTES256Verifier(cfg, &sk.PublicKey, clk)
+	for _, test := range []struct {
+		signer, verifier *JWT
+	}{{hs256, hs256}, {es256, es256}, {es256, es256Verifier}} {
+		token, expiration, err := test.signer.Issue(&id)
+		require.Nil(t, err)
+		assert.Equal(t, time.Unix(4600, 0), expiration)
+		id2, err := test.verifier.Verify(token)
+		require.Nil(t, err)
+		assert.Equal(t, id, *id2)
+	}
+	_, _, err = es256Verifier.Issue(&id)
+	assert.Equal(t, ErrJWTSigningKeyNotSet, err)
+
+	token, _, err := hs256.Issue(&id)
+	require.Nil(t, err)
+	parts := strings.Split(token, ".")
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.Nil(t, err)
+	var claims JWTClaims
+	require.Nil(t, json.Unmarshal(claimsJSON, &claims))
+	assert.Equal(t, JWTClaims{Issuer: cfg.Issuer, Subject: id.String(), Audience: cfg.Audience,
+		IssuedAt: 1000, ExpiresAt: 4600}, claims)
+
+	// The tokens of another key, algorithm or audience are not valid
+	for _, verifier := range []*JWT{
+		NewJWTHS256(cfg, []byte("other"), clk),
+		es256,
+		NewJWTHS256(JWTConfig{Audience: "other"}, []byte("key"), clk),
+	} {
+		_, err := verifier.Verify(token)
+		assert.True(t, errors.Is(err, ErrSessionInvalid))
+	}
+	_, err = hs256.Verify(parts[0] + "." + parts[1] + ".")
+	assert.Equal(t, ErrSessionInvalid, err)
+
+	clk.Advance(time.Hour)
+	_, err = hs256.Verify(token)
+	assert.Equal(t, ErrSessionExpired, err)
+}
+
+func TestServiceJWT(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	cfg := JWTConfig{Audience: "backend", TTL: time.Hour}
+	sk, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	verifier := &sigVerifier{sk: &babyjub.PrivateKey{1}}
+	s := NewWithTokens(ConfigDefault, db.NewMemoryStorage(), NewJWTES256(cfg, sk, clk), verifier, clk)
+	id := core.NewID(core.TypeBJP0, [27]byte{1})
+
+	nonce, _, err := s.Nonces.New()
+	require.Nil(t, err)
+	token, expiration, err := s.Auth(&AuthRequest{Id: &id, Nonce: nonce, CredKSign: &proof.CredentialExistence{},
+		Signature: verifier.sign([]byte(nonce))})
+	require.Nil(t, err)
+	assert.Equal(t, time.Unix(4600, 0), expiration)
+
+	// A backend that only knows the public key
+	backend := httptest.NewServer(Middleware(NewJWTES256Verifier(cfg, &sk.PublicKey, clk),
+		http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			id, _ := IDFromContext(req.Context())
+			w.Write([]byte(id.String()))
+		})))
+	defer backend.Close()
+	req, err := http.NewRequest(http.MethodGet, backend.URL, nil)
+	require.Nil(t, err)
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := http.DefaultClient.Do(req)
+	require.Nil(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	body, err := ioutil.ReadAll(res.Body)
+	require.Nil(t, err)
+	assert.Equal(t, id.String(), string(body))
+}
@@ -0,0 +1,151 @@
+// Package credrefresh implements the protocol used by a holder to refresh an
+// existence credential against the newest identity state published by its
+// issuer:
+//
+//	POST /credentials/existence/refresh
+//	{"hIndex": "<hex>"}
+//
+// The response is the JSON CredentialExistence of the claim in the last
+// identity state on chain.  On failure the response is a JSON error with a
+// code that tells apart the claims that are revoked (410), not issued (404)
+// and issued but not yet published (409).
+package credrefresh
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/merkletree"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathRefresh is the path of the refresh endpoint.
+const PathRefresh = "/credentials/existence/refresh"
+
+// Codes of the errors returned by the refresh endpoint.
+const (
+	CodeRevoked      = "revoked"
+	CodeNotFound     = "not_found"
+	CodeNotPublished = "not_published"
+	CodeBadRequest   = "bad_request"
+	CodeInternal     = "internal"
+)
+
+// Refresher refreshes existence credentials, satisfied by issuer.Issuer,
+// issuer.ReadOnly and Client.
+type Refresher interface {
+	RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error)
+}
+
+// RefreshRequest is the body of a refresh request.
+type RefreshRequest struct {
+	HIndex *merkletree.Hash `json:"hIndex"`
+}
+
+// Error is the body of a failed refresh response.
+type Error struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
+
+// errorStatus maps the issuer errors to the status and code of the response.
+var errorStatus = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{issuer.ErrClaimRevoked, http.StatusGone, CodeRevoked},
+	{issuer.ErrClaimNotFound, http.StatusNotFound, CodeNotFound},
+	{issuer.ErrClaimNotFoundStateOnChain, http.StatusConflict, CodeNotPublished},
+	{issuer.ErrIdenStateOnChainZero, http.StatusConflict, CodeNotPublished},
+}
+
+// Handler returns an http.Handler that serves the refresh endpoint with r.
+func Handler(r Refresher) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathRefresh, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			httpjson.Write(w, http.StatusMethodNotAllowed, Error{Code: CodeBadRequest, Error: "method not allowed"})
+			return
+		}
+		var refreshReq RefreshRequest
+		if err := json.NewDecoder(req.Body).Decode(&refreshReq); err != nil {
+			httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeBadRequest, Error: "invalid request: " + err.Error()})
+			return
+		} else if refreshReq.HIndex == nil {
+			httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeBadRequest, Error: "missing hIndex"})
+			return
+		}
+		credExist, err := r.RefreshCredentialExistence(refreshReq.HIndex)
+		if err != nil {
+			for _, e := range errorStatus {
+				if err == e.err {
+					httpjson.Write(w, e.status, Error{Code: e.code, Error: err.Error()})
+					return
+				}
+			}
+			log.WithError(err).Error("RefreshCredentialExistence")
+			httpjson.Write(w, http.StatusInternalServerError, Error{Code: CodeInternal, Error: "internal error"})
+			return
+		}
+		httpjson.Write(w, http.StatusOK, credExist)
+	})
+	return mux
+}
+
+// Client is the holder side of the refresh protocol.
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient creates a Client of the refresh endpoint served at url.  If
+// httpClient is nil, http.DefaultClient is used.
+func NewClient(url string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{url: strings.TrimSuffix(url, "/"), httpClient: httpClient}
+}
+
+// RefreshCredentialExistence requests the existence credential of the claim
+// at hIndex in the last identity state of the issuer.  The errors of the
+// issuer are returned as issuer.ErrClaimRevoked, issuer.ErrClaimNotFound and
+// issuer.ErrClaimNotFoundStateOnChain.
+func (c *Client) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	body, err := json.Marshal(RefreshRequest{HIndex: hIndex})
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.httpClient.Post(c.url+PathRefresh, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var resErr Error
+		if err := json.NewDecoder(res.Body).Decode(&resErr); err != nil {
+			return nil, fmt.Errorf("refresh failed with status %v", res.Status)
+		}
+		for _, e := range errorStatus {
+			if resErr.Code == e.code {
+				if e.code == CodeNotPublished {
+					return nil, issuer.ErrClaimNotFoundStateOnChain
+				}
+				return nil, e.err
+			}
+		}
+		return nil, fmt.Errorf("refresh failed with status %v: %v", res.Status, resErr.Error)
+	}
+	var credExist proof.CredentialExistence
+	if err := json.NewDecoder(res.Body).Decode(&credExist); err != nil {
+		return nil, err
+	}
+	return &credExist, nil
+}
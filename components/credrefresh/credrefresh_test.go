@@ -0,0 +1,91 @@
+package credrefresh
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// refresher is a Refresher that returns the error of the first byte of
+// hIndex, or a credential if there is none.
+type refresher map[byte]error
+
+func (r refresher) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	if err, ok := r[hIndex[0]]; ok {
+		return nil, err
+	}
+	id := core.NewID(core.TypeBJP0, [27]byte{1})
+	claim := &merkletree.Entry{}
+	claim.Data[0][0] = hIndex[0]
+	return &proof.CredentialExistence{
+		Id:              &id,
+		IdenStateData:   proof.IdenStateData{BlockN: 2, BlockTs: 3, IdenState: &merkletree.Hash{4}},
+		MtpClaim:        &merkletree.Proof{Existence: true},
+		Claim:           claim,
+		RevocationsRoot: &merkletree.Hash{5},
+		RootsRoot:       &merkletree.Hash{6},
+		IdPubUrl:        "https://issuer",
+	}, nil
+}
+
+func TestRefresh(t *testing.T) {
+	r := refresher{
+		1: issuer.ErrClaimRevoked,
+		2: issuer.ErrClaimNotFound,
+		3: issuer.ErrClaimNotFoundStateOnChain,
+		4: issuer.ErrIdenStateOnChainZero,
+		5: fmt.Errorf("db error"),
+	}
+	server := httptest.NewServer(Handler(r))
+	defer server.Close()
+	client := NewClient(server.URL+"/", nil)
+
+	credExist, err := client.RefreshCredentialExistence(&merkletree.Hash{7})
+	require.Nil(t, err)
+	expected, _ := r.RefreshCredentialExistence(&merkletree.Hash{7})
+	assert.Equal(t, expected.Claim.Data, credExist.Claim.Data)
+	assert.Equal(t, expected.Id, credExist.Id)
+	assert.Equal(t, expected.IdenStateData, credExist.IdenStateData)
+
+	for _, test := range []struct {
+		hIndex byte
+		err    error
+	}{
+		{1, issuer.ErrClaimRevoked},
+		{2, issuer.ErrClaimNotFound},
+		{3, issuer.ErrClaimNotFoundStateOnChain},
+		{4, issuer.ErrClaimNotFoundStateOnChain},
+	} {
+		_, err := client.RefreshCredentialExistence(&merkletree.Hash{test.hIndex})
+		assert.Equal(t, test.err, err)
+	}
+	_, err = client.RefreshCredentialExistence(&merkletree.Hash{5})
+	require.NotNil(t, err)
+	assert.Contains(t, err.Error(), "internal error")
+}
+
+func TestRefreshBadRequest(t *testing.T) {
+	server := httptest.NewServer(Handler(refresher{}))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + PathRefresh)
+	require.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+
+	for _, body := range []string{`{"hIndex": "zz"}`, `{}`} {
+		res, err = http.Post(server.URL+PathRefresh, "application/json", strings.NewReader(body))
+		require.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+	}
+}
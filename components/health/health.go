@@ -0,0 +1,205 @@
+// Package health implements the liveness and readiness endpoints of the
+// service binaries, to be used as orchestration probes:
+//
+//	GET /healthz
+//	GET /readyz
+//
+// /healthz succeeds while the process is serving requests.  /readyz runs the
+// registered readiness checks concurrently and fails with 503 if any of them
+// fails.  Both respond with a JSON Status.
+package health
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	log "github.com/sirupsen/logrus"
+)
+
+// Paths of the endpoints.
+const (
+	PathHealthz = "/healthz"
+	PathReadyz  = "/readyz"
+)
+
+var (
+	ErrKeyLocked = fmt.Errorf("key is locked")
+)
+
+// ConfigDefault is a default configuration for the Checker.
+var ConfigDefault = Config{Timeout: 5 * time.Second}
+
+// Config allows configuring the Checker.
+type Config struct {
+	// Timeout is the maximum duration of the readiness checks.
+	Timeout time.Duration
+}
+
+// Check checks a dependency of the service, returning an error if it's not
+// available.  It must return when ctx is done.
+type Check func(ctx context.Context) error
+
+// Status is the body of the responses.  Checks contains the error of each
+// failed readiness check, or "ok", by name.
+type Status struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Checker runs the readiness checks of a service.
+type Checker struct {
+	cfg    Config
+	rw     *sync.RWMutex
+	checks map[string]Check
+}
+
+// New creates a new Checker without checks.
+func New(cfg Config) *Checker {
+	return &Checker{cfg: cfg, rw: &sync.RWMutex{}, checks: make(map[string]Check)}
+}
+
+// Add registers the readiness check with name, replacing any previous check
+// with the same name.
+func (c *Checker) Add(name string, check Check) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	c.checks[name] = check
+}
+
+// Ready runs all the readiness checks concurrently and returns the error of
+// each failed check by name.
+func (c *Checker) Ready(ctx context.Context) map[string]error {
+	c.rw.RLock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.rw.RUnlock()
+
+	ctx, cancel := context.WithTimeout(ctx, c.cfg.Timeout)
+	defer cancel()
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	errs := make(map[string]error)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			err := check(ctx)
+			mutex.Lock()
+			errs[name] = err
+			mutex.Unlock()
+		}(name, check)
+	}
+	wg.Wait()
+	for name, err := range errs {
+		if err == nil {
+			delete(errs, name)
+		}
+	}
+	return errs
+}
+
+// Handler returns an http.Handler that serves the /healthz and /readyz
+// endpoints.
+func (c *Checker) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathHealthz, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		httpjson.Write(w, http.StatusOK, Status{Status: "ok"})
+	})
+	mux.HandleFunc(PathReadyz, func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		errs := c.Ready(req.Context())
+		c.rw.RLock()
+		names := make([]string, 0, len(c.checks))
+		for name := range c.checks {
+			names = append(names, name)
+		}
+		c.rw.RUnlock()
+		sort.Strings(names)
+		status := Status{Status: "ok", Checks: make(map[string]string, len(names))}
+		for _, name := range names {
+			if err, ok := errs[name]; ok {
+				status.Checks[name] = err.Error()
+			} else {
+				status.Checks[name] = "ok"
+			}
+		}
+		if len(errs) != 0 {
+			status.Status = "unavailable"
+			log.WithField("checks", status.Checks).Warn("Readiness check failed")
+			httpjson.Write(w, http.StatusServiceUnavailable, status)
+			return
+		}
+		httpjson.Write(w, http.StatusOK, status)
+	})
+	return mux
+}
+
+// dbKeyHealth is the key read by the storage check, which doesn't need to
+// exist.
+var dbKeyHealth = []byte("health")
+
+// StorageCheck checks that the storage can be read.
+func StorageCheck(storage db.Storage) Check {
+	return func(ctx context.Context) error {
+		if _, err := storage.Get(dbKeyHealth); err != nil && err != db.ErrNotFound {
+			return err
+		}
+		return nil
+	}
+}
+
+// KeyStoreCheck checks that the key pk is unlocked in the keyStore.
+func KeyStoreCheck(keyStore *keystore.KeyStore, pk *babyjub.PublicKeyComp) Check {
+	return func(ctx context.Context) error {
+		if !keyStore.Unlocked(pk) {
+			return ErrKeyLocked
+		}
+		return nil
+	}
+}
+
+// SyncProgresser returns the sync progress of an ethereum node, satisfied
+// by ethclient.Client.
+type SyncProgresser interface {
+	SyncProgress(ctx context.Context) (*ethereum.SyncProgress, error)
+}
+
+// EthSyncCheck checks that the ethereum node is reachable, and that if it's
+// syncing it's at most maxBlockLag blocks behind the network.
+func EthSyncCheck(client SyncProgresser, maxBlockLag uint64) Check {
+	return func(ctx context.Context) error {
+		progress, err := client.SyncProgress(ctx)
+		if err != nil {
+			return err
+		}
+		if progress != nil && progress.HighestBlock > progress.CurrentBlock &&
+			progress.HighestBlock-progress.CurrentBlock > maxBlockLag {
+			return fmt.Errorf("ethereum node is %v blocks behind",
+				progress.HighestBlock-progress.CurrentBlock)
+		}
+		return nil
+	}
+}
+
+// BacklogCheck checks that there are at most max publications pending, as
+// returned by pending.
+func BacklogCheck(pending func() int, max int) Check {
+	return func(ctx context.Context) error {
+		if n := pending(); n > max {
+			return fmt.Errorf("%v publications pending", n)
+		}
+		return nil
+	}
+}
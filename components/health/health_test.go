@@ -0,0 +1,82 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type syncProgressFixed struct {
+	progress *ethereum.SyncProgress
+	err      error
+}
+
+func (s *syncProgressFixed) SyncProgress(ctx context.Context) (*ethereum.SyncProgress, error) {
+	return s.progress, s.err
+}
+
+func TestChecks(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, StorageCheck(db.NewMemoryStorage())(ctx))
+
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	pk, err := keyStore.NewKey([]byte("pass"))
+	require.Nil(t, err)
+	assert.Equal(t, ErrKeyLocked, KeyStoreCheck(keyStore, pk)(ctx))
+	require.Nil(t, keyStore.UnlockKey(pk, []byte("pass")))
+	assert.Nil(t, KeyStoreCheck(keyStore, pk)(ctx))
+
+	assert.Nil(t, EthSyncCheck(&syncProgressFixed{}, 10)(ctx))
+	assert.Nil(t, EthSyncCheck(&syncProgressFixed{progress: &ethereum.SyncProgress{
+		CurrentBlock: 95, HighestBlock: 100}}, 10)(ctx))
+	assert.NotNil(t, EthSyncCheck(&syncProgressFixed{progress: &ethereum.SyncProgress{
+		CurrentBlock: 50, HighestBlock: 100}}, 10)(ctx))
+	assert.NotNil(t, EthSyncCheck(&syncProgressFixed{err: fmt.Errorf("connection refused")}, 10)(ctx))
+
+	assert.Nil(t, BacklogCheck(func() int { return 3 }, 3)(ctx))
+	assert.NotNil(t, BacklogCheck(func() int { return 4 }, 3)(ctx))
+}
+
+func TestHandler(t *testing.T) {
+	c := New(ConfigDefault)
+	backlog := 0
+	c.Add("storage", StorageCheck(db.NewMemoryStorage()))
+	c.Add("backlog", BacklogCheck(func() int { return backlog }, 0))
+	server := httptest.NewServer(c.Handler())
+	defer server.Close()
+
+	get := func(path string) (int, Status) {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		defer res.Body.Close()
+		var status Status
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&status))
+		return res.StatusCode, status
+	}
+
+	code, status := get(PathHealthz)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, "ok", status.Status)
+
+	code, status = get(PathReadyz)
+	assert.Equal(t, http.StatusOK, code)
+	assert.Equal(t, map[string]string{"storage": "ok", "backlog": "ok"}, status.Checks)
+
+	backlog = 1
+	code, status = get(PathReadyz)
+	assert.Equal(t, http.StatusServiceUnavailable, code)
+	assert.Equal(t, "unavailable", status.Status)
+	assert.Equal(t, "1 publications pending", status.Checks["backlog"])
+	assert.Equal(t, "ok", status.Checks["storage"])
+}
@@ -0,0 +1,152 @@
+package idencontract
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+)
+
+var (
+	ErrMalformedSignature = fmt.Errorf("malformed signature")
+	ErrInvalidChainId     = fmt.Errorf("the forward call is for another chain")
+	ErrInvalidNonce       = fmt.Errorf("the forward call nonce is not the next one of the identity")
+)
+
+const (
+	// ForwardDomainName and ForwardDomainVersion identify the EIP-712
+	// domain of the forward calls.
+	ForwardDomainName    = "iden3 identity"
+	ForwardDomainVersion = "1"
+)
+
+// forwardTypes are the EIP-712 types of a ForwardCall.
+var forwardTypes = signercore.Types{
+	"EIP712Domain": []signercore.Type{
+		{Name: "name", Type: "string"},
+		{Name: "version", Type: "string"},
+		{Name: "chainId", Type: "uint256"},
+		{Name: "verifyingContract", Type: "address"},
+	},
+	"ForwardCall": []signercore.Type{
+		{Name: "to", Type: "address"},
+		{Name: "data", Type: "bytes"},
+		{Name: "value", Type: "uint256"},
+		{Name: "gas", Type: "uint256"},
+		{Name: "nonce", Type: "uint256"},
+	},
+}
+
+// ForwardCall is a call that an identity contract forwards to To on behalf
+// of the identity, signed with EIP-712 by an ethereum key authorized by the
+// identity.
+type ForwardCall struct {
+	To      common.Address
+	Data    []byte
+	Value   *big.Int
+	Gas     uint64
+	Nonce   uint64
+	ChainId *big.Int
+}
+
+// TypedData returns the EIP-712 typed data of the call to be signed, in the
+// domain of the identity contract at contract.
+func (c *ForwardCall) TypedData(contract common.Address) *signercore.TypedData {
+	value := c.Value
+	if value == nil {
+		value = big.NewInt(0)
+	}
+	return &signercore.TypedData{
+		Types:       forwardTypes,
+		PrimaryType: "ForwardCall",
+		Domain: signercore.TypedDataDomain{
+			Name:              ForwardDomainName,
+			Version:           ForwardDomainVersion,
+			ChainId:           (*math.HexOrDecimal256)(c.ChainId),
+			VerifyingContract: contract.Hex(),
+		},
+		Message: signercore.TypedDataMessage{
+			"to":    c.To.Hex(),
+			"data":  c.Data,
+			"value": (*math.HexOrDecimal256)(value),
+			"gas":   (*math.HexOrDecimal256)(new(big.Int).SetUint64(c.Gas)),
+			"nonce": (*math.HexOrDecimal256)(new(big.Int).SetUint64(c.Nonce)),
+		},
+	}
+}
+
+// EthTypedDataVerifier verifies EIP-712 signatures by the ethereum keys
+// authorized by an identity, satisfied by sigverify.SigVerifier.
+type EthTypedDataVerifier interface {
+	VerifyEthTypedDataSignature(id *core.ID, credEthKey *proof.CredentialExistence,
+		ethKeyType claims.EthKeyType, typedData *signercore.TypedData, sig *crypto.SignatureEthMsg) error
+}
+
+// ForwardVerifier verifies the signed forward calls of the identities before
+// they are sent, and uses their nonces so that a signed call can't be
+// replayed.
+type ForwardVerifier struct {
+	nonces      *Nonces
+	chainId     *big.Int
+	ethKeyType  claims.EthKeyType
+	sigVerifier EthTypedDataVerifier
+}
+
+// NewForwardVerifier creates a new ForwardVerifier for the calls in the chain
+// chainId, signed by keys authorized with a ClaimAuthEthKey of type
+// ethKeyType.
+func NewForwardVerifier(nonces *Nonces, chainId *big.Int, ethKeyType claims.EthKeyType,
+	sigVerifier EthTypedDataVerifier) *ForwardVerifier {
+	return &ForwardVerifier{
+		nonces:      nonces,
+		chainId:     chainId,
+		ethKeyType:  ethKeyType,
+		sigVerifier: sigVerifier,
+	}
+}
+
+// Verify verifies that sig is an EIP-712 signature of call in the domain of
+// the identity contract at contract, by a non revoked ethereum key authorized
+// by id with the ClaimAuthEthKey of credEthKey.  The call must have the next
+// nonce of id in the Nonces, which is incremented when the call is accepted,
+// so a replayed call is rejected with ErrInvalidNonce.
+func (v *ForwardVerifier) Verify(id *core.ID, contract common.Address, credEthKey *proof.CredentialExistence,
+	call *ForwardCall, sig *crypto.SignatureEthMsg) error {
+	if err := checkSignature(sig); err != nil {
+		return err
+	}
+	if call.ChainId == nil || call.ChainId.Cmp(v.chainId) != 0 {
+		return ErrInvalidChainId
+	}
+	if call.Value != nil && call.Value.Sign() < 0 {
+		return fmt.Errorf("negative forward call value")
+	}
+
+	return v.nonces.Use(id, call.Nonce, func() error {
+		return v.sigVerifier.VerifyEthTypedDataSignature(id, credEthKey, v.ethKeyType,
+			call.TypedData(contract), sig)
+	})
+}
+
+// checkSignature rejects the signatures with an invalid recovery id, and the
+// malleable ones with s in the upper half of the curve order, which would
+// allow a second valid signature of the same call.
+func checkSignature(sig *crypto.SignatureEthMsg) error {
+	v := sig[64]
+	if v >= 27 {
+		v -= 27
+	}
+	r := new(big.Int).SetBytes(sig[:32])
+	s := new(big.Int).SetBytes(sig[32:64])
+	if !ethcrypto.ValidateSignatureValues(v, r, s, true) {
+		return ErrMalformedSignature
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package idencontract
+
+import (
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// addrVerifier is an EthTypedDataVerifier that accepts the signatures of
+// addr.
+type addrVerifier struct {
+	addr common.Address
+}
+
+func (v *addrVerifier) VerifyEthTypedDataSignature(id *core.ID, credEthKey *proof.CredentialExistence,
+	ethKeyType claims.EthKeyType, typedData *signercore.TypedData, sig *crypto.SignatureEthMsg) error {
+	addr, err := crypto.RecoverAddrEthTypedData(sig, typedData)
+	if err != nil {
+		return err
+	}
+	if addr != v.addr {
+		return fmt.Errorf("invalid signer")
+	}
+	return nil
+}
+
+func TestForwardVerifier(t *testing.T) {
+	ethKey, err := ethcrypto.HexToECDSA("da7079f082a1ced80c5dee3bf00752fd67f75321a637e5d5073ce1489af062d8")
+	require.Nil(t, err)
+	contract := common.HexToAddress("0xA00eaae01daF66e99F7F9C24CCeD15c1eAD59D3b")
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	sign := func(call *ForwardCall) *crypto.SignatureEthMsg {
+		hash, err := crypto.EthTypedDataHash(call.TypedData(contract))
+		require.Nil(t, err)
+		sig, err := ethcrypto.Sign(hash[:], ethKey)
+		require.Nil(t, err)
+		sig[64] += 27
+		var sigEthMsg crypto.SignatureEthMsg
+		copy(sigEthMsg[:], sig)
+		return &sigEthMsg
+	}
+
+	nonces := NewNonces(db.NewMemoryStorage())
+	v := NewForwardVerifier(nonces, big.NewInt(1), claims.EthKeyTypeUpgrade,
+		&addrVerifier{addr: ethcrypto.PubkeyToAddress(ethKey.PublicKey)})
+	call := &ForwardCall{
+		To:      common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c"),
+		Data:    []byte{0xca, 0xfe},
+		Value:   big.NewInt(1000),
+		Gas:     100000,
+		Nonce:   0,
+		ChainId: big.NewInt(1),
+	}
+	sig := sign(call)
+	require.Nil(t, v.Verify(&id, contract, nil, call, sig))
+	nonce, err := nonces.Get(&id)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1), nonce)
+
+	// Replayed call
+	assert.Equal(t, ErrInvalidNonce, v.Verify(&id, contract, nil, call, sig))
+
+	// Modified call
+	call.Nonce = 1
+	assert.NotNil(t, v.Verify(&id, contract, nil, call, sig))
+
+	// Call signed for another contract
+	sig = sign(call)
+	assert.NotNil(t, v.Verify(&id, common.Address{}, nil, call, sig))
+
+	// Malleable signature: (r, N-s) with the recovery id flipped
+	var sigMalleable crypto.SignatureEthMsg
+	copy(sigMalleable[:], sig[:])
+	s := new(big.Int).Sub(ethcrypto.S256().Params().N, new(big.Int).SetBytes(sig[32:64]))
+	copy(sigMalleable[32:64], common.LeftPadBytes(s.Bytes(), 32))
+	sigMalleable[64] ^= 1
+	assert.Equal(t, ErrMalformedSignature, v.Verify(&id, contract, nil, call, &sigMalleable))
+
+	// Invalid recovery id
+	var sigBadV crypto.SignatureEthMsg
+	copy(sigBadV[:], sig[:])
+	sigBadV[64] = 30
+	assert.Equal(t, ErrMalformedSignature, v.Verify(&id, contract, nil, call, &sigBadV))
+
+	// Call for another chain
+	call.ChainId = big.NewInt(3)
+	assert.Equal(t, ErrInvalidChainId, v.Verify(&id, contract, nil, call, sign(call)))
+
+	call.ChainId = big.NewInt(1)
+	require.Nil(t, v.Verify(&id, contract, nil, call, sig))
+	nonce, err = nonces.Get(&id)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), nonce)
+}
@@ -0,0 +1,48 @@
+package idencontract
+
+import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// proxyInitCodePrefix and proxyInitCodeSuffix surround the implementation
+// address in the creation code of an EIP-1167 minimal proxy.
+var (
+	proxyInitCodePrefix = common.FromHex("0x3d602d80600a3d3981f3363d3d373d3d3d363d73")
+	proxyInitCodeSuffix = common.FromHex("0x5af43d82803e903d91602b57fd5bf3")
+)
+
+// ProxyInitCode returns the creation code of the EIP-1167 minimal proxy
+// contract that delegates all the calls to impl.  This is the code deployed
+// for each identity contract.
+func ProxyInitCode(impl common.Address) []byte {
+	initCode := make([]byte, 0, len(proxyInitCodePrefix)+common.AddressLength+len(proxyInitCodeSuffix))
+	initCode = append(initCode, proxyInitCodePrefix...)
+	initCode = append(initCode, impl.Bytes()...)
+	return append(initCode, proxyInitCodeSuffix...)
+}
+
+// Salt returns the CREATE2 salt of the identity contract with the given
+// operational keys: the keccak256 of the compressed kop followed by the kdis,
+// kreen and kupdateRoot addresses.
+func Salt(kop *babyjub.PublicKey, kdis, kreen, kupdateRoot common.Address) [32]byte {
+	kopComp := kop.Compress()
+	var salt [32]byte
+	copy(salt[:], crypto.Keccak256(kopComp[:], kdis.Bytes(), kreen.Bytes(), kupdateRoot.Bytes()))
+	return salt
+}
+
+// Create2Address returns the address of the contract created with CREATE2
+// by deployer with salt and initCode, as specified in EIP-1014.
+func Create2Address(deployer common.Address, salt [32]byte, initCode []byte) common.Address {
+	return crypto.CreateAddress2(deployer, salt, crypto.Keccak256(initCode))
+}
+
+// AddressOf returns the counterfactual address of the identity contract with
+// the given operational keys, deployed by deployer as a proxy to the
+// implementation contract impl.  The address can be computed offline, before
+// the contract is deployed.
+func AddressOf(deployer, impl common.Address, kop *babyjub.PublicKey, kdis, kreen, kupdateRoot common.Address) common.Address {
+	return Create2Address(deployer, Salt(kop, kdis, kreen, kupdateRoot), ProxyInitCode(impl))
+}
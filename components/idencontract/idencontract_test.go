@@ -0,0 +1,71 @@
+package idencontract
+
+import (
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreate2Address(t *testing.T) {
+	// Examples from EIP-1014.
+	testVectors := []struct {
+		deployer string
+		salt     string
+		initCode string
+		address  string
+	}{
+		{"0x0000000000000000000000000000000000000000",
+			"0x0000000000000000000000000000000000000000000000000000000000000000",
+			"0x00", "0x4D1A2e2bB4F88F0250f26Ffff098B0b30B26BF38"},
+		{"0xdeadbeef00000000000000000000000000000000",
+			"0x000000000000000000000000feed000000000000000000000000000000000000",
+			"0x00", "0xD04116cDd17beBE565EB2422F2497E06cC1C9833"},
+		{"0x00000000000000000000000000000000deadbeef",
+			"0x00000000000000000000000000000000000000000000000000000000cafebabe",
+			"0xdeadbeef", "0x60f3f640a8508fC6a86d45DF051962668E1e8AC7"},
+		{"0x0000000000000000000000000000000000000000",
+			"0x0000000000000000000000000000000000000000000000000000000000000000",
+			"0x", "0xE33C0C7F7df4809055C3ebA6c09CFe4BaF1BD9e0"},
+	}
+	for _, v := range testVectors {
+		var salt [32]byte
+		copy(salt[:], common.FromHex(v.salt))
+		address := Create2Address(common.HexToAddress(v.deployer), salt, common.FromHex(v.initCode))
+		assert.Equal(t, v.address, address.Hex())
+	}
+}
+
+func TestProxyInitCode(t *testing.T) {
+	impl := common.HexToAddress("0xbebebebebebebebebebebebebebebebebebebebe")
+	// Runtime code from EIP-1167, which follows the 10 bytes of the deployer.
+	runtime := "363d3d373d3d3d363d73bebebebebebebebebebebebebebebebebebebebe5af43d82803e903d91602b57fd5bf3"
+	initCode := ProxyInitCode(impl)
+	assert.Equal(t, 55, len(initCode))
+	assert.Equal(t, runtime, hex.EncodeToString(initCode[10:]))
+}
+
+func TestAddressOf(t *testing.T) {
+	var sk babyjub.PrivateKey
+	_, err := hex.Decode(sk[:], []byte("4be5471a938bdf3606888472878baace4a6a64e14a153adf9a1333969e4e573c"))
+	require.Nil(t, err)
+	kop := sk.Public()
+	kdis := common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c")
+	kreen := common.HexToAddress("0x7d9ea52c4a1c5e4b9d4a0b8ef1bff6b1b5c3a0d2")
+	kupdateRoot := common.HexToAddress("0x2a5c3f7e8e4b1d0c9f6a3b2e1d0c9b8a7f6e5d4c")
+	deployer := common.HexToAddress("0x52dc8e81c3e56d3b1d3de2b2d4e27a27b5e9cc39")
+	impl := common.HexToAddress("0x66d0c2f85f1b717168cbb508afd1c46e07227130")
+
+	address := AddressOf(deployer, impl, kop, kdis, kreen, kupdateRoot)
+	salt := Salt(kop, kdis, kreen, kupdateRoot)
+	assert.Equal(t, Create2Address(deployer, salt, ProxyInitCode(impl)), address)
+	assert.Equal(t, "adc95576960667b3a7636b554cc630aff6c1054ef6b3254e2153c8a351d6a2d1", hex.EncodeToString(salt[:]))
+	assert.Equal(t, "0xA00eaae01daF66e99F7F9C24CCeD15c1eAD59D3b", address.Hex())
+
+	// Any change of the keys changes the address
+	assert.NotEqual(t, address, AddressOf(deployer, impl, kop, kreen, kdis, kupdateRoot))
+	assert.NotEqual(t, address, AddressOf(deployer, kdis, kop, kdis, kreen, kupdateRoot))
+}
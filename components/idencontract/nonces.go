@@ -0,0 +1,110 @@
+package idencontract
+
+import (
+	"encoding/binary"
+	"net/http"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathNonce is the path of the endpoint that returns the next forward nonce
+// of an identity:
+//
+//	GET /forward/nonce?id=<id>
+const PathNonce = "/forward/nonce"
+
+// Nonces keeps in a storage the nonce that the next forward call of each
+// identity must have, so that the forwarded calls can't be replayed and the
+// clients can sign them offline.
+type Nonces struct {
+	mutex   *sync.Mutex
+	storage db.Storage
+}
+
+// NewNonces creates a new Nonces that stores the nonces in storage.
+func NewNonces(storage db.Storage) *Nonces {
+	return &Nonces{
+		mutex:   &sync.Mutex{},
+		storage: storage,
+	}
+}
+
+// Get returns the nonce that the next forward call of id must have.
+func (n *Nonces) Get(id *core.ID) (uint64, error) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	return n.get(id)
+}
+
+func (n *Nonces) get(id *core.ID) (uint64, error) {
+	nonceBytes, err := n.storage.Get(id[:])
+	if err == db.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint64(nonceBytes), nil
+}
+
+// Use checks that nonce is the next nonce of id and calls check, and if it
+// succeeds increments the nonce of id.  The nonces of all the identities are
+// locked during check, so the same nonce can't be used twice concurrently.
+func (n *Nonces) Use(id *core.ID, nonce uint64, check func() error) error {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	next, err := n.get(id)
+	if err != nil {
+		return err
+	}
+	if nonce != next {
+		return ErrInvalidNonce
+	}
+	if err := check(); err != nil {
+		return err
+	}
+	tx, err := n.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	var nonceBytes [8]byte
+	binary.BigEndian.PutUint64(nonceBytes[:], next+1)
+	tx.Put(id[:], nonceBytes[:])
+	return tx.Commit()
+}
+
+// NonceResponse is the body of the nonce endpoint response.
+type NonceResponse struct {
+	Id    *core.ID `json:"id"`
+	Nonce uint64   `json:"nonce"`
+}
+
+// Error is the body of a failed nonce endpoint response.
+type Error = httpjson.Error
+
+// NonceHandler returns an http.Handler that serves the nonce endpoint with n.
+func NonceHandler(n *Nonces) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathNonce, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		id, err := core.IDFromString(req.URL.Query().Get("id"))
+		if err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid id: "+err.Error())
+			return
+		}
+		nonce, err := n.Get(&id)
+		if err != nil {
+			log.WithError(err).Error("Nonces.Get")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, NonceResponse{Id: &id, Nonce: nonce})
+	})
+	return mux
+}
@@ -0,0 +1,64 @@
+package idencontract
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNonces(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	nonces := NewNonces(db.NewMemoryStorage())
+
+	// A failed check doesn't use the nonce
+	assert.NotNil(t, nonces.Use(&id, 0, func() error { return fmt.Errorf("invalid") }))
+	nonce, err := nonces.Get(&id)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), nonce)
+
+	// Only one of the concurrent uses of the same nonce succeeds
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs[i] = nonces.Use(&id, 0, func() error { return nil })
+		}(i)
+	}
+	wg.Wait()
+	used := 0
+	for _, err := range errs {
+		if err == nil {
+			used++
+		} else {
+			assert.Equal(t, ErrInvalidNonce, err)
+		}
+	}
+	assert.Equal(t, 1, used)
+
+	server := httptest.NewServer(NonceHandler(nonces))
+	defer server.Close()
+	res, err := http.Get(server.URL + PathNonce + "?id=" + id.String())
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var nonceRes NonceResponse
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&nonceRes))
+	assert.Equal(t, id, *nonceRes.Id)
+	assert.Equal(t, uint64(1), nonceRes.Nonce)
+
+	res, err = http.Get(server.URL + PathNonce + "?id=invalid")
+	require.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+}
@@ -441,25 +441,22 @@ func (m *IdenManager) GetClaimProofUserByHiOld(id *core.ID, hi *merkletree.Hash)
 // published in the blockchain.  The result is signed (with
 // a timestamp) by the service.
 func (m *IdenManager) GetClaimProofByHiBlockchain(hi *merkletree.Hash) (*proof.ProofClaim, error) {
-	// rootData, err := m.idenStateWriter.GetRoot(m.id)
-	rootData, err := &proof.RootData{}, fmt.Errorf("DEPRECATED")
+	// stateData, err := m.idenStateWriter.GetState(m.id)
+	stateData, err := &proof.IdenStateData{}, fmt.Errorf("DEPRECATED")
 	if err != nil {
 		return nil, err
 	}
-	mt, err := m.mt.Snapshot(rootData.Root)
+	mt, err := m.mt.Snapshot(stateData.IdenState)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Printf("> G %+v\n", rootData.Root.String())
-	fmt.Printf("> B %+v\n", mt.RootKey().String())
 	proofClaim, err := proof.GetClaimProofByHi(mt, hi)
 	if err != nil {
 		return nil, err
 	}
 
-	proofClaim.ID = m.id
-	proofClaim.BlockN = rootData.BlockN
-	proofClaim.BlockTimestamp = rootData.BlockTimestamp
+	proofClaim.Id = m.id
+	proofClaim.IdenStateData = stateData
 
 	return proofClaim, nil
 }
@@ -508,6 +505,36 @@ func (m *IdenManager) CreateIdGenesis(kop *babyjub.PublicKey, kdis, kreen, kupda
 	return id, &proofClaims.KOp, nil
 }
 
+// CreateIdGenesisBatch creates the identities of the requests in reqs with
+// CreateIdGenesis.  The failure of a request doesn't abort the batch: its
+// error is reported in the corresponding result, and the rest of the requests
+// are processed.
+func (m *IdenManager) CreateIdGenesisBatch(reqs []messages.CreateIdReq) *messages.CreateIdBatchRes {
+	res := messages.CreateIdBatchRes{Ids: make([]messages.CreateIdRes, len(reqs))}
+	for i, req := range reqs {
+		id, proofKOp, err := m.createIdGenesisReq(&req)
+		if err != nil {
+			res.Ids[i].Error = err.Error()
+			res.Failed++
+			continue
+		}
+		res.Ids[i].Id = id
+		res.Ids[i].ProofKOp = proofKOp
+	}
+	return &res
+}
+
+func (m *IdenManager) createIdGenesisReq(req *messages.CreateIdReq) (*core.ID, *proof.ProofClaim, error) {
+	if req.KOp == nil {
+		return nil, nil, fmt.Errorf("missing operational key")
+	}
+	kop, err := req.KOp.Decompress()
+	if err != nil {
+		return nil, nil, err
+	}
+	return m.CreateIdGenesis(kop, req.KDis, req.KReen, req.KUpdateRoot)
+}
+
 // getNonRevocationProof returns the next version Hi (that don't exist in the tree, it's value is Empty) with merkleproof and root
 func getNonRevocationProof(mt *merkletree.MerkleTree, hi *merkletree.Hash) (*messages.ProofTreeLeaf, error) {
 	// var value merkletree.Value
@@ -9,6 +9,7 @@ import (
 
 	"github.com/ethereum/go-ethereum/common"
 	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-core/components/idenmanager/messages"
 	"github.com/iden3/go-iden3-core/components/idensigner"
 	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/claims"
@@ -404,9 +405,8 @@ func TestCreateIdGenesisRandomLoop(t *testing.T) {
 			require.Nil(t, err)
 			require.Equal(t, id, id2)
 
-			proofKOpVerified, err := proofKOp.Verify(proofKOp.Proof.Root)
+			_, err = proofKOp.Verify()
 			require.Nil(t, err)
-			require.True(t, proofKOpVerified)
 		}
 	}
 }
@@ -455,3 +455,89 @@ func TestMain(m *testing.M) {
 	}
 	os.Exit(result)
 }
+
+func TestCreateIdGenesisBatch(t *testing.T) {
+	idsrv := initializeIdService(t)
+
+	kDis := common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c")
+	badKOp := babyjub.PublicKeyComp{}
+	for i := range badKOp {
+		badKOp[i] = 0xff
+	}
+	reqs := []messages.CreateIdReq{
+		{KOp: &badKOp, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+		{KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+	}
+	res := idsrv.CreateIdGenesisBatch(reqs)
+	require.Equal(t, 2, len(res.Ids))
+	require.Equal(t, 2, res.Failed)
+	for _, r := range res.Ids {
+		require.NotEqual(t, "", r.Error)
+		require.Nil(t, r.Id)
+		require.Nil(t, r.ProofKOp)
+	}
+
+	res = idsrv.CreateIdGenesisBatch(nil)
+	require.Equal(t, 0, len(res.Ids))
+	require.Equal(t, 0, res.Failed)
+
+	// The kOp of TestCreateIdGenesisHardcoded.  As the ClaimSetRootKey is
+	// not updated to the new spec, only the ids whose last byte fits in the
+	// Finite Field can be created, so the keys are chosen to create them.
+	var kOp0 babyjub.PublicKeyComp
+	err := kOp0.UnmarshalText([]byte("0x117f0a278b32db7380b078cdb451b509a2ed591664d1bac464e8c35a90646796"))
+	require.Nil(t, err)
+	kDis0 := common.HexToAddress("0x2b5ad5c4795c026514f8317c7a215e218dccd6cf")
+	sk1, sk2 := babyjub.PrivateKey{2}, babyjub.PrivateKey{8}
+	kOp1, kOp2 := sk1.Public().Compress(), sk2.Public().Compress()
+	sk3 := babyjub.PrivateKey{1}
+	kOpOutOfField := sk3.Public().Compress()
+
+	// checkBatch checks that the requests of a batch create the same
+	// identities and proofs, or fail with the same error, as
+	// CreateIdGenesis in another relay.
+	checkBatch := func(reqs []messages.CreateIdReq, failed int) {
+		res := initializeIdService(t).CreateIdGenesisBatch(reqs)
+		require.Equal(t, len(reqs), len(res.Ids))
+		require.Equal(t, failed, res.Failed)
+		idsrv := initializeIdService(t)
+		for i, req := range reqs {
+			r := res.Ids[i]
+			var kOp *babyjub.PublicKey
+			if req.KOp != nil {
+				kOp, _ = req.KOp.Decompress()
+			}
+			if kOp == nil {
+				require.NotEqual(t, "", r.Error)
+				require.Nil(t, r.Id)
+				require.Nil(t, r.ProofKOp)
+				continue
+			}
+			id, proofKOp, err := idsrv.CreateIdGenesis(kOp, req.KDis, req.KReen, req.KUpdateRoot)
+			if err != nil {
+				require.Equal(t, err.Error(), r.Error)
+				require.Nil(t, r.Id)
+				require.Nil(t, r.ProofKOp)
+				continue
+			}
+			require.Equal(t, "", r.Error)
+			require.Equal(t, id, r.Id)
+			require.Equal(t, proofKOp, r.ProofKOp)
+			_, err = r.ProofKOp.Verify()
+			require.Nil(t, err)
+		}
+	}
+	checkBatch([]messages.CreateIdReq{
+		{KOp: &kOp0, KDis: kDis0, KReen: kDis0, KUpdateRoot: kDis0},
+		{KOp: &kOp1, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+		{KOp: &kOp2, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+	}, 0)
+	checkBatch([]messages.CreateIdReq{
+		{KOp: &kOp0, KDis: kDis0, KReen: kDis0, KUpdateRoot: kDis0},
+		{KOp: &badKOp, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+		{KOp: &kOp1, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+		{KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+		{KOp: &kOpOutOfField, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+		{KOp: &kOp2, KDis: kDis, KReen: kDis, KUpdateRoot: kDis},
+	}, 3)
+}
@@ -1,6 +1,8 @@
 package messages
 
 import (
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/proof"
 	"github.com/iden3/go-iden3-core/crypto"
 	"github.com/iden3/go-iden3-core/merkletree"
@@ -42,3 +44,31 @@ type ProofClaimUserRes struct {
 	Date                           int64
 	Signature                      []byte // signature of the Root of the Relay
 }
+
+// CreateIdReq contains the keys of a new identity to be created by the Relay
+type CreateIdReq struct {
+	KOp         *babyjub.PublicKeyComp `json:"operationalPk" binding:"required"`
+	KDis        common.Address         `json:"kdisable" binding:"required"`
+	KReen       common.Address         `json:"kreenable" binding:"required"`
+	KUpdateRoot common.Address         `json:"kupdateRoot" binding:"required"`
+}
+
+// CreateIdRes is the result of a CreateIdReq: the new identity with the proof
+// of its operational key claim, or the error that prevented its creation.
+type CreateIdRes struct {
+	Id       *core.ID          `json:"id,omitempty"`
+	ProofKOp *proof.ProofClaim `json:"proofClaim,omitempty"`
+	Error    string            `json:"error,omitempty"`
+}
+
+// CreateIdBatchReq contains several identity creation requests
+type CreateIdBatchReq struct {
+	Ids []CreateIdReq `json:"ids" binding:"required,dive"`
+}
+
+// CreateIdBatchRes contains the result of each request of a CreateIdBatchReq,
+// in the same order.  Failed is the number of requests that failed.
+type CreateIdBatchRes struct {
+	Ids    []CreateIdRes `json:"ids"`
+	Failed int           `json:"failed"`
+}
@@ -1,23 +1,246 @@
 package idenpuboffchainreader
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
 
 	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
 	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/retry"
 )
 
+var (
+	ErrDeltaChainTooLong    = fmt.Errorf("too many delta publications to reconstruct the public data")
+	ErrPublicDataMismatch   = fmt.Errorf("the publication doesn't match the requested identity state")
+	ErrTreeRootMismatch     = fmt.Errorf("the reconstructed tree doesn't match the published root")
+	ErrPublicDataURLUnknown = fmt.Errorf("the public data URL of the identity is unknown")
+	ErrIPFSGatewayNotSet    = fmt.Errorf("an IPFS gateway is required to read ipfs:// public data URLs")
+)
+
+// MaxDeltaChain is the maximum number of delta publications applied to
+// reconstruct a PublicData.
+const MaxDeltaChain = 1024
+
+// maxLevels is the maximum number of levels of the reconstructed trees.
+const maxLevels = 140
+
 // IdenPubOffChainReader is a interface to read the off chain public state of an identity.
 type IdenPubOffChainReader interface {
 	Publish()
 }
 
-// IdenPubOffChainReadHttp satisfies the IdenPubOffChainRead interface, and reads the off chain public state of an identity from a IdenPubOffChainWriteHttp.
+// PublicationGetter returns the publication of the identity state idenState,
+// which can be a delta.
+type PublicationGetter func(idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error)
+
+// Reconstruct returns the full PublicData of the publication publicData.  If
+// it's a delta, the previous publications are fetched with get until a full
+// snapshot is found, and the deltas are applied to it in order.  The
+// reconstructed trees are rebuilt from their leafs, whose roots must be the
+// published ones.
+func Reconstruct(get PublicationGetter, publicData *idenpuboffchainwriter.PublicData) (*idenpuboffchainwriter.PublicData, error) {
+	if !publicData.IsDelta() {
+		return publicData, nil
+	}
+	chain := []*idenpuboffchainwriter.PublicData{publicData}
+	for cur := publicData; cur.IsDelta(); {
+		if len(chain) > MaxDeltaChain {
+			return nil, ErrDeltaChainTooLong
+		}
+		prev, err := get(cur.PrevIdenState)
+		if err != nil {
+			return nil, err
+		}
+		if !prev.IdenState.Equals(cur.PrevIdenState) {
+			return nil, ErrPublicDataMismatch
+		}
+		chain = append(chain, prev)
+		cur = prev
+	}
+
+	// Apply the full snapshot and then the deltas, from the oldest.
+	rotNodes, err := merkletree.NewMerkleTreeInMemory(maxLevels)
+	if err != nil {
+		return nil, err
+	}
+	retNodes, err := merkletree.NewMerkleTreeInMemory(maxLevels)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if err := rotNodes.ImportTree(bytes.NewReader(chain[i].RootsTree)); err != nil {
+			return nil, err
+		}
+		if err := retNodes.ImportTree(bytes.NewReader(chain[i].RevocationsTree)); err != nil {
+			return nil, err
+		}
+	}
+	rot, err := rebuildTree(rotNodes, &publicData.RootsTreeRoot)
+	if err != nil {
+		return nil, err
+	}
+	ret, err := rebuildTree(retNodes, &publicData.RevocationsTreeRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	rotBlob := bytes.NewBufferString("")
+	if err := rot.DumpTree(rotBlob, nil); err != nil {
+		return nil, err
+	}
+	retBlob := bytes.NewBufferString("")
+	if err := ret.DumpTree(retBlob, nil); err != nil {
+		return nil, err
+	}
+	return &idenpuboffchainwriter.PublicData{
+		IdenState:           publicData.IdenState,
+		ClaimsTreeRoot:      publicData.ClaimsTreeRoot,
+		RootsTreeRoot:       publicData.RootsTreeRoot,
+		RootsTree:           rotBlob.Bytes(),
+		RevocationsTreeRoot: publicData.RevocationsTreeRoot,
+		RevocationsTree:     retBlob.Bytes(),
+	}, nil
+}
+
+// rebuildTree rebuilds the tree with root from the leafs of the imported
+// nodes.  ImportTree doesn't check the hashes of the nodes, so the tree is
+// rebuilt with ImportDumpedLeafs, which validates every leaf and checks that
+// the resulting root is root.
+func rebuildTree(nodes *merkletree.MerkleTree, root *merkletree.Hash) (*merkletree.MerkleTree, error) {
+	leafs, err := nodes.DumpClaims(root)
+	if err != nil {
+		return nil, err
+	}
+	mt, err := merkletree.NewMerkleTreeInMemory(maxLevels)
+	if err != nil {
+		return nil, err
+	}
+	if err := mt.ImportDumpedLeafs(leafs, root, nil); errors.Is(err, merkletree.ErrRootMismatch) {
+		return nil, ErrTreeRootMismatch
+	} else if err != nil {
+		return nil, err
+	}
+	return mt, nil
+}
+
+// IdenPubOffChainReadHttp reads the off chain public state of an identity
+// published with the layout of the IdenPubOffChainWriteS3 and served over
+// HTTP at the idPubUrl of the identity.  Delta publications are
+// reconstructed.
 type IdenPubOffChainReadHttp struct {
+	// Client is the HTTP client used for the requests.  If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+	// Retry is the retry policy of each request.  The responses with a 4xx
+	// status code (except 408 and 429) are not retried.  The zero Policy
+	// makes a single attempt.
+	Retry retry.Policy
+	// URLs resolves the idPubUrl of an identity from its ID when
+	// GetPublicData is called without it.  It's satisfied by
+	// idenpubonchain.IdenPubOnChain.  If nil, the idPubUrl is required.
+	URLs PublicDataURLGetter
+	// IPFSGateway is the HTTP gateway used to read the publications at
+	// ipfs://<cid> URLs, like "https://ipfs.io".
+	IPFSGateway string
 }
 
+// PublicDataURLGetter returns the off chain public data URL registered on
+// chain by an identity, or "" if it hasn't registered any.
+type PublicDataURLGetter interface {
+	GetPublicDataURL(id *core.ID) (string, error)
+}
+
+// ResolvePublicDataURL returns the HTTP URL of the off chain public data of
+// the identity id.  If idPubUrl is empty, the URL registered on chain is
+// resolved with URLs.  ipfs://<cid> URLs are read through the IPFSGateway.
+func (i *IdenPubOffChainReadHttp) ResolvePublicDataURL(idPubUrl string, id *core.ID) (string, error) {
+	if idPubUrl == "" {
+		if i.URLs == nil || id == nil {
+			return "", ErrPublicDataURLUnknown
+		}
+		var err error
+		if idPubUrl, err = i.URLs.GetPublicDataURL(id); err != nil {
+			return "", err
+		}
+		if idPubUrl == "" {
+			return "", ErrPublicDataURLUnknown
+		}
+	}
+	if cid := strings.TrimPrefix(idPubUrl, "ipfs://"); cid != idPubUrl {
+		if i.IPFSGateway == "" {
+			return "", ErrIPFSGatewayNotSet
+		}
+		return strings.TrimSuffix(i.IPFSGateway, "/") + "/ipfs/" + cid, nil
+	}
+	return idPubUrl, nil
+}
+
+// GetPublicData returns the full off chain public data of the identity
+// state idenState, or of the last published state if idenState is nil.  The
+// idPubUrl can be empty to use the one registered on chain by id (see
+// ResolvePublicDataURL).
 func (i *IdenPubOffChainReadHttp) GetPublicData(idPubUrl string, id *core.ID, idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	idPubUrl, err := i.ResolvePublicDataURL(idPubUrl, id)
+	if err != nil {
+		return nil, err
+	}
+	get := func(idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+		return i.get(idPubUrl, idenpuboffchainwriter.S3KeyIdenStatePrefix+idenState.Hex())
+	}
+	var publicData *idenpuboffchainwriter.PublicData
+	if idenState == nil {
+		publicData, err = i.get(idPubUrl, idenpuboffchainwriter.S3KeyLatest)
+	} else {
+		publicData, err = get(idenState)
+		if err == nil && !publicData.IdenState.Equals(idenState) {
+			err = ErrPublicDataMismatch
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Reconstruct(get, publicData)
+}
+
+// get fetches the publication at key relative to idPubUrl, retrying with the
+// Retry policy.
+func (i *IdenPubOffChainReadHttp) get(idPubUrl, key string) (*idenpuboffchainwriter.PublicData, error) {
+	var publicData *idenpuboffchainwriter.PublicData
+	err := i.Retry.Do(context.Background(), func() (err error) {
+		publicData, err = i.getOnce(idPubUrl, key)
+		return err
+	})
+	return publicData, err
+}
 
-	return nil, fmt.Errorf("TODO")
+func (i *IdenPubOffChainReadHttp) getOnce(idPubUrl, key string) (*idenpuboffchainwriter.PublicData, error) {
+	client := i.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(strings.TrimSuffix(idPubUrl, "/") + "/" + key)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		err := fmt.Errorf("GET %v: %v: %s", key, res.Status, body)
+		if !retry.RetryableStatus(res.StatusCode) {
+			return nil, retry.Permanent(err)
+		}
+		return nil, err
+	}
+	var publicData idenpuboffchainwriter.PublicData
+	if err := json.NewDecoder(res.Body).Decode(&publicData); err != nil {
+		return nil, retry.Permanent(err)
+	}
+	return &publicData, nil
 }
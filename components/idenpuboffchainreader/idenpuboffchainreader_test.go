@@ -0,0 +1,263 @@
+package idenpuboffchainreader
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/retry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// objectStore is a fake S3 bucket served over HTTP: PUT stores objects and
+// GET returns them.  The first unavailable GETs fail with a 503.
+type objectStore struct {
+	mutex       sync.Mutex
+	objects     map[string][]byte
+	unavailable int
+	gets        int
+}
+
+func (s *objectStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := ioutil.ReadAll(r.Body)
+		s.objects[r.URL.Path] = body
+	case http.MethodGet:
+		s.gets++
+		if s.unavailable > 0 {
+			s.unavailable--
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, ok := s.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(body)
+	}
+}
+
+func dumpTree(t *testing.T, mt *merkletree.MerkleTree, root *merkletree.Hash) []byte {
+	w := bytes.NewBufferString("")
+	require.Nil(t, mt.DumpTree(w, root))
+	return w.Bytes()
+}
+
+func TestReadDeltaPublications(t *testing.T) {
+	cltMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rotMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	retMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+
+	store := &objectStore{objects: map[string][]byte{}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+	cfg := idenpuboffchainwriter.S3ConfigDefault
+	cfg.Endpoint = server.URL
+	cfg.Bucket = "bucket"
+	cfg.Prefix = "iden/"
+	cfg.SnapshotInterval = 3
+	w := idenpuboffchainwriter.NewIdenPubOffChainWriteS3(cfg, rotMt, retMt)
+
+	type state struct {
+		idenState, rootsRoot, revocationsRoot *merkletree.Hash
+	}
+	states := []state{}
+	for i := 0; i < 5; i++ {
+		var indexSlot [claims.IndexSlotBytes]byte
+		var dataSlot [claims.DataSlotBytes]byte
+		indexSlot[0] = byte(i)
+		require.Nil(t, cltMt.AddClaim(claims.NewClaimBasic(indexSlot, dataSlot, uint32(i))))
+		require.Nil(t, claims.AddLeafRootsTree(rotMt, cltMt.RootKey()))
+		require.Nil(t, claims.AddLeafRevocationsTree(retMt, uint32(i), 1))
+		idenState := core.IdenState(cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey())
+		require.Nil(t, w.Publish(idenState, cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey()))
+		states = append(states, state{idenState, rotMt.RootKey(), retMt.RootKey()})
+	}
+
+	// Snapshots every 3 publications, deltas in between.
+	for i, s := range states {
+		var publicData idenpuboffchainwriter.PublicData
+		require.Nil(t, json.Unmarshal(store.objects["/bucket/iden/idenstate/"+s.idenState.Hex()], &publicData))
+		assert.Equal(t, i%3 != 0, publicData.IsDelta(), i)
+		if publicData.IsDelta() {
+			assert.Equal(t, states[i-1].idenState, publicData.PrevIdenState)
+			assert.True(t, len(publicData.RootsTree) < len(dumpTree(t, rotMt, s.rootsRoot)))
+		}
+	}
+
+	r := &IdenPubOffChainReadHttp{}
+	idPubUrl := server.URL + "/bucket/iden"
+	for _, s := range append(states, state{}) {
+		publicData, err := r.GetPublicData(idPubUrl, nil, s.idenState)
+		require.Nil(t, err)
+		if s.idenState == nil {
+			// latest
+			s = states[len(states)-1]
+		}
+		assert.False(t, publicData.IsDelta())
+		assert.Equal(t, *s.idenState, publicData.IdenState)
+		assert.Equal(t, dumpTree(t, rotMt, s.rootsRoot), publicData.RootsTree)
+		assert.Equal(t, dumpTree(t, retMt, s.revocationsRoot), publicData.RevocationsTree)
+	}
+
+	// A missing link in the chain of deltas
+	for k := range store.objects {
+		if strings.HasSuffix(k, states[3].idenState.Hex()) {
+			delete(store.objects, k)
+		}
+	}
+	_, err = r.GetPublicData(idPubUrl, nil, states[4].idenState)
+	assert.NotNil(t, err)
+}
+
+func TestReconstructMismatch(t *testing.T) {
+	prevIdenState := merkletree.Hash{1}
+	delta := &idenpuboffchainwriter.PublicData{PrevIdenState: &prevIdenState}
+	_, err := Reconstruct(func(idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+		return &idenpuboffchainwriter.PublicData{IdenState: merkletree.Hash{2}}, nil
+	}, delta)
+	assert.Equal(t, ErrPublicDataMismatch, err)
+
+	// A chain of deltas that links to itself
+	loop := &idenpuboffchainwriter.PublicData{IdenState: prevIdenState, PrevIdenState: &prevIdenState}
+	_, err = Reconstruct(func(idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+		return loop, nil
+	}, loop)
+	assert.Equal(t, ErrDeltaChainTooLong, err)
+}
+
+func TestReconstructTamperedNodes(t *testing.T) {
+	rotMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	var indexSlot [claims.IndexSlotBytes]byte
+	var dataSlot [claims.DataSlotBytes]byte
+	copy(dataSlot[:], []byte{0x11, 0x22, 0x33, 0x44})
+	for i := 0; i < 3; i++ {
+		indexSlot[0] = byte(i)
+		require.Nil(t, rotMt.AddClaim(claims.NewClaimBasic(indexSlot, dataSlot, 0)))
+	}
+	retMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	snapshot := &idenpuboffchainwriter.PublicData{
+		IdenState:           merkletree.Hash{1},
+		RootsTreeRoot:       *rotMt.RootKey(),
+		RootsTree:           dumpTree(t, rotMt, nil),
+		RevocationsTreeRoot: *retMt.RootKey(),
+		RevocationsTree:     dumpTree(t, retMt, nil),
+	}
+	delta := func() *idenpuboffchainwriter.PublicData {
+		var rot, ret bytes.Buffer
+		require.Nil(t, rotMt.DumpTreeDelta(&rot, nil, nil))
+		require.Nil(t, retMt.DumpTreeDelta(&ret, nil, nil))
+		return &idenpuboffchainwriter.PublicData{
+			IdenState:           merkletree.Hash{2},
+			PrevIdenState:       &snapshot.IdenState,
+			RootsTreeRoot:       snapshot.RootsTreeRoot,
+			RootsTree:           rot.Bytes(),
+			RevocationsTreeRoot: snapshot.RevocationsTreeRoot,
+			RevocationsTree:     ret.Bytes(),
+		}
+	}
+	get := func(idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+		return snapshot, nil
+	}
+	publicData, err := Reconstruct(get, delta())
+	require.Nil(t, err)
+	assert.Equal(t, snapshot.RootsTree, publicData.RootsTree)
+
+	// The data of the leafs is changed, keeping the keys of the nodes.
+	require.Equal(t, 3, bytes.Count(snapshot.RootsTree, []byte{0x11, 0x22, 0x33, 0x44}))
+	snapshot.RootsTree = bytes.Replace(snapshot.RootsTree, []byte{0x11, 0x22, 0x33, 0x44},
+		[]byte{0x11, 0x22, 0x33, 0x45}, 1)
+	_, err = Reconstruct(get, delta())
+	assert.Equal(t, ErrTreeRootMismatch, err)
+}
+
+func TestReadRetry(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+	publicData := idenpuboffchainwriter.PublicData{IdenState: merkletree.Hash{1}}
+	publicDataJSON, err := json.Marshal(&publicData)
+	require.Nil(t, err)
+	store.objects["/iden/latest"] = publicDataJSON
+
+	r := &IdenPubOffChainReadHttp{Retry: retry.Policy{MaxAttempts: 3, BackoffMin: time.Millisecond,
+		BackoffMax: time.Millisecond}}
+	store.unavailable = 2
+	res, err := r.GetPublicData(server.URL+"/iden", nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, publicData.IdenState, res.IdenState)
+	assert.Equal(t, 3, store.gets)
+
+	// A missing publication is not retried.
+	store.gets = 0
+	_, err = r.GetPublicData(server.URL+"/iden", nil, &merkletree.Hash{2})
+	assert.NotNil(t, err)
+	assert.Equal(t, 1, store.gets)
+}
+
+// registry is a PublicDataURLGetter of a map of URLs by ID.
+type registry map[core.ID]string
+
+func (r registry) GetPublicDataURL(id *core.ID) (string, error) {
+	return r[*id], nil
+}
+
+func TestResolvePublicDataURL(t *testing.T) {
+	store := &objectStore{objects: map[string][]byte{}}
+	server := httptest.NewServer(store)
+	defer server.Close()
+	publicData := idenpuboffchainwriter.PublicData{IdenState: merkletree.Hash{1}}
+	publicDataJSON, err := json.Marshal(&publicData)
+	require.Nil(t, err)
+	store.objects["/iden/latest"] = publicDataJSON
+	store.objects["/ipfs/cid0/latest"] = publicDataJSON
+
+	id0, id1, id2 := core.ID{0}, core.ID{1}, core.ID{2}
+	r := &IdenPubOffChainReadHttp{}
+	_, err = r.GetPublicData("", &id0, nil)
+	assert.Equal(t, ErrPublicDataURLUnknown, err)
+
+	r.URLs = registry{id0: server.URL + "/iden", id1: "ipfs://cid0"}
+	res, err := r.GetPublicData("", &id0, nil)
+	require.Nil(t, err)
+	assert.Equal(t, publicData.IdenState, res.IdenState)
+	_, err = r.GetPublicData("", &id2, nil)
+	assert.Equal(t, ErrPublicDataURLUnknown, err)
+
+	// IPFS CIDs are read through the gateway
+	_, err = r.GetPublicData("", &id1, nil)
+	assert.Equal(t, ErrIPFSGatewayNotSet, err)
+	r.IPFSGateway = server.URL + "/"
+	url, err := r.ResolvePublicDataURL("", &id1)
+	require.Nil(t, err)
+	assert.Equal(t, server.URL+"/ipfs/cid0", url)
+	res, err = r.GetPublicData("", &id1, nil)
+	require.Nil(t, err)
+	assert.Equal(t, publicData.IdenState, res.IdenState)
+
+	// An explicit idPubUrl is not resolved
+	url, err = r.ResolvePublicDataURL("https://iden", &id0)
+	require.Nil(t, err)
+	assert.Equal(t, "https://iden", url)
+}
@@ -0,0 +1,57 @@
+package idenpuboffchainwriter
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/merkletree"
+	log "github.com/sirupsen/logrus"
+)
+
+// Handler returns an http.Handler that serves the proofs of the claims in the
+// published states:
+//
+//	GET /claims/{hindex}/proof?state={idenState}
+//
+// hindex and idenState are hex encoded.  If state is not set, the proof in
+// the last published state is returned.  The response is a JSON ClaimProof.
+func (i *IdenPubOffChainWriteHttp) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/claims/", i.handleGetClaimProof)
+	return mux
+}
+
+func (i *IdenPubOffChainWriteHttp) handleGetClaimProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/claims/"), "/")
+	if len(parts) != 2 || parts[1] != "proof" {
+		httpjson.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+	hIndex, err := merkletree.NewHashFromHex(parts[0])
+	if err != nil {
+		httpjson.WriteError(w, http.StatusBadRequest, "invalid hindex: "+err.Error())
+		return
+	}
+	var idenState *merkletree.Hash
+	if state := r.URL.Query().Get("state"); state != "" {
+		if idenState, err = merkletree.NewHashFromHex(state); err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid state: "+err.Error())
+			return
+		}
+	}
+	claimProof, err := i.GetClaimProof(hIndex, idenState)
+	if err == ErrIdenStateNotFound {
+		httpjson.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		log.WithError(err).Error("GetClaimProof")
+		httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+		return
+	}
+	httpjson.Write(w, http.StatusOK, claimProof)
+}
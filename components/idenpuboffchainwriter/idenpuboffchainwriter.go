@@ -36,19 +36,22 @@ type Config struct {
 }
 
 // IdenPubOffChainWriteHttp satisfies the IdenPubOffChainWriter interface, and stores in a leveldb the published RootsTree & RevocationsTree to be returned when requested.
+// It also serves proofs of the claims in the ClaimsTree of the published states, so that verifiers don't need the full trees.
 type IdenPubOffChainWriteHttp struct {
 	rw              *sync.RWMutex
 	storage         db.Storage
+	claimsTree      *merkletree.MerkleTree
 	rootsTree       *merkletree.MerkleTree
 	revocationsTree *merkletree.MerkleTree
 	cfg             *Config
 }
 
 // NewIdenPubOffChainWriteHttp returns a new IdenPubOffChainWriteHttp
-func NewIdenPubOffChainWriteHttp(cfg *Config, storage db.Storage, rootsTree *merkletree.MerkleTree, revocationsTree *merkletree.MerkleTree) (*IdenPubOffChainWriteHttp, error) {
+func NewIdenPubOffChainWriteHttp(cfg *Config, storage db.Storage, claimsTree, rootsTree *merkletree.MerkleTree, revocationsTree *merkletree.MerkleTree) (*IdenPubOffChainWriteHttp, error) {
 	i := IdenPubOffChainWriteHttp{
 		rw:              &sync.RWMutex{},
 		storage:         storage,
+		claimsTree:      claimsTree,
 		rootsTree:       rootsTree,
 		revocationsTree: revocationsTree,
 		cfg:             cfg,
@@ -68,7 +71,7 @@ func NewIdenPubOffChainWriteHttp(cfg *Config, storage db.Storage, rootsTree *mer
 }
 
 // LoadIdenPubOffChainWriteHttp returns a new IdenPubOffChainWriteHttp
-func LoadIdenPubOffChainWriteHttp(storage db.Storage, rootsTree *merkletree.MerkleTree, revocationsTree *merkletree.MerkleTree) (*IdenPubOffChainWriteHttp, error) {
+func LoadIdenPubOffChainWriteHttp(storage db.Storage, claimsTree, rootsTree *merkletree.MerkleTree, revocationsTree *merkletree.MerkleTree) (*IdenPubOffChainWriteHttp, error) {
 	var cfg Config
 	if err := db.LoadJSON(storage, dbKeyConfig, &cfg); err != nil {
 		return nil, err
@@ -76,6 +79,7 @@ func LoadIdenPubOffChainWriteHttp(storage db.Storage, rootsTree *merkletree.Merk
 	i := IdenPubOffChainWriteHttp{
 		rw:              &sync.RWMutex{},
 		storage:         storage,
+		claimsTree:      claimsTree,
 		rootsTree:       rootsTree,
 		revocationsTree: revocationsTree,
 		cfg:             &cfg,
@@ -85,21 +89,10 @@ func LoadIdenPubOffChainWriteHttp(storage db.Storage, rootsTree *merkletree.Merk
 
 // Publish publishes the RootsTree and RevocationsTree to the configured way of publishing
 func (i *IdenPubOffChainWriteHttp) Publish(idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash) error {
-	// RootsTree
-	w := bytes.NewBufferString("")
-	err := i.rootsTree.DumpTree(w, rootsRoot)
+	rotBlob, retBlob, err := dumpTrees(i.rootsTree, i.revocationsTree, rootsRoot, revocationsRoot)
 	if err != nil {
 		return err
 	}
-	rotBlob := w.Bytes()
-
-	// RevocationsTree
-	w = bytes.NewBufferString("")
-	err = i.revocationsTree.DumpTree(w, revocationsRoot)
-	if err != nil {
-		return err
-	}
-	retBlob := w.Bytes()
 
 	tx, err := i.storage.NewTx()
 	if err != nil {
@@ -155,6 +148,27 @@ func (i *IdenPubOffChainWriteHttp) initCacheIdx(tx db.Tx) {
 	tx.Put(dbKeyCacheIdx, []byte{0})
 }
 
+// cacheIdxByIdenState returns the cacheIdx where the published data of
+// queryIdenState is stored.  If queryIdenState is nil, the cacheIdx of the
+// last published data is returned.
+func (i *IdenPubOffChainWriteHttp) cacheIdxByIdenState(tx db.Tx, queryIdenState *merkletree.Hash) (byte, error) {
+	if queryIdenState == nil {
+		return i.prevCacheIdx(tx)
+	}
+	for idx := byte(0); idx < i.cfg.CacheLen; idx++ {
+		idenState, err := tx.Get(append(dbKeyIdenState, idx))
+		if err == db.ErrNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if bytes.Equal(queryIdenState[:], idenState) {
+			return idx, nil
+		}
+	}
+	return 0, ErrIdenStateNotFound
+}
+
 // func (i *IdenPubOffChainWriteHttp) getCacheIdx(tx db.Tx) (byte, error) {
 // 	cacheIdx, err := tx.Get(dbKeyCacheIdx)
 // 	if err == db.ErrNotFound {
@@ -173,6 +187,75 @@ type PublicData struct {
 	RootsTree           []byte
 	RevocationsTreeRoot merkletree.Hash
 	RevocationsTree     []byte
+	// PrevIdenState is only set in delta publications, where RootsTree and
+	// RevocationsTree only contain the nodes that are not in the trees of
+	// the publication of PrevIdenState (see merkletree.DumpTreeDelta).
+	PrevIdenState *merkletree.Hash `json:",omitempty"`
+}
+
+// IsDelta returns true if p is a delta publication, which needs the previous
+// publications to reconstruct the trees.
+func (p *PublicData) IsDelta() bool {
+	return p.PrevIdenState != nil
+}
+
+// dumpTrees returns the dumps of the RootsTree at rootsRoot and the
+// RevocationsTree at revocationsRoot.
+func dumpTrees(rootsTree, revocationsTree *merkletree.MerkleTree, rootsRoot, revocationsRoot *merkletree.Hash) ([]byte, []byte, error) {
+	w := bytes.NewBufferString("")
+	if err := rootsTree.DumpTree(w, rootsRoot); err != nil {
+		return nil, nil, err
+	}
+	rotBlob := w.Bytes()
+	w = bytes.NewBufferString("")
+	if err := revocationsTree.DumpTree(w, revocationsRoot); err != nil {
+		return nil, nil, err
+	}
+	retBlob := w.Bytes()
+	return rotBlob, retBlob, nil
+}
+
+// newPublicData builds the PublicData of a published identity state.
+func newPublicData(rootsTree, revocationsTree *merkletree.MerkleTree,
+	idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash) (*PublicData, error) {
+	rotBlob, retBlob, err := dumpTrees(rootsTree, revocationsTree, rootsRoot, revocationsRoot)
+	if err != nil {
+		return nil, err
+	}
+	return &PublicData{
+		IdenState:           *idenState,
+		ClaimsTreeRoot:      *claimsRoot,
+		RootsTreeRoot:       *rootsRoot,
+		RootsTree:           rotBlob,
+		RevocationsTreeRoot: *revocationsRoot,
+		RevocationsTree:     retBlob,
+	}, nil
+}
+
+// newPublicDataDelta builds the delta PublicData of a published identity
+// state from the previous publication.
+func newPublicDataDelta(rootsTree, revocationsTree *merkletree.MerkleTree, prev *PublicData,
+	idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash) (*PublicData, error) {
+	w := bytes.NewBufferString("")
+	if err := rootsTree.DumpTreeDelta(w, &prev.RootsTreeRoot, rootsRoot); err != nil {
+		return nil, err
+	}
+	rotBlob := w.Bytes()
+	w = bytes.NewBufferString("")
+	if err := revocationsTree.DumpTreeDelta(w, &prev.RevocationsTreeRoot, revocationsRoot); err != nil {
+		return nil, err
+	}
+	retBlob := w.Bytes()
+	prevIdenState := prev.IdenState
+	return &PublicData{
+		IdenState:           *idenState,
+		ClaimsTreeRoot:      *claimsRoot,
+		RootsTreeRoot:       *rootsRoot,
+		RootsTree:           rotBlob,
+		RevocationsTreeRoot: *revocationsRoot,
+		RevocationsTree:     retBlob,
+		PrevIdenState:       &prevIdenState,
+	}, nil
 }
 
 // GetPublicData returns the identity off chain public data corresponding to
@@ -187,27 +270,9 @@ func (i *IdenPubOffChainWriteHttp) GetPublicData(queryIdenState *merkletree.Hash
 	i.rw.RLock()
 	defer i.rw.RUnlock()
 
-	var cacheIdx byte
-	if queryIdenState == nil {
-		cacheIdx, err = i.prevCacheIdx(tx)
-		if err != nil {
-			return nil, err
-		}
-	} else {
-		idx := byte(0)
-		for ; idx < i.cfg.CacheLen; idx++ {
-			// idenState
-			idenState, err := tx.Get(append(dbKeyIdenState, idx))
-			if err != nil {
-				return nil, err
-			}
-			if bytes.Equal(queryIdenState[:], idenState) {
-				break
-			}
-		}
-		if idx == i.cfg.CacheLen {
-			return nil, ErrIdenStateNotFound
-		}
+	cacheIdx, err := i.cacheIdxByIdenState(tx, queryIdenState)
+	if err != nil {
+		return nil, err
 	}
 	// idenState
 	idenState, err := tx.Get(append(dbKeyIdenState, cacheIdx))
@@ -241,22 +306,78 @@ func (i *IdenPubOffChainWriteHttp) GetPublicData(queryIdenState *merkletree.Hash
 		return nil, err
 	}
 
-	var idenState32 [merkletree.ElemBytesLen]byte
-	var cltRoot32 [merkletree.ElemBytesLen]byte
-	var rotRoot32 [merkletree.ElemBytesLen]byte
-	var retRoot32 [merkletree.ElemBytesLen]byte
-	copy(idenState32[:], idenState[:32])
-	copy(cltRoot32[:], cltRoot[:32])
-	copy(rotRoot32[:], rotRoot[:32])
-	copy(retRoot32[:], retRoot[:32])
-
 	p := &PublicData{
-		IdenState:           merkletree.Hash(merkletree.ElemBytes(idenState32)),
-		ClaimsTreeRoot:      merkletree.Hash(merkletree.ElemBytes(cltRoot32)),
-		RootsTreeRoot:       merkletree.Hash(merkletree.ElemBytes(rotRoot32)),
-		RootsTree:           rot,
-		RevocationsTreeRoot: merkletree.Hash(merkletree.ElemBytes(retRoot32)),
-		RevocationsTree:     ret,
+		RootsTree:       rot,
+		RevocationsTree: ret,
+	}
+	for _, kv := range []struct {
+		v    []byte
+		hash *merkletree.Hash
+	}{
+		{idenState, &p.IdenState},
+		{cltRoot, &p.ClaimsTreeRoot},
+		{rotRoot, &p.RootsTreeRoot},
+		{retRoot, &p.RevocationsTreeRoot},
+	} {
+		hash, err := merkletree.NewHashFromBytes(kv.v)
+		if err != nil {
+			return nil, err
+		}
+		*kv.hash = *hash
+	}
+	return p, nil
+}
+
+// ClaimProof contains the proof of a claim in the ClaimsTree of a published
+// identity state, together with the roots needed to check the state.
+type ClaimProof struct {
+	IdenState           merkletree.Hash   `json:"idenState"`
+	ClaimsTreeRoot      merkletree.Hash   `json:"claimsTreeRoot"`
+	RevocationsTreeRoot merkletree.Hash   `json:"revocationsTreeRoot"`
+	RootsTreeRoot       merkletree.Hash   `json:"rootsTreeRoot"`
+	Mtp                 *merkletree.Proof `json:"mtp"`
+}
+
+// GetClaimProof returns the proof of the claim with hIndex in the ClaimsTree
+// of the published queryIdenState.  If the queryIdenState is nil, the proof
+// in the last published state is returned.  The proof is of non-existence if
+// the claim is not in the ClaimsTree.
+func (i *IdenPubOffChainWriteHttp) GetClaimProof(hIndex, queryIdenState *merkletree.Hash) (*ClaimProof, error) {
+	tx, err := i.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	i.rw.RLock()
+	defer i.rw.RUnlock()
+
+	cacheIdx, err := i.cacheIdxByIdenState(tx, queryIdenState)
+	if err != nil {
+		return nil, err
+	}
+	p := &ClaimProof{}
+	for _, kv := range []struct {
+		key  []byte
+		hash *merkletree.Hash
+	}{
+		{dbKeyIdenState, &p.IdenState},
+		{dbKeyClaimsRoot, &p.ClaimsTreeRoot},
+		{dbKeyRevocationsRoot, &p.RevocationsTreeRoot},
+		{dbKeyRootsRoot, &p.RootsTreeRoot},
+	} {
+		v, err := tx.Get(append(kv.key, cacheIdx))
+		if err != nil {
+			return nil, err
+		}
+		hash, err := merkletree.NewHashFromBytes(v)
+		if err != nil {
+			return nil, err
+		}
+		*kv.hash = *hash
+	}
+	p.Mtp, err = i.claimsTree.GenerateProof(hIndex, &p.ClaimsTreeRoot)
+	if err != nil {
+		return nil, err
 	}
 	return p, nil
 }
@@ -1,11 +1,15 @@
 package idenpuboffchainwriter
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"strconv"
 	"testing"
 
+	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/claims"
 	"github.com/iden3/go-iden3-core/db"
 	"github.com/iden3/go-iden3-core/merkletree"
@@ -44,7 +48,7 @@ func TestHttpPublicGetPublicData(t *testing.T) {
 	testgen.CheckTestValue(t, "rootRootsTree1", rotMt.RootKey().Hex())
 	testgen.CheckTestValue(t, "rootRevocationsTree1", retMt.RootKey().Hex())
 
-	idenPubOffChainWriteHttp, err := NewIdenPubOffChainWriteHttp(&ConfigDefault, db.NewMemoryStorage(), rotMt, retMt)
+	idenPubOffChainWriteHttp, err := NewIdenPubOffChainWriteHttp(&ConfigDefault, db.NewMemoryStorage(), cltMt, rotMt, retMt)
 	require.Nil(t, err)
 
 	idenState := merkletree.HexStringToHash(testgen.GetTestValue("idenState0").(string))
@@ -60,6 +64,90 @@ func TestHttpPublicGetPublicData(t *testing.T) {
 	assert.Equal(t, retMt.RootKey().Hex(), pubData.RevocationsTreeRoot.Hex())
 }
 
+func TestGetClaimProof(t *testing.T) {
+	cltMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rotMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	retMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	cfg := Config{CacheLen: 2}
+	w, err := NewIdenPubOffChainWriteHttp(&cfg, db.NewMemoryStorage(), cltMt, rotMt, retMt)
+	require.Nil(t, err)
+
+	publish := func() *merkletree.Hash {
+		require.Nil(t, claims.AddLeafRootsTree(rotMt, cltMt.RootKey()))
+		idenState := core.IdenState(cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey())
+		require.Nil(t, w.Publish(idenState, cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey()))
+		return idenState
+	}
+
+	claim0 := claims.NewClaimBasic([claims.IndexSlotBytes]byte{1}, [claims.DataSlotBytes]byte{}, 0)
+	require.Nil(t, cltMt.AddClaim(claim0))
+	idenState0 := publish()
+	claim1 := claims.NewClaimBasic([claims.IndexSlotBytes]byte{2}, [claims.DataSlotBytes]byte{}, 1)
+	require.Nil(t, cltMt.AddClaim(claim1))
+	idenState1 := publish()
+
+	hIndex0 := claim0.Entry().HIndex()
+	hIndex1 := claim1.Entry().HIndex()
+
+	// Last state
+	p, err := w.GetClaimProof(hIndex1, nil)
+	require.Nil(t, err)
+	assert.Equal(t, *idenState1, p.IdenState)
+	assert.True(t, p.Mtp.Existence)
+	assert.True(t, merkletree.VerifyProof(&p.ClaimsTreeRoot, p.Mtp, hIndex1, claim1.Entry().HValue()))
+	assert.Equal(t, p.IdenState, *core.IdenState(&p.ClaimsTreeRoot, &p.RevocationsTreeRoot, &p.RootsTreeRoot))
+
+	// Previous state, where claim1 was not yet issued
+	p, err = w.GetClaimProof(hIndex1, idenState0)
+	require.Nil(t, err)
+	assert.Equal(t, *idenState0, p.IdenState)
+	assert.False(t, p.Mtp.Existence)
+	p, err = w.GetClaimProof(hIndex0, idenState0)
+	require.Nil(t, err)
+	assert.True(t, p.Mtp.Existence)
+
+	_, err = w.GetClaimProof(hIndex0, &merkletree.Hash{1})
+	assert.Equal(t, ErrIdenStateNotFound, err)
+
+	// The public data of the previous state is also found
+	pubData, err := w.GetPublicData(idenState0)
+	require.Nil(t, err)
+	assert.Equal(t, *idenState0, pubData.IdenState)
+
+	// HTTP
+	server := httptest.NewServer(w.Handler())
+	defer server.Close()
+	get := func(path string) (int, *ClaimProof) {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			return res.StatusCode, nil
+		}
+		var p ClaimProof
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&p))
+		return res.StatusCode, &p
+	}
+	status, p := get(fmt.Sprintf("/claims/%s/proof?state=%s", hIndex0.Hex(), idenState0.Hex()))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, *idenState0, p.IdenState)
+	assert.True(t, merkletree.VerifyProof(&p.ClaimsTreeRoot, p.Mtp, hIndex0, claim0.Entry().HValue()))
+	status, p = get(fmt.Sprintf("/claims/%s/proof", hIndex1.Hex()))
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, *idenState1, p.IdenState)
+	assert.True(t, p.Mtp.Existence)
+
+	status, _ = get("/claims/0x1234/proof")
+	assert.Equal(t, http.StatusBadRequest, status)
+	status, _ = get(fmt.Sprintf("/claims/%s/proof?state=%s", hIndex0.Hex(), merkletree.HashZero.Hex()))
+	assert.Equal(t, http.StatusNotFound, status)
+	status, _ = get(fmt.Sprintf("/claims/%s", hIndex0.Hex()))
+	assert.Equal(t, http.StatusNotFound, status)
+}
+
 func initTest() {
 	// Init test
 	err := testgen.InitTest("idenpuboffchainwriter", generateTest)
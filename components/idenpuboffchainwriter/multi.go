@@ -0,0 +1,232 @@
+package idenpuboffchainwriter
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrPublicationsPending = fmt.Errorf("previous publications pending to be retried")
+)
+
+// MultiConfigDefault is a default configuration for the MultiWriter.
+var MultiConfigDefault = MultiConfig{
+	BackoffMin: 5 * time.Second,
+	BackoffMax: 5 * time.Minute,
+}
+
+// MultiConfig allows configuring the MultiWriter.
+type MultiConfig struct {
+	// BackoffMin is the waiting time before retrying a target after the
+	// first failed attempt.  It's doubled after each failed attempt up to
+	// BackoffMax, and reset when the target succeeds.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+}
+
+// Target is a named IdenPubOffChainWriter of a MultiWriter.
+type Target struct {
+	Name   string
+	Writer IdenPubOffChainWriter
+}
+
+// publication is the set of arguments of a Publish call.
+type publication struct {
+	idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash
+}
+
+// target is the state of a Target in a MultiWriter.
+type target struct {
+	Target
+	// mutex serializes the publications to the target, so that they
+	// happen in the same order as the Publish calls.
+	mutex *sync.Mutex
+	// pending are the publications that failed and are waiting to be
+	// retried, in order.
+	pending   []publication
+	backoff   time.Duration
+	nextRetry time.Time
+}
+
+// MultiError contains the errors of the targets that failed in a Publish
+// call of a MultiWriter, by target name.
+type MultiError struct {
+	Errs map[string]error
+}
+
+func (e *MultiError) Error() string {
+	names := make([]string, 0, len(e.Errs))
+	for name := range e.Errs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%v: %v", name, e.Errs[name])
+	}
+	return "publication failed in targets: " + strings.Join(msgs, "; ")
+}
+
+// MultiWriter satisfies the IdenPubOffChainWriter interface, and publishes to
+// several IdenPubOffChainWriters (like the http cache, IPFS or an S3 bucket)
+// so that the public data is redundantly available.  The publications that
+// fail in a target are retried in the background in order, with exponential
+// backoff, while the MultiWriter is started.
+type MultiWriter struct {
+	cfg     MultiConfig
+	targets []*target
+	rw      *sync.RWMutex
+	stop    chan struct{}
+	wg      *sync.WaitGroup
+	started bool
+}
+
+// NewMultiWriter returns a new MultiWriter that publishes to targets.
+func NewMultiWriter(cfg MultiConfig, targets ...Target) *MultiWriter {
+	m := &MultiWriter{
+		cfg:     cfg,
+		targets: make([]*target, len(targets)),
+		rw:      &sync.RWMutex{},
+		wg:      &sync.WaitGroup{},
+	}
+	for i, t := range targets {
+		m.targets[i] = &target{Target: t, mutex: &sync.Mutex{}}
+	}
+	return m
+}
+
+// Publish publishes to all the targets concurrently.  If some targets fail,
+// the publication is queued to be retried in them and a *MultiError is
+// returned.  A target with queued publications queues the new ones without
+// trying them, so that they are published in order, and fails with
+// ErrPublicationsPending.
+func (m *MultiWriter) Publish(idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash) error {
+	pub := publication{idenState: idenState, claimsRoot: claimsRoot, revocationsRoot: revocationsRoot, rootsRoot: rootsRoot}
+	errs := make([]error, len(m.targets))
+	var wg sync.WaitGroup
+	for i, t := range m.targets {
+		wg.Add(1)
+		go func(i int, t *target) {
+			defer wg.Done()
+			errs[i] = m.publishTarget(t, pub)
+		}(i, t)
+	}
+	wg.Wait()
+	multiErr := MultiError{Errs: make(map[string]error)}
+	for i, err := range errs {
+		if err != nil {
+			multiErr.Errs[m.targets[i].Name] = err
+		}
+	}
+	if len(multiErr.Errs) != 0 {
+		return &multiErr
+	}
+	return nil
+}
+
+func (m *MultiWriter) publishTarget(t *target, pub publication) error {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.pending) != 0 {
+		t.pending = append(t.pending, pub)
+		return ErrPublicationsPending
+	}
+	if err := t.Writer.Publish(pub.idenState, pub.claimsRoot, pub.revocationsRoot, pub.rootsRoot); err != nil {
+		t.pending = append(t.pending, pub)
+		t.backoff = m.cfg.BackoffMin
+		t.nextRetry = time.Now().Add(t.backoff)
+		return err
+	}
+	return nil
+}
+
+// Pending returns the number of publications waiting to be retried by
+// target name.
+func (m *MultiWriter) Pending() map[string]int {
+	pending := make(map[string]int, len(m.targets))
+	for _, t := range m.targets {
+		t.mutex.Lock()
+		pending[t.Name] = len(t.pending)
+		t.mutex.Unlock()
+	}
+	return pending
+}
+
+// Start starts the worker that retries the failed publications.
+func (m *MultiWriter) Start() {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	if m.started {
+		return
+	}
+	m.stop = make(chan struct{})
+	m.started = true
+	m.wg.Add(1)
+	go m.run(m.stop)
+}
+
+// Stop stops the retry worker and waits for it to finish.  The failed
+// publications remain queued.
+func (m *MultiWriter) Stop() {
+	m.rw.Lock()
+	if !m.started {
+		m.rw.Unlock()
+		return
+	}
+	m.started = false
+	close(m.stop)
+	m.rw.Unlock()
+	m.wg.Wait()
+}
+
+func (m *MultiWriter) run(stop chan struct{}) {
+	defer m.wg.Done()
+	ticker := time.NewTicker(m.cfg.BackoffMin)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			m.retry()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// retry retries the pending publications of the targets whose backoff has
+// elapsed.
+func (m *MultiWriter) retry() {
+	for _, t := range m.targets {
+		m.retryTarget(t)
+	}
+}
+
+// retryTarget retries in order the pending publications of t until one fails.
+func (m *MultiWriter) retryTarget(t *target) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if len(t.pending) == 0 || time.Now().Before(t.nextRetry) {
+		return
+	}
+	for len(t.pending) != 0 {
+		pub := t.pending[0]
+		if err := t.Writer.Publish(pub.idenState, pub.claimsRoot, pub.revocationsRoot, pub.rootsRoot); err != nil {
+			log.WithError(err).WithField("target", t.Name).WithField("pending", len(t.pending)).
+				Warn("MultiWriter: retry failed")
+			t.backoff *= 2
+			if t.backoff > m.cfg.BackoffMax {
+				t.backoff = m.cfg.BackoffMax
+			}
+			t.nextRetry = time.Now().Add(t.backoff)
+			return
+		}
+		t.pending = t.pending[1:]
+	}
+	t.backoff = m.cfg.BackoffMin
+}
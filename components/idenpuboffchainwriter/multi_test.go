@@ -0,0 +1,75 @@
+package idenpuboffchainwriter
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writerRecorder is an IdenPubOffChainWriter that records the published
+// identity states, and fails while fail is set.
+type writerRecorder struct {
+	mutex      sync.Mutex
+	fail       bool
+	idenStates []merkletree.Hash
+}
+
+func (w *writerRecorder) Publish(idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	if w.fail {
+		return fmt.Errorf("unavailable")
+	}
+	w.idenStates = append(w.idenStates, *idenState)
+	return nil
+}
+
+func (w *writerRecorder) setFail(fail bool) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.fail = fail
+}
+
+func (w *writerRecorder) published() []merkletree.Hash {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	return append([]merkletree.Hash{}, w.idenStates...)
+}
+
+func TestMultiWriter(t *testing.T) {
+	w0 := &writerRecorder{}
+	w1 := &writerRecorder{fail: true}
+	cfg := MultiConfig{BackoffMin: 10 * time.Millisecond, BackoffMax: 20 * time.Millisecond}
+	m := NewMultiWriter(cfg, Target{Name: "w0", Writer: w0}, Target{Name: "w1", Writer: w1})
+
+	state0, state1 := &merkletree.Hash{1}, &merkletree.Hash{2}
+	err := m.Publish(state0, state0, state0, state0)
+	require.NotNil(t, err)
+	multiErr, ok := err.(*MultiError)
+	require.True(t, ok)
+	assert.Equal(t, 1, len(multiErr.Errs))
+	assert.NotNil(t, multiErr.Errs["w1"])
+
+	// w1 has a pending publication, so the next one is queued after it
+	err = m.Publish(state1, state1, state1, state1)
+	require.NotNil(t, err)
+	assert.Equal(t, ErrPublicationsPending, err.(*MultiError).Errs["w1"])
+	assert.Equal(t, map[string]int{"w0": 0, "w1": 2}, m.Pending())
+	assert.Equal(t, []merkletree.Hash{*state0, *state1}, w0.published())
+
+	m.Start()
+	defer m.Stop()
+	w1.setFail(false)
+	for i := 0; i < 100 && m.Pending()["w1"] != 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, map[string]int{"w0": 0, "w1": 0}, m.Pending())
+	assert.Equal(t, []merkletree.Hash{*state0, *state1}, w1.published())
+
+	require.Nil(t, m.Publish(state0, state0, state0, state0))
+}
@@ -0,0 +1,245 @@
+package idenpuboffchainwriter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+const (
+	// S3KeyLatest is the object key, relative to the S3Config.Prefix, of the
+	// last published PublicData.
+	S3KeyLatest = "latest"
+	// S3KeyIdenStatePrefix is the prefix of the object keys, relative to
+	// the S3Config.Prefix, of the PublicData of each published identity
+	// state, which are stored under S3KeyIdenStatePrefix + idenState.Hex().
+	S3KeyIdenStatePrefix = "idenstate/"
+)
+
+// S3ConfigDefault is a default configuration for the
+// IdenPubOffChainWriteS3.  Endpoint, Region, Bucket and the credentials must
+// be set.
+var S3ConfigDefault = S3Config{
+	CacheControlIdenState: "public, max-age=31536000, immutable",
+	CacheControlLatest:    "public, max-age=60",
+	Timeout:               30 * time.Second,
+}
+
+// S3Config allows configuring the IdenPubOffChainWriteS3.
+type S3Config struct {
+	// Endpoint is the base URL of the S3 compatible API, like
+	// https://s3.eu-west-1.amazonaws.com or https://storage.googleapis.com.
+	// Objects are addressed in path style: Endpoint/Bucket/Key.
+	Endpoint string
+	Region   string
+	Bucket   string
+	// Prefix is prepended to the object keys.
+	Prefix          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// CacheControlIdenState is the Cache-Control header of the objects of
+	// each identity state, which never change.
+	CacheControlIdenState string
+	// CacheControlLatest is the Cache-Control header of the latest object,
+	// which is overwritten on every Publish.
+	CacheControlLatest string
+	// Timeout of each HTTP request.
+	Timeout time.Duration
+	// SnapshotInterval enables the delta publications: a full snapshot is
+	// published every SnapshotInterval publications, and the ones in
+	// between are deltas from the previous publication (see
+	// PublicData.PrevIdenState).  With 0 or 1 all the publications are full
+	// snapshots.
+	SnapshotInterval int
+}
+
+// IdenPubOffChainWriteS3 satisfies the IdenPubOffChainWriter interface, and
+// uploads the JSON serialized PublicData of each published state to an S3
+// compatible bucket, so that it can be served statically.  The requests are
+// signed with AWS Signature Version 4, which is also accepted by GCS with
+// HMAC keys.
+type IdenPubOffChainWriteS3 struct {
+	cfg             S3Config
+	client          *http.Client
+	rootsTree       *merkletree.MerkleTree
+	revocationsTree *merkletree.MerkleTree
+	now             func() time.Time
+	mutex           *sync.Mutex
+	// prev is the last publication, used as the base of the next delta.
+	// It's not persisted, so the first publication after a restart is a
+	// full snapshot.
+	prev *PublicData
+	// deltas is the number of delta publications since the last snapshot.
+	deltas int
+}
+
+// NewIdenPubOffChainWriteS3 returns a new IdenPubOffChainWriteS3
+func NewIdenPubOffChainWriteS3(cfg S3Config, rootsTree, revocationsTree *merkletree.MerkleTree) *IdenPubOffChainWriteS3 {
+	return &IdenPubOffChainWriteS3{
+		cfg:             cfg,
+		client:          &http.Client{Timeout: cfg.Timeout},
+		rootsTree:       rootsTree,
+		revocationsTree: revocationsTree,
+		now:             time.Now,
+		mutex:           &sync.Mutex{},
+	}
+}
+
+// Publish uploads the PublicData of idenState under its own key and then
+// overwrites the latest key with it.  If SnapshotInterval is set, the
+// PublicData is a delta from the previous publication unless a snapshot is
+// due.
+func (i *IdenPubOffChainWriteS3) Publish(idenState, claimsRoot, revocationsRoot, rootsRoot *merkletree.Hash) error {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	var publicData *PublicData
+	var err error
+	delta := i.prev != nil && i.deltas+1 < i.cfg.SnapshotInterval
+	if delta {
+		publicData, err = newPublicDataDelta(i.rootsTree, i.revocationsTree, i.prev,
+			idenState, claimsRoot, revocationsRoot, rootsRoot)
+	} else {
+		publicData, err = newPublicData(i.rootsTree, i.revocationsTree, idenState, claimsRoot, revocationsRoot, rootsRoot)
+	}
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(publicData)
+	if err != nil {
+		return err
+	}
+	if err := i.put(S3KeyIdenStatePrefix+idenState.Hex(), body, i.cfg.CacheControlIdenState); err != nil {
+		return err
+	}
+	if err := i.put(S3KeyLatest, body, i.cfg.CacheControlLatest); err != nil {
+		return err
+	}
+	i.prev = publicData
+	if delta {
+		i.deltas++
+	} else {
+		i.deltas = 0
+	}
+	return nil
+}
+
+// put uploads body to the object key.  The SHA-256 and MD5 of the body are
+// sent so that the object store rejects corrupted uploads.
+func (i *IdenPubOffChainWriteS3) put(key string, body []byte, cacheControl string) error {
+	u, err := url.Parse(strings.TrimSuffix(i.cfg.Endpoint, "/"))
+	if err != nil {
+		return err
+	}
+	u.Path = u.Path + "/" + i.cfg.Bucket + "/" + i.cfg.Prefix + key
+	u.RawPath = s3EscapePath(u.Path)
+	req, err := http.NewRequest(http.MethodPut, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	bodyMD5 := md5.Sum(body)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-MD5", base64.StdEncoding.EncodeToString(bodyMD5[:]))
+	if cacheControl != "" {
+		req.Header.Set("Cache-Control", cacheControl)
+	}
+	i.sign(req, body)
+
+	res, err := i.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		resBody, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("S3 PUT %v: %v: %s", key, res.Status, resBody)
+	}
+	return nil
+}
+
+// sign adds the AWS Signature Version 4 Authorization header to req.
+func (i *IdenPubOffChainWriteS3) sign(req *http.Request, body []byte) {
+	now := i.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	payloadHash := sha256.Sum256(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", hex.EncodeToString(payloadHash[:]))
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	canonicalRequestHash := sha256.Sum256([]byte(canonicalRequest))
+	scope := date + "/" + i.cfg.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(canonicalRequestHash[:]),
+	}, "\n")
+	signature := hmacSHA256(s3SigningKey(i.cfg.SecretAccessKey, date, i.cfg.Region, "s3"), []byte(stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		i.cfg.AccessKeyID, scope, signedHeaders, hex.EncodeToString(signature)))
+}
+
+// s3SigningKey derives the AWS Signature Version 4 signing key.
+func s3SigningKey(secret, date, region, service string) []byte {
+	k := hmacSHA256([]byte("AWS4"+secret), []byte(date))
+	k = hmacSHA256(k, []byte(region))
+	k = hmacSHA256(k, []byte(service))
+	return hmacSHA256(k, []byte("aws4_request"))
+}
+
+func hmacSHA256(key, data []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return mac.Sum(nil)
+}
+
+// s3EscapePath URI-encodes every byte of path except the unreserved
+// characters and '/', as required by the canonical request.
+func s3EscapePath(path string) string {
+	var b strings.Builder
+	for _, c := range []byte(path) {
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
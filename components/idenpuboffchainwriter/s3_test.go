@@ -0,0 +1,90 @@
+package idenpuboffchainwriter
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestS3SigningKey(t *testing.T) {
+	// Example from the AWS Signature Version 4 documentation.
+	key := s3SigningKey("wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "20120215", "us-east-1", "iam")
+	assert.Equal(t, "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d", hex.EncodeToString(key))
+}
+
+func TestS3Publish(t *testing.T) {
+	cltMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rotMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	retMt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	require.Nil(t, claims.AddLeafRootsTree(rotMt, cltMt.RootKey()))
+	require.Nil(t, claims.AddLeafRevocationsTree(retMt, 1, 1))
+	idenState := core.IdenState(cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey())
+
+	objects := map[string][]byte{}
+	cacheControl := map[string]string{}
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		require.Equal(t, http.MethodPut, r.Method)
+		body, err := ioutil.ReadAll(r.Body)
+		require.Nil(t, err)
+		bodySHA256 := sha256.Sum256(body)
+		bodyMD5 := md5.Sum(body)
+		assert.Equal(t, hex.EncodeToString(bodySHA256[:]), r.Header.Get("X-Amz-Content-Sha256"))
+		assert.Equal(t, base64.StdEncoding.EncodeToString(bodyMD5[:]), r.Header.Get("Content-MD5"))
+		assert.Equal(t, "20200102T030405Z", r.Header.Get("X-Amz-Date"))
+		assert.Regexp(t, "^AWS4-HMAC-SHA256 Credential=AKID/20200102/eu-west-1/s3/aws4_request, "+
+			"SignedHeaders=cache-control;content-md5;content-type;host;x-amz-content-sha256;x-amz-date, "+
+			"Signature=[0-9a-f]{64}$", r.Header.Get("Authorization"))
+		objects[r.URL.Path] = body
+		cacheControl[r.URL.Path] = r.Header.Get("Cache-Control")
+	}))
+	defer server.Close()
+
+	cfg := S3ConfigDefault
+	cfg.Endpoint = server.URL
+	cfg.Region = "eu-west-1"
+	cfg.Bucket = "bucket"
+	cfg.Prefix = "iden/"
+	cfg.AccessKeyID = "AKID"
+	cfg.SecretAccessKey = "secret"
+	w := NewIdenPubOffChainWriteS3(cfg, rotMt, retMt)
+	w.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	require.Nil(t, w.Publish(idenState, cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey()))
+	stateKey := "/bucket/iden/idenstate/" + idenState.Hex()
+	require.Equal(t, 2, len(objects))
+	assert.Equal(t, objects[stateKey], objects["/bucket/iden/latest"])
+	assert.Equal(t, cfg.CacheControlIdenState, cacheControl[stateKey])
+	assert.Equal(t, cfg.CacheControlLatest, cacheControl["/bucket/iden/latest"])
+
+	var publicData PublicData
+	require.Nil(t, json.Unmarshal(objects[stateKey], &publicData))
+	assert.Equal(t, *idenState, publicData.IdenState)
+	assert.Equal(t, *rotMt.RootKey(), publicData.RootsTreeRoot)
+	assert.Equal(t, *retMt.RootKey(), publicData.RevocationsTreeRoot)
+	assert.NotEqual(t, 0, len(publicData.RootsTree))
+
+	fail = true
+	assert.NotNil(t, w.Publish(idenState, cltMt.RootKey(), retMt.RootKey(), rotMt.RootKey()))
+}
@@ -0,0 +1,65 @@
+package idenpubonchain
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+)
+
+var (
+	// ErrBlockNumberUnsupported is used when the number of the last block
+	// is requested to an IdenPubOnChainer that isn't a BlockNumberer.
+	ErrBlockNumberUnsupported = fmt.Errorf("the IdenPubOnChainer doesn't tell the number of the last block")
+)
+
+// BlockNumberer is an interface to get the number of the last block of the
+// chain, to count the confirmations of the identity states, satisfied by
+// IdenPubOnChain.
+type BlockNumberer interface {
+	BlockNumber() (uint64, error)
+}
+
+// BlockNumbererCtx is a BlockNumberer whose call takes a context, satisfied by
+// IdenPubOnChain.
+type BlockNumbererCtx interface {
+	BlockNumberer
+	BlockNumberCtx(ctx context.Context) (uint64, error)
+}
+
+// BlockNumberCtx calls ip.BlockNumberCtx if ip is a BlockNumbererCtx, or
+// ip.BlockNumber if it's a BlockNumberer and ctx is not done.  It returns
+// ErrBlockNumberUnsupported otherwise.
+func BlockNumberCtx(ctx context.Context, ip IdenPubOnChainer) (uint64, error) {
+	if ipCtx, ok := ip.(BlockNumbererCtx); ok {
+		return ipCtx.BlockNumberCtx(ctx)
+	}
+	bn, ok := ip.(BlockNumberer)
+	if !ok {
+		return 0, ErrBlockNumberUnsupported
+	}
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	return bn.BlockNumber()
+}
+
+// BlockNumber returns the number of the last block of the chain.
+func (ip *IdenPubOnChain) BlockNumber() (uint64, error) {
+	return ip.BlockNumberCtx(context.Background())
+}
+
+// BlockNumberCtx is BlockNumber with a context that cancels the request to
+// the node.
+func (ip *IdenPubOnChain) BlockNumberCtx(ctx context.Context) (uint64, error) {
+	var blockN uint64
+	err := ip.client.Call(func(c *ethclient.Client) error {
+		header, err := c.HeaderByNumber(ctx, nil)
+		if err != nil {
+			return err
+		}
+		blockN = header.Number.Uint64()
+		return nil
+	})
+	return blockN, err
+}
@@ -1,8 +1,11 @@
 package idenpubonchain
 
 import (
+	"context"
 	"fmt"
+	"strings"
 
+	"github.com/ethereum/go-ethereum/accounts/abi"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
@@ -15,6 +18,13 @@ import (
 	"github.com/iden3/go-iden3-crypto/babyjub"
 )
 
+// Purposes of the transactions sent by IdenPubOnChain, recorded in the
+// eth.TxMeta.
+const (
+	TxPurposeSetState  = "setState"
+	TxPurposeInitState = "initState"
+)
+
 // IdenPubOnChainer is an interface that gives access to the IdenStates Smart Contract.
 type IdenPubOnChainer interface {
 	GetState(id *core.ID) (*proof.IdenStateData, error)
@@ -25,9 +35,83 @@ type IdenPubOnChainer interface {
 	// VerifyProofClaim(pc *proof.ProofClaim) (bool, error)
 }
 
+// StateGasEstimator is an interface to simulate the calls that update the
+// identity state in the IdenStates Smart Contract without sending them,
+// satisfied by IdenPubOnChain.
+type StateGasEstimator interface {
+	EstimateSetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error)
+	EstimateInitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error)
+}
+
+// IdenPubOnChainerCtx is an IdenPubOnChainer whose calls take a context that
+// cancels the requests to the Ethereum node, satisfied by IdenPubOnChain.
+type IdenPubOnChainerCtx interface {
+	IdenPubOnChainer
+	GetStateCtx(ctx context.Context, id *core.ID) (*proof.IdenStateData, error)
+	GetStateByBlockCtx(ctx context.Context, id *core.ID, blockN uint64) (*proof.IdenStateData, error)
+	GetStateByTimeCtx(ctx context.Context, id *core.ID, blockTimestamp int64) (*proof.IdenStateData, error)
+	SetStateCtx(ctx context.Context, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error)
+	InitStateCtx(ctx context.Context, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error)
+}
+
+// IdenPubOnChainerOpts is an IdenPubOnChainerCtx whose calls that update the
+// identity state take the parameters of the sent transaction, satisfied by
+// IdenPubOnChain.  A nil opts takes the defaults of eth.Client2.
+type IdenPubOnChainerOpts interface {
+	IdenPubOnChainerCtx
+	SetStateOpts(ctx context.Context, opts *eth.CallOpts, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error)
+	InitStateOpts(ctx context.Context, opts *eth.CallOpts, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error)
+}
+
+// StateGasEstimatorCtx is a StateGasEstimator whose calls take a context,
+// satisfied by IdenPubOnChain.
+type StateGasEstimatorCtx interface {
+	StateGasEstimator
+	EstimateSetStateCtx(ctx context.Context, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error)
+	EstimateInitStateCtx(ctx context.Context, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error)
+}
+
+// GetStateCtx calls ip.GetStateCtx if ip is an IdenPubOnChainerCtx, or
+// ip.GetState if ctx is not done otherwise.
+func GetStateCtx(ctx context.Context, ip IdenPubOnChainer, id *core.ID) (*proof.IdenStateData, error) {
+	if ipCtx, ok := ip.(IdenPubOnChainerCtx); ok {
+		return ipCtx.GetStateCtx(ctx, id)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ip.GetState(id)
+}
+
+// SetStateCtx calls ip.SetStateCtx if ip is an IdenPubOnChainerCtx, or
+// ip.SetState if ctx is not done otherwise.
+func SetStateCtx(ctx context.Context, ip IdenPubOnChainer, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if ipCtx, ok := ip.(IdenPubOnChainerCtx); ok {
+		return ipCtx.SetStateCtx(ctx, id, newState, kOpProof, stateTransitionProof, signature)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ip.SetState(id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// InitStateCtx calls ip.InitStateCtx if ip is an IdenPubOnChainerCtx, or
+// ip.InitState if ctx is not done otherwise.
+func InitStateCtx(ctx context.Context, ip IdenPubOnChainer, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if ipCtx, ok := ip.(IdenPubOnChainerCtx); ok {
+		return ipCtx.InitStateCtx(ctx, id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ip.InitState(id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
 // ContractAddresses are the list of Smart Contract addresses used for the on chain identity state data.
 type ContractAddresses struct {
 	IdenStates common.Address
+	// PublicDataURLRegistry is optional, see PublicDataURLRegistry.
+	PublicDataURLRegistry common.Address
 }
 
 // IdenPubOnChain is the regular implementation of IdenPubOnChain
@@ -47,6 +131,11 @@ func New(client *eth.Client2, addresses ContractAddresses) *IdenPubOnChain {
 // GetState returns the Identity State Data of the given ID from the IdenStates Smart Contract.
 // If no result is found, the returned IdenStateData is all zeroes.
 func (ip *IdenPubOnChain) GetState(id *core.ID) (*proof.IdenStateData, error) {
+	return ip.GetStateCtx(context.Background(), id)
+}
+
+// GetStateCtx is GetState with a context that cancels the request to the node.
+func (ip *IdenPubOnChain) GetStateCtx(ctx context.Context, id *core.ID) (*proof.IdenStateData, error) {
 	var idenState [32]byte
 	var blockN uint64
 	var blockTS uint64
@@ -55,7 +144,7 @@ func (ip *IdenPubOnChain) GetState(id *core.ID) (*proof.IdenStateData, error) {
 		if err != nil {
 			return err
 		}
-		blockN, blockTS, idenState, err = idenStates.GetStateDataById(nil, *id)
+		blockN, blockTS, idenState, err = idenStates.GetStateDataById(&bind.CallOpts{Context: ctx}, *id)
 		return err
 	})
 	return &proof.IdenStateData{
@@ -70,6 +159,12 @@ func (ip *IdenPubOnChain) GetState(id *core.ID) (*proof.IdenStateData, error) {
 // a resut is found, BlockN <= queryBlockN.
 // If no result is found, the returned IdenStateData is all zeroes.
 func (ip *IdenPubOnChain) GetStateByBlock(id *core.ID, queryBlockN uint64) (*proof.IdenStateData, error) {
+	return ip.GetStateByBlockCtx(context.Background(), id, queryBlockN)
+}
+
+// GetStateByBlockCtx is GetStateByBlock with a context that cancels the request to the
+// node.
+func (ip *IdenPubOnChain) GetStateByBlockCtx(ctx context.Context, id *core.ID, queryBlockN uint64) (*proof.IdenStateData, error) {
 	var idenState [32]byte
 	var blockN uint64
 	var blockTS uint64
@@ -78,7 +173,7 @@ func (ip *IdenPubOnChain) GetStateByBlock(id *core.ID, queryBlockN uint64) (*pro
 		if err != nil {
 			return err
 		}
-		blockN, blockTS, idenState, err = idenStates.GetStateDataByBlock(nil, *id, queryBlockN)
+		blockN, blockTS, idenState, err = idenStates.GetStateDataByBlock(&bind.CallOpts{Context: ctx}, *id, queryBlockN)
 		return err
 	})
 	return &proof.IdenStateData{
@@ -93,6 +188,12 @@ func (ip *IdenPubOnChain) GetStateByBlock(id *core.ID, queryBlockN uint64) (*pro
 // is found, BlockN <= queryBlockN.
 // If no result is found, the returned IdenStateData is all zeroes.
 func (ip *IdenPubOnChain) GetStateByTime(id *core.ID, queryBlockTs int64) (*proof.IdenStateData, error) {
+	return ip.GetStateByTimeCtx(context.Background(), id, queryBlockTs)
+}
+
+// GetStateByTimeCtx is GetStateByTime with a context that cancels the request to the
+// node.
+func (ip *IdenPubOnChain) GetStateByTimeCtx(ctx context.Context, id *core.ID, queryBlockTs int64) (*proof.IdenStateData, error) {
 	var idenState [32]byte
 	var blockN uint64
 	var blockTS uint64
@@ -101,7 +202,7 @@ func (ip *IdenPubOnChain) GetStateByTime(id *core.ID, queryBlockTs int64) (*proo
 		if err != nil {
 			return err
 		}
-		blockN, blockTS, idenState, err = idenStates.GetStateDataByTime(nil, *id, uint64(queryBlockTs))
+		blockN, blockTS, idenState, err = idenStates.GetStateDataByTime(&bind.CallOpts{Context: ctx}, *id, uint64(queryBlockTs))
 		return err
 	})
 	return &proof.IdenStateData{
@@ -119,8 +220,20 @@ func splitSignature(signature *babyjub.SignatureComp) (sigR8 [32]byte, sigS [32]
 }
 
 // SetState updates the Identity State of the given ID in the IdenStates Smart Contract.
+// If the call reverts, the returned error wraps an eth.ContractRevertError.
 func (ip *IdenPubOnChain) SetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
-	if tx, err := ip.client.CallAuth(
+	return ip.SetStateCtx(context.Background(), id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// SetStateCtx is SetState with a context that cancels the requests to the node.
+func (ip *IdenPubOnChain) SetStateCtx(ctx context.Context, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return ip.SetStateOpts(ctx, nil, id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// SetStateOpts is SetStateCtx sending the transaction with the parameters of
+// opts, which may be nil.
+func (ip *IdenPubOnChain) SetStateOpts(ctx context.Context, opts *eth.CallOpts, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if tx, err := ip.client.CallAuthMetaCtx(ctx, eth.TxMeta{Purpose: TxPurposeSetState, Identity: id.String()},
 		func(c *ethclient.Client, auth *bind.TransactOpts) (*types.Transaction, error) {
 			idenStates, err := contracts.NewState(ip.addresses.IdenStates, c)
 			if err != nil {
@@ -128,7 +241,7 @@ func (ip *IdenPubOnChain) SetState(id *core.ID, newState *merkletree.Hash, kOpPr
 			}
 			sigR8, sigS := splitSignature(signature)
 			return idenStates.SetState(auth, *newState, *id, kOpProof, stateTransitionProof, sigR8, sigS)
-		},
+		}, opts,
 	); err != nil {
 		return nil, fmt.Errorf("Failed setting identity state in the Smart Contract (setState): %w", err)
 	} else {
@@ -137,8 +250,20 @@ func (ip *IdenPubOnChain) SetState(id *core.ID, newState *merkletree.Hash, kOpPr
 }
 
 // InitState initializes the first Identity State of the given ID in the IdenStates Smart Contract.
+// If the call reverts, the returned error wraps an eth.ContractRevertError.
 func (ip *IdenPubOnChain) InitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
-	if tx, err := ip.client.CallAuth(
+	return ip.InitStateCtx(context.Background(), id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// InitStateCtx is InitState with a context that cancels the requests to the node.
+func (ip *IdenPubOnChain) InitStateCtx(ctx context.Context, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return ip.InitStateOpts(ctx, nil, id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// InitStateOpts is InitStateCtx sending the transaction with the parameters
+// of opts, which may be nil.
+func (ip *IdenPubOnChain) InitStateOpts(ctx context.Context, opts *eth.CallOpts, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if tx, err := ip.client.CallAuthMetaCtx(ctx, eth.TxMeta{Purpose: TxPurposeInitState, Identity: id.String()},
 		func(c *ethclient.Client, auth *bind.TransactOpts) (*types.Transaction, error) {
 			idenStates, err := contracts.NewState(ip.addresses.IdenStates, c)
 			if err != nil {
@@ -146,10 +271,62 @@ func (ip *IdenPubOnChain) InitState(id *core.ID, genesisState *merkletree.Hash,
 			}
 			sigR8, sigS := splitSignature(signature)
 			return idenStates.InitState(auth, *newState, *genesisState, *id, kOpProof, stateTransitionProof, sigR8, sigS)
-		},
+		}, opts,
 	); err != nil {
 		return nil, fmt.Errorf("Failed initalizating identity state in the Smart Contract (initState): %w", err)
 	} else {
 		return tx, nil
 	}
 }
+
+// estimate simulates the call of the IdenStates Smart Contract method with
+// args and returns the gas it requires.
+func (ip *IdenPubOnChain) estimate(ctx context.Context, method string, args ...interface{}) (uint64, error) {
+	parsed, err := abi.JSON(strings.NewReader(contracts.StateABI))
+	if err != nil {
+		return 0, err
+	}
+	calldata, err := parsed.Pack(method, args...)
+	if err != nil {
+		return 0, err
+	}
+	return ip.client.EstimateGasCtx(ctx, ip.addresses.IdenStates, calldata)
+}
+
+// EstimateSetState simulates the update of the Identity State of the given ID
+// in the IdenStates Smart Contract (see SetState) and returns the gas it
+// requires.  If the call would revert, an error wrapping an
+// eth.ContractRevertError with the revert reason is returned.
+func (ip *IdenPubOnChain) EstimateSetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error) {
+	return ip.EstimateSetStateCtx(context.Background(), id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// EstimateSetStateCtx is EstimateSetState with a context that cancels the requests to
+// the node.
+func (ip *IdenPubOnChain) EstimateSetStateCtx(ctx context.Context, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error) {
+	sigR8, sigS := splitSignature(signature)
+	gas, err := ip.estimate(ctx, "setState", [32]byte(*newState), [31]byte(*id), kOpProof, stateTransitionProof, sigR8, sigS)
+	if err != nil {
+		return 0, fmt.Errorf("Failed estimating the identity state update in the Smart Contract (setState): %w", err)
+	}
+	return gas, nil
+}
+
+// EstimateInitState simulates the initialization of the first Identity State
+// of the given ID in the IdenStates Smart Contract (see InitState) and
+// returns the gas it requires.  If the call would revert, an error wrapping
+// an eth.ContractRevertError with the revert reason is returned.
+func (ip *IdenPubOnChain) EstimateInitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error) {
+	return ip.EstimateInitStateCtx(context.Background(), id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// EstimateInitStateCtx is EstimateInitState with a context that cancels the requests to
+// the node.
+func (ip *IdenPubOnChain) EstimateInitStateCtx(ctx context.Context, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error) {
+	sigR8, sigS := splitSignature(signature)
+	gas, err := ip.estimate(ctx, "initState", [32]byte(*newState), [32]byte(*genesisState), [31]byte(*id), kOpProof, stateTransitionProof, sigR8, sigS)
+	if err != nil {
+		return 0, fmt.Errorf("Failed estimating the identity state initialization in the Smart Contract (initState): %w", err)
+	}
+	return gas, nil
+}
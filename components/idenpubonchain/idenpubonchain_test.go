@@ -0,0 +1,76 @@
+package idenpubonchain
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/iden3/go-iden3-core/eth/rpcfixture"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newReplayed creates an IdenPubOnChain whose client replays the fixture
+// testdata/<name>.json, recorded with the account of the key
+// da7079f0...62d8, which is imported in a keystore at dir.
+func newReplayed(t *testing.T, dir, name string) (*IdenPubOnChain, *eth.Client2, *rpcfixture.Replayer) {
+	fixture, err := rpcfixture.Load("testdata/" + name + ".json")
+	require.Nil(t, err)
+	replayer := rpcfixture.NewReplayer(fixture)
+	client, err := rpcfixture.Dial("http://fixture", replayer)
+	require.Nil(t, err)
+
+	ks := ethkeystore.NewKeyStore(dir, ethkeystore.LightScryptN, ethkeystore.LightScryptP)
+	key, err := ethcrypto.HexToECDSA("da7079f082a1ced80c5dee3bf00752fd67f75321a637e5d5073ce1489af062d8")
+	require.Nil(t, err)
+	account, err := ks.ImportECDSA(key, "pass")
+	require.Nil(t, err)
+	require.Nil(t, ks.Unlock(account, "pass"))
+
+	c := eth.NewClient2(client, &account, ks)
+	ip := New(c, ContractAddresses{IdenStates: common.HexToAddress("0x52dc5ef21b8bbd4a5bfce9a7f29db2b77a7fb2e8")})
+	return ip, c, replayer
+}
+
+// TestIdenPubOnChainSetStateReorg replays a state update whose receipt is
+// pending the first time it's requested, and whose block is later
+// reorganized.
+func TestIdenPubOnChainSetStateReorg(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ip, c, replayer := newReplayed(t, dir, "setstate_reorg")
+	id := core.ID{1}
+
+	state, err := ip.GetState(&id)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(10), state.BlockN)
+	assert.Equal(t, &merkletree.Hash{1}, state.IdenState)
+
+	tx, err := ip.SetState(&id, &merkletree.Hash{2}, []byte{}, []byte{}, &babyjub.SignatureComp{})
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), tx.Nonce())
+	receipt, err := c.WaitReceipt(tx)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(11), receipt.BlockNumber.Uint64())
+
+	state, err = ip.GetState(&id)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(11), state.BlockN)
+	assert.Equal(t, &merkletree.Hash{2}, state.IdenState)
+
+	// Block 11 is reorganized.
+	state, err = ip.GetState(&id)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(10), state.BlockN)
+	assert.Equal(t, &merkletree.Hash{1}, state.IdenState)
+
+	assert.Equal(t, 0, len(replayer.Pending()))
+}
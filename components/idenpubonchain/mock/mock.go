@@ -32,6 +32,11 @@ func (m *IdenPubOnChainMock) GetStateByTime(id *core.ID, blockTimeStamp int64) (
 	return args.Get(0).(*proof.IdenStateData), args.Error(1)
 }
 
+func (m *IdenPubOnChainMock) BlockNumber() (uint64, error) {
+	args := m.Called()
+	return args.Get(0).(uint64), args.Error(1)
+}
+
 func (m *IdenPubOnChainMock) InitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
 	args := m.Called(id, genesisState, newState, kOpProof, stateTransitionProof, signature)
 	return args.Get(0).(*types.Transaction), args.Error(1)
@@ -51,3 +56,13 @@ func (m *IdenPubOnChainMock) SetState(id *core.ID, newState *merkletree.Hash, kO
 // 	args := m.Called()
 // 	return args.Get(0).(*eth.Client2)
 // }
+
+func (m *IdenPubOnChainMock) GetPublicDataURL(id *core.ID) (string, error) {
+	args := m.Called(id)
+	return args.String(0), args.Error(1)
+}
+
+func (m *IdenPubOnChainMock) SetPublicDataURL(id *core.ID, url string, kOpProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	args := m.Called(id, url, kOpProof, signature)
+	return args.Get(0).(*types.Transaction), args.Error(1)
+}
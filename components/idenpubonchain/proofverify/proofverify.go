@@ -0,0 +1,369 @@
+// Package proofverify implements a light-client verification mode of the
+// identity states on chain: instead of trusting the responses of the node to
+// the calls of the IdenStates Smart Contract, the identity states are read
+// from the contract storage with eth_getProof, and the storage proofs are
+// verified against the state root of a block header whose hash is provided
+// by a trusted HeaderSource, like a light client following the chain.
+//
+// The identity states are read from the storage layout of the State.sol
+// contract deployed by eth/contracts: a mapping at Config.IdentitiesSlot from
+// the id (bytes31) to the array of its states, where each state takes two
+// slots: the block number and timestamp (uint64 each, packed in the first
+// slot) and the identity state (bytes32).
+package proofverify
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/ethdb/memorydb"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/ethereum/go-ethereum/trie"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+var (
+	// ErrHeaderMismatch is used when the header returned by the node
+	// doesn't have the hash given by the HeaderSource.
+	ErrHeaderMismatch = errors.New("the block header of the node doesn't match the trusted hash")
+	// ErrInvalidProof is used when a proof returned by the node doesn't
+	// verify against the trusted header.
+	ErrInvalidProof = errors.New("invalid proof")
+	// ErrContractNotFound is used when the account of the IdenStates
+	// Smart Contract doesn't exist at the trusted header.
+	ErrContractNotFound = errors.New("the IdenStates Smart Contract doesn't exist at the trusted block")
+	// ErrReadOnly is used when an identity state is updated through an
+	// IdenPubOnChain without an underlying IdenPubOnChainer.
+	ErrReadOnly = errors.New("the verified IdenPubOnChain is read only")
+)
+
+// RPC is an interface to make JSON-RPC calls to an Ethereum node, satisfied by
+// rpc.Client.
+type RPC interface {
+	CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error
+}
+
+// HeaderSource is the trusted source of the block hashes, like a light
+// client.
+type HeaderSource interface {
+	// Head returns the number and the hash of the last block.
+	Head(ctx context.Context) (uint64, common.Hash, error)
+}
+
+// IdentitiesSlotDefault is the storage slot of the mapping of the identity
+// states in the State contract of eth/contracts (contracts.StateBin).
+const IdentitiesSlotDefault = 0
+
+// Config is the configuration of an IdenPubOnChain.
+type Config struct {
+	// IdenStates is the address of the IdenStates Smart Contract.
+	IdenStates common.Address
+	// IdentitiesSlot is the storage slot of the mapping of the identity
+	// states in the IdenStates Smart Contract.  The zero value is
+	// IdentitiesSlotDefault, so it only needs to be set for a contract
+	// with another storage layout.
+	IdentitiesSlot uint64
+}
+
+// IdenPubOnChain is an idenpubonchain.IdenPubOnChainer whose identity states
+// are verified against the trusted block headers.  The updates of the
+// identity states are sent through an underlying IdenPubOnChainer.
+type IdenPubOnChain struct {
+	cfg            Config
+	rpc            RPC
+	headers        HeaderSource
+	idenPubOnChain idenpubonchain.IdenPubOnChainer
+}
+
+var _ idenpubonchain.IdenPubOnChainerCtx = (*IdenPubOnChain)(nil)
+
+// New creates an IdenPubOnChain that reads the storage of the IdenStates
+// Smart Contract from the node through rpc and verifies it against the
+// headers.  idenPubOnChain, used to update the identity states, can be nil.
+func New(cfg Config, rpc RPC, headers HeaderSource, idenPubOnChain idenpubonchain.IdenPubOnChainer) *IdenPubOnChain {
+	return &IdenPubOnChain{cfg: cfg, rpc: rpc, headers: headers, idenPubOnChain: idenPubOnChain}
+}
+
+// accountResult is the result of eth_getProof (EIP-1186).
+type accountResult struct {
+	AccountProof []hexutil.Bytes `json:"accountProof"`
+	StorageProof []storageResult `json:"storageProof"`
+}
+
+// storageResult is a storage proof of eth_getProof.  The value returned by
+// the node is not used, as it's read from the proof.
+type storageResult struct {
+	Proof []hexutil.Bytes `json:"proof"`
+}
+
+// verifyProof returns the value of key in the trie with root, proven by the
+// nodes of proof, or nil if it's not in the trie.
+func verifyProof(root common.Hash, key []byte, nodes []hexutil.Bytes) ([]byte, error) {
+	db := memorydb.New()
+	for _, node := range nodes {
+		if err := db.Put(crypto.Keccak256(node), node); err != nil {
+			return nil, err
+		}
+	}
+	value, _, err := trie.VerifyProof(root, crypto.Keccak256(key), db)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	return value, nil
+}
+
+// view is the storage of the IdenStates Smart Contract at a trusted block.
+type view struct {
+	ip          *IdenPubOnChain
+	blockN      string
+	storageRoot common.Hash
+}
+
+// view verifies the header of the last block of the HeaderSource and the
+// account of the IdenStates Smart Contract in it.
+func (ip *IdenPubOnChain) view(ctx context.Context) (*view, error) {
+	blockN, hash, err := ip.headers.Head(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v := &view{ip: ip, blockN: hexutil.EncodeUint64(blockN)}
+	var header types.Header
+	if err := ip.rpc.CallContext(ctx, &header, "eth_getBlockByNumber", v.blockN, false); err != nil {
+		return nil, err
+	}
+	if header.Hash() != hash {
+		return nil, ErrHeaderMismatch
+	}
+	var res accountResult
+	if err := ip.rpc.CallContext(ctx, &res, "eth_getProof", ip.cfg.IdenStates, []string{}, v.blockN); err != nil {
+		return nil, err
+	}
+	accountRLP, err := verifyProof(header.Root, ip.cfg.IdenStates[:], res.AccountProof)
+	if err != nil {
+		return nil, err
+	}
+	if accountRLP == nil {
+		return nil, ErrContractNotFound
+	}
+	var account struct {
+		Nonce    uint64
+		Balance  *big.Int
+		Root     common.Hash
+		CodeHash []byte
+	}
+	if err := rlp.DecodeBytes(accountRLP, &account); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+	}
+	v.storageRoot = account.Root
+	return v, nil
+}
+
+// storage returns the verified values of the storage slots.
+func (v *view) storage(ctx context.Context, slots ...common.Hash) ([]common.Hash, error) {
+	keys := make([]string, len(slots))
+	for i, slot := range slots {
+		keys[i] = slot.Hex()
+	}
+	var res accountResult
+	if err := v.ip.rpc.CallContext(ctx, &res, "eth_getProof", v.ip.cfg.IdenStates, keys, v.blockN); err != nil {
+		return nil, err
+	}
+	if len(res.StorageProof) != len(slots) {
+		return nil, fmt.Errorf("%w: expected %v storage proofs, got %v", ErrInvalidProof, len(slots), len(res.StorageProof))
+	}
+	values := make([]common.Hash, len(slots))
+	for i, slot := range slots {
+		valueRLP, err := verifyProof(v.storageRoot, slot[:], res.StorageProof[i].Proof)
+		if err != nil {
+			return nil, err
+		}
+		if valueRLP == nil {
+			continue
+		}
+		var value []byte
+		if err := rlp.DecodeBytes(valueRLP, &value); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidProof, err)
+		}
+		values[i] = common.BytesToHash(value)
+	}
+	return values, nil
+}
+
+// statesSlot returns the slot of the length of the array of states of id.
+func (v *view) statesSlot(id *core.ID) common.Hash {
+	var key, slot common.Hash
+	copy(key[:], id[:])
+	binary.BigEndian.PutUint64(slot[24:], v.ip.cfg.IdentitiesSlot)
+	return crypto.Keccak256Hash(key[:], slot[:])
+}
+
+// stateSlot returns the first slot of the state i of the array of states at
+// statesSlot.
+func stateSlot(statesSlot common.Hash, i uint64) common.Hash {
+	base := new(big.Int).SetBytes(crypto.Keccak256(statesSlot[:]))
+	base.Add(base, new(big.Int).SetUint64(2*i))
+	return common.BigToHash(base)
+}
+
+// length returns the number of states of the array at statesSlot.
+func (v *view) length(ctx context.Context, statesSlot common.Hash) (uint64, error) {
+	values, err := v.storage(ctx, statesSlot)
+	if err != nil {
+		return 0, err
+	}
+	return new(big.Int).SetBytes(values[0][:]).Uint64(), nil
+}
+
+// blockNTs returns the block number and timestamp of the state i.
+func (v *view) blockNTs(ctx context.Context, statesSlot common.Hash, i uint64) (uint64, uint64, error) {
+	values, err := v.storage(ctx, stateSlot(statesSlot, i))
+	if err != nil {
+		return 0, 0, err
+	}
+	// The first member of the struct is in the lowest order bytes.
+	return binary.BigEndian.Uint64(values[0][24:]), binary.BigEndian.Uint64(values[0][16:24]), nil
+}
+
+// state returns the state i.
+func (v *view) state(ctx context.Context, statesSlot common.Hash, i uint64) (*proof.IdenStateData, error) {
+	slot := stateSlot(statesSlot, i)
+	next := common.BigToHash(new(big.Int).Add(slot.Big(), big.NewInt(1)))
+	values, err := v.storage(ctx, slot, next)
+	if err != nil {
+		return nil, err
+	}
+	idenState := merkletree.Hash(values[1])
+	return &proof.IdenStateData{
+		BlockN:    binary.BigEndian.Uint64(values[0][24:]),
+		BlockTs:   int64(binary.BigEndian.Uint64(values[0][16:24])),
+		IdenState: &idenState,
+	}, nil
+}
+
+// search returns the last state of id whose block number (or timestamp, if
+// byTs) is not greater than q, or the zero state if there's none, like the
+// IdenStates Smart Contract.
+func (ip *IdenPubOnChain) search(ctx context.Context, id *core.ID, q uint64, byTs bool) (*proof.IdenStateData, error) {
+	v, err := ip.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statesSlot := v.statesSlot(id)
+	n, err := v.length(ctx, statesSlot)
+	if err != nil {
+		return nil, err
+	}
+	// Binary search of the first state after q in [lo, hi).
+	lo, hi := uint64(0), n
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		blockN, blockTs, err := v.blockNTs(ctx, statesSlot, mid)
+		if err != nil {
+			return nil, err
+		}
+		key := blockN
+		if byTs {
+			key = blockTs
+		}
+		if key <= q {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	if lo == 0 {
+		return &proof.IdenStateData{IdenState: &merkletree.HashZero}, nil
+	}
+	return v.state(ctx, statesSlot, lo-1)
+}
+
+// GetState returns the last Identity State Data of id, verified against the
+// last trusted header.  If there's none, the returned IdenStateData is all
+// zeroes.
+func (ip *IdenPubOnChain) GetState(id *core.ID) (*proof.IdenStateData, error) {
+	return ip.GetStateCtx(context.Background(), id)
+}
+
+// GetStateCtx is GetState with a context that cancels the requests.
+func (ip *IdenPubOnChain) GetStateCtx(ctx context.Context, id *core.ID) (*proof.IdenStateData, error) {
+	v, err := ip.view(ctx)
+	if err != nil {
+		return nil, err
+	}
+	statesSlot := v.statesSlot(id)
+	n, err := v.length(ctx, statesSlot)
+	if err != nil {
+		return nil, err
+	}
+	if n == 0 {
+		return &proof.IdenStateData{IdenState: &merkletree.HashZero}, nil
+	}
+	return v.state(ctx, statesSlot, n-1)
+}
+
+// GetStateByBlock returns the Identity State Data of id closest (equal or
+// older) to queryBlockN, verified against the last trusted header.  The
+// states are binary searched, with a request per visited state.
+func (ip *IdenPubOnChain) GetStateByBlock(id *core.ID, queryBlockN uint64) (*proof.IdenStateData, error) {
+	return ip.GetStateByBlockCtx(context.Background(), id, queryBlockN)
+}
+
+// GetStateByBlockCtx is GetStateByBlock with a context that cancels the
+// requests.
+func (ip *IdenPubOnChain) GetStateByBlockCtx(ctx context.Context, id *core.ID, queryBlockN uint64) (*proof.IdenStateData, error) {
+	return ip.search(ctx, id, queryBlockN, false)
+}
+
+// GetStateByTime returns the Identity State Data of id closest (equal or
+// older) to queryBlockTs, verified like GetStateByBlock.
+func (ip *IdenPubOnChain) GetStateByTime(id *core.ID, queryBlockTs int64) (*proof.IdenStateData, error) {
+	return ip.GetStateByTimeCtx(context.Background(), id, queryBlockTs)
+}
+
+// GetStateByTimeCtx is GetStateByTime with a context that cancels the
+// requests.
+func (ip *IdenPubOnChain) GetStateByTimeCtx(ctx context.Context, id *core.ID, queryBlockTs int64) (*proof.IdenStateData, error) {
+	if queryBlockTs < 0 {
+		return &proof.IdenStateData{IdenState: &merkletree.HashZero}, nil
+	}
+	return ip.search(ctx, id, uint64(queryBlockTs), true)
+}
+
+// SetState updates the Identity State of id through the underlying
+// IdenPubOnChainer.
+func (ip *IdenPubOnChain) SetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return ip.SetStateCtx(context.Background(), id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// SetStateCtx is SetState with a context that cancels the requests.
+func (ip *IdenPubOnChain) SetStateCtx(ctx context.Context, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if ip.idenPubOnChain == nil {
+		return nil, ErrReadOnly
+	}
+	return idenpubonchain.SetStateCtx(ctx, ip.idenPubOnChain, id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// InitState initializes the Identity State of id through the underlying
+// IdenPubOnChainer.
+func (ip *IdenPubOnChain) InitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return ip.InitStateCtx(context.Background(), id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// InitStateCtx is InitState with a context that cancels the requests.
+func (ip *IdenPubOnChain) InitStateCtx(ctx context.Context, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if ip.idenPubOnChain == nil {
+		return nil, ErrReadOnly
+	}
+	return idenpubonchain.InitStateCtx(ctx, ip.idenPubOnChain, id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
@@ -0,0 +1,198 @@
+package proofverify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	ethcore "github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/eth/contracts"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// node serves eth_getBlockByNumber and eth_getProof from the chain of a
+// simulated backend, building the proofs like the eth_getProof of geth.
+type node struct {
+	backend *backends.SimulatedBackend
+	// tamper modifies the storage proofs returned.
+	tamper bool
+}
+
+func (n *node) CallContext(ctx context.Context, result interface{}, method string, args ...interface{}) error {
+	bc := n.backend.Blockchain()
+	blockNArg := args[0]
+	if method == "eth_getProof" {
+		blockNArg = args[2]
+	}
+	blockN, err := hexutil.DecodeUint64(blockNArg.(string))
+	if err != nil {
+		return err
+	}
+	header := bc.GetHeaderByNumber(blockN)
+	if header == nil {
+		return fmt.Errorf("block %v not found", blockN)
+	}
+	var res interface{}
+	switch method {
+	case "eth_getBlockByNumber":
+		res = header
+	case "eth_getProof":
+		statedb, err := bc.StateAt(header.Root)
+		if err != nil {
+			return err
+		}
+		address := args[0].(common.Address)
+		accountProof, err := statedb.GetProof(address)
+		if err != nil {
+			return err
+		}
+		type storageResult struct {
+			Proof []hexutil.Bytes `json:"proof"`
+		}
+		accountRes := struct {
+			AccountProof []hexutil.Bytes `json:"accountProof"`
+			StorageProof []storageResult `json:"storageProof"`
+		}{StorageProof: []storageResult{}}
+		for _, node := range accountProof {
+			accountRes.AccountProof = append(accountRes.AccountProof, node)
+		}
+		for _, key := range args[1].([]string) {
+			proof, err := statedb.GetStorageProof(address, common.HexToHash(key))
+			if err != nil {
+				return err
+			}
+			storageRes := storageResult{Proof: []hexutil.Bytes{}}
+			for _, node := range proof {
+				if n.tamper {
+					node = append([]byte{}, node...)
+					node[len(node)-1] ^= 1
+				}
+				storageRes.Proof = append(storageRes.Proof, node)
+			}
+			accountRes.StorageProof = append(accountRes.StorageProof, storageRes)
+		}
+		res = accountRes
+	default:
+		return fmt.Errorf("unsupported method %v", method)
+	}
+	b, err := json.Marshal(res)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, result)
+}
+
+// headers trusts the current block of the simulated backend, or hash if
+// it's set.
+type headers struct {
+	backend *backends.SimulatedBackend
+	hash    *common.Hash
+}
+
+func (h *headers) Head(ctx context.Context) (uint64, common.Hash, error) {
+	block := h.backend.Blockchain().CurrentBlock()
+	if h.hash != nil {
+		return block.NumberU64(), *h.hash, nil
+	}
+	return block.NumberU64(), block.Hash(), nil
+}
+
+func TestIdenPubOnChain(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	auth := bind.NewKeyedTransactor(key)
+	backend := backends.NewSimulatedBackend(ethcore.GenesisAlloc{
+		auth.From: {Balance: new(big.Int).Lsh(big.NewInt(1), 64)},
+	}, 8000000)
+	address, _, state, err := contracts.DeployState(auth, backend)
+	require.Nil(t, err)
+	backend.Commit()
+
+	// Block 2: init the state of id1.  Blocks 3 and 4: update it.
+	id1, id2 := core.ID{1}, core.ID{2}
+	_, err = state.InitState(auth, [32]byte{1}, [32]byte{}, id1, nil, nil, [32]byte{}, [32]byte{})
+	require.Nil(t, err)
+	backend.Commit()
+	for i := byte(2); i <= 3; i++ {
+		_, err = state.SetState(auth, [32]byte{i}, id1, nil, nil, [32]byte{}, [32]byte{})
+		require.Nil(t, err)
+		backend.Commit()
+	}
+	lastBlockN := backend.Blockchain().CurrentBlock().NumberU64()
+
+	n := &node{backend: backend}
+	ip := New(Config{IdenStates: address}, n, &headers{backend: backend}, nil)
+
+	// The states read from the storage match the ones of the calls to the
+	// contract.
+	blockN, blockTs, idenState, err := state.GetStateDataById(nil, id1)
+	require.Nil(t, err)
+	stateData, err := ip.GetState(&id1)
+	require.Nil(t, err)
+	assert.Equal(t, blockN, stateData.BlockN)
+	assert.Equal(t, int64(blockTs), stateData.BlockTs)
+	assert.Equal(t, merkletree.Hash(idenState), *stateData.IdenState)
+	assert.Equal(t, &merkletree.Hash{3}, stateData.IdenState)
+
+	stateData, err = ip.GetState(&id2)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), stateData.BlockN)
+	assert.Equal(t, &merkletree.HashZero, stateData.IdenState)
+
+	// The contract reverts the queries before the first state and at the
+	// current block.
+	for q := uint64(0); q <= lastBlockN; q++ {
+		queryTs := backend.Blockchain().GetHeaderByNumber(q).Time
+		stateData, err := ip.GetStateByBlock(&id1, q)
+		require.Nil(t, err)
+		stateDataByTime, err := ip.GetStateByTime(&id1, int64(queryTs))
+		require.Nil(t, err)
+		assert.Equal(t, stateData, stateDataByTime, "block %v", q)
+		switch {
+		case q < 2:
+			assert.Equal(t, &merkletree.HashZero, stateData.IdenState, "block %v", q)
+		case q == lastBlockN:
+			assert.Equal(t, &merkletree.Hash{3}, stateData.IdenState, "block %v", q)
+		default:
+			blockN, blockTs, idenState, err := state.GetStateDataByBlock(nil, id1, q)
+			require.Nil(t, err)
+			assert.Equal(t, blockN, stateData.BlockN, "block %v", q)
+			assert.Equal(t, int64(blockTs), stateData.BlockTs, "block %v", q)
+			assert.Equal(t, merkletree.Hash(idenState), *stateData.IdenState, "block %v", q)
+			_, _, idenState, err = state.GetStateDataByTime(nil, id1, queryTs)
+			require.Nil(t, err)
+			assert.Equal(t, merkletree.Hash(idenState), *stateData.IdenState, "time %v", queryTs)
+		}
+	}
+
+	_, err = ip.SetState(&id1, &merkletree.Hash{4}, nil, nil, nil)
+	assert.Equal(t, ErrReadOnly, err)
+
+	// A header that is not the trusted one.
+	ip = New(Config{IdenStates: address}, n, &headers{backend: backend, hash: &common.Hash{1}}, nil)
+	_, err = ip.GetState(&id1)
+	assert.Equal(t, ErrHeaderMismatch, err)
+
+	// Tampered storage proofs.
+	n.tamper = true
+	ip = New(Config{IdenStates: address}, n, &headers{backend: backend}, nil)
+	_, err = ip.GetState(&id1)
+	assert.True(t, errors.Is(err, ErrInvalidProof))
+	n.tamper = false
+
+	// An account that is not the IdenStates Smart Contract.
+	ip = New(Config{IdenStates: common.Address{1}}, n, &headers{backend: backend}, nil)
+	_, err = ip.GetState(&id1)
+	assert.Equal(t, ErrContractNotFound, err)
+}
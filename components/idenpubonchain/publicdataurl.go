@@ -0,0 +1,109 @@
+package idenpubonchain
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// TxPurposeSetPublicDataURL is the purpose of the transactions that register
+// the off chain public data URL of an identity.
+const TxPurposeSetPublicDataURL = "setPublicDataURL"
+
+var (
+	ErrPublicDataURLRegistryNotSet = fmt.Errorf("the PublicDataURLRegistry contract address is not set")
+)
+
+// PublicDataURLRegistryABI is the ABI of the registry of the off chain public
+// data URLs of the identities:
+//
+//	function setPublicDataURL(bytes31 id, string url, bytes kOpProof, bytes32 sigR8, bytes32 sigS)
+//	function getPublicDataURL(bytes31 id) view returns (string)
+//
+// setPublicDataURL checks, like the IdenStates setState, that the signature
+// of the url (see issuer.SigPrefixSetPublicDataURL) is done with the kOp of
+// the identity.
+const PublicDataURLRegistryABI = `[` +
+	`{"inputs":[{"internalType":"bytes31","name":"id","type":"bytes31"},{"internalType":"string","name":"url","type":"string"},{"internalType":"bytes","name":"kOpProof","type":"bytes"},{"internalType":"bytes32","name":"sigR8","type":"bytes32"},{"internalType":"bytes32","name":"sigS","type":"bytes32"}],"name":"setPublicDataURL","outputs":[],"stateMutability":"nonpayable","type":"function"},` +
+	`{"inputs":[{"internalType":"bytes31","name":"id","type":"bytes31"}],"name":"getPublicDataURL","outputs":[{"internalType":"string","name":"","type":"string"}],"stateMutability":"view","type":"function"}` +
+	`]`
+
+// PublicDataURLRegistry is an optional interface of an IdenPubOnChainer that
+// gives access to the on chain registry of the URLs (or IPFS CIDs, as
+// ipfs://<cid>) where the identities publish their off chain public data, so
+// that a verifier can find it from the ID.
+type PublicDataURLRegistry interface {
+	// GetPublicDataURL returns the URL registered by the identity id, or
+	// "" if it hasn't registered any.
+	GetPublicDataURL(id *core.ID) (string, error)
+	// SetPublicDataURL registers the URL of the identity id, signed by its
+	// kOp.
+	SetPublicDataURL(id *core.ID, url string, kOpProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error)
+}
+
+// registry returns the binding of the PublicDataURLRegistry Smart Contract.
+func (ip *IdenPubOnChain) registry(c *ethclient.Client) (*bind.BoundContract, error) {
+	if ip.addresses.PublicDataURLRegistry == (common.Address{}) {
+		return nil, ErrPublicDataURLRegistryNotSet
+	}
+	parsed, err := abi.JSON(strings.NewReader(PublicDataURLRegistryABI))
+	if err != nil {
+		return nil, err
+	}
+	return bind.NewBoundContract(ip.addresses.PublicDataURLRegistry, parsed, c, c, c), nil
+}
+
+// GetPublicDataURL returns the off chain public data URL of the given ID from
+// the PublicDataURLRegistry Smart Contract, or "" if it's not registered.
+func (ip *IdenPubOnChain) GetPublicDataURL(id *core.ID) (string, error) {
+	return ip.GetPublicDataURLCtx(context.Background(), id)
+}
+
+// GetPublicDataURLCtx is GetPublicDataURL with a context that cancels the
+// request to the node.
+func (ip *IdenPubOnChain) GetPublicDataURLCtx(ctx context.Context, id *core.ID) (string, error) {
+	var url string
+	err := ip.client.Call(func(c *ethclient.Client) error {
+		registry, err := ip.registry(c)
+		if err != nil {
+			return err
+		}
+		return registry.Call(&bind.CallOpts{Context: ctx}, &url, "getPublicDataURL", [31]byte(*id))
+	})
+	return url, err
+}
+
+// SetPublicDataURL registers the off chain public data URL of the given ID in
+// the PublicDataURLRegistry Smart Contract.
+// If the call reverts, the returned error wraps an eth.ContractRevertError.
+func (ip *IdenPubOnChain) SetPublicDataURL(id *core.ID, url string, kOpProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return ip.SetPublicDataURLCtx(context.Background(), id, url, kOpProof, signature)
+}
+
+// SetPublicDataURLCtx is SetPublicDataURL with a context that cancels the
+// requests to the node.
+func (ip *IdenPubOnChain) SetPublicDataURLCtx(ctx context.Context, id *core.ID, url string, kOpProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if tx, err := ip.client.CallAuthMetaCtx(ctx, eth.TxMeta{Purpose: TxPurposeSetPublicDataURL, Identity: id.String()},
+		func(c *ethclient.Client, auth *bind.TransactOpts) (*types.Transaction, error) {
+			registry, err := ip.registry(c)
+			if err != nil {
+				return nil, err
+			}
+			sigR8, sigS := splitSignature(signature)
+			return registry.Transact(auth, "setPublicDataURL", [31]byte(*id), url, kOpProof, sigR8, sigS)
+		},
+	); err != nil {
+		return nil, fmt.Errorf("Failed setting the public data URL in the Smart Contract (setPublicDataURL): %w", err)
+	} else {
+		return tx, nil
+	}
+}
@@ -0,0 +1,152 @@
+package sim
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathStates is the path of the states endpoint, followed by the ID.
+const PathStates = "/sim/states/"
+
+var (
+	// ErrReadOnly is used when a Client is used to update a state.
+	ErrReadOnly = errors.New("the simulated backend client is read only")
+)
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// Handler returns an http.Handler that serves the states of b:
+//
+//	GET /sim/states/<id>               last state, like GetState
+//	GET /sim/states/<id>?block=<n>     like GetStateByBlock
+//	GET /sim/states/<id>?time=<unix>   like GetStateByTime
+//
+// The response is a JSON proof.IdenStateData.
+func Handler(b *Backend) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathStates, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		id, err := core.IDFromString(strings.TrimPrefix(req.URL.Path, PathStates))
+		if err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid id: "+err.Error())
+			return
+		}
+		var state *proof.IdenStateData
+		query := req.URL.Query()
+		switch {
+		case query.Get("block") != "":
+			blockN, errParse := strconv.ParseUint(query.Get("block"), 10, 64)
+			if errParse != nil {
+				httpjson.WriteError(w, http.StatusBadRequest, "invalid block: "+errParse.Error())
+				return
+			}
+			state, err = b.GetStateByBlock(&id, blockN)
+		case query.Get("time") != "":
+			blockTs, errParse := strconv.ParseInt(query.Get("time"), 10, 64)
+			if errParse != nil {
+				httpjson.WriteError(w, http.StatusBadRequest, "invalid time: "+errParse.Error())
+				return
+			}
+			state, err = b.GetStateByTime(&id, blockTs)
+		default:
+			state, err = b.GetState(&id)
+		}
+		if err != nil {
+			log.WithError(err).Error("GetState")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, state)
+	})
+	return mux
+}
+
+var _ idenpubonchain.IdenPubOnChainer = (*Client)(nil)
+
+// Client is a read only IdenPubOnChainer of the states of a Backend served by
+// Handler.
+type Client struct {
+	URL        string
+	httpClient *http.Client
+}
+
+// NewClient creates a Client of the Handler served at baseURL.  If httpClient
+// is nil, http.DefaultClient is used.
+func NewClient(baseURL string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{URL: strings.TrimSuffix(baseURL, "/"), httpClient: httpClient}
+}
+
+func (c *Client) get(id *core.ID, query url.Values) (*proof.IdenStateData, error) {
+	u := c.URL + PathStates + id.String()
+	if len(query) != 0 {
+		u += "?" + query.Encode()
+	}
+	res, err := c.httpClient.Get(u)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var e Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil || e.Error == "" {
+			return nil, fmt.Errorf("simulated backend request failed with status %v", res.StatusCode)
+		}
+		return nil, fmt.Errorf("simulated backend request failed with status %v: %v", res.StatusCode, e.Error)
+	}
+	var state proof.IdenStateData
+	if err := json.NewDecoder(res.Body).Decode(&state); err != nil {
+		return nil, err
+	}
+	if state.IdenState == nil {
+		return nil, errors.New("simulated backend response without idenState")
+	}
+	return &state, nil
+}
+
+// GetState returns the last state of id.
+func (c *Client) GetState(id *core.ID) (*proof.IdenStateData, error) {
+	return c.get(id, nil)
+}
+
+// GetStateByBlock returns the state of id at the block blockN.
+func (c *Client) GetStateByBlock(id *core.ID, blockN uint64) (*proof.IdenStateData, error) {
+	return c.get(id, url.Values{"block": []string{strconv.FormatUint(blockN, 10)}})
+}
+
+// GetStateByTime returns the state of id at the time blockTs.
+func (c *Client) GetStateByTime(id *core.ID, blockTs int64) (*proof.IdenStateData, error) {
+	return c.get(id, url.Values{"time": []string{strconv.FormatInt(blockTs, 10)}})
+}
+
+// SetState returns ErrReadOnly.
+func (c *Client) SetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte,
+	signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return nil, ErrReadOnly
+}
+
+// InitState returns ErrReadOnly.
+func (c *Client) InitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte,
+	stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return nil, ErrReadOnly
+}
@@ -0,0 +1,133 @@
+// Package sim is a simulated IdenStates Smart Contract, that keeps the
+// identity states in memory, to run the issuers and verifiers without an
+// Ethereum node in examples and tests.  Each state update is mined right away
+// in a new block.  The proofs and signatures of the updates are not
+// verified.
+//
+// The states of a Backend can be served over HTTP with Handler, and read by
+// other processes with Client.
+package sim
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+var (
+	// ErrStateInitialized is used when InitState is called for an
+	// identity that already has a state.
+	ErrStateInitialized = errors.New("the identity state is already initialized")
+	// ErrStateNotInitialized is used when SetState is called for an
+	// identity without a state.
+	ErrStateNotInitialized = errors.New("the identity state is not initialized")
+	// ErrGenesisMismatch is used when the genesis state passed to
+	// InitState doesn't derive the identity.
+	ErrGenesisMismatch = errors.New("the genesis state doesn't match the identity")
+)
+
+var _ idenpubonchain.IdenPubOnChainer = (*Backend)(nil)
+
+// Backend is a simulated IdenStates Smart Contract.
+type Backend struct {
+	mutex  sync.RWMutex
+	clock  clock.Clock
+	blockN uint64
+	states map[core.ID][]proof.IdenStateData
+}
+
+// New creates a Backend whose blocks are timestamped with clk.
+func New(clk clock.Clock) *Backend {
+	return &Backend{clock: clk, states: make(map[core.ID][]proof.IdenStateData)}
+}
+
+// BlockN returns the number of the last mined block.
+func (b *Backend) BlockN() uint64 {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	return b.blockN
+}
+
+// mine appends newState to the states of id in a new block, and returns the
+// transaction that sets it.
+func (b *Backend) mine(id *core.ID, newState *merkletree.Hash) *types.Transaction {
+	b.blockN++
+	state := *newState
+	b.states[*id] = append(b.states[*id], proof.IdenStateData{
+		BlockN:    b.blockN,
+		BlockTs:   b.clock.Now().Unix(),
+		IdenState: &state,
+	})
+	return types.NewTransaction(b.blockN, common.Address{}, big.NewInt(0), 0, big.NewInt(0), newState[:])
+}
+
+// zeroState is the state data returned for the identities without a state.
+func zeroState() *proof.IdenStateData {
+	return &proof.IdenStateData{IdenState: &merkletree.Hash{}}
+}
+
+// find returns the last state of id that satisfies match, or zeroState.
+func (b *Backend) find(id *core.ID, match func(s *proof.IdenStateData) bool) *proof.IdenStateData {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	states := b.states[*id]
+	for i := len(states) - 1; i >= 0; i-- {
+		if match(&states[i]) {
+			s := states[i]
+			state := *s.IdenState
+			s.IdenState = &state
+			return &s
+		}
+	}
+	return zeroState()
+}
+
+// GetState returns the last state of id.
+func (b *Backend) GetState(id *core.ID) (*proof.IdenStateData, error) {
+	return b.find(id, func(*proof.IdenStateData) bool { return true }), nil
+}
+
+// GetStateByBlock returns the state of id at the block blockN.
+func (b *Backend) GetStateByBlock(id *core.ID, blockN uint64) (*proof.IdenStateData, error) {
+	return b.find(id, func(s *proof.IdenStateData) bool { return s.BlockN <= blockN }), nil
+}
+
+// GetStateByTime returns the state of id at the time blockTs.
+func (b *Backend) GetStateByTime(id *core.ID, blockTs int64) (*proof.IdenStateData, error) {
+	return b.find(id, func(s *proof.IdenStateData) bool { return s.BlockTs <= blockTs }), nil
+}
+
+// SetState sets the state of an initialized identity.
+func (b *Backend) SetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte,
+	signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.states[*id]) == 0 {
+		return nil, ErrStateNotInitialized
+	}
+	return b.mine(id, newState), nil
+}
+
+// InitState sets the first state of an identity from its genesis state.
+func (b *Backend) InitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte,
+	stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.states[*id]) != 0 {
+		return nil, ErrStateInitialized
+	}
+	if genesisID := core.IdGenesisFromIdenState(genesisState); *genesisID != *id {
+		return nil, fmt.Errorf("%w: %v", ErrGenesisMismatch, genesisID)
+	}
+	return b.mine(id, newState), nil
+}
@@ -0,0 +1,63 @@
+package sim
+
+import (
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackend(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1000, 0))
+	b := New(clk)
+	genesisState := &merkletree.Hash{}
+	genesisState[20] = 1
+	id := core.IdGenesisFromIdenState(genesisState)
+
+	state, err := b.GetState(id)
+	require.Nil(t, err)
+	assert.Equal(t, &merkletree.HashZero, state.IdenState)
+
+	_, err = b.SetState(id, &merkletree.Hash{4}, nil, nil, nil)
+	assert.Equal(t, ErrStateNotInitialized, err)
+	_, err = b.InitState(id, &merkletree.Hash{9}, &merkletree.Hash{4}, nil, nil, nil)
+	assert.True(t, errors.Is(err, ErrGenesisMismatch))
+
+	_, err = b.InitState(id, genesisState, &merkletree.Hash{4}, nil, nil, nil)
+	require.Nil(t, err)
+	_, err = b.InitState(id, genesisState, &merkletree.Hash{4}, nil, nil, nil)
+	assert.Equal(t, ErrStateInitialized, err)
+	clk.Advance(10 * time.Second)
+	_, err = b.SetState(id, &merkletree.Hash{5}, nil, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), b.BlockN())
+
+	server := httptest.NewServer(Handler(b))
+	defer server.Close()
+	c := NewClient(server.URL, nil)
+
+	state, err = c.GetState(id)
+	require.Nil(t, err)
+	assert.Equal(t, &merkletree.Hash{5}, state.IdenState)
+	assert.Equal(t, uint64(2), state.BlockN)
+	assert.Equal(t, int64(1010), state.BlockTs)
+
+	state, err = c.GetStateByBlock(id, 1)
+	require.Nil(t, err)
+	assert.Equal(t, &merkletree.Hash{4}, state.IdenState)
+	state, err = c.GetStateByTime(id, 1005)
+	require.Nil(t, err)
+	assert.Equal(t, &merkletree.Hash{4}, state.IdenState)
+	state, err = c.GetStateByTime(id, 999)
+	require.Nil(t, err)
+	assert.Equal(t, &merkletree.HashZero, state.IdenState)
+
+	_, err = c.SetState(id, &merkletree.Hash{6}, nil, nil, nil)
+	assert.Equal(t, ErrReadOnly, err)
+}
@@ -0,0 +1,121 @@
+package identitysrv
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathIdentities is the path of the identities endpoint.
+const PathIdentities = "/identities"
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// ListResponse is the body of the response of GET /identities.
+type ListResponse struct {
+	Total      int        `json:"total"`
+	Identities []Identity `json:"identities"`
+}
+
+// queryInt returns the integer query parameter name, or 0 if it's missing.
+func queryInt(req *http.Request, name string) (int, error) {
+	s := req.URL.Query().Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func parseKOp(s string) (*babyjub.PublicKeyComp, error) {
+	b, err := common3.HexDecode(s)
+	if err != nil {
+		return nil, err
+	}
+	var kOp babyjub.PublicKeyComp
+	if len(b) != len(kOp) {
+		return nil, errInvalidKOp
+	}
+	copy(kOp[:], b)
+	return &kOp, nil
+}
+
+// Handler returns an http.Handler that serves the identities of s, as
+// described in the package documentation.
+func Handler(s Storage) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathIdentities, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var identities []Identity
+		var err error
+		if kOpHex := req.URL.Query().Get("kop"); kOpHex != "" {
+			kOp, errParse := parseKOp(kOpHex)
+			if errParse != nil {
+				httpjson.WriteError(w, http.StatusBadRequest, "invalid kop: "+errParse.Error())
+				return
+			}
+			identities, err = s.ByKOp(kOp)
+			if err != nil {
+				log.WithError(err).Error("Unable to get the identities by operational key")
+				httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+				return
+			}
+			httpjson.Write(w, http.StatusOK, ListResponse{Total: len(identities), Identities: identities})
+			return
+		}
+		offset, err := queryInt(req, "offset")
+		if err != nil || offset < 0 {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid offset")
+			return
+		}
+		limit, err := queryInt(req, "limit")
+		if err != nil || limit < 0 {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid limit")
+			return
+		}
+		total, err := s.Count()
+		if err != nil {
+			log.WithError(err).Error("Unable to count the identities")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		identities, err = s.List(offset, limit)
+		if err != nil {
+			log.WithError(err).Error("Unable to list the identities")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, ListResponse{Total: total, Identities: identities})
+	})
+	mux.HandleFunc(PathIdentities+"/", func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		id, err := core.IDFromString(strings.TrimPrefix(req.URL.Path, PathIdentities+"/"))
+		if err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid id: "+err.Error())
+			return
+		}
+		identity, err := s.Get(&id)
+		if err == ErrIdentityNotFound {
+			httpjson.WriteError(w, http.StatusNotFound, err.Error())
+			return
+		} else if err != nil {
+			log.WithError(err).Error("Unable to get the identity")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, identity)
+	})
+	return mux
+}
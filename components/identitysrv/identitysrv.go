@@ -0,0 +1,238 @@
+// Package identitysrv keeps the index of the identities served by a relay,
+// so that relays with hundreds of thousands of identities can list them page
+// by page and find them by operational key.  The index is kept by a Storage,
+// implemented over a db.Storage (leveldb or memory) by DBStorage and over a
+// SQL database (Postgres) by SQLStorage, and it's served by Handler:
+//
+//	GET /identities?offset=<n>&limit=<n>   list the identities
+//	GET /identities?kop=<hex>              identities of an operational key
+//	GET /identities/<id>                   an identity
+package identitysrv
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// MaxLimit is the maximum number of identities returned at once by List.
+const MaxLimit = 1000
+
+var (
+	// ErrIdentityNotFound is used when an identity is not in the Storage.
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityExists is used when an identity added to the Storage is
+	// already in it.
+	ErrIdentityExists = errors.New("identity already exists")
+
+	errInvalidCount = errors.New("invalid identities count")
+	errInvalidKOp   = errors.New("the operational key must have 32 bytes")
+)
+
+// Identity is an identity served by the relay.
+type Identity struct {
+	Id        *core.ID               `json:"id"`
+	KOp       *babyjub.PublicKeyComp `json:"operationalPk"`
+	CreatedTs int64                  `json:"createdTs"`
+}
+
+// Storage is the index of the identities of a relay.
+type Storage interface {
+	// Add adds an identity, or returns ErrIdentityExists.
+	Add(identity *Identity) error
+	// Get returns the identity id, or ErrIdentityNotFound.
+	Get(id *core.ID) (*Identity, error)
+	// List returns up to limit (at most MaxLimit) identities, in the
+	// order they were added, skipping the first offset ones.
+	List(offset, limit int) ([]Identity, error)
+	// Count returns the number of identities.
+	Count() (int, error)
+	// ByKOp returns the identities with the operational key kOp.
+	ByKOp(kOp *babyjub.PublicKeyComp) ([]Identity, error)
+	// SetKOp replaces the operational key of the identity id with kOp,
+	// like after a recovery of the key (see
+	// issuer.Issuer.CompleteRecovery), or returns ErrIdentityNotFound.
+	SetKOp(id *core.ID, kOp *babyjub.PublicKeyComp) error
+}
+
+// clampLimit returns the limit used by List for the requested one.
+func clampLimit(limit int) int {
+	if limit <= 0 || limit > MaxLimit {
+		return MaxLimit
+	}
+	return limit
+}
+
+var (
+	dbPrefixIdentity = []byte("identity:")
+	// dbPrefixSeq indexes the identities by the order they were added.
+	dbPrefixSeq = []byte("identityseq:")
+	// dbPrefixKOp indexes the identities by operational key.
+	dbPrefixKOp = []byte("identitykop:")
+	dbKeyCount  = []byte("identitycount")
+)
+
+func key(prefix []byte, parts ...[]byte) []byte {
+	k := append([]byte{}, prefix...)
+	for _, part := range parts {
+		k = append(k, part...)
+	}
+	return k
+}
+
+func seqBytes(seq uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return b[:]
+}
+
+// DBStorage is a Storage over a db.Storage.  The identities are stored by
+// ID, and indexed by the order they were added, so that List only reads the
+// requested page, and by operational key.
+type DBStorage struct {
+	storage db.Storage
+	// mutex serializes the Adds, which update the count, and the SetKOps,
+	// which update the operational key index.
+	mutex sync.Mutex
+}
+
+// NewDBStorage creates a DBStorage over storage.
+func NewDBStorage(storage db.Storage) *DBStorage {
+	return &DBStorage{storage: storage}
+}
+
+func (s *DBStorage) count() (uint64, error) {
+	b, err := s.storage.Get(dbKeyCount)
+	if err == db.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(b) != 8 {
+		return 0, errInvalidCount
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Add adds an identity.
+func (s *DBStorage) Add(identity *Identity) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if _, err := s.storage.Get(key(dbPrefixIdentity, identity.Id[:])); err == nil {
+		return ErrIdentityExists
+	} else if err != db.ErrNotFound {
+		return err
+	}
+	count, err := s.count()
+	if err != nil {
+		return err
+	}
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := db.StoreJSON(tx, key(dbPrefixIdentity, identity.Id[:]), identity); err != nil {
+		return err
+	}
+	tx.Put(key(dbPrefixSeq, seqBytes(count)), identity.Id[:])
+	if identity.KOp != nil {
+		tx.Put(key(dbPrefixKOp, identity.KOp[:], identity.Id[:]), []byte{})
+	}
+	tx.Put(dbKeyCount, seqBytes(count+1))
+	return tx.Commit()
+}
+
+func (s *DBStorage) get(idBytes []byte) (*Identity, error) {
+	v, err := s.storage.Get(key(dbPrefixIdentity, idBytes))
+	if err == db.ErrNotFound {
+		return nil, ErrIdentityNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var identity Identity
+	if err := json.Unmarshal(v, &identity); err != nil {
+		return nil, err
+	}
+	return &identity, nil
+}
+
+// Get returns the identity id.
+func (s *DBStorage) Get(id *core.ID) (*Identity, error) {
+	return s.get(id[:])
+}
+
+// List returns a page of the identities in the order they were added.
+func (s *DBStorage) List(offset, limit int) ([]Identity, error) {
+	count, err := s.count()
+	if err != nil {
+		return nil, err
+	}
+	identities := []Identity{}
+	if offset < 0 {
+		offset = 0
+	}
+	for seq := uint64(offset); seq < count && len(identities) < clampLimit(limit); seq++ {
+		idBytes, err := s.storage.Get(key(dbPrefixSeq, seqBytes(seq)))
+		if err != nil {
+			return nil, err
+		}
+		identity, err := s.get(idBytes)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *identity)
+	}
+	return identities, nil
+}
+
+// Count returns the number of identities.
+func (s *DBStorage) Count() (int, error) {
+	count, err := s.count()
+	return int(count), err
+}
+
+// ByKOp returns the identities with the operational key kOp.
+func (s *DBStorage) ByKOp(kOp *babyjub.PublicKeyComp) ([]Identity, error) {
+	identities := []Identity{}
+	if err := s.storage.WithPrefix(key(dbPrefixKOp, kOp[:])).Iterate(func(idBytes, _ []byte) (bool, error) {
+		identity, err := s.get(idBytes)
+		if err != nil {
+			return false, err
+		}
+		identities = append(identities, *identity)
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	return identities, nil
+}
+
+// SetKOp replaces the operational key of the identity id with kOp.
+func (s *DBStorage) SetKOp(id *core.ID, kOp *babyjub.PublicKeyComp) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	identity, err := s.get(id[:])
+	if err != nil {
+		return err
+	}
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if identity.KOp != nil {
+		tx.Delete(key(dbPrefixKOp, identity.KOp[:], id[:]))
+	}
+	identity.KOp = kOp
+	if err := db.StoreJSON(tx, key(dbPrefixIdentity, id[:]), identity); err != nil {
+		return err
+	}
+	tx.Put(key(dbPrefixKOp, kOp[:], id[:]), []byte{})
+	return tx.Commit()
+}
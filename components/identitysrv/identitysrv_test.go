@@ -0,0 +1,112 @@
+package identitysrv
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newIdentity(i byte, kOp *babyjub.PublicKeyComp) *Identity {
+	id := core.NewID(core.TypeBJP0, [27]byte{i + 1})
+	return &Identity{Id: &id, KOp: kOp, CreatedTs: int64(i)}
+}
+
+func TestDBStorage(t *testing.T) {
+	s := NewDBStorage(db.NewMemoryStorage())
+	kOp1 := babyjub.PublicKeyComp{1}
+	kOp2 := babyjub.PublicKeyComp{2}
+	for i := byte(0); i < 10; i++ {
+		kOp := &kOp1
+		if i%3 == 0 {
+			kOp = &kOp2
+		}
+		require.Nil(t, s.Add(newIdentity(i, kOp)))
+	}
+	assert.Equal(t, ErrIdentityExists, s.Add(newIdentity(4, &kOp1)))
+
+	count, err := s.Count()
+	require.Nil(t, err)
+	assert.Equal(t, 10, count)
+
+	identity, err := s.Get(newIdentity(4, nil).Id)
+	require.Nil(t, err)
+	assert.Equal(t, newIdentity(4, &kOp1), identity)
+	_, err = s.Get(newIdentity(20, nil).Id)
+	assert.Equal(t, ErrIdentityNotFound, err)
+
+	page, err := s.List(3, 4)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(page))
+	for i, identity := range page {
+		assert.Equal(t, int64(3+i), identity.CreatedTs)
+	}
+	page, err = s.List(8, 4)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(page))
+	page, err = s.List(10, 4)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(page))
+
+	identities, err := s.ByKOp(&kOp2)
+	require.Nil(t, err)
+	assert.Equal(t, 4, len(identities))
+	identities, err = s.ByKOp(&babyjub.PublicKeyComp{3})
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(identities))
+
+	// A recovered operational key is reindexed
+	require.Nil(t, s.SetKOp(newIdentity(3, nil).Id, &babyjub.PublicKeyComp{3}))
+	identities, err = s.ByKOp(&kOp2)
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(identities))
+	identities, err = s.ByKOp(&babyjub.PublicKeyComp{3})
+	require.Nil(t, err)
+	assert.Equal(t, []Identity{*newIdentity(3, &babyjub.PublicKeyComp{3})}, identities)
+	assert.Equal(t, ErrIdentityNotFound, s.SetKOp(newIdentity(20, nil).Id, &kOp1))
+}
+
+func TestHandler(t *testing.T) {
+	s := NewDBStorage(db.NewMemoryStorage())
+	kOp := babyjub.PublicKeyComp{1}
+	for i := byte(0); i < 5; i++ {
+		require.Nil(t, s.Add(newIdentity(i, &kOp)))
+	}
+	server := httptest.NewServer(Handler(s))
+	defer server.Close()
+
+	get := func(path string, v interface{}) int {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		defer res.Body.Close()
+		if v != nil {
+			require.Nil(t, json.NewDecoder(res.Body).Decode(v))
+		}
+		return res.StatusCode
+	}
+
+	var list ListResponse
+	assert.Equal(t, http.StatusOK, get(PathIdentities+"?offset=1&limit=2", &list))
+	assert.Equal(t, 5, list.Total)
+	require.Equal(t, 2, len(list.Identities))
+	assert.Equal(t, newIdentity(1, &kOp), &list.Identities[0])
+
+	list = ListResponse{}
+	assert.Equal(t, http.StatusOK, get(PathIdentities+"?kop="+hex.EncodeToString(kOp[:]), &list))
+	assert.Equal(t, 5, list.Total)
+	assert.Equal(t, http.StatusBadRequest, get(PathIdentities+"?kop=0x01", nil))
+	assert.Equal(t, http.StatusBadRequest, get(PathIdentities+"?limit=x", nil))
+
+	var identity Identity
+	id := newIdentity(2, nil).Id
+	assert.Equal(t, http.StatusOK, get(PathIdentities+"/"+id.String(), &identity))
+	assert.Equal(t, newIdentity(2, &kOp), &identity)
+	assert.Equal(t, http.StatusNotFound, get(PathIdentities+"/"+newIdentity(9, nil).Id.String(), nil))
+}
@@ -0,0 +1,140 @@
+package identitysrv
+
+import (
+	"database/sql"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// SQLSchema creates the table of a SQLStorage in Postgres.
+const SQLSchema = `
+CREATE TABLE IF NOT EXISTS identities (
+	seq        BIGSERIAL PRIMARY KEY,
+	id         BYTEA NOT NULL UNIQUE,
+	kop        BYTEA,
+	created_ts BIGINT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS identities_kop ON identities (kop);
+`
+
+// SQLStorage is a Storage over a Postgres database, for the relays whose
+// identities don't fit a local db.  The database driver must be imported by
+// the caller, and the table created with SQLSchema.
+type SQLStorage struct {
+	db *sql.DB
+}
+
+// NewSQLStorage creates a SQLStorage over the database sqlDB.
+func NewSQLStorage(sqlDB *sql.DB) *SQLStorage {
+	return &SQLStorage{db: sqlDB}
+}
+
+// CreateTable creates the table of the identities if it doesn't exist.
+func (s *SQLStorage) CreateTable() error {
+	_, err := s.db.Exec(SQLSchema)
+	return err
+}
+
+// Add adds an identity.
+func (s *SQLStorage) Add(identity *Identity) error {
+	var kOp []byte
+	if identity.KOp != nil {
+		kOp = identity.KOp[:]
+	}
+	res, err := s.db.Exec(`INSERT INTO identities (id, kop, created_ts) VALUES ($1, $2, $3)
+		ON CONFLICT (id) DO NOTHING`, identity.Id[:], kOp, identity.CreatedTs)
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrIdentityExists
+	}
+	return nil
+}
+
+// scanner is a *sql.Row or *sql.Rows.
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanIdentity(row scanner) (*Identity, error) {
+	var idBytes, kOpBytes []byte
+	var identity Identity
+	if err := row.Scan(&idBytes, &kOpBytes, &identity.CreatedTs); err != nil {
+		return nil, err
+	}
+	var id core.ID
+	copy(id[:], idBytes)
+	identity.Id = &id
+	if kOpBytes != nil {
+		var kOp babyjub.PublicKeyComp
+		copy(kOp[:], kOpBytes)
+		identity.KOp = &kOp
+	}
+	return &identity, nil
+}
+
+func (s *SQLStorage) query(query string, args ...interface{}) ([]Identity, error) {
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	identities := []Identity{}
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, err
+		}
+		identities = append(identities, *identity)
+	}
+	return identities, rows.Err()
+}
+
+// Get returns the identity id.
+func (s *SQLStorage) Get(id *core.ID) (*Identity, error) {
+	identity, err := scanIdentity(s.db.QueryRow(
+		`SELECT id, kop, created_ts FROM identities WHERE id = $1`, id[:]))
+	if err == sql.ErrNoRows {
+		return nil, ErrIdentityNotFound
+	}
+	return identity, err
+}
+
+// List returns a page of the identities in the order they were added.
+func (s *SQLStorage) List(offset, limit int) ([]Identity, error) {
+	if offset < 0 {
+		offset = 0
+	}
+	return s.query(`SELECT id, kop, created_ts FROM identities ORDER BY seq LIMIT $1 OFFSET $2`,
+		clampLimit(limit), offset)
+}
+
+// Count returns the number of identities.
+func (s *SQLStorage) Count() (int, error) {
+	var count int
+	err := s.db.QueryRow(`SELECT COUNT(*) FROM identities`).Scan(&count)
+	return count, err
+}
+
+// ByKOp returns the identities with the operational key kOp.
+func (s *SQLStorage) ByKOp(kOp *babyjub.PublicKeyComp) ([]Identity, error) {
+	return s.query(`SELECT id, kop, created_ts FROM identities WHERE kop = $1 ORDER BY seq`, kOp[:])
+}
+
+// SetKOp replaces the operational key of the identity id with kOp.
+func (s *SQLStorage) SetKOp(id *core.ID, kOp *babyjub.PublicKeyComp) error {
+	res, err := s.db.Exec(`UPDATE identities SET kop = $1 WHERE id = $2`, kOp[:], id[:])
+	if err != nil {
+		return err
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
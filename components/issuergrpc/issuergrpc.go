@@ -0,0 +1,420 @@
+package issuergrpc
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/proto/issuerpb"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// ChunkSizeDefault is the default maximum size of the tree dumps sent in each
+// PublicDataChunk.
+const ChunkSizeDefault = 64 * 1024
+
+// PublicDataGetter is an interface to get the off chain public data of an
+// identity, satisfied by idenpuboffchainwriter.IdenPubOffChainWriteHttp.
+type PublicDataGetter interface {
+	GetPublicData(queryIdenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error)
+}
+
+// Server implements the issuerpb.IssuerServer gRPC service over an Issuer.
+type Server struct {
+	issuer     *issuer.Issuer
+	publicData PublicDataGetter
+	chunkSize  int
+}
+
+// NewServer creates a new Server.  publicData can be nil, in which case
+// GetPublicData returns an Unimplemented error.
+func NewServer(is *issuer.Issuer, publicData PublicDataGetter) *Server {
+	return &Server{issuer: is, publicData: publicData, chunkSize: ChunkSizeDefault}
+}
+
+// NewGrpcServer returns a grpc.Server with the Server registered.  If
+// tlsConfig is not nil, the server uses TLS (see NewServerTLSConfig to require
+// client certificates).
+func NewGrpcServer(s *Server, tlsConfig *tls.Config, opts ...grpc.ServerOption) *grpc.Server {
+	if tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	gs := grpc.NewServer(opts...)
+	issuerpb.RegisterIssuerServer(gs, s)
+	return gs
+}
+
+// NewServerTLSConfig returns a TLS configuration for mutual TLS authentication:
+// the server uses the certificate certFile with the key keyFile, and only
+// accepts clients with a certificate signed by a CA in clientCAFile.
+func NewServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	clientCAs, err := loadCertPool(clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		ClientCAs:    clientCAs,
+	}, nil
+}
+
+// NewClientTLSConfig returns a TLS configuration for a client of a server
+// using mutual TLS authentication: the client uses the certificate certFile
+// with the key keyFile, and only accepts a server with a certificate signed by
+// a CA in caFile.
+func NewClientTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, err
+	}
+	rootCAs, err := loadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		RootCAs:      rootCAs,
+	}, nil
+}
+
+func loadCertPool(caFile string) (*x509.CertPool, error) {
+	caPEM, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, fmt.Errorf("no certificates found in %v", caFile)
+	}
+	return pool, nil
+}
+
+// statusErr converts the errors returned by the Issuer to gRPC status errors.
+func statusErr(err error) error {
+	switch err {
+	case nil:
+		return nil
+	case merkletree.ErrEntryIndexAlreadyExists, issuer.ErrClaimAlreadyIssued:
+		return status.Error(codes.AlreadyExists, err.Error())
+	case merkletree.ErrEntryIndexNotFound, issuer.ErrClaimNotFoundStateOnChain,
+		idenpuboffchainwriter.ErrIdenStateNotFound:
+		return status.Error(codes.NotFound, err.Error())
+	case issuer.ErrIdenStateOnChainZero, issuer.ErrIdenPubOnChainNil, issuer.ErrRecovererNotSet,
+		issuer.ErrRecoveryTimelockZero, issuer.ErrRecoveryPending, issuer.ErrNoRecoveryPending,
+		issuer.ErrRecoveryTimelock:
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case issuer.ErrInvalidRecoverySig, issuer.ErrInvalidCancelSig:
+		return status.Error(codes.PermissionDenied, err.Error())
+	case issuer.ErrRecoveryKOpUnchanged:
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return status.Error(codes.Internal, err.Error())
+	}
+}
+
+func parseClaim(claimBytes []byte) (merkletree.Entrier, error) {
+	entry, err := merkletree.NewEntryFromBytes(claimBytes)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	claim, err := claims.NewClaimFromEntry(entry)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return claim, nil
+}
+
+func parseHash(b []byte) (*merkletree.Hash, error) {
+	h, err := merkletree.NewHashFromBytes(b)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid hash: %v", err)
+	}
+	return h, nil
+}
+
+// IssueClaim adds a claim to the claims tree of the Issuer.
+func (s *Server) IssueClaim(ctx context.Context, req *issuerpb.IssueClaimRequest) (*issuerpb.IssueClaimResponse, error) {
+	claim, err := parseClaim(req.Claim)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.issuer.IssueClaim(claim); err != nil {
+		return nil, statusErr(err)
+	}
+	return &issuerpb.IssueClaimResponse{}, nil
+}
+
+// RevokeClaim revokes an issued claim.
+func (s *Server) RevokeClaim(ctx context.Context, req *issuerpb.RevokeClaimRequest) (*issuerpb.RevokeClaimResponse, error) {
+	claim, err := parseClaim(req.Claim)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.issuer.RevokeClaim(claim); err != nil {
+		return nil, statusErr(err)
+	}
+	return &issuerpb.RevokeClaimResponse{}, nil
+}
+
+// GetCredential returns the existence credential of an issued claim.
+func (s *Server) GetCredential(ctx context.Context, req *issuerpb.GetCredentialRequest) (*issuerpb.GetCredentialResponse, error) {
+	claim, err := parseClaim(req.Claim)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := s.issuer.GenCredentialExistence(claim)
+	if err != nil {
+		return nil, statusErr(err)
+	}
+	return &issuerpb.GetCredentialResponse{Credential: CredentialExistenceToPb(cred)}, nil
+}
+
+// PublishState publishes the current identity state on chain.
+func (s *Server) PublishState(ctx context.Context, req *issuerpb.PublishStateRequest) (*issuerpb.PublishStateResponse, error) {
+	res, err := s.issuer.PublishState()
+	if err != nil {
+		return nil, statusErr(err)
+	}
+	return PublishStateResultToPb(res), nil
+}
+
+// GetRecovery returns the pending recovery of the operational key and the
+// next recovery nonce.
+func (s *Server) GetRecovery(ctx context.Context, req *issuerpb.GetRecoveryRequest) (*issuerpb.GetRecoveryResponse, error) {
+	nonce, err := s.issuer.RecoveryNonce()
+	if err != nil {
+		return nil, statusErr(err)
+	}
+	recovery, err := s.issuer.PendingRecovery()
+	if err != nil {
+		return nil, statusErr(err)
+	}
+	res := &issuerpb.GetRecoveryResponse{Nonce: nonce}
+	if recovery != nil {
+		res.Recovery = RecoveryToPb(recovery)
+	}
+	return res, nil
+}
+
+// InitRecovery initiates the recovery of the operational key.
+func (s *Server) InitRecovery(ctx context.Context, req *issuerpb.InitRecoveryRequest) (*issuerpb.InitRecoveryResponse, error) {
+	var newKOp babyjub.PublicKeyComp
+	if len(req.NewKop) != len(newKOp) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid new_kop length: %v", len(req.NewKop))
+	}
+	copy(newKOp[:], req.NewKop)
+	var sig crypto.SignatureEthMsg
+	if len(req.Signature) != len(sig) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid signature length: %v", len(req.Signature))
+	}
+	copy(sig[:], req.Signature)
+	recovery, err := s.issuer.InitRecovery(&newKOp, &sig)
+	if err != nil {
+		return nil, statusErr(err)
+	}
+	return &issuerpb.InitRecoveryResponse{Recovery: RecoveryToPb(recovery)}, nil
+}
+
+// CancelRecovery cancels the pending recovery of the operational key.
+func (s *Server) CancelRecovery(ctx context.Context, req *issuerpb.CancelRecoveryRequest) (*issuerpb.CancelRecoveryResponse, error) {
+	var sig babyjub.SignatureComp
+	if len(req.Signature) != len(sig) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid signature length: %v", len(req.Signature))
+	}
+	copy(sig[:], req.Signature)
+	if err := s.issuer.CancelRecovery(&sig); err != nil {
+		return nil, statusErr(err)
+	}
+	return &issuerpb.CancelRecoveryResponse{}, nil
+}
+
+// CompleteRecovery completes the pending recovery of the operational key.
+func (s *Server) CompleteRecovery(ctx context.Context, req *issuerpb.CompleteRecoveryRequest) (*issuerpb.CompleteRecoveryResponse, error) {
+	if err := s.issuer.CompleteRecovery(); err != nil {
+		return nil, statusErr(err)
+	}
+	return &issuerpb.CompleteRecoveryResponse{}, nil
+}
+
+// GetPublicData streams the off chain public data of the identity.
+func (s *Server) GetPublicData(req *issuerpb.GetPublicDataRequest, stream issuerpb.Issuer_GetPublicDataServer) error {
+	if s.publicData == nil {
+		return status.Error(codes.Unimplemented, "public data not available")
+	}
+	var idenState *merkletree.Hash
+	if len(req.IdenState) != 0 {
+		var err error
+		if idenState, err = parseHash(req.IdenState); err != nil {
+			return err
+		}
+	}
+	publicData, err := s.publicData.GetPublicData(idenState)
+	if err != nil {
+		return statusErr(err)
+	}
+	if err := stream.Send(&issuerpb.PublicDataChunk{
+		IdenState:           publicData.IdenState[:],
+		ClaimsTreeRoot:      publicData.ClaimsTreeRoot[:],
+		RevocationsTreeRoot: publicData.RevocationsTreeRoot[:],
+		RootsTreeRoot:       publicData.RootsTreeRoot[:],
+	}); err != nil {
+		return err
+	}
+	for rot, ret := publicData.RootsTree, publicData.RevocationsTree; len(rot) != 0 || len(ret) != 0; {
+		var chunk issuerpb.PublicDataChunk
+		chunk.RootsTree, rot = split(rot, s.chunkSize)
+		chunk.RevocationsTree, ret = split(ret, s.chunkSize)
+		if err := stream.Send(&chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func split(b []byte, n int) ([]byte, []byte) {
+	if len(b) < n {
+		return b, nil
+	}
+	return b[:n], b[n:]
+}
+
+// PublishStateResultToPb converts an issuer.PublishStateResult to its
+// protobuf representation.
+func PublishStateResultToPb(res *issuer.PublishStateResult) *issuerpb.PublishStateResponse {
+	pb := &issuerpb.PublishStateResponse{}
+	switch res.Status {
+	case issuer.PublishStateSubmitted:
+		pb.Status = issuerpb.PublishStateResponse_SUBMITTED
+	case issuer.PublishStateNoChanges:
+		pb.Status = issuerpb.PublishStateResponse_NO_CHANGES
+	case issuer.PublishStateAlreadyPending:
+		pb.Status = issuerpb.PublishStateResponse_ALREADY_PENDING
+	}
+	if res.IdenState != nil {
+		pb.IdenState = res.IdenState[:]
+	}
+	if res.EthTx != nil {
+		pb.EthTxHash = res.EthTx.Hash().Bytes()
+	}
+	return pb
+}
+
+// RecoveryToPb converts an issuer.Recovery to its protobuf message.
+func RecoveryToPb(recovery *issuer.Recovery) *issuerpb.Recovery {
+	return &issuerpb.Recovery{
+		NewKop:    recovery.NewKOp[:],
+		NotBefore: recovery.NotBefore,
+		Nonce:     recovery.Nonce,
+	}
+}
+
+// RecvPublicData reads all the chunks of a GetPublicData stream and returns
+// the assembled public data.
+func RecvPublicData(stream issuerpb.Issuer_GetPublicDataClient) (*idenpuboffchainwriter.PublicData, error) {
+	var publicData idenpuboffchainwriter.PublicData
+	first := true
+	for {
+		chunk, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		if first {
+			for _, h := range []struct {
+				dst *merkletree.Hash
+				src []byte
+			}{
+				{&publicData.IdenState, chunk.IdenState},
+				{&publicData.ClaimsTreeRoot, chunk.ClaimsTreeRoot},
+				{&publicData.RevocationsTreeRoot, chunk.RevocationsTreeRoot},
+				{&publicData.RootsTreeRoot, chunk.RootsTreeRoot},
+			} {
+				hash, err := parseHash(h.src)
+				if err != nil {
+					return nil, err
+				}
+				*h.dst = *hash
+			}
+			first = false
+		}
+		publicData.RootsTree = append(publicData.RootsTree, chunk.RootsTree...)
+		publicData.RevocationsTree = append(publicData.RevocationsTree, chunk.RevocationsTree...)
+	}
+	if first {
+		return nil, fmt.Errorf("empty public data stream")
+	}
+	return &publicData, nil
+}
+
+// CredentialExistenceToPb converts a CredentialExistence to its protobuf
+// message.
+func CredentialExistenceToPb(cred *proof.CredentialExistence) *issuerpb.CredentialExistence {
+	return &issuerpb.CredentialExistence{
+		Id: cred.Id[:],
+		IdenStateData: &issuerpb.IdenStateData{
+			BlockN:    cred.IdenStateData.BlockN,
+			BlockTs:   cred.IdenStateData.BlockTs,
+			IdenState: cred.IdenStateData.IdenState[:],
+		},
+		MtpClaim:        cred.MtpClaim.Bytes(),
+		Claim:           cred.Claim.Bytes(),
+		RevocationsRoot: cred.RevocationsRoot[:],
+		RootsRoot:       cred.RootsRoot[:],
+		IdPubUrl:        cred.IdPubUrl,
+	}
+}
+
+// CredentialExistenceFromPb converts a protobuf message to a
+// CredentialExistence.
+func CredentialExistenceFromPb(m *issuerpb.CredentialExistence) (*proof.CredentialExistence, error) {
+	var cred proof.CredentialExistence
+	var err error
+	if m.IdenStateData == nil {
+		return nil, fmt.Errorf("missing IdenStateData")
+	}
+	var id core.ID
+	if len(m.Id) != len(id) {
+		return nil, fmt.Errorf("invalid id length: %v", len(m.Id))
+	}
+	copy(id[:], m.Id)
+	cred.Id = &id
+	cred.IdenStateData.BlockN = m.IdenStateData.BlockN
+	cred.IdenStateData.BlockTs = m.IdenStateData.BlockTs
+	if cred.IdenStateData.IdenState, err = parseHash(m.IdenStateData.IdenState); err != nil {
+		return nil, err
+	}
+	if cred.MtpClaim, err = merkletree.NewProofFromBytes(m.MtpClaim); err != nil {
+		return nil, err
+	}
+	if cred.Claim, err = merkletree.NewEntryFromBytes(m.Claim); err != nil {
+		return nil, err
+	}
+	if cred.RevocationsRoot, err = parseHash(m.RevocationsRoot); err != nil {
+		return nil, err
+	}
+	if cred.RootsRoot, err = parseHash(m.RootsRoot); err != nil {
+		return nil, err
+	}
+	cred.IdPubUrl = m.IdPubUrl
+	return &cred, nil
+}
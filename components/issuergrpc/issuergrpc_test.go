@@ -0,0 +1,284 @@
+package issuergrpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/proto/issuerpb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+type publicDataFixed struct {
+	publicData *idenpuboffchainwriter.PublicData
+}
+
+func (p *publicDataFixed) GetPublicData(queryIdenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	if queryIdenState != nil && !queryIdenState.Equals(&p.publicData.IdenState) {
+		return nil, idenpuboffchainwriter.ErrIdenStateNotFound
+	}
+	return p.publicData, nil
+}
+
+type certFiles struct {
+	ca, serverCert, serverKey, clientCert, clientKey string
+}
+
+func writePEM(t *testing.T, path, typ string, b []byte) {
+	require.Nil(t, ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: b}), 0600))
+}
+
+func genCert(t *testing.T, dir, name string, tmpl, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.Nil(t, err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	require.Nil(t, err)
+	writePEM(t, filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(dir, name+".key"), "EC PRIVATE KEY", keyDer)
+	return cert, key
+}
+
+func genCerts(t *testing.T, dir string) *certFiles {
+	notBefore := time.Now().Add(-time.Hour)
+	notAfter := time.Now().Add(time.Hour)
+	ca, caKey := genCert(t, dir, "ca", &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ca"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}, nil, nil)
+	genCert(t, dir, "server", &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "server"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, ca, caKey)
+	genCert(t, dir, "client", &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "client"},
+		NotBefore:    notBefore,
+		NotAfter:     notAfter,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}, ca, caKey)
+	return &certFiles{
+		ca:         filepath.Join(dir, "ca.crt"),
+		serverCert: filepath.Join(dir, "server.crt"),
+		serverKey:  filepath.Join(dir, "server.key"),
+		clientCert: filepath.Join(dir, "client.crt"),
+		clientKey:  filepath.Join(dir, "client.key"),
+	}
+}
+
+func newIssuer(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock) *issuer.Issuer {
+	is, _ := newIssuerWithConfig(t, issuer.ConfigDefault, idenPubOnChain)
+	return is
+}
+
+func newIssuerWithConfig(t *testing.T, cfg issuer.Config, idenPubOnChain *idenpubonchain.IdenPubOnChainMock) (*issuer.Issuer, *keystore.KeyStore) {
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	pass := []byte("my passphrase")
+	kOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kOp, pass))
+	is, err := issuer.New(cfg, kOp, []merkletree.Entrier{}, db.NewMemoryStorage(), keyStore, idenPubOnChain, nil)
+	require.Nil(t, err)
+	return is, keyStore
+}
+
+func TestServer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issuergrpc")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	certs := genCerts(t, dir)
+
+	idenPubOnChain := idenpubonchain.New()
+	is := newIssuer(t, idenPubOnChain)
+	publicData := &idenpuboffchainwriter.PublicData{
+		IdenState:       merkletree.Hash{1},
+		ClaimsTreeRoot:  merkletree.Hash{2},
+		RootsTree:       bytes.Repeat([]byte{3}, 1000),
+		RevocationsTree: bytes.Repeat([]byte{4}, 250),
+	}
+	s := NewServer(is, &publicDataFixed{publicData})
+	s.chunkSize = 100
+
+	serverTLS, err := NewServerTLSConfig(certs.serverCert, certs.serverKey, certs.ca)
+	require.Nil(t, err)
+	gs := NewGrpcServer(s, serverTLS)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go gs.Serve(lis)
+	defer gs.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Clients without a certificate are rejected
+	caTLS, err := NewClientTLSConfig(certs.clientCert, certs.clientKey, certs.ca)
+	require.Nil(t, err)
+	noCertTLS := caTLS.Clone()
+	noCertTLS.Certificates = nil
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(noCertTLS)))
+	require.Nil(t, err)
+	_, err = issuerpb.NewIssuerClient(conn).PublishState(ctx, &issuerpb.PublishStateRequest{})
+	assert.NotNil(t, err)
+	conn.Close()
+
+	conn, err = grpc.Dial(lis.Addr().String(), grpc.WithTransportCredentials(credentials.NewTLS(caTLS)))
+	require.Nil(t, err)
+	defer conn.Close()
+	client := issuerpb.NewIssuerClient(conn)
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+
+	_, err = client.IssueClaim(ctx, &issuerpb.IssueClaimRequest{Claim: claim.Entry().Bytes()})
+	require.Nil(t, err)
+	_, err = client.IssueClaim(ctx, &issuerpb.IssueClaimRequest{Claim: claim.Entry().Bytes()})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	_, err = client.IssueClaim(ctx, &issuerpb.IssueClaimRequest{Claim: []byte{1, 2, 3}})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = client.GetCredential(ctx, &issuerpb.GetCredentialRequest{Claim: claim.Entry().Bytes()})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	idenPubOnChain.On("InitState", is.ID(), mock.Anything, mock.Anything, []byte(nil), []byte(nil), mock.Anything).
+		Return(ethTx, nil).Once()
+	pubRes, err := client.PublishState(ctx, &issuerpb.PublishStateRequest{})
+	require.Nil(t, err)
+	idenState, _ := is.State()
+	assert.Equal(t, issuerpb.PublishStateResponse_SUBMITTED, pubRes.Status)
+	assert.Equal(t, idenState[:], pubRes.IdenState)
+	assert.Equal(t, ethTx.Hash().Bytes(), pubRes.EthTxHash)
+	pubRes, err = client.PublishState(ctx, &issuerpb.PublishStateRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, issuerpb.PublishStateResponse_ALREADY_PENDING, pubRes.Status)
+	idenPubOnChain.On("GetState", is.ID()).Return(&proof.IdenStateData{IdenState: idenState}, nil).Once()
+	require.Nil(t, is.SyncIdenStatePublic())
+
+	res, err := client.GetCredential(ctx, &issuerpb.GetCredentialRequest{Claim: claim.Entry().Bytes()})
+	require.Nil(t, err)
+	cred, err := CredentialExistenceFromPb(res.Credential)
+	require.Nil(t, err)
+	credExpected, err := is.GenCredentialExistence(claim)
+	require.Nil(t, err)
+	assert.Equal(t, credExpected.MtpClaim.Bytes(), cred.MtpClaim.Bytes())
+	credExpected.MtpClaim, cred.MtpClaim = nil, nil
+	assert.Equal(t, credExpected, cred)
+
+	stream, err := client.GetPublicData(ctx, &issuerpb.GetPublicDataRequest{})
+	require.Nil(t, err)
+	publicDataRecv, err := RecvPublicData(stream)
+	require.Nil(t, err)
+	assert.Equal(t, publicData, publicDataRecv)
+
+	stream, err = client.GetPublicData(ctx, &issuerpb.GetPublicDataRequest{IdenState: merkletree.HashZero[:]})
+	require.Nil(t, err)
+	_, err = RecvPublicData(stream)
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestServerRecovery(t *testing.T) {
+	recovererKey, err := ethcrypto.HexToECDSA("da7079f082a1ced80c5dee3bf00752fd67f75321a637e5d5073ce1489af062d8")
+	require.Nil(t, err)
+	recoverer := ethcrypto.PubkeyToAddress(recovererKey.PublicKey)
+	cfg := issuer.ConfigDefault
+	cfg.Recoverer = &recoverer
+	cfg.RecoveryTimelock = time.Hour
+	is, keyStore := newIssuerWithConfig(t, cfg, idenpubonchain.New())
+	newKOp, err := keyStore.NewKey([]byte("my passphrase"))
+	require.Nil(t, err)
+
+	gs := NewGrpcServer(NewServer(is, nil), nil)
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	go gs.Serve(lis)
+	defer gs.Stop()
+	conn, err := grpc.Dial(lis.Addr().String(), grpc.WithInsecure())
+	require.Nil(t, err)
+	defer conn.Close()
+	client := issuerpb.NewIssuerClient(conn)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	res, err := client.GetRecovery(ctx, &issuerpb.GetRecoveryRequest{})
+	require.Nil(t, err)
+	assert.Nil(t, res.Recovery)
+	assert.Equal(t, uint32(0), res.Nonce)
+
+	hash := crypto.EthHash(is.RecoveryMsg(newKOp, res.Nonce))
+	sig, err := ethcrypto.Sign(hash[:], recovererKey)
+	require.Nil(t, err)
+	sig[64] += 27
+	_, err = client.InitRecovery(ctx, &issuerpb.InitRecoveryRequest{NewKop: newKOp[:], Signature: sig[:64]})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	_, err = client.InitRecovery(ctx, &issuerpb.InitRecoveryRequest{NewKop: newKOp[:], Signature: sig})
+	require.Nil(t, err)
+	res, err = client.GetRecovery(ctx, &issuerpb.GetRecoveryRequest{})
+	require.Nil(t, err)
+	assert.Equal(t, newKOp[:], res.Recovery.NewKop)
+	assert.Equal(t, uint32(1), res.Nonce)
+
+	_, err = client.CompleteRecovery(ctx, &issuerpb.CompleteRecoveryRequest{})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+	recovery, err := is.PendingRecovery()
+	require.Nil(t, err)
+	_, err = client.CancelRecovery(ctx, &issuerpb.CancelRecoveryRequest{Signature: make([]byte, 64)})
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+	cancelSig, err := is.SignBinary(issuer.SigPrefixRecoveryCancel, is.CancelRecoveryMsg(recovery))
+	require.Nil(t, err)
+	_, err = client.CancelRecovery(ctx, &issuerpb.CancelRecoveryRequest{Signature: cancelSig[:]})
+	require.Nil(t, err)
+	res, err = client.GetRecovery(ctx, &issuerpb.GetRecoveryRequest{})
+	require.Nil(t, err)
+	assert.Nil(t, res.Recovery)
+}
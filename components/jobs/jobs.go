@@ -0,0 +1,381 @@
+// Package jobs runs long operations, like bulk imports or the compaction of
+// the identity state history, in the background and keeps their status, so
+// that they can be followed by a job ID.  The status of each job is persisted
+// in a storage, and the progress reported by the operation is surfaced as a
+// percent.  The jobs can be canceled through the context passed to the
+// operation.  The status of the jobs is served by Handler:
+//
+//	GET    /jobs       list the jobs
+//	GET    /jobs/<id>  status of a job
+//	DELETE /jobs/<id>  cancel a job
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+// Status is the status of a Job.
+type Status string
+
+const (
+	// StatusRunning is the status of a started job that hasn't finished.
+	StatusRunning Status = "running"
+	// StatusSucceeded is the status of a job that finished without error.
+	StatusSucceeded Status = "succeeded"
+	// StatusFailed is the status of a job that finished with an error, or
+	// that was interrupted by a restart.
+	StatusFailed Status = "failed"
+	// StatusCanceled is the status of a canceled job.
+	StatusCanceled Status = "canceled"
+)
+
+// Finished returns true if the status is final.
+func (s Status) Finished() bool {
+	return s != StatusRunning
+}
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobFinished = errors.New("job already finished")
+)
+
+// PathJobs is the path of the jobs endpoints.
+const PathJobs = "/jobs"
+
+var (
+	dbPrefixJob = []byte("job:")
+)
+
+// errInterrupted is the error of the jobs found running when the Manager is
+// created, which were interrupted by a restart.
+const errInterrupted = "interrupted by a restart"
+
+// ProgressFunc reports the progress of an operation as done units of work
+// out of total.  The long operations of the library accept a
+// func(done, total int) with the same meaning.
+type ProgressFunc func(done, total int)
+
+// Func is an operation run as a job.  It must report its progress with
+// progress, and return as soon as possible with ctx.Err() once ctx is done.
+// The result is stored JSON encoded in the Job.
+type Func func(ctx context.Context, progress ProgressFunc) (interface{}, error)
+
+// Job is the status of a job.
+type Job struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Status Status `json:"status"`
+	// Progress is the percent of the work done, from 0 to 100.
+	Progress  int             `json:"progress"`
+	Done      int             `json:"done"`
+	Total     int             `json:"total"`
+	Result    json.RawMessage `json:"result,omitempty"`
+	Error     string          `json:"error,omitempty"`
+	CreatedTs int64           `json:"createdTs"`
+	UpdatedTs int64           `json:"updatedTs"`
+}
+
+// running is a job run by the Manager.
+type running struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// Manager runs the jobs and stores their status.
+type Manager struct {
+	rw      *sync.RWMutex
+	storage db.Storage
+	clock   clock.Clock
+	running map[string]*running
+}
+
+// New creates a new Manager that stores the status of the jobs in storage.
+// The jobs stored as running, left by a previous process, are marked as
+// failed.
+func New(storage db.Storage, clk clock.Clock) (*Manager, error) {
+	m := &Manager{
+		rw:      &sync.RWMutex{},
+		storage: storage,
+		clock:   clk,
+		running: make(map[string]*running),
+	}
+	jobs, err := m.list()
+	if err != nil {
+		return nil, err
+	}
+	for i := range jobs {
+		job := &jobs[i]
+		if job.Status.Finished() {
+			continue
+		}
+		job.Status = StatusFailed
+		job.Error = errInterrupted
+		job.UpdatedTs = m.clock.Now().Unix()
+		if err := m.store(job); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+func jobKey(id string) []byte {
+	return append(append([]byte{}, dbPrefixJob...), []byte(id)...)
+}
+
+func (m *Manager) store(job *Job) error {
+	tx, err := m.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := db.StoreJSON(tx, jobKey(job.ID), job); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (m *Manager) load(id string) (*Job, error) {
+	v, err := m.storage.Get(jobKey(id))
+	if err == db.ErrNotFound {
+		return nil, ErrJobNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var job Job
+	if err := json.Unmarshal(v, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+func (m *Manager) list() ([]Job, error) {
+	jobs := []Job{}
+	if err := m.storage.WithPrefix(dbPrefixJob).Iterate(func(_, v []byte) (bool, error) {
+		var job Job
+		if err := json.Unmarshal(v, &job); err != nil {
+			return false, err
+		}
+		jobs = append(jobs, job)
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(jobs, func(a, b int) bool { return jobs[a].CreatedTs < jobs[b].CreatedTs })
+	return jobs, nil
+}
+
+// update loads the job id, applies f to it and stores it.
+func (m *Manager) update(id string, f func(job *Job)) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	job, err := m.load(id)
+	if err != nil {
+		return err
+	}
+	f(job)
+	job.UpdatedTs = m.clock.Now().Unix()
+	return m.store(job)
+}
+
+func newJobID() (string, error) {
+	var id [16]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id[:]), nil
+}
+
+// Start runs f in the background as a job of kind, and returns its initial
+// status.
+func (m *Manager) Start(kind string, f Func) (*Job, error) {
+	id, err := newJobID()
+	if err != nil {
+		return nil, err
+	}
+	now := m.clock.Now().Unix()
+	job := &Job{ID: id, Kind: kind, Status: StatusRunning, CreatedTs: now, UpdatedTs: now}
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &running{cancel: cancel, done: make(chan struct{})}
+	m.rw.Lock()
+	if err := m.store(job); err != nil {
+		m.rw.Unlock()
+		cancel()
+		return nil, err
+	}
+	m.running[id] = r
+	m.rw.Unlock()
+	go m.run(ctx, job.ID, f, r)
+	return job, nil
+}
+
+func (m *Manager) run(ctx context.Context, id string, f Func, r *running) {
+	defer close(r.done)
+	defer r.cancel()
+	progress := func(done, total int) {
+		if err := m.update(id, func(job *Job) {
+			job.Done, job.Total = done, total
+			job.Progress = percent(done, total)
+		}); err != nil {
+			log.WithError(err).WithField("job", id).Warn("Unable to store the job progress")
+		}
+	}
+	result, err := f(ctx, throttle(progress))
+	var resultJSON []byte
+	if result != nil {
+		var errJSON error
+		if resultJSON, errJSON = json.Marshal(result); err == nil {
+			err = errJSON
+		}
+	}
+	m.rw.Lock()
+	delete(m.running, id)
+	m.rw.Unlock()
+	if err := m.update(id, func(job *Job) {
+		switch {
+		case ctx.Err() == context.Canceled:
+			// The result of a canceled job, if any, is the
+			// partial result of the operation.
+			job.Status = StatusCanceled
+			job.Result = resultJSON
+		case err != nil:
+			job.Status = StatusFailed
+			job.Error = err.Error()
+		default:
+			job.Status = StatusSucceeded
+			job.Progress = 100
+			job.Done = job.Total
+			job.Result = resultJSON
+		}
+	}); err != nil {
+		log.WithError(err).WithField("job", id).Error("Unable to store the job status")
+	}
+}
+
+func percent(done, total int) int {
+	if total <= 0 {
+		return 0
+	}
+	return done * 100 / total
+}
+
+// throttle returns a ProgressFunc that calls progress only when the percent
+// of done out of total changes, to bound the writes of the operations with
+// many units of work.
+func throttle(progress ProgressFunc) ProgressFunc {
+	last := -1
+	return func(done, total int) {
+		if p := percent(done, total); p != last {
+			last = p
+			progress(done, total)
+		}
+	}
+}
+
+// Job returns the status of the job id.
+func (m *Manager) Job(id string) (*Job, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	return m.load(id)
+}
+
+// Jobs returns the status of all the jobs, sorted by creation.
+func (m *Manager) Jobs() ([]Job, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	return m.list()
+}
+
+// Cancel cancels the job id.  The job is marked as canceled once its
+// operation returns.
+func (m *Manager) Cancel(id string) error {
+	m.rw.RLock()
+	r, ok := m.running[id]
+	m.rw.RUnlock()
+	if !ok {
+		if _, err := m.Job(id); err != nil {
+			return err
+		}
+		return ErrJobFinished
+	}
+	r.cancel()
+	return nil
+}
+
+// Wait blocks until the job id is finished or ctx is done, and returns its
+// status.
+func (m *Manager) Wait(ctx context.Context, id string) (*Job, error) {
+	m.rw.RLock()
+	r, ok := m.running[id]
+	m.rw.RUnlock()
+	if ok {
+		select {
+		case <-r.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	return m.Job(id)
+}
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// Handler returns an http.Handler that serves the jobs endpoints of m.  The
+// responses are the JSON Job, or list of Jobs.
+func Handler(m *Manager) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathJobs, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		jobs, err := m.Jobs()
+		if err != nil {
+			log.WithError(err).Error("Jobs")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, jobs)
+	})
+	mux.HandleFunc(PathJobs+"/", func(w http.ResponseWriter, req *http.Request) {
+		id := strings.TrimPrefix(req.URL.Path, PathJobs+"/")
+		var err error
+		switch req.Method {
+		case http.MethodGet:
+		case http.MethodDelete:
+			err = m.Cancel(id)
+		default:
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var job *Job
+		if err == nil {
+			job, err = m.Job(id)
+		}
+		switch err {
+		case nil:
+			httpjson.Write(w, http.StatusOK, job)
+		case ErrJobNotFound:
+			httpjson.WriteError(w, http.StatusNotFound, err.Error())
+		case ErrJobFinished:
+			httpjson.WriteError(w, http.StatusConflict, err.Error())
+		default:
+			log.WithError(err).Error("Job")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+		}
+	})
+	return mux
+}
@@ -0,0 +1,145 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	m, err := New(storage, clock.Real)
+	require.Nil(t, err)
+
+	job, err := m.Start("count", func(ctx context.Context, progress ProgressFunc) (interface{}, error) {
+		for i := 0; i <= 10; i++ {
+			progress(i, 10)
+		}
+		return map[string]int{"count": 10}, nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, StatusRunning, job.Status)
+	job, err = m.Wait(context.Background(), job.ID)
+	require.Nil(t, err)
+	assert.Equal(t, StatusSucceeded, job.Status)
+	assert.Equal(t, 100, job.Progress)
+	assert.Equal(t, 10, job.Done)
+	assert.Equal(t, `{"count":10}`, string(job.Result))
+	assert.Equal(t, ErrJobFinished, m.Cancel(job.ID))
+
+	failed, err := m.Start("fail", func(ctx context.Context, progress ProgressFunc) (interface{}, error) {
+		progress(1, 4)
+		return nil, errors.New("boom")
+	})
+	require.Nil(t, err)
+	failed, err = m.Wait(context.Background(), failed.ID)
+	require.Nil(t, err)
+	assert.Equal(t, StatusFailed, failed.Status)
+	assert.Equal(t, "boom", failed.Error)
+	assert.Equal(t, 25, failed.Progress)
+
+	started := make(chan struct{})
+	canceled, err := m.Start("wait", func(ctx context.Context, progress ProgressFunc) (interface{}, error) {
+		close(started)
+		<-ctx.Done()
+		return "partial", ctx.Err()
+	})
+	require.Nil(t, err)
+	<-started
+	require.Nil(t, m.Cancel(canceled.ID))
+	canceled, err = m.Wait(context.Background(), canceled.ID)
+	require.Nil(t, err)
+	assert.Equal(t, StatusCanceled, canceled.Status)
+	assert.Equal(t, `"partial"`, string(canceled.Result))
+
+	_, err = m.Job("unknown")
+	assert.Equal(t, ErrJobNotFound, err)
+	jobs, err := m.Jobs()
+	require.Nil(t, err)
+	assert.Equal(t, 3, len(jobs))
+}
+
+func TestManagerRestart(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	m, err := New(storage, clock.Real)
+	require.Nil(t, err)
+	block := make(chan struct{})
+	defer close(block)
+	job, err := m.Start("block", func(ctx context.Context, progress ProgressFunc) (interface{}, error) {
+		<-block
+		return nil, nil
+	})
+	require.Nil(t, err)
+
+	// A new Manager on the same storage marks the running job as failed
+	m2, err := New(storage, clock.Real)
+	require.Nil(t, err)
+	job, err = m2.Job(job.ID)
+	require.Nil(t, err)
+	assert.Equal(t, StatusFailed, job.Status)
+	assert.Equal(t, errInterrupted, job.Error)
+}
+
+func TestHandler(t *testing.T) {
+	m, err := New(db.NewMemoryStorage(), clock.Real)
+	require.Nil(t, err)
+	server := httptest.NewServer(Handler(m))
+	defer server.Close()
+
+	started := make(chan struct{})
+	job, err := m.Start("wait", func(ctx context.Context, progress ProgressFunc) (interface{}, error) {
+		progress(1, 2)
+		close(started)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	require.Nil(t, err)
+	<-started
+
+	do := func(method, path string) (int, *Job) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		require.Nil(t, err)
+		res, err := http.DefaultClient.Do(req)
+		require.Nil(t, err)
+		defer res.Body.Close()
+		var job Job
+		if res.StatusCode == http.StatusOK {
+			require.Nil(t, json.NewDecoder(res.Body).Decode(&job))
+		}
+		return res.StatusCode, &job
+	}
+	status, got := do(http.MethodGet, PathJobs+"/"+job.ID)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusRunning, got.Status)
+	assert.Equal(t, 50, got.Progress)
+
+	status, _ = do(http.MethodDelete, PathJobs+"/"+job.ID)
+	require.Equal(t, http.StatusOK, status)
+	_, err = m.Wait(context.Background(), job.ID)
+	require.Nil(t, err)
+	status, got = do(http.MethodGet, PathJobs+"/"+job.ID)
+	require.Equal(t, http.StatusOK, status)
+	assert.Equal(t, StatusCanceled, got.Status)
+
+	status, _ = do(http.MethodDelete, PathJobs+"/"+job.ID)
+	assert.Equal(t, http.StatusConflict, status)
+	status, _ = do(http.MethodGet, PathJobs+"/unknown")
+	assert.Equal(t, http.StatusNotFound, status)
+
+	res, err := http.Get(server.URL + PathJobs)
+	require.Nil(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var jobs []Job
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&jobs))
+	require.Equal(t, 1, len(jobs))
+	assert.Equal(t, job.ID, jobs[0].ID)
+}
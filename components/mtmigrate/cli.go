@@ -0,0 +1,78 @@
+// Package mtmigrate provides the CLI subcommand that migrates the merkle
+// trees in a storage to a NodeEncoding in place (see
+// merkletree.MerkleTree.MigrateNodeEncoding).  The trees of an issuer are at
+// the prefixes "treeclaims:", "treerevocation:" and "treeroots:" of its
+// storage.
+package mtmigrate
+
+import (
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// LoadStorageFunc opens the storage with the merkle trees used by the CLI
+// subcommands from the command context.  The storage must not be used by
+// other processes during the migration.
+type LoadStorageFunc func(c *cli.Context) (db.Storage, error)
+
+// Commands returns the merkle tree CLI subcommands, to be registered in a
+// cli.App.
+func Commands(loadStorage LoadStorageFunc) []cli.Command {
+	return []cli.Command{
+		{
+			Name:  "merkletree",
+			Usage: "manage the merkle trees of the storage",
+			Subcommands: []cli.Command{
+				{
+					Name:  "migrate",
+					Usage: "rewrite the nodes of the merkle trees with a node encoding",
+					Flags: []cli.Flag{
+						cli.StringSliceFlag{
+							Name:  "prefix",
+							Usage: "migrate the tree at the storage `PREFIX` (repeatable)",
+						},
+						cli.IntFlag{
+							Name:  "encoding",
+							Usage: "node encoding `VERSION`",
+							Value: int(merkletree.NodeEncodingLast),
+						},
+					},
+					Action: cmdMigrate(loadStorage),
+				},
+			},
+		},
+	}
+}
+
+func cmdMigrate(loadStorage LoadStorageFunc) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		prefixes := c.StringSlice("prefix")
+		if len(prefixes) == 0 {
+			return fmt.Errorf("at least one prefix is required")
+		}
+		enc := c.Int("encoding")
+		if enc < 0 || enc > int(merkletree.NodeEncodingLast) {
+			return merkletree.ErrNodeEncodingUnknown
+		}
+		storage, err := loadStorage(c)
+		if err != nil {
+			return err
+		}
+		for _, prefix := range prefixes {
+			mt, err := merkletree.NewMerkleTree(storage.WithPrefix([]byte(prefix)), 0)
+			if err != nil {
+				return fmt.Errorf("open tree %v: %w", prefix, err)
+			}
+			from := mt.NodeEncoding()
+			n, err := mt.MigrateNodeEncoding(merkletree.NodeEncoding(enc))
+			if err != nil {
+				return fmt.Errorf("migrate tree %v: %w", prefix, err)
+			}
+			fmt.Fprintf(c.App.Writer, "%v: migrated %v nodes from encoding %v to %v\n", prefix, n, from, enc)
+		}
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package mtmigrate
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/urfave/cli.v1"
+)
+
+func TestMigrate(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	mt, err := merkletree.NewMerkleTree(storage.WithPrefix([]byte("treeclaims:")), 140)
+	require.Nil(t, err)
+	_, err = mt.MigrateNodeEncoding(merkletree.NodeEncodingV0)
+	require.Nil(t, err)
+	e := merkletree.NewEntryFromInts(1, 0, 0, 0, 0, 0, 0, 0)
+	require.Nil(t, mt.AddEntry(&e))
+
+	app := cli.NewApp()
+	var out bytes.Buffer
+	app.Writer = &out
+	app.Commands = Commands(func(c *cli.Context) (db.Storage, error) { return storage, nil })
+
+	assert.NotNil(t, app.Run([]string{"app", "merkletree", "migrate"}))
+	assert.NotNil(t, app.Run([]string{"app", "merkletree", "migrate", "--prefix", "treeclaims:", "--encoding", "9"}))
+	require.Nil(t, app.Run([]string{"app", "merkletree", "migrate", "--prefix", "treeclaims:"}))
+	assert.Equal(t, "treeclaims:: migrated 1 nodes from encoding 0 to 1\n", out.String())
+
+	mt, err = merkletree.NewMerkleTree(storage.WithPrefix([]byte("treeclaims:")), 0)
+	require.Nil(t, err)
+	assert.Equal(t, merkletree.NodeEncodingV1, mt.NodeEncoding())
+	_, err = mt.GetDataByIndex(e.HIndex())
+	assert.Nil(t, err)
+}
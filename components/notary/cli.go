@@ -0,0 +1,152 @@
+package notary
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core"
+	"gopkg.in/urfave/cli.v1"
+)
+
+// LoadNotaryFunc builds the Notary used by the CLI subcommands from the
+// command context.
+type LoadNotaryFunc func(c *cli.Context) (*Notary, error)
+
+// LoadVerifierFunc builds the Verifier used by the CLI subcommands from the
+// command context.
+type LoadVerifierFunc func(c *cli.Context) (*verifier.Verifier, error)
+
+// Commands returns the notary CLI subcommands, to be registered in a cli.App.
+// loadNotary is used by the subcommands that notarize documents and
+// loadVerifier by the subcommand that verifies bundles.
+func Commands(loadNotary LoadNotaryFunc, loadVerifier LoadVerifierFunc) []cli.Command {
+	return []cli.Command{
+		{
+			Name:  "notary",
+			Usage: "notarize documents and verify notarizations",
+			Subcommands: []cli.Command{
+				{
+					Name:      "notarize",
+					Usage:     "issue a claim notarizing a document",
+					ArgsUsage: "FILE",
+					Action:    withNotary(loadNotary, cmdNotarize),
+				},
+				{
+					Name:      "bundle",
+					Usage:     "write the verification bundle of a notarized document",
+					ArgsUsage: "FILE BUNDLE",
+					Action:    withNotary(loadNotary, cmdBundle),
+				},
+				{
+					Name:      "verify",
+					Usage:     "verify the bundle of a notarized document",
+					ArgsUsage: "FILE BUNDLE",
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:  "id",
+							Usage: "require the notarization to be issued by `ID`",
+						},
+					},
+					Action: cmdVerify(loadVerifier),
+				},
+			},
+		},
+	}
+}
+
+func withNotary(loadNotary LoadNotaryFunc, f func(c *cli.Context, n *Notary) error) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		n, err := loadNotary(c)
+		if err != nil {
+			return err
+		}
+		return f(c, n)
+	}
+}
+
+func checkArgs(c *cli.Context, n int) error {
+	if c.NArg() != n {
+		return fmt.Errorf("expected %v arguments, got %v", n, c.NArg())
+	}
+	return nil
+}
+
+func cmdNotarize(c *cli.Context, n *Notary) error {
+	if err := checkArgs(c, 1); err != nil {
+		return err
+	}
+	doc, err := os.Open(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	claim, err := n.Notarize(doc)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.App.Writer, "%v\n", claim.Entry().HIndex().Hex())
+	return nil
+}
+
+func cmdBundle(c *cli.Context, n *Notary) error {
+	if err := checkArgs(c, 2); err != nil {
+		return err
+	}
+	doc, err := os.Open(c.Args().Get(0))
+	if err != nil {
+		return err
+	}
+	defer doc.Close()
+	bundle, err := n.Bundle(doc)
+	if err != nil {
+		return err
+	}
+	bundleJSON, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Args().Get(1), bundleJSON, 0644)
+}
+
+func cmdVerify(loadVerifier LoadVerifierFunc) func(c *cli.Context) error {
+	return func(c *cli.Context) error {
+		if err := checkArgs(c, 2); err != nil {
+			return err
+		}
+		var id *core.ID
+		if c.String("id") != "" {
+			_id, err := core.IDFromString(c.String("id"))
+			if err != nil {
+				return err
+			}
+			id = &_id
+		}
+		bundleJSON, err := ioutil.ReadFile(c.Args().Get(1))
+		if err != nil {
+			return err
+		}
+		var bundle Bundle
+		if err := json.Unmarshal(bundleJSON, &bundle); err != nil {
+			return err
+		}
+		v, err := loadVerifier(c)
+		if err != nil {
+			return err
+		}
+		doc, err := os.Open(c.Args().Get(0))
+		if err != nil {
+			return err
+		}
+		defer doc.Close()
+		if err := VerifyBundle(v, id, doc, &bundle); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.App.Writer, "notarized by %v at %v\n", bundle.Credential.Id,
+			bundle.Timestamp().UTC().Format(time.RFC3339))
+		return nil
+	}
+}
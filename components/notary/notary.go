@@ -0,0 +1,143 @@
+package notary
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	ErrDocumentHashMismatch = fmt.Errorf("the document hash doesn't match the bundle")
+	ErrInvalidClaim         = fmt.Errorf("the bundle claim is not a notarization of the document")
+	ErrIdMismatch           = fmt.Errorf("the bundle was not issued by the expected identity")
+)
+
+// Bundle is the verification bundle of a notarized document: the existence
+// credential of the ClaimLinkObjectIdentity of the document, which contains
+// the identity state data where the claim was published.
+type Bundle struct {
+	// DocumentHash is the hash of the document, as returned by
+	// HashDocument.
+	DocumentHash merkletree.Hash            `json:"documentHash"`
+	Credential   *proof.CredentialExistence `json:"credential"`
+}
+
+// Timestamp returns the time of the block where the identity state
+// containing the notarization was published.
+func (b *Bundle) Timestamp() time.Time {
+	return time.Unix(b.Credential.IdenStateData.BlockTs, 0)
+}
+
+// HashDocument returns the sha256 hash of the document mapped into the
+// finite field by clearing its most significant byte, so that it can be used
+// as the ObjectHash of a ClaimLinkObjectIdentity.
+func HashDocument(doc io.Reader) (merkletree.Hash, error) {
+	var h merkletree.Hash
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, doc); err != nil {
+		return h, err
+	}
+	copy(h[:], hasher.Sum(nil))
+	h[len(h)-1] = 0
+	return h, nil
+}
+
+// Notary notarizes documents by issuing claims that link their hashes to the
+// identity of an Issuer.
+type Notary struct {
+	issuer *issuer.Issuer
+}
+
+// New creates a new Notary that issues the notarization claims with is.
+func New(is *issuer.Issuer) *Notary {
+	return &Notary{issuer: is}
+}
+
+// claimDocument returns the ClaimLinkObjectIdentity that notarizes a
+// document with hash docHash by the identity id.
+func claimDocument(id *core.ID, docHash merkletree.Hash, revocationNonce uint32) (*claims.ClaimLinkObjectIdentity, error) {
+	return claims.NewClaimLinkObjectIdentity(claims.ObjectTypeDocument, 0, *id,
+		docHash, [256 / 8]byte{}, revocationNonce)
+}
+
+// Notarize issues a ClaimLinkObjectIdentity of the document.  The
+// notarization can only be proved (see Bundle) once the Issuer has published
+// a new state.
+func (n *Notary) Notarize(doc io.Reader) (*claims.ClaimLinkObjectIdentity, error) {
+	docHash, err := HashDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := n.issuer.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return claimDocument(n.issuer.ID(), docHash, revocationNonce)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim.(*claims.ClaimLinkObjectIdentity), nil
+}
+
+// Bundle returns the verification bundle of a notarized document.  It fails
+// with issuer.ErrClaimNotFoundStateOnChain if the notarization has not been
+// published yet.
+func (n *Notary) Bundle(doc io.Reader) (*Bundle, error) {
+	docHash, err := HashDocument(doc)
+	if err != nil {
+		return nil, err
+	}
+	claimIndex, err := claimDocument(n.issuer.ID(), docHash, 0)
+	if err != nil {
+		return nil, err
+	}
+	// The revocation nonce is not part of the index, so we get the
+	// issued claim to obtain it.
+	entry, err := n.issuer.ClaimByHIndex(claimIndex.Entry().HIndex())
+	if err != nil {
+		return nil, err
+	}
+	claim, err := claims.NewClaimFromEntry(entry)
+	if err != nil {
+		return nil, err
+	}
+	cred, err := n.issuer.GenCredentialExistence(claim)
+	if err != nil {
+		return nil, err
+	}
+	return &Bundle{DocumentHash: docHash, Credential: cred}, nil
+}
+
+// VerifyBundle verifies that the bundle proves that the document was
+// notarized.  If id is not nil, the notarization must be issued by id.
+func VerifyBundle(v *verifier.Verifier, id *core.ID, doc io.Reader, b *Bundle) error {
+	docHash, err := HashDocument(doc)
+	if err != nil {
+		return err
+	}
+	if !docHash.Equals(&b.DocumentHash) {
+		return ErrDocumentHashMismatch
+	}
+	if b.Credential == nil || b.Credential.Id == nil || b.Credential.Claim == nil {
+		return ErrInvalidClaim
+	}
+	if id != nil && !b.Credential.Id.Equal(id) {
+		return ErrIdMismatch
+	}
+	claim, err := claims.NewClaimFromEntry(b.Credential.Claim)
+	if err != nil {
+		return err
+	}
+	claimDoc, ok := claim.(*claims.ClaimLinkObjectIdentity)
+	if !ok || claimDoc.ObjectType != claims.ObjectTypeDocument ||
+		claimDoc.ObjectHash != [256 / 8]byte(docHash) || !claimDoc.Id.Equal(b.Credential.Id) {
+		return ErrInvalidClaim
+	}
+	return v.VerifyCredentialExistence(b.Credential)
+}
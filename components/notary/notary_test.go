@@ -0,0 +1,119 @@
+package notary
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/urfave/cli.v1"
+)
+
+var document = []byte("The quick brown fox jumps over the lazy dog")
+
+func publishState(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, is *issuer.Issuer) {
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	idenPubOnChain.On("InitState", is.ID(), mock.Anything, mock.Anything, []byte(nil), []byte(nil), mock.Anything).
+		Return(ethTx, nil).Once()
+	res, err := is.PublishState()
+	require.Nil(t, err)
+	require.Equal(t, issuer.PublishStateSubmitted, res.Status)
+	idenState, _ := is.State()
+	idenStateData := &proof.IdenStateData{IdenState: idenState, BlockN: 42, BlockTs: 1500000000}
+	idenPubOnChain.On("GetState", is.ID()).Return(idenStateData, nil)
+	idenPubOnChain.On("GetStateByBlock", is.ID(), uint64(42)).Return(idenStateData, nil)
+	require.Nil(t, is.SyncIdenStatePublic())
+}
+
+func TestHashDocument(t *testing.T) {
+	h, err := HashDocument(bytes.NewReader(document))
+	require.Nil(t, err)
+	assert.Equal(t, byte(0), h[len(h)-1])
+	_, err = claims.NewClaimLinkObjectIdentity(claims.ObjectTypeDocument, 0, [31]byte{}, h, [32]byte{}, 0)
+	assert.Nil(t, err)
+}
+
+func TestNotary(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	demo, err := issuer.NewDemoOnChain([]byte("notary"), idenPubOnChain)
+	require.Nil(t, err)
+	is := demo.Issuer
+	n := New(is)
+
+	claim, err := n.Notarize(bytes.NewReader(document))
+	require.Nil(t, err)
+	assert.Equal(t, *is.ID(), claim.Id)
+	_, err = n.Notarize(bytes.NewReader(document))
+	assert.Equal(t, issuer.ErrClaimAlreadyIssued, err)
+
+	_, err = n.Bundle(bytes.NewReader(document))
+	assert.Equal(t, issuer.ErrIdenStateOnChainZero, err)
+	publishState(t, idenPubOnChain, is)
+
+	bundle, err := n.Bundle(bytes.NewReader(document))
+	require.Nil(t, err)
+	assert.Equal(t, int64(1500000000), bundle.Timestamp().Unix())
+
+	// The bundle can be verified after a JSON round trip
+	bundleJSON, err := json.Marshal(bundle)
+	require.Nil(t, err)
+	var bundle2 Bundle
+	require.Nil(t, json.Unmarshal(bundleJSON, &bundle2))
+
+	v := verifier.New(idenPubOnChain)
+	assert.Nil(t, VerifyBundle(v, is.ID(), bytes.NewReader(document), &bundle2))
+	assert.Nil(t, VerifyBundle(v, nil, bytes.NewReader(document), &bundle2))
+	assert.Equal(t, ErrDocumentHashMismatch,
+		VerifyBundle(v, nil, bytes.NewReader([]byte("other document")), &bundle2))
+	otherId := *is.ID()
+	otherId[4] ^= 0xff
+	assert.Equal(t, ErrIdMismatch, VerifyBundle(v, &otherId, bytes.NewReader(document), &bundle2))
+
+	// A document that is not notarized
+	_, err = n.Bundle(bytes.NewReader([]byte("other document")))
+	assert.Equal(t, merkletree.ErrEntryIndexNotFound, err)
+}
+
+func TestCommands(t *testing.T) {
+	dir, err := ioutil.TempDir("", "notary")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	docPath := filepath.Join(dir, "document.txt")
+	bundlePath := filepath.Join(dir, "bundle.json")
+	require.Nil(t, ioutil.WriteFile(docPath, document, 0644))
+
+	idenPubOnChain := idenpubonchain.New()
+	demo, err := issuer.NewDemoOnChain([]byte("notary"), idenPubOnChain)
+	require.Nil(t, err)
+	is := demo.Issuer
+	app := cli.NewApp()
+	var out bytes.Buffer
+	app.Writer = &out
+	app.Commands = Commands(
+		func(c *cli.Context) (*Notary, error) { return New(is), nil },
+		func(c *cli.Context) (*verifier.Verifier, error) { return verifier.New(idenPubOnChain), nil },
+	)
+
+	require.Nil(t, app.Run([]string{"app", "notary", "notarize", docPath}))
+	publishState(t, idenPubOnChain, is)
+	require.Nil(t, app.Run([]string{"app", "notary", "bundle", docPath, bundlePath}))
+	out.Reset()
+	require.Nil(t, app.Run([]string{"app", "notary", "verify", "--id", is.ID().String(), docPath, bundlePath}))
+	assert.True(t, strings.HasPrefix(out.String(), "notarized by "+is.ID().String()))
+	assert.NotNil(t, app.Run([]string{"app", "notary", "verify", bundlePath, bundlePath}))
+}
@@ -0,0 +1,528 @@
+package notifier
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/utils/retry"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventClaimIssued    = "claim.issued"
+	EventClaimRevoked   = "claim.revoked"
+	EventStatePublished = "state.published"
+	EventStateSynced    = "state.synced"
+)
+
+// HeaderSignature is the HTTP header of the webhook request that contains
+// the HMAC-SHA256 of the body, hex encoded.
+const HeaderSignature = "X-Iden3-Signature"
+
+var (
+	ErrNotStarted = fmt.Errorf("notifier not started")
+)
+
+var (
+	dbPrefixDelivery = []byte("delivery:")
+	dbKeyDeliveryIdx = []byte("deliveryidx")
+)
+
+// ConfigDefault is a default configuration for the Notifier.  URLs and
+// Secret must be set.
+var ConfigDefault = Config{
+	MaxAttempts: 5,
+	BackoffMin:  1 * time.Second,
+	BackoffMax:  1 * time.Minute,
+	Jitter:      0.2,
+	Timeout:     10 * time.Second,
+	QueueLen:    64,
+	Retention:   24 * time.Hour,
+}
+
+// Config allows configuring the Notifier.
+type Config struct {
+	// URLs are the webhook endpoints where every event is delivered.
+	URLs []string
+	// Secret is the key used to sign the body of the requests.
+	Secret []byte
+	// MaxAttempts is the maximum number of delivery attempts of an event
+	// to an URL.  The deliveries rejected by the receiver with a 4xx
+	// status code (except 408 and 429) are not retried.
+	MaxAttempts int
+	// BackoffMin is the waiting time after the first failed attempt.  It's
+	// doubled after each failed attempt up to BackoffMax.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+	// Jitter is the fraction of each waiting time that is randomized.  See
+	// retry.Policy.
+	Jitter float64
+	// Timeout of each HTTP request.
+	Timeout time.Duration
+	// QueueLen is the number of deliveries that can be queued for each
+	// URL.  When the queue of an URL is full, Notify doesn't block: the
+	// deliveries stay pending in the delivery log, from where the worker
+	// of the URL picks them up once its queue is drained.
+	QueueLen int
+	// Retention is how long the finished deliveries (delivered, rejected
+	// or given up after MaxAttempts) and the deliveries to URLs that are
+	// no longer configured are kept in the delivery log after their last
+	// attempt, to be inspected with Deliveries.  They are pruned by
+	// Notify at most once every Retention.  0 deletes each delivery as
+	// soon as it's finished.
+	Retention time.Duration
+}
+
+// Event is the JSON body sent to the webhook endpoints.
+type Event struct {
+	Type      string      `json:"type"`
+	Timestamp int64       `json:"timestamp"`
+	Data      interface{} `json:"data"`
+}
+
+// Delivery is the record of the delivery of an event to an URL stored in the
+// delivery log.
+type Delivery struct {
+	Idx       uint32
+	URL       string
+	Body      json.RawMessage
+	Attempts  int
+	Delivered bool
+	// Rejected is set when the receiver rejects the delivery, which is not
+	// retried.
+	Rejected      bool
+	LastError     string
+	LastAttemptTs int64
+}
+
+// Notifier delivers signed JSON webhooks of the identity events to the
+// configured URLs, retrying with exponential backoff the failed deliveries.
+// Every delivery is recorded in the storage, so that the pending ones are
+// resumed after a restart.  Each URL has its own worker, so that an URL that
+// is down doesn't delay the deliveries to the others.  The pending
+// deliveries to URLs that are no longer configured are not resumed.  The
+// finished deliveries are pruned from the delivery log after Retention.
+type Notifier struct {
+	rw      *sync.RWMutex
+	storage db.Storage
+	cfg     Config
+	client  *http.Client
+	workers map[string]*urlWorker
+	stop    chan struct{}
+	wg      *sync.WaitGroup
+	started bool
+	// lastPrune is the time of the last pruning of the delivery log.
+	lastPrune time.Time
+}
+
+// urlWorker is the queue of the deliveries to an URL.
+type urlWorker struct {
+	url   string
+	queue chan uint32
+	// rescan is signaled when a delivery couldn't be queued, so that the
+	// worker looks for the pending deliveries in the delivery log.
+	rescan chan struct{}
+}
+
+// New creates a new Notifier that stores its delivery log in storage.
+func New(cfg Config, storage db.Storage) *Notifier {
+	workers := make(map[string]*urlWorker)
+	for _, url := range cfg.URLs {
+		workers[url] = &urlWorker{
+			url:    url,
+			queue:  make(chan uint32, cfg.QueueLen),
+			rescan: make(chan struct{}, 1),
+		}
+	}
+	return &Notifier{
+		rw:      &sync.RWMutex{},
+		storage: storage,
+		cfg:     cfg,
+		client:  &http.Client{Timeout: cfg.Timeout},
+		workers: workers,
+		wg:      &sync.WaitGroup{},
+	}
+}
+
+// Signature returns the hex encoded HMAC-SHA256 of body with the key secret,
+// as sent in the HeaderSignature header.
+func Signature(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifySignature checks that sig is the signature of body with the key
+// secret.  It can be used by the webhook receivers.
+func VerifySignature(secret, body []byte, sig string) bool {
+	return hmac.Equal([]byte(Signature(secret, body)), []byte(sig))
+}
+
+// Start starts the delivery workers, which resume the pending deliveries
+// found in the delivery log.
+func (n *Notifier) Start() error {
+	n.rw.Lock()
+	defer n.rw.Unlock()
+	if n.started {
+		return nil
+	}
+	n.stop = make(chan struct{})
+	n.started = true
+	for _, w := range n.workers {
+		w.signalRescan()
+		n.wg.Add(1)
+		go n.run(w, n.stop)
+	}
+	return nil
+}
+
+// Stop stops the delivery workers and waits for them to finish.  The queued
+// deliveries remain pending in the delivery log.
+func (n *Notifier) Stop() {
+	n.rw.Lock()
+	if !n.started {
+		n.rw.Unlock()
+		return
+	}
+	n.started = false
+	close(n.stop)
+	n.rw.Unlock()
+	n.wg.Wait()
+}
+
+// Notify records the delivery of an event of type typ with data to each
+// configured URL and queues it.  It doesn't wait for the deliveries, and it
+// doesn't block when a queue is full, as it's called from the Issuer hooks.
+func (n *Notifier) Notify(typ string, data interface{}) error {
+	body, err := json.Marshal(Event{Type: typ, Timestamp: time.Now().Unix(), Data: data})
+	if err != nil {
+		return err
+	}
+	idxs, err := n.record(body)
+	if err != nil {
+		return err
+	}
+	for i, idx := range idxs {
+		w := n.workers[n.cfg.URLs[i]]
+		select {
+		case w.queue <- idx:
+		default:
+			// The delivery is in the delivery log.
+			w.signalRescan()
+		}
+	}
+	return nil
+}
+
+func (w *urlWorker) signalRescan() {
+	select {
+	case w.rescan <- struct{}{}:
+	default:
+	}
+}
+
+// record stores in the delivery log a new delivery of body for each
+// configured URL.
+func (n *Notifier) record(body []byte) ([]uint32, error) {
+	n.rw.Lock()
+	defer n.rw.Unlock()
+	if !n.started {
+		return nil, ErrNotStarted
+	}
+	if now := time.Now(); n.cfg.Retention > 0 && now.Sub(n.lastPrune) >= n.cfg.Retention {
+		if _, err := n.prune(now); err != nil {
+			return nil, err
+		}
+	}
+	tx, err := n.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	idxs := make([]uint32, len(n.cfg.URLs))
+	deliveryIdx := db.NewStorageValue(dbKeyDeliveryIdx)
+	for i, url := range n.cfg.URLs {
+		idx, err := deliveryIdx.Get(tx)
+		if err != nil && err != db.ErrNotFound {
+			return nil, err
+		}
+		deliveryIdx.Set(tx, idx+1)
+		if err := storeDelivery(tx, &Delivery{Idx: idx, URL: url, Body: body}); err != nil {
+			return nil, err
+		}
+		idxs[i] = idx
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return idxs, nil
+}
+
+// Hooks returns the issuer Hooks that notify the Issuer events.
+func (n *Notifier) Hooks() *issuer.Hooks {
+	notify := func(typ string, data interface{}) {
+		if err := n.Notify(typ, data); err != nil {
+			log.WithError(err).WithField("event", typ).Error("Notifier: unable to notify event")
+		}
+	}
+	return &issuer.Hooks{
+		OnClaimIssued:    func(ev *issuer.ClaimIssuedEvent) { notify(EventClaimIssued, ev) },
+		OnClaimRevoked:   func(ev *issuer.ClaimRevokedEvent) { notify(EventClaimRevoked, ev) },
+		OnStatePublished: func(ev *issuer.StatePublishedEvent) { notify(EventStatePublished, ev) },
+		OnStateSynced:    func(ev *issuer.StateSyncedEvent) { notify(EventStateSynced, ev) },
+	}
+}
+
+// Deliveries returns all the deliveries recorded in the delivery log.
+func (n *Notifier) Deliveries() ([]Delivery, error) {
+	n.rw.RLock()
+	defer n.rw.RUnlock()
+	return n.deliveries()
+}
+
+func (n *Notifier) deliveries() ([]Delivery, error) {
+	deliveries := []Delivery{}
+	err := n.storage.WithPrefix(dbPrefixDelivery).Iterate(func(_, v []byte) (bool, error) {
+		var d Delivery
+		if err := json.Unmarshal(v, &d); err != nil {
+			return false, err
+		}
+		deliveries = append(deliveries, d)
+		return true, nil
+	})
+	return deliveries, err
+}
+
+// pending returns true if d must be attempted again.
+func (d *Delivery) pending(maxAttempts int) bool {
+	return !d.Delivered && !d.Rejected && d.Attempts < maxAttempts
+}
+
+// prune deletes from the delivery log the deliveries that are finished, or
+// to URLs that are no longer configured, whose last attempt is older than
+// the Retention at now, and returns how many were deleted.  The caller must
+// hold the write lock.
+func (n *Notifier) prune(now time.Time) (int, error) {
+	n.lastPrune = now
+	deliveries, err := n.deliveries()
+	if err != nil {
+		return 0, err
+	}
+	var expired []uint32
+	for _, d := range deliveries {
+		_, configured := n.workers[d.URL]
+		if configured && d.pending(n.cfg.MaxAttempts) {
+			continue
+		}
+		if now.Sub(time.Unix(d.LastAttemptTs, 0)) >= n.cfg.Retention {
+			expired = append(expired, d.Idx)
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	tx, err := n.storage.NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	for _, idx := range expired {
+		tx.Delete(deliveryKey(idx))
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+func deliveryKey(idx uint32) []byte {
+	var idxBytes [4]byte
+	binary.BigEndian.PutUint32(idxBytes[:], idx)
+	return append(append([]byte{}, dbPrefixDelivery...), idxBytes[:]...)
+}
+
+func storeDelivery(tx db.Tx, d *Delivery) error {
+	return db.StoreJSON(tx, deliveryKey(d.Idx), d)
+}
+
+func (n *Notifier) loadDelivery(idx uint32) (*Delivery, error) {
+	n.rw.RLock()
+	defer n.rw.RUnlock()
+	var d Delivery
+	v, err := n.storage.Get(deliveryKey(idx))
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(v, &d); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// updateDelivery stores d in the delivery log, or deletes it if it's
+// finished and there is no Retention.
+func (n *Notifier) updateDelivery(d *Delivery) error {
+	n.rw.Lock()
+	defer n.rw.Unlock()
+	tx, err := n.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if n.cfg.Retention == 0 && !d.pending(n.cfg.MaxAttempts) {
+		tx.Delete(deliveryKey(d.Idx))
+	} else if err := storeDelivery(tx, d); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// run delivers the queued deliveries of w in order, and the pending ones of
+// the delivery log when a rescan is signaled and the queue is empty.
+func (n *Notifier) run(w *urlWorker, stop chan struct{}) {
+	defer n.wg.Done()
+	deliver := func(idx uint32) {
+		if err := n.deliver(idx, stop); err != nil {
+			log.WithError(err).WithField("delivery", idx).Error("Notifier: delivery failed")
+		}
+	}
+	for {
+		select {
+		case idx := <-w.queue:
+			deliver(idx)
+			continue
+		case <-stop:
+			return
+		default:
+		}
+		select {
+		case idx := <-w.queue:
+			deliver(idx)
+		case <-w.rescan:
+			idxs, err := n.pending(w.url)
+			if err != nil {
+				log.WithError(err).WithField("url", w.url).Error("Notifier: unable to read the delivery log")
+			}
+			for _, idx := range idxs {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				deliver(idx)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
+
+// pending returns the indexes of the pending deliveries to url in the
+// delivery log, in order.
+func (n *Notifier) pending(url string) ([]uint32, error) {
+	n.rw.RLock()
+	defer n.rw.RUnlock()
+	deliveries, err := n.deliveries()
+	if err != nil {
+		return nil, err
+	}
+	var idxs []uint32
+	for _, d := range deliveries {
+		if d.URL == url && d.pending(n.cfg.MaxAttempts) {
+			idxs = append(idxs, d.Idx)
+		}
+	}
+	return idxs, nil
+}
+
+// deliver sends the delivery idx retrying with exponential backoff until it
+// succeeds, the maximum number of attempts is reached, the receiver rejects
+// it or the Notifier is stopped.
+func (n *Notifier) deliver(idx uint32, stop chan struct{}) error {
+	d, err := n.loadDelivery(idx)
+	if err != nil {
+		return err
+	}
+	if !d.pending(n.cfg.MaxAttempts) {
+		return nil
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	// The attempts made before a restart are counted.
+	policy := retry.Policy{
+		MaxAttempts: n.cfg.MaxAttempts - d.Attempts,
+		BackoffMin:  n.cfg.BackoffMin,
+		BackoffMax:  n.cfg.BackoffMax,
+		Jitter:      n.cfg.Jitter,
+	}
+	var storeErr error
+	err = policy.Do(ctx, func() error {
+		err := n.post(ctx, d.URL, d.Body)
+		if err != nil && ctx.Err() != nil {
+			// Stopped during the attempt, which is not counted.
+			return retry.Permanent(ctx.Err())
+		}
+		d.Attempts++
+		d.LastAttemptTs = time.Now().Unix()
+		if err == nil {
+			d.Delivered = true
+			d.LastError = ""
+		} else {
+			d.Rejected = retry.IsPermanent(err)
+			d.LastError = err.Error()
+		}
+		if storeErr = n.updateDelivery(d); storeErr != nil {
+			return retry.Permanent(storeErr)
+		}
+		return err
+	})
+	switch {
+	case storeErr != nil:
+		return storeErr
+	case err == context.Canceled:
+		return nil
+	case err != nil:
+		return fmt.Errorf("giving up after %v attempts: %v", d.Attempts, d.LastError)
+	}
+	return nil
+}
+
+func (n *Notifier) post(ctx context.Context, url string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderSignature, Signature(n.cfg.Secret, body))
+	res, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		err := fmt.Errorf("unexpected status code: %v", res.StatusCode)
+		if !retry.RetryableStatus(res.StatusCode) {
+			return retry.Permanent(err)
+		}
+		return err
+	}
+	return nil
+}
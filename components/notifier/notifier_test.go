@@ -0,0 +1,274 @@
+package notifier
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var secret = []byte("webhook secret")
+
+type receiver struct {
+	sync.Mutex
+	fails  int
+	events []Event
+	done   chan struct{}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.Lock()
+	defer r.Unlock()
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil || !VerifySignature(secret, body, req.Header.Get(HeaderSignature)) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if r.fails > 0 {
+		r.fails--
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	var event Event
+	if err := json.Unmarshal(body, &event); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.events = append(r.events, event)
+	r.done <- struct{}{}
+}
+
+func TestSignature(t *testing.T) {
+	body := []byte(`{"type":"claim.issued"}`)
+	sig := Signature(secret, body)
+	assert.True(t, VerifySignature(secret, body, sig))
+	assert.False(t, VerifySignature([]byte("other"), body, sig))
+	assert.False(t, VerifySignature(secret, []byte(`{}`), sig))
+}
+
+func TestNotifier(t *testing.T) {
+	r := &receiver{fails: 2, done: make(chan struct{}, 4)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	cfg := ConfigDefault
+	cfg.URLs = []string{server.URL}
+	cfg.Secret = secret
+	cfg.BackoffMin = 10 * time.Millisecond
+	cfg.BackoffMax = 20 * time.Millisecond
+	storage := db.NewMemoryStorage()
+	n := New(cfg, storage)
+
+	assert.Equal(t, ErrNotStarted, n.Notify(EventStateSynced, nil))
+	require.Nil(t, n.Start())
+	defer n.Stop()
+
+	hooks := n.Hooks()
+	hooks.OnClaimRevoked(&issuer.ClaimRevokedEvent{
+		Claim:           &merkletree.Entry{},
+		RevocationNonce: 42,
+	})
+
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	r.Lock()
+	require.Equal(t, 1, len(r.events))
+	assert.Equal(t, EventClaimRevoked, r.events[0].Type)
+	assert.Equal(t, float64(42), r.events[0].Data.(map[string]interface{})["RevocationNonce"])
+	r.Unlock()
+
+	// The response may still be in flight.
+	var deliveries []Delivery
+	for i := 0; i < 100; i++ {
+		var err error
+		deliveries, err = n.Deliveries()
+		require.Nil(t, err)
+		if len(deliveries) == 1 && deliveries[0].Delivered {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	n.Stop()
+	require.Equal(t, 1, len(deliveries))
+	assert.True(t, deliveries[0].Delivered)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+	assert.Equal(t, server.URL, deliveries[0].URL)
+}
+
+func TestNotifierResumePending(t *testing.T) {
+	r := &receiver{done: make(chan struct{}, 4)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	cfg := ConfigDefault
+	cfg.URLs = []string{server.URL}
+	cfg.Secret = secret
+	storage := db.NewMemoryStorage()
+
+	// Record a pending delivery as if the process stopped before
+	// delivering it.
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	db.NewStorageValue(dbKeyDeliveryIdx).Set(tx, 1)
+	require.Nil(t, storeDelivery(tx, &Delivery{Idx: 0, URL: server.URL,
+		Body: json.RawMessage(`{"type":"state.synced","timestamp":0,"data":null}`)}))
+	require.Nil(t, tx.Commit())
+
+	n := New(cfg, storage)
+	require.Nil(t, n.Start())
+	defer n.Stop()
+
+	select {
+	case <-r.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not delivered")
+	}
+	r.Lock()
+	assert.Equal(t, EventStateSynced, r.events[0].Type)
+	r.Unlock()
+}
+
+func TestNotifierRejected(t *testing.T) {
+	r := &receiver{done: make(chan struct{}, 4)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	cfg := ConfigDefault
+	cfg.URLs = []string{server.URL}
+	cfg.Secret = []byte("wrong secret")
+	cfg.BackoffMin = 10 * time.Millisecond
+	cfg.BackoffMax = 20 * time.Millisecond
+	n := New(cfg, db.NewMemoryStorage())
+	require.Nil(t, n.Start())
+	defer n.Stop()
+	require.Nil(t, n.Notify(EventStateSynced, nil))
+
+	// The receiver rejects the delivery with a 400, which is not retried.
+	var deliveries []Delivery
+	for i := 0; i < 100; i++ {
+		time.Sleep(10 * time.Millisecond)
+		var err error
+		deliveries, err = n.Deliveries()
+		require.Nil(t, err)
+		if len(deliveries) == 1 && deliveries[0].Attempts > 0 {
+			break
+		}
+	}
+	time.Sleep(50 * time.Millisecond)
+	deliveries, err := n.Deliveries()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(deliveries))
+	assert.False(t, deliveries[0].Delivered)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, "unexpected status code: 400", deliveries[0].LastError)
+}
+
+func TestNotifierPrune(t *testing.T) {
+	cfg := ConfigDefault
+	cfg.URLs = []string{"http://configured"}
+	cfg.Secret = secret
+	cfg.Retention = time.Hour
+	storage := db.NewMemoryStorage()
+	now := time.Unix(1600000000, 0)
+	old, recent := now.Add(-time.Hour).Unix(), now.Add(-time.Minute).Unix()
+
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	for _, d := range []*Delivery{
+		{Idx: 0, URL: "http://configured", Delivered: true, Attempts: 1, LastAttemptTs: old},
+		{Idx: 1, URL: "http://configured", Rejected: true, Attempts: 1, LastAttemptTs: recent},
+		{Idx: 2, URL: "http://configured", Attempts: cfg.MaxAttempts, LastAttemptTs: old},
+		{Idx: 3, URL: "http://configured", Attempts: 1, LastAttemptTs: old},
+		{Idx: 4, URL: "http://removed"},
+	} {
+		require.Nil(t, storeDelivery(tx, d))
+	}
+	require.Nil(t, tx.Commit())
+
+	// The finished deliveries older than the retention and the ones to
+	// URLs no longer configured are pruned, the pending ones are kept.
+	n := New(cfg, storage)
+	pruned, err := n.prune(now)
+	require.Nil(t, err)
+	assert.Equal(t, 3, pruned)
+	deliveries, err := n.Deliveries()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(deliveries))
+	assert.Equal(t, uint32(1), deliveries[0].Idx)
+	assert.Equal(t, uint32(3), deliveries[1].Idx)
+
+	// Without retention a delivery is deleted as soon as it's finished.
+	n.cfg.Retention = 0
+	d := deliveries[1]
+	d.Attempts++
+	require.Nil(t, n.updateDelivery(&d))
+	deliveries, err = n.Deliveries()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(deliveries))
+	d.Delivered = true
+	require.Nil(t, n.updateDelivery(&d))
+	deliveries, err = n.Deliveries()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(deliveries))
+	assert.Equal(t, uint32(1), deliveries[0].Idx)
+}
+
+func TestNotifierSlowURL(t *testing.T) {
+	r := &receiver{done: make(chan struct{}, 8)}
+	server := httptest.NewServer(r)
+	defer server.Close()
+	// dead never answers before the Notifier is stopped.
+	stopDead := make(chan struct{})
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		<-stopDead
+	}))
+	defer dead.Close()
+	defer close(stopDead)
+
+	cfg := ConfigDefault
+	cfg.URLs = []string{dead.URL, server.URL}
+	cfg.Secret = secret
+	cfg.QueueLen = 1
+	n := New(cfg, db.NewMemoryStorage())
+	require.Nil(t, n.Start())
+	defer n.Stop()
+
+	// Notify doesn't block on the full queue of dead, and the deliveries
+	// to server don't wait for the ones to dead.
+	notified := make(chan struct{})
+	go func() {
+		for i := 0; i < 4; i++ {
+			assert.Nil(t, n.Notify(EventStateSynced, i))
+		}
+		close(notified)
+	}()
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Notify blocked")
+	}
+	for i := 0; i < 4; i++ {
+		select {
+		case <-r.done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook not delivered")
+		}
+	}
+	r.Lock()
+	for i, event := range r.events {
+		assert.Equal(t, float64(i), event.Data)
+	}
+	r.Unlock()
+}
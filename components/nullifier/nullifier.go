@@ -0,0 +1,94 @@
+// Package nullifier derives the nullifiers of credentials, which let a
+// verifier accept each credential once (one vote, one airdrop) without
+// learning anything that links the presentations of the same credential to
+// different verifiers.  The nullifier of a claim for a verifier is
+//
+//	Poseidon(hIndex of the claim, revocation nonce of the claim, verifier id,
+//	         holder key)
+//
+// where the holder key is the scalar of the babyjub private key of the
+// holder.  The hIndex tells apart the claims of different issuers that share
+// a revocation nonce.  Only the holder can calculate it, and different verifiers get
+// unrelated nullifiers of the same claim.  As the verifier can't recalculate
+// the nullifier, it must be proven together with the credential (in a zero
+// knowledge proof) for the verifier to trust that it belongs to the claim.
+// The verifiers keep the used nullifiers in a Store.
+package nullifier
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+var (
+	// ErrNullifierUsed is used when a nullifier has already been used at
+	// the verifier.
+	ErrNullifierUsed = errors.New("the nullifier has already been used")
+)
+
+// New returns the nullifier of claim for the verifier, derived with the
+// private key sk of the holder.
+func New(claim merkletree.Entrier, verifier *core.ID, sk *babyjub.PrivateKey) (*merkletree.Hash, error) {
+	entry := claim.Entry()
+	nonce := new(big.Int).SetUint64(uint64(claims.GetRevocationNonce(entry)))
+	// The 31 bytes of the id are always inside the Finite Field.
+	verifierInt := new(big.Int).SetBytes(verifier[:])
+	n, err := poseidon.Hash([]*big.Int{entry.HIndex().BigInt(), nonce, verifierInt,
+		sk.Scalar().BigInt()})
+	if err != nil {
+		return nil, err
+	}
+	return merkletree.NewHashFromBigInt(n)
+}
+
+var dbPrefixNullifier = []byte("nullifier:")
+
+// Store keeps the nullifiers used at a verifier.
+type Store struct {
+	storage db.Storage
+	// mutex serializes the Uses, so that a nullifier can't be used twice
+	// concurrently.
+	mutex sync.Mutex
+}
+
+// NewStore creates a Store over storage.
+func NewStore(storage db.Storage) *Store {
+	return &Store{storage: storage.WithPrefix(dbPrefixNullifier)}
+}
+
+// Used returns true if the nullifier n has been used.
+func (s *Store) Used(n *merkletree.Hash) (bool, error) {
+	if _, err := s.storage.Get(n[:]); err == db.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// Use marks the nullifier n as used, or returns ErrNullifierUsed if it
+// already was.
+func (s *Store) Use(n *merkletree.Hash) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if used, err := s.Used(n); err != nil {
+		return err
+	} else if used {
+		return ErrNullifierUsed
+	}
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	tx.Put(n[:], []byte{})
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+package nullifier
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNullifier(t *testing.T) {
+	sk := babyjub.NewRandPrivKey()
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 1)
+	verifierA := core.NewID(core.TypeBJP0, [27]byte{1})
+	verifierB := core.NewID(core.TypeBJP0, [27]byte{2})
+
+	nA, err := New(claim, &verifierA, &sk)
+	require.Nil(t, err)
+	nA2, err := New(claim, &verifierA, &sk)
+	require.Nil(t, err)
+	assert.Equal(t, nA, nA2)
+
+	// Other verifier, other claim nonce or other holder key.
+	nB, err := New(claim, &verifierB, &sk)
+	require.Nil(t, err)
+	assert.NotEqual(t, nA, nB)
+	n, err := New(claims.NewClaimBasic(indexBytes, dataBytes, 2), &verifierA, &sk)
+	require.Nil(t, err)
+	assert.NotEqual(t, nA, n)
+	sk2 := babyjub.NewRandPrivKey()
+	n, err = New(claim, &verifierA, &sk2)
+	require.Nil(t, err)
+	assert.NotEqual(t, nA, n)
+
+	// Another claim with the same revocation nonce, like one of another
+	// issuer.
+	indexBytes[0] = 1
+	n, err = New(claims.NewClaimBasic(indexBytes, dataBytes, 1), &verifierA, &sk)
+	require.Nil(t, err)
+	assert.NotEqual(t, nA, n)
+
+	store := NewStore(db.NewMemoryStorage())
+	used, err := store.Used(nA)
+	require.Nil(t, err)
+	assert.False(t, used)
+	require.Nil(t, store.Use(nA))
+	used, err = store.Used(nA)
+	require.Nil(t, err)
+	assert.True(t, used)
+	assert.Equal(t, ErrNullifierUsed, store.Use(nA))
+	require.Nil(t, store.Use(nB))
+}
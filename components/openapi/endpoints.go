@@ -0,0 +1,101 @@
+package openapi
+
+import (
+	"net/http"
+
+	"github.com/iden3/go-iden3-core/components/credrefresh"
+	"github.com/iden3/go-iden3-core/components/health"
+	"github.com/iden3/go-iden3-core/components/idencontract"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core/proof"
+)
+
+// ErrorBody is the body of the failed responses of the APIs that don't export
+// their error type.
+type ErrorBody struct {
+	Error string `json:"error"`
+}
+
+// CredRefreshEndpoints are the endpoints of credrefresh.Handler.
+var CredRefreshEndpoints = []Endpoint{
+	{
+		Method:  http.MethodPost,
+		Path:    credrefresh.PathRefresh,
+		Summary: "Refresh an existence credential against the last identity state on chain",
+		Request: credrefresh.RefreshRequest{},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  proof.CredentialExistence{},
+			http.StatusBadRequest:          credrefresh.Error{},
+			http.StatusNotFound:            credrefresh.Error{},
+			http.StatusConflict:            credrefresh.Error{},
+			http.StatusGone:                credrefresh.Error{},
+			http.StatusInternalServerError: credrefresh.Error{},
+		},
+	},
+}
+
+// IdenPubOffChainEndpoints are the endpoints of
+// idenpuboffchainwriter.IdenPubOffChainWriteHttp.Handler.
+var IdenPubOffChainEndpoints = []Endpoint{
+	{
+		Method:  http.MethodGet,
+		Path:    "/claims/{hindex}/proof",
+		Summary: "Get the proof of a claim in a published identity state",
+		Params: []Param{
+			{Name: "hindex", In: "path", Description: "hex encoded hIndex of the claim"},
+			{Name: "state", In: "query", Description: "hex encoded identity state, the last published one by default"},
+		},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  idenpuboffchainwriter.ClaimProof{},
+			http.StatusBadRequest:          ErrorBody{},
+			http.StatusNotFound:            ErrorBody{},
+			http.StatusInternalServerError: ErrorBody{},
+		},
+	},
+}
+
+// ForwardEndpoints are the endpoints of idencontract.NonceHandler.
+var ForwardEndpoints = []Endpoint{
+	{
+		Method:  http.MethodGet,
+		Path:    idencontract.PathNonce,
+		Summary: "Get the nonce of the next forward call of an identity",
+		Params: []Param{
+			{Name: "id", In: "query", Description: "identity", Required: true},
+		},
+		Responses: map[int]interface{}{
+			http.StatusOK:                  idencontract.NonceResponse{},
+			http.StatusBadRequest:          idencontract.Error{},
+			http.StatusInternalServerError: idencontract.Error{},
+		},
+	},
+}
+
+// HealthEndpoints are the endpoints of health.Checker.Handler.
+var HealthEndpoints = []Endpoint{
+	{
+		Method:    http.MethodGet,
+		Path:      health.PathHealthz,
+		Summary:   "Liveness probe",
+		Responses: map[int]interface{}{http.StatusOK: health.Status{}},
+	},
+	{
+		Method:  http.MethodGet,
+		Path:    health.PathReadyz,
+		Summary: "Readiness probe",
+		Responses: map[int]interface{}{
+			http.StatusOK:                 health.Status{},
+			http.StatusServiceUnavailable: health.Status{},
+		},
+	},
+}
+
+// OpenAPIEndpoints are the endpoints of Handler.
+var OpenAPIEndpoints = []Endpoint{
+	{
+		Method:    http.MethodGet,
+		Path:      PathOpenAPI,
+		Summary:   "OpenAPI document of the API",
+		Responses: map[int]interface{}{http.StatusOK: nil},
+	},
+}
@@ -0,0 +1,325 @@
+// Package openapi generates the OpenAPI v3 document of the HTTP APIs from
+// their typed request and response structs, so that clients in other
+// languages can be generated from it.  The document is served at:
+//
+//	GET /openapi.json
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+)
+
+// PathOpenAPI is the path where the document is served.
+const PathOpenAPI = "/openapi.json"
+
+// Version is the OpenAPI version of the generated documents.
+const Version = "3.0.3"
+
+// Document is an OpenAPI v3 document.
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+}
+
+// Info is the metadata of the API.
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Components holds the schemas referenced in the document.
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas,omitempty"`
+}
+
+// PathItem holds the operations of a path.
+type PathItem struct {
+	Get  *Operation `json:"get,omitempty"`
+	Post *Operation `json:"post,omitempty"`
+	Put  *Operation `json:"put,omitempty"`
+}
+
+// Operation is an API operation.
+type Operation struct {
+	Summary     string               `json:"summary,omitempty"`
+	OperationId string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+// RequestBody is the JSON body of a request.
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response of an operation.
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType is the schema of a body.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Schema is the JSON schema of a value.  The empty Schema accepts any value.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+}
+
+// Endpoint describes an operation of an API to be added to a Document.
+type Endpoint struct {
+	Method string
+	// Path uses the OpenAPI templates for the path parameters, like
+	// /claims/{hindex}/proof.
+	Path    string
+	Summary string
+	// Params are the path and query parameters.
+	Params []Param
+	// Request is a value of the type of the JSON request body, or nil.
+	Request interface{}
+	// Responses are values of the types of the JSON response bodies by
+	// status code.  A nil value is a response without body.
+	Responses map[int]interface{}
+}
+
+// Param is a string path or query parameter of an Endpoint.
+type Param struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+}
+
+// Generator builds a Document, registering the schemas of the named structs
+// used by the endpoints in the document components.
+type Generator struct {
+	doc *Document
+}
+
+// NewGenerator creates a Generator of a Document with title and version.
+func NewGenerator(title, version string) *Generator {
+	return &Generator{doc: &Document{
+		OpenAPI:    Version,
+		Info:       Info{Title: title, Version: version},
+		Paths:      make(map[string]*PathItem),
+		Components: Components{Schemas: make(map[string]*Schema)},
+	}}
+}
+
+// Add adds the endpoints to the document.
+func (g *Generator) Add(endpoints ...Endpoint) {
+	for _, e := range endpoints {
+		item, ok := g.doc.Paths[e.Path]
+		if !ok {
+			item = &PathItem{}
+			g.doc.Paths[e.Path] = item
+		}
+		op := &Operation{
+			Summary:     e.Summary,
+			OperationId: operationId(e.Method, e.Path),
+			Responses:   make(map[string]*Response),
+		}
+		for _, p := range e.Params {
+			op.Parameters = append(op.Parameters, &Parameter{Name: p.Name, In: p.In,
+				Description: p.Description, Required: p.Required || p.In == "path",
+				Schema: &Schema{Type: "string"}})
+		}
+		if e.Request != nil {
+			op.RequestBody = &RequestBody{Required: true, Content: map[string]*MediaType{
+				"application/json": {Schema: g.SchemaOf(reflect.TypeOf(e.Request))}}}
+		}
+		for status, res := range e.Responses {
+			response := &Response{Description: http.StatusText(status)}
+			if res != nil {
+				response.Content = map[string]*MediaType{
+					"application/json": {Schema: g.SchemaOf(reflect.TypeOf(res))}}
+			}
+			op.Responses[strconv.Itoa(status)] = response
+		}
+		switch e.Method {
+		case http.MethodGet:
+			item.Get = op
+		case http.MethodPost:
+			item.Post = op
+		case http.MethodPut:
+			item.Put = op
+		}
+	}
+}
+
+// Document returns the generated document.
+func (g *Generator) Document() *Document {
+	return g.doc
+}
+
+// operationId returns an identifier like postCredentialsExistenceRefresh.
+func operationId(method, path string) string {
+	id := strings.ToLower(method)
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '{' || r == '}' || r == '.' || r == '-' || r == '_'
+	}) {
+		id += strings.ToUpper(part[:1]) + part[1:]
+	}
+	return id
+}
+
+var (
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	timeType          = reflect.TypeOf(time.Time{})
+)
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || reflect.PtrTo(t).Implements(iface)
+}
+
+// SchemaOf returns the schema of the JSON serialization of t.  Named structs
+// are registered in the components and referenced.
+func (g *Generator) SchemaOf(t reflect.Type) *Schema {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch {
+	case t == timeType:
+		return &Schema{Type: "string", Format: "date-time"}
+	case implements(t, jsonMarshalerType):
+		return &Schema{}
+	case implements(t, textMarshalerType):
+		return &Schema{Type: "string"}
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64, reflect.Uint, reflect.Uint64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.SchemaOf(t.Elem())}
+	case reflect.Array:
+		return &Schema{Type: "array", Items: g.SchemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.SchemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structSchema(t)
+		}
+		name := schemaName(t)
+		if _, ok := g.doc.Components.Schemas[name]; !ok {
+			// Register before generating to support recursive types.
+			g.doc.Components.Schemas[name] = &Schema{}
+			*g.doc.Components.Schemas[name] = *g.structSchema(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + name}
+	default:
+		return &Schema{}
+	}
+}
+
+// schemaName returns the name of the schema of the named type t, like
+// proof.CredentialExistence -> ProofCredentialExistence.
+func schemaName(t reflect.Type) string {
+	pkg := t.PkgPath()
+	pkg = pkg[strings.LastIndex(pkg, "/")+1:]
+	if pkg == "" {
+		return t.Name()
+	}
+	return strings.ToUpper(pkg[:1]) + pkg[1:] + t.Name()
+}
+
+// structSchema returns the schema of the struct t following the encoding/json
+// rules for the field names.
+func (g *Generator) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: "object", Properties: make(map[string]*Schema)}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		tag := strings.Split(f.Tag.Get("json"), ",")
+		name := tag[0]
+		if name == "-" && len(tag) == 1 {
+			continue
+		}
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				embedded := g.structSchema(ft)
+				for k, v := range embedded.Properties {
+					schema.Properties[k] = v
+				}
+				schema.Required = append(schema.Required, embedded.Required...)
+				continue
+			}
+		}
+		if name == "" {
+			name = f.Name
+		}
+		omitempty := false
+		for _, opt := range tag[1:] {
+			if opt == "omitempty" {
+				omitempty = true
+			}
+		}
+		fieldSchema := g.SchemaOf(f.Type)
+		if f.Type.Kind() == reflect.Ptr && fieldSchema.Ref == "" {
+			fieldSchema.Nullable = true
+		}
+		schema.Properties[name] = fieldSchema
+		if !omitempty {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+	sort.Strings(schema.Required)
+	return schema
+}
+
+// Handler returns an http.Handler that serves doc at PathOpenAPI.
+func Handler(doc *Document) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathOpenAPI, func(w http.ResponseWriter, r *http.Request) {
+		httpjson.Write(w, http.StatusOK, doc)
+	})
+	return mux
+}
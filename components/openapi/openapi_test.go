@@ -0,0 +1,153 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/components/credrefresh"
+	"github.com/iden3/go-iden3-core/components/health"
+	"github.com/iden3/go-iden3-core/components/idencontract"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type refresherNotFound struct{}
+
+func (r refresherNotFound) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	return nil, fmt.Errorf("not found")
+}
+
+// newServer returns a server with the actual handlers of the documented APIs.
+func newServer(t *testing.T, doc *Document) *httptest.Server {
+	clt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rot, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	ret, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	require.Nil(t, claims.AddLeafRootsTree(rot, clt.RootKey()))
+	cfg := idenpuboffchainwriter.ConfigDefault
+	offChain, err := idenpuboffchainwriter.NewIdenPubOffChainWriteHttp(&cfg, db.NewMemoryStorage(), clt, rot, ret)
+	require.Nil(t, err)
+
+	mux := http.NewServeMux()
+	mux.Handle(credrefresh.PathRefresh, credrefresh.Handler(refresherNotFound{}))
+	mux.Handle("/claims/", offChain.Handler())
+	mux.Handle(idencontract.PathNonce, idencontract.NonceHandler(idencontract.NewNonces(db.NewMemoryStorage())))
+	checker := health.New(health.ConfigDefault)
+	mux.Handle(health.PathHealthz, checker.Handler())
+	mux.Handle(health.PathReadyz, checker.Handler())
+	mux.Handle(PathOpenAPI, Handler(doc))
+	return httptest.NewServer(mux)
+}
+
+func newDocument() *Document {
+	g := NewGenerator("iden3", "1")
+	g.Add(CredRefreshEndpoints...)
+	g.Add(IdenPubOffChainEndpoints...)
+	g.Add(ForwardEndpoints...)
+	g.Add(HealthEndpoints...)
+	g.Add(OpenAPIEndpoints...)
+	return g.Document()
+}
+
+// checkRefs checks that all the references of the schema are defined in the
+// document.
+func checkRefs(t *testing.T, doc *Document, schema *Schema) {
+	if schema == nil {
+		return
+	}
+	if schema.Ref != "" {
+		_, ok := doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		assert.True(t, ok, schema.Ref)
+	}
+	checkRefs(t, doc, schema.Items)
+	checkRefs(t, doc, schema.AdditionalProperties)
+	for _, p := range schema.Properties {
+		checkRefs(t, doc, p)
+	}
+}
+
+// checkBody checks that the properties of the JSON object body are defined in
+// the schema, and that the required ones are present.
+func checkBody(t *testing.T, doc *Document, schema *Schema, body []byte) {
+	if schema.Ref != "" {
+		schema = doc.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+	}
+	if schema.Type != "object" {
+		return
+	}
+	var obj map[string]json.RawMessage
+	require.Nil(t, json.Unmarshal(body, &obj), string(body))
+	for k := range obj {
+		_, ok := schema.Properties[k]
+		assert.True(t, ok, "property %v not in the schema", k)
+	}
+	for _, k := range schema.Required {
+		_, ok := obj[k]
+		assert.True(t, ok, "required property %v missing", k)
+	}
+}
+
+func TestDocumentMatchesRoutes(t *testing.T) {
+	doc := newDocument()
+	server := newServer(t, doc)
+	defer server.Close()
+
+	for _, schema := range doc.Components.Schemas {
+		checkRefs(t, doc, schema)
+	}
+	for path, item := range doc.Paths {
+		for method, op := range map[string]*Operation{http.MethodGet: item.Get, http.MethodPost: item.Post} {
+			if op == nil {
+				continue
+			}
+			url := server.URL + strings.Replace(path, "{hindex}", merkletree.HashZero.Hex(), 1)
+			for _, p := range op.Parameters {
+				if p.In == "query" && p.Name == "id" {
+					url += "?id=113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf"
+				}
+			}
+			body := ""
+			if op.RequestBody != nil {
+				body = `{"hIndex": "` + merkletree.HashZero.Hex() + `"}`
+			}
+			req, err := http.NewRequest(method, url, strings.NewReader(body))
+			require.Nil(t, err)
+			res, err := http.DefaultClient.Do(req)
+			require.Nil(t, err)
+			resBody, err := ioutil.ReadAll(res.Body)
+			res.Body.Close()
+			require.Nil(t, err)
+
+			// The route exists and responds with a documented status
+			response, ok := op.Responses[strconv.Itoa(res.StatusCode)]
+			require.True(t, ok, "%v %v: undocumented status %v: %s", method, path, res.StatusCode, resBody)
+			if mediaType, ok := response.Content["application/json"]; ok {
+				checkBody(t, doc, mediaType.Schema, resBody)
+			}
+		}
+	}
+}
+
+func TestSchemaOf(t *testing.T) {
+	g := NewGenerator("test", "1")
+	g.Add(ForwardEndpoints...)
+	schema := g.Document().Components.Schemas["IdencontractNonceResponse"]
+	require.NotNil(t, schema)
+	assert.Equal(t, &Schema{Type: "string", Nullable: true}, schema.Properties["id"])
+	assert.Equal(t, &Schema{Type: "integer", Format: "int64"}, schema.Properties["nonce"])
+	assert.Equal(t, []string{"id", "nonce"}, schema.Required)
+	assert.Equal(t, "getForwardNonce", g.Document().Paths[idencontract.PathNonce].Get.OperationId)
+}
@@ -0,0 +1,137 @@
+package protocol
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+)
+
+// Client is the holder side of the protocol.
+type Client struct {
+	httpClient *http.Client
+	decrypter  Decrypter
+}
+
+// NewClient creates a Client.  If httpClient is nil, http.DefaultClient is
+// used.
+func NewClient(httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	return &Client{httpClient: httpClient}
+}
+
+// SetDecrypter sets the Decrypter of the holder, so that the responses of the
+// agent are encrypted to its key.
+func (c *Client) SetDecrypter(d Decrypter) {
+	c.decrypter = d
+}
+
+// Fetch fetches the credentials of the TypeOffer Message offerMsg from its
+// agent endpoint, with the session token of the holder.  The credentials are
+// returned in the order of the offer, and checked to be of the offered claims
+// of the issuer.  The fetch request is encrypted if the offer has an
+// encryption key, and the response if the Client has a Decrypter.  The
+// errors of the agent are returned as ErrOfferNotFound,
+// issuer.ErrClaimRevoked, issuer.ErrClaimNotFound and
+// issuer.ErrClaimNotFoundStateOnChain.
+func (c *Client) Fetch(ctx context.Context, offerMsg *Message, token string) ([]*proof.CredentialExistence, error) {
+	var offer OfferBody
+	if err := offerMsg.UnmarshalBody(TypeOffer, &offer); err != nil {
+		return nil, err
+	}
+	fetchBody := FetchRequestBody{Nonce: offer.Nonce}
+	if c.decrypter != nil {
+		fetchBody.EncryptionKey = c.decrypter.RecipientKey()
+	}
+	fetchMsg, err := NewMessage(TypeFetchRequest, offerMsg.To, offerMsg.From, fetchBody)
+	if err != nil {
+		return nil, err
+	}
+	var reqBody []byte
+	if offer.EncryptionKey != nil {
+		env, err := Encrypt(fetchMsg, offer.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		reqBody, err = json.Marshal(env)
+		if err != nil {
+			return nil, err
+		}
+	} else if reqBody, err = json.Marshal(fetchMsg); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, offer.URL, bytes.NewReader(reqBody))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	res, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		var resErr Error
+		if err := json.NewDecoder(res.Body).Decode(&resErr); err != nil {
+			return nil, fmt.Errorf("fetch failed with status %v", res.Status)
+		}
+		for _, e := range errorStatus {
+			if resErr.Code == e.code {
+				if e.code == CodeNotPublished {
+					return nil, issuer.ErrClaimNotFoundStateOnChain
+				}
+				return nil, e.err
+			}
+		}
+		return nil, fmt.Errorf("fetch failed with status %v: %v", res.Status, resErr.Error)
+	}
+	resMsg, err := c.decodeResponse(res)
+	if err != nil {
+		return nil, err
+	}
+	var issuance IssuanceBody
+	if err := resMsg.UnmarshalBody(TypeIssuance, &issuance); err != nil {
+		return nil, err
+	}
+	if len(issuance.Credentials) != len(offer.Credentials) {
+		return nil, ErrInvalidResponse
+	}
+	for i, credential := range issuance.Credentials {
+		if credential == nil || credential.Claim == nil || credential.Id == nil ||
+			*credential.Id != *offerMsg.From ||
+			offer.Credentials[i].HIndex == nil ||
+			*credential.Claim.HIndex() != *offer.Credentials[i].HIndex {
+			return nil, ErrInvalidResponse
+		}
+	}
+	return issuance.Credentials, nil
+}
+
+// decodeResponse decodes the Message of the response of the agent,
+// decrypting it if the Client has a Decrypter.  A plain Message is rejected
+// when the Client has a Decrypter, so that the encryption can't be removed.
+func (c *Client) decodeResponse(res *http.Response) (*Message, error) {
+	if c.decrypter == nil {
+		var msg Message
+		if err := json.NewDecoder(res.Body).Decode(&msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+	var env Envelope
+	if err := json.NewDecoder(res.Body).Decode(&env); err != nil {
+		return nil, err
+	}
+	if env.Alg == "" {
+		return nil, ErrInvalidResponse
+	}
+	return c.decrypter.Decrypt(&env)
+}
@@ -0,0 +1,291 @@
+package protocol
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// The messages are encrypted end to end in an Envelope with an
+// ephemeral-static ECDH: the sender creates an ephemeral key pair, derives
+// a shared secret with the static key of the recipient, and encrypts the
+// JSON message with XChaCha20-Poly1305 under a key derived from the secret
+// with HKDF-SHA256.  The header of the Envelope (algorithm, recipient key
+// and ephemeral key) is authenticated as additional data.  The static keys
+// are BabyJubJub keys, like the operational key of an identity, or X25519
+// keys authorized by a claims.ClaimAuthorizeKEncX25519.
+
+// Alg is the key agreement algorithm of an Envelope.
+type Alg string
+
+const (
+	// AlgECDHBabyJub is the ECDH over BabyJubJub.
+	AlgECDHBabyJub Alg = "ECDH-ES+BJJ/XC20P"
+	// AlgECDHX25519 is the ECDH over Curve25519.
+	AlgECDHX25519 Alg = "ECDH-ES+X25519/XC20P"
+)
+
+// hkdfInfo is the info of the HKDF that derives the encryption key.
+var hkdfInfo = []byte("iden3 protocol envelope")
+
+var (
+	// ErrUnsupportedAlg is used when an Envelope or a RecipientKey have
+	// an unknown algorithm.
+	ErrUnsupportedAlg = errors.New("unsupported encryption algorithm")
+	// ErrInvalidKey is used when a key is not valid for its algorithm.
+	ErrInvalidKey = errors.New("invalid encryption key")
+	// ErrNotRecipient is used when an Envelope is decrypted with a key
+	// other than the one it's encrypted to.
+	ErrNotRecipient = errors.New("the envelope is encrypted to another key")
+	// ErrDecrypt is used when an Envelope can't be decrypted or
+	// authenticated.
+	ErrDecrypt = errors.New("unable to decrypt the envelope")
+)
+
+// RecipientKey is the static public key that messages are encrypted to.
+type RecipientKey struct {
+	Alg Alg         `json:"alg"`
+	Key common3.Hex `json:"key"`
+}
+
+// BabyJubRecipientKey returns the RecipientKey of the BabyJubJub public key
+// pk.
+func BabyJubRecipientKey(pk *babyjub.PublicKey) *RecipientKey {
+	pkComp := pk.Compress()
+	return &RecipientKey{Alg: AlgECDHBabyJub, Key: pkComp[:]}
+}
+
+// X25519RecipientKey returns the RecipientKey of the X25519 public key pk.
+func X25519RecipientKey(pk *[32]byte) *RecipientKey {
+	return &RecipientKey{Alg: AlgECDHX25519, Key: append([]byte{}, pk[:]...)}
+}
+
+// RecipientKeyFromClaim returns the RecipientKey of the X25519 public key
+// authorized by claim.
+func RecipientKeyFromClaim(claim *claims.ClaimAuthorizeKEncX25519) *RecipientKey {
+	return X25519RecipientKey(&claim.PubKey)
+}
+
+// Envelope is an encrypted and authenticated Message.
+type Envelope struct {
+	Alg Alg `json:"alg"`
+	// Kid is the recipient public key.
+	Kid common3.Hex `json:"kid"`
+	// EphemeralKey is the public key of the ephemeral key pair of the
+	// sender.
+	EphemeralKey common3.Hex `json:"epk"`
+	Nonce        common3.Hex `json:"nonce"`
+	Ciphertext   common3.Hex `json:"ciphertext"`
+}
+
+// additionalData returns the authenticated header of env.
+func (env *Envelope) additionalData() []byte {
+	ad := append([]byte(env.Alg), 0)
+	ad = append(ad, env.Kid...)
+	return append(ad, env.EphemeralKey...)
+}
+
+// seal encrypts msg in env with the shared secret.
+func (env *Envelope) seal(shared []byte, msg *Message) error {
+	aead, err := newAEAD(shared, env)
+	if err != nil {
+		return err
+	}
+	plaintext, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	env.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(env.Nonce); err != nil {
+		return err
+	}
+	env.Ciphertext = aead.Seal(nil, env.Nonce, plaintext, env.additionalData())
+	return nil
+}
+
+// open decrypts the message of env with the shared secret.
+func (env *Envelope) open(shared []byte) (*Message, error) {
+	aead, err := newAEAD(shared, env)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	var msg Message
+	if err := json.Unmarshal(plaintext, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// newAEAD returns the cipher of env, with the key derived from the shared
+// secret.
+func newAEAD(shared []byte, env *Envelope) (cipher.AEAD, error) {
+	salt := append(append([]byte{}, env.EphemeralKey...), env.Kid...)
+	info := append(append([]byte{}, hkdfInfo...), env.Alg...)
+	key := make([]byte, chacha20poly1305.KeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, shared, salt, info), key); err != nil {
+		return nil, err
+	}
+	return chacha20poly1305.NewX(key)
+}
+
+// babyJubPoint decompresses the BabyJubJub point in b.
+func babyJubPoint(b []byte) (*babyjub.Point, error) {
+	var comp [32]byte
+	if len(b) != len(comp) {
+		return nil, ErrInvalidKey
+	}
+	copy(comp[:], b)
+	p, err := babyjub.NewPoint().Decompress(comp)
+	if err != nil || !p.InSubGroup() {
+		return nil, ErrInvalidKey
+	}
+	return p, nil
+}
+
+// x25519Shared returns the X25519 shared secret of sk and pk, failing on the
+// low order public keys.
+func x25519Shared(sk, pk *[32]byte) ([]byte, error) {
+	var shared [32]byte
+	curve25519.ScalarMult(&shared, sk, pk)
+	if shared == ([32]byte{}) {
+		return nil, ErrInvalidKey
+	}
+	return shared[:], nil
+}
+
+// Encrypt encrypts msg in an Envelope to the recipient key to.
+func Encrypt(msg *Message, to *RecipientKey) (*Envelope, error) {
+	env := Envelope{Alg: to.Alg, Kid: append([]byte{}, to.Key...)}
+	var shared []byte
+	switch to.Alg {
+	case AlgECDHBabyJub:
+		pk, err := babyJubPoint(to.Key)
+		if err != nil {
+			return nil, err
+		}
+		esk := babyjub.NewRandPrivKey()
+		epk := esk.Public().Compress()
+		env.EphemeralKey = epk[:]
+		sharedPoint := babyjub.NewPoint().Mul(esk.Scalar().BigInt(), pk).Compress()
+		shared = sharedPoint[:]
+	case AlgECDHX25519:
+		var pk, esk, epk [32]byte
+		if len(to.Key) != len(pk) {
+			return nil, ErrInvalidKey
+		}
+		copy(pk[:], to.Key)
+		if _, err := rand.Read(esk[:]); err != nil {
+			return nil, err
+		}
+		curve25519.ScalarBaseMult(&epk, &esk)
+		env.EphemeralKey = epk[:]
+		var err error
+		if shared, err = x25519Shared(&esk, &pk); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, ErrUnsupportedAlg
+	}
+	if err := env.seal(shared, msg); err != nil {
+		return nil, err
+	}
+	return &env, nil
+}
+
+// Decrypter decrypts the Envelopes encrypted to its key.
+type Decrypter interface {
+	// RecipientKey returns the public key of the Decrypter.
+	RecipientKey() *RecipientKey
+	// Decrypt decrypts the message of env.
+	Decrypt(env *Envelope) (*Message, error)
+}
+
+// checkRecipient checks that env is encrypted to the key of d.
+func checkRecipient(d Decrypter, env *Envelope) error {
+	key := d.RecipientKey()
+	if env.Alg != key.Alg {
+		return ErrUnsupportedAlg
+	}
+	if !bytes.Equal(env.Kid, key.Key) {
+		return ErrNotRecipient
+	}
+	return nil
+}
+
+// BabyJubDecrypter is a Decrypter with a BabyJubJub private key.
+type BabyJubDecrypter struct {
+	sk  *babyjub.PrivateKey
+	key *RecipientKey
+}
+
+// NewBabyJubDecrypter creates a BabyJubDecrypter with the private key sk.
+func NewBabyJubDecrypter(sk *babyjub.PrivateKey) *BabyJubDecrypter {
+	return &BabyJubDecrypter{sk: sk, key: BabyJubRecipientKey(sk.Public())}
+}
+
+// RecipientKey returns the public key of d.
+func (d *BabyJubDecrypter) RecipientKey() *RecipientKey { return d.key }
+
+// Decrypt decrypts the message of env.
+func (d *BabyJubDecrypter) Decrypt(env *Envelope) (*Message, error) {
+	if err := checkRecipient(d, env); err != nil {
+		return nil, err
+	}
+	epk, err := babyJubPoint(env.EphemeralKey)
+	if err != nil {
+		return nil, err
+	}
+	sharedPoint := babyjub.NewPoint().Mul(d.sk.Scalar().BigInt(), epk).Compress()
+	return env.open(sharedPoint[:])
+}
+
+// X25519Decrypter is a Decrypter with an X25519 private key.
+type X25519Decrypter struct {
+	sk  [32]byte
+	key *RecipientKey
+}
+
+// NewX25519Decrypter creates an X25519Decrypter with the private key sk.
+func NewX25519Decrypter(sk *[32]byte) *X25519Decrypter {
+	var pk [32]byte
+	curve25519.ScalarBaseMult(&pk, sk)
+	return &X25519Decrypter{sk: *sk, key: X25519RecipientKey(&pk)}
+}
+
+// RecipientKey returns the public key of d.
+func (d *X25519Decrypter) RecipientKey() *RecipientKey { return d.key }
+
+// Decrypt decrypts the message of env.
+func (d *X25519Decrypter) Decrypt(env *Envelope) (*Message, error) {
+	if err := checkRecipient(d, env); err != nil {
+		return nil, err
+	}
+	var epk [32]byte
+	if len(env.EphemeralKey) != len(epk) {
+		return nil, ErrInvalidKey
+	}
+	copy(epk[:], env.EphemeralKey)
+	shared, err := x25519Shared(&d.sk, &epk)
+	if err != nil {
+		return nil, err
+	}
+	return env.open(shared)
+}
@@ -0,0 +1,112 @@
+package protocol
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/centrauth"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryption(t *testing.T) {
+	msg, err := NewMessage(TypeFetchRequest, &holderID, &issuerID, FetchRequestBody{Nonce: "00"})
+	require.Nil(t, err)
+
+	sk := babyjub.NewRandPrivKey()
+	x25519Sk := [32]byte{1, 2, 3}
+	x25519 := NewX25519Decrypter(&x25519Sk)
+	var x25519Pk [32]byte
+	copy(x25519Pk[:], x25519.RecipientKey().Key)
+	claim := claims.NewClaimAuthorizeKEncX25519(&x25519Pk, 0)
+	for _, d := range []Decrypter{NewBabyJubDecrypter(&sk), x25519} {
+		key := d.RecipientKey()
+		if key.Alg == AlgECDHX25519 {
+			assert.Equal(t, key, RecipientKeyFromClaim(claim))
+		}
+		env, err := Encrypt(msg, key)
+		require.Nil(t, err)
+		assert.Equal(t, key.Alg, env.Alg)
+		decrypted, err := d.Decrypt(env)
+		require.Nil(t, err)
+		assert.Equal(t, msg, decrypted)
+
+		// Every encryption uses a new ephemeral key.
+		env2, err := Encrypt(msg, key)
+		require.Nil(t, err)
+		assert.NotEqual(t, env.EphemeralKey, env2.EphemeralKey)
+
+		// The ciphertext and the header are authenticated.
+		tampered := *env
+		tampered.Ciphertext = append([]byte{}, env.Ciphertext...)
+		tampered.Ciphertext[0] ^= 1
+		_, err = d.Decrypt(&tampered)
+		assert.Equal(t, ErrDecrypt, err)
+		tampered = *env
+		tampered.EphemeralKey = env2.EphemeralKey
+		_, err = d.Decrypt(&tampered)
+		assert.Equal(t, ErrDecrypt, err)
+	}
+
+	// Envelopes encrypted to other keys.
+	otherSk := babyjub.NewRandPrivKey()
+	env, err := Encrypt(msg, BabyJubRecipientKey(otherSk.Public()))
+	require.Nil(t, err)
+	_, err = NewBabyJubDecrypter(&sk).Decrypt(env)
+	assert.Equal(t, ErrNotRecipient, err)
+	_, err = x25519.Decrypt(env)
+	assert.Equal(t, ErrUnsupportedAlg, err)
+
+	_, err = Encrypt(msg, &RecipientKey{Alg: "none", Key: []byte{1}})
+	assert.Equal(t, ErrUnsupportedAlg, err)
+	_, err = Encrypt(msg, &RecipientKey{Alg: AlgECDHBabyJub, Key: []byte{1}})
+	assert.Equal(t, ErrInvalidKey, err)
+	_, err = Encrypt(msg, X25519RecipientKey(&[32]byte{}))
+	assert.Equal(t, ErrInvalidKey, err)
+}
+
+func TestOfferFetchEncrypted(t *testing.T) {
+	claim1 := newClaim(1)
+	is := &issuerMock{claims: map[merkletree.Hash]*merkletree.Entry{*claim1.HIndex(): claim1}}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	tokens := centrauth.NewSessions([]byte("key"), time.Hour, clk)
+	server := NewServer(ConfigDefault, is, db.NewMemoryStorage(), clk)
+	httpServer := httptest.NewServer(server.Handler(tokens))
+	defer httpServer.Close()
+	server.cfg.URL = httpServer.URL + PathAgent
+	token, _, err := tokens.Issue(&holderID)
+	require.Nil(t, err)
+
+	// The agent doesn't accept encrypted requests without a Decrypter.
+	offerMsg, err := server.Offer(&holderID, []OfferedCredential{{HIndex: claim1.HIndex()}})
+	require.Nil(t, err)
+	var offer OfferBody
+	require.Nil(t, offerMsg.UnmarshalBody(TypeOffer, &offer))
+	assert.Nil(t, offer.EncryptionKey)
+	agentSk := babyjub.NewRandPrivKey()
+	offer.EncryptionKey = BabyJubRecipientKey(agentSk.Public())
+	badOfferMsg, err := NewMessage(TypeOffer, offerMsg.From, offerMsg.To, offer)
+	require.Nil(t, err)
+	_, err = NewClient(nil).Fetch(context.Background(), badOfferMsg, token)
+	assert.NotNil(t, err)
+
+	server.SetDecrypter(NewBabyJubDecrypter(&agentSk))
+	offerMsg, err = server.Offer(&holderID, []OfferedCredential{{HIndex: claim1.HIndex()}})
+	require.Nil(t, err)
+	require.Nil(t, offerMsg.UnmarshalBody(TypeOffer, &offer))
+	assert.Equal(t, BabyJubRecipientKey(agentSk.Public()), offer.EncryptionKey)
+
+	client := NewClient(nil)
+	client.SetDecrypter(NewX25519Decrypter(&[32]byte{4, 5, 6}))
+	credentials, err := client.Fetch(context.Background(), offerMsg, token)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(credentials))
+	assert.Equal(t, claim1.Data, credentials[0].Claim.Data)
+}
@@ -0,0 +1,140 @@
+// Package protocol implements the credential offer protocol, by which an
+// issuer hands credentials to a holder:
+//
+//  1. The issuer creates an offer of some of its claims to a holder with
+//     Server.Offer, and sends the resulting TypeOffer Message to the holder
+//     by any channel (a notification, a QR code, ...).  The offer has the
+//     hIndex of the claims, the URL of the agent endpoint and a nonce.
+//
+//  2. The holder authenticates as the offer recipient at the centrauth
+//     service of the issuer, getting a session token.
+//
+//  3. The holder sends a TypeFetchRequest Message with the nonce of the
+//     offer to the agent endpoint with the session token, and gets back a
+//     TypeIssuance Message with the existence credentials of the claims:
+//
+//     POST /agent
+//     Authorization: Bearer <token>
+//     {"type": "...", "from": "<holder id>", "to": "<issuer id>", "body": {...}}
+//
+// The messages to and from the agent can be encrypted end to end in an
+// Envelope (see Encrypt): the fetch request to the key in the offer, and the
+// response to the key in the fetch request.  An offer can be fetched until it
+// expires, so that a holder can retry the fetch of the claims whose identity
+// state is not yet published.  On failure the response is a JSON Error with a
+// code.  Client implements the holder side.
+package protocol
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// PathAgent is the path of the agent endpoint.
+const PathAgent = "/agent"
+
+// MessageType is the type of a Message, which defines its body.
+type MessageType string
+
+const (
+	// TypeOffer is the type of the offer sent by the issuer, with an
+	// OfferBody.
+	TypeOffer MessageType = "iden3/credentials/1.0/offer"
+	// TypeFetchRequest is the type of the request of the offered
+	// credentials sent by the holder, with a FetchRequestBody.
+	TypeFetchRequest MessageType = "iden3/credentials/1.0/fetch-request"
+	// TypeIssuance is the type of the response to a fetch request, with
+	// an IssuanceBody.
+	TypeIssuance MessageType = "iden3/credentials/1.0/issuance"
+)
+
+var (
+	// ErrUnexpectedType is used when a Message doesn't have the expected
+	// type.
+	ErrUnexpectedType = errors.New("unexpected message type")
+	// ErrOfferNotFound is used when an offer is unknown, expired or not
+	// addressed to the holder.
+	ErrOfferNotFound = errors.New("offer not found or expired")
+	// ErrInvalidResponse is used when the response of the agent doesn't
+	// match the fetched offer.
+	ErrInvalidResponse = errors.New("invalid agent response")
+)
+
+// Message is a message of the protocol between From and To.
+type Message struct {
+	Type MessageType     `json:"type"`
+	From *core.ID        `json:"from"`
+	To   *core.ID        `json:"to"`
+	Body json.RawMessage `json:"body"`
+}
+
+// NewMessage creates a Message of type typ from from to to with the JSON of
+// body.
+func NewMessage(typ MessageType, from, to *core.ID, body interface{}) (*Message, error) {
+	bodyJSON, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Message{Type: typ, From: from, To: to, Body: bodyJSON}, nil
+}
+
+// UnmarshalBody parses the body of m, which must have type typ, into body.
+func (m *Message) UnmarshalBody(typ MessageType, body interface{}) error {
+	if m.Type != typ {
+		return ErrUnexpectedType
+	}
+	return json.Unmarshal(m.Body, body)
+}
+
+// OfferedCredential is a credential of an offer.
+type OfferedCredential struct {
+	HIndex *merkletree.Hash `json:"hIndex"`
+	// Description is a human readable description of the credential.
+	Description string `json:"description,omitempty"`
+}
+
+// OfferBody is the body of a TypeOffer Message.
+type OfferBody struct {
+	// URL is the agent endpoint where the credentials are fetched.
+	URL         string              `json:"url"`
+	Nonce       string              `json:"nonce"`
+	Expiration  int64               `json:"expiration"`
+	Credentials []OfferedCredential `json:"credentials"`
+	// EncryptionKey is the key the fetch request is encrypted to, if the
+	// agent accepts encrypted messages.
+	EncryptionKey *RecipientKey `json:"encryptionKey,omitempty"`
+}
+
+// FetchRequestBody is the body of a TypeFetchRequest Message.
+type FetchRequestBody struct {
+	Nonce string `json:"nonce"`
+	// EncryptionKey is the key the response is encrypted to, if set.
+	EncryptionKey *RecipientKey `json:"encryptionKey,omitempty"`
+}
+
+// IssuanceBody is the body of a TypeIssuance Message, with the credentials
+// in the order of the offer.
+type IssuanceBody struct {
+	Credentials []*proof.CredentialExistence `json:"credentials"`
+}
+
+// Codes of the errors returned by the agent endpoint.
+const (
+	CodeBadRequest      = "bad_request"
+	CodeUnsupportedType = "unsupported_type"
+	CodeOfferNotFound   = "offer_not_found"
+	CodeRevoked         = "revoked"
+	CodeNotFound        = "not_found"
+	CodeNotPublished    = "not_published"
+	CodeInternal        = "internal"
+)
+
+// Error is the body of a failed response.
+type Error struct {
+	Code  string `json:"code"`
+	Error string `json:"error"`
+}
@@ -0,0 +1,139 @@
+package protocol
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/centrauth"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	issuerID = core.NewID(core.TypeBJP0, [27]byte{1})
+	holderID = core.NewID(core.TypeBJP0, [27]byte{2})
+)
+
+// issuerMock issues the claims, or returns their error.
+type issuerMock struct {
+	claims map[merkletree.Hash]*merkletree.Entry
+	errs   map[merkletree.Hash]error
+}
+
+func newClaim(i byte) *merkletree.Entry {
+	claim := &merkletree.Entry{}
+	claim.Data[0][0] = i
+	return claim
+}
+
+func (is *issuerMock) ID() *core.ID { return &issuerID }
+
+func (is *issuerMock) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	if err, ok := is.errs[*hIndex]; ok {
+		return nil, err
+	}
+	claim, ok := is.claims[*hIndex]
+	if !ok {
+		return nil, issuer.ErrClaimNotFound
+	}
+	return &proof.CredentialExistence{
+		Id:            &issuerID,
+		IdenStateData: proof.IdenStateData{BlockN: 2, BlockTs: 3, IdenState: &merkletree.Hash{4}},
+		MtpClaim:      &merkletree.Proof{Existence: true},
+		Claim:         claim,
+	}, nil
+}
+
+func TestOfferFetch(t *testing.T) {
+	claim1, claim2 := newClaim(1), newClaim(2)
+	is := &issuerMock{
+		claims: map[merkletree.Hash]*merkletree.Entry{*claim1.HIndex(): claim1, *claim2.HIndex(): claim2},
+		errs:   map[merkletree.Hash]error{},
+	}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	tokens := centrauth.NewSessions([]byte("key"), time.Hour, clk)
+	cfg := ConfigDefault
+	server := NewServer(cfg, is, db.NewMemoryStorage(), clk)
+	httpServer := httptest.NewServer(server.Handler(tokens))
+	defer httpServer.Close()
+	server.cfg.URL = httpServer.URL + PathAgent
+	client := NewClient(nil)
+	ctx := context.Background()
+
+	offerMsg, err := server.Offer(&holderID, []OfferedCredential{
+		{HIndex: claim1.HIndex(), Description: "first"},
+		{HIndex: claim2.HIndex()},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, TypeOffer, offerMsg.Type)
+	assert.Equal(t, &issuerID, offerMsg.From)
+	var offer OfferBody
+	require.Nil(t, offerMsg.UnmarshalBody(TypeOffer, &offer))
+	assert.Equal(t, int64(1000)+int64(cfg.OfferTTL/time.Second), offer.Expiration)
+	assert.Equal(t, ErrUnexpectedType, offerMsg.UnmarshalBody(TypeIssuance, &IssuanceBody{}))
+
+	holderToken, _, err := tokens.Issue(&holderID)
+	require.Nil(t, err)
+	otherID := core.NewID(core.TypeBJP0, [27]byte{3})
+	otherToken, _, err := tokens.Issue(&otherID)
+	require.Nil(t, err)
+
+	// Not authenticated, or not the recipient of the offer.
+	_, err = client.Fetch(ctx, offerMsg, "invalid")
+	assert.NotNil(t, err)
+	_, err = client.Fetch(ctx, offerMsg, otherToken)
+	assert.NotNil(t, err)
+
+	// A claim not yet published can be fetched again later.
+	is.errs[*claim2.HIndex()] = issuer.ErrClaimNotFoundStateOnChain
+	_, err = client.Fetch(ctx, offerMsg, holderToken)
+	assert.Equal(t, issuer.ErrClaimNotFoundStateOnChain, err)
+	delete(is.errs, *claim2.HIndex())
+
+	credentials, err := client.Fetch(ctx, offerMsg, holderToken)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(credentials))
+	assert.Equal(t, claim1.Data, credentials[0].Claim.Data)
+	assert.Equal(t, claim2.Data, credentials[1].Claim.Data)
+
+	// Unknown and expired offers.
+	unknownMsg, err := NewMessage(TypeOffer, &issuerID, &holderID,
+		OfferBody{URL: offer.URL, Nonce: "00", Credentials: offer.Credentials})
+	require.Nil(t, err)
+	_, err = client.Fetch(ctx, unknownMsg, holderToken)
+	assert.Equal(t, ErrOfferNotFound, err)
+	clk.Advance(cfg.OfferTTL)
+	holderToken, _, err = tokens.Issue(&holderID)
+	require.Nil(t, err)
+	_, err = client.Fetch(ctx, offerMsg, holderToken)
+	assert.Equal(t, ErrOfferNotFound, err)
+	n, err := server.Prune()
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+}
+
+func TestFetchInvalidResponse(t *testing.T) {
+	claim1 := newClaim(1)
+	is := &issuerMock{claims: map[merkletree.Hash]*merkletree.Entry{*claim1.HIndex(): newClaim(9)}}
+	clk := clock.NewFake(time.Unix(1000, 0))
+	tokens := centrauth.NewSessions([]byte("key"), time.Hour, clk)
+	server := NewServer(ConfigDefault, is, db.NewMemoryStorage(), clk)
+	httpServer := httptest.NewServer(server.Handler(tokens))
+	defer httpServer.Close()
+	server.cfg.URL = httpServer.URL + PathAgent
+
+	offerMsg, err := server.Offer(&holderID, []OfferedCredential{{HIndex: claim1.HIndex()}})
+	require.Nil(t, err)
+	token, _, err := tokens.Issue(&holderID)
+	require.Nil(t, err)
+	_, err = NewClient(nil).Fetch(context.Background(), offerMsg, token)
+	assert.Equal(t, ErrInvalidResponse, err)
+}
@@ -0,0 +1,297 @@
+package protocol
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/centrauth"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+// nonceLen is the length in bytes of the offer nonces.
+const nonceLen = 32
+
+// Config is the configuration of the Server.
+type Config struct {
+	// URL is the URL of the agent endpoint sent in the offers.
+	URL string
+	// OfferTTL is the time an offer can be fetched since it's created.
+	OfferTTL time.Duration
+}
+
+// ConfigDefault is the default configuration of the Server.
+var ConfigDefault = Config{OfferTTL: 7 * 24 * time.Hour}
+
+// Issuer issues the offered credentials, satisfied by issuer.Issuer.
+type Issuer interface {
+	ID() *core.ID
+	RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error)
+}
+
+// offer is a stored offer.
+type offer struct {
+	Holder     *core.ID           `json:"holder"`
+	HIndexes   []*merkletree.Hash `json:"hIndexes"`
+	Expiration int64              `json:"expiration"`
+}
+
+// Server is the issuer side of the protocol.  It stores the offers in a
+// storage until they expire.
+type Server struct {
+	cfg     Config
+	issuer  Issuer
+	mutex   sync.Mutex
+	storage db.Storage
+	clock   clock.Clock
+	// decrypter decrypts the messages sent to the agent, if set.
+	decrypter Decrypter
+}
+
+// NewServer creates a Server that offers the credentials of is, storing the
+// offers in storage.
+func NewServer(cfg Config, is Issuer, storage db.Storage, clk clock.Clock) *Server {
+	return &Server{cfg: cfg, issuer: is, storage: storage, clock: clk}
+}
+
+// SetDecrypter sets the Decrypter of the encrypted messages sent to the
+// agent.  Its key is sent in the offers, so that the holders encrypt their
+// fetch requests.
+func (s *Server) SetDecrypter(d Decrypter) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.decrypter = d
+}
+
+func (s *Server) getDecrypter() Decrypter {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.decrypter
+}
+
+// Offer creates an offer of credentials to holder, and returns the TypeOffer
+// Message to be sent to the holder.
+func (s *Server) Offer(holder *core.ID, credentials []OfferedCredential) (*Message, error) {
+	var nonce [nonceLen]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return nil, err
+	}
+	o := offer{Holder: holder, HIndexes: make([]*merkletree.Hash, len(credentials)),
+		Expiration: s.clock.Now().Add(s.cfg.OfferTTL).Unix()}
+	for i, credential := range credentials {
+		o.HIndexes[i] = credential.HIndex
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if err := db.StoreJSON(tx, nonce[:], &o); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	body := OfferBody{
+		URL:         s.cfg.URL,
+		Nonce:       hex.EncodeToString(nonce[:]),
+		Expiration:  o.Expiration,
+		Credentials: credentials,
+	}
+	if s.decrypter != nil {
+		body.EncryptionKey = s.decrypter.RecipientKey()
+	}
+	return NewMessage(TypeOffer, s.issuer.ID(), holder, body)
+}
+
+// getOffer returns the offer with nonce to holder, or ErrOfferNotFound.
+func (s *Server) getOffer(nonce string, holder *core.ID) (*offer, error) {
+	nonceBytes, err := hex.DecodeString(nonce)
+	if err != nil || len(nonceBytes) != nonceLen {
+		return nil, ErrOfferNotFound
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v, err := s.storage.Get(nonceBytes)
+	if err == db.ErrNotFound {
+		return nil, ErrOfferNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var o offer
+	if err := json.Unmarshal(v, &o); err != nil {
+		return nil, err
+	}
+	if s.clock.Now().Unix() >= o.Expiration || o.Holder == nil || *o.Holder != *holder {
+		return nil, ErrOfferNotFound
+	}
+	return &o, nil
+}
+
+// Prune deletes the expired offers and returns how many were deleted.
+func (s *Server) Prune() (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var expired [][]byte
+	now := s.clock.Now().Unix()
+	if err := s.storage.Iterate(func(k, v []byte) (bool, error) {
+		var o offer
+		if err := json.Unmarshal(v, &o); err != nil || now >= o.Expiration {
+			expired = append(expired, append([]byte{}, k...))
+		}
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	for _, k := range expired {
+		tx.Delete(k)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(expired), nil
+}
+
+// Fetch returns the credentials of the offer with nonce to holder.
+func (s *Server) Fetch(holder *core.ID, nonce string) ([]*proof.CredentialExistence, error) {
+	o, err := s.getOffer(nonce, holder)
+	if err != nil {
+		return nil, err
+	}
+	credentials := make([]*proof.CredentialExistence, len(o.HIndexes))
+	for i, hIndex := range o.HIndexes {
+		if credentials[i], err = s.issuer.RefreshCredentialExistence(hIndex); err != nil {
+			return nil, err
+		}
+	}
+	return credentials, nil
+}
+
+// errorStatus maps the errors of Fetch to the status and code of the
+// response.
+var errorStatus = []struct {
+	err    error
+	status int
+	code   string
+}{
+	{ErrOfferNotFound, http.StatusNotFound, CodeOfferNotFound},
+	{issuer.ErrClaimRevoked, http.StatusGone, CodeRevoked},
+	{issuer.ErrClaimNotFound, http.StatusNotFound, CodeNotFound},
+	{issuer.ErrClaimNotFoundStateOnChain, http.StatusConflict, CodeNotPublished},
+	{issuer.ErrIdenStateOnChainZero, http.StatusConflict, CodeNotPublished},
+}
+
+// decodeMessage decodes the Message in raw, decrypting it if it's an
+// Envelope.
+func (s *Server) decodeMessage(raw json.RawMessage) (*Message, error) {
+	var env Envelope
+	if err := json.Unmarshal(raw, &env); err != nil {
+		return nil, err
+	}
+	if env.Alg == "" {
+		var msg Message
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
+	d := s.getDecrypter()
+	if d == nil {
+		return nil, ErrUnsupportedAlg
+	}
+	return d.Decrypt(&env)
+}
+
+// Handler returns an http.Handler that serves the agent endpoint, requiring
+// a session token verified by tokens (see centrauth.Middleware).
+func (s *Server) Handler(tokens centrauth.Tokens) http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle(PathAgent, centrauth.Middleware(tokens, http.HandlerFunc(s.handleAgent)))
+	return mux
+}
+
+func (s *Server) handleAgent(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpjson.Write(w, http.StatusMethodNotAllowed, Error{Code: CodeBadRequest, Error: "method not allowed"})
+		return
+	}
+	holder, _ := centrauth.IDFromContext(req.Context())
+	var raw json.RawMessage
+	if err := json.NewDecoder(req.Body).Decode(&raw); err != nil {
+		httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeBadRequest, Error: "invalid message: " + err.Error()})
+		return
+	}
+	msg, err := s.decodeMessage(raw)
+	if err != nil {
+		httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeBadRequest, Error: "invalid message: " + err.Error()})
+		return
+	}
+	if msg.Type != TypeFetchRequest {
+		httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeUnsupportedType,
+			Error: "unsupported message type " + string(msg.Type)})
+		return
+	}
+	// The sender must be the authenticated identity, and the recipient
+	// this issuer.
+	if msg.From == nil || *msg.From != *holder || msg.To == nil || *msg.To != *s.issuer.ID() {
+		httpjson.Write(w, http.StatusForbidden, Error{Code: CodeBadRequest, Error: "invalid message sender or recipient"})
+		return
+	}
+	var body FetchRequestBody
+	if err := msg.UnmarshalBody(TypeFetchRequest, &body); err != nil {
+		httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeBadRequest, Error: "invalid body: " + err.Error()})
+		return
+	}
+	credentials, err := s.Fetch(holder, body.Nonce)
+	if err != nil {
+		for _, e := range errorStatus {
+			if err == e.err {
+				httpjson.Write(w, e.status, Error{Code: e.code, Error: err.Error()})
+				return
+			}
+		}
+		log.WithError(err).Error("Fetch")
+		httpjson.Write(w, http.StatusInternalServerError, Error{Code: CodeInternal, Error: "internal error"})
+		return
+	}
+	res, err := NewMessage(TypeIssuance, s.issuer.ID(), holder, IssuanceBody{Credentials: credentials})
+	if err != nil {
+		log.WithError(err).Error("NewMessage")
+		httpjson.Write(w, http.StatusInternalServerError, Error{Code: CodeInternal, Error: "internal error"})
+		return
+	}
+	if body.EncryptionKey == nil {
+		httpjson.Write(w, http.StatusOK, res)
+		return
+	}
+	env, err := Encrypt(res, body.EncryptionKey)
+	if err == ErrUnsupportedAlg || err == ErrInvalidKey {
+		httpjson.Write(w, http.StatusBadRequest, Error{Code: CodeBadRequest, Error: "invalid encryptionKey: " + err.Error()})
+		return
+	} else if err != nil {
+		log.WithError(err).Error("Encrypt")
+		httpjson.Write(w, http.StatusInternalServerError, Error{Code: CodeInternal, Error: "internal error"})
+		return
+	}
+	httpjson.Write(w, http.StatusOK, env)
+}
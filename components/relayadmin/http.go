@@ -0,0 +1,323 @@
+package relayadmin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/common"
+	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/components/identitysrv"
+	"github.com/iden3/go-iden3-core/components/txjournal"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// PathIdentities is the path of the identities endpoints.
+	PathIdentities = "/admin/identities"
+	// PathTxs is the path of the transaction journal endpoint.
+	PathTxs = "/admin/txs"
+	// PathCompact is the path of the storage compaction endpoint.
+	PathCompact = "/admin/compact"
+	// PathAccount is the path of the ethereum account endpoint.
+	PathAccount = "/admin/account"
+	// PathCosts is the path of the transaction costs endpoint.
+	PathCosts = "/admin/costs"
+)
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// IdentityStatus is an identity with the status of its publication.
+type IdentityStatus struct {
+	identitysrv.Identity
+	PublishStatus *PublishStatus `json:"publishStatus,omitempty"`
+	// PublishError is the error getting the PublishStatus.
+	PublishError string `json:"publishError,omitempty"`
+}
+
+// IdentitiesResponse is the body of the response of GET /admin/identities.
+type IdentitiesResponse struct {
+	Total      int              `json:"total"`
+	Identities []IdentityStatus `json:"identities"`
+}
+
+// CompactRequest is the body of POST /admin/compact.
+type CompactRequest struct {
+	// Prefix is the hex prefix of the compacted keys, or empty to compact
+	// the whole storage.
+	Prefix string `json:"prefix"`
+}
+
+// AccountRequest is the body of POST /admin/account.
+type AccountRequest struct {
+	Address common.Address `json:"address"`
+}
+
+// AccountResponse is the body of the responses of /admin/account.
+type AccountResponse struct {
+	Address *common.Address `json:"address"`
+}
+
+// CostsResponse is the body of the response of GET /admin/costs.
+type CostsResponse struct {
+	// Total are the costs of all the identities.
+	Total *txjournal.Costs `json:"total"`
+	// ByIdentity are the costs by identity.  The transactions sent on
+	// behalf of no identity are under the empty identity.
+	ByIdentity map[string]*txjournal.Costs `json:"byIdentity"`
+}
+
+func writeInternalError(w http.ResponseWriter, msg string, err error) {
+	log.WithError(err).Error(msg)
+	httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+}
+
+func notImplemented(w http.ResponseWriter) {
+	httpjson.WriteError(w, http.StatusNotImplemented, "not available in this relay")
+}
+
+// authenticated returns an http.Handler that requires the admin token and
+// calls next.
+func (a *Admin) authenticated(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		auth := req.Header.Get("Authorization")
+		token := strings.TrimPrefix(auth, "Bearer ")
+		if !strings.HasPrefix(auth, "Bearer ") ||
+			subtle.ConstantTimeCompare([]byte(token), []byte(a.cfg.Token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			httpjson.WriteError(w, http.StatusUnauthorized, "invalid admin token")
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// Handler returns an http.Handler that serves the admin API, as described in
+// the package documentation.
+func (a *Admin) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathIdentities, a.handleIdentities)
+	mux.HandleFunc(PathIdentities+"/", a.handleResync)
+	mux.HandleFunc(PathTxs, a.handleTxs)
+	mux.HandleFunc(PathCompact, a.handleCompact)
+	mux.HandleFunc(PathAccount, a.handleAccount)
+	mux.HandleFunc(PathCosts, a.handleCosts)
+	return a.authenticated(mux)
+}
+
+// queryInt returns the integer query parameter name, or 0 if it's missing.
+func queryInt(req *http.Request, name string) (int, error) {
+	s := req.URL.Query().Get(name)
+	if s == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(s)
+}
+
+func (a *Admin) handleIdentities(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if a.identities == nil {
+		notImplemented(w)
+		return
+	}
+	offset, err := queryInt(req, "offset")
+	if err != nil || offset < 0 {
+		httpjson.WriteError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+	limit, err := queryInt(req, "limit")
+	if err != nil || limit < 0 {
+		httpjson.WriteError(w, http.StatusBadRequest, "invalid limit")
+		return
+	}
+	total, err := a.identities.Count()
+	if err != nil {
+		writeInternalError(w, "Unable to count the identities", err)
+		return
+	}
+	identities, err := a.identities.List(offset, limit)
+	if err != nil {
+		writeInternalError(w, "Unable to list the identities", err)
+		return
+	}
+	res := IdentitiesResponse{Total: total, Identities: make([]IdentityStatus, len(identities))}
+	for i, identity := range identities {
+		res.Identities[i].Identity = identity
+		if a.publisher == nil {
+			continue
+		}
+		// A broken identity must not hide the status of the rest.
+		status, err := a.publisher.PublishStatus(identity.Id)
+		if err != nil {
+			res.Identities[i].PublishError = err.Error()
+			continue
+		}
+		res.Identities[i].PublishStatus = status
+	}
+	httpjson.Write(w, http.StatusOK, res)
+}
+
+func (a *Admin) handleResync(w http.ResponseWriter, req *http.Request) {
+	idStr := strings.TrimPrefix(req.URL.Path, PathIdentities+"/")
+	if !strings.HasSuffix(idStr, "/resync") {
+		httpjson.WriteError(w, http.StatusNotFound, "not found")
+		return
+	}
+	if req.Method != http.MethodPost {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if a.publisher == nil {
+		notImplemented(w)
+		return
+	}
+	id, err := core.IDFromString(strings.TrimSuffix(idStr, "/resync"))
+	if err != nil {
+		httpjson.WriteError(w, http.StatusBadRequest, "invalid id: "+err.Error())
+		return
+	}
+	if err := a.publisher.Resync(req.Context(), &id); err == identitysrv.ErrIdentityNotFound {
+		httpjson.WriteError(w, http.StatusNotFound, err.Error())
+		return
+	} else if err != nil {
+		writeInternalError(w, "Unable to resync the identity state", err)
+		return
+	}
+	status, err := a.publisher.PublishStatus(&id)
+	if err != nil {
+		writeInternalError(w, "Unable to get the publish status", err)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, status)
+}
+
+func (a *Admin) handleTxs(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if a.journal == nil {
+		notImplemented(w)
+		return
+	}
+	var statuses []txjournal.TxStatus
+	for _, status := range req.URL.Query()["status"] {
+		switch s := txjournal.TxStatus(status); s {
+		case txjournal.TxStatusPending, txjournal.TxStatusConfirmed, txjournal.TxStatusFailed:
+			statuses = append(statuses, s)
+		default:
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid status "+status)
+			return
+		}
+	}
+	entries, err := a.journal.Entries(statuses...)
+	if err != nil {
+		writeInternalError(w, "Unable to read the transaction journal", err)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, entries)
+}
+
+func (a *Admin) handleCosts(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodGet {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	if a.journal == nil {
+		notImplemented(w)
+		return
+	}
+	purposes := req.URL.Query()["purpose"]
+	if len(purposes) == 0 {
+		purposes = []string{idenpubonchain.TxPurposeInitState, idenpubonchain.TxPurposeSetState}
+	}
+	byIdentity, err := a.journal.CostsByIdentity(purposes...)
+	if err != nil {
+		writeInternalError(w, "Unable to read the transaction journal", err)
+		return
+	}
+	res := CostsResponse{Total: txjournal.NewCosts(), ByIdentity: byIdentity}
+	for _, costs := range byIdentity {
+		res.Total.Txs += costs.Txs
+		res.Total.Pending += costs.Pending
+		res.Total.GasUsed += costs.GasUsed
+		res.Total.Wei.Add(res.Total.Wei, costs.Wei)
+	}
+	httpjson.Write(w, http.StatusOK, res)
+}
+
+func (a *Admin) handleCompact(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	compacter, ok := a.storage.(db.Compacter)
+	if !ok {
+		notImplemented(w)
+		return
+	}
+	var compactReq CompactRequest
+	if req.ContentLength != 0 {
+		if err := json.NewDecoder(req.Body).Decode(&compactReq); err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+	}
+	var prefix []byte
+	if compactReq.Prefix != "" {
+		var err error
+		if prefix, err = common3.HexDecode(compactReq.Prefix); err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid prefix: "+err.Error())
+			return
+		}
+	}
+	log.WithField("prefix", compactReq.Prefix).Info("Compacting the storage")
+	if err := compacter.Compact(prefix); err != nil {
+		writeInternalError(w, "Unable to compact the storage", err)
+		return
+	}
+	httpjson.Write(w, http.StatusOK, struct{}{})
+}
+
+func (a *Admin) handleAccount(w http.ResponseWriter, req *http.Request) {
+	if a.account == nil {
+		notImplemented(w)
+		return
+	}
+	switch req.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		var accountReq AccountRequest
+		if err := json.NewDecoder(req.Body).Decode(&accountReq); err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		if err := a.account.SetAccount(accountReq.Address); err == eth.ErrAccountNotInKeyStore {
+			httpjson.WriteError(w, http.StatusBadRequest, err.Error())
+			return
+		} else if err != nil {
+			writeInternalError(w, "Unable to rotate the account", err)
+			return
+		}
+		log.WithField("address", accountReq.Address.Hex()).Info("Rotated the ethereum account")
+	default:
+		httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	var res AccountResponse
+	if account := a.account.Account(); account != nil {
+		res.Address = &account.Address
+	}
+	httpjson.Write(w, http.StatusOK, res)
+}
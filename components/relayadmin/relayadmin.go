@@ -0,0 +1,132 @@
+// Package relayadmin implements the admin API of a relay, for the operations
+// that would otherwise require poking its database manually:
+//
+//	GET  /admin/identities?offset=<n>&limit=<n>   identities and publish status
+//	POST /admin/identities/<id>/resync            re-sync the on chain state
+//	GET  /admin/txs?status=<status>               transaction journal
+//	POST /admin/compact                           compact the storage
+//	GET  /admin/account                           ethereum account
+//	POST /admin/account                           rotate the ethereum account
+//	GET  /admin/costs?purpose=<purpose>           transaction costs by identity
+//
+// Every endpoint requires the header "Authorization: Bearer <token>" with the
+// token of the Config.  The endpoints of the dependencies that are not set
+// in Admin return 501 Not Implemented.
+package relayadmin
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-core/components/identitysrv"
+	"github.com/iden3/go-iden3-core/components/txjournal"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	// ErrTokenRequired is returned by New when the Config has no token,
+	// so that the admin API is never served without authentication.
+	ErrTokenRequired = errors.New("the admin API token is required")
+)
+
+// Config is the configuration of the Admin.
+type Config struct {
+	// Token is the secret that authenticates the operator.
+	Token string
+}
+
+// PublishStatus is the status of the publication of the state of an
+// identity.
+type PublishStatus struct {
+	// IdenState is the current identity state.
+	IdenState *merkletree.Hash `json:"idenState"`
+	// IdenStateOnChain is the last identity state confirmed on chain.
+	IdenStateOnChain *merkletree.Hash `json:"idenStateOnChain"`
+	// IdenStatePending is the identity state published and not yet
+	// confirmed, if any, and PendingTx the transaction that published it.
+	IdenStatePending *merkletree.Hash `json:"idenStatePending,omitempty"`
+	PendingTx        *common.Hash     `json:"pendingTx,omitempty"`
+}
+
+// Publisher publishes the states of the identities of the relay.
+type Publisher interface {
+	// PublishStatus returns the publish status of the identity id.
+	PublishStatus(id *core.ID) (*PublishStatus, error)
+	// Resync updates the on chain and pending states of the identity id
+	// from the Smart Contract.
+	Resync(ctx context.Context, id *core.ID) error
+}
+
+// AccountRotator is the ethereum client of the relay whose account can be
+// rotated, like eth.Client2.
+type AccountRotator interface {
+	Account() *accounts.Account
+	SetAccount(address common.Address) error
+}
+
+// IssuerPublisher is a Publisher over the issuer.Issuer of each identity.
+type IssuerPublisher struct {
+	issuer func(id *core.ID) (*issuer.Issuer, error)
+}
+
+// NewIssuerPublisher creates an IssuerPublisher that gets the issuer.Issuer
+// of an identity with getIssuer, which returns
+// identitysrv.ErrIdentityNotFound for the unknown identities.
+func NewIssuerPublisher(getIssuer func(id *core.ID) (*issuer.Issuer, error)) *IssuerPublisher {
+	return &IssuerPublisher{issuer: getIssuer}
+}
+
+// PublishStatus returns the publish status of the identity id.
+func (p *IssuerPublisher) PublishStatus(id *core.ID) (*PublishStatus, error) {
+	is, err := p.issuer(id)
+	if err != nil {
+		return nil, err
+	}
+	idenState, _ := is.State()
+	status := PublishStatus{IdenState: idenState, IdenStateOnChain: is.StateDataOnChain().IdenState}
+	if pending, tx, ok := is.PendingState(); ok {
+		status.IdenStatePending = pending
+		if tx != nil {
+			hash := tx.Hash()
+			status.PendingTx = &hash
+		}
+	}
+	return &status, nil
+}
+
+// Resync updates the states of the identity id with
+// issuer.Issuer.SyncIdenStatePublicCtx.
+func (p *IssuerPublisher) Resync(ctx context.Context, id *core.ID) error {
+	is, err := p.issuer(id)
+	if err != nil {
+		return err
+	}
+	return is.SyncIdenStatePublicCtx(ctx)
+}
+
+// Admin serves the admin API of a relay.
+type Admin struct {
+	cfg        Config
+	identities identitysrv.Storage
+	publisher  Publisher
+	journal    *txjournal.Journal
+	storage    db.Storage
+	account    AccountRotator
+}
+
+// New creates an Admin with the token of cfg.  The identities, publisher,
+// journal, storage and account are optional: their endpoints are disabled
+// when they are nil, and the compaction when storage is not a db.Compacter.
+func New(cfg Config, identities identitysrv.Storage, publisher Publisher, journal *txjournal.Journal,
+	storage db.Storage, account AccountRotator) (*Admin, error) {
+	if cfg.Token == "" {
+		return nil, ErrTokenRequired
+	}
+	return &Admin{cfg: cfg, identities: identities, publisher: publisher, journal: journal,
+		storage: storage, account: account}, nil
+}
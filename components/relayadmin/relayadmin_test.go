@@ -0,0 +1,188 @@
+package relayadmin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/identitysrv"
+	"github.com/iden3/go-iden3-core/components/txjournal"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const token = "secret"
+
+type publisherMock struct {
+	resynced []core.ID
+}
+
+func (p *publisherMock) PublishStatus(id *core.ID) (*PublishStatus, error) {
+	if id[2] == 2 {
+		return nil, identitysrv.ErrIdentityNotFound
+	}
+	return &PublishStatus{IdenState: &merkletree.Hash{id[2]}, IdenStateOnChain: &merkletree.Hash{}}, nil
+}
+
+func (p *publisherMock) Resync(ctx context.Context, id *core.ID) error {
+	if _, err := p.PublishStatus(id); err != nil {
+		return err
+	}
+	p.resynced = append(p.resynced, *id)
+	return nil
+}
+
+type accountMock struct {
+	account *accounts.Account
+}
+
+func (a *accountMock) Account() *accounts.Account { return a.account }
+
+func (a *accountMock) SetAccount(address common.Address) error {
+	if address == (common.Address{}) {
+		return eth.ErrAccountNotInKeyStore
+	}
+	a.account = &accounts.Account{Address: address}
+	return nil
+}
+
+func newID(i byte) *core.ID {
+	id := core.NewID(core.TypeBJP0, [27]byte{i})
+	return &id
+}
+
+type testAdmin struct {
+	t      *testing.T
+	server *httptest.Server
+}
+
+func (a *testAdmin) do(method, path, token string, body, res interface{}) int {
+	var reqBody []byte
+	if body != nil {
+		var err error
+		reqBody, err = json.Marshal(body)
+		require.Nil(a.t, err)
+	}
+	req, err := http.NewRequest(method, a.server.URL+path, bytes.NewReader(reqBody))
+	require.Nil(a.t, err)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	httpRes, err := http.DefaultClient.Do(req)
+	require.Nil(a.t, err)
+	defer httpRes.Body.Close()
+	if res != nil && httpRes.StatusCode == http.StatusOK {
+		require.Nil(a.t, json.NewDecoder(httpRes.Body).Decode(res))
+	}
+	return httpRes.StatusCode
+}
+
+func TestAdmin(t *testing.T) {
+	_, err := New(Config{}, nil, nil, nil, nil, nil)
+	assert.Equal(t, ErrTokenRequired, err)
+
+	dir, err := ioutil.TempDir("", "relayadmin")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	storage, err := db.NewLevelDbStorage(dir, false)
+	require.Nil(t, err)
+	defer storage.Close()
+
+	identities := identitysrv.NewDBStorage(storage.WithPrefix([]byte("ids:")))
+	for i := byte(1); i <= 3; i++ {
+		require.Nil(t, identities.Add(&identitysrv.Identity{Id: newID(i)}))
+	}
+	journal := txjournal.New(storage.WithPrefix([]byte("txs:")))
+	tx := types.NewTransaction(1, common.Address{}, big.NewInt(0), 21000, big.NewInt(1), nil)
+	require.Nil(t, journal.RecordTx(tx, eth.TxMeta{Purpose: "setState"}))
+	publisher := &publisherMock{}
+	account := &accountMock{account: &accounts.Account{Address: common.Address{1}}}
+
+	admin, err := New(Config{Token: token}, identities, publisher, journal, storage, account)
+	require.Nil(t, err)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+	a := &testAdmin{t: t, server: server}
+
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, PathIdentities, "", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, a.do(http.MethodGet, PathIdentities, "wrong", nil, nil))
+
+	var identitiesRes IdentitiesResponse
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathIdentities+"?limit=2", token, nil, &identitiesRes))
+	assert.Equal(t, 3, identitiesRes.Total)
+	require.Equal(t, 2, len(identitiesRes.Identities))
+	assert.Equal(t, &merkletree.Hash{1}, identitiesRes.Identities[0].PublishStatus.IdenState)
+	assert.Nil(t, identitiesRes.Identities[1].PublishStatus)
+	assert.Equal(t, identitysrv.ErrIdentityNotFound.Error(), identitiesRes.Identities[1].PublishError)
+
+	var status PublishStatus
+	assert.Equal(t, http.StatusOK,
+		a.do(http.MethodPost, PathIdentities+"/"+newID(3).String()+"/resync", token, nil, &status))
+	assert.Equal(t, []core.ID{*newID(3)}, publisher.resynced)
+	assert.Equal(t, http.StatusNotFound,
+		a.do(http.MethodPost, PathIdentities+"/"+newID(2).String()+"/resync", token, nil, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed,
+		a.do(http.MethodGet, PathIdentities+"/"+newID(3).String()+"/resync", token, nil, nil))
+
+	var entries []txjournal.Entry
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathTxs+"?status=pending", token, nil, &entries))
+	require.Equal(t, 1, len(entries))
+	assert.Equal(t, tx.Hash(), entries[0].Hash)
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathTxs+"?status=failed", token, nil, &entries))
+	assert.Equal(t, 0, len(entries))
+	assert.Equal(t, http.StatusBadRequest, a.do(http.MethodGet, PathTxs+"?status=lost", token, nil, nil))
+
+	var costsRes CostsResponse
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathCosts, token, nil, &costsRes))
+	assert.Equal(t, &txjournal.Costs{Pending: 1, Wei: big.NewInt(0)}, costsRes.Total)
+	require.Nil(t, journal.MarkMined(tx.Hash(), txjournal.TxStatusConfirmed, "", 21000))
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathCosts, token, nil, &costsRes))
+	assert.Equal(t, &txjournal.Costs{Txs: 1, GasUsed: 21000, Wei: big.NewInt(21000)}, costsRes.Total)
+	assert.Equal(t, map[string]*txjournal.Costs{"": costsRes.Total}, costsRes.ByIdentity)
+	costsRes = CostsResponse{}
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathCosts+"?purpose=initState", token, nil, &costsRes))
+	assert.Equal(t, 0, costsRes.Total.Txs)
+	assert.Equal(t, "0", costsRes.Total.Wei.String())
+	assert.Equal(t, 0, len(costsRes.ByIdentity))
+
+	assert.Equal(t, http.StatusOK, a.do(http.MethodPost, PathCompact, token, nil, nil))
+	assert.Equal(t, http.StatusOK,
+		a.do(http.MethodPost, PathCompact, token, CompactRequest{Prefix: "0x747873"}, nil))
+	assert.Equal(t, http.StatusBadRequest,
+		a.do(http.MethodPost, PathCompact, token, CompactRequest{Prefix: "zz"}, nil))
+
+	var accountRes AccountResponse
+	require.Equal(t, http.StatusOK, a.do(http.MethodGet, PathAccount, token, nil, &accountRes))
+	assert.Equal(t, &common.Address{1}, accountRes.Address)
+	require.Equal(t, http.StatusOK,
+		a.do(http.MethodPost, PathAccount, token, AccountRequest{Address: common.Address{2}}, &accountRes))
+	assert.Equal(t, &common.Address{2}, accountRes.Address)
+	assert.Equal(t, http.StatusBadRequest,
+		a.do(http.MethodPost, PathAccount, token, AccountRequest{}, nil))
+}
+
+func TestAdminNotImplemented(t *testing.T) {
+	admin, err := New(Config{Token: token}, nil, nil, nil, db.NewMemoryStorage(), nil)
+	require.Nil(t, err)
+	server := httptest.NewServer(admin.Handler())
+	defer server.Close()
+	a := &testAdmin{t: t, server: server}
+	assert.Equal(t, http.StatusNotImplemented, a.do(http.MethodGet, PathIdentities, token, nil, nil))
+	assert.Equal(t, http.StatusNotImplemented, a.do(http.MethodGet, PathTxs, token, nil, nil))
+	assert.Equal(t, http.StatusNotImplemented, a.do(http.MethodGet, PathCosts, token, nil, nil))
+	assert.Equal(t, http.StatusNotImplemented, a.do(http.MethodPost, PathCompact, token, nil, nil))
+	assert.Equal(t, http.StatusNotImplemented, a.do(http.MethodGet, PathAccount, token, nil, nil))
+}
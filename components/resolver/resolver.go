@@ -0,0 +1,217 @@
+// Package resolver discovers where an identity publishes its off chain public
+// data given only its ID, so that a verifier doesn't need to be configured
+// for each issuer.  The Resolver tries a chain of Discoverers in order:
+// usually the on chain URL registry, the DID document of the identity and a
+// static map of known issuers.
+//
+// The DID document of an identity (see package did) can either list the
+// public data URL as a service of type did.ServiceTypePublicData, or link a
+// domain (a service of type did.ServiceTypeLinkedDomains) that serves the
+// public data at the well-known path:
+//
+//	https://<domain>/.well-known/iden3/<id>
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainreader"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/did"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// PathWellKnown is the path where a linked domain serves the public data of
+// the identities, followed by the ID.
+const PathWellKnown = "/.well-known/iden3/"
+
+var (
+	// ErrNotDiscovered is used when a Discoverer doesn't know the public
+	// data URL of an identity.
+	ErrNotDiscovered = errors.New("the public data URL of the identity was not discovered")
+)
+
+// Discoverer finds the off chain public data URL of an identity.
+type Discoverer interface {
+	// Discover returns the public data URL of id, or ErrNotDiscovered if
+	// it's unknown to the Discoverer.
+	Discover(id *core.ID) (string, error)
+}
+
+// OnChain discovers the public data URL registered in the Smart Contract,
+// like idenpubonchain.IdenPubOnChain.
+type OnChain struct {
+	Registry idenpuboffchainreader.PublicDataURLGetter
+}
+
+// Discover implements the Discoverer interface.
+func (d *OnChain) Discover(id *core.ID) (string, error) {
+	idPubUrl, err := d.Registry.GetPublicDataURL(id)
+	if err != nil {
+		return "", err
+	}
+	if idPubUrl == "" {
+		return "", ErrNotDiscovered
+	}
+	return idPubUrl, nil
+}
+
+// Static discovers the public data URLs of a fixed set of identities.
+type Static map[core.ID]string
+
+// Discover implements the Discoverer interface.
+func (d Static) Discover(id *core.ID) (string, error) {
+	idPubUrl, ok := d[*id]
+	if !ok {
+		return "", ErrNotDiscovered
+	}
+	return idPubUrl, nil
+}
+
+// PublicDataURL returns the public data URL of the identity id found in its
+// DID document, or ErrNotDiscovered.  A did.ServiceTypePublicData service
+// takes precedence over the well-known path of a did.ServiceTypeLinkedDomains
+// service.
+func PublicDataURL(doc *did.Document, id *core.ID) (string, error) {
+	for _, s := range doc.Service {
+		if s.Type == did.ServiceTypePublicData && s.ServiceEndpoint != "" {
+			return s.ServiceEndpoint, nil
+		}
+	}
+	for _, s := range doc.Service {
+		if s.Type != did.ServiceTypeLinkedDomains {
+			continue
+		}
+		u, err := url.Parse(s.ServiceEndpoint)
+		if err != nil || u.Scheme != "https" || u.Host == "" {
+			continue
+		}
+		return "https://" + u.Host + PathWellKnown + id.String(), nil
+	}
+	return "", ErrNotDiscovered
+}
+
+// DIDResolver discovers the public data URL in the DID document of the
+// identity, fetched from a DID resolver over HTTP at
+// <ResolverURL>/<did>, like the universal resolver
+// (https://dev.uniresolver.io/1.0/identifiers).
+type DIDResolver struct {
+	ResolverURL string
+	// Client is the HTTP client used for the requests.  If nil,
+	// http.DefaultClient is used.
+	Client *http.Client
+}
+
+// Document returns the DID document of the identity id.
+func (d *DIDResolver) Document(id *core.ID) (*did.Document, error) {
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Get(strings.TrimSuffix(d.ResolverURL, "/") + "/" + did.DID(id))
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, ErrNotDiscovered
+	} else if res.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(res.Body)
+		return nil, fmt.Errorf("GET %v: %v: %s", did.DID(id), res.Status, body)
+	}
+	var doc did.Document
+	if err := json.NewDecoder(res.Body).Decode(&doc); err != nil {
+		return nil, err
+	}
+	if doc.ID != did.DID(id) {
+		return nil, fmt.Errorf("the DID document %v doesn't belong to %v", doc.ID, did.DID(id))
+	}
+	return &doc, nil
+}
+
+// Discover implements the Discoverer interface.
+func (d *DIDResolver) Discover(id *core.ID) (string, error) {
+	doc, err := d.Document(id)
+	if err != nil {
+		return "", err
+	}
+	return PublicDataURL(doc, id)
+}
+
+// Resolver discovers the public data URL of the identities with a chain of
+// Discoverers and reads the public data with an
+// idenpuboffchainreader.IdenPubOffChainReadHttp.
+type Resolver struct {
+	reader      *idenpuboffchainreader.IdenPubOffChainReadHttp
+	discoverers []Discoverer
+}
+
+// New creates a Resolver that tries the discoverers in order and reads the
+// public data with reader.
+func New(reader *idenpuboffchainreader.IdenPubOffChainReadHttp, discoverers ...Discoverer) *Resolver {
+	return &Resolver{reader: reader, discoverers: discoverers}
+}
+
+// Discover returns the public data URL of id from the first Discoverer that
+// knows it.  The Discoverers that fail are skipped, and their errors are
+// returned wrapping ErrNotDiscovered if none succeeds.
+func (r *Resolver) Discover(id *core.ID) (string, error) {
+	var errs []string
+	for i, d := range r.discoverers {
+		idPubUrl, err := d.Discover(id)
+		if err == nil {
+			return idPubUrl, nil
+		} else if err != ErrNotDiscovered {
+			errs = append(errs, fmt.Sprintf("discoverer %d: %v", i, err))
+		}
+	}
+	if len(errs) != 0 {
+		return "", fmt.Errorf("%w: %v", ErrNotDiscovered, strings.Join(errs, "; "))
+	}
+	return "", ErrNotDiscovered
+}
+
+// Resolve returns a Reader of the public data of id, connected to its
+// discovered public data URL.
+func (r *Resolver) Resolve(id *core.ID) (*Reader, error) {
+	idPubUrl, err := r.Discover(id)
+	if err != nil {
+		return nil, err
+	}
+	return &Reader{ID: id, IdPubUrl: idPubUrl, reader: r.reader}, nil
+}
+
+// GetPublicData returns the public data of the identity state idenState of id
+// (or the last one if idenState is nil) read from idPubUrl, or from the
+// discovered URL if idPubUrl is empty.  It satisfies the
+// sigverify.PublicDataGetter interface.
+func (r *Resolver) GetPublicData(idPubUrl string, id *core.ID, idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	if idPubUrl == "" {
+		var err error
+		if idPubUrl, err = r.Discover(id); err != nil {
+			return nil, err
+		}
+	}
+	return r.reader.GetPublicData(idPubUrl, id, idenState)
+}
+
+// Reader reads the off chain public data of an identity from its discovered
+// public data URL.
+type Reader struct {
+	ID       *core.ID
+	IdPubUrl string
+	reader   *idenpuboffchainreader.IdenPubOffChainReadHttp
+}
+
+// GetPublicData returns the public data of the identity state idenState, or
+// of the last published state if idenState is nil.
+func (r *Reader) GetPublicData(idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	return r.reader.GetPublicData(r.IdPubUrl, r.ID, idenState)
+}
@@ -0,0 +1,94 @@
+package resolver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainreader"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/did"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// registry is a PublicDataURLGetter of a map of URLs by ID.
+type registry map[core.ID]string
+
+func (r registry) GetPublicDataURL(id *core.ID) (string, error) {
+	return r[*id], nil
+}
+
+type failingDiscoverer struct{}
+
+func (failingDiscoverer) Discover(id *core.ID) (string, error) {
+	return "", errors.New("unavailable")
+}
+
+func TestResolver(t *testing.T) {
+	id0, id1, id2, id3, id4 := core.ID{0}, core.ID{1}, core.ID{2}, core.ID{3}, core.ID{4}
+	objects := map[string]interface{}{}
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		object, ok := objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(object)
+	}))
+	defer server.Close()
+
+	for i := 0; i < 4; i++ {
+		objects[fmt.Sprintf("/iden%d/latest", i)] = &idenpuboffchainwriter.PublicData{IdenState: merkletree.Hash{byte(i)}}
+	}
+	objects[PathWellKnown+id2.String()+"/latest"] = &idenpuboffchainwriter.PublicData{IdenState: merkletree.Hash{2}}
+	objects["/dids/"+did.DID(&id1)] = did.Document{ID: did.DID(&id1), Service: []did.Service{
+		{Type: did.ServiceTypePublicData, ServiceEndpoint: server.URL + "/iden1"},
+	}}
+	objects["/dids/"+did.DID(&id2)] = did.Document{ID: did.DID(&id2), Service: []did.Service{
+		{Type: did.ServiceTypeLinkedDomains, ServiceEndpoint: server.URL + "/"},
+	}}
+	// A DID document of another identity
+	objects["/dids/"+did.DID(&id4)] = did.Document{ID: did.DID(&id0), Service: []did.Service{
+		{Type: did.ServiceTypePublicData, ServiceEndpoint: server.URL + "/iden0"},
+	}}
+
+	reader := &idenpuboffchainreader.IdenPubOffChainReadHttp{Client: server.Client()}
+	r := New(reader,
+		&OnChain{Registry: registry{id0: server.URL + "/iden0"}},
+		&DIDResolver{ResolverURL: server.URL + "/dids", Client: server.Client()},
+		Static{id3: server.URL + "/iden3"},
+	)
+
+	for i, id := range []core.ID{id0, id1, id2, id3} {
+		idReader, err := r.Resolve(&id)
+		require.Nil(t, err, i)
+		publicData, err := idReader.GetPublicData(nil)
+		require.Nil(t, err, i)
+		assert.Equal(t, merkletree.Hash{byte(i)}, publicData.IdenState, i)
+	}
+	idPubUrl, err := r.Discover(&id2)
+	require.Nil(t, err)
+	assert.Equal(t, server.URL+PathWellKnown+id2.String(), idPubUrl)
+
+	// The Resolver is a sigverify.PublicDataGetter
+	publicData, err := r.GetPublicData("", &id1, nil)
+	require.Nil(t, err)
+	assert.Equal(t, merkletree.Hash{1}, publicData.IdenState)
+
+	_, err = r.Resolve(&id4)
+	assert.True(t, errors.Is(err, ErrNotDiscovered))
+	assert.NotEqual(t, ErrNotDiscovered, err)
+
+	// Failing discoverers are skipped
+	r = New(reader, failingDiscoverer{}, Static{id3: server.URL + "/iden3"})
+	_, err = r.Resolve(&id3)
+	require.Nil(t, err)
+	_, err = r.Resolve(&id0)
+	assert.True(t, errors.Is(err, ErrNotDiscovered))
+}
@@ -0,0 +1,59 @@
+package sigverify
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathRevocations is the path of the batch revocations endpoint.
+const PathRevocations = "/revocations"
+
+// MaxNonces is the maximum number of nonces checked in a request to the
+// revocations endpoint.
+const MaxNonces = 1000
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// RevocationsRequest is the body of a request to the revocations endpoint.
+type RevocationsRequest struct {
+	Issuer *core.ID `json:"issuer"`
+	Nonces []uint32 `json:"nonces"`
+}
+
+// Handler returns an http.Handler that serves CheckRevocations at POST
+// PathRevocations, with a RevocationsRequest body and a Revocations
+// response.
+func (s *SigVerifier) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathRevocations, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var revReq RevocationsRequest
+		if err := json.NewDecoder(req.Body).Decode(&revReq); err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		if revReq.Issuer == nil || len(revReq.Nonces) == 0 || len(revReq.Nonces) > MaxNonces {
+			httpjson.WriteError(w, http.StatusBadRequest, "an issuer and between 1 and 1000 nonces are required")
+			return
+		}
+		revocations, err := s.CheckRevocations(revReq.Issuer, revReq.Nonces)
+		switch err {
+		case nil:
+			httpjson.Write(w, http.StatusOK, revocations)
+		case ErrIdenStateOnChainZero:
+			httpjson.WriteError(w, http.StatusNotFound, err.Error())
+		default:
+			log.WithError(err).WithField("issuer", revReq.Issuer).Error("Unable to check the revocations")
+			httpjson.WriteError(w, http.StatusBadGateway, err.Error())
+		}
+	})
+	return mux
+}
@@ -0,0 +1,48 @@
+package sigverify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	iden := newIdentity(t, idenPubOnChain)
+	require.Nil(t, claims.AddLeafRevocationsTree(iden.ret, 3, claims.RevocationVersionAll))
+	iden.publish(t, 12)
+	server := httptest.NewServer(New(idenPubOnChain, &publicDataFixed{publicData: iden.publicData}).Handler())
+	defer server.Close()
+
+	post := func(body interface{}) *http.Response {
+		b, err := json.Marshal(body)
+		require.Nil(t, err)
+		res, err := http.Post(server.URL+PathRevocations, "application/json", bytes.NewReader(b))
+		require.Nil(t, err)
+		return res
+	}
+
+	res := post(RevocationsRequest{Issuer: &iden.id, Nonces: []uint32{3, 4}})
+	defer res.Body.Close()
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	var revocations Revocations
+	require.Nil(t, json.NewDecoder(res.Body).Decode(&revocations))
+	assert.Equal(t, &iden.id, revocations.Issuer)
+	assert.Equal(t, []RevocationStatus{{3, true}, {4, false}}, revocations.Statuses)
+
+	res = post(RevocationsRequest{Issuer: &iden.id})
+	res.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, res.StatusCode)
+
+	res, err := http.Get(server.URL + PathRevocations)
+	require.Nil(t, err)
+	res.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, res.StatusCode)
+}
@@ -0,0 +1,242 @@
+package sigverify
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// MaxLevelsRevocationsTree is the number of levels used to import the
+// revocations tree from the public data.
+const MaxLevelsRevocationsTree = 140
+
+var (
+	ErrIdenStateOnChainZero   = fmt.Errorf("the identity has no state on chain")
+	ErrPublicDataDoesntMatch  = fmt.Errorf("the public data doesn't match the identity state on chain")
+	ErrClaimKSignRevoked      = verifier.ErrClaimKSignRevoked
+	ErrInvalidPublicDataProof = fmt.Errorf("unable to generate the revocation proof from the public data")
+	ErrInvalidClaimEthKey     = fmt.Errorf("the credential claim is not a ClaimAuthEthKey of the required type")
+	ErrEthKeyDoesntMatch      = fmt.Errorf("the signer address doesn't match the ClaimAuthEthKey")
+	ErrClaimEthKeyRevoked     = fmt.Errorf("the ClaimAuthEthKey is revoked")
+	ErrNonceDoesntMatch       = fmt.Errorf("the nonce of the ownership proof doesn't match")
+)
+
+// PublicDataGetter is an interface to get the off chain public data of an
+// identity, satisfied by idenpuboffchainreader.IdenPubOffChainReadHttp.
+type PublicDataGetter interface {
+	GetPublicData(idPubUrl string, id *core.ID, idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error)
+}
+
+// SigVerifier verifies signatures made by the keys authorized by identities
+// with a ClaimAuthorizeKSignBabyJub or a ClaimAuthEthKey, checking that the
+// claim is not revoked in the last identity state.
+type SigVerifier struct {
+	verifier        *verifier.Verifier
+	idenPubOnChain  idenpubonchain.IdenPubOnChainer
+	idenPubOffChain PublicDataGetter
+}
+
+var _ verifier.RevocationChecker = (*SigVerifier)(nil)
+
+// New creates a new SigVerifier.
+func New(idenPubOnChain idenpubonchain.IdenPubOnChainer, idenPubOffChain PublicDataGetter) *SigVerifier {
+	s := &SigVerifier{
+		verifier:        verifier.New(idenPubOnChain),
+		idenPubOnChain:  idenPubOnChain,
+		idenPubOffChain: idenPubOffChain,
+	}
+	s.verifier.SetRevocationChecker(s)
+	return s
+}
+
+// Verifier returns the verifier.Verifier of s, which checks the revocation
+// of the signing claims with s.
+func (s *SigVerifier) Verifier() *verifier.Verifier {
+	return s.verifier
+}
+
+// VerifySignature verifies that sig is a signature of prefix|msg (as
+// generated by Issuer.SignBinary) by the identity id.  credKSign is the
+// existence credential of the ClaimAuthorizeKSignBabyJub of the signing key,
+// issued by id.  The public data of the last state of id is fetched to check
+// that the claim is not revoked.
+func (s *SigVerifier) VerifySignature(id *core.ID, credKSign *proof.CredentialExistence,
+	prefix, msg []byte, sig *babyjub.SignatureComp) error {
+	return s.verifier.VerifySignature(id, credKSign, prefix, msg, sig)
+}
+
+// VerifySignatureDelegated is verifier.Verifier.VerifySignatureDelegated,
+// checking that the ClaimDelegate and the ClaimAuthorizeKSignBabyJub are not
+// revoked in the last identity state of their issuer.
+func (s *SigVerifier) VerifySignatureDelegated(id *core.ID, scope claims.DelegateScope,
+	credDelegate, credKSign *proof.CredentialExistence, prefix, msg []byte, sig *babyjub.SignatureComp) error {
+	return s.verifier.VerifySignatureDelegated(id, scope, credDelegate, credKSign, prefix, msg, sig)
+}
+
+// IsRevoked returns true if the claim of cred is revoked in the last identity
+// state of its issuer.  The public data of the issuer is fetched to check it.
+func (s *SigVerifier) IsRevoked(cred *proof.CredentialExistence) (bool, error) {
+	revocations, err := s.checkRevocations(cred.Id, cred.IdPubUrl, []uint32{claims.GetRevocationNonce(cred.Claim)})
+	if err != nil {
+		return false, err
+	}
+	return revocations.Statuses[0].Revoked, nil
+}
+
+// VerifyOwnership verifies that op proves the control of its identity: it
+// responds to nonce, chosen by the caller for this verification, and it's
+// signed by a key authorized by the identity with op.CredKSign, which must
+// not be revoked in the last identity state.
+func (s *SigVerifier) VerifyOwnership(op *proof.OwnershipProof, nonce []byte) error {
+	if len(nonce) == 0 {
+		return proof.ErrOwnershipNonceEmpty
+	}
+	if !bytes.Equal(op.Nonce, nonce) {
+		return ErrNonceDoesntMatch
+	}
+	if op.Id == nil || op.CredKSign == nil || op.Signature == nil {
+		return verifier.ErrInvalidSignature
+	}
+	return s.VerifySignature(op.Id, op.CredKSign, proof.SigPrefixOwnership, op.SigMsg(), op.Signature)
+}
+
+// VerifyEthMsgSignature verifies that sig is an EIP-191 signature
+// (personal_sign) of msg by an ethereum key authorized by the identity id.
+// credEthKey is the existence credential of the ClaimAuthEthKey of the
+// signing address with type ethKeyType, issued by id.  The public data of the
+// last state of id is fetched to check that the claim is not revoked.
+func (s *SigVerifier) VerifyEthMsgSignature(id *core.ID, credEthKey *proof.CredentialExistence,
+	ethKeyType claims.EthKeyType, msg []byte, sig *crypto.SignatureEthMsg) error {
+	addr, err := crypto.RecoverAddrEthMsg(sig, msg)
+	if err != nil {
+		return err
+	}
+	return s.verifyEthKey(id, credEthKey, ethKeyType, addr)
+}
+
+// VerifyEthTypedDataSignature verifies that sig is an EIP-712 signature
+// (eth_signTypedData) of typedData by an ethereum key authorized by the
+// identity id.  credEthKey is the existence credential of the ClaimAuthEthKey
+// of the signing address with type ethKeyType, issued by id.  The public data
+// of the last state of id is fetched to check that the claim is not revoked.
+func (s *SigVerifier) VerifyEthTypedDataSignature(id *core.ID, credEthKey *proof.CredentialExistence,
+	ethKeyType claims.EthKeyType, typedData *signercore.TypedData, sig *crypto.SignatureEthMsg) error {
+	addr, err := crypto.RecoverAddrEthTypedData(sig, typedData)
+	if err != nil {
+		return err
+	}
+	return s.verifyEthKey(id, credEthKey, ethKeyType, addr)
+}
+
+// verifyEthKey checks that credEthKey is a valid credential of a non revoked
+// ClaimAuthEthKey of addr with type ethKeyType issued by id.
+func (s *SigVerifier) verifyEthKey(id *core.ID, credEthKey *proof.CredentialExistence,
+	ethKeyType claims.EthKeyType, addr common.Address) error {
+	if !credEthKey.Id.Equal(id) {
+		return verifier.ErrCredentialIdDoesntMatch
+	}
+	claim, err := claims.NewClaimFromEntry(credEthKey.Claim)
+	if err != nil {
+		return err
+	}
+	claimEthKey, ok := claim.(*claims.ClaimAuthEthKey)
+	if !ok || claimEthKey.EthKeyType != binary.BigEndian.Uint32(ethKeyType[:]) {
+		return ErrInvalidClaimEthKey
+	}
+	if claimEthKey.EthKey != addr {
+		return ErrEthKeyDoesntMatch
+	}
+	if err := s.verifier.VerifyCredentialExistence(credEthKey); err != nil {
+		return err
+	}
+	return s.checkNotRevoked(credEthKey, ErrClaimEthKeyRevoked)
+}
+
+// checkNotRevoked checks that the claim of the credential is not revoked in
+// the last identity state of id, returning errRevoked if it is.
+func (s *SigVerifier) checkNotRevoked(cred *proof.CredentialExistence, errRevoked error) error {
+	revoked, err := s.IsRevoked(cred)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errRevoked
+	}
+	return nil
+}
+
+// RevocationStatus is the revocation status of a claim revocation nonce.
+type RevocationStatus struct {
+	Nonce   uint32 `json:"nonce"`
+	Revoked bool   `json:"revoked"`
+}
+
+// Revocations is the revocation status of a set of claim revocation nonces
+// of an issuer, in its last identity state.
+type Revocations struct {
+	Issuer        *core.ID             `json:"issuer"`
+	IdenStateData *proof.IdenStateData `json:"idenStateData"`
+	// Statuses has the status of each nonce in the order requested.
+	Statuses []RevocationStatus `json:"statuses"`
+}
+
+// CheckRevocations returns the revocation status of the claims with the
+// revocation nonces in the last identity state of the issuer issuerID.  The
+// public data of the issuer is fetched, and its revocations tree imported,
+// once for all the nonces, so that services validating many credentials of
+// the same issuer can check them together.
+func (s *SigVerifier) CheckRevocations(issuerID *core.ID, nonces []uint32) (*Revocations, error) {
+	return s.checkRevocations(issuerID, "", nonces)
+}
+
+func (s *SigVerifier) checkRevocations(id *core.ID, idPubUrl string, nonces []uint32) (*Revocations, error) {
+	idenStateData, err := s.idenPubOnChain.GetState(id)
+	if err != nil {
+		return nil, err
+	}
+	if idenStateData.IdenState == nil || idenStateData.IdenState.Equals(&merkletree.HashZero) {
+		return nil, ErrIdenStateOnChainZero
+	}
+	publicData, err := s.idenPubOffChain.GetPublicData(idPubUrl, id, idenStateData.IdenState)
+	if err != nil {
+		return nil, err
+	}
+	idenState := core.IdenState(&publicData.ClaimsTreeRoot, &publicData.RevocationsTreeRoot,
+		&publicData.RootsTreeRoot)
+	if !idenState.Equals(idenStateData.IdenState) || !publicData.IdenState.Equals(idenState) {
+		return nil, ErrPublicDataDoesntMatch
+	}
+	ret, err := merkletree.NewMerkleTreeInMemory(MaxLevelsRevocationsTree)
+	if err != nil {
+		return nil, err
+	}
+	if err := ret.ImportTree(bytes.NewReader(publicData.RevocationsTree)); err != nil {
+		return nil, err
+	}
+	revocations := &Revocations{
+		Issuer:        id,
+		IdenStateData: idenStateData,
+		Statuses:      make([]RevocationStatus, len(nonces)),
+	}
+	for i, nonce := range nonces {
+		mtp, err := ret.GenerateProof(claims.HIndexLeafRevocationsTree(nonce, claims.RevocationVersionAll),
+			&publicData.RevocationsTreeRoot)
+		if err != nil {
+			return nil, ErrInvalidPublicDataProof
+		}
+		revocations.Statuses[i] = RevocationStatus{Nonce: nonce, Revoked: mtp.Existence}
+	}
+	return revocations, nil
+}
@@ -0,0 +1,284 @@
+package sigverify
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common/math"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type publicDataFixed struct {
+	publicData *idenpuboffchainwriter.PublicData
+}
+
+func (p *publicDataFixed) GetPublicData(idPubUrl string, id *core.ID,
+	idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	if !idenState.Equals(&p.publicData.IdenState) {
+		return nil, idenpuboffchainwriter.ErrIdenStateNotFound
+	}
+	return p.publicData, nil
+}
+
+// identity holds the trees of an identity built without an Issuer.
+type identity struct {
+	id                 core.ID
+	clt, ret, rot      *merkletree.MerkleTree
+	idenStateData      *proof.IdenStateData
+	publicData         *idenpuboffchainwriter.PublicData
+	idenPubOnChainMock *idenpubonchain.IdenPubOnChainMock
+}
+
+func newIdentity(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock) *identity {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	iden := &identity{id: id, idenPubOnChainMock: idenPubOnChain}
+	for _, mt := range []**merkletree.MerkleTree{&iden.clt, &iden.ret, &iden.rot} {
+		*mt, err = merkletree.NewMerkleTreeInMemory(140)
+		require.Nil(t, err)
+	}
+	return iden
+}
+
+// publish publishes the current identity state in blockN.
+func (iden *identity) publish(t *testing.T, blockN uint64) {
+	if err := claims.AddLeafRootsTree(iden.rot, iden.clt.RootKey()); err != merkletree.ErrEntryIndexAlreadyExists {
+		require.Nil(t, err)
+	}
+	idenState := core.IdenState(iden.clt.RootKey(), iden.ret.RootKey(), iden.rot.RootKey())
+	iden.idenStateData = &proof.IdenStateData{IdenState: idenState, BlockN: blockN, BlockTs: int64(blockN) * 100}
+	var rotBlob, retBlob bytes.Buffer
+	require.Nil(t, iden.rot.DumpTree(&rotBlob, nil))
+	require.Nil(t, iden.ret.DumpTree(&retBlob, nil))
+	iden.publicData = &idenpuboffchainwriter.PublicData{
+		IdenState:           *idenState,
+		ClaimsTreeRoot:      *iden.clt.RootKey(),
+		RootsTreeRoot:       *iden.rot.RootKey(),
+		RootsTree:           rotBlob.Bytes(),
+		RevocationsTreeRoot: *iden.ret.RootKey(),
+		RevocationsTree:     retBlob.Bytes(),
+	}
+	iden.idenPubOnChainMock.On("GetState", &iden.id).Return(iden.idenStateData, nil).Once()
+	iden.idenPubOnChainMock.On("GetStateByBlock", &iden.id, blockN).Return(iden.idenStateData, nil)
+}
+
+func (iden *identity) credential(t *testing.T, claim merkletree.Entrier) *proof.CredentialExistence {
+	mtp, err := iden.clt.GenerateProof(claim.Entry().HIndex(), nil)
+	require.Nil(t, err)
+	return &proof.CredentialExistence{
+		Id:              &iden.id,
+		IdenStateData:   *iden.idenStateData,
+		MtpClaim:        mtp,
+		Claim:           claim.Entry(),
+		RevocationsRoot: iden.ret.RootKey(),
+		RootsRoot:       iden.rot.RootKey(),
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	pass := []byte("my passphrase")
+	kSignComp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kSignComp, pass))
+	kSign, err := kSignComp.Decompress()
+	require.Nil(t, err)
+
+	idenPubOnChain := idenpubonchain.New()
+	iden := newIdentity(t, idenPubOnChain)
+	claimKSign := claims.NewClaimAuthorizeKSignBabyJub(kSign, 7)
+	require.Nil(t, iden.clt.AddClaim(claimKSign))
+	iden.publish(t, 12)
+	credKSign := iden.credential(t, claimKSign)
+
+	publicData := &publicDataFixed{}
+	s := New(idenPubOnChain, publicData)
+
+	prefix, msg := []byte("auth:"), []byte("challenge")
+	sig, err := keyStore.SignRaw(kSignComp, append(append([]byte{}, prefix...), msg...))
+	require.Nil(t, err)
+
+	publicData.publicData = iden.publicData
+	assert.Nil(t, s.VerifySignature(&iden.id, credKSign, prefix, msg, sig))
+
+	assert.Equal(t, verifier.ErrInvalidSignature,
+		s.VerifySignature(&iden.id, credKSign, prefix, []byte("other"), sig))
+
+	// Public data that doesn't match the state on chain
+	iden.idenPubOnChainMock.On("GetState", &iden.id).Return(iden.idenStateData, nil).Once()
+	badPublicData := *iden.publicData
+	badPublicData.RevocationsTreeRoot = merkletree.Hash{1}
+	publicData.publicData = &badPublicData
+	assert.Equal(t, ErrPublicDataDoesntMatch, s.VerifySignature(&iden.id, credKSign, prefix, msg, sig))
+
+	// The claim is revoked in a later state
+	require.Nil(t, claims.AddLeafRevocationsTree(iden.ret, 7, claims.RevocationVersionAll))
+	iden.publish(t, 13)
+	publicData.publicData = iden.publicData
+	assert.Equal(t, ErrClaimKSignRevoked, s.VerifySignature(&iden.id, credKSign, prefix, msg, sig))
+}
+
+// keySigner signs on behalf of an identity with a key of a keystore.
+type keySigner struct {
+	id       *core.ID
+	keyStore *keystore.KeyStore
+	kSign    *babyjub.PublicKeyComp
+}
+
+func (s *keySigner) ID() *core.ID { return s.id }
+
+func (s *keySigner) SignBinary(prefix, msg []byte) (*babyjub.SignatureComp, error) {
+	return s.keyStore.SignRaw(s.kSign, append(append([]byte{}, prefix...), msg...))
+}
+
+func TestVerifyOwnership(t *testing.T) {
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	pass := []byte("my passphrase")
+	kSignComp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kSignComp, pass))
+	kSign, err := kSignComp.Decompress()
+	require.Nil(t, err)
+
+	idenPubOnChain := idenpubonchain.New()
+	iden := newIdentity(t, idenPubOnChain)
+	claimKSign := claims.NewClaimAuthorizeKSignBabyJub(kSign, 7)
+	require.Nil(t, iden.clt.AddClaim(claimKSign))
+	iden.publish(t, 12)
+	credKSign := iden.credential(t, claimKSign)
+	s := New(idenPubOnChain, &publicDataFixed{publicData: iden.publicData})
+
+	signer := &keySigner{id: &iden.id, keyStore: keyStore, kSign: kSignComp}
+	_, err = proof.NewOwnershipProof(signer, nil, credKSign)
+	assert.Equal(t, proof.ErrOwnershipNonceEmpty, err)
+	nonce := []byte("exchange nonce")
+	op, err := proof.NewOwnershipProof(signer, nonce, credKSign)
+	require.Nil(t, err)
+	assert.Nil(t, s.VerifyOwnership(op, nonce))
+
+	// The proof is replayed with another nonce.
+	assert.Equal(t, ErrNonceDoesntMatch, s.VerifyOwnership(op, []byte("other nonce")))
+	assert.Equal(t, proof.ErrOwnershipNonceEmpty, s.VerifyOwnership(op, nil))
+
+	// The proof is presented for another identity.
+	opOther := *op
+	opOther.Id = &core.ID{1}
+	assert.Equal(t, verifier.ErrCredentialIdDoesntMatch, s.VerifyOwnership(&opOther, nonce))
+
+	// The nonce is changed after signing.
+	opOther = *op
+	opOther.Nonce = []byte("other nonce")
+	assert.Equal(t, verifier.ErrInvalidSignature, s.VerifyOwnership(&opOther, opOther.Nonce))
+
+	opOther = *op
+	opOther.Signature = nil
+	assert.Equal(t, verifier.ErrInvalidSignature, s.VerifyOwnership(&opOther, nonce))
+}
+
+func TestVerifyEthSignature(t *testing.T) {
+	ethKey, err := ethcrypto.GenerateKey()
+	require.Nil(t, err)
+	addr := ethcrypto.PubkeyToAddress(ethKey.PublicKey)
+	signHash := func(hash crypto.Hash) *crypto.SignatureEthMsg {
+		sig, err := ethcrypto.Sign(hash[:], ethKey)
+		require.Nil(t, err)
+		sig[64] += 27
+		var sigEthMsg crypto.SignatureEthMsg
+		copy(sigEthMsg[:], sig)
+		return &sigEthMsg
+	}
+
+	idenPubOnChain := idenpubonchain.New()
+	iden := newIdentity(t, idenPubOnChain)
+	claimEthKey := claims.NewClaimAuthEthKey(addr, claims.EthKeyTypeAuthenticate, 9)
+	require.Nil(t, iden.clt.AddClaim(claimEthKey))
+	iden.publish(t, 12)
+	credEthKey := iden.credential(t, claimEthKey)
+
+	publicData := &publicDataFixed{publicData: iden.publicData}
+	s := New(idenPubOnChain, publicData)
+
+	// EIP-191
+	msg := []byte("login challenge")
+	sigMsg := signHash(crypto.EthHash(msg))
+	assert.Nil(t, s.VerifyEthMsgSignature(&iden.id, credEthKey, claims.EthKeyTypeAuthenticate, msg, sigMsg))
+
+	// EIP-712
+	typedData := &signercore.TypedData{
+		Types: signercore.Types{
+			"EIP712Domain": []signercore.Type{
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+			},
+			"Login": []signercore.Type{
+				{Name: "challenge", Type: "string"},
+			},
+		},
+		PrimaryType: "Login",
+		Domain: signercore.TypedDataDomain{
+			Name:    "iden3",
+			Version: "1",
+			ChainId: math.NewHexOrDecimal256(1),
+		},
+		Message: signercore.TypedDataMessage{"challenge": "login challenge"},
+	}
+	hash, err := crypto.EthTypedDataHash(typedData)
+	require.Nil(t, err)
+	sigTypedData := signHash(hash)
+	iden.idenPubOnChainMock.On("GetState", &iden.id).Return(iden.idenStateData, nil).Once()
+	assert.Nil(t, s.VerifyEthTypedDataSignature(&iden.id, credEthKey, claims.EthKeyTypeAuthenticate,
+		typedData, sigTypedData))
+
+	// Signature of another message
+	assert.Equal(t, ErrEthKeyDoesntMatch, s.VerifyEthMsgSignature(&iden.id, credEthKey,
+		claims.EthKeyTypeAuthenticate, []byte("other"), sigMsg))
+	// The key is not authorized for the required type
+	assert.Equal(t, ErrInvalidClaimEthKey, s.VerifyEthMsgSignature(&iden.id, credEthKey,
+		claims.EthKeyTypeUpgrade, msg, sigMsg))
+
+	// The claim is revoked in a later state
+	require.Nil(t, claims.AddLeafRevocationsTree(iden.ret, 9, claims.RevocationVersionAll))
+	iden.publish(t, 13)
+	publicData.publicData = iden.publicData
+	assert.Equal(t, ErrClaimEthKeyRevoked, s.VerifyEthMsgSignature(&iden.id, credEthKey,
+		claims.EthKeyTypeAuthenticate, msg, sigMsg))
+}
+
+func TestCheckRevocations(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	iden := newIdentity(t, idenPubOnChain)
+	require.Nil(t, claims.AddLeafRevocationsTree(iden.ret, 3, claims.RevocationVersionAll))
+	require.Nil(t, claims.AddLeafRevocationsTree(iden.ret, 9, claims.RevocationVersionAll))
+	iden.publish(t, 12)
+
+	s := New(idenPubOnChain, &publicDataFixed{publicData: iden.publicData})
+	revocations, err := s.CheckRevocations(&iden.id, []uint32{1, 3, 7, 9})
+	require.Nil(t, err)
+	assert.Equal(t, &iden.id, revocations.Issuer)
+	assert.Equal(t, iden.idenStateData, revocations.IdenStateData)
+	assert.Equal(t, []RevocationStatus{{1, false}, {3, true}, {7, false}, {9, true}}, revocations.Statuses)
+
+	// The identity has no state on chain
+	other := core.NewID(core.TypeBJP0, [27]byte{1})
+	idenPubOnChain.On("GetState", &other).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero}, nil).Once()
+	_, err = s.CheckRevocations(&other, []uint32{1})
+	assert.Equal(t, ErrIdenStateOnChainZero, err)
+}
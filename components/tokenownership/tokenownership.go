@@ -0,0 +1,260 @@
+package tokenownership
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	ErrInvalidOwnerSignature = fmt.Errorf("the signature doesn't prove the control of the owner address")
+	ErrNotTokenOwner         = fmt.Errorf("the owner address doesn't own the token at the block")
+	ErrBalanceBelowBuckets   = fmt.Errorf("the owner address balance is below all the buckets at the block")
+	ErrInvalidClaim          = fmt.Errorf("the credential claim is not a ClaimTokenOwnership")
+	ErrIdDoesntMatch         = fmt.Errorf("the ClaimTokenOwnership is not about the identity")
+	ErrBlockHashMismatch     = fmt.Errorf("the block hash of the ClaimTokenOwnership doesn't match the chain")
+)
+
+// erc20And721ABI contains the read only methods of ERC-20 and ERC-721
+// contracts used to check the ownership of tokens.
+const erc20And721ABI = `[
+{"constant":true,"inputs":[{"name":"owner","type":"address"}],"name":"balanceOf","outputs":[{"name":"","type":"uint256"}],"payable":false,"stateMutability":"view","type":"function"},
+{"constant":true,"inputs":[{"name":"tokenId","type":"uint256"}],"name":"ownerOf","outputs":[{"name":"","type":"address"}],"payable":false,"stateMutability":"view","type":"function"}
+]`
+
+// TokenReader is an interface to read the ownership of tokens at a block.
+type TokenReader interface {
+	BalanceOf(contract, owner common.Address, blockN uint64) (*big.Int, error)
+	OwnerOf(contract common.Address, tokenId *big.Int, blockN uint64) (common.Address, error)
+	BlockHash(blockN uint64) (common.Hash, error)
+}
+
+// EthTokenReader is the regular implementation of TokenReader, that queries
+// the token contracts with an ethereum client.  Querying past blocks requires
+// an archive node.
+type EthTokenReader struct {
+	client *eth.Client2
+	abi    abi.ABI
+}
+
+// NewEthTokenReader creates a new EthTokenReader.
+func NewEthTokenReader(client *eth.Client2) (*EthTokenReader, error) {
+	parsed, err := abi.JSON(strings.NewReader(erc20And721ABI))
+	if err != nil {
+		return nil, err
+	}
+	return &EthTokenReader{client: client, abi: parsed}, nil
+}
+
+func (r *EthTokenReader) call(contract common.Address, blockN uint64, out interface{}, method string, params ...interface{}) error {
+	return r.client.Call(func(c *ethclient.Client) error {
+		bc := bind.NewBoundContract(contract, r.abi, c, c, c)
+		opts := &bind.CallOpts{BlockNumber: new(big.Int).SetUint64(blockN)}
+		return bc.Call(opts, out, method, params...)
+	})
+}
+
+// BalanceOf returns the balance of owner in the ERC-20 contract at blockN.
+func (r *EthTokenReader) BalanceOf(contract, owner common.Address, blockN uint64) (*big.Int, error) {
+	var balance *big.Int
+	if err := r.call(contract, blockN, &balance, "balanceOf", owner); err != nil {
+		return nil, err
+	}
+	return balance, nil
+}
+
+// OwnerOf returns the owner of tokenId in the ERC-721 contract at blockN.
+func (r *EthTokenReader) OwnerOf(contract common.Address, tokenId *big.Int, blockN uint64) (common.Address, error) {
+	var owner common.Address
+	if err := r.call(contract, blockN, &owner, "ownerOf", tokenId); err != nil {
+		return common.Address{}, err
+	}
+	return owner, nil
+}
+
+// BlockHash returns the hash of the block blockN.
+func (r *EthTokenReader) BlockHash(blockN uint64) (common.Hash, error) {
+	var hash common.Hash
+	err := r.client.Call(func(c *ethclient.Client) error {
+		header, err := c.HeaderByNumber(context.Background(), new(big.Int).SetUint64(blockN))
+		if err != nil {
+			return err
+		}
+		hash = header.Hash()
+		return nil
+	})
+	return hash, err
+}
+
+// OwnershipMsg returns the message that the owner address must sign
+// (EIP-191) to prove that it's controlled by the identity id.
+func OwnershipMsg(id *core.ID) []byte {
+	return []byte("iden3 token ownership: " + id.String())
+}
+
+// Attester issues ClaimTokenOwnership claims with an Issuer after checking
+// the ownership of the tokens on chain.
+type Attester struct {
+	issuer *issuer.Issuer
+	tokens TokenReader
+}
+
+// NewAttester creates a new Attester.
+func NewAttester(is *issuer.Issuer, tokens TokenReader) *Attester {
+	return &Attester{issuer: is, tokens: tokens}
+}
+
+// checkOwner checks that sig is a signature of OwnershipMsg(id) by owner.
+func checkOwner(id *core.ID, owner common.Address, sig *crypto.SignatureEthMsg) error {
+	addr, err := crypto.RecoverAddrEthMsg(sig, OwnershipMsg(id))
+	if err != nil {
+		return err
+	}
+	if addr != owner {
+		return ErrInvalidOwnerSignature
+	}
+	return nil
+}
+
+func (a *Attester) issue(id *core.ID, standard claims.TokenStandard, contract common.Address, amount *big.Int,
+	owner common.Address, blockN uint64) (*claims.ClaimTokenOwnership, error) {
+	blockHash, err := a.tokens.BlockHash(blockN)
+	if err != nil {
+		return nil, err
+	}
+	claim, err := a.issuer.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return claims.NewClaimTokenOwnership(id, standard, contract, amount, owner, blockN, blockHash, revocationNonce)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim.(*claims.ClaimTokenOwnership), nil
+}
+
+// AttestERC721 issues a ClaimTokenOwnership to the identity id that attests
+// that it owns tokenId of the ERC-721 contract at blockN.  sig is the
+// signature of OwnershipMsg(id) by the owner address.
+func (a *Attester) AttestERC721(id *core.ID, owner common.Address, sig *crypto.SignatureEthMsg,
+	contract common.Address, tokenId *big.Int, blockN uint64) (*claims.ClaimTokenOwnership, error) {
+	if err := checkOwner(id, owner, sig); err != nil {
+		return nil, err
+	}
+	tokenOwner, err := a.tokens.OwnerOf(contract, tokenId, blockN)
+	if err != nil {
+		return nil, err
+	}
+	if tokenOwner != owner {
+		return nil, ErrNotTokenOwner
+	}
+	return a.issue(id, claims.TokenStandardERC721, contract, tokenId, owner, blockN)
+}
+
+// AttestERC20 issues a ClaimTokenOwnership to the identity id that attests
+// that it has a balance of at least one of buckets in the ERC-20 contract at
+// blockN.  The greatest bucket not exceeding the balance is attested, so that
+// the exact balance is not disclosed.  sig is the signature of
+// OwnershipMsg(id) by the owner address.
+func (a *Attester) AttestERC20(id *core.ID, owner common.Address, sig *crypto.SignatureEthMsg,
+	contract common.Address, buckets []*big.Int, blockN uint64) (*claims.ClaimTokenOwnership, error) {
+	if err := checkOwner(id, owner, sig); err != nil {
+		return nil, err
+	}
+	balance, err := a.tokens.BalanceOf(contract, owner, blockN)
+	if err != nil {
+		return nil, err
+	}
+	var bucket *big.Int
+	for _, b := range buckets {
+		if b.Cmp(balance) <= 0 && (bucket == nil || b.Cmp(bucket) > 0) {
+			bucket = b
+		}
+	}
+	if bucket == nil {
+		return nil, ErrBalanceBelowBuckets
+	}
+	return a.issue(id, claims.TokenStandardERC20, contract, bucket, owner, blockN)
+}
+
+// Verifier verifies the credentials of ClaimTokenOwnership claims.
+type Verifier struct {
+	verifier *verifier.Verifier
+	tokens   TokenReader
+}
+
+// NewVerifier creates a new Verifier.
+func NewVerifier(idenPubOnChain idenpubonchain.IdenPubOnChainer, tokens TokenReader) *Verifier {
+	return &Verifier{verifier: verifier.New(idenPubOnChain), tokens: tokens}
+}
+
+// VerifyAttestation verifies that cred is a valid credential of a
+// ClaimTokenOwnership about the identity id issued by issuerId, and that the
+// claim is anchored to a block of the chain.  The verified claim is returned.
+func (v *Verifier) VerifyAttestation(issuerId, id *core.ID, cred *proof.CredentialExistence) (*claims.ClaimTokenOwnership, error) {
+	if !cred.Id.Equal(issuerId) {
+		return nil, verifier.ErrCredentialIdDoesntMatch
+	}
+	claim, err := claims.NewClaimFromEntry(cred.Claim)
+	if err != nil {
+		return nil, err
+	}
+	claimToken, ok := claim.(*claims.ClaimTokenOwnership)
+	if !ok {
+		return nil, ErrInvalidClaim
+	}
+	if !claimToken.Id.Equal(id) {
+		return nil, ErrIdDoesntMatch
+	}
+	if err := v.verifier.VerifyCredentialExistence(cred); err != nil {
+		return nil, err
+	}
+	blockHash, err := v.tokens.BlockHash(claimToken.BlockN)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(blockHash[:claims.TokenBlockHashLen], claimToken.BlockHash[:]) {
+		return nil, ErrBlockHashMismatch
+	}
+	return claimToken, nil
+}
+
+// CheckOnChain checks again on chain that the ownership attested by claim
+// held at its block.  It requires a TokenReader that can query past blocks.
+func (v *Verifier) CheckOnChain(claim *claims.ClaimTokenOwnership) error {
+	switch claim.Standard {
+	case claims.TokenStandardERC721:
+		owner, err := v.tokens.OwnerOf(claim.Contract, claim.Amount, claim.BlockN)
+		if err != nil {
+			return err
+		}
+		if owner != claim.Owner {
+			return ErrNotTokenOwner
+		}
+	case claims.TokenStandardERC20:
+		balance, err := v.tokens.BalanceOf(claim.Contract, claim.Owner, claim.BlockN)
+		if err != nil {
+			return err
+		}
+		if balance.Cmp(claim.Amount) < 0 {
+			return ErrNotTokenOwner
+		}
+	default:
+		return ErrInvalidClaim
+	}
+	return nil
+}
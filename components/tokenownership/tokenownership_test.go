@@ -0,0 +1,143 @@
+package tokenownership
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// tokensFixed is a TokenReader with a fixed state for every block.
+type tokensFixed struct {
+	balances    map[common.Address]*big.Int
+	owners      map[string]common.Address
+	blockHashes map[uint64]common.Hash
+}
+
+func (tf *tokensFixed) BalanceOf(contract, owner common.Address, blockN uint64) (*big.Int, error) {
+	if b, ok := tf.balances[owner]; ok {
+		return b, nil
+	}
+	return big.NewInt(0), nil
+}
+
+func (tf *tokensFixed) OwnerOf(contract common.Address, tokenId *big.Int, blockN uint64) (common.Address, error) {
+	return tf.owners[tokenId.String()], nil
+}
+
+func (tf *tokensFixed) BlockHash(blockN uint64) (common.Hash, error) {
+	return tf.blockHashes[blockN], nil
+}
+
+func publishState(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, is *issuer.Issuer) {
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	idenPubOnChain.On("InitState", is.ID(), mock.Anything, mock.Anything, []byte(nil), []byte(nil), mock.Anything).
+		Return(ethTx, nil).Once()
+	res, err := is.PublishState()
+	require.Nil(t, err)
+	require.Equal(t, issuer.PublishStateSubmitted, res.Status)
+	idenState, _ := is.State()
+	idenStateData := &proof.IdenStateData{IdenState: idenState, BlockN: 42, BlockTs: 1500000000}
+	idenPubOnChain.On("GetState", is.ID()).Return(idenStateData, nil)
+	idenPubOnChain.On("GetStateByBlock", is.ID(), uint64(42)).Return(idenStateData, nil)
+	require.Nil(t, is.SyncIdenStatePublic())
+}
+
+func TestNewEthTokenReader(t *testing.T) {
+	r, err := NewEthTokenReader(nil)
+	require.Nil(t, err)
+	assert.Contains(t, r.abi.Methods, "balanceOf")
+	assert.Contains(t, r.abi.Methods, "ownerOf")
+}
+
+func TestAttestAndVerify(t *testing.T) {
+	ownerKey, err := ethcrypto.GenerateKey()
+	require.Nil(t, err)
+	owner := ethcrypto.PubkeyToAddress(ownerKey.PublicKey)
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	signOwnership := func(id *core.ID) *crypto.SignatureEthMsg {
+		hash := crypto.EthHash(OwnershipMsg(id))
+		sig, err := ethcrypto.Sign(hash[:], ownerKey)
+		require.Nil(t, err)
+		sig[64] += 27
+		var sigEthMsg crypto.SignatureEthMsg
+		copy(sigEthMsg[:], sig)
+		return &sigEthMsg
+	}
+	sig := signOwnership(&id)
+
+	contract := common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c")
+	tokens := &tokensFixed{
+		balances:    map[common.Address]*big.Int{owner: big.NewInt(1500)},
+		owners:      map[string]common.Address{"7": owner},
+		blockHashes: map[uint64]common.Hash{100: common.HexToHash("0x1234")},
+	}
+
+	idenPubOnChain := idenpubonchain.New()
+	demo, err := issuer.NewDemoOnChain([]byte("tokenownership"), idenPubOnChain)
+	require.Nil(t, err)
+	is := demo.Issuer
+	a := NewAttester(is, tokens)
+
+	// ERC-721
+	claim721, err := a.AttestERC721(&id, owner, sig, contract, big.NewInt(7), 100)
+	require.Nil(t, err)
+	assert.Equal(t, claims.TokenStandardERC721, claim721.Standard)
+	assert.Equal(t, big.NewInt(7), claim721.Amount)
+	_, err = a.AttestERC721(&id, owner, sig, contract, big.NewInt(8), 100)
+	assert.Equal(t, ErrNotTokenOwner, err)
+	otherId := id
+	otherId[3]++
+	_, err = a.AttestERC721(&otherId, owner, sig, contract, big.NewInt(7), 100)
+	assert.Equal(t, ErrInvalidOwnerSignature, err)
+
+	// ERC-20: the greatest bucket not exceeding the balance is attested
+	buckets := []*big.Int{big.NewInt(100), big.NewInt(1000), big.NewInt(10000)}
+	claim20, err := a.AttestERC20(&id, owner, sig, contract, buckets, 100)
+	require.Nil(t, err)
+	assert.Equal(t, claims.TokenStandardERC20, claim20.Standard)
+	assert.Equal(t, big.NewInt(1000), claim20.Amount)
+	_, err = a.AttestERC20(&id, owner, sig, contract, []*big.Int{big.NewInt(10000)}, 100)
+	assert.Equal(t, ErrBalanceBelowBuckets, err)
+
+	publishState(t, idenPubOnChain, is)
+
+	v := NewVerifier(idenPubOnChain, tokens)
+	for _, claim := range []*claims.ClaimTokenOwnership{claim721, claim20} {
+		cred, err := is.GenCredentialExistence(claim)
+		require.Nil(t, err)
+		verified, err := v.VerifyAttestation(is.ID(), &id, cred)
+		require.Nil(t, err)
+		assert.Equal(t, claim, verified)
+		assert.Nil(t, v.CheckOnChain(verified))
+
+		_, err = v.VerifyAttestation(&id, &id, cred)
+		assert.Equal(t, verifier.ErrCredentialIdDoesntMatch, err)
+		_, err = v.VerifyAttestation(is.ID(), &otherId, cred)
+		assert.Equal(t, ErrIdDoesntMatch, err)
+	}
+
+	// The ownership changed after the block
+	tokens.owners["7"] = common.Address{}
+	assert.Equal(t, ErrNotTokenOwner, v.CheckOnChain(claim721))
+
+	// The block is not in the chain anymore (reorg)
+	tokens.blockHashes[100] = common.HexToHash("0x5678")
+	cred, err := is.GenCredentialExistence(claim721)
+	require.Nil(t, err)
+	_, err = v.VerifyAttestation(is.ID(), &id, cred)
+	assert.Equal(t, ErrBlockHashMismatch, err)
+}
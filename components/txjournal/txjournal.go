@@ -0,0 +1,306 @@
+package txjournal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/eth"
+)
+
+// TxStatus is the status of a transaction recorded in the Journal.
+type TxStatus string
+
+const (
+	// TxStatusPending is the status of a sent transaction that hasn't been
+	// mined yet.
+	TxStatusPending TxStatus = "pending"
+	// TxStatusConfirmed is the status of a mined successful transaction.
+	TxStatusConfirmed TxStatus = "confirmed"
+	// TxStatusFailed is the status of a reverted or dropped transaction.
+	TxStatusFailed TxStatus = "failed"
+)
+
+var (
+	ErrTxNotFound = fmt.Errorf("transaction not found in the journal")
+)
+
+var (
+	dbPrefixTx = []byte("tx:")
+)
+
+// Entry is the record of a transaction in the Journal.
+type Entry struct {
+	Hash     common.Hash
+	Nonce    uint64
+	To       *common.Address
+	Gas      uint64
+	GasPrice *big.Int
+	Purpose  string
+	Identity string
+	Status   TxStatus
+	// Reason is the reason of the last status change, like the error of a
+	// failed transaction.
+	Reason    string
+	SentTs    int64
+	UpdatedTs int64
+	// GasUsed is the gas used by the mined transaction, confirmed or
+	// failed, and Cost the ether it spent in wei (GasUsed * GasPrice).
+	// Cost is nil if the transaction hasn't been mined.
+	GasUsed uint64
+	Cost    *big.Int
+}
+
+// Costs are the gas and ether spent by a set of transactions of the Journal.
+type Costs struct {
+	// Txs is the number of mined transactions, confirmed or failed.
+	Txs int `json:"txs"`
+	// Pending is the number of pending transactions, whose cost is not
+	// known yet.
+	Pending int `json:"pending"`
+	// GasUsed is the gas used by the mined transactions.
+	GasUsed uint64 `json:"gasUsed"`
+	// Wei is the ether spent by the mined transactions, in wei.
+	Wei *big.Int `json:"wei"`
+}
+
+// NewCosts returns the Costs of no transactions.
+func NewCosts() *Costs {
+	return &Costs{Wei: big.NewInt(0)}
+}
+
+// Add adds the cost of the transaction e.
+func (c *Costs) Add(e *Entry) {
+	if e.Status == TxStatusPending {
+		c.Pending++
+		return
+	}
+	if e.Cost == nil {
+		// Dropped transaction, or mined before the costs were
+		// recorded.
+		return
+	}
+	c.Txs++
+	c.GasUsed += e.GasUsed
+	c.Wei.Add(c.Wei, e.Cost)
+}
+
+// Journal records every transaction sent by an eth.Client2 in a storage, so
+// that the pending and failed transactions can be listed and handled after a
+// restart.  It implements eth.TxRecorder: set it with
+// eth.Client2.SetTxRecorder.
+type Journal struct {
+	rw      *sync.RWMutex
+	storage db.Storage
+}
+
+// New creates a new Journal that stores the transactions in storage.
+func New(storage db.Storage) *Journal {
+	return &Journal{
+		rw:      &sync.RWMutex{},
+		storage: storage,
+	}
+}
+
+func txKey(hash common.Hash) []byte {
+	return append(append([]byte{}, dbPrefixTx...), hash[:]...)
+}
+
+func (j *Journal) store(e *Entry) error {
+	tx, err := j.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := db.StoreJSON(tx, txKey(e.Hash), e); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (j *Journal) load(hash common.Hash) (*Entry, error) {
+	v, err := j.storage.Get(txKey(hash))
+	if err == db.ErrNotFound {
+		return nil, ErrTxNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var e Entry
+	if err := json.Unmarshal(v, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// RecordTx records the sent transaction tx as pending.
+func (j *Journal) RecordTx(tx *types.Transaction, meta eth.TxMeta) error {
+	j.rw.Lock()
+	defer j.rw.Unlock()
+	now := time.Now().Unix()
+	return j.store(&Entry{
+		Hash:      tx.Hash(),
+		Nonce:     tx.Nonce(),
+		To:        tx.To(),
+		Gas:       tx.Gas(),
+		GasPrice:  tx.GasPrice(),
+		Purpose:   meta.Purpose,
+		Identity:  meta.Identity,
+		Status:    TxStatusPending,
+		SentTs:    now,
+		UpdatedTs: now,
+	})
+}
+
+// Entry returns the record of the transaction with hash.
+func (j *Journal) Entry(hash common.Hash) (*Entry, error) {
+	j.rw.RLock()
+	defer j.rw.RUnlock()
+	return j.load(hash)
+}
+
+// Mark sets the status of the transaction with hash, with the reason of the
+// change.
+func (j *Journal) Mark(hash common.Hash, status TxStatus, reason string) error {
+	return j.mark(hash, status, reason, nil)
+}
+
+// MarkMined sets the status of the mined transaction with hash, with the
+// reason of the change, and records its cost from the gas it used.
+func (j *Journal) MarkMined(hash common.Hash, status TxStatus, reason string, gasUsed uint64) error {
+	return j.mark(hash, status, reason, &gasUsed)
+}
+
+func (j *Journal) mark(hash common.Hash, status TxStatus, reason string, gasUsed *uint64) error {
+	j.rw.Lock()
+	defer j.rw.Unlock()
+	e, err := j.load(hash)
+	if err != nil {
+		return err
+	}
+	e.Status = status
+	e.Reason = reason
+	e.UpdatedTs = time.Now().Unix()
+	if gasUsed != nil {
+		e.GasUsed = *gasUsed
+		e.Cost = new(big.Int).Mul(new(big.Int).SetUint64(*gasUsed), e.GasPrice)
+	}
+	return j.store(e)
+}
+
+// Entries returns the records of the transactions with any of the statuses,
+// or all of them if no status is given, sorted by sending order.
+func (j *Journal) Entries(statuses ...TxStatus) ([]Entry, error) {
+	j.rw.RLock()
+	defer j.rw.RUnlock()
+	entries := []Entry{}
+	err := j.storage.WithPrefix(dbPrefixTx).Iterate(func(_, v []byte) (bool, error) {
+		var e Entry
+		if err := json.Unmarshal(v, &e); err != nil {
+			return false, err
+		}
+		if len(statuses) == 0 {
+			entries = append(entries, e)
+			return true, nil
+		}
+		for _, status := range statuses {
+			if e.Status == status {
+				entries = append(entries, e)
+				break
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(a, b int) bool {
+		if entries[a].SentTs != entries[b].SentTs {
+			return entries[a].SentTs < entries[b].SentTs
+		}
+		return entries[a].Nonce < entries[b].Nonce
+	})
+	return entries, nil
+}
+
+// Pending returns the records of the pending transactions.
+func (j *Journal) Pending() ([]Entry, error) {
+	return j.Entries(TxStatusPending)
+}
+
+// Failed returns the records of the failed transactions.
+func (j *Journal) Failed() ([]Entry, error) {
+	return j.Entries(TxStatusFailed)
+}
+
+// hasPurpose returns true if e has any of the purposes, or if no purpose is
+// given.
+func hasPurpose(e *Entry, purposes []string) bool {
+	if len(purposes) == 0 {
+		return true
+	}
+	for _, purpose := range purposes {
+		if e.Purpose == purpose {
+			return true
+		}
+	}
+	return false
+}
+
+// Costs returns the Costs of the transactions sent on behalf of identity with
+// any of the purposes, or all of them if no purpose is given.
+func (j *Journal) Costs(identity string, purposes ...string) (*Costs, error) {
+	costs, err := j.CostsByIdentity(purposes...)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := costs[identity]; ok {
+		return c, nil
+	}
+	return NewCosts(), nil
+}
+
+// CostsByIdentity returns the Costs of the transactions with any of the
+// purposes, or all of them if no purpose is given, by the identity on whose
+// behalf they were sent.  The transactions sent on behalf of no identity are
+// under the empty identity.
+func (j *Journal) CostsByIdentity(purposes ...string) (map[string]*Costs, error) {
+	entries, err := j.Entries()
+	if err != nil {
+		return nil, err
+	}
+	costs := make(map[string]*Costs)
+	for i := range entries {
+		e := &entries[i]
+		if !hasPurpose(e, purposes) {
+			continue
+		}
+		c, ok := costs[e.Identity]
+		if !ok {
+			c = NewCosts()
+			costs[e.Identity] = c
+		}
+		c.Add(e)
+	}
+	return costs, nil
+}
+
+// Track waits for the receipt of the pending transaction tx with client and
+// marks it as confirmed or failed accordingly.  If the receipt is not
+// available before the client timeout, the transaction is kept as pending
+// and the error is returned.
+func (j *Journal) Track(client *eth.Client2, tx *types.Transaction) error {
+	receipt, err := client.WaitReceipt(tx)
+	if receipt == nil {
+		return err
+	}
+	if err != nil {
+		return j.MarkMined(tx.Hash(), TxStatusFailed, err.Error(), receipt.GasUsed)
+	}
+	return j.MarkMined(tx.Hash(), TxStatusConfirmed, "", receipt.GasUsed)
+}
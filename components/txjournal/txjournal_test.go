@@ -0,0 +1,103 @@
+package txjournal
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTx(nonce uint64) *types.Transaction {
+	return types.NewTransaction(nonce, common.Address{}, big.NewInt(0), 300000, big.NewInt(1), nil)
+}
+
+func TestJournal(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	journal := New(storage)
+	var _ eth.TxRecorder = journal
+
+	tx0, tx1, tx2 := newTx(0), newTx(1), newTx(2)
+	require.Nil(t, journal.RecordTx(tx0, eth.TxMeta{Purpose: "initState", Identity: "id0"}))
+	require.Nil(t, journal.RecordTx(tx1, eth.TxMeta{Purpose: "setState", Identity: "id0"}))
+	require.Nil(t, journal.RecordTx(tx2, eth.TxMeta{Purpose: "setState", Identity: "id1"}))
+
+	e, err := journal.Entry(tx1.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, tx1.Hash(), e.Hash)
+	assert.Equal(t, uint64(1), e.Nonce)
+	assert.Equal(t, uint64(300000), e.Gas)
+	assert.Equal(t, "setState", e.Purpose)
+	assert.Equal(t, "id0", e.Identity)
+	assert.Equal(t, TxStatusPending, e.Status)
+
+	_, err = journal.Entry(newTx(3).Hash())
+	assert.Equal(t, ErrTxNotFound, err)
+	assert.Equal(t, ErrTxNotFound, journal.Mark(newTx(3).Hash(), TxStatusConfirmed, ""))
+
+	require.Nil(t, journal.Mark(tx0.Hash(), TxStatusConfirmed, ""))
+	require.Nil(t, journal.Mark(tx1.Hash(), TxStatusFailed, "contract call reverted"))
+
+	pending, err := journal.Pending()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(pending))
+	assert.Equal(t, tx2.Hash(), pending[0].Hash)
+
+	failed, err := journal.Failed()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(failed))
+	assert.Equal(t, tx1.Hash(), failed[0].Hash)
+	assert.Equal(t, "contract call reverted", failed[0].Reason)
+
+	all, err := journal.Entries()
+	require.Nil(t, err)
+	require.Equal(t, 3, len(all))
+	for i, e := range all {
+		assert.Equal(t, uint64(i), e.Nonce)
+	}
+
+	// The journal is persisted in the storage
+	failed, err = New(storage).Failed()
+	require.Nil(t, err)
+	assert.Equal(t, 1, len(failed))
+}
+
+func TestJournalCosts(t *testing.T) {
+	journal := New(db.NewMemoryStorage())
+	tx0, tx1, tx2, tx3 := newTx(0), newTx(1), newTx(2), newTx(3)
+	require.Nil(t, journal.RecordTx(tx0, eth.TxMeta{Purpose: "initState", Identity: "id0"}))
+	require.Nil(t, journal.RecordTx(tx1, eth.TxMeta{Purpose: "setState", Identity: "id0"}))
+	require.Nil(t, journal.RecordTx(tx2, eth.TxMeta{Purpose: "setState", Identity: "id1"}))
+	require.Nil(t, journal.RecordTx(tx3, eth.TxMeta{Purpose: "setPublicDataURL", Identity: "id0"}))
+
+	require.Nil(t, journal.MarkMined(tx0.Hash(), TxStatusConfirmed, "", 100000))
+	// Reverted transactions spend gas too.
+	require.Nil(t, journal.MarkMined(tx1.Hash(), TxStatusFailed, "contract call reverted", 30000))
+	require.Nil(t, journal.MarkMined(tx3.Hash(), TxStatusConfirmed, "", 50000))
+
+	e, err := journal.Entry(tx0.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, uint64(100000), e.GasUsed)
+	assert.Equal(t, big.NewInt(100000), e.Cost)
+
+	costs, err := journal.Costs("id0", "initState", "setState")
+	require.Nil(t, err)
+	assert.Equal(t, &Costs{Txs: 2, GasUsed: 130000, Wei: big.NewInt(130000)}, costs)
+	costs, err = journal.Costs("id0")
+	require.Nil(t, err)
+	assert.Equal(t, &Costs{Txs: 3, GasUsed: 180000, Wei: big.NewInt(180000)}, costs)
+	costs, err = journal.Costs("id2")
+	require.Nil(t, err)
+	assert.Equal(t, NewCosts(), costs)
+
+	byIdentity, err := journal.CostsByIdentity("initState", "setState")
+	require.Nil(t, err)
+	assert.Equal(t, map[string]*Costs{
+		"id0": {Txs: 2, GasUsed: 130000, Wei: big.NewInt(130000)},
+		"id1": {Pending: 1, Wei: big.NewInt(0)},
+	}, byIdentity)
+}
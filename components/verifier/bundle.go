@@ -0,0 +1,100 @@
+package verifier
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	ErrChallengeDoesntMatch = fmt.Errorf("The presentation bundle challenge doesn't match")
+)
+
+// CredentialResult is the result of the verification of a credential of a
+// PresentationBundle.
+type CredentialResult struct {
+	// Issuer is the identity that issued the credential.
+	Issuer *core.ID
+	// Claim is the claim of the credential, or nil if it's blinded.
+	Claim *merkletree.Entry
+	// BlindedClaim is the claim of the credential if it's blinded.
+	BlindedClaim *proof.BlindedClaim
+	// Err is the reason why the credential is not valid, or nil if it's
+	// valid.
+	Err error
+}
+
+// PresentationResult is the result of the verification of a
+// PresentationBundle, with a CredentialResult for each credential in the
+// order of the bundle.
+type PresentationResult struct {
+	Holder      *core.ID
+	Credentials []CredentialResult
+}
+
+// Valid returns true if all the credentials are valid.
+func (r *PresentationResult) Valid() bool {
+	for _, cr := range r.Credentials {
+		if cr.Err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// VerifyPresentationBundle verifies that the bundle responds to challenge
+// and is signed by its holder, and then verifies each credential
+// independently against the state on chain of its issuer, with freshness
+// for the validity credentials (see VerifyCredentialValidity).  An error is
+// returned only if the bundle as a whole is invalid; the errors of each
+// credential are reported in the PresentationResult.
+func (v *Verifier) VerifyPresentationBundle(bundle *proof.PresentationBundle, challenge []byte,
+	freshness time.Duration) (*PresentationResult, error) {
+	if !bytes.Equal(bundle.Challenge, challenge) {
+		return nil, ErrChallengeDoesntMatch
+	}
+	if len(bundle.Credentials) == 0 {
+		return nil, proof.ErrPresentationBundleEmpty
+	}
+	if bundle.Holder == nil || bundle.CredKSign == nil || bundle.Signature == nil {
+		return nil, ErrInvalidSignature
+	}
+	msg, err := bundle.SigMsg()
+	if err != nil {
+		return nil, err
+	}
+	if err := v.VerifySignature(bundle.Holder, bundle.CredKSign, proof.SigPrefixPresentationBundle,
+		msg, bundle.Signature); err != nil {
+		return nil, err
+	}
+
+	result := &PresentationResult{
+		Holder:      bundle.Holder,
+		Credentials: make([]CredentialResult, len(bundle.Credentials)),
+	}
+	for i := range bundle.Credentials {
+		pc := &bundle.Credentials[i]
+		cr := &result.Credentials[i]
+		if !pc.Valid() {
+			cr.Err = proof.ErrPresentationCredentialEmpty
+			continue
+		}
+		cr.Issuer = pc.Issuer()
+		switch {
+		case pc.Validity != nil:
+			cr.Claim = pc.Validity.CredentialExistence.Claim
+			cr.Err = v.VerifyCredentialValidity(pc.Validity, freshness)
+		case pc.Blinded != nil:
+			cr.BlindedClaim = pc.Blinded.Claim
+			cr.Err = v.VerifyBlindedCredentialExistence(pc.Blinded)
+		default:
+			cr.Claim = pc.Existence.Claim
+			cr.Err = v.VerifyCredentialExistence(pc.Existence)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,140 @@
+package verifier
+
+import (
+	"testing"
+	"time"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerifyPresentationBundle(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claimA := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	isA := newIssuerIssuedClaim(t, idenPubOnChain, claimA)
+	credA, err := isA.GenCredentialExistence(claimA)
+	require.Nil(t, err)
+
+	indexBytes[0] = 0x48
+	claimB := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	isB := newIssuerIssuedClaim(t, idenPubOnChain, claimB)
+	credB, err := isB.GenCredentialExistence(claimB)
+	require.Nil(t, err)
+
+	// The credential of claimB presented as issued by isA
+	var credBTampered proof.CredentialExistence
+	Copy(&credBTampered, credB)
+	credBTampered.Id = isA.ID()
+
+	holder, _, keyStoreH := newIssuer(t, idenPubOnChain)
+	genesisStateH, _ := holder.State()
+	indexBytes[0] = 0x50
+	require.Nil(t, holder.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0)))
+	publishFirstState(t, idenPubOnChain, holder, genesisStateH, 13)
+	kOpH, err := keyStoreH.Keys()[0].Decompress()
+	require.Nil(t, err)
+	credKSign, err := holder.GenCredentialExistence(claims.NewClaimAuthorizeKSignBabyJub(kOpH, 0))
+	require.Nil(t, err)
+
+	challenge := []byte("challenge")
+	bundle, err := proof.NewPresentationBundleBuilder(challenge).
+		AddExistence(credA).AddExistence(credB).AddExistence(&credBTampered).
+		Sign(holder, credKSign)
+	require.Nil(t, err)
+
+	verifier := New(idenPubOnChain)
+	verifier.SetRevocationChecker(revocations{})
+	res, err := verifier.VerifyPresentationBundle(bundle, challenge, time.Hour)
+	require.Nil(t, err)
+	assert.Equal(t, holder.ID(), res.Holder)
+	require.Equal(t, 3, len(res.Credentials))
+	assert.Equal(t, isA.ID(), res.Credentials[0].Issuer)
+	assert.Equal(t, claimA.Entry().Data, res.Credentials[0].Claim.Data)
+	assert.Nil(t, res.Credentials[0].Err)
+	assert.Equal(t, isB.ID(), res.Credentials[1].Issuer)
+	assert.Nil(t, res.Credentials[1].Err)
+	assert.Equal(t, isA.ID(), res.Credentials[2].Issuer)
+	assert.Equal(t, ErrIdenStateOnChainDoesntMatch, res.Credentials[2].Err)
+	assert.False(t, res.Valid())
+
+	bundleValid, err := proof.NewPresentationBundleBuilder(challenge).
+		AddExistence(credA).AddExistence(credB).Sign(holder, credKSign)
+	require.Nil(t, err)
+	res, err = verifier.VerifyPresentationBundle(bundleValid, challenge, time.Hour)
+	require.Nil(t, err)
+	assert.True(t, res.Valid())
+
+	// The bundle responds to another challenge
+	_, err = verifier.VerifyPresentationBundle(bundleValid, []byte("other"), time.Hour)
+	assert.Equal(t, ErrChallengeDoesntMatch, err)
+
+	// A credential is removed after signing
+	var bundleModified proof.PresentationBundle
+	Copy(&bundleModified, bundleValid)
+	bundleModified.Credentials = bundleModified.Credentials[:1]
+	_, err = verifier.VerifyPresentationBundle(&bundleModified, challenge, time.Hour)
+	assert.Equal(t, ErrInvalidSignature, err)
+
+	// The bundle is not signed
+	Copy(&bundleModified, bundleValid)
+	bundleModified.Signature = nil
+	_, err = verifier.VerifyPresentationBundle(&bundleModified, challenge, time.Hour)
+	assert.Equal(t, ErrInvalidSignature, err)
+}
+
+func TestVerifyPresentationBundleBlinded(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0], dataBytes[0] = 0x42, 0x43
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	is := newIssuerIssuedClaim(t, idenPubOnChain, claim)
+	cred, err := is.GenCredentialExistence(claim)
+	require.Nil(t, err)
+
+	// Reveal the index and hide the value.
+	credBlinded, err := cred.Blind(0, 1, 2, 3)
+	require.Nil(t, err)
+
+	// A revealed slot is changed.
+	var credTampered proof.BlindedCredentialExistence
+	Copy(&credTampered, credBlinded)
+	credTampered.Claim.Slots[2][0] ^= 0x01
+
+	holder, _, keyStoreH := newIssuer(t, idenPubOnChain)
+	genesisStateH, _ := holder.State()
+	indexBytes[0] = 0x50
+	require.Nil(t, holder.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0)))
+	publishFirstState(t, idenPubOnChain, holder, genesisStateH, 13)
+	kOpH, err := keyStoreH.Keys()[0].Decompress()
+	require.Nil(t, err)
+	credKSign, err := holder.GenCredentialExistence(claims.NewClaimAuthorizeKSignBabyJub(kOpH, 0))
+	require.Nil(t, err)
+
+	challenge := []byte("challenge")
+	bundle, err := proof.NewPresentationBundleBuilder(challenge).
+		AddBlinded(credBlinded).AddBlinded(&credTampered).Sign(holder, credKSign)
+	require.Nil(t, err)
+
+	verifier := New(idenPubOnChain)
+	verifier.SetRevocationChecker(revocations{})
+	res, err := verifier.VerifyPresentationBundle(bundle, challenge, time.Hour)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(res.Credentials))
+	assert.Equal(t, is.ID(), res.Credentials[0].Issuer)
+	assert.Nil(t, res.Credentials[0].Claim)
+	slot, ok := res.Credentials[0].BlindedClaim.Slot(2)
+	require.True(t, ok)
+	assert.Equal(t, claim.Entry().Data[2], *slot)
+	_, ok = res.Credentials[0].BlindedClaim.Slot(6)
+	assert.False(t, ok)
+	assert.Nil(t, res.Credentials[0].Err)
+	assert.Equal(t, ErrCalculatedIdenStateDoesntMatch, res.Credentials[1].Err)
+	assert.False(t, res.Valid())
+}
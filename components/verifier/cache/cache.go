@@ -0,0 +1,257 @@
+// Package cache implements a time-bounded cache of the identity states on
+// chain and the off chain public data of the issuers, used by verifiers to
+// avoid querying the smart contract and the issuers on every verification.
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// ErrInvalidConfig is used when the StateTTL of the Config doesn't leave time
+// for the Freshness of the verifier.
+var ErrInvalidConfig = fmt.Errorf("StateTTL must be shorter than Freshness")
+
+// PublicDataGetter is an interface to get the off chain public data of an
+// identity, satisfied by idenpuboffchainreader.IdenPubOffChainReadHttp.
+type PublicDataGetter interface {
+	GetPublicData(idPubUrl string, id *core.ID, idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error)
+}
+
+// Config is the configuration of a Cache.
+type Config struct {
+	// Freshness is the freshness policy of the verifier: the maximum age
+	// of the identity states it accepts in the validity credentials.
+	Freshness time.Duration
+	// StateTTL is the time that the last state of an identity, and the
+	// public data of the last state, are cached.  As the last state may
+	// be outdated by StateTTL, the verifier must check the validity
+	// credentials with the remaining freshness (see Cache.Freshness).
+	StateTTL time.Duration
+	// HistoryTTL is the time that the states by block and time, and the
+	// public data of a given state, are cached.  They don't change once
+	// published, so it can be long.
+	HistoryTTL time.Duration
+	// MaxEntries is the maximum number of cached entries.  When reached,
+	// the expired entries are evicted, and if there are none, the entry
+	// closest to expiration.
+	MaxEntries int
+}
+
+// ConfigDefault is the default configuration of a Cache.
+var ConfigDefault = Config{
+	Freshness:  10 * time.Minute,
+	StateTTL:   2 * time.Minute,
+	HistoryTTL: time.Hour,
+	MaxEntries: 4096,
+}
+
+// entry is a cached value.
+type entry struct {
+	// id is the identity the value belongs to.
+	id      core.ID
+	value   interface{}
+	expires time.Time
+}
+
+// call is a fetch in flight, shared by the concurrent lookups of the same
+// key.
+type call struct {
+	wg    sync.WaitGroup
+	value interface{}
+	err   error
+}
+
+// Cache caches the lookups of the identity states on chain and the off chain
+// public data.  It satisfies idenpubonchain.IdenPubOnChainer, so it can be
+// passed to verifier.New and sigverify.New, and the concurrent lookups of
+// the same value are deduplicated into a single fetch.  Errors are never
+// cached.  The returned values are shared, so they must not be modified.
+type Cache struct {
+	cfg            Config
+	idenPubOnChain idenpubonchain.IdenPubOnChainer
+	publicData     PublicDataGetter
+	timeNow        func() time.Time
+
+	rw       sync.RWMutex
+	entries  map[string]*entry
+	inFlight map[string]*call
+}
+
+// New creates a new Cache of the lookups to idenPubOnChain and publicData.
+// publicData can be nil if the public data is not needed.
+func New(cfg Config, idenPubOnChain idenpubonchain.IdenPubOnChainer, publicData PublicDataGetter) (*Cache, error) {
+	return NewWithTimeNow(cfg, idenPubOnChain, publicData, time.Now)
+}
+
+// NewWithTimeNow creates a new Cache like New using timeNow as the clock.
+func NewWithTimeNow(cfg Config, idenPubOnChain idenpubonchain.IdenPubOnChainer, publicData PublicDataGetter,
+	timeNow func() time.Time) (*Cache, error) {
+	if cfg.StateTTL >= cfg.Freshness {
+		return nil, ErrInvalidConfig
+	}
+	return &Cache{
+		cfg:            cfg,
+		idenPubOnChain: idenPubOnChain,
+		publicData:     publicData,
+		timeNow:        timeNow,
+		entries:        make(map[string]*entry),
+		inFlight:       make(map[string]*call),
+	}, nil
+}
+
+// Freshness returns the freshness that the verifier must use to check the
+// validity credentials, so that the accepted states are at most
+// Config.Freshness old even when the cached last state is outdated.
+func (c *Cache) Freshness() time.Duration {
+	return c.cfg.Freshness - c.cfg.StateTTL
+}
+
+// get returns the cached value of key, belonging to id, or fetches it and
+// caches it for ttl.  Concurrent calls with the same key wait for a single
+// fetch.
+func (c *Cache) get(key string, id *core.ID, ttl time.Duration, fetch func() (interface{}, error)) (interface{}, error) {
+	c.rw.Lock()
+	if e, ok := c.entries[key]; ok {
+		if c.timeNow().Before(e.expires) {
+			c.rw.Unlock()
+			return e.value, nil
+		}
+		delete(c.entries, key)
+	}
+	if cl, ok := c.inFlight[key]; ok {
+		c.rw.Unlock()
+		cl.wg.Wait()
+		return cl.value, cl.err
+	}
+	cl := &call{}
+	cl.wg.Add(1)
+	c.inFlight[key] = cl
+	c.rw.Unlock()
+
+	cl.value, cl.err = fetch()
+
+	c.rw.Lock()
+	delete(c.inFlight, key)
+	if cl.err == nil {
+		c.put(key, &entry{id: *id, value: cl.value, expires: c.timeNow().Add(ttl)})
+	}
+	c.rw.Unlock()
+	cl.wg.Done()
+	return cl.value, cl.err
+}
+
+// put adds an entry, evicting entries if the cache is full.  It must be
+// called with the write lock held.
+func (c *Cache) put(key string, e *entry) {
+	if c.cfg.MaxEntries > 0 && len(c.entries) >= c.cfg.MaxEntries {
+		now := c.timeNow()
+		var oldestKey string
+		var oldest *entry
+		for k, e := range c.entries {
+			if !now.Before(e.expires) {
+				delete(c.entries, k)
+			} else if oldest == nil || e.expires.Before(oldest.expires) {
+				oldestKey, oldest = k, e
+			}
+		}
+		if len(c.entries) >= c.cfg.MaxEntries {
+			delete(c.entries, oldestKey)
+		}
+	}
+	c.entries[key] = e
+}
+
+// Invalidate removes all the cached values of the identity id, for example
+// after being notified of a new state.
+func (c *Cache) Invalidate(id *core.ID) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	for k, e := range c.entries {
+		if e.id.Equal(id) {
+			delete(c.entries, k)
+		}
+	}
+}
+
+// Len returns the number of cached entries.
+func (c *Cache) Len() int {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	return len(c.entries)
+}
+
+// GetState returns the last state of id on chain, cached for StateTTL.
+func (c *Cache) GetState(id *core.ID) (*proof.IdenStateData, error) {
+	v, err := c.get("state:"+id.String(), id, c.cfg.StateTTL, func() (interface{}, error) {
+		return c.idenPubOnChain.GetState(id)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*proof.IdenStateData), nil
+}
+
+// GetStateByBlock returns the state of id on chain at blockN, cached for
+// HistoryTTL.
+func (c *Cache) GetStateByBlock(id *core.ID, blockN uint64) (*proof.IdenStateData, error) {
+	key := fmt.Sprintf("block:%v:%v", id, blockN)
+	v, err := c.get(key, id, c.cfg.HistoryTTL, func() (interface{}, error) {
+		return c.idenPubOnChain.GetStateByBlock(id, blockN)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*proof.IdenStateData), nil
+}
+
+// GetStateByTime returns the state of id on chain at blockTimestamp, cached
+// for HistoryTTL.
+func (c *Cache) GetStateByTime(id *core.ID, blockTimestamp int64) (*proof.IdenStateData, error) {
+	key := fmt.Sprintf("time:%v:%v", id, blockTimestamp)
+	v, err := c.get(key, id, c.cfg.HistoryTTL, func() (interface{}, error) {
+		return c.idenPubOnChain.GetStateByTime(id, blockTimestamp)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*proof.IdenStateData), nil
+}
+
+// SetState is not cached, it's forwarded to the wrapped IdenPubOnChainer.
+func (c *Cache) SetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte,
+	signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return c.idenPubOnChain.SetState(id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// InitState is not cached, it's forwarded to the wrapped IdenPubOnChainer.
+func (c *Cache) InitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte,
+	stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	return c.idenPubOnChain.InitState(id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
+// GetPublicData returns the off chain public data of id at idenState.  If
+// idenState is nil, the public data of the last state is returned, cached for
+// StateTTL, otherwise it's cached for HistoryTTL.
+func (c *Cache) GetPublicData(idPubUrl string, id *core.ID, idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	key, ttl := fmt.Sprintf("pubdata:%v:%v:last", idPubUrl, id), c.cfg.StateTTL
+	if idenState != nil {
+		key, ttl = fmt.Sprintf("pubdata:%v:%v:%v", idPubUrl, id, idenState.Hex()), c.cfg.HistoryTTL
+	}
+	v, err := c.get(key, id, ttl, func() (interface{}, error) {
+		return c.publicData.GetPublicData(idPubUrl, id, idenState)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*idenpuboffchainwriter.PublicData), nil
+}
@@ -0,0 +1,174 @@
+package cache
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	id1 = core.ID{0, 1}
+	id2 = core.ID{0, 2}
+)
+
+type clock struct {
+	now time.Time
+}
+
+func (c *clock) timeNow() time.Time { return c.now }
+
+func newTestCache(t *testing.T, publicData PublicDataGetter) (*Cache, *idenpubonchain.IdenPubOnChainMock, *clock) {
+	idenPubOnChain := idenpubonchain.New()
+	clk := &clock{now: time.Unix(1600000000, 0)}
+	c, err := NewWithTimeNow(ConfigDefault, idenPubOnChain, publicData, clk.timeNow)
+	require.Nil(t, err)
+	return c, idenPubOnChain, clk
+}
+
+func TestCacheGetState(t *testing.T) {
+	c, idenPubOnChain, clk := newTestCache(t, nil)
+	state := &proof.IdenStateData{BlockN: 1, IdenState: &merkletree.Hash{1}}
+	idenPubOnChain.On("GetState", &id1).Return(state, nil).Once()
+
+	for i := 0; i < 3; i++ {
+		s, err := c.GetState(&id1)
+		require.Nil(t, err)
+		assert.Equal(t, state, s)
+	}
+	idenPubOnChain.AssertExpectations(t)
+
+	// After StateTTL the state is fetched again
+	clk.now = clk.now.Add(ConfigDefault.StateTTL)
+	newState := &proof.IdenStateData{BlockN: 2, IdenState: &merkletree.Hash{2}}
+	idenPubOnChain.On("GetState", &id1).Return(newState, nil).Once()
+	s, err := c.GetState(&id1)
+	require.Nil(t, err)
+	assert.Equal(t, newState, s)
+
+	// The states by block last HistoryTTL
+	idenPubOnChain.On("GetStateByBlock", &id1, uint64(1)).Return(state, nil).Once()
+	for i := 0; i < 2; i++ {
+		s, err = c.GetStateByBlock(&id1, 1)
+		require.Nil(t, err)
+		assert.Equal(t, state, s)
+		clk.now = clk.now.Add(ConfigDefault.StateTTL)
+	}
+	idenPubOnChain.AssertExpectations(t)
+}
+
+func TestCacheErrorNotCached(t *testing.T) {
+	c, idenPubOnChain, _ := newTestCache(t, nil)
+	errRPC := fmt.Errorf("rpc error")
+	state := &proof.IdenStateData{BlockN: 1, IdenState: &merkletree.Hash{1}}
+	idenPubOnChain.On("GetStateByTime", &id1, int64(10)).Return((*proof.IdenStateData)(nil), errRPC).Once()
+	idenPubOnChain.On("GetStateByTime", &id1, int64(10)).Return(state, nil).Once()
+
+	_, err := c.GetStateByTime(&id1, 10)
+	assert.Equal(t, errRPC, err)
+	s, err := c.GetStateByTime(&id1, 10)
+	require.Nil(t, err)
+	assert.Equal(t, state, s)
+	idenPubOnChain.AssertExpectations(t)
+}
+
+// publicDataGetter is a PublicDataGetter that blocks until release is
+// closed, counting the calls.
+type publicDataGetter struct {
+	calls   int32
+	release chan struct{}
+}
+
+func (p *publicDataGetter) GetPublicData(idPubUrl string, id *core.ID,
+	idenState *merkletree.Hash) (*idenpuboffchainwriter.PublicData, error) {
+	atomic.AddInt32(&p.calls, 1)
+	<-p.release
+	publicData := &idenpuboffchainwriter.PublicData{}
+	if idenState != nil {
+		publicData.IdenState = *idenState
+	}
+	return publicData, nil
+}
+
+func TestCacheSingleFlight(t *testing.T) {
+	getter := &publicDataGetter{release: make(chan struct{})}
+	c, _, _ := newTestCache(t, getter)
+
+	var wg sync.WaitGroup
+	results := make([]*idenpuboffchainwriter.PublicData, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			publicData, err := c.GetPublicData("https://issuer", &id1, &merkletree.Hash{3})
+			require.Nil(t, err)
+			results[i] = publicData
+		}(i)
+	}
+	for atomic.LoadInt32(&getter.calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	// Give time to the rest of the lookups to join the fetch in flight
+	time.Sleep(10 * time.Millisecond)
+	close(getter.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&getter.calls))
+	for _, publicData := range results {
+		assert.Equal(t, merkletree.Hash{3}, publicData.IdenState)
+	}
+
+	// The last public data is cached with a different key
+	_, err := c.GetPublicData("https://issuer", &id1, nil)
+	require.Nil(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&getter.calls))
+}
+
+func TestCacheInvalidateEvict(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	cfg := ConfigDefault
+	cfg.MaxEntries = 2
+	c, err := New(cfg, idenPubOnChain, nil)
+	require.Nil(t, err)
+	state := &proof.IdenStateData{BlockN: 1, IdenState: &merkletree.Hash{1}}
+	idenPubOnChain.On("GetState", &id1).Return(state, nil)
+	idenPubOnChain.On("GetState", &id2).Return(state, nil)
+	idenPubOnChain.On("GetStateByBlock", &id1, uint64(1)).Return(state, nil)
+
+	_, err = c.GetState(&id1)
+	require.Nil(t, err)
+	_, err = c.GetStateByBlock(&id1, 1)
+	require.Nil(t, err)
+	assert.Equal(t, 2, c.Len())
+
+	// The state of id1 is the closest to expiration, so it's evicted
+	_, err = c.GetState(&id2)
+	require.Nil(t, err)
+	assert.Equal(t, 2, c.Len())
+	_, err = c.GetState(&id1)
+	require.Nil(t, err)
+	idenPubOnChain.AssertNumberOfCalls(t, "GetState", 3)
+
+	c.Invalidate(&id1)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCacheConfig(t *testing.T) {
+	cfg := ConfigDefault
+	cfg.StateTTL = cfg.Freshness
+	_, err := New(cfg, idenpubonchain.New(), nil)
+	assert.Equal(t, ErrInvalidConfig, err)
+
+	c, err := New(ConfigDefault, idenpubonchain.New(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, 8*time.Minute, c.Freshness())
+}
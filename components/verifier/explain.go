@@ -0,0 +1,259 @@
+package verifier
+
+import (
+	"bytes"
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// CheckName identifies each one of the checks performed by Explain.
+type CheckName string
+
+const (
+	// CheckClaimsTreeProof verifies that the claim is in the claims tree
+	// of the identity state of the credential.
+	CheckClaimsTreeProof CheckName = "claims-tree proof"
+	// CheckStateMismatch verifies that the identity state of the
+	// credential is the one found on chain, and that the public data
+	// corresponds to the on chain identity state.
+	CheckStateMismatch CheckName = "state mismatch"
+	// CheckExpiredState verifies that, if the identity state of the
+	// credential has been superseded by a newer one on chain, the claim
+	// has not been revoked since.  A superseded identity state is still
+	// valid as long as its claims tree root is in the roots tree of the
+	// last one (see CheckRootsTreeProof).
+	CheckExpiredState CheckName = "expired state"
+	// CheckRootsTreeProof verifies that the claims tree root of the
+	// credential is in the roots tree of the last identity state.
+	CheckRootsTreeProof CheckName = "roots-tree proof"
+	// CheckRevocationProof verifies that the claim is not revoked in the
+	// revocations tree of the last identity state.
+	CheckRevocationProof CheckName = "revocation proof"
+)
+
+var (
+	// ErrPublicDataNotAvailable is the error of the checks that need the
+	// public data when Explain is called without it.
+	ErrPublicDataNotAvailable = fmt.Errorf("public data not available")
+	// ErrStateDataNotAvailable is the error of the checks that need the
+	// on chain identity state data when Explain is called without it.
+	ErrStateDataNotAvailable = fmt.Errorf("on chain identity state data not available")
+)
+
+// explainMaxLevels is the number of levels used to import the trees from the
+// public data.
+const explainMaxLevels = 140
+
+// Check is the result of a single check performed by Explain.  If Err is nil
+// the check passed.
+type Check struct {
+	Name CheckName
+	Err  error
+}
+
+// Explanation is a report of all the checks performed when verifying a
+// credential.
+type Explanation struct {
+	Checks []Check
+}
+
+// Ok returns true if all the checks passed.
+func (e *Explanation) Ok() bool {
+	return e.Failed() == nil
+}
+
+// Failed returns the first check that failed, or nil if all the checks
+// passed.
+func (e *Explanation) Failed() *Check {
+	for i := range e.Checks {
+		if e.Checks[i].Err != nil {
+			return &e.Checks[i]
+		}
+	}
+	return nil
+}
+
+// Err returns an error describing the first failed check, or nil if all the
+// checks passed.
+func (e *Explanation) Err() error {
+	if c := e.Failed(); c != nil {
+		return fmt.Errorf("%v: %w", c.Name, c.Err)
+	}
+	return nil
+}
+
+func (e *Explanation) String() string {
+	buf := bytes.NewBufferString("Explanation:\n")
+	for _, c := range e.Checks {
+		if c.Err == nil {
+			fmt.Fprintf(buf, "\t%v: ok\n", c.Name)
+		} else {
+			fmt.Fprintf(buf, "\t%v: FAILED (%v)\n", c.Name, c.Err)
+		}
+	}
+	return buf.String()
+}
+
+func (e *Explanation) add(name CheckName, err error) {
+	e.Checks = append(e.Checks, Check{Name: name, Err: err})
+}
+
+// importTree imports a tree dumped with DumpTree into a memory merkle tree.
+func importTree(blob []byte) (*merkletree.MerkleTree, error) {
+	mt, err := merkletree.NewMerkleTreeInMemory(explainMaxLevels)
+	if err != nil {
+		return nil, err
+	}
+	if err := mt.ImportTree(bytes.NewReader(blob)); err != nil {
+		return nil, err
+	}
+	return mt, nil
+}
+
+// Explain is a diagnostic tool that reports which check fails when verifying
+// the existence credential cred.  stateData is the last identity state data
+// of the issuer found in the smart contract, and publicData is the issuer off
+// chain public data corresponding to that identity state.  The returned
+// Explanation contains the result of every check, so that it can be shown in
+// a CLI or returned by a debug endpoint.  publicData and stateData may be nil
+// when they couldn't be fetched, in which case the checks that need them fail
+// with ErrPublicDataNotAvailable or ErrStateDataNotAvailable.
+func Explain(cred *proof.CredentialExistence, publicData *idenpuboffchainwriter.PublicData, stateData *proof.IdenStateData) *Explanation {
+	e := &Explanation{}
+
+	// Claims Tree proof
+	claimsRoot, err := explainClaimsTreeProof(cred)
+	e.add(CheckClaimsTreeProof, err)
+
+	// State mismatch
+	e.add(CheckStateMismatch, explainStateMismatch(cred, publicData, stateData))
+
+	// Expired state
+	revoked, errRevocation := explainRevocationProof(cred.Claim, publicData)
+	if stateData == nil {
+		e.add(CheckExpiredState, ErrStateDataNotAvailable)
+	} else if superseded := !cred.IdenStateData.IdenState.Equals(stateData.IdenState); !superseded {
+		e.add(CheckExpiredState, nil)
+	} else if revoked {
+		e.add(CheckExpiredState, fmt.Errorf("credential IdenState (%v) at block %v"+
+			" is superseded by the IdenState (%v) at block %v, where the claim is revoked",
+			cred.IdenStateData.IdenState, cred.IdenStateData.BlockN,
+			stateData.IdenState, stateData.BlockN))
+	} else if publicData == nil {
+		e.add(CheckExpiredState, ErrPublicDataNotAvailable)
+	} else {
+		e.add(CheckExpiredState, nil)
+	}
+
+	// Roots Tree proof
+	if claimsRoot == nil {
+		e.add(CheckRootsTreeProof, fmt.Errorf("claims tree root unavailable"))
+	} else if publicData == nil {
+		e.add(CheckRootsTreeProof, ErrPublicDataNotAvailable)
+	} else {
+		e.add(CheckRootsTreeProof, explainRootsTreeProof(claimsRoot, publicData))
+	}
+
+	// Revocation proof
+	e.add(CheckRevocationProof, errRevocation)
+
+	return e
+}
+
+func explainClaimsTreeProof(cred *proof.CredentialExistence) (*merkletree.Hash, error) {
+	if cred.MtpClaim == nil || cred.Claim == nil {
+		return nil, fmt.Errorf("missing claim or claim proof")
+	}
+	if !cred.MtpClaim.Existence {
+		return nil, fmt.Errorf("claim proof is a non-existence proof")
+	}
+	claimsRoot, err := merkletree.RootFromProof(cred.MtpClaim, cred.Claim.HIndex(), cred.Claim.HValue())
+	if err != nil {
+		return nil, err
+	}
+	idenState := core.IdenState(claimsRoot, cred.RevocationsRoot, cred.RootsRoot)
+	if !idenState.Equals(cred.IdenStateData.IdenState) {
+		return nil, fmt.Errorf("IdenState calculated from the claim proof (%v)"+
+			" doesn't match the credential IdenState (%v)", idenState, cred.IdenStateData.IdenState)
+	}
+	return claimsRoot, nil
+}
+
+func explainStateMismatch(cred *proof.CredentialExistence, publicData *idenpuboffchainwriter.PublicData, stateData *proof.IdenStateData) error {
+	if stateData == nil {
+		return ErrStateDataNotAvailable
+	}
+	if cred.IdenStateData.BlockN > stateData.BlockN {
+		return fmt.Errorf("credential IdenState block (%v) is newer than the last"+
+			" IdenState block on chain (%v)", cred.IdenStateData.BlockN, stateData.BlockN)
+	}
+	if cred.IdenStateData.BlockN == stateData.BlockN &&
+		!cred.IdenStateData.IdenState.Equals(stateData.IdenState) {
+		return fmt.Errorf("credential IdenState (%v) doesn't match the IdenState"+
+			" on chain (%v) at block %v", cred.IdenStateData.IdenState, stateData.IdenState, stateData.BlockN)
+	}
+	if publicData == nil {
+		return ErrPublicDataNotAvailable
+	}
+	if !publicData.IdenState.Equals(stateData.IdenState) {
+		return fmt.Errorf("public data IdenState (%v) doesn't match the IdenState"+
+			" on chain (%v)", &publicData.IdenState, stateData.IdenState)
+	}
+	idenState := core.IdenState(&publicData.ClaimsTreeRoot, &publicData.RevocationsTreeRoot, &publicData.RootsTreeRoot)
+	if !idenState.Equals(&publicData.IdenState) {
+		return fmt.Errorf("IdenState calculated from the public data roots (%v)"+
+			" doesn't match the public data IdenState (%v)", idenState, &publicData.IdenState)
+	}
+	return nil
+}
+
+func explainRootsTreeProof(claimsRoot *merkletree.Hash, publicData *idenpuboffchainwriter.PublicData) error {
+	if claimsRoot.Equals(&publicData.ClaimsTreeRoot) {
+		return nil
+	}
+	rot, err := importTree(publicData.RootsTree)
+	if err != nil {
+		return fmt.Errorf("unable to import the roots tree: %w", err)
+	}
+	mtp, err := rot.GenerateProof(claims.HIndexLeafRootsTree(claimsRoot), &publicData.RootsTreeRoot)
+	if err != nil {
+		return fmt.Errorf("unable to generate the roots tree proof: %w", err)
+	}
+	if !mtp.Existence {
+		return fmt.Errorf("claims tree root (%v) not found in the roots tree (%v)",
+			claimsRoot, &publicData.RootsTreeRoot)
+	}
+	return nil
+}
+
+// explainRevocationProof returns true and an error if the claim is revoked in
+// the revocations tree of publicData, or false and an error if the revocation
+// can't be checked.
+func explainRevocationProof(claim *merkletree.Entry, publicData *idenpuboffchainwriter.PublicData) (bool, error) {
+	if claim == nil {
+		return false, fmt.Errorf("missing claim")
+	}
+	if publicData == nil {
+		return false, ErrPublicDataNotAvailable
+	}
+	ret, err := importTree(publicData.RevocationsTree)
+	if err != nil {
+		return false, fmt.Errorf("unable to import the revocations tree: %w", err)
+	}
+	nonce := claims.GetRevocationNonce(claim)
+	mtp, err := ret.GenerateProof(claims.HIndexLeafRevocationsTree(nonce, claims.RevocationVersionAll),
+		&publicData.RevocationsTreeRoot)
+	if err != nil {
+		return false, fmt.Errorf("unable to generate the revocations tree proof: %w", err)
+	}
+	if mtp.Existence {
+		return true, fmt.Errorf("claim revocation nonce (%v) found in the revocations tree (%v)",
+			nonce, &publicData.RevocationsTreeRoot)
+	}
+	return false, nil
+}
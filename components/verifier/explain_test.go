@@ -0,0 +1,135 @@
+package verifier
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type explainIden struct {
+	clt, ret, rot *merkletree.MerkleTree
+}
+
+func newExplainIden(t *testing.T) *explainIden {
+	clt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	ret, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rot, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	return &explainIden{clt: clt, ret: ret, rot: rot}
+}
+
+func (ei *explainIden) state() *merkletree.Hash {
+	return core.IdenState(ei.clt.RootKey(), ei.ret.RootKey(), ei.rot.RootKey())
+}
+
+func (ei *explainIden) credential(t *testing.T, claim merkletree.Entrier, blockN uint64) *proof.CredentialExistence {
+	mtp, err := ei.clt.GenerateProof(claim.Entry().HIndex(), nil)
+	require.Nil(t, err)
+	return &proof.CredentialExistence{
+		IdenStateData:   proof.IdenStateData{BlockN: blockN, BlockTs: int64(blockN) * 10, IdenState: ei.state()},
+		MtpClaim:        mtp,
+		Claim:           claim.Entry(),
+		RevocationsRoot: ei.ret.RootKey(),
+		RootsRoot:       ei.rot.RootKey(),
+	}
+}
+
+func (ei *explainIden) publicData(t *testing.T) *idenpuboffchainwriter.PublicData {
+	rotBlob := bytes.NewBufferString("")
+	require.Nil(t, ei.rot.DumpTree(rotBlob, nil))
+	retBlob := bytes.NewBufferString("")
+	require.Nil(t, ei.ret.DumpTree(retBlob, nil))
+	return &idenpuboffchainwriter.PublicData{
+		IdenState:           *ei.state(),
+		ClaimsTreeRoot:      *ei.clt.RootKey(),
+		RootsTreeRoot:       *ei.rot.RootKey(),
+		RootsTree:           rotBlob.Bytes(),
+		RevocationsTreeRoot: *ei.ret.RootKey(),
+		RevocationsTree:     retBlob.Bytes(),
+	}
+}
+
+func TestExplain(t *testing.T) {
+	ei := newExplainIden(t)
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 3)
+	require.Nil(t, ei.clt.AddClaim(claim0))
+	require.Nil(t, claims.AddLeafRootsTree(ei.rot, ei.clt.RootKey()))
+
+	cred := ei.credential(t, claim0, 10)
+	stateData := cred.IdenStateData
+
+	// Good credential
+	e := Explain(cred, ei.publicData(t), &stateData)
+	assert.True(t, e.Ok(), e.String())
+	assert.Nil(t, e.Err())
+	assert.Equal(t, 5, len(e.Checks))
+
+	// Bad claim
+	credBad := *cred
+	indexBytes[0] = 0x43
+	credBad.Claim = claims.NewClaimBasic(indexBytes, dataBytes, 3).Entry()
+	e = Explain(&credBad, ei.publicData(t), &stateData)
+	assert.Equal(t, CheckClaimsTreeProof, e.Failed().Name)
+
+	// State on chain doesn't match
+	stateDataBad := stateData
+	stateDataBad.IdenState = &merkletree.HashZero
+	e = Explain(cred, ei.publicData(t), &stateDataBad)
+	assert.Equal(t, CheckStateMismatch, e.Failed().Name)
+
+	// New state, the old claims root is in the roots tree
+	indexBytes[0] = 0x44
+	claim1 := claims.NewClaimBasic(indexBytes, dataBytes, 4)
+	require.Nil(t, ei.clt.AddClaim(claim1))
+	cred1 := ei.credential(t, claim1, 11)
+	stateData = cred1.IdenStateData
+	e = Explain(cred, ei.publicData(t), &stateData)
+	assert.True(t, e.Ok(), e.String())
+	assert.Equal(t, CheckRootsTreeProof, e.Checks[3].Name)
+
+	// New state, the old claims root is not in the roots tree
+	indexBytes[0] = 0x45
+	require.Nil(t, ei.clt.AddClaim(claims.NewClaimBasic(indexBytes, dataBytes, 5)))
+	stateData = proof.IdenStateData{BlockN: 12, BlockTs: 120, IdenState: ei.state()}
+	e = Explain(cred1, ei.publicData(t), &stateData)
+	assert.Equal(t, CheckRootsTreeProof, e.Failed().Name)
+	assert.Nil(t, e.Checks[4].Err)
+
+	// New state where the claim is revoked
+	require.Nil(t, claims.AddLeafRevocationsTree(ei.ret, 3, claims.RevocationVersionAll))
+	stateData = proof.IdenStateData{BlockN: 13, BlockTs: 130, IdenState: ei.state()}
+	e = Explain(cred, ei.publicData(t), &stateData)
+	assert.Equal(t, CheckExpiredState, e.Failed().Name)
+	assert.NotNil(t, e.Checks[4].Err)
+
+	// Revoked claim
+	cred = ei.credential(t, claim0, 14)
+	stateData = cred.IdenStateData
+	e = Explain(cred, ei.publicData(t), &stateData)
+	assert.Equal(t, CheckRevocationProof, e.Failed().Name)
+	assert.NotNil(t, e.Err())
+
+	// Public data and state on chain not available
+	e = Explain(cred, nil, nil)
+	require.Equal(t, 5, len(e.Checks))
+	assert.Nil(t, e.Checks[0].Err)
+	assert.Equal(t, ErrStateDataNotAvailable, e.Checks[1].Err)
+	assert.Equal(t, ErrStateDataNotAvailable, e.Checks[2].Err)
+	assert.Equal(t, ErrPublicDataNotAvailable, e.Checks[3].Err)
+	assert.Equal(t, ErrPublicDataNotAvailable, e.Checks[4].Err)
+	e = Explain(cred, nil, &stateData)
+	assert.Equal(t, ErrPublicDataNotAvailable, e.Checks[1].Err)
+	assert.Nil(t, e.Checks[2].Err)
+}
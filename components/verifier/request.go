@@ -0,0 +1,149 @@
+package verifier
+
+import (
+	"crypto/rand"
+	"fmt"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/noncestore"
+)
+
+// NonceLen is the length in bytes of the nonces of the proof requests.
+const NonceLen = 32
+
+var (
+	ErrNoProofRequest        = fmt.Errorf("The presentation bundle doesn't respond to a proof request")
+	ErrAudienceDoesntMatch   = fmt.Errorf("The proof request audience doesn't match the verifier")
+	ErrProofRequestExpired   = fmt.Errorf("The proof request has expired")
+	ErrNonceUnknown          = fmt.Errorf("The proof request nonce was not issued by the verifier")
+	ErrNonceUsed             = fmt.Errorf("The proof request nonce has already been used")
+	ErrNonceExpirationChange = fmt.Errorf("The proof request expiration doesn't match the issued one")
+)
+
+// NonceStore keeps the nonces of the proof requests issued by a verifier,
+// so that each one is accepted once and before it expires.  It must be
+// persistent for the nonces to survive restarts of the verifier.
+type NonceStore interface {
+	// Issue records the nonce, which expires at expiration.
+	Issue(nonce []byte, expiration time.Time) error
+	// Use marks the nonce as used.  It returns ErrNonceUnknown if the
+	// nonce was not issued, ErrNonceUsed if it was already used and
+	// ErrProofRequestExpired if it has expired at now.
+	Use(nonce []byte, now time.Time) (expiration time.Time, err error)
+	// Prune removes the nonces expired at now, which can't be used
+	// anymore.
+	Prune(now time.Time) error
+}
+
+var dbPrefixNonce = []byte("proofnonce:")
+
+// DBNonceStore is a NonceStore over a db.Storage.
+type DBNonceStore struct {
+	store *noncestore.Store
+}
+
+// NewDBNonceStore creates a DBNonceStore over storage.
+func NewDBNonceStore(storage db.Storage) *DBNonceStore {
+	return &DBNonceStore{store: noncestore.New(storage.WithPrefix(dbPrefixNonce))}
+}
+
+// Issue records the nonce, which expires at expiration.
+func (s *DBNonceStore) Issue(nonce []byte, expiration time.Time) error {
+	return s.store.Issue(nonce, expiration)
+}
+
+// Use marks the nonce as used.
+func (s *DBNonceStore) Use(nonce []byte, now time.Time) (time.Time, error) {
+	expiration, err := s.store.Use(nonce, now)
+	switch err {
+	case noncestore.ErrUnknown:
+		err = ErrNonceUnknown
+	case noncestore.ErrUsed:
+		err = ErrNonceUsed
+	case noncestore.ErrExpired:
+		err = ErrProofRequestExpired
+	}
+	return expiration, err
+}
+
+// Prune removes the nonces expired at now.
+func (s *DBNonceStore) Prune(now time.Time) error {
+	_, err := s.store.Prune(now)
+	return err
+}
+
+// ProofRequester issues the proof requests of a verifier and verifies the
+// presentation bundles that respond to them, rejecting the bundles for
+// another audience, with an expired request or with a nonce already used.
+type ProofRequester struct {
+	verifier *Verifier
+	audience string
+	ttl      time.Duration
+	nonces   NonceStore
+}
+
+// NewProofRequester creates a ProofRequester of the verifier identified by
+// audience, whose requests expire after ttl.
+func NewProofRequester(verifier *Verifier, audience string, ttl time.Duration,
+	nonces NonceStore) *ProofRequester {
+	return &ProofRequester{verifier: verifier, audience: audience, ttl: ttl, nonces: nonces}
+}
+
+// NewRequest issues a new proof request with a fresh nonce.
+func (r *ProofRequester) NewRequest() (*proof.ProofRequest, error) {
+	nonce := make([]byte, NonceLen)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	now := r.verifier.timeNow()
+	expiration := now.Add(r.ttl)
+	if err := r.nonces.Issue(nonce, expiration); err != nil {
+		return nil, err
+	}
+	return &proof.ProofRequest{
+		Audience:     r.audience,
+		Nonce:        nonce,
+		IssuedTs:     now.Unix(),
+		ExpirationTs: expiration.Unix(),
+	}, nil
+}
+
+// VerifyResponse verifies that the bundle responds to a request issued by
+// r for its audience, and then verifies the bundle like
+// Verifier.VerifyPresentationBundle.  Once the signature of the bundle is
+// verified, the nonce of the request is used, so that the bundle can't be
+// replayed.
+func (r *ProofRequester) VerifyResponse(bundle *proof.PresentationBundle,
+	freshness time.Duration) (*PresentationResult, error) {
+	req := bundle.Request
+	if req == nil {
+		return nil, ErrNoProofRequest
+	}
+	if req.Audience != r.audience {
+		return nil, ErrAudienceDoesntMatch
+	}
+	now := r.verifier.timeNow()
+	if now.After(time.Unix(req.ExpirationTs, 0)) {
+		return nil, ErrProofRequestExpired
+	}
+	challenge, err := req.Challenge()
+	if err != nil {
+		return nil, err
+	}
+	result, err := r.verifier.VerifyPresentationBundle(bundle, challenge, freshness)
+	if err != nil {
+		return nil, err
+	}
+	// The expiration is checked against the issued one, as the request
+	// is in the hands of the holder.
+	expiration, err := r.nonces.Use(req.Nonce, now)
+	if err != nil {
+		return nil, err
+	}
+	if expiration.Unix() != req.ExpirationTs {
+		return nil, ErrNonceExpirationChange
+	}
+	return result, nil
+}
@@ -0,0 +1,127 @@
+package verifier
+
+import (
+	"testing"
+	"time"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDBNonceStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := NewDBNonceStore(db.NewMemoryStorage())
+	require.Nil(t, store.Issue([]byte("a"), now.Add(time.Minute)))
+	require.Nil(t, store.Issue([]byte("b"), now.Add(time.Hour)))
+
+	_, err := store.Use([]byte("c"), now)
+	assert.Equal(t, ErrNonceUnknown, err)
+	expiration, err := store.Use([]byte("a"), now)
+	require.Nil(t, err)
+	assert.Equal(t, now.Add(time.Minute), expiration)
+	_, err = store.Use([]byte("a"), now)
+	assert.Equal(t, ErrNonceUsed, err)
+	_, err = store.Use([]byte("b"), now.Add(2*time.Hour))
+	assert.Equal(t, ErrProofRequestExpired, err)
+
+	require.Nil(t, store.Prune(now.Add(2*time.Minute)))
+	_, err = store.Use([]byte("a"), now)
+	assert.Equal(t, ErrNonceUnknown, err)
+	_, err = store.Use([]byte("b"), now)
+	assert.Nil(t, err)
+}
+
+func TestProofRequester(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	is := newIssuerIssuedClaim(t, idenPubOnChain, claim)
+	cred, err := is.GenCredentialExistence(claim)
+	require.Nil(t, err)
+
+	holder, _, keyStoreH := newIssuer(t, idenPubOnChain)
+	genesisStateH, _ := holder.State()
+	indexBytes[0] = 0x50
+	require.Nil(t, holder.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0)))
+	publishFirstState(t, idenPubOnChain, holder, genesisStateH, 13)
+	kOpH, err := keyStoreH.Keys()[0].Decompress()
+	require.Nil(t, err)
+	credKSign, err := holder.GenCredentialExistence(claims.NewClaimAuthorizeKSignBabyJub(kOpH, 0))
+	require.Nil(t, err)
+
+	clk := clock.NewFake(time.Unix(1000, 0))
+	verifier := NewWithClock(idenPubOnChain, clk)
+	verifier.SetRevocationChecker(revocations{})
+	requester := NewProofRequester(verifier, "https://verifier.example", time.Minute,
+		NewDBNonceStore(db.NewMemoryStorage()))
+	respond := func(req *proof.ProofRequest) *proof.PresentationBundle {
+		bb, err := proof.NewPresentationBundleBuilderForRequest(req)
+		require.Nil(t, err)
+		bundle, err := bb.AddExistence(cred).Sign(holder, credKSign)
+		require.Nil(t, err)
+		return bundle
+	}
+
+	req, err := requester.NewRequest()
+	require.Nil(t, err)
+	assert.Equal(t, "https://verifier.example", req.Audience)
+	assert.Equal(t, NonceLen, len(req.Nonce))
+	assert.Equal(t, int64(1060), req.ExpirationTs)
+	bundle := respond(req)
+	res, err := requester.VerifyResponse(bundle, time.Hour)
+	require.Nil(t, err)
+	assert.True(t, res.Valid())
+
+	// The response is replayed.
+	_, err = requester.VerifyResponse(bundle, time.Hour)
+	assert.Equal(t, ErrNonceUsed, err)
+
+	// The response is presented to another verifier.
+	other := NewProofRequester(verifier, "https://other.example", time.Minute,
+		NewDBNonceStore(db.NewMemoryStorage()))
+	_, err = other.VerifyResponse(bundle, time.Hour)
+	assert.Equal(t, ErrAudienceDoesntMatch, err)
+
+	// The request has expired.
+	req, err = requester.NewRequest()
+	require.Nil(t, err)
+	bundle = respond(req)
+	clk.Advance(2 * time.Minute)
+	_, err = requester.VerifyResponse(bundle, time.Hour)
+	assert.Equal(t, ErrProofRequestExpired, err)
+
+	// The holder extends the expiration of the request.
+	req, err = requester.NewRequest()
+	require.Nil(t, err)
+	req.ExpirationTs += 3600
+	bundle = respond(req)
+	clk.Advance(2 * time.Minute)
+	_, err = requester.VerifyResponse(bundle, time.Hour)
+	assert.Equal(t, ErrProofRequestExpired, err)
+
+	// The request was not issued by the verifier.
+	req.Nonce = []byte("forged")
+	_, err = requester.VerifyResponse(respond(req), time.Hour)
+	assert.Equal(t, ErrNonceUnknown, err)
+
+	// The request is changed after signing.
+	req, err = requester.NewRequest()
+	require.Nil(t, err)
+	bundle = respond(req)
+	bundle.Request.IssuedTs++
+	_, err = requester.VerifyResponse(bundle, time.Hour)
+	assert.Equal(t, ErrChallengeDoesntMatch, err)
+
+	// A bundle without request.
+	bundle = respond(req)
+	bundle.Request = nil
+	_, err = requester.VerifyResponse(bundle, time.Hour)
+	assert.Equal(t, ErrNoProofRequest, err)
+}
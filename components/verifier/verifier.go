@@ -9,7 +9,10 @@ import (
 	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/claims"
 	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/keystore"
 	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-crypto/babyjub"
 )
 
 var (
@@ -17,20 +20,36 @@ var (
 	ErrMtpNonExistence                = fmt.Errorf("The Merkle Tree Proof is of non-existence")
 	ErrMtpExistence                   = fmt.Errorf("The Merkle Tree Proof is of existence")
 	ErrCalculatedIdenStateDoesntMatch = fmt.Errorf("Calculated IdenState doesn't match the one in the credential")
+	ErrCredentialIdDoesntMatch        = fmt.Errorf("The credential Id doesn't match the signer identity")
+	ErrInvalidClaimKSign              = fmt.Errorf("The credential claim is not a ClaimAuthorizeKSignBabyJub")
+	ErrInvalidClaimDelegate           = fmt.Errorf("The credential claim is not a ClaimDelegate for the required scope")
+	ErrInvalidSignature               = fmt.Errorf("Invalid signature")
+	ErrGenesisStateOutdated           = fmt.Errorf("The genesis credential is outdated, as the identity has published a state on chain")
+	ErrClaimKSignRevoked              = fmt.Errorf("The ClaimAuthorizeKSignBabyJub is revoked")
+	ErrClaimDelegateRevoked           = fmt.Errorf("The ClaimDelegate is revoked")
+	ErrRevocationCheckerNil           = fmt.Errorf("No RevocationChecker to check that the signing claims are not revoked")
 )
 
+// RevocationChecker checks whether the claim of a credential is revoked in
+// the last identity state of its issuer, satisfied by sigverify.SigVerifier.
+type RevocationChecker interface {
+	IsRevoked(cred *proof.CredentialExistence) (bool, error)
+}
+
 type Verifier struct {
 	idenPubOnChain idenpubonchain.IdenPubOnChainer
 	timeNow        func() time.Time
+	revocations    RevocationChecker
 }
 
 func New(idenPubOnChain idenpubonchain.IdenPubOnChainer) *Verifier {
-	return &Verifier{
-		idenPubOnChain: idenPubOnChain,
-		timeNow: func() time.Time {
-			return time.Now()
-		},
-	}
+	return NewWithClock(idenPubOnChain, clock.Real)
+}
+
+// NewWithClock creates a new Verifier that uses clk to check the freshness
+// of the credentials.
+func NewWithClock(idenPubOnChain idenpubonchain.IdenPubOnChainer, clk clock.Clock) *Verifier {
+	return NewWithTimeNow(idenPubOnChain, clk.Now)
 }
 
 func NewWithTimeNow(idenPubOnChain idenpubonchain.IdenPubOnChainer, timeNow func() time.Time) *Verifier {
@@ -40,33 +59,107 @@ func NewWithTimeNow(idenPubOnChain idenpubonchain.IdenPubOnChainer, timeNow func
 	}
 }
 
+// SetRevocationChecker sets the RevocationChecker used by VerifySignature
+// and VerifySignatureDelegated to reject the revoked keys and delegations.
+// Without it, they fail with ErrRevocationCheckerNil.
+func (v *Verifier) SetRevocationChecker(revocations RevocationChecker) {
+	v.revocations = revocations
+}
+
+// checkNotRevoked checks that the claim of cred is not revoked in the last
+// identity state of its issuer, returning errRevoked if it is.
+func (v *Verifier) checkNotRevoked(cred *proof.CredentialExistence, errRevoked error) error {
+	if v.revocations == nil {
+		return ErrRevocationCheckerNil
+	}
+	revoked, err := v.revocations.IsRevoked(cred)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errRevoked
+	}
+	return nil
+}
+
 func (v *Verifier) VerifyCredentialExistence(credExist *proof.CredentialExistence) error {
-	if !credExist.MtpClaim.Existence {
+	if err := credExist.Validate(); err != nil {
+		return err
+	}
+	return v.verifyExistence(credExist.Id, &credExist.IdenStateData, credExist.MtpClaim,
+		credExist.Claim.HIndex(), credExist.Claim.HValue(), credExist.RevocationsRoot, credExist.RootsRoot,
+		credExist.IsGenesis())
+}
+
+// VerifyBlindedCredentialExistence verifies a credential whose claim has
+// hidden slots like VerifyCredentialExistence, with the hashes of the hidden
+// halves of the claim.  The revealed slots are the ones of the claim issued.
+func (v *Verifier) VerifyBlindedCredentialExistence(credBlinded *proof.BlindedCredentialExistence) error {
+	if err := credBlinded.Validate(); err != nil {
+		return err
+	}
+	hIndex, hValue, err := credBlinded.Claim.Hashes()
+	if err != nil {
+		return err
+	}
+	return v.verifyExistence(credBlinded.Id, &credBlinded.IdenStateData, credBlinded.MtpClaim,
+		hIndex, hValue, credBlinded.RevocationsRoot, credBlinded.RootsRoot, credBlinded.IsGenesis())
+}
+
+// verifyExistence verifies that the claim with hIndex and hValue is in the
+// identity state of idenStateData of the identity id.
+func (v *Verifier) verifyExistence(id *core.ID, idenStateData *proof.IdenStateData, mtpClaim *merkletree.Proof,
+	hIndex, hValue, revocationsRoot, rootsRoot *merkletree.Hash, isGenesis bool) error {
+	if !mtpClaim.Existence {
 		return ErrMtpNonExistence
 	}
 	// Verify that the idenState is built from claims merkle tree where the
 	// claim exists.
-	claimsRoot, err := merkletree.RootFromProof(credExist.MtpClaim, credExist.Claim.HIndex(), credExist.Claim.HValue())
+	claimsRoot, err := merkletree.RootFromProof(mtpClaim, hIndex, hValue)
 	if err != nil {
 		return err
 	}
-	idenState := core.IdenState(claimsRoot, credExist.RevocationsRoot, credExist.RootsRoot)
-	if !idenState.Equals(credExist.IdenStateData.IdenState) {
+	idenState := core.IdenState(claimsRoot, revocationsRoot, rootsRoot)
+	if !idenState.Equals(idenStateData.IdenState) {
 		return ErrCalculatedIdenStateDoesntMatch
 	}
 
+	if isGenesis {
+		return v.verifyGenesis(id)
+	}
+
 	// Verify that the IdenStateData from the eistence credential is in the smart contract.
-	idenStateDataOnChain, err := v.idenPubOnChain.GetStateByBlock(credExist.Id, credExist.IdenStateData.BlockN)
+	idenStateDataOnChain, err := v.idenPubOnChain.GetStateByBlock(id, idenStateData.BlockN)
 	if err != nil {
 		return err
 	}
-	if !reflect.DeepEqual(idenStateDataOnChain, &credExist.IdenStateData) {
+	if !reflect.DeepEqual(idenStateDataOnChain, idenStateData) {
 		return ErrIdenStateOnChainDoesntMatch
 	}
 	return nil
 }
 
+// verifyGenesis verifies a credential of the genesis identity state of id.
+// The Id encodes the genesis state, so the credential is valid while the
+// identity hasn't published any state on chain, which allows freshly created
+// identities to use their genesis claims (like the kOp authorization)
+// immediately.  Once a state is published, the genesis claims may have been
+// revoked, so the credential must be refreshed to a state on chain.
+func (v *Verifier) verifyGenesis(id *core.ID) error {
+	idenStateDataLast, err := v.idenPubOnChain.GetState(id)
+	if err != nil {
+		return err
+	}
+	if idenStateDataLast.IdenState != nil && !idenStateDataLast.IdenState.Equals(&merkletree.HashZero) {
+		return ErrGenesisStateOutdated
+	}
+	return nil
+}
+
 func (v *Verifier) VerifyCredentialValidity(credValid *proof.CredentialValidity, freshness time.Duration) error {
+	if err := credValid.Validate(); err != nil {
+		return err
+	}
 	if err := v.VerifyCredentialExistence(&credValid.CredentialExistence); err != nil {
 		return err
 	}
@@ -96,7 +189,7 @@ func (v *Verifier) VerifyCredentialValidity(credValid *proof.CredentialValidity,
 	// where the claim is not revoked (the revocation nonce is not a leaf).
 	// NOTE: Once we add versions, this will require some changes that need to be thought properly!
 	nonce := claims.GetRevocationNonce(credValid.CredentialExistence.Claim)
-	revLeaf := claims.NewLeafRevocationsTree(nonce, 0xffffffff).Entry()
+	revLeaf := claims.NewLeafRevocationsTree(nonce, claims.RevocationVersionAll).Entry()
 	revocationsRoot, err := merkletree.RootFromProof(credValid.MtpNotNonce, revLeaf.HIndex(), revLeaf.HValue())
 	if err != nil {
 		return err
@@ -116,3 +209,66 @@ func (v *Verifier) VerifyCredentialValidity(credValid *proof.CredentialValidity,
 	}
 	return nil
 }
+
+// VerifySignature verifies that sig is a signature of prefix|msg (as
+// generated by Issuer.SignBinary) by the identity id, where credKSign is the
+// existence credential of the ClaimAuthorizeKSignBabyJub of the key used to
+// sign, issued by id.  The claim must not be revoked in the last identity
+// state of id (see SetRevocationChecker).
+func (v *Verifier) VerifySignature(id *core.ID, credKSign *proof.CredentialExistence,
+	prefix, msg []byte, sig *babyjub.SignatureComp) error {
+	if !credKSign.Id.Equal(id) {
+		return ErrCredentialIdDoesntMatch
+	}
+	claim, err := claims.NewClaimFromEntry(credKSign.Claim)
+	if err != nil {
+		return err
+	}
+	claimKSign, ok := claim.(*claims.ClaimAuthorizeKSignBabyJub)
+	if !ok {
+		return ErrInvalidClaimKSign
+	}
+	if err := v.VerifyCredentialExistence(credKSign); err != nil {
+		return err
+	}
+	if ok, err := keystore.VerifySignatureRaw(claimKSign.PublicKeyComp(), sig,
+		append(append([]byte{}, prefix...), msg...)); err != nil {
+		return err
+	} else if !ok {
+		return ErrInvalidSignature
+	}
+	return v.checkNotRevoked(credKSign, ErrClaimKSignRevoked)
+}
+
+// VerifySignatureDelegated verifies that sig is a signature of prefix|msg
+// made on behalf of the identity id for scope, following one level of
+// delegation.  If credDelegate is nil, the signature must be made by id
+// itself (see VerifySignature).  Otherwise credDelegate is the existence
+// credential of a ClaimDelegate issued by id for scope, and the signature must
+// be made by the delegate identity, with credKSign issued by the delegate.
+// Neither the ClaimDelegate nor the ClaimAuthorizeKSignBabyJub must be revoked
+// in the last identity state of their issuer (see SetRevocationChecker).
+func (v *Verifier) VerifySignatureDelegated(id *core.ID, scope claims.DelegateScope,
+	credDelegate, credKSign *proof.CredentialExistence, prefix, msg []byte, sig *babyjub.SignatureComp) error {
+	if credDelegate == nil {
+		return v.VerifySignature(id, credKSign, prefix, msg, sig)
+	}
+	if !credDelegate.Id.Equal(id) {
+		return ErrCredentialIdDoesntMatch
+	}
+	claim, err := claims.NewClaimFromEntry(credDelegate.Claim)
+	if err != nil {
+		return err
+	}
+	claimDelegate, ok := claim.(*claims.ClaimDelegate)
+	if !ok || claimDelegate.Scope != scope {
+		return ErrInvalidClaimDelegate
+	}
+	if err := v.VerifyCredentialExistence(credDelegate); err != nil {
+		return err
+	}
+	if err := v.VerifySignature(&claimDelegate.Id, credKSign, prefix, msg, sig); err != nil {
+		return err
+	}
+	return v.checkNotRevoked(credDelegate, ErrClaimDelegateRevoked)
+}
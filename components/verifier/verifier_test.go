@@ -13,6 +13,7 @@ import (
 	"github.com/iden3/go-iden3-core/identity/issuer"
 	"github.com/iden3/go-iden3-core/keystore"
 	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -29,6 +30,14 @@ func Copy(dst interface{}, src interface{}) {
 	}
 }
 
+// revocations is a RevocationChecker of the claims revoked in the tests,
+// by hIndex.
+type revocations map[merkletree.Hash]bool
+
+func (r revocations) IsRevoked(cred *proof.CredentialExistence) (bool, error) {
+	return r[*cred.Claim.HIndex()], nil
+}
+
 func newIssuer(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock) (*issuer.Issuer, db.Storage, *keystore.KeyStore) {
 	cfg := issuer.ConfigDefault
 	storage := db.NewMemoryStorage()
@@ -39,7 +48,7 @@ func newIssuer(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock)
 	require.Nil(t, err)
 	err = keyStore.UnlockKey(kOp, pass)
 	require.Nil(t, err)
-	is, err := issuer.New(cfg, kOp, []merkletree.Entrier{}, storage, keyStore, idenPubOnChain)
+	is, err := issuer.New(cfg, kOp, []merkletree.Entrier{}, storage, keyStore, idenPubOnChain, nil)
 	require.Nil(t, err)
 	return is, storage, keyStore
 }
@@ -71,7 +80,7 @@ func newIssuerIssuedClaim(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOn
 	_, newState := mockInitState(t, idenPubOnChain, is, genesisState)
 
 	// Publishing state for the first time
-	err = is.PublishState()
+	_, err = is.PublishState()
 	require.Nil(t, err)
 
 	blockN := uint64(12)
@@ -95,7 +104,7 @@ func newIssuerIssuedClaim2(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubO
 	_, newState := mockInitState(t, idenPubOnChain, is, genesisState)
 
 	// Publishing state for the first time with claim1
-	err = is.PublishState()
+	_, err = is.PublishState()
 	require.Nil(t, err)
 
 	blockN := uint64(12)
@@ -116,7 +125,7 @@ func newIssuerIssuedClaim2(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubO
 	require.Nil(t, err)
 
 	_, newState = mockSetState(t, idenPubOnChain, is, newState)
-	err = is.PublishState()
+	_, err = is.PublishState()
 	require.Nil(t, err)
 
 	blockN = uint64(13)
@@ -134,7 +143,7 @@ func newIssuerIssuedClaim2(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubO
 	require.Nil(t, err)
 
 	_, newState = mockSetState(t, idenPubOnChain, is, newState)
-	err = is.PublishState()
+	_, err = is.PublishState()
 	require.Nil(t, err)
 
 	blockN = uint64(14)
@@ -245,10 +254,7 @@ func TestVerifyCredentialValidity(t *testing.T) {
 	claim2 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
 	_, credExistClaim1 := newIssuerIssuedClaim2(t, idenPubOnChain, claim1, claim2)
 
-	now := time.Unix(400, 0)
-	verifier := NewWithTimeNow(idenPubOnChain, func() time.Time {
-		return now
-	})
+	verifier := NewWithClock(idenPubOnChain, clock.NewFake(time.Unix(400, 0)))
 
 	err := verifier.VerifyCredentialExistence(credExistClaim1)
 	assert.Nil(t, err)
@@ -261,3 +267,118 @@ func TestVerifyCredentialValidity(t *testing.T) {
 
 	// TODO: Continue once holder is implemented
 }
+
+func publishFirstState(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, is *issuer.Issuer,
+	genesisState *merkletree.Hash, blockN uint64) {
+	_, newState := mockInitState(t, idenPubOnChain, is, genesisState)
+	res, err := is.PublishState()
+	require.Nil(t, err)
+	require.Equal(t, issuer.PublishStateSubmitted, res.Status)
+	idenStateData := &proof.IdenStateData{IdenState: newState, BlockN: blockN, BlockTs: int64(blockN) * 100}
+	idenPubOnChain.On("GetState", is.ID()).Return(idenStateData, nil)
+	idenPubOnChain.On("GetStateByBlock", is.ID(), blockN).Return(idenStateData, nil)
+	require.Nil(t, is.SyncIdenStatePublic())
+}
+
+func TestVerifySignatureDelegated(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+
+	// isB is the delegate of isA
+	isB, _, keyStoreB := newIssuer(t, idenPubOnChain)
+	genesisStateB, _ := isB.State()
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	require.Nil(t, isB.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0)))
+	publishFirstState(t, idenPubOnChain, isB, genesisStateB, 12)
+
+	isA, _, _ := newIssuer(t, idenPubOnChain)
+	genesisStateA, _ := isA.State()
+	claimDelegate, err := isA.IssueClaimDelegate(isB.ID(), claims.DelegateScopeAuthenticate)
+	require.Nil(t, err)
+	assert.Equal(t, *isB.ID(), claimDelegate.Id)
+	_, err = isA.IssueClaimDelegate(isB.ID(), claims.DelegateScopeAuthenticate)
+	assert.Equal(t, issuer.ErrClaimAlreadyIssued, err)
+	publishFirstState(t, idenPubOnChain, isA, genesisStateA, 13)
+
+	credDelegate, err := isA.GenCredentialExistence(claimDelegate)
+	require.Nil(t, err)
+	kOpB, err := keyStoreB.Keys()[0].Decompress()
+	require.Nil(t, err)
+	credKSignB, err := isB.GenCredentialExistence(claims.NewClaimAuthorizeKSignBabyJub(kOpB, 0))
+	require.Nil(t, err)
+
+	prefix, msg := []byte("auth:"), []byte("challenge")
+	sig, err := isB.SignBinary(prefix, msg)
+	require.Nil(t, err)
+
+	verifier := New(idenPubOnChain)
+	assert.Equal(t, ErrRevocationCheckerNil, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeAuthenticate, credDelegate, credKSignB, prefix, msg, sig))
+	revoked := revocations{}
+	verifier.SetRevocationChecker(revoked)
+	// isB signs on behalf of isA
+	assert.Nil(t, verifier.VerifySignatureDelegated(isA.ID(), claims.DelegateScopeAuthenticate,
+		credDelegate, credKSignB, prefix, msg, sig))
+	// isB signs on its own behalf
+	assert.Nil(t, verifier.VerifySignatureDelegated(isB.ID(), claims.DelegateScopeAuthenticate,
+		nil, credKSignB, prefix, msg, sig))
+	// isB is not isA
+	assert.Equal(t, ErrCredentialIdDoesntMatch, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeAuthenticate, nil, credKSignB, prefix, msg, sig))
+	// isB is not authorized to issue claims on behalf of isA
+	assert.Equal(t, ErrInvalidClaimDelegate, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeIssueClaims, credDelegate, credKSignB, prefix, msg, sig))
+	// The key credential must be issued by the delegate
+	assert.Equal(t, ErrCredentialIdDoesntMatch, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeAuthenticate, credDelegate, credDelegate, prefix, msg, sig))
+	// The key credential must be of a ClaimAuthorizeKSignBabyJub
+	assert.Equal(t, ErrInvalidClaimKSign, verifier.VerifySignature(isA.ID(),
+		credDelegate, prefix, msg, sig))
+	// Signature of a different message
+	assert.Equal(t, ErrInvalidSignature, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeAuthenticate, credDelegate, credKSignB, prefix, []byte("other"), sig))
+
+	// isA revokes the delegation
+	revoked[*credDelegate.Claim.HIndex()] = true
+	assert.Equal(t, ErrClaimDelegateRevoked, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeAuthenticate, credDelegate, credKSignB, prefix, msg, sig))
+	// isB revokes its key
+	revoked[*credDelegate.Claim.HIndex()] = false
+	revoked[*credKSignB.Claim.HIndex()] = true
+	assert.Equal(t, ErrClaimKSignRevoked, verifier.VerifySignatureDelegated(isA.ID(),
+		claims.DelegateScopeAuthenticate, credDelegate, credKSignB, prefix, msg, sig))
+	assert.Equal(t, ErrClaimKSignRevoked, verifier.VerifySignature(isB.ID(), credKSignB, prefix, msg, sig))
+}
+
+func TestVerifyCredentialExistenceGenesis(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	is, _, keyStore := newIssuer(t, idenPubOnChain)
+	kOpComp := keyStore.Keys()[0]
+	kOp, err := kOpComp.Decompress()
+	require.Nil(t, err)
+	credKOp, err := is.GenCredentialExistenceGenesis(claims.NewClaimAuthorizeKSignBabyJub(kOp, 0))
+	require.Nil(t, err)
+
+	// The identity hasn't published any state yet.
+	idenPubOnChain.On("GetState", is.ID()).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero}, nil).Times(2)
+	verifier := New(idenPubOnChain)
+	verifier.SetRevocationChecker(revocations{})
+	require.Nil(t, verifier.VerifyCredentialExistence(credKOp))
+
+	msg := []byte("genesis")
+	sig, err := is.SignBinary(issuer.SigPrefixSetState, msg)
+	require.Nil(t, err)
+	require.Nil(t, verifier.VerifySignature(is.ID(), credKOp, issuer.SigPrefixSetState, msg, sig))
+
+	// A credential with a different claims tree proof doesn't match the
+	// genesis state.
+	credBad := &proof.CredentialExistence{}
+	Copy(credBad, credKOp)
+	credBad.RootsRoot = &merkletree.Hash{1}
+	assert.Equal(t, ErrCalculatedIdenStateDoesntMatch, verifier.VerifyCredentialExistence(credBad))
+
+	// After the identity publishes a state, the genesis credential must be
+	// refreshed.
+	idenPubOnChain.On("GetState", is.ID()).Return(&proof.IdenStateData{IdenState: &merkletree.Hash{1}, BlockN: 12}, nil).Once()
+	assert.Equal(t, ErrGenesisStateOutdated, verifier.VerifyCredentialExistence(credKOp))
+}
@@ -0,0 +1,157 @@
+// Package calldata encodes the credentials into the calldata of the smart
+// contracts that verify them on chain, so that the dapps can verify the
+// claims issued with this package.  The layout is the one of the
+// CredentialVerifierABI:
+//
+//   - The identity is a bytes31 and the identity states are a bytes32 with
+//     the bytes of the merkletree.Hash, like in the IdenStates contract.
+//   - The elements that the contract hashes (the claim slots, the roots and
+//     the siblings) are a uint256 with their integer value.
+//   - The block number and timestamp of an identity state are packed in a
+//     uint256 (see PackStateData).
+//   - The merkle tree proofs are the uint256[] of all their siblings from the
+//     root (see merkletree.Proof.AllSiblings), and the proofs of
+//     non-existence have a uint256[3] with the leaf found in the path (see
+//     NodeAux).  The bit n of the path of a leaf is the bit n of the bytes32
+//     of its HIndex read as a big-endian integer.
+package calldata
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+const (
+	// MethodVerifyCredentialExistence is the method of the
+	// CredentialVerifierABI that verifies a proof.CredentialExistence.
+	MethodVerifyCredentialExistence = "verifyCredentialExistence"
+	// MethodVerifyCredentialValidity is the method of the
+	// CredentialVerifierABI that verifies a proof.CredentialValidity.
+	MethodVerifyCredentialValidity = "verifyCredentialValidity"
+)
+
+// CredentialVerifierABI is the ABI of the verifier contracts of the
+// credentials:
+//
+//	function verifyCredentialExistence(bytes31 id, uint256 stateData, bytes32 idenState,
+//		uint256[8] claim, uint256[] mtpClaim, uint256 revocationsRoot, uint256 rootsRoot)
+//		view returns (bool)
+//	function verifyCredentialValidity(bytes31 id, uint256 stateData, bytes32 idenState,
+//		uint256[8] claim, uint256[] mtpClaim, uint256 revocationsRoot, uint256 rootsRoot,
+//		uint256 validityStateData, bytes32 validityIdenState, uint256[] mtpNotNonce,
+//		uint256[3] mtpNotNonceAux, uint256 claimsRoot, uint256 validityRootsRoot)
+//		view returns (bool)
+//
+// They check that the claim is in the identity state, and that the identity
+// state data is in the IdenStates contract, like the verifier.Verifier.
+const CredentialVerifierABI = `[` +
+	`{"inputs":[{"internalType":"bytes31","name":"id","type":"bytes31"},{"internalType":"uint256","name":"stateData","type":"uint256"},{"internalType":"bytes32","name":"idenState","type":"bytes32"},{"internalType":"uint256[8]","name":"claim","type":"uint256[8]"},{"internalType":"uint256[]","name":"mtpClaim","type":"uint256[]"},{"internalType":"uint256","name":"revocationsRoot","type":"uint256"},{"internalType":"uint256","name":"rootsRoot","type":"uint256"}],"name":"verifyCredentialExistence","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"},` +
+	`{"inputs":[{"internalType":"bytes31","name":"id","type":"bytes31"},{"internalType":"uint256","name":"stateData","type":"uint256"},{"internalType":"bytes32","name":"idenState","type":"bytes32"},{"internalType":"uint256[8]","name":"claim","type":"uint256[8]"},{"internalType":"uint256[]","name":"mtpClaim","type":"uint256[]"},{"internalType":"uint256","name":"revocationsRoot","type":"uint256"},{"internalType":"uint256","name":"rootsRoot","type":"uint256"},{"internalType":"uint256","name":"validityStateData","type":"uint256"},{"internalType":"bytes32","name":"validityIdenState","type":"bytes32"},{"internalType":"uint256[]","name":"mtpNotNonce","type":"uint256[]"},{"internalType":"uint256[3]","name":"mtpNotNonceAux","type":"uint256[3]"},{"internalType":"uint256","name":"claimsRoot","type":"uint256"},{"internalType":"uint256","name":"validityRootsRoot","type":"uint256"}],"name":"verifyCredentialValidity","outputs":[{"internalType":"bool","name":"","type":"bool"}],"stateMutability":"view","type":"function"}` +
+	`]`
+
+// PackStateData packs the block number and timestamp of an identity state
+// data in a uint256: blockN << 64 | blockTs.
+func PackStateData(stateData *proof.IdenStateData) *big.Int {
+	packed := new(big.Int).SetUint64(stateData.BlockN)
+	packed.Lsh(packed, 64)
+	return packed.Or(packed, new(big.Int).SetUint64(uint64(stateData.BlockTs)))
+}
+
+// Siblings returns all the siblings of the proof from the root as uint256.
+func Siblings(mtp *merkletree.Proof) []*big.Int {
+	siblings := mtp.AllSiblings()
+	ints := make([]*big.Int, len(siblings))
+	for i, sibling := range siblings {
+		ints[i] = sibling.BigInt()
+	}
+	return ints
+}
+
+// NodeAux returns the leaf found in the path of a proof of non-existence as
+// [flag, hIndex, hValue], where flag is 1 if there's a leaf and 0 if the path
+// ends in an empty node.
+func NodeAux(mtp *merkletree.Proof) [3]*big.Int {
+	hIndex, hValue, ok := mtp.NodeAux()
+	if !ok {
+		return [3]*big.Int{big.NewInt(0), big.NewInt(0), big.NewInt(0)}
+	}
+	return [3]*big.Int{big.NewInt(1), hIndex.BigInt(), hValue.BigInt()}
+}
+
+// Claim returns the slots of the claim as uint256.
+func Claim(claim *merkletree.Entry) [merkletree.DataLen]*big.Int {
+	var ints [merkletree.DataLen]*big.Int
+	for i := range claim.Data {
+		ints[i] = claim.Data[i].BigInt()
+	}
+	return ints
+}
+
+// CredentialExistenceArgs returns the arguments of
+// MethodVerifyCredentialExistence for the credential.
+func CredentialExistenceArgs(cred *proof.CredentialExistence) ([]interface{}, error) {
+	if err := cred.Validate(); err != nil {
+		return nil, err
+	}
+	return []interface{}{
+		[31]byte(*cred.Id),
+		PackStateData(&cred.IdenStateData),
+		[32]byte(*cred.IdenStateData.IdenState),
+		Claim(cred.Claim),
+		Siblings(cred.MtpClaim),
+		cred.RevocationsRoot.BigInt(),
+		cred.RootsRoot.BigInt(),
+	}, nil
+}
+
+// CredentialValidityArgs returns the arguments of
+// MethodVerifyCredentialValidity for the credential.
+func CredentialValidityArgs(cred *proof.CredentialValidity) ([]interface{}, error) {
+	if err := cred.Validate(); err != nil {
+		return nil, err
+	}
+	args, err := CredentialExistenceArgs(&cred.CredentialExistence)
+	if err != nil {
+		return nil, err
+	}
+	return append(args,
+		PackStateData(&cred.IdenStateData),
+		[32]byte(*cred.IdenStateData.IdenState),
+		Siblings(cred.MtpNotNonce),
+		NodeAux(cred.MtpNotNonce),
+		cred.ClaimsRoot.BigInt(),
+		cred.RootsRoot.BigInt(),
+	), nil
+}
+
+func pack(method string, args []interface{}) ([]byte, error) {
+	parsed, err := abi.JSON(strings.NewReader(CredentialVerifierABI))
+	if err != nil {
+		return nil, err
+	}
+	return parsed.Pack(method, args...)
+}
+
+// PackCredentialExistence returns the calldata of the call to
+// MethodVerifyCredentialExistence with the credential.
+func PackCredentialExistence(cred *proof.CredentialExistence) ([]byte, error) {
+	args, err := CredentialExistenceArgs(cred)
+	if err != nil {
+		return nil, err
+	}
+	return pack(MethodVerifyCredentialExistence, args)
+}
+
+// PackCredentialValidity returns the calldata of the call to
+// MethodVerifyCredentialValidity with the credential.
+func PackCredentialValidity(cred *proof.CredentialValidity) ([]byte, error) {
+	args, err := CredentialValidityArgs(cred)
+	if err != nil {
+		return nil, err
+	}
+	return pack(MethodVerifyCredentialValidity, args)
+}
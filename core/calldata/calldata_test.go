@@ -0,0 +1,181 @@
+package calldata
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math/big"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// If generateTest is true, the fixtures in testVectors will be overwritten
+// with the calldata encoded by the Go implementation.
+var generateTest = false
+
+// newCredentials builds deterministic credentials of a claim in the claims
+// tree of an identity.
+func newCredentials(t *testing.T) (*proof.CredentialExistence, *proof.CredentialValidity) {
+	clt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	ret, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rot, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		var indexSlot [claims.IndexSlotBytes]byte
+		var dataSlot [claims.DataSlotBytes]byte
+		indexSlot[0], dataSlot[0] = byte(i), byte(i+1)
+		require.Nil(t, clt.AddClaim(claims.NewClaimBasic(indexSlot, dataSlot, uint32(i))))
+	}
+	require.Nil(t, claims.AddLeafRevocationsTree(ret, 7, claims.RevocationVersionAll))
+	require.Nil(t, claims.AddLeafRootsTree(rot, clt.RootKey()))
+	idenState := core.IdenState(clt.RootKey(), ret.RootKey(), rot.RootKey())
+	id := core.IdGenesisFromIdenState(idenState)
+
+	var indexSlot [claims.IndexSlotBytes]byte
+	var dataSlot [claims.DataSlotBytes]byte
+	indexSlot[0], dataSlot[0] = 2, 3
+	claim := claims.NewClaimBasic(indexSlot, dataSlot, 2).Entry()
+	mtp, err := clt.GenerateProof(claim.HIndex(), nil)
+	require.Nil(t, err)
+	mtpNotNonce, err := ret.GenerateProof(claims.HIndexLeafRevocationsTree(2, claims.RevocationVersionAll), nil)
+	require.Nil(t, err)
+
+	stateData := proof.IdenStateData{BlockN: 42, BlockTs: 1580000000, IdenState: idenState}
+	credExist := &proof.CredentialExistence{
+		Id:              id,
+		IdenStateData:   stateData,
+		MtpClaim:        mtp,
+		Claim:           claim,
+		RevocationsRoot: ret.RootKey(),
+		RootsRoot:       rot.RootKey(),
+	}
+	credValid := &proof.CredentialValidity{
+		CredentialExistence: *credExist,
+		IdenStateData:       stateData,
+		MtpNotNonce:         mtpNotNonce,
+		ClaimsRoot:          clt.RootKey(),
+		RootsRoot:           rot.RootKey(),
+	}
+	return credExist, credValid
+}
+
+// checkFixture compares the calldata with the fixture name, or writes it
+// when generateTest is set.
+func checkFixture(t *testing.T, name string, calldata []byte) {
+	fileName := path.Join("testVectors", name+".json")
+	if generateTest {
+		b, err := json.MarshalIndent(map[string]string{"calldata": "0x" + hex.EncodeToString(calldata)}, "", "  ")
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(fileName, append(b, '\n'), 0644))
+	}
+	b, err := ioutil.ReadFile(fileName)
+	require.Nil(t, err)
+	var fixture map[string]string
+	require.Nil(t, json.Unmarshal(b, &fixture))
+	assert.Equal(t, fixture["calldata"], "0x"+hex.EncodeToString(calldata))
+}
+
+// unpack checks the selector of the calldata of method and returns its
+// arguments.
+func unpack(t *testing.T, signature string, calldata []byte) []interface{} {
+	parsed, err := abi.JSON(strings.NewReader(CredentialVerifierABI))
+	require.Nil(t, err)
+	method := parsed.Methods[strings.Split(signature, "(")[0]]
+	assert.Equal(t, signature, method.Sig())
+	require.Equal(t, crypto.Keccak256([]byte(signature))[:4], calldata[:4])
+	args, err := method.Inputs.UnpackValues(calldata[4:])
+	require.Nil(t, err)
+	return args
+}
+
+func elem(i *big.Int) merkletree.ElemBytes {
+	return merkletree.ElemBytes(merkletree.BigIntToHash(i))
+}
+
+func hash(i *big.Int) *merkletree.Hash {
+	h := merkletree.BigIntToHash(i)
+	return &h
+}
+
+// rootFromSiblings calculates the root of a merkle tree like a contract,
+// from all the siblings of the path of hIndex and the key of the leaf.
+func rootFromSiblings(siblings []*big.Int, hIndex *merkletree.Hash, leafKey *big.Int) *big.Int {
+	path := new(big.Int).SetBytes(hIndex[:])
+	mid := leafKey
+	for lvl := len(siblings) - 1; lvl >= 0; lvl-- {
+		l, r := mid, siblings[lvl]
+		if path.Bit(lvl) == 1 {
+			l, r = r, l
+		}
+		mid = merkletree.HashElems(elem(l), elem(r)).BigInt()
+	}
+	return mid
+}
+
+func TestPackCredentialExistence(t *testing.T) {
+	credExist, _ := newCredentials(t)
+	calldata, err := PackCredentialExistence(credExist)
+	require.Nil(t, err)
+	checkFixture(t, "credentialExistence", calldata)
+
+	args := unpack(t, "verifyCredentialExistence(bytes31,uint256,bytes32,uint256[8],uint256[],uint256,uint256)", calldata)
+	require.Equal(t, 7, len(args))
+	assert.Equal(t, [31]byte(*credExist.Id), args[0])
+	stateData := args[1].(*big.Int)
+	assert.Equal(t, uint64(42), new(big.Int).Rsh(stateData, 64).Uint64())
+	assert.Equal(t, uint64(1580000000), stateData.Uint64())
+	assert.Equal(t, [32]byte(*credExist.IdenStateData.IdenState), args[2])
+
+	// The contract recalculates the identity state from the claim.
+	var claim merkletree.Entry
+	for i, slot := range args[3].([8]*big.Int) {
+		claim.Data[i] = elem(slot)
+	}
+	assert.Equal(t, credExist.Claim.Data, claim.Data)
+	claimsRoot := rootFromSiblings(args[4].([]*big.Int), claim.HIndex(),
+		merkletree.LeafKey(claim.HIndex(), claim.HValue()).BigInt())
+	idenState := merkletree.HashElems(elem(claimsRoot),
+		elem(args[5].(*big.Int)), elem(args[6].(*big.Int)))
+	assert.Equal(t, credExist.IdenStateData.IdenState, idenState)
+
+	credExist.MtpClaim = nil
+	_, err = PackCredentialExistence(credExist)
+	assert.Equal(t, proof.ErrInvalidCredential, err)
+}
+
+func TestPackCredentialValidity(t *testing.T) {
+	_, credValid := newCredentials(t)
+	calldata, err := PackCredentialValidity(credValid)
+	require.Nil(t, err)
+	checkFixture(t, "credentialValidity", calldata)
+
+	args := unpack(t, "verifyCredentialValidity(bytes31,uint256,bytes32,uint256[8],uint256[],uint256,uint256,"+
+		"uint256,bytes32,uint256[],uint256[3],uint256,uint256)", calldata)
+	require.Equal(t, 13, len(args))
+
+	// The contract recalculates the revocations root from the proof of
+	// non-existence of the claim nonce.
+	hIndex := claims.HIndexLeafRevocationsTree(2, claims.RevocationVersionAll)
+	aux := args[10].([3]*big.Int)
+	leafKey := big.NewInt(0)
+	if aux[0].Sign() != 0 {
+		leafKey = merkletree.LeafKey(hash(aux[1]), hash(aux[2])).BigInt()
+	}
+	revocationsRoot := rootFromSiblings(args[9].([]*big.Int), hIndex, leafKey)
+	idenState := merkletree.HashElems(elem(args[11].(*big.Int)),
+		elem(revocationsRoot), elem(args[12].(*big.Int)))
+	assert.Equal(t, [32]byte(*idenState), args[8])
+}
@@ -0,0 +1,39 @@
+package claims
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+func BenchmarkClaimBasicEntry(b *testing.B) {
+	indexBytes, dataBytes := [IndexSlotBytes]byte{}, [DataSlotBytes]byte{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.BigEndian.PutUint64(indexBytes[:8], uint64(i))
+		NewClaimBasic(indexBytes, dataBytes, uint32(i)).Entry()
+	}
+}
+
+func BenchmarkClaimBasicHash(b *testing.B) {
+	indexBytes, dataBytes := [IndexSlotBytes]byte{}, [DataSlotBytes]byte{}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		binary.BigEndian.PutUint64(indexBytes[:8], uint64(i))
+		e := NewClaimBasic(indexBytes, dataBytes, uint32(i)).Entry()
+		e.HIndex()
+		e.HValue()
+	}
+}
+
+func BenchmarkClaimAuthorizeKSignBabyJubHash(b *testing.B) {
+	k := babyjub.NewRandPrivKey()
+	pk := k.Public()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e := NewClaimAuthorizeKSignBabyJub(pk, uint32(i)).Entry()
+		e.HIndex()
+		e.HValue()
+	}
+}
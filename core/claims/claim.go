@@ -117,6 +117,18 @@ var (
 	ClaimTypeEthId = NewClaimTypeNum(8)
 	// ClaimTypeAuthEthKey is a claim type to authorize an Eth Address directly from a private key, allowing to specify if is used as KDisable (revoke), KReenable (recover), etc
 	ClaimTypeAuthEthKey = NewClaimTypeNum(9)
+	// ClaimTypeDelegate is a claim type to authorize another identity to act on behalf of the identity for a scoped purpose
+	ClaimTypeDelegate = NewClaimTypeNum(10)
+	// ClaimTypeTokenOwnership is a claim type to attest the ownership of ERC-20 / ERC-721 tokens by an identity at a block
+	ClaimTypeTokenOwnership = NewClaimTypeNum(11)
+	// ClaimTypeEmail is a claim type to attest an email address of an identity (see package std)
+	ClaimTypeEmail = NewClaimTypeNum(12)
+	// ClaimTypeBirthdate is a claim type to attest the birthdate of an identity (see package std)
+	ClaimTypeBirthdate = NewClaimTypeNum(13)
+	// ClaimTypeKYCLevel is a claim type to attest the KYC level verified for an identity (see package std)
+	ClaimTypeKYCLevel = NewClaimTypeNum(14)
+	// ClaimTypeAuthorizeKEncX25519 is a claim type to authorize an X25519 public key for encrypting the messages sent to an identity.
+	ClaimTypeAuthorizeKEncX25519 = NewClaimTypeNum(15)
 )
 
 // ClaimTypeVersionLen is the length in bytes of the version and length in a claim.
@@ -159,6 +171,15 @@ func NewClaimFromEntry(e *merkletree.Entry) (merkletree.Entrier, error) {
 	case *ClaimTypeAuthEthKey:
 		c := NewClaimAuthEthKeyFromEntry(e)
 		return c, nil
+	case *ClaimTypeDelegate:
+		c := NewClaimDelegateFromEntry(e)
+		return c, nil
+	case *ClaimTypeTokenOwnership:
+		c := NewClaimTokenOwnershipFromEntry(e)
+		return c, nil
+	case *ClaimTypeAuthorizeKEncX25519:
+		c := NewClaimAuthorizeKEncX25519FromEntry(e)
+		return c, nil
 	default:
 		return nil, ErrInvalidClaimType
 	}
@@ -29,12 +29,16 @@ var (
 	EthKeyTypeUpgrade = NewEthKeyType(2)
 	// EthKeyTypeUpdateRoot specifies a Ethereum Key (Addr) that is allowed to Update the Root in roots smart contract in name of the ID
 	EthKeyTypeUpdateRoot = NewEthKeyType(3)
+	// EthKeyTypeAuthenticate specifies a Ethereum Key (Addr) that is allowed to authenticate (sign messages) in name of the ID
+	EthKeyTypeAuthenticate = NewEthKeyType(4)
 )
 
 // ClaimAuthEthKey is a claim type to authorize an Eth Address directly from a private key, allowing to specify if is used as KDisable (revoke), KReenable (recover), etc
 type ClaimAuthEthKey struct {
 	// Version is the claim version
 	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
 	// EthKey is the ethereum address of the Key that is being authorized
 	EthKey common.Address
 	// EthKeyType specifies the type of the EthKey, for which use is authorized
@@ -42,33 +46,35 @@ type ClaimAuthEthKey struct {
 }
 
 // NewClaimAuthEthKey returns a ClaimAuthEthKey
-func NewClaimAuthEthKey(ethKey common.Address, typ EthKeyType) *ClaimAuthEthKey {
+func NewClaimAuthEthKey(ethKey common.Address, typ EthKeyType, revocationNonce uint32) *ClaimAuthEthKey {
 	return &ClaimAuthEthKey{
-		Version:    0,
-		EthKey:     ethKey,
-		EthKeyType: binary.BigEndian.Uint32(typ[:]),
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		EthKey:          ethKey,
+		EthKeyType:      binary.BigEndian.Uint32(typ[:]),
 	}
 }
 
-// NewClaimAuthEthKey deserializes a ClaimAuthEthKey from an Entry
+// NewClaimAuthEthKeyFromEntry deserializes a ClaimAuthEthKey from an Entry
 func NewClaimAuthEthKeyFromEntry(e *merkletree.Entry) *ClaimAuthEthKey {
 	c := &ClaimAuthEthKey{}
 	_, c.Version = GetClaimTypeVersion(e)
-	copyFromElemBytes(c.EthKey[:], 0, &e.Data[2])
-	var typ [EthKeyTypeLen]byte
-	copyFromElemBytes(typ[:], 20, &e.Data[2])
-	c.EthKeyType = binary.BigEndian.Uint32(typ[:])
+	copy(c.EthKey[:], e.Data[1][:common.AddressLength])
+	c.EthKeyType = binary.BigEndian.Uint32(e.Data[1][common.AddressLength : common.AddressLength+EthKeyTypeLen])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
 	return c
 }
 
 // Entry serializes the claim into an Entry
 func (c *ClaimAuthEthKey) Entry() *merkletree.Entry {
 	e := &merkletree.Entry{}
+	index := e.Index()
 	SetClaimTypeVersion(e, c.Type(), c.Version)
-	copyToElemBytes(&e.Data[2], 0, c.EthKey[:])
-	var typ [EthKeyTypeLen]byte
-	binary.BigEndian.PutUint32(typ[:], c.EthKeyType)
-	copyToElemBytes(&e.Data[2], 20, typ[:])
+	copy(index[1][:common.AddressLength], c.EthKey[:])
+	binary.BigEndian.PutUint32(index[1][common.AddressLength:common.AddressLength+EthKeyTypeLen], c.EthKeyType)
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+
 	return e
 }
 
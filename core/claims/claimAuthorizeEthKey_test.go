@@ -1,47 +1,64 @@
 package claims
 
-/*
-// TMP commented until ClaimAuthEthKey is updated to new spec
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/testgen"
+	"github.com/stretchr/testify/assert"
+)
+
 func TestClaimAuthEthKey(t *testing.T) {
 	// If generateTest is true, the checked values will be used to generate a test vector
 	generateTest := false
 
-	// Init test
+	// Init test, restoring the claim test vector afterwards
+	defer testgen.RestoreState(testgen.SaveState())
 	if err := testgen.InitTest("claimAuthorizeEthKey", generateTest); err != nil {
 		panic(fmt.Errorf("error initializing test data: %w", err))
 	}
 	// Add input data to the test vector
 	if generateTest {
 		testgen.SetTestValue("addr", "0xe0fbce58cfaa72812103f003adce3f284fe5fc7c")
+		testgen.SetTestValue("revocationNonce", float64(1234))
 	}
-	ethKey := common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c")
+	ethKey := common.HexToAddress(testgen.GetTestValue("addr").(string))
+	revocationNonce := uint32(testgen.GetTestValue("revocationNonce").(float64))
 	ethKeyType := EthKeyTypeUpgrade
 
-	c0 := NewClaimAuthEthKey(ethKey, ethKeyType)
+	c0 := NewClaimAuthEthKey(ethKey, ethKeyType, revocationNonce)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	// Check claim against test vector
+	checkClaim(e, t)
+	dataTestOutput(&e.Data)
 
-	c1 := NewClaimAuthEthKeyFromEntry(c0.Entry())
-	c2, err := NewClaimFromEntry(c0.Entry())
+	c1 := NewClaimAuthEthKeyFromEntry(e)
+	c2, err := NewClaimFromEntry(e)
 	assert.Nil(t, err)
 	assert.Equal(t, c0, c1)
 	assert.Equal(t, c0, c2)
 
-	assert.Equal(t, c0.EthKey, ethKey)
-	assert.Equal(t, c0.EthKeyType, binary.BigEndian.Uint32(ethKeyType[:]))
-	assert.Equal(t, c0.EthKey, c1.EthKey)
-	assert.Equal(t, c0.EthKeyType, c1.EthKeyType)
-	assert.Equal(t, c0.Type(), c1.Type())
-	assert.Equal(t, c0.Type(), *ClaimTypeAuthEthKey)
+	assert.Equal(t, ethKey, c1.EthKey)
+	assert.Equal(t, binary.BigEndian.Uint32(ethKeyType[:]), c1.EthKeyType)
+	assert.Equal(t, *ClaimTypeAuthEthKey, c1.Type())
 
-	assert.Equal(t, c0.Entry().Bytes(), c1.Entry().Bytes())
-	assert.Equal(t, c0.Entry().Bytes(), c2.Entry().Bytes())
+	// The same key with a different type is a different claim
+	c3 := NewClaimAuthEthKey(ethKey, EthKeyTypeAuthenticate, revocationNonce)
+	c3.Version = 1
+	assert.NotEqual(t, e.HIndex(), c3.Entry().HIndex())
+
+	// The revocation nonce is not part of the index
+	c4 := NewClaimAuthEthKey(ethKey, ethKeyType, 5678)
+	c4.Version = 1
+	assert.Equal(t, e.HIndex(), c4.Entry().HIndex())
 
-	e := c0.Entry()
-	// Check claim against test vector
-	checkClaim(e, t)
-	dataTestOutput(&e.Data)
 	// Stop test (write new test vector if needed)
 	if err := testgen.StopTest(); err != nil {
 		panic(fmt.Errorf("Error stopping test: %w", err))
 	}
 }
-*/
@@ -0,0 +1,58 @@
+package claims
+
+import (
+	"encoding/binary"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// ClaimAuthorizeKEncX25519 is a claim to authorize an X25519 public key for
+// encrypting the messages sent to the identity.
+type ClaimAuthorizeKEncX25519 struct {
+	// Version is the claim version.
+	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
+	// PubKey is the X25519 public key.
+	PubKey [32]byte
+}
+
+// NewClaimAuthorizeKEncX25519 returns a ClaimAuthorizeKEncX25519 that
+// authorizes the X25519 public key pk.
+func NewClaimAuthorizeKEncX25519(pk *[32]byte, revocationNonce uint32) *ClaimAuthorizeKEncX25519 {
+	return &ClaimAuthorizeKEncX25519{
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		PubKey:          *pk,
+	}
+}
+
+// NewClaimAuthorizeKEncX25519FromEntry deserializes a
+// ClaimAuthorizeKEncX25519 from an Entry.
+func NewClaimAuthorizeKEncX25519FromEntry(e *merkletree.Entry) *ClaimAuthorizeKEncX25519 {
+	c := &ClaimAuthorizeKEncX25519{}
+	_, c.Version = GetClaimTypeVersion(e)
+	copy(c.PubKey[:16], e.Data[1][:16])
+	copy(c.PubKey[16:], e.Data[2][:16])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	return c
+}
+
+// Entry serializes the claim into an Entry.  The key is split in two halves
+// so that the elements stay inside the Finite Field.
+func (c *ClaimAuthorizeKEncX25519) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	index := e.Index()
+	SetClaimTypeVersion(e, c.Type(), c.Version)
+	copy(index[1][:16], c.PubKey[:16])
+	copy(index[2][:16], c.PubKey[16:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+
+	return e
+}
+
+// Type returns the ClaimType of the claim.
+func (c *ClaimAuthorizeKEncX25519) Type() ClaimType {
+	return *ClaimTypeAuthorizeKEncX25519
+}
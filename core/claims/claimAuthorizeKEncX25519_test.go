@@ -0,0 +1,29 @@
+package claims
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimAuthorizeKEncX25519(t *testing.T) {
+	var pk [32]byte
+	for i := range pk {
+		pk[i] = 0xff - byte(i)
+	}
+	c0 := NewClaimAuthorizeKEncX25519(&pk, 1234)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	c1 := NewClaimAuthorizeKEncX25519FromEntry(e)
+	c2, err := NewClaimFromEntry(e)
+	assert.Nil(t, err)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+
+	// The revocation nonce is not part of the index
+	c3 := NewClaimAuthorizeKEncX25519(&pk, 5678)
+	c3.Version = 1
+	assert.Equal(t, e.HIndex(), c3.Entry().HIndex())
+}
@@ -27,7 +27,9 @@ type ClaimBasic struct {
 	DataSlot [DataSlotBytes]byte
 }
 
-// NewClaimBasic returns a ClaimBasic with the provided data.
+// NewClaimBasic returns a ClaimBasic with the provided data.  Use
+// IndexSlotFromSlots and DataSlotFromSlots to build the slots from encoded
+// values.
 func NewClaimBasic(indexSlot [IndexSlotBytes]byte, dataSlot [DataSlotBytes]byte, revocationNonce uint32) *ClaimBasic {
 	// TODO: at this moment, revocation nonce is not defined, neither other
 	// claim options.  So, for now, the ClaimBasic just holds two static
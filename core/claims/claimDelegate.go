@@ -0,0 +1,73 @@
+package claims
+
+import (
+	"encoding/binary"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// DelegateScope defines the purpose for which an identity is authorized to
+// act on behalf of another identity.
+type DelegateScope uint32
+
+const (
+	// DelegateScopeIssueClaims authorizes the delegate to issue claims on
+	// behalf of the identity.
+	DelegateScopeIssueClaims DelegateScope = 0
+	// DelegateScopeAuthenticate authorizes the delegate to authenticate on
+	// behalf of the identity.
+	DelegateScopeAuthenticate DelegateScope = 1
+)
+
+// ClaimDelegate is a claim to authorize another identity to act on behalf of
+// the identity that issues the claim for a scoped purpose.
+type ClaimDelegate struct {
+	// Version is the claim version.
+	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
+	// Scope is the purpose for which the delegate is authorized.
+	Scope DelegateScope
+	// Id is the ID of the delegate.
+	Id core.ID
+}
+
+// NewClaimDelegate returns a ClaimDelegate that authorizes the identity id
+// for the given scope.
+func NewClaimDelegate(id *core.ID, scope DelegateScope, revocationNonce uint32) *ClaimDelegate {
+	return &ClaimDelegate{
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		Scope:           scope,
+		Id:              *id,
+	}
+}
+
+// NewClaimDelegateFromEntry deserializes a ClaimDelegate from an Entry.
+func NewClaimDelegateFromEntry(e *merkletree.Entry) *ClaimDelegate {
+	c := &ClaimDelegate{}
+	_, c.Version = GetClaimTypeVersion(e)
+	c.Scope = DelegateScope(binary.BigEndian.Uint32(e.Data[1][:4]))
+	copy(c.Id[:], e.Data[2][:])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	return c
+}
+
+// Entry serializes the claim into an Entry.
+func (c *ClaimDelegate) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	index := e.Index()
+	SetClaimTypeVersion(e, c.Type(), c.Version)
+	binary.BigEndian.PutUint32(index[1][:4], uint32(c.Scope))
+	copy(index[2][:], c.Id[:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+
+	return e
+}
+
+// Type returns the ClaimType of the claim.
+func (c *ClaimDelegate) Type() ClaimType {
+	return *ClaimTypeDelegate
+}
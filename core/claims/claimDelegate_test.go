@@ -0,0 +1,34 @@
+package claims
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimDelegate(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	c0 := NewClaimDelegate(&id, DelegateScopeAuthenticate, 1234)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	c1 := NewClaimDelegateFromEntry(e)
+	c2, err := NewClaimFromEntry(e)
+	assert.Nil(t, err)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+
+	// The same delegate with a different scope is a different claim
+	c3 := NewClaimDelegate(&id, DelegateScopeIssueClaims, 1234)
+	c3.Version = 1
+	assert.NotEqual(t, e.HIndex(), c3.Entry().HIndex())
+
+	// The revocation nonce is not part of the index
+	c4 := NewClaimDelegate(&id, DelegateScopeAuthenticate, 5678)
+	c4.Version = 1
+	assert.Equal(t, e.HIndex(), c4.Entry().HIndex())
+}
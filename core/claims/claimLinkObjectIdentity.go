@@ -40,12 +40,17 @@ const (
 	ObjectTypeCertificate ObjectType = 6
 	// ObjectTypeStorage indicates that hash represents a stored file.
 	ObjectTypeStorage ObjectType = 7
+	// ObjectTypeDocument indicates that hash represents a notarized
+	// document.
+	ObjectTypeDocument ObjectType = 8
 )
 
 // ClaimLinkObjectIdentity aims to link a hash of an object to an identity.
 type ClaimLinkObjectIdentity struct {
 	// Version is the claim version.
 	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
 	// ObjectType is the representation of the objectHash.
 	ObjectType ObjectType
 	// ObjectIndex is the index of this object which the identity has.
@@ -60,7 +65,7 @@ type ClaimLinkObjectIdentity struct {
 
 // NewClaimLinkObjectIdentity returns a ClaimLinkObjectIdentity.
 func NewClaimLinkObjectIdentity(objectType ObjectType, objectIndex uint16, id core.ID,
-	objectHash [256 / 8]byte, auxData [256 / 8]byte) (*ClaimLinkObjectIdentity, error) {
+	objectHash [256 / 8]byte, auxData [256 / 8]byte, revocationNonce uint32) (*ClaimLinkObjectIdentity, error) {
 	if ok := cryptoUtils.CheckBigIntArrayInField(merkletree.ElemBytesToBigInts(objectHash), cryptoConstants.Q); !ok {
 		return nil, errors.New("objectHash not in the Finite Field over R")
 	}
@@ -68,60 +73,50 @@ func NewClaimLinkObjectIdentity(objectType ObjectType, objectIndex uint16, id co
 		return nil, errors.New("auxData not in the Finite Field over R")
 	}
 	return &ClaimLinkObjectIdentity{
-		Version:     0,
-		ObjectType:  objectType,
-		ObjectIndex: objectIndex,
-		Id:          id,
-		ObjectHash:  objectHash,
-		AuxData:     auxData,
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		ObjectType:      objectType,
+		ObjectIndex:     objectIndex,
+		Id:              id,
+		ObjectHash:      objectHash,
+		AuxData:         auxData,
 	}, nil
 }
 
 // NewClaimLinkObjectIdentityFromEntry deserializes a ClaimLinkObjectIdentity from an Entry.
-func NewClaimLinkObjectIdentityFromEntry(entry *merkletree.Entry) *ClaimLinkObjectIdentity {
-	claim := &ClaimLinkObjectIdentity{}
-	_, claim.Version = GetClaimTypeVersion(entry)
-	var objectType [32 / 8]byte
-	var objectIndex [16 / 8]byte
-	var indexLen = ClaimTypeVersionLen
-	// object type
-	copyFromElemBytes(objectType[:], indexLen, &entry.Data[3])
-	claim.ObjectType = ObjectType(binary.BigEndian.Uint32(objectType[:]))
-	// object index
-	indexLen += len(objectType)
-	copyFromElemBytes(objectIndex[:], indexLen, &entry.Data[3])
-	claim.ObjectIndex = binary.BigEndian.Uint16(objectIndex[:])
-	// identity address
-	copyFromElemBytes(claim.Id[:], 0, &entry.Data[2])
-	// hash object
-	copyFromElemBytes(claim.ObjectHash[:], 0, &entry.Data[1])
-	// hash type
-	copyFromElemBytes(claim.AuxData[:], 0, &entry.Data[0])
-	return claim
+func NewClaimLinkObjectIdentityFromEntry(e *merkletree.Entry) *ClaimLinkObjectIdentity {
+	c := &ClaimLinkObjectIdentity{}
+	_, c.Version = GetClaimTypeVersion(e)
+	// object type and object index after the type and version
+	c.ObjectType = ObjectType(binary.BigEndian.Uint32(e.Data[0][ClaimTypeVersionLen : ClaimTypeVersionLen+32/8]))
+	c.ObjectIndex = binary.BigEndian.Uint16(e.Data[0][ClaimTypeVersionLen+32/8 : ClaimTypeVersionLen+48/8])
+	// identity
+	copy(c.Id[:], e.Data[1][:])
+	// object hash
+	copy(c.ObjectHash[:], e.Data[2][:])
+
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	// aux data
+	copy(c.AuxData[:], e.Data[5][:])
+	return c
 }
 
 // Entry serializes the claim into an Entry.
-func (claim *ClaimLinkObjectIdentity) Entry() *merkletree.Entry {
-	entry := &merkletree.Entry{}
-	var indexLen = ClaimTypeVersionLen
-	// type and version
-	SetClaimTypeVersion(entry, claim.Type(), claim.Version)
-	// object type
-	var objectType [32 / 8]byte
-	binary.BigEndian.PutUint32(objectType[:], uint32(claim.ObjectType))
-	copyToElemBytes(&entry.Data[3], indexLen, objectType[:])
-	// object index
-	indexLen += len(objectType)
-	var objectIndex [16 / 8]byte
-	binary.BigEndian.PutUint16(objectIndex[:], claim.ObjectIndex)
-	copyToElemBytes(&entry.Data[3], indexLen, objectIndex[:])
-	// identity address
-	copyToElemBytes(&entry.Data[2], 0, claim.Id[:])
+func (c *ClaimLinkObjectIdentity) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	SetClaimTypeVersion(e, c.Type(), c.Version)
+	// object type and object index after the type and version
+	binary.BigEndian.PutUint32(e.Data[0][ClaimTypeVersionLen:ClaimTypeVersionLen+32/8], uint32(c.ObjectType))
+	binary.BigEndian.PutUint16(e.Data[0][ClaimTypeVersionLen+32/8:ClaimTypeVersionLen+48/8], c.ObjectIndex)
+	// identity
+	copy(e.Data[1][:], c.Id[:])
 	// object hash
-	copyToElemBytes(&entry.Data[1], 0, claim.ObjectHash[:])
+	copy(e.Data[2][:], c.ObjectHash[:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
 	// aux data
-	copyToElemBytes(&entry.Data[0], 0, claim.AuxData[:])
-	return entry
+	copy(e.Data[5][:], c.AuxData[:])
+	return e
 }
 
 // Type returns the ClaimType of the claim.
@@ -1,11 +1,23 @@
 package claims
 
-/*
-// TMP commented until ClaimLinkObjectIdentity is updated to new spec
+import (
+	"encoding/hex"
+	"fmt"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/testgen"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
 func TestClaimLinkObjectIdentity(t *testing.T) {
 	// If generateTest is true, the checked values will be used to generate a test vector
 	generateTest := false
-	// Init test
+
+	// Init test, restoring the claim test vector afterwards
+	defer testgen.RestoreState(testgen.SaveState())
 	if err := testgen.InitTest("claimLinkObjectIdentity", generateTest); err != nil {
 		panic(fmt.Errorf("error initializing test data: %w", err))
 	}
@@ -16,40 +28,54 @@ func TestClaimLinkObjectIdentity(t *testing.T) {
 			0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b,
 			0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b,
 			0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b,
-			0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0c}))
+			0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x0b, 0x00}))
 		testgen.SetTestValue("auxData", hex.EncodeToString([]byte{
-			0x0f, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+			0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
 			0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
-			0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x09,
-			0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x01, 0x02}))
+			0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+			0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x00}))
+		testgen.SetTestValue("revocationNonce", float64(1234))
 	}
-	// ClaimLinkObjectIdentity
-	const objectType = ObjectTypeAddress
-	var indexType uint16
 	id, err := core.IDFromString(testgen.GetTestValue("idString").(string))
-	assert.Nil(t, err)
-	var objectHash [256 / 8]byte
-	var auxData [256 / 8]byte
-	objectHashHex, _ := hex.DecodeString(testgen.GetTestValue("objectHash").(string))
-	auxDataHex, _ := hex.DecodeString(testgen.GetTestValue("auxData").(string))
-	copy(objectHash[:], objectHashHex[:256/8])
-	copy(auxData[:], auxDataHex[:256/8])
-
-	claim, err := NewClaimLinkObjectIdentity(objectType, indexType, id, objectHash, auxData)
-	assert.Nil(t, err)
-	claim.Version = 1
-	e := claim.Entry()
+	require.Nil(t, err)
+	var objectHash, auxData [256 / 8]byte
+	objectHashHex, err := hex.DecodeString(testgen.GetTestValue("objectHash").(string))
+	require.Nil(t, err)
+	auxDataHex, err := hex.DecodeString(testgen.GetTestValue("auxData").(string))
+	require.Nil(t, err)
+	copy(objectHash[:], objectHashHex)
+	copy(auxData[:], auxDataHex)
+	revocationNonce := uint32(testgen.GetTestValue("revocationNonce").(float64))
+
+	c0, err := NewClaimLinkObjectIdentity(ObjectTypeDocument, 3, id, objectHash, auxData, revocationNonce)
+	require.Nil(t, err)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
 	// Check claim against test vector
 	checkClaim(e, t)
 	dataTestOutput(&e.Data)
 	c1 := NewClaimLinkObjectIdentityFromEntry(e)
 	c2, err := NewClaimFromEntry(e)
 	assert.Nil(t, err)
-	assert.Equal(t, claim, c1)
-	assert.Equal(t, claim, c2)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+
+	// The revocation nonce and the aux data are not part of the index
+	auxData[0] = 0xff
+	c3, err := NewClaimLinkObjectIdentity(ObjectTypeDocument, 3, id, objectHash, auxData, 5678)
+	require.Nil(t, err)
+	c3.Version = 1
+	assert.Equal(t, e.HIndex(), c3.Entry().HIndex())
+	assert.NotEqual(t, e.HValue(), c3.Entry().HValue())
+
+	// Hashes not in the field are rejected
+	objectHash[31] = 0xff
+	_, err = NewClaimLinkObjectIdentity(ObjectTypeDocument, 3, id, objectHash, auxData, 0)
+	assert.NotNil(t, err)
+
 	// Stop test (write new test vector if needed)
 	if err := testgen.StopTest(); err != nil {
 		panic(fmt.Errorf("Error stopping test: %w", err))
 	}
 }
-*/
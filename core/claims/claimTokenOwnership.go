@@ -0,0 +1,117 @@
+package claims
+
+import (
+	"encoding/binary"
+	"errors"
+	"math/big"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// TokenStandard is the standard of the token contract of a
+// ClaimTokenOwnership.
+type TokenStandard uint8
+
+const (
+	// TokenStandardERC20 is a fungible token contract.  The claim attests
+	// a minimum balance.
+	TokenStandardERC20 TokenStandard = 0
+	// TokenStandardERC721 is a non-fungible token contract.  The claim
+	// attests the ownership of a token id.
+	TokenStandardERC721 TokenStandard = 1
+)
+
+// TokenBlockHashLen is the number of bytes of the block hash stored in a
+// ClaimTokenOwnership.
+const TokenBlockHashLen = 31
+
+// ErrTokenAmountTooBig is used when the token id or balance of a
+// ClaimTokenOwnership doesn't fit in a claim element.
+var ErrTokenAmountTooBig = errors.New("token id or balance doesn't fit in 248 bits")
+
+// ClaimTokenOwnership is a claim that attests that the identity Id owns, by
+// means of the ethereum address Owner, a token of the contract Contract at
+// the block BlockN.  For ERC-721 contracts Amount is the owned token id, and
+// for ERC-20 contracts Amount is a lower bound (bucket) of the balance.
+type ClaimTokenOwnership struct {
+	// Version is the claim version.
+	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
+	// Standard is the standard of the token contract.
+	Standard TokenStandard
+	// Contract is the address of the token contract.
+	Contract common.Address
+	// BlockN is the block number at which the ownership was checked.
+	BlockN uint64
+	// Amount is the token id (ERC-721) or minimum balance (ERC-20).
+	Amount *big.Int
+	// Id is the identity that owns the token.
+	Id core.ID
+	// BlockHash is the first TokenBlockHashLen bytes of the hash of the
+	// block BlockN, to anchor the claim to a chain.
+	BlockHash [TokenBlockHashLen]byte
+	// Owner is the ethereum address that holds the token.
+	Owner common.Address
+}
+
+// NewClaimTokenOwnership returns a ClaimTokenOwnership.
+func NewClaimTokenOwnership(id *core.ID, standard TokenStandard, contract common.Address, amount *big.Int,
+	owner common.Address, blockN uint64, blockHash common.Hash, revocationNonce uint32) (*ClaimTokenOwnership, error) {
+	if amount.Sign() < 0 || amount.BitLen() > 8*(merkletree.ElemBytesLen-1) {
+		return nil, ErrTokenAmountTooBig
+	}
+	c := &ClaimTokenOwnership{
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		Standard:        standard,
+		Contract:        contract,
+		BlockN:          blockN,
+		Amount:          new(big.Int).Set(amount),
+		Id:              *id,
+		Owner:           owner,
+	}
+	copy(c.BlockHash[:], blockHash[:TokenBlockHashLen])
+	return c, nil
+}
+
+// NewClaimTokenOwnershipFromEntry deserializes a ClaimTokenOwnership from an
+// Entry.
+func NewClaimTokenOwnershipFromEntry(e *merkletree.Entry) *ClaimTokenOwnership {
+	c := &ClaimTokenOwnership{}
+	_, c.Version = GetClaimTypeVersion(e)
+	copy(c.Contract[:], e.Data[1][:common.AddressLength])
+	c.Standard = TokenStandard(e.Data[1][common.AddressLength])
+	c.BlockN = binary.BigEndian.Uint64(e.Data[1][common.AddressLength+1 : common.AddressLength+9])
+	c.Amount = merkletree.ElemBytesToBigInt(e.Data[2])
+	copy(c.Id[:], e.Data[3][:])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	copy(c.BlockHash[:], e.Data[5][:TokenBlockHashLen])
+	copy(c.Owner[:], e.Data[6][:common.AddressLength])
+	return c
+}
+
+// Entry serializes the claim into an Entry.
+func (c *ClaimTokenOwnership) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	index := e.Index()
+	SetClaimTypeVersion(e, c.Type(), c.Version)
+	copy(index[1][:common.AddressLength], c.Contract[:])
+	index[1][common.AddressLength] = byte(c.Standard)
+	binary.BigEndian.PutUint64(index[1][common.AddressLength+1:common.AddressLength+9], c.BlockN)
+	index[2] = merkletree.ElemBytes(merkletree.BigIntToHash(c.Amount))
+	copy(index[3][:], c.Id[:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+	copy(e.Data[5][:TokenBlockHashLen], c.BlockHash[:])
+	copy(e.Data[6][:common.AddressLength], c.Owner[:])
+
+	return e
+}
+
+// Type returns the ClaimType of the claim.
+func (c *ClaimTokenOwnership) Type() ClaimType {
+	return *ClaimTypeTokenOwnership
+}
@@ -0,0 +1,52 @@
+package claims
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimTokenOwnership(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	contract := common.HexToAddress("0xe0fbce58cfaa72812103f003adce3f284fe5fc7c")
+	owner := common.HexToAddress("0x107618bD90C32Aec120e118e8B0Fcb1Db2537ef8")
+	blockHash := common.HexToHash("0xffeeddccbbaa99887766554433221100ffeeddccbbaa99887766554433221100")
+	tokenId, ok := new(big.Int).SetString("123456789012345678901234567890", 10)
+	require.True(t, ok)
+
+	c0, err := NewClaimTokenOwnership(&id, TokenStandardERC721, contract, tokenId, owner, 1234567, blockHash, 42)
+	require.Nil(t, err)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	c1 := NewClaimTokenOwnershipFromEntry(e)
+	c2, err := NewClaimFromEntry(e)
+	assert.Nil(t, err)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+	assert.Equal(t, blockHash[:TokenBlockHashLen], c1.BlockHash[:])
+
+	// The same token attested at another block is a different claim
+	c3, err := NewClaimTokenOwnership(&id, TokenStandardERC721, contract, tokenId, owner, 1234568, blockHash, 42)
+	require.Nil(t, err)
+	c3.Version = 1
+	assert.NotEqual(t, e.HIndex(), c3.Entry().HIndex())
+
+	// Amounts that don't fit in a claim element are rejected
+	tooBig := new(big.Int).Lsh(big.NewInt(1), 248)
+	_, err = NewClaimTokenOwnership(&id, TokenStandardERC721, contract, tooBig, owner, 1, blockHash, 0)
+	assert.Equal(t, ErrTokenAmountTooBig, err)
+	_, err = NewClaimTokenOwnership(&id, TokenStandardERC20, contract, big.NewInt(-1), owner, 1, blockHash, 0)
+	assert.Equal(t, ErrTokenAmountTooBig, err)
+	maxAmount := new(big.Int).Sub(tooBig, big.NewInt(1))
+	c4, err := NewClaimTokenOwnership(&id, TokenStandardERC20, contract, maxAmount, owner, 1, blockHash, 0)
+	require.Nil(t, err)
+	assert.True(t, merkletree.CheckEntryInField(*c4.Entry()))
+	assert.Equal(t, maxAmount, NewClaimTokenOwnershipFromEntry(c4.Entry()).Amount)
+}
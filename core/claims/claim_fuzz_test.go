@@ -0,0 +1,39 @@
+//go:build go1.18
+// +build go1.18
+
+package claims
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzNewClaimFromEntry(f *testing.F) {
+	claimTypes := []*ClaimType{ClaimTypeBasic, ClaimTypeAuthorizeKSignBabyJub, ClaimTypeSetRootKey,
+		ClaimTypeAssignName, ClaimTypeAuthorizeKSignSecp256k1, ClaimTypeLinkObjectIdentity,
+		ClaimTypeAuthorizeService, ClaimTypeEthId, ClaimTypeAuthEthKey, ClaimTypeDelegate,
+		ClaimTypeTokenOwnership, ClaimTypeAuthorizeKEncX25519}
+	for i, claimType := range claimTypes {
+		e := merkletree.Entry{}
+		SetClaimTypeVersion(&e, *claimType, uint32(i))
+		f.Add(e.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		var b [merkletree.ElemBytesLen * merkletree.DataLen]byte
+		copy(b[:], bs)
+		e := merkletree.Entry{Data: *merkletree.NewDataFromBytes(b)}
+		claim, err := NewClaimFromEntry(&e)
+		if err != nil {
+			return
+		}
+		claimType, _ := GetClaimTypeVersion(&e)
+		claimType2, _ := GetClaimTypeVersion(claim.Entry())
+		require.Equal(t, claimType, claimType2)
+		claim2, err := NewClaimFromEntry(claim.Entry())
+		require.Nil(t, err)
+		assert.Equal(t, claim.Entry(), claim2.Entry())
+	})
+}
@@ -81,6 +81,13 @@ func TestMain(m *testing.M) {
 	os.Exit(result)
 }
 
+// checkClaim checks the entry of a claim against the current test vector.
+func checkClaim(e *merkletree.Entry, t *testing.T) {
+	testgen.CheckTestValue(t, "HIndex", e.HIndex().Hex())
+	testgen.CheckTestValue(t, "HValue", e.HValue().Hex())
+	testgen.CheckTestValue(t, "dataString", e.Data.String())
+}
+
 func dataTestOutput(d *merkletree.Data) {
 	if !debug {
 		return
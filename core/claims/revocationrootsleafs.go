@@ -2,10 +2,31 @@ package claims
 
 import (
 	"encoding/binary"
+	"errors"
 
 	"github.com/iden3/go-iden3-core/merkletree"
 )
 
+// RevocationVersionAll is the version of a LeafRevocationsTree that revokes
+// all the versions of a claim.
+const RevocationVersionAll = uint32(0xffffffff)
+
+// ErrInvalidLeaf is used when an Entry doesn't have the layout of the leaf
+// it's parsed as.
+var ErrInvalidLeaf = errors.New("the entry is not a valid leaf")
+
+// checkZero returns ErrInvalidLeaf if any of the bytes in bs is not zero.
+func checkZero(bs ...[]byte) error {
+	for _, b := range bs {
+		for _, v := range b {
+			if v != 0 {
+				return ErrInvalidLeaf
+			}
+		}
+	}
+	return nil
+}
+
 // LeafRootsTree contains the root to be inserted in the leaf
 type LeafRootsTree struct {
 	Root merkletree.Hash
@@ -25,6 +46,18 @@ func NewLeafRootsTreeFromEntry(e *merkletree.Entry) *LeafRootsTree {
 	return l
 }
 
+// FromEntry deserializes the leaf from an Entry, checking that the Entry
+// contains only a root.
+func (l *LeafRootsTree) FromEntry(e *merkletree.Entry) error {
+	for _, elem := range e.Data[1:] {
+		if err := checkZero(elem[:]); err != nil {
+			return err
+		}
+	}
+	l.Root = merkletree.Hash(e.Data[0])
+	return nil
+}
+
 // Entry serializes the leaf into an Entry.
 func (l *LeafRootsTree) Entry() *merkletree.Entry {
 	e := &merkletree.Entry{}
@@ -32,6 +65,18 @@ func (l *LeafRootsTree) Entry() *merkletree.Entry {
 	return e
 }
 
+// HIndex returns the hash of the index of the leaf, used to generate the
+// proofs of the leaf in the roots tree.
+func (l *LeafRootsTree) HIndex() *merkletree.Hash {
+	return l.Entry().HIndex()
+}
+
+// HIndexLeafRootsTree returns the hash of the index of the leaf of the roots
+// tree that contains root.
+func HIndexLeafRootsTree(root *merkletree.Hash) *merkletree.Hash {
+	return NewLeafRootsTree(*root).HIndex()
+}
+
 // LeafRevocationsTree contains the root to be inserted in the leaf
 type LeafRevocationsTree struct {
 	Nonce   uint32
@@ -54,6 +99,23 @@ func NewLeafRevocationsTreeFromEntry(e *merkletree.Entry) *LeafRevocationsTree {
 	return l
 }
 
+// FromEntry deserializes the leaf from an Entry, checking that the Entry
+// contains only a nonce and a version.
+func (l *LeafRevocationsTree) FromEntry(e *merkletree.Entry) error {
+	for i, elem := range e.Data {
+		b := elem[:]
+		if i == 0 || i == 4 {
+			b = b[4:]
+		}
+		if err := checkZero(b); err != nil {
+			return err
+		}
+	}
+	l.Nonce = binary.BigEndian.Uint32(e.Data[0][:4])
+	l.Version = binary.BigEndian.Uint32(e.Data[4][:4])
+	return nil
+}
+
 // Entry serializes the leaf into an Entry.
 func (l *LeafRevocationsTree) Entry() *merkletree.Entry {
 	e := &merkletree.Entry{}
@@ -62,6 +124,18 @@ func (l *LeafRevocationsTree) Entry() *merkletree.Entry {
 	return e
 }
 
+// HIndex returns the hash of the index of the leaf, used to generate the
+// proofs of the leaf in the revocations tree.
+func (l *LeafRevocationsTree) HIndex() *merkletree.Hash {
+	return l.Entry().HIndex()
+}
+
+// HIndexLeafRevocationsTree returns the hash of the index of the leaf of the
+// revocations tree that contains nonce and version.
+func HIndexLeafRevocationsTree(nonce, version uint32) *merkletree.Hash {
+	return NewLeafRevocationsTree(nonce, version).HIndex()
+}
+
 // AddLeafRootsTree adds a new leaf to the given MerkleTree, which contains the Root
 func AddLeafRootsTree(mt *merkletree.MerkleTree, root *merkletree.Hash) error {
 	l := NewLeafRootsTree(*root)
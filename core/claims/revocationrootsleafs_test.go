@@ -75,3 +75,25 @@ func TestAddLeafRevocationsTree(t *testing.T) {
 	assert.Nil(t, err)
 	testgen.CheckTestValue(t, "proofRevocationsTree", hex.EncodeToString(proof.Bytes()))
 }
+
+func TestLeafFromEntry(t *testing.T) {
+	root := merkletree.HexStringToHash(testgen.GetTestValue("root0").(string))
+	lRoot0 := NewLeafRootsTree(root)
+	var lRoot1 LeafRootsTree
+	assert.Nil(t, lRoot1.FromEntry(lRoot0.Entry()))
+	assert.Equal(t, *lRoot0, lRoot1)
+	assert.Equal(t, lRoot0.Entry().HIndex(), HIndexLeafRootsTree(&root))
+
+	lRev0 := NewLeafRevocationsTree(42, RevocationVersionAll)
+	var lRev1 LeafRevocationsTree
+	assert.Nil(t, lRev1.FromEntry(lRev0.Entry()))
+	assert.Equal(t, *lRev0, lRev1)
+	assert.Equal(t, lRev0.Entry().HIndex(), HIndexLeafRevocationsTree(42, RevocationVersionAll))
+
+	// A revocations tree leaf is not a valid roots tree leaf and vice versa
+	assert.Equal(t, ErrInvalidLeaf, lRoot1.FromEntry(lRev0.Entry()))
+	assert.Equal(t, ErrInvalidLeaf, lRev1.FromEntry(lRoot0.Entry()))
+	e := lRev0.Entry()
+	e.Data[0][4] = 1
+	assert.Equal(t, ErrInvalidLeaf, lRev1.FromEntry(e))
+}
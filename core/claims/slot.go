@@ -0,0 +1,165 @@
+package claims
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"time"
+	"unicode/utf8"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// SlotLen is the length in bytes of a Slot: the bytes of a claim element that
+// can hold any value while staying inside the Finite Field (248 bits).
+const SlotLen = 248 / 8
+
+var (
+	// ErrSlotOverflow is used when a value doesn't fit in a Slot.
+	ErrSlotOverflow = errors.New("value doesn't fit in a slot of 248 bits")
+	// ErrSlotInvalidString is used when a string can't be stored in a Slot
+	// (it's not valid UTF-8 or it contains NUL bytes).
+	ErrSlotInvalidString = errors.New("string is not valid UTF-8 without NUL bytes")
+	// ErrSlotTooMany is used when more Slots than the available in the
+	// IndexSlot or DataSlot of a ClaimBasic are given.
+	ErrSlotTooMany = errors.New("too many slots")
+)
+
+// Slot is a value encoded to be stored in a claim element.  The encoding is
+// little-endian, as the merkletree.ElemBytes, so a Slot stored in an element
+// is the value of the element in the Finite Field.
+type Slot [SlotLen]byte
+
+// SlotFromString encodes s into a Slot.  The UTF-8 bytes of s are stored
+// padded with zeroes, so s must be at most SlotLen bytes long and can't
+// contain NUL bytes.
+func SlotFromString(s string) (Slot, error) {
+	var slot Slot
+	if len(s) > SlotLen {
+		return slot, ErrSlotOverflow
+	}
+	if !utf8.ValidString(s) || bytes.IndexByte([]byte(s), 0) != -1 {
+		return slot, ErrSlotInvalidString
+	}
+	copy(slot[:], s)
+	return slot, nil
+}
+
+// StringFromSlot decodes the string encoded in slot with SlotFromString.
+func StringFromSlot(slot Slot) (string, error) {
+	n := bytes.IndexByte(slot[:], 0)
+	if n == -1 {
+		n = SlotLen
+	}
+	if !utf8.Valid(slot[:n]) {
+		return "", ErrSlotInvalidString
+	}
+	for _, b := range slot[n:] {
+		if b != 0 {
+			return "", ErrSlotInvalidString
+		}
+	}
+	return string(slot[:n]), nil
+}
+
+// SlotFromBigInt encodes n into a Slot in little-endian.  n must be
+// non-negative and fit in 248 bits.
+func SlotFromBigInt(n *big.Int) (Slot, error) {
+	var slot Slot
+	if n.Sign() < 0 || n.BitLen() > 8*SlotLen {
+		return slot, ErrSlotOverflow
+	}
+	copy(slot[:], merkletree.SwapEndianness(n.Bytes()))
+	return slot, nil
+}
+
+// BigIntFromSlot decodes the big.Int encoded in slot with SlotFromBigInt.
+func BigIntFromSlot(slot Slot) *big.Int {
+	return new(big.Int).SetBytes(merkletree.SwapEndianness(slot[:]))
+}
+
+// SlotFromTime encodes the unix time in seconds of t into a Slot.  t can't
+// be before the unix epoch.  The sub-second part and the location of t are
+// discarded.
+func SlotFromTime(t time.Time) (Slot, error) {
+	if t.Unix() < 0 {
+		return Slot{}, ErrSlotOverflow
+	}
+	return SlotFromBigInt(new(big.Int).SetInt64(t.Unix()))
+}
+
+// TimeFromSlot decodes the time encoded in slot with SlotFromTime, in UTC.
+func TimeFromSlot(slot Slot) (time.Time, error) {
+	n := BigIntFromSlot(slot)
+	if !n.IsInt64() {
+		return time.Time{}, ErrSlotOverflow
+	}
+	return time.Unix(n.Int64(), 0).UTC(), nil
+}
+
+// ElemBytes returns the claim element that holds the slot.
+func (s Slot) ElemBytes() merkletree.ElemBytes {
+	var e merkletree.ElemBytes
+	copy(e[:SlotLen], s[:])
+	return e
+}
+
+// SlotFromElemBytes returns the Slot held in the claim element e.
+func SlotFromElemBytes(e merkletree.ElemBytes) Slot {
+	var s Slot
+	copy(s[:], e[:SlotLen])
+	return s
+}
+
+// Offsets of the Slots that fill the last elements of the IndexSlot and
+// DataSlot of a ClaimBasic (see ClaimBasic.Entry).
+var (
+	indexSlotOffsets = []int{56 / 8, 304 / 8, 552 / 8}
+	dataSlotOffsets  = []int{216 / 8, 464 / 8, 712 / 8}
+)
+
+// IndexSlotFromSlots returns an IndexSlot for a ClaimBasic with up to 3
+// slots, each one stored in its own index element.
+func IndexSlotFromSlots(slots ...Slot) ([IndexSlotBytes]byte, error) {
+	var indexSlot [IndexSlotBytes]byte
+	if len(slots) > len(indexSlotOffsets) {
+		return indexSlot, ErrSlotTooMany
+	}
+	for i, slot := range slots {
+		copy(indexSlot[indexSlotOffsets[i]:], slot[:])
+	}
+	return indexSlot, nil
+}
+
+// SlotsFromIndexSlot returns the 3 slots of an IndexSlot built with
+// IndexSlotFromSlots.
+func SlotsFromIndexSlot(indexSlot [IndexSlotBytes]byte) [3]Slot {
+	var slots [3]Slot
+	for i, offset := range indexSlotOffsets {
+		copy(slots[i][:], indexSlot[offset:])
+	}
+	return slots
+}
+
+// DataSlotFromSlots returns a DataSlot for a ClaimBasic with up to 3 slots,
+// each one stored in its own data element.
+func DataSlotFromSlots(slots ...Slot) ([DataSlotBytes]byte, error) {
+	var dataSlot [DataSlotBytes]byte
+	if len(slots) > len(dataSlotOffsets) {
+		return dataSlot, ErrSlotTooMany
+	}
+	for i, slot := range slots {
+		copy(dataSlot[dataSlotOffsets[i]:], slot[:])
+	}
+	return dataSlot, nil
+}
+
+// SlotsFromDataSlot returns the 3 slots of a DataSlot built with
+// DataSlotFromSlots.
+func SlotsFromDataSlot(dataSlot [DataSlotBytes]byte) [3]Slot {
+	var slots [3]Slot
+	for i, offset := range dataSlotOffsets {
+		copy(slots[i][:], dataSlot[offset:])
+	}
+	return slots
+}
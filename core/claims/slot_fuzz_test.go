@@ -0,0 +1,60 @@
+//go:build go1.18
+// +build go1.18
+
+package claims
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzSlotFromString(f *testing.F) {
+	f.Add("iden3")
+	f.Add("")
+	f.Add("a\x00b")
+	f.Fuzz(func(t *testing.T, s string) {
+		slot, err := SlotFromString(s)
+		if err != nil {
+			return
+		}
+		assert.True(t, merkletree.CheckEntryInField(merkletree.Entry{Data: merkletree.Data{slot.ElemBytes()}}))
+		s2, err := StringFromSlot(slot)
+		require.Nil(t, err)
+		assert.Equal(t, s, s2)
+	})
+}
+
+func FuzzSlotFromBigInt(f *testing.F) {
+	f.Add([]byte{0x01, 0x02})
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, b []byte) {
+		n := new(big.Int).SetBytes(b)
+		slot, err := SlotFromBigInt(n)
+		if n.BitLen() > 8*SlotLen {
+			assert.Equal(t, ErrSlotOverflow, err)
+			return
+		}
+		require.Nil(t, err)
+		assert.True(t, merkletree.CheckEntryInField(merkletree.Entry{Data: merkletree.Data{slot.ElemBytes()}}))
+		assert.Equal(t, 0, n.Cmp(BigIntFromSlot(slot)))
+	})
+}
+
+func FuzzStringFromSlot(f *testing.F) {
+	f.Add([]byte("iden3"))
+	f.Fuzz(func(t *testing.T, b []byte) {
+		var slot Slot
+		copy(slot[:], b)
+		s, err := StringFromSlot(slot)
+		if err != nil {
+			return
+		}
+		slot2, err := SlotFromString(s)
+		require.Nil(t, err)
+		assert.Equal(t, slot, slot2)
+	})
+}
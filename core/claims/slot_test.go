@@ -0,0 +1,97 @@
+package claims
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlotString(t *testing.T) {
+	for _, s := range []string{"", "iden3", "ñandú 🦤", "0123456789012345678901234567890"} {
+		slot, err := SlotFromString(s)
+		require.Nil(t, err, s)
+		s2, err := StringFromSlot(slot)
+		require.Nil(t, err, s)
+		assert.Equal(t, s, s2)
+	}
+	_, err := SlotFromString("01234567890123456789012345678901")
+	assert.Equal(t, ErrSlotOverflow, err)
+	_, err = SlotFromString("a\x00b")
+	assert.Equal(t, ErrSlotInvalidString, err)
+	_, err = SlotFromString("\xff")
+	assert.Equal(t, ErrSlotInvalidString, err)
+	_, err = StringFromSlot(Slot{'a', 0, 'b'})
+	assert.Equal(t, ErrSlotInvalidString, err)
+}
+
+func TestSlotBigInt(t *testing.T) {
+	max := new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 248), big.NewInt(1))
+	for _, n := range []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(0x0102), max} {
+		slot, err := SlotFromBigInt(n)
+		require.Nil(t, err)
+		assert.Equal(t, 0, n.Cmp(BigIntFromSlot(slot)))
+		// The slot in an element is the value of the element
+		assert.Equal(t, 0, n.Cmp(merkletree.ElemBytesToBigInt(slot.ElemBytes())))
+		assert.Equal(t, slot, SlotFromElemBytes(slot.ElemBytes()))
+	}
+	slot, err := SlotFromBigInt(big.NewInt(0x0102))
+	require.Nil(t, err)
+	assert.Equal(t, Slot{0x02, 0x01}, slot)
+
+	_, err = SlotFromBigInt(new(big.Int).Add(max, big.NewInt(1)))
+	assert.Equal(t, ErrSlotOverflow, err)
+	_, err = SlotFromBigInt(big.NewInt(-1))
+	assert.Equal(t, ErrSlotOverflow, err)
+}
+
+func TestSlotTime(t *testing.T) {
+	tm := time.Date(2020, time.March, 14, 15, 9, 26, 0, time.UTC)
+	slot, err := SlotFromTime(tm.In(time.FixedZone("UTC+2", 2*60*60)))
+	require.Nil(t, err)
+	tm2, err := TimeFromSlot(slot)
+	require.Nil(t, err)
+	assert.Equal(t, tm, tm2)
+
+	_, err = SlotFromTime(time.Unix(-1, 0))
+	assert.Equal(t, ErrSlotOverflow, err)
+	var slotMax Slot
+	for i := range slotMax {
+		slotMax[i] = 0xff
+	}
+	_, err = TimeFromSlot(slotMax)
+	assert.Equal(t, ErrSlotOverflow, err)
+}
+
+func TestSlotsClaimBasic(t *testing.T) {
+	name, err := SlotFromString("alice")
+	require.Nil(t, err)
+	amount, err := SlotFromBigInt(big.NewInt(1000))
+	require.Nil(t, err)
+	expiration, err := SlotFromTime(time.Unix(1600000000, 0))
+	require.Nil(t, err)
+
+	indexSlot, err := IndexSlotFromSlots(name, amount)
+	require.Nil(t, err)
+	dataSlot, err := DataSlotFromSlots(expiration)
+	require.Nil(t, err)
+	c0 := NewClaimBasic(indexSlot, dataSlot, 0)
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	// Each slot is stored in its own element
+	assert.Equal(t, name.ElemBytes(), e.Data[1])
+	assert.Equal(t, amount.ElemBytes(), e.Data[2])
+	assert.Equal(t, expiration.ElemBytes(), e.Data[5])
+
+	c1 := NewClaimBasicFromEntry(e)
+	assert.Equal(t, [3]Slot{name, amount, {}}, SlotsFromIndexSlot(c1.IndexSlot))
+	assert.Equal(t, [3]Slot{expiration, {}, {}}, SlotsFromDataSlot(c1.DataSlot))
+
+	_, err = IndexSlotFromSlots(name, name, name, name)
+	assert.Equal(t, ErrSlotTooMany, err)
+	_, err = DataSlotFromSlots(name, name, name, name)
+	assert.Equal(t, ErrSlotTooMany, err)
+}
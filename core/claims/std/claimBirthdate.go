@@ -0,0 +1,113 @@
+package std
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// ErrInvalidBirthdate is used when a birthdate can't be encoded in a
+// ClaimBirthdate.
+var ErrInvalidBirthdate = errors.New("birthdate year out of range [0, 65535]")
+
+// ClaimBirthdate is a claim that attests the birthdate of the identity Id.
+// The date is encoded as its calendar year, month and day, without time nor
+// location.
+type ClaimBirthdate struct {
+	// Version is the claim version.
+	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
+	// Year, Month and Day are the calendar date of birth.
+	Year  uint16
+	Month uint8
+	Day   uint8
+	// Id is the identity whose birthdate is attested.
+	Id core.ID
+}
+
+// NewClaimBirthdate returns a ClaimBirthdate that attests that id was born
+// on the calendar date of birthdate (in its location).
+func NewClaimBirthdate(id *core.ID, birthdate time.Time, revocationNonce uint32) (*ClaimBirthdate, error) {
+	year, month, day := birthdate.Date()
+	if year < 0 || year > 0xffff {
+		return nil, ErrInvalidBirthdate
+	}
+	return &ClaimBirthdate{
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		Year:            uint16(year),
+		Month:           uint8(month),
+		Day:             uint8(day),
+		Id:              *id,
+	}, nil
+}
+
+// NewClaimBirthdateFromEntry deserializes a ClaimBirthdate from an Entry.
+func NewClaimBirthdateFromEntry(e *merkletree.Entry) *ClaimBirthdate {
+	c := &ClaimBirthdate{}
+	_, c.Version = claims.GetClaimTypeVersion(e)
+	c.Year = binary.BigEndian.Uint16(e.Data[1][:2])
+	c.Month = e.Data[1][2]
+	c.Day = e.Data[1][3]
+	copy(c.Id[:], e.Data[2][:])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	return c
+}
+
+// Entry serializes the claim into an Entry.
+func (c *ClaimBirthdate) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	index := e.Index()
+	claims.SetClaimTypeVersion(e, c.Type(), c.Version)
+	binary.BigEndian.PutUint16(index[1][:2], c.Year)
+	index[1][2] = c.Month
+	index[1][3] = c.Day
+	copy(index[2][:], c.Id[:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+
+	return e
+}
+
+// Type returns the ClaimType of the claim.
+func (c *ClaimBirthdate) Type() claims.ClaimType {
+	return *claims.ClaimTypeBirthdate
+}
+
+// Birthdate returns the date of birth at 00:00 UTC.
+func (c *ClaimBirthdate) Birthdate() time.Time {
+	return time.Date(int(c.Year), time.Month(c.Month), int(c.Day), 0, 0, 0, 0, time.UTC)
+}
+
+// AgeAt returns the age in whole years at the calendar date of t.
+func (c *ClaimBirthdate) AgeAt(t time.Time) int {
+	year, month, day := t.Date()
+	age := year - int(c.Year)
+	if month < time.Month(c.Month) || (month == time.Month(c.Month) && day < int(c.Day)) {
+		age--
+	}
+	return age
+}
+
+// IsOlderThan returns true if the age at the calendar date of t is at least
+// years.
+func (c *ClaimBirthdate) IsOlderThan(years int, t time.Time) bool {
+	return c.AgeAt(t) >= years
+}
+
+// IssueBirthdate issues with is a ClaimBirthdate that attests that id was
+// born on birthdate.
+func IssueBirthdate(is Issuer, id *core.ID, birthdate time.Time) (*ClaimBirthdate, error) {
+	claim, err := is.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return NewClaimBirthdate(id, birthdate, revocationNonce)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim.(*ClaimBirthdate), nil
+}
@@ -0,0 +1,37 @@
+package std
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimBirthdate(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	birthdate := time.Date(2000, time.February, 29, 0, 0, 0, 0, time.UTC)
+	c0, err := NewClaimBirthdate(&id, birthdate, 1234)
+	require.Nil(t, err)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	c1 := NewClaimBirthdateFromEntry(e)
+	c2, err := NewClaimFromEntry(e)
+	assert.Nil(t, err)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+	assert.Equal(t, birthdate, c1.Birthdate())
+
+	assert.Equal(t, 17, c1.AgeAt(time.Date(2018, time.February, 28, 12, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 18, c1.AgeAt(time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)))
+	assert.Equal(t, 20, c1.AgeAt(time.Date(2020, time.February, 29, 0, 0, 0, 0, time.UTC)))
+	assert.False(t, c1.IsOlderThan(18, time.Date(2018, time.February, 28, 0, 0, 0, 0, time.UTC)))
+	assert.True(t, c1.IsOlderThan(18, time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC)))
+
+	_, err = NewClaimBirthdate(&id, time.Date(-1, time.January, 1, 0, 0, 0, 0, time.UTC), 0)
+	assert.Equal(t, ErrInvalidBirthdate, err)
+}
@@ -0,0 +1,114 @@
+package std
+
+import (
+	"encoding/binary"
+	"errors"
+	"net/mail"
+	"strings"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// ErrInvalidEmail is used when an email address is not valid.
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// EmailHashLen is the length in bytes of the hash of the email address
+// stored in a ClaimEmail.
+const EmailHashLen = 248 / 8
+
+// NormalizeEmail checks that email is a plain email address (without display
+// name) and returns it trimmed and lowercased, so that the same address
+// always produces the same claim.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// EmailHash returns the hash of the normalized email address stored in a
+// ClaimEmail.
+func EmailHash(email string) ([EmailHashLen]byte, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return [EmailHashLen]byte{}, err
+	}
+	return claims.HashString(email), nil
+}
+
+// ClaimEmail is a claim that attests that the identity Id controls an email
+// address.  Only the hash of the normalized address is stored, so the claim
+// can be queried by address but the address can't be read from it.
+type ClaimEmail struct {
+	// Version is the claim version.
+	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
+	// EmailHash is the hash of the normalized email address.
+	EmailHash [EmailHashLen]byte
+	// Id is the identity that controls the email address.
+	Id core.ID
+}
+
+// NewClaimEmail returns a ClaimEmail that attests that id controls email.
+func NewClaimEmail(id *core.ID, email string, revocationNonce uint32) (*ClaimEmail, error) {
+	emailHash, err := EmailHash(email)
+	if err != nil {
+		return nil, err
+	}
+	return &ClaimEmail{
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		EmailHash:       emailHash,
+		Id:              *id,
+	}, nil
+}
+
+// NewClaimEmailFromEntry deserializes a ClaimEmail from an Entry.
+func NewClaimEmailFromEntry(e *merkletree.Entry) *ClaimEmail {
+	c := &ClaimEmail{}
+	_, c.Version = claims.GetClaimTypeVersion(e)
+	copy(c.EmailHash[:], e.Data[1][:EmailHashLen])
+	copy(c.Id[:], e.Data[2][:])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	return c
+}
+
+// Entry serializes the claim into an Entry.
+func (c *ClaimEmail) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	index := e.Index()
+	claims.SetClaimTypeVersion(e, c.Type(), c.Version)
+	copy(index[1][:EmailHashLen], c.EmailHash[:])
+	copy(index[2][:], c.Id[:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+
+	return e
+}
+
+// Type returns the ClaimType of the claim.
+func (c *ClaimEmail) Type() claims.ClaimType {
+	return *claims.ClaimTypeEmail
+}
+
+// HasEmail returns true if the claim attests the email address.
+func (c *ClaimEmail) HasEmail(email string) bool {
+	emailHash, err := EmailHash(email)
+	return err == nil && emailHash == c.EmailHash
+}
+
+// IssueEmail issues with is a ClaimEmail that attests that id controls email.
+func IssueEmail(is Issuer, id *core.ID, email string) (*ClaimEmail, error) {
+	claim, err := is.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return NewClaimEmail(id, email, revocationNonce)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim.(*ClaimEmail), nil
+}
@@ -0,0 +1,38 @@
+package std
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimEmail(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	c0, err := NewClaimEmail(&id, "alice@example.com", 1234)
+	require.Nil(t, err)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	c1 := NewClaimEmailFromEntry(e)
+	c2, err := NewClaimFromEntry(e)
+	assert.Nil(t, err)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+
+	// The email address is normalized
+	assert.True(t, c0.HasEmail(" Alice@Example.com"))
+	assert.False(t, c0.HasEmail("bob@example.com"))
+	c3, err := NewClaimEmail(&id, "ALICE@example.com ", 5678)
+	require.Nil(t, err)
+	c3.Version = 1
+	assert.Equal(t, e.HIndex(), c3.Entry().HIndex())
+
+	for _, email := range []string{"", "alice", "Alice <alice@example.com>", "alice@example.com, bob@example.com"} {
+		_, err = NewClaimEmail(&id, email, 0)
+		assert.Equal(t, ErrInvalidEmail, err, email)
+	}
+}
@@ -0,0 +1,102 @@
+package std
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// KYCLevel is the level of the Know Your Customer checks passed by an
+// identity.  Higher levels include the checks of the lower ones.
+type KYCLevel uint8
+
+const (
+	// KYCLevelBasic is the level of the identities with a verified contact
+	// (email or phone).
+	KYCLevelBasic KYCLevel = 1
+	// KYCLevelStandard is the level of the identities with a verified
+	// identity document.
+	KYCLevelStandard KYCLevel = 2
+	// KYCLevelEnhanced is the level of the identities with a verified
+	// identity document, proof of address and source of funds.
+	KYCLevelEnhanced KYCLevel = 3
+)
+
+// ErrInvalidKYCLevel is used when a KYCLevel is not one of the defined
+// levels.
+var ErrInvalidKYCLevel = errors.New("invalid KYC level")
+
+// ClaimKYCLevel is a claim that attests the KYC level verified by the issuer
+// for the identity Id.
+type ClaimKYCLevel struct {
+	// Version is the claim version.
+	Version uint32
+	// RevocationNonce is used to revocate the claim
+	RevocationNonce uint32
+	// Level is the KYC level verified.
+	Level KYCLevel
+	// Id is the identity whose KYC level is attested.
+	Id core.ID
+}
+
+// NewClaimKYCLevel returns a ClaimKYCLevel that attests that id passed the
+// checks of level.
+func NewClaimKYCLevel(id *core.ID, level KYCLevel, revocationNonce uint32) (*ClaimKYCLevel, error) {
+	if level < KYCLevelBasic || level > KYCLevelEnhanced {
+		return nil, ErrInvalidKYCLevel
+	}
+	return &ClaimKYCLevel{
+		Version:         0,
+		RevocationNonce: revocationNonce,
+		Level:           level,
+		Id:              *id,
+	}, nil
+}
+
+// NewClaimKYCLevelFromEntry deserializes a ClaimKYCLevel from an Entry.
+func NewClaimKYCLevelFromEntry(e *merkletree.Entry) *ClaimKYCLevel {
+	c := &ClaimKYCLevel{}
+	_, c.Version = claims.GetClaimTypeVersion(e)
+	c.Level = KYCLevel(e.Data[1][0])
+	copy(c.Id[:], e.Data[2][:])
+	c.RevocationNonce = binary.BigEndian.Uint32(e.Data[4][:4])
+	return c
+}
+
+// Entry serializes the claim into an Entry.
+func (c *ClaimKYCLevel) Entry() *merkletree.Entry {
+	e := &merkletree.Entry{}
+	index := e.Index()
+	claims.SetClaimTypeVersion(e, c.Type(), c.Version)
+	index[1][0] = byte(c.Level)
+	copy(index[2][:], c.Id[:])
+
+	binary.BigEndian.PutUint32(e.Data[4][:4], c.RevocationNonce)
+
+	return e
+}
+
+// Type returns the ClaimType of the claim.
+func (c *ClaimKYCLevel) Type() claims.ClaimType {
+	return *claims.ClaimTypeKYCLevel
+}
+
+// AtLeast returns true if the attested level includes the checks of level.
+func (c *ClaimKYCLevel) AtLeast(level KYCLevel) bool {
+	return c.Level >= level
+}
+
+// IssueKYCLevel issues with is a ClaimKYCLevel that attests that id passed
+// the checks of level.
+func IssueKYCLevel(is Issuer, id *core.ID, level KYCLevel) (*ClaimKYCLevel, error) {
+	claim, err := is.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return NewClaimKYCLevel(id, level, revocationNonce)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claim.(*ClaimKYCLevel), nil
+}
@@ -0,0 +1,34 @@
+package std
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClaimKYCLevel(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	c0, err := NewClaimKYCLevel(&id, KYCLevelStandard, 1234)
+	require.Nil(t, err)
+	c0.Version = 1
+	e := c0.Entry()
+	assert.True(t, merkletree.CheckEntryInField(*e))
+	c1 := NewClaimKYCLevelFromEntry(e)
+	c2, err := NewClaimFromEntry(e)
+	assert.Nil(t, err)
+	assert.Equal(t, c0, c1)
+	assert.Equal(t, c0, c2)
+
+	assert.True(t, c1.AtLeast(KYCLevelBasic))
+	assert.True(t, c1.AtLeast(KYCLevelStandard))
+	assert.False(t, c1.AtLeast(KYCLevelEnhanced))
+
+	_, err = NewClaimKYCLevel(&id, 0, 0)
+	assert.Equal(t, ErrInvalidKYCLevel, err)
+	_, err = NewClaimKYCLevel(&id, KYCLevelEnhanced+1, 0)
+	assert.Equal(t, ErrInvalidKYCLevel, err)
+}
@@ -0,0 +1,40 @@
+// Package std contains ready-made typed claims for common attestations about
+// identities (email address, birthdate and KYC level), with the encoding of
+// their values into the claim elements already defined, so that the claims
+// issued by different applications are interoperable.
+package std
+
+import (
+	"errors"
+
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// ErrNotInField is used when an Entry has elements out of the Finite Field.
+var ErrNotInField = errors.New("Elements not in the Finite Field over R")
+
+// Issuer is the interface of the issuers of std claims, satisfied by
+// *issuer.Issuer.
+type Issuer interface {
+	IssueClaimWithNonce(newClaim func(revocationNonce uint32) (merkletree.Entrier, error)) (merkletree.Entrier, error)
+}
+
+// NewClaimFromEntry deserializes a std claim, or any claim known by
+// claims.NewClaimFromEntry, from an Entry.
+func NewClaimFromEntry(e *merkletree.Entry) (merkletree.Entrier, error) {
+	if !merkletree.CheckEntryInField(*e) {
+		return nil, ErrNotInField
+	}
+	claimType, _ := claims.GetClaimTypeVersion(e)
+	switch claimType {
+	case *claims.ClaimTypeEmail:
+		return NewClaimEmailFromEntry(e), nil
+	case *claims.ClaimTypeBirthdate:
+		return NewClaimBirthdateFromEntry(e), nil
+	case *claims.ClaimTypeKYCLevel:
+		return NewClaimKYCLevelFromEntry(e), nil
+	default:
+		return claims.NewClaimFromEntry(e)
+	}
+}
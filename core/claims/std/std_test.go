@@ -0,0 +1,49 @@
+package std
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type issuerTest struct {
+	nonce  uint32
+	claims []merkletree.Entrier
+}
+
+func (is *issuerTest) IssueClaimWithNonce(newClaim func(revocationNonce uint32) (merkletree.Entrier, error)) (merkletree.Entrier, error) {
+	claim, err := newClaim(is.nonce)
+	if err != nil {
+		return nil, err
+	}
+	is.nonce++
+	is.claims = append(is.claims, claim)
+	return claim, nil
+}
+
+func TestIssue(t *testing.T) {
+	id, err := core.IDFromString("113kyY52PSBr9oUqosmYkCavjjrQFuiuAw47FpZeUf")
+	require.Nil(t, err)
+	is := &issuerTest{nonce: 7}
+
+	claimEmail, err := IssueEmail(is, &id, "alice@example.com")
+	require.Nil(t, err)
+	assert.Equal(t, uint32(7), claimEmail.RevocationNonce)
+	claimKYC, err := IssueKYCLevel(is, &id, KYCLevelBasic)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(8), claimKYC.RevocationNonce)
+	// Invalid values are not issued
+	_, err = IssueEmail(is, &id, "alice")
+	assert.Equal(t, ErrInvalidEmail, err)
+	assert.Equal(t, 2, len(is.claims))
+
+	// Non std claims are deserialized by claims.NewClaimFromEntry
+	claimBasic := claims.NewClaimBasic([claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}, 0)
+	claim, err := NewClaimFromEntry(claimBasic.Entry())
+	require.Nil(t, err)
+	assert.Equal(t, claimBasic, claim)
+}
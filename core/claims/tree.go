@@ -0,0 +1,49 @@
+package claims
+
+import (
+	"bytes"
+	"sort"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// SortByHIndex sorts entries in place by the bytes of their HIndex, which is
+// the canonical order of a set of claims.
+func SortByHIndex(entries []merkletree.Entrier) {
+	type keyed struct {
+		hIndex []byte
+		entry  merkletree.Entrier
+	}
+	keys := make([]keyed, len(entries))
+	for i, e := range entries {
+		keys[i] = keyed{hIndex: e.Entry().HIndex().Bytes(), entry: e}
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i].hIndex, keys[j].hIndex) < 0
+	})
+	for i := range keys {
+		entries[i] = keys[i].entry
+	}
+}
+
+// TreeFromClaims builds a claims tree in storage with the set of claims,
+// adding them in the canonical order (sorted by HIndex), so that any party
+// building the tree from the same claim set gets the same tree, root and
+// errors regardless of the order of claims.  claims is not modified.  It
+// returns the tree and its root.
+func TreeFromClaims(storage db.Storage, claims []merkletree.Entrier) (*merkletree.MerkleTree, *merkletree.Hash, error) {
+	sorted := make([]merkletree.Entrier, len(claims))
+	copy(sorted, claims)
+	SortByHIndex(sorted)
+	mt, err := merkletree.NewMerkleTree(storage, 140)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, claim := range sorted {
+		if err := mt.AddClaim(claim); err != nil {
+			return nil, nil, err
+		}
+	}
+	return mt, mt.RootKey(), nil
+}
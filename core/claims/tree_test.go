@@ -0,0 +1,60 @@
+package claims
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTreeTestClaims(n int) []merkletree.Entrier {
+	entries := make([]merkletree.Entrier, n)
+	for i := range entries {
+		var indexSlot [IndexSlotBytes]byte
+		var dataSlot [DataSlotBytes]byte
+		indexSlot[0], dataSlot[0] = byte(i), byte(i)
+		entries[i] = NewClaimBasic(indexSlot, dataSlot, uint32(i))
+	}
+	return entries
+}
+
+func TestTreeFromClaims(t *testing.T) {
+	entries := newTreeTestClaims(8)
+	reversed := make([]merkletree.Entrier, len(entries))
+	for i, e := range entries {
+		reversed[len(entries)-1-i] = e
+	}
+
+	mt0, root0, err := TreeFromClaims(db.NewMemoryStorage(), entries)
+	require.Nil(t, err)
+	assert.Equal(t, mt0.RootKey(), root0)
+	_, root1, err := TreeFromClaims(db.NewMemoryStorage(), reversed)
+	require.Nil(t, err)
+	assert.Equal(t, root0, root1)
+	// The input slice is not reordered.
+	assert.Equal(t, entries[7], reversed[0])
+
+	for _, e := range entries {
+		data, err := mt0.GetDataByIndex(e.Entry().HIndex())
+		require.Nil(t, err)
+		assert.Equal(t, e.Entry().Data, *data)
+	}
+
+	_, _, err = TreeFromClaims(db.NewMemoryStorage(), append(entries, entries[3]))
+	assert.Equal(t, merkletree.ErrEntryIndexAlreadyExists, err)
+
+	mtEmpty, rootEmpty, err := TreeFromClaims(db.NewMemoryStorage(), nil)
+	require.Nil(t, err)
+	assert.Equal(t, &merkletree.HashZero, rootEmpty)
+	assert.Equal(t, mtEmpty.RootKey(), rootEmpty)
+}
+
+func TestSortByHIndex(t *testing.T) {
+	entries := newTreeTestClaims(8)
+	SortByHIndex(entries)
+	for i := 1; i < len(entries); i++ {
+		assert.True(t, entries[i-1].Entry().HIndex().Hex() < entries[i].Entry().HIndex().Hex())
+	}
+}
@@ -0,0 +1,182 @@
+// Package did describes the iden3 identities as W3C DID documents
+// (https://www.w3.org/TR/did-core/).  The DID of an identity is
+// did:iden3:<id>.  Its document lists the keys authorized by the
+// authorization claims of the identity as verification methods, the URL of
+// its off chain public data as a service, and its identity state.
+package did
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// Method is the DID method of the iden3 identities.
+const Method = "iden3"
+
+// ContextDIDv1 is the JSON-LD context of the DID documents.
+const ContextDIDv1 = "https://www.w3.org/ns/did/v1"
+
+// Types of the services of a Document.
+const (
+	// ServiceTypePublicData is the service of the off chain public data
+	// of the identity.
+	ServiceTypePublicData = "Iden3PublicData"
+	// ServiceTypeLinkedDomains is a domain of the identity, which serves
+	// its off chain public data at a well-known path.
+	ServiceTypeLinkedDomains = "LinkedDomains"
+)
+
+// Types of the verification methods of a Document.
+const (
+	// VerificationMethodBabyJubJub is a BabyJubJub key, like the kOp,
+	// given as the hex of the compressed public key.
+	VerificationMethodBabyJubJub = "Iden3BabyJubJubKey"
+	// VerificationMethodSecp256k1 is a secp256k1 key, given as the hex of
+	// the compressed public key.
+	VerificationMethodSecp256k1 = "EcdsaSecp256k1VerificationKey2019"
+	// VerificationMethodEthereumAddress is an Ethereum key, given as its
+	// address.
+	VerificationMethodEthereumAddress = "EcdsaSecp256k1RecoveryMethod2020"
+)
+
+var (
+	ErrInvalidDID = errors.New("invalid iden3 DID")
+)
+
+// DID returns the DID of the identity id.
+func DID(id *core.ID) string {
+	return "did:" + Method + ":" + id.String()
+}
+
+// IDFromDID returns the identity of an iden3 DID.
+func IDFromDID(did string) (*core.ID, error) {
+	prefix := "did:" + Method + ":"
+	if !strings.HasPrefix(did, prefix) {
+		return nil, ErrInvalidDID
+	}
+	id, err := core.IDFromString(strings.TrimPrefix(did, prefix))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDID, err)
+	}
+	return &id, nil
+}
+
+// VerificationMethod is a key authorized by the identity.
+type VerificationMethod struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	Controller      string `json:"controller"`
+	PublicKeyHex    string `json:"publicKeyHex,omitempty"`
+	EthereumAddress string `json:"ethereumAddress,omitempty"`
+}
+
+// Service is a service endpoint of the identity.
+type Service struct {
+	ID              string `json:"id"`
+	Type            string `json:"type"`
+	ServiceEndpoint string `json:"serviceEndpoint"`
+}
+
+// State is the identity state metadata of a Document.
+type State struct {
+	IdenState *merkletree.Hash `json:"idenState"`
+	BlockN    uint64           `json:"blockN,omitempty"`
+	BlockTs   int64            `json:"blockTimestamp,omitempty"`
+	// Genesis is true if IdenState is the genesis state of the identity,
+	// which is not published on chain.
+	Genesis bool `json:"genesis,omitempty"`
+}
+
+// Document is the DID document of an identity.
+type Document struct {
+	Context            []string             `json:"@context"`
+	ID                 string               `json:"id"`
+	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
+	// Authentication are the verification methods that sign on behalf of
+	// the identity.
+	Authentication []string `json:"authentication,omitempty"`
+	// CapabilityInvocation are the verification methods that manage the
+	// identity in the Smart Contract (disable, reenable, upgrade and
+	// update the state).
+	CapabilityInvocation []string  `json:"capabilityInvocation,omitempty"`
+	Service              []Service `json:"service,omitempty"`
+	State                *State    `json:"iden3State,omitempty"`
+}
+
+// PublicData is the information of an identity described in its Document.
+type PublicData struct {
+	// Claims are the valid claims of the identity.  The authorization
+	// claims of keys (ClaimAuthorizeKSignBabyJub,
+	// ClaimAuthorizeKSignSecp256k1 and ClaimAuthEthKey) become
+	// verification methods, and the rest are ignored.
+	Claims []merkletree.Entrier
+	// PublicDataURL is the URL of the off chain public data.  If not
+	// empty, it's added as a ServiceTypePublicData service.
+	PublicDataURL string
+	// State is the identity state.  If not nil, it's added as the State
+	// metadata.
+	State *proof.IdenStateData
+}
+
+// NewDocument returns the DID document of the identity id with publicData.
+func NewDocument(id *core.ID, publicData *PublicData) (*Document, error) {
+	did := DID(id)
+	doc := Document{Context: []string{ContextDIDv1}, ID: did}
+	for i, c := range publicData.Claims {
+		e := c.Entry()
+		claimType, _ := claims.GetClaimTypeVersion(e)
+		vm := VerificationMethod{ID: fmt.Sprintf("%v#key-%d", did, len(doc.VerificationMethod)), Controller: did}
+		authentication := true
+		switch claimType {
+		case *claims.ClaimTypeAuthorizeKSignBabyJub:
+			claim := claims.NewClaimAuthorizeKSignBabyJubFromEntry(e)
+			vm.Type = VerificationMethodBabyJubJub
+			vm.PublicKeyHex = hex.EncodeToString(claim.PublicKeyComp()[:])
+		case *claims.ClaimTypeAuthorizeKSignSecp256k1:
+			claim, err := claims.NewClaimAuthorizeKSignSecp256k1FromEntry(e)
+			if err != nil {
+				return nil, fmt.Errorf("claim %d: %w", i, err)
+			}
+			vm.Type = VerificationMethodSecp256k1
+			vm.PublicKeyHex = hex.EncodeToString(crypto.CompressPubkey(claim.PubKey))
+		case *claims.ClaimTypeAuthEthKey:
+			claim := claims.NewClaimAuthEthKeyFromEntry(e)
+			vm.Type = VerificationMethodEthereumAddress
+			vm.EthereumAddress = claim.EthKey.Hex()
+			authentication = claims.NewEthKeyType(claim.EthKeyType) == claims.EthKeyTypeAuthenticate
+		default:
+			continue
+		}
+		doc.VerificationMethod = append(doc.VerificationMethod, vm)
+		if authentication {
+			doc.Authentication = append(doc.Authentication, vm.ID)
+		} else {
+			doc.CapabilityInvocation = append(doc.CapabilityInvocation, vm.ID)
+		}
+	}
+	if publicData.PublicDataURL != "" {
+		doc.Service = append(doc.Service, Service{
+			ID:              did + "#public-data",
+			Type:            ServiceTypePublicData,
+			ServiceEndpoint: publicData.PublicDataURL,
+		})
+	}
+	if publicData.State != nil {
+		credExist := proof.CredentialExistence{Id: id, IdenStateData: *publicData.State}
+		doc.State = &State{
+			IdenState: publicData.State.IdenState,
+			BlockN:    publicData.State.BlockN,
+			BlockTs:   publicData.State.BlockTs,
+			Genesis:   credExist.IsGenesis(),
+		}
+	}
+	return &doc, nil
+}
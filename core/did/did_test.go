@@ -0,0 +1,110 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDID(t *testing.T) {
+	id := core.NewID(core.TypeBJP0, [27]byte{1, 2, 3})
+	did := DID(&id)
+	assert.Equal(t, "did:iden3:"+id.String(), did)
+	id2, err := IDFromDID(did)
+	require.Nil(t, err)
+	assert.Equal(t, id, *id2)
+
+	_, err = IDFromDID("did:web:example.com")
+	assert.Equal(t, ErrInvalidDID, err)
+	_, err = IDFromDID("did:iden3:invalid")
+	assert.True(t, errors.Is(err, ErrInvalidDID))
+}
+
+func newTestDocument(t *testing.T) (*core.ID, *Document) {
+	sk0 := babyjub.NewRandPrivKey()
+	kOp := sk0.Public()
+	claimKOp := claims.NewClaimAuthorizeKSignBabyJub(kOp, 0)
+	idenState := core.IdenState(&merkletree.Hash{1}, &merkletree.HashZero, &merkletree.Hash{2})
+	id := core.IdGenesisFromIdenState(idenState)
+
+	sk, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	doc, err := NewDocument(id, &PublicData{
+		Claims: []merkletree.Entrier{
+			claimKOp,
+			claims.NewClaimBasic(indexBytes, dataBytes, 1),
+			claims.NewClaimAuthorizeKSignSecp256k1(&sk.PublicKey),
+			claims.NewClaimAuthEthKey(common.Address{1}, claims.EthKeyTypeAuthenticate, 2),
+			claims.NewClaimAuthEthKey(common.Address{2}, claims.EthKeyTypeDisable, 3),
+		},
+		PublicDataURL: "https://iden.example.com",
+		State:         &proof.IdenStateData{IdenState: idenState},
+	})
+	require.Nil(t, err)
+	return id, doc
+}
+
+func TestNewDocument(t *testing.T) {
+	id, doc := newTestDocument(t)
+	did := DID(id)
+	assert.Equal(t, did, doc.ID)
+	require.Equal(t, 4, len(doc.VerificationMethod))
+	assert.Equal(t, VerificationMethodBabyJubJub, doc.VerificationMethod[0].Type)
+	assert.Equal(t, VerificationMethodSecp256k1, doc.VerificationMethod[1].Type)
+	assert.Equal(t, common.Address{1}.Hex(), doc.VerificationMethod[2].EthereumAddress)
+	assert.Equal(t, did+"#key-3", doc.VerificationMethod[3].ID)
+	assert.Equal(t, []string{did + "#key-0", did + "#key-1", did + "#key-2"}, doc.Authentication)
+	assert.Equal(t, []string{did + "#key-3"}, doc.CapabilityInvocation)
+	assert.Equal(t, []Service{{ID: did + "#public-data", Type: ServiceTypePublicData,
+		ServiceEndpoint: "https://iden.example.com"}}, doc.Service)
+	assert.True(t, doc.State.Genesis)
+
+	docJSON, err := json.Marshal(doc)
+	require.Nil(t, err)
+	var doc2 Document
+	require.Nil(t, json.Unmarshal(docJSON, &doc2))
+	assert.Equal(t, doc, &doc2)
+}
+
+func TestHandler(t *testing.T) {
+	id, doc := newTestDocument(t)
+	server := httptest.NewServer(Handler(id, func(docID *core.ID) (*Document, error) {
+		if *docID != *id {
+			return nil, ErrDocumentNotFound
+		}
+		return doc, nil
+	}))
+	defer server.Close()
+
+	for _, path := range []string{PathWellKnown, "/" + DID(id)} {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		assert.Equal(t, http.StatusOK, res.StatusCode)
+		assert.Equal(t, ContentType, res.Header.Get("Content-Type"))
+		var doc2 Document
+		require.Nil(t, json.NewDecoder(res.Body).Decode(&doc2))
+		res.Body.Close()
+		assert.Equal(t, doc, &doc2)
+	}
+
+	other := core.NewID(core.TypeBJP0, [27]byte{1})
+	for _, path := range []string{"/" + DID(&other), "/did.json"} {
+		res, err := http.Get(server.URL + path)
+		require.Nil(t, err)
+		res.Body.Close()
+		assert.Equal(t, http.StatusNotFound, res.StatusCode)
+	}
+}
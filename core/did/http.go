@@ -0,0 +1,62 @@
+package did
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/iden3/go-iden3-core/core"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathWellKnown is the path where a domain serves the DID document of its
+// identity.
+const PathWellKnown = "/.well-known/did.json"
+
+// ContentType is the media type of the served DID documents.
+const ContentType = "application/did+json"
+
+var (
+	// ErrDocumentNotFound is returned by a DocumentGetter for the unknown
+	// identities.
+	ErrDocumentNotFound = errors.New("DID document not found")
+)
+
+// DocumentGetter returns the DID document of the identity id, or
+// ErrDocumentNotFound.
+type DocumentGetter func(id *core.ID) (*Document, error)
+
+// Handler returns an http.Handler that serves the DID document of the
+// identity id at PathWellKnown, and the DID document of any identity known to
+// get at /<did>, so that it can be used as the resolver of
+// resolver.DIDResolver.  id can be nil to serve only the latter.
+func Handler(id *core.ID, get DocumentGetter) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		docID := id
+		if r.URL.Path != PathWellKnown || id == nil {
+			var err error
+			if docID, err = IDFromDID(strings.TrimPrefix(r.URL.Path, "/")); err != nil {
+				http.NotFound(w, r)
+				return
+			}
+		}
+		doc, err := get(docID)
+		if err == ErrDocumentNotFound {
+			http.NotFound(w, r)
+			return
+		} else if err != nil {
+			log.WithError(err).WithField("id", docID).Error("DocumentGetter")
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		if err := json.NewEncoder(w).Encode(doc); err != nil {
+			log.WithError(err).Warn("Unable to write http response")
+		}
+	})
+}
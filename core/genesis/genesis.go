@@ -17,7 +17,8 @@ import (
 // where the hash function is Poseidon
 func CalculateIdGenesis(claimKOp *claims.ClaimAuthorizeKSignBabyJub, extraGenesisClaims []merkletree.Entrier) (*core.ID, *proof.ProofClaim, error) {
 	// add the claims into an ephemeral merkletree to calculate the genesis root to get that identity
-	clt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	genesisClaims := append([]merkletree.Entrier{claimKOp}, extraGenesisClaims...)
+	clt, _, err := claims.TreeFromClaims(db.NewMemoryStorage(), genesisClaims)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -26,7 +27,7 @@ func CalculateIdGenesis(claimKOp *claims.ClaimAuthorizeKSignBabyJub, extraGenesi
 		return nil, nil, err
 	}
 
-	return CalculateIdGenesisMT(clt, rot, claimKOp, extraGenesisClaims)
+	return idGenesis(clt, rot, claimKOp)
 }
 
 // CalculateIdGenesisMT calculates the Genesis ID from the given claims using
@@ -43,6 +44,12 @@ func CalculateIdGenesisMT(clt *merkletree.MerkleTree, rot *merkletree.MerkleTree
 		}
 	}
 
+	return idGenesis(clt, rot, claimKOp)
+}
+
+// idGenesis calculates the Genesis ID from the Claims Merkle Tree containing
+// the genesis claims, adding its root to the Roots Merkle Tree.
+func idGenesis(clt *merkletree.MerkleTree, rot *merkletree.MerkleTree, claimKOp *claims.ClaimAuthorizeKSignBabyJub) (*core.ID, *proof.ProofClaim, error) {
 	clr := clt.RootKey()
 
 	if err := claims.AddLeafRootsTree(rot, clr); err != nil {
@@ -55,10 +62,31 @@ func CalculateIdGenesisMT(clt *merkletree.MerkleTree, rot *merkletree.MerkleTree
 	if err != nil {
 		return nil, nil, err
 	}
+	mtpClaimsRoot, err := rot.GenerateProof(claims.NewLeafRootsTree(*clr).Entry().HIndex(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The genesis revocations tree is empty.
+	ret, err := merkletree.NewMerkleTreeInMemory(140)
+	if err != nil {
+		return nil, nil, err
+	}
+	revLeaf := claims.NewLeafRevocationsTree(claims.GetRevocationNonce(claimKOp.Entry()), claims.RevocationVersionAll)
+	mtpNotRevoked, err := ret.GenerateProof(revLeaf.Entry().HIndex(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	idenState := core.IdenState(clr, &merkletree.HashZero, ror)
 	id := core.IdGenesisFromIdenState(idenState)
 
+	proofClaimKOp.Id = id
+	proofClaimKOp.MtpNotRevoked = mtpNotRevoked
+	proofClaimKOp.RevocationsRoot = &merkletree.HashZero
+	proofClaimKOp.MtpClaimsRoot = mtpClaimsRoot
+	proofClaimKOp.RootsRoot = ror
+	proofClaimKOp.IdenStateData = &proof.IdenStateData{IdenState: idenState}
+
 	return id, proofClaimKOp, nil
 }
 
@@ -84,17 +112,17 @@ func CalculateIdGenesisFrom4Keys(kop *babyjub.PublicKey, kdis, kreen, kupdateRoo
 		return nil, nil, err
 	}
 
-	claimKDis := claims.NewClaimAuthEthKey(kdis, claims.EthKeyTypeDisable)
+	claimKDis := claims.NewClaimAuthEthKey(kdis, claims.EthKeyTypeDisable, 1)
 	err = mt.AddClaim(claimKDis)
 	if err != nil {
 		return nil, nil, err
 	}
-	claimKReen := claims.NewClaimAuthEthKey(kreen, claims.EthKeyTypeReenable)
+	claimKReen := claims.NewClaimAuthEthKey(kreen, claims.EthKeyTypeReenable, 2)
 	err = mt.AddClaim(claimKReen)
 	if err != nil {
 		return nil, nil, err
 	}
-	claimKUpdateRoot := claims.NewClaimAuthEthKey(kupdateRoot, claims.EthKeyTypeUpdateRoot)
+	claimKUpdateRoot := claims.NewClaimAuthEthKey(kupdateRoot, claims.EthKeyTypeUpdateRoot, 3)
 	err = mt.AddClaim(claimKUpdateRoot)
 	if err != nil {
 		return nil, nil, err
@@ -9,10 +9,12 @@ import (
 	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/claims"
 	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
 	"github.com/iden3/go-iden3-core/merkletree"
 	"github.com/iden3/go-iden3-core/testgen"
 	"github.com/iden3/go-iden3-crypto/babyjub"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var debug = false
@@ -73,8 +75,15 @@ func TestProofClaimGenesis(t *testing.T) {
 	id, proofClaimKOp, err := CalculateIdGenesis(claimKOp, []merkletree.Entrier{})
 	assert.Nil(t, err)
 
+	verified, err := proofClaimKOp.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, proof.ProofClaimClaimsTree|proof.ProofClaimNonRevocation|
+		proof.ProofClaimRootsTree|proof.ProofClaimState, verified)
+	assert.True(t, proofClaimKOp.IsGenesis())
+	assert.Equal(t, id, proofClaimKOp.Id)
+
 	proofClaimGenesis := proof.ProofClaimGenesis{
-		Mtp: proofClaimKOp.Proof.Mtp0,
+		Mtp: proofClaimKOp.MtpClaim,
 		Id:  id,
 	}
 	_, err = proofClaimGenesis.Verify(claimKOp.Entry())
@@ -82,7 +91,7 @@ func TestProofClaimGenesis(t *testing.T) {
 
 	// Invalid Id
 	proofClaimGenesis = proof.ProofClaimGenesis{
-		Mtp: proofClaimKOp.Proof.Mtp0,
+		Mtp: proofClaimKOp.MtpClaim,
 		Id:  &core.ID{},
 	}
 	_, err = proofClaimGenesis.Verify(claimKOp.Entry())
@@ -91,16 +100,24 @@ func TestProofClaimGenesis(t *testing.T) {
 	// Invalid Mtp of non-existence
 	claimKOp2 := claims.NewClaimAuthorizeKSignBabyJub(&kOp, 0)
 	claimKOp2.Version = 1
+	clt, _, err := claims.TreeFromClaims(db.NewMemoryStorage(), []merkletree.Entrier{claimKOp})
+	require.Nil(t, err)
+	require.Equal(t, proofClaimKOp.ClaimsRoot, clt.RootKey())
+	mtpNonExistence, err := clt.GenerateProof(claimKOp2.Entry().HIndex(), nil)
+	require.Nil(t, err)
+	require.False(t, mtpNonExistence.Existence)
+	require.True(t, merkletree.VerifyProof(clt.RootKey(), mtpNonExistence,
+		claimKOp2.Entry().HIndex(), claimKOp2.Entry().HValue()))
 	proofClaimGenesis = proof.ProofClaimGenesis{
-		Mtp: proofClaimKOp.Proof.Mtp1,
-		Id:  &core.ID{},
+		Mtp: mtpNonExistence,
+		Id:  id,
 	}
 	_, err = proofClaimGenesis.Verify(claimKOp2.Entry())
 	assert.NotNil(t, err)
 
 	// Invalid Claim
 	proofClaimGenesis = proof.ProofClaimGenesis{
-		Mtp: proofClaimKOp.Proof.Mtp0,
+		Mtp: proofClaimKOp.MtpClaim,
 		Id:  &core.ID{},
 	}
 	_, err = proofClaimGenesis.Verify(claims.NewClaimBasic([claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}, 0).Entry())
@@ -2,9 +2,12 @@ package core
 
 import (
 	"bytes"
+	"database/sql/driver"
 	"errors"
+	"fmt"
 
 	"github.com/btcsuite/btcutil/base58"
+	common3 "github.com/iden3/go-iden3-core/common"
 	"github.com/iden3/go-iden3-core/merkletree"
 	"github.com/iden3/go-iden3-crypto/poseidon"
 )
@@ -18,6 +21,16 @@ var (
 	TypeBJP0 = [2]byte{0x00, 0x00}
 )
 
+var (
+	// ErrIDLength is used when the bytes of an ID don't have the ID length.
+	ErrIDLength = errors.New("IDFromBytes error: byte array incorrect length")
+	// ErrIDEmpty is used when the bytes of an ID are all zero.
+	ErrIDEmpty = errors.New("IDFromBytes error: byte array empty")
+	// ErrIDChecksum is used when the checksum of an ID doesn't match its
+	// type and genesis.
+	ErrIDChecksum = errors.New("IDFromBytes error: checksum error")
+)
+
 // ID is a byte array with
 // [  type  | root_genesis | checksum ]
 // [2 bytes |   27 bytes   | 2 bytes  ]
@@ -58,12 +71,50 @@ func (id ID) MarshalText() ([]byte, error) {
 	return []byte(id.String()), nil
 }
 
+// UnmarshalText parses the base58 representation of an ID.  id is not
+// modified if the text is not a valid ID.
 func (id *ID) UnmarshalText(b []byte) error {
+	idFromString, err := IDFromString(string(b))
+	if err != nil {
+		return err
+	}
+	*id = idFromString
+	return nil
+}
+
+// Hex returns the ID bytes in hex, with the 0x prefix.
+func (id *ID) Hex() string {
+	return common3.HexEncode(id[:])
+}
+
+// Value implements the driver.Valuer interface, so that the ID is stored in
+// SQL databases in its base58 representation.
+func (id ID) Value() (driver.Value, error) {
+	return id.String(), nil
+}
+
+// Scan implements the sql.Scanner interface.  It accepts the base58
+// representation of the ID as a string or []byte, and the raw ID bytes.
+func (id *ID) Scan(src interface{}) error {
+	var idScanned ID
 	var err error
-	var idFromString ID
-	idFromString, err = IDFromString(string(b))
-	copy(id[:], idFromString[:])
-	return err
+	switch v := src.(type) {
+	case string:
+		idScanned, err = IDFromString(v)
+	case []byte:
+		if len(v) == len(idScanned) {
+			idScanned, err = IDFromBytes(v)
+		} else {
+			idScanned, err = IDFromString(string(v))
+		}
+	default:
+		return fmt.Errorf("can't scan %T into an ID", src)
+	}
+	if err != nil {
+		return err
+	}
+	*id = idScanned
+	return nil
 }
 
 func (id1 *ID) Equals(id2 *ID) bool {
@@ -76,21 +127,31 @@ func IDFromString(s string) (ID, error) {
 	return IDFromBytes(b)
 }
 
+// IDFromHex returns the ID from its bytes in hex, with or without the 0x
+// prefix.
+func IDFromHex(s string) (ID, error) {
+	b, err := common3.HexDecode(s)
+	if err != nil {
+		return ID{}, err
+	}
+	return IDFromBytes(b)
+}
+
 var emptyID [31]byte
 
 // IDFromBytes returns the ID from a given byte array
 func IDFromBytes(b []byte) (ID, error) {
 	if len(b) != 31 {
-		return ID{}, errors.New("IDFromBytes error: byte array incorrect length")
+		return ID{}, ErrIDLength
 	}
 	if bytes.Equal(b, emptyID[:]) {
-		return ID{}, errors.New("IDFromBytes error: byte array empty")
+		return ID{}, ErrIDEmpty
 	}
 	var bId [31]byte
 	copy(bId[:], b[:])
 	id := ID(bId)
 	if !CheckChecksum(id) {
-		return ID{}, errors.New("IDFromBytes error: checksum error")
+		return ID{}, ErrIDChecksum
 	}
 	return id, nil
 }
@@ -84,6 +84,50 @@ func TestIDjsonParser(t *testing.T) {
 	assert.Nil(t, err)
 }
 
+func TestIDHex(t *testing.T) {
+	id, err := IDFromString(testgen.GetTestValue("idStringInput").(string))
+	assert.Nil(t, err)
+	idHex := id.Hex()
+	assert.True(t, strings.HasPrefix(idHex, "0x"))
+	id2, err := IDFromHex(idHex)
+	assert.Nil(t, err)
+	assert.Equal(t, id, id2)
+	id2, err = IDFromHex(strings.TrimPrefix(idHex, "0x"))
+	assert.Nil(t, err)
+	assert.Equal(t, id, id2)
+
+	_, err = IDFromHex(idHex[:len(idHex)-2])
+	assert.Equal(t, ErrIDLength, err)
+	_, err = IDFromHex(idHex[:len(idHex)-1] + "f")
+	assert.Equal(t, ErrIDChecksum, err)
+	_, err = IDFromHex("0xzz")
+	assert.NotNil(t, err)
+}
+
+func TestIDValueScan(t *testing.T) {
+	id, err := IDFromString(testgen.GetTestValue("idStringInput").(string))
+	assert.Nil(t, err)
+	v, err := id.Value()
+	assert.Nil(t, err)
+	assert.Equal(t, id.String(), v)
+
+	for _, src := range []interface{}{v, []byte(id.String()), id.Bytes()} {
+		var id2 ID
+		assert.Nil(t, id2.Scan(src))
+		assert.Equal(t, id, id2)
+	}
+
+	// A failed Scan or UnmarshalText doesn't modify the ID
+	id2 := id
+	assert.NotNil(t, id2.Scan(nil))
+	assert.NotNil(t, id2.Scan(int64(1)))
+	assert.Equal(t, ErrIDLength, id2.Scan("invalid"))
+	var empty [31]byte
+	assert.Equal(t, ErrIDEmpty, id2.Scan(empty[:]))
+	assert.NotNil(t, id2.UnmarshalText([]byte("invalid")))
+	assert.Equal(t, id, id2)
+}
+
 func TestCheckChecksum(t *testing.T) {
 	typ := TypeBJP0
 	var genesis [27]byte
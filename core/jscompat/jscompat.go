@@ -0,0 +1,135 @@
+// Package jscompat serializes the identities, claims, proofs and credentials
+// in the JSON layouts used by the JavaScript implementations (iden3js and
+// js-sdk).
+//
+// IDs, claims (merkletree.Entry), hashes and merkle tree proofs already use
+// the same encoding in both implementations: base58 for IDs and 0x prefixed
+// hex for the rest.  The credentials differ, as the Go structs have no json
+// tags, so this package provides the JavaScript layout for them with
+// camelCase keys.
+package jscompat
+
+import (
+	"encoding/json"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// IdenStateData is the JavaScript layout of proof.IdenStateData.
+type IdenStateData struct {
+	BlockN         uint64           `json:"blockN"`
+	BlockTimestamp int64            `json:"blockTimestamp"`
+	IdenState      *merkletree.Hash `json:"idenState"`
+}
+
+// NewIdenStateData converts a proof.IdenStateData to its JavaScript layout.
+func NewIdenStateData(d *proof.IdenStateData) IdenStateData {
+	return IdenStateData{BlockN: d.BlockN, BlockTimestamp: d.BlockTs, IdenState: d.IdenState}
+}
+
+// IdenStateData converts d to a proof.IdenStateData.
+func (d *IdenStateData) IdenStateData() proof.IdenStateData {
+	return proof.IdenStateData{BlockN: d.BlockN, BlockTs: d.BlockTimestamp, IdenState: d.IdenState}
+}
+
+// CredentialExistence is the JavaScript layout of proof.CredentialExistence.
+type CredentialExistence struct {
+	Id              *core.ID          `json:"id"`
+	IdenStateData   IdenStateData     `json:"idenStateData"`
+	MtpClaim        *merkletree.Proof `json:"mtpClaim"`
+	Claim           *merkletree.Entry `json:"claim"`
+	RevocationsRoot *merkletree.Hash  `json:"revocationsRoot"`
+	RootsRoot       *merkletree.Hash  `json:"rootsRoot"`
+	IdPubUrl        string            `json:"idPubUrl"`
+}
+
+// NewCredentialExistence converts a proof.CredentialExistence to its
+// JavaScript layout.
+func NewCredentialExistence(c *proof.CredentialExistence) *CredentialExistence {
+	return &CredentialExistence{
+		Id:              c.Id,
+		IdenStateData:   NewIdenStateData(&c.IdenStateData),
+		MtpClaim:        c.MtpClaim,
+		Claim:           c.Claim,
+		RevocationsRoot: c.RevocationsRoot,
+		RootsRoot:       c.RootsRoot,
+		IdPubUrl:        c.IdPubUrl,
+	}
+}
+
+// CredentialExistence converts c to a proof.CredentialExistence.
+func (c *CredentialExistence) CredentialExistence() *proof.CredentialExistence {
+	return &proof.CredentialExistence{
+		Id:              c.Id,
+		IdenStateData:   c.IdenStateData.IdenStateData(),
+		MtpClaim:        c.MtpClaim,
+		Claim:           c.Claim,
+		RevocationsRoot: c.RevocationsRoot,
+		RootsRoot:       c.RootsRoot,
+		IdPubUrl:        c.IdPubUrl,
+	}
+}
+
+// CredentialValidity is the JavaScript layout of proof.CredentialValidity.
+type CredentialValidity struct {
+	CredentialExistence CredentialExistence `json:"credentialExistence"`
+	IdenStateData       IdenStateData       `json:"idenStateData"`
+	MtpNotNonce         *merkletree.Proof   `json:"mtpNotNonce"`
+	ClaimsRoot          *merkletree.Hash    `json:"claimsRoot"`
+	RootsRoot           *merkletree.Hash    `json:"rootsRoot"`
+}
+
+// NewCredentialValidity converts a proof.CredentialValidity to its
+// JavaScript layout.
+func NewCredentialValidity(c *proof.CredentialValidity) *CredentialValidity {
+	return &CredentialValidity{
+		CredentialExistence: *NewCredentialExistence(&c.CredentialExistence),
+		IdenStateData:       NewIdenStateData(&c.IdenStateData),
+		MtpNotNonce:         c.MtpNotNonce,
+		ClaimsRoot:          c.ClaimsRoot,
+		RootsRoot:           c.RootsRoot,
+	}
+}
+
+// CredentialValidity converts c to a proof.CredentialValidity.
+func (c *CredentialValidity) CredentialValidity() *proof.CredentialValidity {
+	return &proof.CredentialValidity{
+		CredentialExistence: *c.CredentialExistence.CredentialExistence(),
+		IdenStateData:       c.IdenStateData.IdenStateData(),
+		MtpNotNonce:         c.MtpNotNonce,
+		ClaimsRoot:          c.ClaimsRoot,
+		RootsRoot:           c.RootsRoot,
+	}
+}
+
+// MarshalCredentialExistence serializes c in the JavaScript layout.
+func MarshalCredentialExistence(c *proof.CredentialExistence) ([]byte, error) {
+	return json.Marshal(NewCredentialExistence(c))
+}
+
+// UnmarshalCredentialExistence deserializes a CredentialExistence in the
+// JavaScript layout.
+func UnmarshalCredentialExistence(b []byte) (*proof.CredentialExistence, error) {
+	var c CredentialExistence
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return c.CredentialExistence(), nil
+}
+
+// MarshalCredentialValidity serializes c in the JavaScript layout.
+func MarshalCredentialValidity(c *proof.CredentialValidity) ([]byte, error) {
+	return json.Marshal(NewCredentialValidity(c))
+}
+
+// UnmarshalCredentialValidity deserializes a CredentialValidity in the
+// JavaScript layout.
+func UnmarshalCredentialValidity(b []byte) (*proof.CredentialValidity, error) {
+	var c CredentialValidity
+	if err := json.Unmarshal(b, &c); err != nil {
+		return nil, err
+	}
+	return c.CredentialValidity(), nil
+}
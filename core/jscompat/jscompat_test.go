@@ -0,0 +1,149 @@
+package jscompat
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// If generateTest is true, the fixtures in testVectors will be overwritten
+// with the serialization of the Go implementation.
+var generateTest = false
+
+// primitives are the values that already share the encoding with the
+// JavaScript implementation.
+type primitives struct {
+	Id    *core.ID          `json:"id"`
+	Claim *merkletree.Entry `json:"claim"`
+	Mtp   *merkletree.Proof `json:"mtp"`
+	Root  *merkletree.Hash  `json:"root"`
+}
+
+// newCredentials builds deterministic credentials of a claim in the claims
+// tree of an identity.
+func newCredentials(t *testing.T) (*primitives, *proof.CredentialExistence, *proof.CredentialValidity) {
+	clt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	ret, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	rot, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	for i := 0; i < 4; i++ {
+		var indexSlot [claims.IndexSlotBytes]byte
+		var dataSlot [claims.DataSlotBytes]byte
+		indexSlot[0], dataSlot[0] = byte(i), byte(i+1)
+		require.Nil(t, clt.AddClaim(claims.NewClaimBasic(indexSlot, dataSlot, uint32(i))))
+	}
+	require.Nil(t, claims.AddLeafRootsTree(rot, clt.RootKey()))
+	idenState := core.IdenState(clt.RootKey(), ret.RootKey(), rot.RootKey())
+	id := core.IdGenesisFromIdenState(idenState)
+
+	var indexSlot [claims.IndexSlotBytes]byte
+	var dataSlot [claims.DataSlotBytes]byte
+	indexSlot[0], dataSlot[0] = 2, 3
+	claim := claims.NewClaimBasic(indexSlot, dataSlot, 2).Entry()
+	mtp, err := clt.GenerateProof(claim.HIndex(), nil)
+	require.Nil(t, err)
+	mtpNotNonce, err := ret.GenerateProof(&merkletree.Hash{2}, nil)
+	require.Nil(t, err)
+
+	stateData := proof.IdenStateData{BlockN: 42, BlockTs: 1580000000, IdenState: idenState}
+	credExist := &proof.CredentialExistence{
+		Id:              id,
+		IdenStateData:   stateData,
+		MtpClaim:        mtp,
+		Claim:           claim,
+		RevocationsRoot: ret.RootKey(),
+		RootsRoot:       rot.RootKey(),
+		IdPubUrl:        "https://iden.example.com/api/unstable",
+	}
+	credValid := &proof.CredentialValidity{
+		CredentialExistence: *credExist,
+		IdenStateData:       stateData,
+		MtpNotNonce:         mtpNotNonce,
+		ClaimsRoot:          clt.RootKey(),
+		RootsRoot:           rot.RootKey(),
+	}
+	return &primitives{Id: id, Claim: claim, Mtp: mtp, Root: clt.RootKey()}, credExist, credValid
+}
+
+// checkFixture compares the JSON in b with the fixture name, or writes it
+// when generateTest is set.
+func checkFixture(t *testing.T, name string, b []byte) []byte {
+	fileName := path.Join("testVectors", name+".json")
+	if generateTest {
+		var out interface{}
+		require.Nil(t, json.Unmarshal(b, &out))
+		pretty, err := json.MarshalIndent(out, "", "  ")
+		require.Nil(t, err)
+		require.Nil(t, ioutil.WriteFile(fileName, append(pretty, '\n'), 0644))
+	}
+	fixture, err := ioutil.ReadFile(fileName)
+	require.Nil(t, err)
+	assert.JSONEq(t, string(fixture), string(b))
+	return fixture
+}
+
+func TestPrimitives(t *testing.T) {
+	p, _, _ := newCredentials(t)
+	b, err := json.Marshal(p)
+	require.Nil(t, err)
+	fixture := checkFixture(t, "primitives", b)
+
+	var p2 primitives
+	require.Nil(t, json.Unmarshal(fixture, &p2))
+	assert.Equal(t, p.Id, p2.Id)
+	assert.Equal(t, p.Claim.Data, p2.Claim.Data)
+	assert.Equal(t, p.Mtp.Bytes(), p2.Mtp.Bytes())
+	assert.Equal(t, p.Root, p2.Root)
+	assert.True(t, merkletree.VerifyProof(p2.Root, p2.Mtp, p2.Claim.HIndex(), p2.Claim.HValue()))
+}
+
+func TestCredentialExistence(t *testing.T) {
+	_, credExist, _ := newCredentials(t)
+	b, err := MarshalCredentialExistence(credExist)
+	require.Nil(t, err)
+	fixture := checkFixture(t, "credentialExistence", b)
+
+	credExist2, err := UnmarshalCredentialExistence(fixture)
+	require.Nil(t, err)
+	assert.Equal(t, credExist.Id, credExist2.Id)
+	assert.Equal(t, credExist.IdenStateData, credExist2.IdenStateData)
+	assert.Equal(t, credExist.Claim.Data, credExist2.Claim.Data)
+	assert.Equal(t, credExist.MtpClaim.Bytes(), credExist2.MtpClaim.Bytes())
+	assert.Equal(t, credExist.RevocationsRoot, credExist2.RevocationsRoot)
+	assert.Equal(t, credExist.RootsRoot, credExist2.RootsRoot)
+	assert.Equal(t, credExist.IdPubUrl, credExist2.IdPubUrl)
+
+	b2, err := MarshalCredentialExistence(credExist2)
+	require.Nil(t, err)
+	assert.JSONEq(t, string(fixture), string(b2))
+}
+
+func TestCredentialValidity(t *testing.T) {
+	_, _, credValid := newCredentials(t)
+	b, err := MarshalCredentialValidity(credValid)
+	require.Nil(t, err)
+	fixture := checkFixture(t, "credentialValidity", b)
+
+	credValid2, err := UnmarshalCredentialValidity(fixture)
+	require.Nil(t, err)
+	assert.Equal(t, credValid.CredentialExistence.Id, credValid2.CredentialExistence.Id)
+	assert.Equal(t, credValid.IdenStateData, credValid2.IdenStateData)
+	assert.Equal(t, credValid.MtpNotNonce.Bytes(), credValid2.MtpNotNonce.Bytes())
+	assert.Equal(t, credValid.ClaimsRoot, credValid2.ClaimsRoot)
+	assert.Equal(t, credValid.RootsRoot, credValid2.RootsRoot)
+
+	b2, err := MarshalCredentialValidity(credValid2)
+	require.Nil(t, err)
+	assert.JSONEq(t, string(fixture), string(b2))
+}
@@ -0,0 +1,181 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	// ErrBlindedSlotUnverifiable is used when a slot is revealed while
+	// other slots of the index or the value of the claim are hidden.
+	ErrBlindedSlotUnverifiable = errors.New("a slot can't be revealed when other slots of its index or value are hidden")
+	// ErrInvalidBlindedClaim is used when a BlindedClaim has a half that
+	// is neither fully revealed nor committed by its hash.
+	ErrInvalidBlindedClaim = errors.New("invalid blinded claim")
+)
+
+// BlindedClaim is a claim presented with some of its data slots hidden.  The
+// index and the value of a claim are each hashed at once (see
+// merkletree.Entry.HIndex and HValue), so they can't be opened slot by slot:
+// a half with hidden slots is presented by its hash, which commits the holder
+// to the hidden slots and is checked by the verifier against the merkle tree
+// proof of the claim, while the hash of a fully revealed half is calculated
+// by the verifier.  The hash of a half only hides slots with enough entropy:
+// the slots of a hidden half with few possible values can be found by brute
+// force.
+type BlindedClaim struct {
+	// Slots are the data slots of the claim, nil for the hidden ones.
+	Slots [merkletree.DataLen]*merkletree.ElemBytes
+	// HIndex is the hash of the index, set only if it's hidden.
+	HIndex *merkletree.Hash `json:",omitempty"`
+	// HValue is the hash of the value, set only if it's hidden.
+	HValue *merkletree.Hash `json:",omitempty"`
+}
+
+// NewBlindedClaim returns claim with only the slots revealed, by position in
+// claim.Data.  All the slots of a half must be revealed or hidden together,
+// or ErrBlindedSlotUnverifiable is returned.
+func NewBlindedClaim(claim *merkletree.Entry, revealed ...int) (*BlindedClaim, error) {
+	var reveal [merkletree.DataLen]bool
+	for _, i := range revealed {
+		if i < 0 || i >= merkletree.DataLen {
+			return nil, ErrInvalidBlindedClaim
+		}
+		reveal[i] = true
+	}
+	var bc BlindedClaim
+	for half := 0; half < merkletree.DataLen; half += merkletree.IndexLen {
+		n := 0
+		for i := half; i < half+merkletree.IndexLen; i++ {
+			if reveal[i] {
+				n++
+			}
+		}
+		switch n {
+		case 0:
+			if half == 0 {
+				bc.HIndex = claim.HIndex()
+			} else {
+				bc.HValue = claim.HValue()
+			}
+		case merkletree.IndexLen:
+			for i := half; i < half+merkletree.IndexLen; i++ {
+				slot := claim.Data[i]
+				bc.Slots[i] = &slot
+			}
+		default:
+			return nil, ErrBlindedSlotUnverifiable
+		}
+	}
+	return &bc, nil
+}
+
+// Slot returns the slot i of the claim, or false if it's hidden.
+func (bc *BlindedClaim) Slot(i int) (*merkletree.ElemBytes, bool) {
+	if i < 0 || i >= merkletree.DataLen || bc.Slots[i] == nil {
+		return nil, false
+	}
+	return bc.Slots[i], true
+}
+
+// halfHash returns the hash of the half of the slots starting at half, which
+// is either fully revealed or committed by hash.
+func (bc *BlindedClaim) halfHash(half int, hash *merkletree.Hash) (*merkletree.Hash, error) {
+	slots := bc.Slots[half : half+merkletree.IndexLen]
+	n := 0
+	for _, slot := range slots {
+		if slot != nil {
+			n++
+		}
+	}
+	switch {
+	case n == 0 && hash != nil && hash.InField():
+		return hash, nil
+	case n == len(slots) && hash == nil:
+		elems := make([]merkletree.ElemBytes, len(slots))
+		for i, slot := range slots {
+			if !(*merkletree.Hash)(slot).InField() {
+				return nil, ErrInvalidBlindedClaim
+			}
+			elems[i] = *slot
+		}
+		return merkletree.HashElems(elems...), nil
+	default:
+		return nil, ErrInvalidBlindedClaim
+	}
+}
+
+// Hashes returns the HIndex and HValue of the claim, from the revealed slots
+// or the hashes of the hidden halves.
+func (bc *BlindedClaim) Hashes() (hIndex, hValue *merkletree.Hash, err error) {
+	if hIndex, err = bc.halfHash(0, bc.HIndex); err != nil {
+		return nil, nil, err
+	}
+	if hValue, err = bc.halfHash(merkletree.IndexLen, bc.HValue); err != nil {
+		return nil, nil, err
+	}
+	return hIndex, hValue, nil
+}
+
+// BlindedCredentialExistence is a CredentialExistence whose claim has hidden
+// slots.  The claim can't be checked for revocation, as the proof of
+// non-revocation requires its revocation nonce, so only its existence is
+// proven.
+type BlindedCredentialExistence struct {
+	Id              *core.ID
+	IdenStateData   IdenStateData
+	MtpClaim        *merkletree.Proof
+	Claim           *BlindedClaim
+	RevocationsRoot *merkletree.Hash
+	RootsRoot       *merkletree.Hash
+	IdPubUrl        string
+}
+
+// Blind returns the credential with only the slots revealed of its claim (see
+// NewBlindedClaim).
+func (ce *CredentialExistence) Blind(revealed ...int) (*BlindedCredentialExistence, error) {
+	if ce.Claim == nil {
+		return nil, ErrInvalidCredential
+	}
+	claim, err := NewBlindedClaim(ce.Claim, revealed...)
+	if err != nil {
+		return nil, err
+	}
+	return &BlindedCredentialExistence{
+		Id:              ce.Id,
+		IdenStateData:   ce.IdenStateData,
+		MtpClaim:        ce.MtpClaim,
+		Claim:           claim,
+		RevocationsRoot: ce.RevocationsRoot,
+		RootsRoot:       ce.RootsRoot,
+		IdPubUrl:        ce.IdPubUrl,
+	}, nil
+}
+
+// IsGenesis returns true if the credential is anchored at the genesis
+// identity state of its Id, like CredentialExistence.IsGenesis.
+func (bce *BlindedCredentialExistence) IsGenesis() bool {
+	if bce.Id == nil || bce.IdenStateData.IdenState == nil ||
+		bce.IdenStateData.BlockN != 0 || bce.IdenStateData.BlockTs != 0 {
+		return false
+	}
+	return bytes.Equal(bce.Id[:], core.IdGenesisFromIdenState(bce.IdenStateData.IdenState)[:])
+}
+
+// Validate checks the credential like CredentialExistence.Validate, and that
+// each half of the claim is either revealed or committed by its hash.
+func (bce *BlindedCredentialExistence) Validate() error {
+	if bce.Id == nil || bce.MtpClaim == nil || bce.Claim == nil {
+		return ErrInvalidCredential
+	}
+	if _, _, err := bce.Claim.Hashes(); err != nil {
+		return ErrInvalidCredential
+	}
+	if !hashesInField(bce.IdenStateData.IdenState, bce.RevocationsRoot, bce.RootsRoot) {
+		return ErrInvalidCredential
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package proof
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlindedClaim(t *testing.T) {
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0], dataBytes[0] = 0x42, 0x43
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 0).Entry()
+
+	// Revealing the index hides the value behind its hash.
+	bc, err := NewBlindedClaim(claim, 0, 1, 2, 3)
+	require.Nil(t, err)
+	for i := 0; i < merkletree.DataLen; i++ {
+		slot, ok := bc.Slot(i)
+		if i < merkletree.IndexLen {
+			require.True(t, ok)
+			assert.Equal(t, claim.Data[i], *slot)
+		} else {
+			assert.False(t, ok)
+		}
+	}
+	assert.Nil(t, bc.HIndex)
+	assert.Equal(t, claim.HValue(), bc.HValue)
+	hIndex, hValue, err := bc.Hashes()
+	require.Nil(t, err)
+	assert.Equal(t, claim.HIndex(), hIndex)
+	assert.Equal(t, claim.HValue(), hValue)
+
+	// The blinded claim survives a JSON round trip.
+	bcJSON, err := json.Marshal(bc)
+	require.Nil(t, err)
+	var bc2 BlindedClaim
+	require.Nil(t, json.Unmarshal(bcJSON, &bc2))
+	assert.Equal(t, bc, &bc2)
+
+	// Fully hidden.
+	bc, err = NewBlindedClaim(claim)
+	require.Nil(t, err)
+	hIndex, hValue, err = bc.Hashes()
+	require.Nil(t, err)
+	assert.Equal(t, claim.HIndex(), hIndex)
+	assert.Equal(t, claim.HValue(), hValue)
+
+	// A half can't be partially revealed.
+	_, err = NewBlindedClaim(claim, 0, 1, 2, 3, 4)
+	assert.Equal(t, ErrBlindedSlotUnverifiable, err)
+	_, err = NewBlindedClaim(claim, merkletree.DataLen)
+	assert.Equal(t, ErrInvalidBlindedClaim, err)
+
+	// A half both revealed and committed by hash, or neither.
+	bc, err = NewBlindedClaim(claim, 0, 1, 2, 3)
+	require.Nil(t, err)
+	bc.HIndex = claim.HIndex()
+	_, _, err = bc.Hashes()
+	assert.Equal(t, ErrInvalidBlindedClaim, err)
+	bc.HIndex, bc.HValue = nil, nil
+	_, _, err = bc.Hashes()
+	assert.Equal(t, ErrInvalidBlindedClaim, err)
+
+	// A revealed slot out of the Finite Field.
+	bc, err = NewBlindedClaim(claim, 0, 1, 2, 3)
+	require.Nil(t, err)
+	bc.Slots[1] = &merkletree.ElemBytes{}
+	bc.Slots[1][31] = 0xff
+	_, _, err = bc.Hashes()
+	assert.Equal(t, ErrInvalidBlindedClaim, err)
+}
+
+func TestBlindedCredentialExistence(t *testing.T) {
+	clt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 0).Entry()
+	require.Nil(t, clt.AddEntry(claim))
+	mtp, err := clt.GenerateProof(claim.HIndex(), nil)
+	require.Nil(t, err)
+	// The revocations and roots trees are empty.
+	idenState := core.IdenState(clt.RootKey(), &merkletree.HashZero, &merkletree.HashZero)
+	cred := &CredentialExistence{
+		Id:              core.IdGenesisFromIdenState(idenState),
+		IdenStateData:   IdenStateData{IdenState: idenState},
+		MtpClaim:        mtp,
+		Claim:           claim,
+		RevocationsRoot: &merkletree.HashZero,
+		RootsRoot:       &merkletree.HashZero,
+	}
+
+	bce, err := cred.Blind(4, 5, 6, 7)
+	require.Nil(t, err)
+	require.Nil(t, bce.Validate())
+	assert.True(t, bce.IsGenesis())
+	assert.Equal(t, cred.IsGenesis(), bce.IsGenesis())
+
+	// The blinded claim is in the claims tree of the identity state.
+	hIndex, hValue, err := bce.Claim.Hashes()
+	require.Nil(t, err)
+	claimsRoot, err := merkletree.RootFromProof(bce.MtpClaim, hIndex, hValue)
+	require.Nil(t, err)
+	assert.Equal(t, clt.RootKey(), claimsRoot)
+
+	_, err = cred.Blind(3, 4, 5, 6, 7)
+	assert.Equal(t, ErrBlindedSlotUnverifiable, err)
+
+	bce.Claim.HIndex = nil
+	assert.Equal(t, ErrInvalidCredential, bce.Validate())
+}
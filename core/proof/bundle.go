@@ -0,0 +1,181 @@
+package proof
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// SigPrefixPresentationBundle is the prefix of the message signed by the
+// holder of a PresentationBundle.
+var SigPrefixPresentationBundle = []byte("presentationbundle:")
+
+var (
+	// ErrPresentationCredentialEmpty is used when a PresentationCredential
+	// doesn't have exactly one of an existence, a validity or a blinded
+	// existence credential.
+	ErrPresentationCredentialEmpty = errors.New("the presented credential must be either of existence, of validity or blinded")
+	// ErrPresentationBundleEmpty is used when a PresentationBundle has no
+	// credentials.
+	ErrPresentationBundleEmpty = errors.New("the presentation bundle has no credentials")
+)
+
+// PresentationCredential is a credential in a PresentationBundle.  Exactly
+// one of Existence, Validity and Blinded is set.
+type PresentationCredential struct {
+	Existence *CredentialExistence        `json:",omitempty"`
+	Validity  *CredentialValidity         `json:",omitempty"`
+	Blinded   *BlindedCredentialExistence `json:",omitempty"`
+}
+
+// Valid returns true if exactly one credential is set.
+func (pc *PresentationCredential) Valid() bool {
+	n := 0
+	if pc.Existence != nil {
+		n++
+	}
+	if pc.Validity != nil {
+		n++
+	}
+	if pc.Blinded != nil {
+		n++
+	}
+	return n == 1
+}
+
+// Issuer returns the identity that issued the credential.
+func (pc *PresentationCredential) Issuer() *core.ID {
+	if pc.Validity != nil {
+		return pc.Validity.CredentialExistence.Id
+	}
+	if pc.Blinded != nil {
+		return pc.Blinded.Id
+	}
+	return pc.Existence.Id
+}
+
+// CredentialExistence returns the existence credential, which is part of
+// the validity credential if the presented credential is of validity, or nil
+// if the presented credential is blinded.
+func (pc *PresentationCredential) CredentialExistence() *CredentialExistence {
+	if pc.Validity != nil {
+		return &pc.Validity.CredentialExistence
+	}
+	return pc.Existence
+}
+
+// PresentationBundle is a set of credentials, possibly from different
+// issuers, presented together by a holder to a verifier.  The holder signs
+// the credentials with a key authorized by the existence credential
+// CredKSign, binding them to the Challenge of the verifier, so that the
+// bundle can't be replayed to another verifier nor modified.
+type PresentationBundle struct {
+	// Holder is the identity that presents the credentials.
+	Holder *core.ID
+	// Challenge is the value chosen by the verifier that the bundle
+	// responds to.
+	Challenge []byte
+	// Request is the request of the verifier that the bundle responds
+	// to, if the Challenge is the one of a ProofRequest.
+	Request *ProofRequest `json:",omitempty"`
+	// Credentials are the presented credentials.
+	Credentials []PresentationCredential
+	// CredKSign is the existence credential of the
+	// ClaimAuthorizeKSignBabyJub of the key used to sign, issued by
+	// Holder.
+	CredKSign *CredentialExistence
+	// Signature is the signature of SigMsg by the holder.
+	Signature *babyjub.SignatureComp
+}
+
+// SigMsg returns the message signed by the holder (after the
+// SigPrefixPresentationBundle): the hash of the holder, the challenge and
+// the credentials.
+func (b *PresentationBundle) SigMsg() ([]byte, error) {
+	msg, err := json.Marshal(struct {
+		Holder      *core.ID
+		Challenge   []byte
+		Credentials []PresentationCredential
+	}{b.Holder, b.Challenge, b.Credentials})
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(msg)
+	return h[:], nil
+}
+
+// Signer signs binary messages on behalf of an identity, satisfied by
+// issuer.Issuer.
+type Signer interface {
+	ID() *core.ID
+	SignBinary(prefix, msg []byte) (*babyjub.SignatureComp, error)
+}
+
+// PresentationBundleBuilder builds a PresentationBundle by adding the
+// credentials one by one.
+type PresentationBundleBuilder struct {
+	bundle PresentationBundle
+}
+
+// NewPresentationBundleBuilder creates a new builder of a PresentationBundle
+// responding to the challenge of the verifier.
+func NewPresentationBundleBuilder(challenge []byte) *PresentationBundleBuilder {
+	return &PresentationBundleBuilder{bundle: PresentationBundle{
+		Challenge:   append([]byte{}, challenge...),
+		Credentials: []PresentationCredential{},
+	}}
+}
+
+// NewPresentationBundleBuilderForRequest creates a new builder of a
+// PresentationBundle responding to the request of the verifier.
+func NewPresentationBundleBuilderForRequest(req *ProofRequest) (*PresentationBundleBuilder, error) {
+	challenge, err := req.Challenge()
+	if err != nil {
+		return nil, err
+	}
+	bb := NewPresentationBundleBuilder(challenge)
+	reqCopy := *req
+	bb.bundle.Request = &reqCopy
+	return bb, nil
+}
+
+// AddExistence adds an existence credential to the bundle.
+func (bb *PresentationBundleBuilder) AddExistence(credExist *CredentialExistence) *PresentationBundleBuilder {
+	bb.bundle.Credentials = append(bb.bundle.Credentials, PresentationCredential{Existence: credExist})
+	return bb
+}
+
+// AddValidity adds a validity credential to the bundle.
+func (bb *PresentationBundleBuilder) AddValidity(credValid *CredentialValidity) *PresentationBundleBuilder {
+	bb.bundle.Credentials = append(bb.bundle.Credentials, PresentationCredential{Validity: credValid})
+	return bb
+}
+
+// AddBlinded adds a blinded existence credential to the bundle.
+func (bb *PresentationBundleBuilder) AddBlinded(credBlinded *BlindedCredentialExistence) *PresentationBundleBuilder {
+	bb.bundle.Credentials = append(bb.bundle.Credentials, PresentationCredential{Blinded: credBlinded})
+	return bb
+}
+
+// Sign returns the bundle of the added credentials signed by signer with the
+// key authorized by credKSign.
+func (bb *PresentationBundleBuilder) Sign(signer Signer, credKSign *CredentialExistence) (*PresentationBundle, error) {
+	if len(bb.bundle.Credentials) == 0 {
+		return nil, ErrPresentationBundleEmpty
+	}
+	bundle := bb.bundle
+	bundle.Credentials = append([]PresentationCredential{}, bb.bundle.Credentials...)
+	bundle.Holder = signer.ID()
+	bundle.CredKSign = credKSign
+	msg, err := bundle.SigMsg()
+	if err != nil {
+		return nil, err
+	}
+	if bundle.Signature, err = signer.SignBinary(SigPrefixPresentationBundle, msg); err != nil {
+		return nil, err
+	}
+	return &bundle, nil
+}
@@ -0,0 +1,85 @@
+package proof
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type bundleSigner struct {
+	id     core.ID
+	prefix []byte
+	msg    []byte
+}
+
+func (s *bundleSigner) ID() *core.ID { return &s.id }
+
+func (s *bundleSigner) SignBinary(prefix, msg []byte) (*babyjub.SignatureComp, error) {
+	s.prefix, s.msg = prefix, msg
+	return &babyjub.SignatureComp{}, nil
+}
+
+func TestPresentationBundleBuilder(t *testing.T) {
+	issuerA := core.NewID(core.TypeBJP0, [27]byte{1})
+	issuerB := core.NewID(core.TypeBJP0, [27]byte{2})
+	credA := &CredentialExistence{Id: &issuerA, Claim: &merkletree.Entry{}}
+	credB := &CredentialValidity{CredentialExistence: CredentialExistence{Id: &issuerB, Claim: &merkletree.Entry{}}}
+
+	_, err := NewPresentationBundleBuilder([]byte("challenge")).Sign(&bundleSigner{}, nil)
+	assert.Equal(t, ErrPresentationBundleEmpty, err)
+
+	signer := &bundleSigner{id: core.NewID(core.TypeBJP0, [27]byte{3})}
+	bb := NewPresentationBundleBuilder([]byte("challenge")).AddExistence(credA).AddValidity(credB)
+	bundle, err := bb.Sign(signer, credA)
+	require.Nil(t, err)
+
+	assert.Equal(t, &signer.id, bundle.Holder)
+	assert.Equal(t, []byte("challenge"), bundle.Challenge)
+	require.Equal(t, 2, len(bundle.Credentials))
+	assert.Equal(t, &issuerA, bundle.Credentials[0].Issuer())
+	assert.Equal(t, credA, bundle.Credentials[0].CredentialExistence())
+	assert.Equal(t, &issuerB, bundle.Credentials[1].Issuer())
+	assert.Equal(t, &credB.CredentialExistence, bundle.Credentials[1].CredentialExistence())
+
+	msg, err := bundle.SigMsg()
+	require.Nil(t, err)
+	assert.Equal(t, SigPrefixPresentationBundle, signer.prefix)
+	assert.Equal(t, msg, signer.msg)
+
+	// The message depends on the challenge and the credentials
+	other := *bundle
+	other.Challenge = []byte("other")
+	otherMsg, err := other.SigMsg()
+	require.Nil(t, err)
+	assert.NotEqual(t, msg, otherMsg)
+	other = *bundle
+	other.Credentials = other.Credentials[:1]
+	otherMsg, err = other.SigMsg()
+	require.Nil(t, err)
+	assert.NotEqual(t, msg, otherMsg)
+
+	// Adding credentials to the builder doesn't modify the signed bundle
+	bb.AddExistence(credA)
+	assert.Equal(t, 2, len(bundle.Credentials))
+}
+
+func TestPresentationBundleBuilderForRequest(t *testing.T) {
+	req := &ProofRequest{Audience: "verifier", Nonce: []byte("nonce"), IssuedTs: 1, ExpirationTs: 2}
+	challenge, err := req.Challenge()
+	require.Nil(t, err)
+	bb, err := NewPresentationBundleBuilderForRequest(req)
+	require.Nil(t, err)
+	assert.Equal(t, challenge, bb.bundle.Challenge)
+	assert.Equal(t, req, bb.bundle.Request)
+
+	// Any change of the request changes the challenge.
+	other := *req
+	other.Audience = "other"
+	otherChallenge, err := other.Challenge()
+	require.Nil(t, err)
+	assert.NotEqual(t, challenge, otherChallenge)
+}
@@ -0,0 +1,68 @@
+package proof
+
+import (
+	"crypto/sha256"
+	"errors"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// SigPrefixOwnership is the prefix of the message signed by the identity of
+// an OwnershipProof.
+var SigPrefixOwnership = []byte("ownership:")
+
+var (
+	// ErrOwnershipNonceEmpty is used when an OwnershipProof is created for
+	// an empty nonce, which could be replayed to any third party.
+	ErrOwnershipNonceEmpty = errors.New("the nonce of the ownership proof is empty")
+)
+
+// OwnershipProof proves to a third party, like an exchange or a registrar,
+// that the sender controls the identity Id.  The identity signs the Nonce
+// chosen by the third party with a key authorized by the existence credential
+// CredKSign, which anchors the key to an identity state on chain (its
+// IdenStateData), so that the proof can't be replayed to another third party
+// nor to the same one later.
+type OwnershipProof struct {
+	// Id is the identity whose control is proven.
+	Id *core.ID
+	// Nonce is the value chosen by the third party that the proof
+	// responds to.
+	Nonce []byte
+	// CredKSign is the existence credential of the
+	// ClaimAuthorizeKSignBabyJub of the key used to sign, issued by Id.
+	CredKSign *CredentialExistence
+	// Signature is the signature of SigMsg by the identity.
+	Signature *babyjub.SignatureComp
+}
+
+// SigMsg returns the message signed by the identity (after the
+// SigPrefixOwnership): the hash of the identity and the nonce.
+func (op *OwnershipProof) SigMsg() []byte {
+	h := sha256.New()
+	if op.Id != nil {
+		h.Write(op.Id[:])
+	}
+	h.Write(op.Nonce)
+	return h.Sum(nil)
+}
+
+// NewOwnershipProof returns the OwnershipProof of the identity of signer
+// responding to nonce, signed with the key authorized by credKSign.
+func NewOwnershipProof(signer Signer, nonce []byte, credKSign *CredentialExistence) (*OwnershipProof, error) {
+	if len(nonce) == 0 {
+		return nil, ErrOwnershipNonceEmpty
+	}
+	op := &OwnershipProof{
+		Id:        signer.ID(),
+		Nonce:     append([]byte{}, nonce...),
+		CredKSign: credKSign,
+	}
+	sig, err := signer.SignBinary(SigPrefixOwnership, op.SigMsg())
+	if err != nil {
+		return nil, err
+	}
+	op.Signature = sig
+	return op, nil
+}
@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
-	"time"
 
 	// common3 "github.com/iden3/go-iden3-core/common"
 	"github.com/iden3/go-iden3-core/core"
@@ -15,162 +14,11 @@ import (
 
 var (
 	ErrRevokedClaim = errors.New("the claim is revoked: the next version exists")
+	// ErrInvalidCredential is used when a credential lacks a field required
+	// to verify it, or has a value outside the Finite Field.
+	ErrInvalidCredential = errors.New("the credential is missing fields or has invalid values")
 )
 
-// ProofClaimPartial is a proof of existence and non-existence of a claim in
-// a single tree (only one level).
-type ProofClaimPartial struct {
-	Mtp0 *merkletree.Proof `json:"mtp0" binding:"required"`
-	Mtp1 *merkletree.Proof `json:"mtp1" binding:"required"`
-	Root *merkletree.Hash  `json:"root" binding:"required"`
-}
-
-// Returns either (false, nil) or (true, error)
-func (p *ProofClaimPartial) Verify(claim *merkletree.Entry) (bool, error) {
-	// Proof of existence verification
-	if !p.Mtp0.Existence {
-		return false, fmt.Errorf("Mtp0 is a non-existence proof")
-	}
-	if !merkletree.VerifyProof(p.Root, p.Mtp0, claim.HIndex(), claim.HValue()) {
-		return false, fmt.Errorf("Mtp0 doesn't match with the root")
-	}
-
-	// Proof of non-existence of next version (revocation) verification
-	if p.Mtp1.Existence {
-		return false, fmt.Errorf("Mtp1 is an existence proof")
-	}
-	// Make a copy of the claim and increase the version
-	claimNext := claim.Clone()
-	claimType, claimVer := claims.GetClaimTypeVersionFromData(&claimNext.Data)
-	claims.SetClaimTypeVersionInData(&claimNext.Data, claimType, claimVer+1)
-	if !merkletree.VerifyProof(p.Root, p.Mtp1, claimNext.HIndex(), claimNext.HValue()) {
-		return false, fmt.Errorf("Mtp1 doesn't match with the root")
-	}
-	return true, nil
-}
-
-func (pcp *ProofClaimPartial) String() string {
-	buf := bytes.NewBufferString("ProofClaimPartial:\n")
-	fmt.Fprintf(buf, "mtp0: %v\n", pcp.Mtp0)
-	fmt.Fprintf(buf, "mtp0: %v\n", pcp.Mtp1)
-	fmt.Fprintf(buf, "root: %v", pcp.Root)
-	return buf.String()
-}
-
-// RelayAux is auxiliary data used to check a proof when the identity publishes
-// roots via a Relay.
-type RelayAux struct {
-	// Version is SetRootClaim.Version
-	Version uint32 `json:"version" binding:"required"`
-	// Era is SetRootClaim.Era
-	Era uint32 `json:"era" binding:"required"`
-	// Proof is the ProofClaimPartial of the SetRootClaim in the Relay MT
-	Proof ProofClaimPartial `json:"proof" binding:"required"`
-
-	// RelayID is the ID of the Relay authorized by the Identity
-	RelayID *core.ID `json:"relayId" binding:"required"`
-
-	// GenesisRoot is the Genesis Root of the Identity
-	// GenesisRoot *merkletree.Hash `json:"genesisRoot" binding:"required"`
-	// MtpClaimAuthRelay is the mtp of the Identity Genesis ClaimAuthService authorizing the Relay
-	MtpClaimAuthRelay *merkletree.Proof `json:"mtpAuthRelay" binding:"required"`
-}
-
-func (ra *RelayAux) ProofClaimAuthRelay(id *core.ID) *ProofClaimGenesis {
-	return &ProofClaimGenesis{
-		Mtp: ra.MtpClaimAuthRelay,
-		Id:  id,
-	}
-}
-
-func (ra *RelayAux) ClaimAuthRelay() *merkletree.Entry {
-	return claims.NewClaimAuthorizeService(claims.ServiceTypeRelay, ra.RelayID.String(), "", "").Entry()
-}
-
-type RootData struct {
-	BlockN         uint64
-	BlockTimestamp int64
-	Root           *merkletree.Hash
-}
-
-// ProofClaim is a complete proof of a claim that includes all the proofs of
-// existence and non-existence for mutliple levels from the claim of a tree to
-// the signed root of possibly another tree whose root binding:"required".
-type ProofClaim struct {
-	Claim          *merkletree.Entry `json:"claim" binding:"required"`
-	ID             *core.ID          `json:"id" binding:"required"`
-	BlockN         uint64            `json:"blockN" binding:"required"`
-	BlockTimestamp int64             `json:"blockTS" binding:"required"`
-	Proof          ProofClaimPartial `json:"proof" binding:"required,dive"`
-
-	RelayAux *RelayAux `json:"relayAux"`
-}
-
-func (pc *ProofClaim) String() string {
-	buf := bytes.NewBufferString("ProofClaim:\n")
-	fmt.Fprintf(buf, "blockTS: %v\n", time.Unix(pc.BlockTimestamp, 0))
-	fmt.Fprintf(buf, "blockN: %v\n", pc.BlockN)
-	fmt.Fprintf(buf, "claim: %v\n", pc.Claim)
-	if pc.RelayAux != nil {
-		fmt.Fprintf(buf, "relayAux: %v", *pc.RelayAux)
-	}
-	return buf.String()
-}
-
-// PublishedData returns the id of the root publisher with the corresponding
-// block number and block timestamp linked to publish the root.
-func (pc *ProofClaim) PublishedData() (*core.ID, uint64, int64) {
-	var publisherID *core.ID
-	if pc.RelayAux != nil {
-		publisherID = pc.RelayAux.RelayID
-	} else {
-		publisherID = pc.ID
-	}
-	return publisherID, pc.BlockN, pc.BlockTimestamp
-
-}
-
-// CheckProofClaim checks the claim proofs from the bottom to the top are valid and not revoked, and that the top root is signed by relayAddr.
-// Returns either (false, nil) or (true, error)
-// TODO Check id-root in the blockchain!
-func (pc *ProofClaim) Verify(publishedRoot *merkletree.Hash) (bool, error) {
-	var publisherClaim *merkletree.Entry
-	if pc.RelayAux != nil {
-		relayAux := pc.RelayAux
-		// Verify that the identity has authorized the relay ID in a genesis claim
-		proofClaimAuthRelay := relayAux.ProofClaimAuthRelay(pc.ID)
-		if ok, err := proofClaimAuthRelay.Verify(relayAux.ClaimAuthRelay()); !ok {
-			return false, fmt.Errorf("verification of ProofClaim.RelayAux.ProofClaimAuthRelay failed: %v", err)
-		}
-		// Verify that the claim is under the identity MT
-		if ok, err := relayAux.Proof.Verify(pc.Claim); !ok {
-			return false, fmt.Errorf("verification of ProofClaim.RelayAux.Proof failed: %v", err)
-		}
-		// Construct setRootClaim from the identity root
-		setRootClaim, err := claims.NewClaimSetRootKey(pc.ID, relayAux.Proof.Root)
-		if err != nil {
-			return false, err
-		}
-		setRootClaim.Version = relayAux.Version
-		setRootClaim.Era = relayAux.Era
-		publisherClaim = setRootClaim.Entry()
-	} else {
-		publisherClaim = pc.Claim
-	}
-
-	// Verify that the publisherClaim is in the publisher MT
-	if ok, err := pc.Proof.Verify(publisherClaim); !ok {
-		return false, fmt.Errorf("verification of ProofClaim.Proof failed: %v", err)
-	}
-
-	// Verify that the root matches with the published root passed as argument
-	if !pc.Proof.Root.Equals(publishedRoot) {
-		return false, fmt.Errorf("ProofClaim root doesn't match the expected published root")
-	}
-
-	return true, nil
-}
-
 func VerifyGenesisMTProof(id *core.ID, proof *merkletree.Proof, hIndex, hValue *merkletree.Hash) (bool, error) {
 	clr, err := merkletree.RootFromProof(proof, hIndex, hValue)
 	if err != nil {
@@ -227,46 +75,24 @@ func GetNonRevocationMTProof(mt *merkletree.MerkleTree, leafData *merkletree.Dat
 	return proof, nil
 }
 
-// GetClaimProofByHi given a Hash(index) (Hi) and an id, returns the Claim
-// in that Hi position inside the User merkletree, it's proof of existence and
-// of non-revocation, and the proof of existence and of non-revocation for the
-// set root claim in the relay tree, all in the form of a ProofClaim.  The
-// result is not yet signed and has no timestamp.
+// GetClaimProofByHi returns a ProofClaim of the claim at the position hi in
+// the merkle tree mt with only the claims tree component: the claim, its
+// proof of existence and the root of mt.
 func GetClaimProofByHi(mt *merkletree.MerkleTree, hi *merkletree.Hash) (*ProofClaim, error) {
-	// get the value in the hi position
 	leafData, err := mt.GetDataByIndex(hi)
 	if err != nil {
 		return nil, err
 	}
-
-	// get the MT proof of existence of the claim and the non-existence of
-	// the claim's next version in the Relay Tree
-	mtpExist, err := mt.GenerateProof(hi, nil)
-	if err != nil {
-		return nil, err
-	}
-	mtpNonExist, err := GetNonRevocationMTProof(mt, leafData, hi)
+	rootKey := mt.RootKey()
+	mtpExist, err := mt.GenerateProof(hi, rootKey)
 	if err != nil {
 		return nil, err
 	}
-
-	rootKey := mt.RootKey()
-
-	proofClaimPartial := ProofClaimPartial{
-		Mtp0: mtpExist,
-		Mtp1: mtpNonExist,
-		Root: rootKey,
-	}
-	proofClaim := ProofClaim{
-		Claim:          &merkletree.Entry{Data: *leafData},
-		ID:             nil,
-		BlockN:         0,
-		BlockTimestamp: 0,
-		Proof:          proofClaimPartial,
-		RelayAux:       nil,
-	}
-
-	return &proofClaim, nil
+	return &ProofClaim{
+		Claim:      &merkletree.Entry{Data: *leafData},
+		MtpClaim:   mtpExist,
+		ClaimsRoot: rootKey,
+	}, nil
 }
 
 type PredicateProof struct {
@@ -477,6 +303,19 @@ type CredentialExistence struct {
 	IdPubUrl        string
 }
 
+// IsGenesis returns true if the credential is anchored at the genesis
+// identity state of its Id: the IdenStateData has BlockN and BlockTs 0, and
+// its IdenState is the one encoded in the Id.  Such a credential is verified
+// against the genesis state encoded in the Id instead of a state on chain.
+// The credential proof is not checked.
+func (ce *CredentialExistence) IsGenesis() bool {
+	if ce.Id == nil || ce.IdenStateData.IdenState == nil ||
+		ce.IdenStateData.BlockN != 0 || ce.IdenStateData.BlockTs != 0 {
+		return false
+	}
+	return bytes.Equal(ce.Id[:], core.IdGenesisFromIdenState(ce.IdenStateData.IdenState)[:])
+}
+
 type CredentialValidity struct {
 	CredentialExistence CredentialExistence
 	IdenStateData       IdenStateData
@@ -484,3 +323,45 @@ type CredentialValidity struct {
 	ClaimsRoot          *merkletree.Hash
 	RootsRoot           *merkletree.Hash
 }
+
+// hashesInField returns true if all the hashes are set and inside the Finite
+// Field.
+func hashesInField(hs ...*merkletree.Hash) bool {
+	for _, h := range hs {
+		if h == nil || !h.InField() {
+			return false
+		}
+	}
+	return true
+}
+
+// Validate checks that the fields required to verify the credential are set
+// and that the values that are hashed during the verification are inside the
+// Finite Field, so that a credential parsed from an untrusted source can be
+// verified safely.
+func (ce *CredentialExistence) Validate() error {
+	if ce.Id == nil || ce.MtpClaim == nil || ce.Claim == nil {
+		return ErrInvalidCredential
+	}
+	if !merkletree.CheckEntryInField(*ce.Claim) {
+		return ErrInvalidCredential
+	}
+	if !hashesInField(ce.IdenStateData.IdenState, ce.RevocationsRoot, ce.RootsRoot) {
+		return ErrInvalidCredential
+	}
+	return nil
+}
+
+// Validate checks the credential like CredentialExistence.Validate.
+func (cv *CredentialValidity) Validate() error {
+	if err := cv.CredentialExistence.Validate(); err != nil {
+		return err
+	}
+	if cv.MtpNotNonce == nil {
+		return ErrInvalidCredential
+	}
+	if !hashesInField(cv.IdenStateData.IdenState, cv.ClaimsRoot, cv.RootsRoot) {
+		return ErrInvalidCredential
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+//go:build go1.18
+// +build go1.18
+
+package proof
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzCredentialExistenceJSON(f *testing.F) {
+	mt, err := merkletree.NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(f, err)
+	claim := merkletree.NewEntryFromInts(1, 2, 3, 4, 5, 6, 7, 8)
+	require.Nil(f, mt.AddEntry(&claim))
+	mtp, err := mt.GenerateProof(claim.HIndex(), nil)
+	require.Nil(f, err)
+	idenState := core.IdenState(mt.RootKey(), &merkletree.HashZero, &merkletree.HashZero)
+	credExist := CredentialExistence{
+		Id:              core.IdGenesisFromIdenState(idenState),
+		IdenStateData:   IdenStateData{IdenState: idenState},
+		MtpClaim:        mtp,
+		Claim:           &claim,
+		RevocationsRoot: &merkletree.HashZero,
+		RootsRoot:       &merkletree.HashZero,
+		IdPubUrl:        "https://iden3.io",
+	}
+	credExistJSON, err := json.Marshal(credExist)
+	require.Nil(f, err)
+	f.Add(credExistJSON)
+	f.Add([]byte(`{"MtpClaim":"0x0001"}`))
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		var credExist CredentialExistence
+		if err := json.Unmarshal(bs, &credExist); err != nil {
+			return
+		}
+		if err := credExist.Validate(); err != nil {
+			return
+		}
+		credExistJSON, err := json.Marshal(credExist)
+		require.Nil(t, err)
+		var credExist2 CredentialExistence
+		require.Nil(t, json.Unmarshal(credExistJSON, &credExist2))
+		assert.Equal(t, credExist, credExist2)
+		credExist.IsGenesis()
+	})
+}
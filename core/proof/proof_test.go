@@ -80,9 +80,11 @@ func TestClaimProof(t *testing.T) {
 	mtp, err := GetClaimProofByHi(mt, entry1.HIndex())
 	assert.Nil(t, err)
 
-	fmt.Println("mtp", mtp.Claim,
-		hex.EncodeToString(mtp.Proof.Mtp0.Bytes()),
-		hex.EncodeToString(mtp.Proof.Mtp1.Bytes()))
+	fmt.Println("mtp", mtp.Claim, hex.EncodeToString(mtp.MtpClaim.Bytes()))
+
+	verified, err := mtp.Verify()
+	assert.Nil(t, err)
+	assert.Equal(t, ProofClaimClaimsTree, verified)
 }
 
 func TestGetPredicateProof(t *testing.T) {
@@ -107,10 +109,9 @@ func TestGetPredicateProof(t *testing.T) {
 	cp, err := GetClaimProofByHi(mt, claim0.Entry().HIndex())
 	assert.Nil(t, err)
 
-	// id := core.ID{}
-	verified, err := cp.Verify(cp.Proof.Root)
+	verified, err := cp.Verify()
 	assert.Nil(t, err)
-	assert.True(t, verified)
+	assert.Equal(t, ProofClaimClaimsTree, verified)
 
 	p, err := GetPredicateProof(mt, oldRoot, claim0.Entry().HIndex())
 	assert.Nil(t, err)
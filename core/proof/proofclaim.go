@@ -0,0 +1,261 @@
+package proof
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// SigPrefixProofClaim is the prefix of the message signed in a ProofClaim,
+// followed by the identity state.
+var SigPrefixProofClaim = []byte("proofclaim:")
+
+var (
+	// ErrProofClaimIncomplete is used when a component of a ProofClaim
+	// lacks some of its fields, or a component requires another one that
+	// is missing.
+	ErrProofClaimIncomplete = errors.New("the proof claim lacks fields required to verify it")
+	// ErrProofClaimRevoked is used when the non-revocation component of a
+	// ProofClaim is a proof of existence of the revocation leaf.
+	ErrProofClaimRevoked = errors.New("the claim of the proof claim is revoked")
+	// ErrProofClaimInvalidSignature is used when the signature of a
+	// ProofClaim doesn't match the public key.
+	ErrProofClaimInvalidSignature = errors.New("invalid proof claim signature")
+)
+
+// ProofClaimComponents is a set of components of a ProofClaim.
+type ProofClaimComponents uint
+
+const (
+	// ProofClaimClaimsTree is the proof of existence of the claim in the
+	// claims tree: MtpClaim and ClaimsRoot.  It's required in all the
+	// ProofClaims.
+	ProofClaimClaimsTree ProofClaimComponents = 1 << iota
+	// ProofClaimNonRevocation is the proof of non-existence of the
+	// revocation leaf of the claim in the revocations tree: MtpNotRevoked
+	// and RevocationsRoot.
+	ProofClaimNonRevocation
+	// ProofClaimRootsTree is the proof of existence of ClaimsRoot in the
+	// roots tree: MtpClaimsRoot and RootsRoot.
+	ProofClaimRootsTree
+	// ProofClaimState is the identity state built from ClaimsRoot,
+	// RevocationsRoot and RootsRoot: IdenStateData.
+	ProofClaimState
+	// ProofClaimSignature is the signature of the identity state by the
+	// issuer: Signature.
+	ProofClaimSignature
+)
+
+// ProofClaim is a proof about a claim made of optional components (see
+// ProofClaimComponents), which replaces the legacy relay proofs and can be
+// converted from and to a CredentialExistence.  The components present
+// determine what is proven:
+//
+// - Claims tree: the claim exists in a claims tree with root ClaimsRoot.
+//
+// - Non-revocation: the claim is not revoked in the revocations tree with
+// root RevocationsRoot.  Only meaningful together with the state, which ties
+// the revocations tree to the claims tree.
+//
+// - Roots tree: ClaimsRoot is in the roots tree with root RootsRoot.
+//
+// - State: ClaimsRoot, RevocationsRoot and RootsRoot are the roots of the
+// identity state IdenStateData.IdenState of Id.  The state must be checked
+// on chain by the verifier, unless it's the genesis state (see IsGenesis).
+//
+// - Signature: the issuer signed the identity state.  The signature is
+// checked with VerifySignature, as the public key of the issuer is not part
+// of the proof.
+type ProofClaim struct {
+	Claim           *merkletree.Entry      `json:"claim" binding:"required"`
+	Id              *core.ID               `json:"id,omitempty"`
+	MtpClaim        *merkletree.Proof      `json:"mtpClaim" binding:"required"`
+	ClaimsRoot      *merkletree.Hash       `json:"claimsRoot" binding:"required"`
+	MtpNotRevoked   *merkletree.Proof      `json:"mtpNotRevoked,omitempty"`
+	RevocationsRoot *merkletree.Hash       `json:"revocationsRoot,omitempty"`
+	MtpClaimsRoot   *merkletree.Proof      `json:"mtpClaimsRoot,omitempty"`
+	RootsRoot       *merkletree.Hash       `json:"rootsRoot,omitempty"`
+	IdenStateData   *IdenStateData         `json:"idenStateData,omitempty"`
+	Signature       *babyjub.SignatureComp `json:"signature,omitempty"`
+	IdPubUrl        string                 `json:"idPubUrl,omitempty"`
+}
+
+// NewProofClaimFromCredentialExistence returns the ProofClaim with the claims
+// tree and state components of the existence credential credExist.
+func NewProofClaimFromCredentialExistence(credExist *CredentialExistence) (*ProofClaim, error) {
+	if err := credExist.Validate(); err != nil {
+		return nil, err
+	}
+	claimsRoot, err := merkletree.RootFromProof(credExist.MtpClaim, credExist.Claim.HIndex(), credExist.Claim.HValue())
+	if err != nil {
+		return nil, err
+	}
+	idenStateData := credExist.IdenStateData
+	return &ProofClaim{
+		Claim:           credExist.Claim,
+		Id:              credExist.Id,
+		MtpClaim:        credExist.MtpClaim,
+		ClaimsRoot:      claimsRoot,
+		RevocationsRoot: credExist.RevocationsRoot,
+		RootsRoot:       credExist.RootsRoot,
+		IdenStateData:   &idenStateData,
+		IdPubUrl:        credExist.IdPubUrl,
+	}, nil
+}
+
+// CredentialExistence returns the existence credential of the proof, which
+// requires the claims tree and state components.
+func (pc *ProofClaim) CredentialExistence() (*CredentialExistence, error) {
+	if pc.Components()&(ProofClaimClaimsTree|ProofClaimState) != ProofClaimClaimsTree|ProofClaimState {
+		return nil, ErrProofClaimIncomplete
+	}
+	return &CredentialExistence{
+		Id:              pc.Id,
+		IdenStateData:   *pc.IdenStateData,
+		MtpClaim:        pc.MtpClaim,
+		Claim:           pc.Claim,
+		RevocationsRoot: pc.RevocationsRoot,
+		RootsRoot:       pc.RootsRoot,
+		IdPubUrl:        pc.IdPubUrl,
+	}, nil
+}
+
+// Components returns the components present in the proof, without verifying
+// them.
+func (pc *ProofClaim) Components() ProofClaimComponents {
+	var c ProofClaimComponents
+	if pc.MtpClaim != nil {
+		c |= ProofClaimClaimsTree
+	}
+	if pc.MtpNotRevoked != nil {
+		c |= ProofClaimNonRevocation
+	}
+	if pc.MtpClaimsRoot != nil {
+		c |= ProofClaimRootsTree
+	}
+	if pc.IdenStateData != nil {
+		c |= ProofClaimState
+	}
+	if pc.Signature != nil {
+		c |= ProofClaimSignature
+	}
+	return c
+}
+
+// Verify verifies the components present in the proof, and returns the ones
+// verified, which are all the present except the signature.  The claims tree
+// component is required.  The state on chain and the signature must be
+// verified by the caller.
+func (pc *ProofClaim) Verify() (ProofClaimComponents, error) {
+	if pc.Claim == nil || pc.MtpClaim == nil || !hashesInField(pc.ClaimsRoot) {
+		return 0, ErrProofClaimIncomplete
+	}
+	if !merkletree.CheckEntryInField(*pc.Claim) {
+		return 0, fmt.Errorf("claim elements not in the finite field")
+	}
+	if !pc.MtpClaim.Existence {
+		return 0, fmt.Errorf("MtpClaim is a non-existence proof")
+	}
+	if !merkletree.VerifyProof(pc.ClaimsRoot, pc.MtpClaim, pc.Claim.HIndex(), pc.Claim.HValue()) {
+		return 0, fmt.Errorf("MtpClaim doesn't match with the ClaimsRoot")
+	}
+	verified := ProofClaimClaimsTree
+
+	if pc.MtpNotRevoked != nil {
+		if !hashesInField(pc.RevocationsRoot) {
+			return 0, ErrProofClaimIncomplete
+		}
+		if pc.MtpNotRevoked.Existence {
+			return 0, ErrProofClaimRevoked
+		}
+		nonce := claims.GetRevocationNonce(pc.Claim)
+		revLeaf := claims.NewLeafRevocationsTree(nonce, claims.RevocationVersionAll).Entry()
+		if !merkletree.VerifyProof(pc.RevocationsRoot, pc.MtpNotRevoked, revLeaf.HIndex(), revLeaf.HValue()) {
+			return 0, fmt.Errorf("MtpNotRevoked doesn't match with the RevocationsRoot")
+		}
+		verified |= ProofClaimNonRevocation
+	}
+
+	if pc.MtpClaimsRoot != nil {
+		if !hashesInField(pc.RootsRoot) {
+			return 0, ErrProofClaimIncomplete
+		}
+		if !pc.MtpClaimsRoot.Existence {
+			return 0, fmt.Errorf("MtpClaimsRoot is a non-existence proof")
+		}
+		rootsLeaf := claims.NewLeafRootsTree(*pc.ClaimsRoot).Entry()
+		if !merkletree.VerifyProof(pc.RootsRoot, pc.MtpClaimsRoot, rootsLeaf.HIndex(), rootsLeaf.HValue()) {
+			return 0, fmt.Errorf("MtpClaimsRoot doesn't match with the RootsRoot")
+		}
+		verified |= ProofClaimRootsTree
+	}
+
+	if pc.IdenStateData != nil {
+		if pc.Id == nil || pc.IdenStateData.IdenState == nil ||
+			!hashesInField(pc.RevocationsRoot, pc.RootsRoot) {
+			return 0, ErrProofClaimIncomplete
+		}
+		idenState := core.IdenState(pc.ClaimsRoot, pc.RevocationsRoot, pc.RootsRoot)
+		if !idenState.Equals(pc.IdenStateData.IdenState) {
+			return 0, fmt.Errorf("calculated IdenState doesn't match the one in the proof")
+		}
+		verified |= ProofClaimState
+	}
+	return verified, nil
+}
+
+// IsGenesis returns true if the state of the proof is the genesis identity
+// state of its Id, like CredentialExistence.IsGenesis.
+func (pc *ProofClaim) IsGenesis() bool {
+	if pc.IdenStateData == nil {
+		return false
+	}
+	credExist := CredentialExistence{Id: pc.Id, IdenStateData: *pc.IdenStateData}
+	return credExist.IsGenesis()
+}
+
+// SigMsg returns the message signed in the signature component (after the
+// SigPrefixProofClaim): the identity state.
+func (pc *ProofClaim) SigMsg() ([]byte, error) {
+	if pc.IdenStateData == nil || pc.IdenStateData.IdenState == nil {
+		return nil, ErrProofClaimIncomplete
+	}
+	return pc.IdenStateData.IdenState[:], nil
+}
+
+// VerifySignature verifies the signature component with the public key of
+// the issuer.
+func (pc *ProofClaim) VerifySignature(pk *babyjub.PublicKeyComp) error {
+	if pc.Signature == nil {
+		return ErrProofClaimIncomplete
+	}
+	msg, err := pc.SigMsg()
+	if err != nil {
+		return err
+	}
+	if ok, err := keystore.VerifySignatureRaw(pk, pc.Signature,
+		append(append([]byte{}, SigPrefixProofClaim...), msg...)); err != nil {
+		return err
+	} else if !ok {
+		return ErrProofClaimInvalidSignature
+	}
+	return nil
+}
+
+func (pc *ProofClaim) String() string {
+	buf := bytes.NewBufferString("ProofClaim:\n")
+	fmt.Fprintf(buf, "claim: %v\n", pc.Claim)
+	fmt.Fprintf(buf, "claimsRoot: %v\n", pc.ClaimsRoot)
+	if pc.IdenStateData != nil {
+		fmt.Fprintf(buf, "id: %v\n", pc.Id)
+		fmt.Fprintf(buf, "idenState: %v (block %v)\n", pc.IdenStateData.IdenState, pc.IdenStateData.BlockN)
+	}
+	fmt.Fprintf(buf, "components: %b", pc.Components())
+	return buf.String()
+}
@@ -0,0 +1,140 @@
+package proof
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestProofClaim returns a ProofClaim with all the components except the
+// signature, of a claim in a genesis identity state, and the revocations tree
+// of that state.
+func newTestProofClaim(t *testing.T) (*ProofClaim, *merkletree.MerkleTree) {
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 7)
+	clt, err := merkletree.NewMerkleTreeInMemory(140)
+	require.Nil(t, err)
+	require.Nil(t, clt.AddClaim(claim))
+	ret, err := merkletree.NewMerkleTreeInMemory(140)
+	require.Nil(t, err)
+	rot, err := merkletree.NewMerkleTreeInMemory(140)
+	require.Nil(t, err)
+	require.Nil(t, claims.AddLeafRootsTree(rot, clt.RootKey()))
+
+	pc, err := GetClaimProofByHi(clt, claim.Entry().HIndex())
+	require.Nil(t, err)
+	revLeaf := claims.NewLeafRevocationsTree(7, claims.RevocationVersionAll)
+	pc.MtpNotRevoked, err = ret.GenerateProof(revLeaf.HIndex(), nil)
+	require.Nil(t, err)
+	pc.RevocationsRoot = ret.RootKey()
+	pc.MtpClaimsRoot, err = rot.GenerateProof(claims.NewLeafRootsTree(*clt.RootKey()).Entry().HIndex(), nil)
+	require.Nil(t, err)
+	pc.RootsRoot = rot.RootKey()
+	idenState := core.IdenState(pc.ClaimsRoot, pc.RevocationsRoot, pc.RootsRoot)
+	pc.Id = core.IdGenesisFromIdenState(idenState)
+	pc.IdenStateData = &IdenStateData{IdenState: idenState}
+	return pc, ret
+}
+
+func TestProofClaimVerify(t *testing.T) {
+	pc, ret := newTestProofClaim(t)
+	all := ProofClaimClaimsTree | ProofClaimNonRevocation | ProofClaimRootsTree | ProofClaimState
+	assert.Equal(t, all, pc.Components())
+	verified, err := pc.Verify()
+	require.Nil(t, err)
+	assert.Equal(t, all, verified)
+	assert.True(t, pc.IsGenesis())
+
+	// Only the claims tree
+	partial := ProofClaim{Claim: pc.Claim, MtpClaim: pc.MtpClaim, ClaimsRoot: pc.ClaimsRoot}
+	verified, err = partial.Verify()
+	require.Nil(t, err)
+	assert.Equal(t, ProofClaimClaimsTree, verified)
+	assert.False(t, partial.IsGenesis())
+
+	// Claims tree and non-revocation
+	partial.MtpNotRevoked, partial.RevocationsRoot = pc.MtpNotRevoked, pc.RevocationsRoot
+	verified, err = partial.Verify()
+	require.Nil(t, err)
+	assert.Equal(t, ProofClaimClaimsTree|ProofClaimNonRevocation, verified)
+
+	// The state requires all the roots
+	partial.Id, partial.IdenStateData = pc.Id, pc.IdenStateData
+	_, err = partial.Verify()
+	assert.Equal(t, ErrProofClaimIncomplete, err)
+	partial.RootsRoot = pc.RootsRoot
+	verified, err = partial.Verify()
+	require.Nil(t, err)
+	assert.Equal(t, ProofClaimClaimsTree|ProofClaimNonRevocation|ProofClaimState, verified)
+
+	// The claims tree is required
+	_, err = (&ProofClaim{Claim: pc.Claim}).Verify()
+	assert.Equal(t, ErrProofClaimIncomplete, err)
+
+	// Wrong claims root
+	invalid := *pc
+	invalid.ClaimsRoot = &merkletree.Hash{1}
+	_, err = invalid.Verify()
+	assert.NotNil(t, err)
+
+	// Wrong identity state
+	invalid = *pc
+	invalid.IdenStateData = &IdenStateData{IdenState: &merkletree.Hash{1}}
+	_, err = invalid.Verify()
+	assert.NotNil(t, err)
+
+	// Revoked claim
+	require.Nil(t, claims.AddLeafRevocationsTree(ret, 7, claims.RevocationVersionAll))
+	invalid = *pc
+	invalid.MtpNotRevoked, err = ret.GenerateProof(claims.NewLeafRevocationsTree(7, claims.RevocationVersionAll).HIndex(), nil)
+	require.Nil(t, err)
+	invalid.RevocationsRoot = ret.RootKey()
+	_, err = invalid.Verify()
+	assert.Equal(t, ErrProofClaimRevoked, err)
+}
+
+func TestProofClaimSignature(t *testing.T) {
+	pc, _ := newTestProofClaim(t)
+	storage := keystore.MemStorage([]byte{})
+	ks, err := keystore.NewKeyStore(&storage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	pass := []byte("my passphrase")
+	pk, err := ks.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, ks.UnlockKey(pk, pass))
+
+	assert.Equal(t, ErrProofClaimIncomplete, pc.VerifySignature(pk))
+	msg, err := pc.SigMsg()
+	require.Nil(t, err)
+	pc.Signature, err = ks.SignRaw(pk, append(append([]byte{}, SigPrefixProofClaim...), msg...))
+	require.Nil(t, err)
+	assert.Equal(t, ProofClaimSignature, pc.Components()&ProofClaimSignature)
+	require.Nil(t, pc.VerifySignature(pk))
+
+	// The signature is bound to the identity state
+	pc.IdenStateData = &IdenStateData{IdenState: &merkletree.Hash{1}}
+	assert.Equal(t, ErrProofClaimInvalidSignature, pc.VerifySignature(pk))
+}
+
+func TestProofClaimCredentialExistence(t *testing.T) {
+	pc, _ := newTestProofClaim(t)
+	credExist, err := pc.CredentialExistence()
+	require.Nil(t, err)
+	require.Nil(t, credExist.Validate())
+
+	pc2, err := NewProofClaimFromCredentialExistence(credExist)
+	require.Nil(t, err)
+	assert.Equal(t, pc.ClaimsRoot, pc2.ClaimsRoot)
+	verified, err := pc2.Verify()
+	require.Nil(t, err)
+	assert.Equal(t, ProofClaimClaimsTree|ProofClaimState, verified)
+
+	_, err = (&ProofClaim{Claim: pc.Claim, MtpClaim: pc.MtpClaim, ClaimsRoot: pc.ClaimsRoot}).CredentialExistence()
+	assert.Equal(t, ErrProofClaimIncomplete, err)
+}
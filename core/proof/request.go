@@ -0,0 +1,34 @@
+package proof
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+)
+
+// SigPrefixProofRequest is the prefix of the message hashed into the
+// challenge of a ProofRequest.
+var SigPrefixProofRequest = []byte("proofrequest:")
+
+// ProofRequest is the request of a verifier for a PresentationBundle.  The
+// verifier issues a single use Nonce, valid until ExpirationTs, for the
+// Audience that identifies it (an url or an identity), and the holder
+// responds with a bundle whose Challenge is the Challenge of the request, so
+// that the bundle can't be replayed to another verifier nor to the same
+// verifier after it has been used or has expired.
+type ProofRequest struct {
+	Audience     string
+	Nonce        []byte
+	IssuedTs     int64
+	ExpirationTs int64
+}
+
+// Challenge returns the challenge of the request, the hash of all its
+// fields.
+func (r *ProofRequest) Challenge() ([]byte, error) {
+	msg, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.Sum256(append(append([]byte{}, SigPrefixProofRequest...), msg...))
+	return h[:], nil
+}
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"crypto/ecdsa"
 	"encoding/json"
+	"errors"
 	"fmt"
 
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/keystore"
 	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/crypto"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
 	common3 "github.com/iden3/go-iden3-core/common"
 )
 
@@ -42,6 +44,10 @@ func (s *SignatureEthMsg) MarshalJSON() ([]byte, error) {
 
 const web3SignaturePrefix = "\x19Ethereum Signed Message:\n"
 
+// ErrInvalidSignatureV is used when the recovery id of a signature is not
+// valid.
+var ErrInvalidSignatureV = errors.New("invalid signature recovery id")
+
 // Sign performs the signature over a Hash
 func Sign(hash Hash, ks *keystore.KeyStore, acc accounts.Account) ([]byte, error) {
 	return ks.SignHash(acc, hash[:])
@@ -93,6 +99,89 @@ func VerifySig(addr common.Address, sig *Signature, msgHash []byte) bool {
 	return bytes.Equal(addr.Bytes(), recoveredAddr.Bytes())
 }
 
+// RecoverAddr recovers the ethereum address that signed msgHash with sig.
+func RecoverAddr(sig *Signature, msgHash []byte) (common.Address, error) {
+	recoveredPub, err := crypto.Ecrecover(msgHash, sig[:])
+	if err != nil {
+		return common.Address{}, err
+	}
+	pubKey, err := crypto.UnmarshalPubkey(recoveredPub)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return crypto.PubkeyToAddress(*pubKey), nil
+}
+
+// ethSigToSig converts a signature of an ethereum message (with the recovery
+// id offset by 27 as returned by wallets) to a raw Signature.  Signatures
+// with the recovery id not offset are also accepted.
+func ethSigToSig(sig *SignatureEthMsg) (*Signature, error) {
+	var _sig Signature
+	copy(_sig[:], sig[:])
+	if _sig[64] >= 27 {
+		_sig[64] -= 27
+	}
+	if _sig[64] > 1 {
+		return nil, ErrInvalidSignatureV
+	}
+	return &_sig, nil
+}
+
+// RecoverAddrEthMsg recovers the ethereum address that signed msg following
+// EIP-191 (personal_sign / eth_sign).
+func RecoverAddrEthMsg(sig *SignatureEthMsg, msg []byte) (common.Address, error) {
+	_sig, err := ethSigToSig(sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	hash := EthHash(msg)
+	return RecoverAddr(_sig, hash[:])
+}
+
+// EthTypedDataHash is the hashing function used before signing EIP-712 typed
+// data: keccak256("\x19\x01" ‖ domainSeparator ‖ hashStruct(message)).
+func EthTypedDataHash(typedData *signercore.TypedData) (Hash, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return Hash{}, err
+	}
+	typedDataHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return Hash{}, err
+	}
+	return HashBytes([]byte("\x19\x01"), domainSeparator, typedDataHash), nil
+}
+
+// SignEthTypedData performs an EIP-712 signature over typedData.
+func SignEthTypedData(ks *keystore.KeyStore, acc accounts.Account, typedData *signercore.TypedData) (*SignatureEthMsg, error) {
+	hash, err := EthTypedDataHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := ks.SignHash(acc, hash[:])
+	if err != nil {
+		return nil, err
+	}
+	sig[64] += 27
+	sigEthMsg := &SignatureEthMsg{}
+	copy(sigEthMsg[:], sig)
+	return sigEthMsg, nil
+}
+
+// RecoverAddrEthTypedData recovers the ethereum address that signed
+// typedData following EIP-712 (eth_signTypedData).
+func RecoverAddrEthTypedData(sig *SignatureEthMsg, typedData *signercore.TypedData) (common.Address, error) {
+	_sig, err := ethSigToSig(sig)
+	if err != nil {
+		return common.Address{}, err
+	}
+	hash, err := EthTypedDataHash(typedData)
+	if err != nil {
+		return common.Address{}, err
+	}
+	return RecoverAddr(_sig, hash[:])
+}
+
 // GetPkFromKeyStore is a hack to obtain the public key of an addres who's
 // private key is stored in a key store.  It does this by signing an empty hash
 // and recovering the public key from the signature.
@@ -4,9 +4,12 @@ import (
 	"testing"
 
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/math"
 	"github.com/ethereum/go-ethereum/crypto"
+	signercore "github.com/ethereum/go-ethereum/signer/core"
 	common3 "github.com/iden3/go-iden3-core/common"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -48,3 +51,85 @@ func TestSignAndVerify(t *testing.T) {
 	testAddr := crypto.PubkeyToAddress(testPrivK.PublicKey)
 	assert.True(t, VerifySig(testAddr, signature, msgHash[:]))
 }
+
+func TestRecoverAddrEthMsg(t *testing.T) {
+	// recover the signer of a signature performed in iden3js
+	signatureHex := "0x5413b44384531e9e92bdd80ff21cea7449441dcfff6f4ed0f90864583e3fcade3d5c8857672b473f71d09355e034dba11bb2ca4aa73c55c534293fdca68941041c"
+	signature := &SignatureEthMsg{}
+	err := common3.HexDecodeInto(signature[:], []byte(signatureHex))
+	require.Nil(t, err)
+	addr, err := RecoverAddrEthMsg(signature, []byte("test"))
+	require.Nil(t, err)
+	assert.Equal(t, common.HexToAddress("0xBc8C480E68d0895f1E410f4e4eA6E2d6b160Ca9F"), addr)
+
+	addr, err = RecoverAddrEthMsg(signature, []byte("test2"))
+	require.Nil(t, err)
+	assert.NotEqual(t, common.HexToAddress("0xBc8C480E68d0895f1E410f4e4eA6E2d6b160Ca9F"), addr)
+
+	signature[64] = 30
+	_, err = RecoverAddrEthMsg(signature, []byte("test"))
+	assert.Equal(t, ErrInvalidSignatureV, err)
+}
+
+// testTypedDataMail is the example of the EIP-712 specification:
+// https://eips.ethereum.org/EIPS/eip-712
+func testTypedDataMail() *signercore.TypedData {
+	return &signercore.TypedData{
+		Types: signercore.Types{
+			"EIP712Domain": []signercore.Type{
+				{Name: "name", Type: "string"},
+				{Name: "version", Type: "string"},
+				{Name: "chainId", Type: "uint256"},
+				{Name: "verifyingContract", Type: "address"},
+			},
+			"Person": []signercore.Type{
+				{Name: "name", Type: "string"},
+				{Name: "wallet", Type: "address"},
+			},
+			"Mail": []signercore.Type{
+				{Name: "from", Type: "Person"},
+				{Name: "to", Type: "Person"},
+				{Name: "contents", Type: "string"},
+			},
+		},
+		PrimaryType: "Mail",
+		Domain: signercore.TypedDataDomain{
+			Name:              "Ether Mail",
+			Version:           "1",
+			ChainId:           math.NewHexOrDecimal256(1),
+			VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+		},
+		Message: signercore.TypedDataMessage{
+			"from": map[string]interface{}{
+				"name":   "Cow",
+				"wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826",
+			},
+			"to": map[string]interface{}{
+				"name":   "Bob",
+				"wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB",
+			},
+			"contents": "Hello, Bob!",
+		},
+	}
+}
+
+func TestRecoverAddrEthTypedData(t *testing.T) {
+	typedData := testTypedDataMail()
+	hash, err := EthTypedDataHash(typedData)
+	require.Nil(t, err)
+	assert.Equal(t, "0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2", hash.Hex())
+
+	// signature of the EIP-712 specification example
+	signatureHex := "0x4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b915621c"
+	signature := &SignatureEthMsg{}
+	err = common3.HexDecodeInto(signature[:], []byte(signatureHex))
+	require.Nil(t, err)
+	addr, err := RecoverAddrEthTypedData(signature, typedData)
+	require.Nil(t, err)
+	assert.Equal(t, common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), addr)
+
+	typedData.Message["contents"] = "Hello, Alice!"
+	addr, err = RecoverAddrEthTypedData(signature, typedData)
+	require.Nil(t, err)
+	assert.NotEqual(t, common.HexToAddress("0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"), addr)
+}
@@ -0,0 +1,84 @@
+package db
+
+import (
+	"errors"
+	"os"
+
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+)
+
+// checkpointBatchLen is the number of keys written at once to a checkpoint.
+const checkpointBatchLen = 1024
+
+var (
+	// ErrCheckpointExists is used when the path of a new checkpoint
+	// already exists.
+	ErrCheckpointExists = errors.New("the checkpoint path already exists")
+)
+
+// Checkpointer is implemented by the Storages that can take a consistent
+// snapshot of their keys while they keep being written.
+type Checkpointer interface {
+	// Checkpoint writes a consistent snapshot of the keys of the Storage
+	// in a new leveldb database at path, which must not exist.  The keys
+	// are written without the prefix of the Storage, so the checkpoint
+	// is opened as a standalone storage with NewLevelDbStorage.
+	Checkpoint(path string) error
+}
+
+// Checkpoint writes a consistent snapshot of the keys of the storage, taken
+// with a leveldb snapshot, in a new leveldb database at path.  See
+// Checkpointer.
+func (l *LevelDbStorage) Checkpoint(path string) error {
+	// Iterate reads from a leveldb snapshot.
+	return writeCheckpoint(path, l.Iterate)
+}
+
+// Checkpoint writes the keys of the storage in a new leveldb database at
+// path.  See Checkpointer.
+func (m *MemoryStorage) Checkpoint(path string) error {
+	return writeCheckpoint(path, m.Iterate)
+}
+
+// writeCheckpoint writes the keys visited by iterate in a new leveldb
+// database at path.  The database is written in a temporary directory that
+// is renamed to path once complete, so that an interrupted checkpoint is
+// never mistaken for a complete one.
+func writeCheckpoint(path string, iterate func(func([]byte, []byte) (bool, error)) error) error {
+	if _, err := os.Stat(path); err == nil {
+		return ErrCheckpointExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	tmpPath := path + ".tmp"
+	if err := os.RemoveAll(tmpPath); err != nil {
+		return err
+	}
+	ldb, err := leveldb.OpenFile(tmpPath, &opt.Options{ErrorIfExist: true})
+	if err != nil {
+		return err
+	}
+	var batch leveldb.Batch
+	err = iterate(func(k, v []byte) (bool, error) {
+		batch.Put(k, v)
+		if batch.Len() == checkpointBatchLen {
+			if err := ldb.Write(&batch, nil); err != nil {
+				return false, err
+			}
+			batch.Reset()
+		}
+		return true, nil
+	})
+	if err == nil {
+		err = ldb.Write(&batch, &opt.WriteOptions{Sync: true})
+	}
+	if errClose := ldb.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		os.RemoveAll(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
@@ -0,0 +1,287 @@
+// Package encrypted implements a db.Storage wrapper that encrypts the values
+// with XChaCha20-Poly1305 before storing them in an underlying db.Storage.
+// Keys are stored in plain text, so that iteration order and prefixes keep
+// working.
+package encrypted
+
+import (
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-core/db"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	scryptR     = 8
+	scryptDKLen = chacha20poly1305.KeySize
+)
+
+var (
+	// dataPrefix is the prefix in the underlying storage under which the
+	// encrypted values are stored.
+	dataPrefix = []byte{0}
+	// metaPrefix is the prefix in the underlying storage under which the
+	// key derivation parameters are stored.
+	metaPrefix = []byte{1}
+	metaKey    = []byte("params")
+	// checkPlaintext is encrypted with the derived key and stored with the
+	// key derivation parameters to detect a wrong passphrase on open.
+	checkPlaintext = []byte("iden3 encrypted storage")
+)
+
+var (
+	// ErrInvalidPassphrase is returned when opening an existing storage
+	// with a passphrase different from the one it was created with.
+	ErrInvalidPassphrase = fmt.Errorf("Invalid passphrase for encrypted storage")
+	// ErrNotSupported is returned by PrefixInfo and Compact when the
+	// underlying storage doesn't support them.
+	ErrNotSupported = fmt.Errorf("Operation not supported by the underlying storage")
+)
+
+// params are the key derivation parameters, persisted in the underlying
+// storage.
+type params struct {
+	Salt    common3.Hex
+	ScryptN int
+	ScryptP int
+	Check   common3.Hex
+}
+
+// Storage is a db.Storage that encrypts the values before passing them to an
+// underlying db.Storage.
+type Storage struct {
+	sto    db.Storage
+	aead   cipher.AEAD
+	prefix []byte
+}
+
+// Tx is a db.Tx of an encrypted Storage.
+type Tx struct {
+	tx  db.Tx
+	sto *Storage
+}
+
+// NewStorage returns a Storage that encrypts the values stored in sto with
+// key.
+func NewStorage(sto db.Storage, key *[chacha20poly1305.KeySize]byte) (*Storage, error) {
+	aead, err := chacha20poly1305.NewX(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return &Storage{sto: sto.WithPrefix(dataPrefix), aead: aead, prefix: []byte{}}, nil
+}
+
+// NewStorageWithPassphrase returns a Storage that encrypts the values stored
+// in sto with a key derived from pass using scrypt.  The first time the
+// storage is opened a random salt is generated and stored along with scryptN
+// and scryptP in sto; the next times the stored parameters are used and
+// scryptN and scryptP are ignored.
+func NewStorageWithPassphrase(sto db.Storage, pass []byte, scryptN, scryptP int) (*Storage, error) {
+	metaSto := sto.WithPrefix(metaPrefix)
+	paramsJSON, err := metaSto.Get(metaKey)
+	if err == db.ErrNotFound {
+		return createWithPassphrase(sto, metaSto, pass, scryptN, scryptP)
+	} else if err != nil {
+		return nil, err
+	}
+	var p params
+	if err := json.Unmarshal(paramsJSON, &p); err != nil {
+		return nil, err
+	}
+	s, err := newStorageFromPassphrase(sto, pass, &p)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.open(metaKey, p.Check); err != nil {
+		return nil, ErrInvalidPassphrase
+	}
+	return s, nil
+}
+
+func createWithPassphrase(sto, metaSto db.Storage, pass []byte, scryptN, scryptP int) (*Storage, error) {
+	var salt [32]byte
+	if _, err := io.ReadFull(rand.Reader, salt[:]); err != nil {
+		return nil, err
+	}
+	p := params{Salt: salt[:], ScryptN: scryptN, ScryptP: scryptP}
+	s, err := newStorageFromPassphrase(sto, pass, &p)
+	if err != nil {
+		return nil, err
+	}
+	p.Check = s.seal(metaKey, checkPlaintext)
+	paramsJSON, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := metaSto.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	tx.Put(metaKey, paramsJSON)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func newStorageFromPassphrase(sto db.Storage, pass []byte, p *params) (*Storage, error) {
+	derivedKey, err := scrypt.Key(pass, p.Salt, p.ScryptN, scryptR, p.ScryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+	var key [chacha20poly1305.KeySize]byte
+	copy(key[:], derivedKey)
+	return NewStorage(sto, &key)
+}
+
+// seal encrypts value with a random nonce.  The key is used as additional
+// data so that encrypted values can't be swapped between keys.
+func (s *Storage) seal(key, value []byte) []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSizeX, chacha20poly1305.NonceSizeX+len(value)+s.aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		panic("reading from crypto/rand failed: " + err.Error())
+	}
+	return s.aead.Seal(nonce, nonce, value, s.additionalData(key))
+}
+
+// open decrypts a value encrypted with seal.
+func (s *Storage) open(key, sealed []byte) ([]byte, error) {
+	if len(sealed) < chacha20poly1305.NonceSizeX {
+		return nil, fmt.Errorf("Encrypted value too short")
+	}
+	nonce, ciphertext := sealed[:chacha20poly1305.NonceSizeX], sealed[chacha20poly1305.NonceSizeX:]
+	value, err := s.aead.Open(nil, nonce, ciphertext, s.additionalData(key))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decrypt value: %w", err)
+	}
+	return value, nil
+}
+
+func (s *Storage) additionalData(key []byte) []byte {
+	ad := make([]byte, 0, len(s.prefix)+len(key))
+	ad = append(ad, s.prefix...)
+	return append(ad, key...)
+}
+
+// Info returns information about the underlying storage.
+func (s *Storage) Info() string {
+	return "encrypted " + s.sto.Info()
+}
+
+// PrefixInfo returns the space used by the keys of the underlying storage,
+// which includes the encryption overhead.  See db.Inspector.
+func (s *Storage) PrefixInfo(prefixes ...[]byte) ([]db.PrefixInfo, error) {
+	inspector, ok := s.sto.(db.Inspector)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return inspector.PrefixInfo(prefixes...)
+}
+
+// Compact compacts the keys with prefix in the underlying storage.  See
+// db.Compacter.
+func (s *Storage) Compact(prefix []byte) error {
+	compacter, ok := s.sto.(db.Compacter)
+	if !ok {
+		return ErrNotSupported
+	}
+	return compacter.Compact(prefix)
+}
+
+// WithPrefix returns a Storage for the keys with prefix.
+func (s *Storage) WithPrefix(prefix []byte) db.Storage {
+	fullPrefix := make([]byte, 0, len(s.prefix)+len(prefix))
+	fullPrefix = append(fullPrefix, s.prefix...)
+	fullPrefix = append(fullPrefix, prefix...)
+	return &Storage{sto: s.sto.WithPrefix(prefix), aead: s.aead, prefix: fullPrefix}
+}
+
+// NewTx returns a new transaction.
+func (s *Storage) NewTx() (db.Tx, error) {
+	tx, err := s.sto.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{tx: tx, sto: s}, nil
+}
+
+// Get returns the decrypted value of key.
+func (s *Storage) Get(key []byte) ([]byte, error) {
+	sealed, err := s.sto.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return s.open(key, sealed)
+}
+
+// Iterate calls f with every key and decrypted value in the storage.
+func (s *Storage) Iterate(f func([]byte, []byte) (bool, error)) error {
+	return s.sto.Iterate(func(key, sealed []byte) (bool, error) {
+		value, err := s.open(key, sealed)
+		if err != nil {
+			return false, err
+		}
+		return f(key, value)
+	})
+}
+
+// List returns up to limit keys with their decrypted values.
+func (s *Storage) List(limit int) ([]db.KV, error) {
+	ret := []db.KV{}
+	err := s.Iterate(func(key []byte, value []byte) (bool, error) {
+		k := make([]byte, len(key))
+		copy(k, key)
+		ret = append(ret, db.KV{K: k, V: value})
+		if len(ret) == limit {
+			return false, nil
+		}
+		return true, nil
+	})
+	return ret, err
+}
+
+// Close closes the underlying storage.
+func (s *Storage) Close() {
+	s.sto.Close()
+}
+
+// Get returns the decrypted value of key.
+func (tx *Tx) Get(key []byte) ([]byte, error) {
+	sealed, err := tx.tx.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return tx.sto.open(key, sealed)
+}
+
+// Put encrypts v and stores it under k when the transaction is committed.
+func (tx *Tx) Put(k, v []byte) {
+	tx.tx.Put(k, tx.sto.seal(k, v))
+}
+
+// Delete removes a key from the storage when the transaction is committed.
+func (tx *Tx) Delete(k []byte) {
+	tx.tx.Delete(k)
+}
+
+// Add adds the operations of atx, which must be a transaction of an
+// encrypted Storage over the same underlying storage, to the transaction.
+func (tx *Tx) Add(atx db.Tx) {
+	tx.tx.Add(atx.(*Tx).tx)
+}
+
+// Commit commits the transaction.
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+// Close closes the transaction.
+func (tx *Tx) Close() {
+	tx.tx.Close()
+}
@@ -0,0 +1,90 @@
+package encrypted
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testScryptN = 1 << 12
+	testScryptP = 6
+)
+
+func TestStorage(t *testing.T) {
+	var key [32]byte
+	key[0] = 1
+	underlying := db.NewMemoryStorage()
+	sto, err := NewStorage(underlying, &key)
+	require.Nil(t, err)
+
+	sto1 := sto.WithPrefix([]byte{1})
+	tx, err := sto1.NewTx()
+	require.Nil(t, err)
+	tx.Put([]byte{1}, []byte("secret claim 1"))
+	tx.Put([]byte{2}, []byte("secret claim 2"))
+	v, err := tx.Get([]byte{1})
+	require.Nil(t, err)
+	assert.Equal(t, []byte("secret claim 1"), v)
+	require.Nil(t, tx.Commit())
+
+	v, err = sto1.Get([]byte{2})
+	require.Nil(t, err)
+	assert.Equal(t, []byte("secret claim 2"), v)
+	_, err = sto1.Get([]byte{3})
+	assert.Equal(t, db.ErrNotFound, err)
+
+	kvs, err := sto1.List(10)
+	require.Nil(t, err)
+	assert.Equal(t, []db.KV{
+		{K: []byte{1}, V: []byte("secret claim 1")},
+		{K: []byte{2}, V: []byte("secret claim 2")},
+	}, kvs)
+
+	// The values in the underlying storage are encrypted
+	err = underlying.Iterate(func(k, v []byte) (bool, error) {
+		assert.False(t, bytes.Contains(v, []byte("secret")))
+		return true, nil
+	})
+	require.Nil(t, err)
+
+	// A value moved to a different key fails to decrypt
+	raw, err := underlying.WithPrefix(dataPrefix).WithPrefix([]byte{1}).Get([]byte{1})
+	require.Nil(t, err)
+	rawTx, err := underlying.WithPrefix(dataPrefix).WithPrefix([]byte{1}).NewTx()
+	require.Nil(t, err)
+	rawTx.Put([]byte{2}, raw)
+	require.Nil(t, rawTx.Commit())
+	_, err = sto1.Get([]byte{2})
+	assert.NotNil(t, err)
+
+	// A different key can't decrypt the values
+	key[0] = 2
+	stoOther, err := NewStorage(underlying, &key)
+	require.Nil(t, err)
+	_, err = stoOther.WithPrefix([]byte{1}).Get([]byte{1})
+	assert.NotNil(t, err)
+}
+
+func TestStoragePassphrase(t *testing.T) {
+	pass := []byte("my passphrase")
+	underlying := db.NewMemoryStorage()
+	sto, err := NewStorageWithPassphrase(underlying, pass, testScryptN, testScryptP)
+	require.Nil(t, err)
+	tx, err := sto.NewTx()
+	require.Nil(t, err)
+	tx.Put([]byte("k"), []byte("v"))
+	require.Nil(t, tx.Commit())
+
+	sto, err = NewStorageWithPassphrase(underlying, pass, testScryptN, testScryptP)
+	require.Nil(t, err)
+	v, err := sto.Get([]byte("k"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("v"), v)
+
+	_, err = NewStorageWithPassphrase(underlying, []byte("wrong"), testScryptN, testScryptP)
+	assert.Equal(t, ErrInvalidPassphrase, err)
+}
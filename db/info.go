@@ -0,0 +1,83 @@
+package db
+
+import (
+	"sort"
+)
+
+// maxGroupPrefixLen is the maximum length of the prefixes by which the keys
+// are grouped in PrefixInfo.
+const maxGroupPrefixLen = 32
+
+// groupPrefix returns the prefix of key ending at its first ':', if it's
+// made of lowercase letters, digits, '-' and '_', or "" otherwise.
+func groupPrefix(key []byte) string {
+	for i, b := range key {
+		if i > maxGroupPrefixLen {
+			break
+		}
+		switch {
+		case b == ':' && i > 0:
+			return string(key[:i+1])
+		case 'a' <= b && b <= 'z', '0' <= b && b <= '9', b == '-', b == '_':
+		default:
+			return ""
+		}
+	}
+	return ""
+}
+
+// prefixInfo computes the PrefixInfo of sto as described in
+// Inspector.PrefixInfo, calling diskSize to get the on-disk size of each
+// prefix.
+func prefixInfo(sto Storage, prefixes [][]byte, diskSize func(prefix []byte) (int64, error)) ([]PrefixInfo, error) {
+	var infos []PrefixInfo
+	if len(prefixes) != 0 {
+		infos = make([]PrefixInfo, len(prefixes))
+		for i, prefix := range prefixes {
+			info := &infos[i]
+			info.Prefix = string(prefix)
+			if err := sto.WithPrefix(prefix).Iterate(func(k, v []byte) (bool, error) {
+				info.KeyCount++
+				info.Bytes += int64(len(prefix) + len(k) + len(v))
+				return true, nil
+			}); err != nil {
+				return nil, err
+			}
+		}
+	} else {
+		groups := make(map[string]*PrefixInfo)
+		if err := sto.Iterate(func(k, v []byte) (bool, error) {
+			prefix := groupPrefix(k)
+			info, ok := groups[prefix]
+			if !ok {
+				info = &PrefixInfo{Prefix: prefix}
+				groups[prefix] = info
+			}
+			info.KeyCount++
+			info.Bytes += int64(len(k) + len(v))
+			return true, nil
+		}); err != nil {
+			return nil, err
+		}
+		infos = make([]PrefixInfo, 0, len(groups))
+		for _, info := range groups {
+			infos = append(infos, *info)
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Prefix < infos[j].Prefix })
+	}
+	if diskSize == nil {
+		return infos, nil
+	}
+	for i := range infos {
+		if infos[i].Prefix == "" && len(prefixes) == 0 {
+			// The ungrouped keys are scattered among the groups.
+			continue
+		}
+		size, err := diskSize([]byte(infos[i].Prefix))
+		if err != nil {
+			return nil, err
+		}
+		infos[i].DiskSize = size
+	}
+	return infos, nil
+}
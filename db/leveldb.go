@@ -18,6 +18,7 @@ type LevelDbStorage struct {
 type LevelDbStorageTx struct {
 	*LevelDbStorage
 	cache kvMap
+	del   kvMap
 }
 
 func NewLevelDbStorage(path string, errorIfMissing bool) (*LevelDbStorage, error) {
@@ -32,8 +33,8 @@ func NewLevelDbStorage(path string, errorIfMissing bool) (*LevelDbStorage, error
 }
 
 type storageInfo struct {
-	KeyCount   int
-	ClaimCount int
+	KeyCount int
+	Prefixes []PrefixInfo
 }
 
 func (l *LevelDbStorage) Info() string {
@@ -41,37 +42,59 @@ func (l *LevelDbStorage) Info() string {
 	if err != nil {
 		return err.Error()
 	}
+	defer snapshot.Release()
 
 	keycount := 0
-	claimcount := 0
 	iter := snapshot.NewIterator(nil, nil)
 	for iter.Next() {
-		if iter.Value()[0] == byte(1) { // TODO when the new merkletree version is ready, instead of byte(1) use the type indicator
-			claimcount++
-		}
-
 		keycount++
 	}
 	iter.Release()
 	if err := iter.Error(); err != nil {
 		return err.Error()
 	}
+	prefixes, err := l.PrefixInfo()
+	if err != nil {
+		return err.Error()
+	}
 	json, _ := json.MarshalIndent(
 		storageInfo{
-			KeyCount:   keycount,
-			ClaimCount: claimcount,
+			KeyCount: keycount,
+			Prefixes: prefixes,
 		},
 		"", "  ",
 	)
 	return string(json)
 }
 
+// PrefixInfo returns the space used by the keys with each of the prefixes,
+// or grouped by their prefix if no prefix is given.  See Inspector.
+func (l *LevelDbStorage) PrefixInfo(prefixes ...[]byte) ([]PrefixInfo, error) {
+	return prefixInfo(l, prefixes, func(prefix []byte) (int64, error) {
+		sizes, err := l.ldb.SizeOf([]util.Range{*util.BytesPrefix(concat(l.prefix, prefix))})
+		if err != nil {
+			return 0, err
+		}
+		return sizes.Sum(), nil
+	})
+}
+
+// Compact compacts the leveldb tables of the keys with prefix, or of all the
+// keys of the storage if prefix is empty.
+func (l *LevelDbStorage) Compact(prefix []byte) error {
+	fullPrefix := concat(l.prefix, prefix)
+	if len(fullPrefix) == 0 {
+		return l.ldb.CompactRange(util.Range{})
+	}
+	return l.ldb.CompactRange(*util.BytesPrefix(fullPrefix))
+}
+
 func (l *LevelDbStorage) WithPrefix(prefix []byte) Storage {
 	return &LevelDbStorage{l.ldb, concat(l.prefix, prefix)}
 }
 
 func (l *LevelDbStorage) NewTx() (Tx, error) {
-	return &LevelDbStorageTx{l, make(kvMap)}, nil
+	return &LevelDbStorageTx{l, make(kvMap), make(kvMap)}, nil
 }
 
 // Get retreives a value from a key in the mt.Lvl
@@ -89,6 +112,8 @@ func (l *LevelDbStorage) Iterate(f func([]byte, []byte) (bool, error)) error {
 	if err != nil {
 		return err
 	}
+	// Unreleased snapshots prevent the compaction of the deleted keys.
+	defer snapshot.Release()
 	iter := snapshot.NewIterator(util.BytesPrefix(l.prefix), nil)
 	defer iter.Release()
 	for iter.Next() {
@@ -109,6 +134,9 @@ func (l *LevelDbStorageTx) Get(key []byte) ([]byte, error) {
 
 	fullkey := concat(l.prefix, key)
 
+	if _, ok := l.del.Get(fullkey); ok {
+		return nil, ErrNotFound
+	}
 	if value, ok := l.cache.Get(fullkey); ok {
 		return value, nil
 	}
@@ -123,12 +151,24 @@ func (l *LevelDbStorageTx) Get(key []byte) ([]byte, error) {
 
 // Insert saves a key:value into the mt.Lvl
 func (tx *LevelDbStorageTx) Put(k, v []byte) {
+	tx.del.Delete(concat(tx.prefix, k[:]))
 	tx.cache.Put(concat(tx.prefix, k[:]), v)
 }
 
+// Delete removes a key from the mt.Lvl when the transaction is committed.
+func (tx *LevelDbStorageTx) Delete(k []byte) {
+	tx.cache.Delete(concat(tx.prefix, k[:]))
+	tx.del.Put(concat(tx.prefix, k[:]), nil)
+}
+
 func (tx *LevelDbStorageTx) Add(atx Tx) {
 	ldbtx := atx.(*LevelDbStorageTx)
+	for _, v := range ldbtx.del {
+		tx.cache.Delete(v.K)
+		tx.del.Put(v.K, nil)
+	}
 	for _, v := range ldbtx.cache {
+		tx.del.Delete(v.K)
 		tx.cache.Put(v.K, v.V)
 	}
 }
@@ -136,16 +176,21 @@ func (tx *LevelDbStorageTx) Add(atx Tx) {
 func (l *LevelDbStorageTx) Commit() error {
 
 	var batch leveldb.Batch
+	for _, v := range l.del {
+		batch.Delete(v.K)
+	}
 	for _, v := range l.cache {
 		batch.Put(v.K, v.V)
 	}
 
 	l.cache = nil
+	l.del = nil
 	return l.ldb.Write(&batch, nil)
 }
 
 func (l *LevelDbStorageTx) Close() {
 	l.cache = nil
+	l.del = nil
 }
 
 func (l *LevelDbStorage) Close() {
@@ -0,0 +1,222 @@
+// Package migrations upgrades the layout of a db.Storage by running ordered
+// migrations.  The schema version of a storage is stored in the key
+// "schemaversion", and is 0 for the storages created before it was
+// persisted.  Before running the pending migrations of a storage, a backup of
+// its previous version is taken, so that it can be restored if the upgrade
+// goes wrong.
+package migrations
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/db"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrVersionTooNew is used when a storage has a schema version newer
+	// than the latest migration, written by a newer version of the
+	// library.
+	ErrVersionTooNew = errors.New("the storage schema version is newer than the supported one")
+	// ErrVersionOutdated is used when a storage that can't be migrated has
+	// a schema version older than the latest migration.
+	ErrVersionOutdated = errors.New("the storage schema version is outdated")
+	// ErrInvalidMigrations is used when the versions of the migrations are
+	// not consecutive starting from 1.
+	ErrInvalidMigrations = errors.New("the migration versions must be consecutive starting from 1")
+)
+
+var (
+	dbKeySchemaVersion = []byte("schemaversion")
+	// dbPrefixBackup is the prefix of the backups taken by SelfBackup.
+	dbPrefixBackup = []byte("backup:")
+)
+
+// Migration upgrades a storage from the schema Version-1 to Version.
+type Migration struct {
+	Version     uint32
+	Description string
+	// Migrate applies the migration to the storage.  It must be
+	// idempotent: if the process stops after Migrate returns but before
+	// the new version is stored, it runs again.
+	Migrate func(storage db.Storage) error
+}
+
+// Backup takes a backup of storage at the schema version before migrating
+// it.
+type Backup func(storage db.Storage, version uint32) error
+
+// Latest returns the schema version after running migrations.
+func Latest(migrations []Migration) uint32 {
+	return uint32(len(migrations))
+}
+
+func validate(migrations []Migration) error {
+	for i, m := range migrations {
+		if m.Version != uint32(i+1) {
+			return ErrInvalidMigrations
+		}
+	}
+	return nil
+}
+
+// Version returns the schema version of storage.
+func Version(storage db.Storage) (uint32, error) {
+	tx, err := storage.NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	version, err := db.NewStorageValue(dbKeySchemaVersion).Get(tx)
+	if err == db.ErrNotFound {
+		return 0, nil
+	}
+	return version, err
+}
+
+// SetVersion sets the schema version in an open db transaction, to be used
+// when a storage is created with the latest layout.
+func SetVersion(tx db.Tx, version uint32) {
+	db.NewStorageValue(dbKeySchemaVersion).Set(tx, version)
+}
+
+// Check returns nil if the schema version of storage is the latest of
+// migrations, ErrVersionOutdated if it's older and ErrVersionTooNew if it's
+// newer.  It's used by the readers that must not migrate the storage.
+func Check(storage db.Storage, migrations []Migration) error {
+	version, err := Version(storage)
+	if err != nil {
+		return err
+	}
+	switch latest := Latest(migrations); {
+	case version < latest:
+		return ErrVersionOutdated
+	case version > latest:
+		return ErrVersionTooNew
+	}
+	return nil
+}
+
+// Run runs the migrations of storage newer than its schema version in order,
+// storing the version after each one.  If any migration is pending and
+// backup is not nil, backup is called first with the current version.  It
+// returns the version of the storage before running the migrations.
+func Run(storage db.Storage, migrations []Migration, backup Backup) (uint32, error) {
+	if err := validate(migrations); err != nil {
+		return 0, err
+	}
+	version, err := Version(storage)
+	if err != nil {
+		return 0, err
+	}
+	latest := Latest(migrations)
+	if version > latest {
+		return version, ErrVersionTooNew
+	}
+	if version == latest {
+		return version, nil
+	}
+	if backup != nil {
+		if err := backup(storage, version); err != nil {
+			return version, fmt.Errorf("backup of schema version %v: %w", version, err)
+		}
+	}
+	for _, m := range migrations[version:] {
+		log.WithField("version", m.Version).WithField("description", m.Description).
+			Info("Running storage migration")
+		if err := m.Migrate(storage); err != nil {
+			return version, fmt.Errorf("migration to schema version %v: %w", m.Version, err)
+		}
+		tx, err := storage.NewTx()
+		if err != nil {
+			return version, err
+		}
+		SetVersion(tx, m.Version)
+		if err := tx.Commit(); err != nil {
+			tx.Close()
+			return version, err
+		}
+	}
+	return version, nil
+}
+
+// backupPrefix returns the prefix of the backup of the schema version taken
+// by SelfBackup.
+func backupPrefix(version uint32) []byte {
+	return append(append([]byte{}, dbPrefixBackup...), []byte(fmt.Sprintf("v%d:", version))...)
+}
+
+func isBackupKey(k []byte) bool {
+	return bytes.HasPrefix(k, dbPrefixBackup)
+}
+
+// SelfBackup is a Backup that copies the keys of the storage under the prefix
+// "backup:v<version>:" of the same storage, skipping the previous backups.
+func SelfBackup(storage db.Storage, version uint32) error {
+	return copyKeys(storage.WithPrefix(backupPrefix(version)), storage, isBackupKey)
+}
+
+// BackupTo returns a Backup that copies the keys of the storage under the
+// prefix "backup:v<version>:" of dst.
+func BackupTo(dst db.Storage) Backup {
+	return func(storage db.Storage, version uint32) error {
+		return copyKeys(dst.WithPrefix(backupPrefix(version)), storage, func([]byte) bool { return false })
+	}
+}
+
+// Restore replaces the keys of storage, except the backups, with the ones of
+// the backup of version taken by SelfBackup.
+func Restore(storage db.Storage, version uint32) error {
+	backup := storage.WithPrefix(backupPrefix(version))
+	found := false
+	if err := backup.Iterate(func([]byte, []byte) (bool, error) {
+		found = true
+		return false, nil
+	}); err != nil {
+		return err
+	}
+	if !found {
+		return db.ErrNotFound
+	}
+	tx, err := storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := storage.Iterate(func(k, _ []byte) (bool, error) {
+		if !isBackupKey(k) {
+			tx.Delete(append([]byte{}, k...))
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	if err := backup.Iterate(func(k, v []byte) (bool, error) {
+		tx.Put(append([]byte{}, k...), append([]byte{}, v...))
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// copyKeys copies the keys of src, except the skipped ones, into dst in a
+// single transaction.
+func copyKeys(dst, src db.Storage, skip func(k []byte) bool) error {
+	tx, err := dst.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := src.Iterate(func(k, v []byte) (bool, error) {
+		if !skip(k) {
+			tx.Put(append([]byte{}, k...), append([]byte{}, v...))
+		}
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,102 @@
+package migrations
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func put(t *testing.T, storage db.Storage, k, v string) {
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	tx.Put([]byte(k), []byte(v))
+	require.Nil(t, tx.Commit())
+}
+
+func get(t *testing.T, storage db.Storage, k string) string {
+	v, err := storage.Get([]byte(k))
+	require.Nil(t, err)
+	return string(v)
+}
+
+func TestRun(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	put(t, storage, "name", "alice")
+	var ran []uint32
+	ms := []Migration{
+		{Version: 1, Description: "rename", Migrate: func(storage db.Storage) error {
+			ran = append(ran, 1)
+			put(t, storage, "fullname", get(t, storage, "name"))
+			return nil
+		}},
+		{Version: 2, Description: "add age", Migrate: func(storage db.Storage) error {
+			ran = append(ran, 2)
+			put(t, storage, "age", "30")
+			return nil
+		}},
+	}
+
+	from, err := Run(storage, ms, SelfBackup)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(0), from)
+	assert.Equal(t, []uint32{1, 2}, ran)
+	version, err := Version(storage)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(2), version)
+	assert.Nil(t, Check(storage, ms))
+	assert.Equal(t, "alice", get(t, storage, "fullname"))
+	assert.Equal(t, "alice", get(t, storage, "backup:v0:name"))
+
+	// Nothing to run
+	from, err = Run(storage, ms, SelfBackup)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(2), from)
+	assert.Equal(t, []uint32{1, 2}, ran)
+
+	// A new migration backs up the version 2 without the previous backup
+	ms = append(ms, Migration{Version: 3, Migrate: func(storage db.Storage) error {
+		return errors.New("boom")
+	}})
+	assert.Equal(t, ErrVersionOutdated, Check(storage, ms))
+	_, err = Run(storage, ms, SelfBackup)
+	assert.NotNil(t, err)
+	assert.Equal(t, "30", get(t, storage, "backup:v2:age"))
+	_, err = storage.Get([]byte("backup:v2:backup:v0:name"))
+	assert.Equal(t, db.ErrNotFound, err)
+	version, err = Version(storage)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(2), version)
+
+	// Restore the version 0
+	require.Nil(t, Restore(storage, 0))
+	version, err = Version(storage)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(0), version)
+	_, err = storage.Get([]byte("fullname"))
+	assert.Equal(t, db.ErrNotFound, err)
+	assert.Equal(t, "alice", get(t, storage, "name"))
+	assert.Equal(t, db.ErrNotFound, Restore(storage, 5))
+
+}
+
+func TestRunInvalid(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	_, err := Run(storage, []Migration{{Version: 2}}, nil)
+	assert.Equal(t, ErrInvalidMigrations, err)
+
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	SetVersion(tx, 3)
+	require.Nil(t, tx.Commit())
+	_, err = Run(storage, nil, nil)
+	assert.Equal(t, ErrVersionTooNew, err)
+	assert.Equal(t, ErrVersionTooNew, Check(storage, nil))
+
+	dst := db.NewMemoryStorage()
+	require.Nil(t, BackupTo(dst)(storage, 3))
+	_, err = dst.Get([]byte("backup:v3:schemaversion"))
+	assert.Nil(t, err)
+}
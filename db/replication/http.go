@@ -0,0 +1,97 @@
+package replication
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathWAL is the path of the WAL endpoint.
+const PathWAL = "/replication/wal"
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// Handler returns an http.Handler that serves the WAL of l.  The response is
+// the JSON list of Entries, or 410 Gone if the entries have been truncated.
+// It must only be exposed to the followers, as the entries contain the raw
+// values of the storage.
+func Handler(l *Leader) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathWAL, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodGet {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		query := req.URL.Query()
+		from, err := strconv.ParseUint(query.Get("from"), 10, 64)
+		if err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid from")
+			return
+		}
+		limit := MaxEntries
+		if v := query.Get("limit"); v != "" {
+			if limit, err = strconv.Atoi(v); err != nil {
+				httpjson.WriteError(w, http.StatusBadRequest, "invalid limit")
+				return
+			}
+		}
+		entries, err := l.Entries(from, limit)
+		if err == ErrWALTruncated {
+			httpjson.WriteError(w, http.StatusGone, err.Error())
+			return
+		} else if err != nil {
+			log.WithError(err).Error("Entries")
+			httpjson.WriteError(w, http.StatusInternalServerError, "internal error")
+			return
+		}
+		httpjson.Write(w, http.StatusOK, entries)
+	})
+	return mux
+}
+
+// HTTPSource is a Source that fetches the entries from the Handler of a
+// leader at URL.
+type HTTPSource struct {
+	URL    string
+	Client *http.Client
+}
+
+// NewHTTPSource returns an HTTPSource for the leader at baseURL.
+func NewHTTPSource(baseURL string) *HTTPSource {
+	return &HTTPSource{URL: baseURL, Client: &http.Client{Timeout: 30 * time.Second}}
+}
+
+// Entries fetches the entries of the WAL.  See Source.
+func (s *HTTPSource) Entries(from uint64, limit int) ([]Entry, error) {
+	query := url.Values{}
+	query.Set("from", strconv.FormatUint(from, 10))
+	query.Set("limit", strconv.Itoa(limit))
+	res, err := s.Client.Get(s.URL + PathWAL + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK:
+	case http.StatusGone:
+		return nil, ErrWALTruncated
+	default:
+		var e Error
+		if err := json.NewDecoder(res.Body).Decode(&e); err != nil {
+			return nil, fmt.Errorf("leader responded with status %v", res.StatusCode)
+		}
+		return nil, fmt.Errorf("leader responded with status %v: %v", res.StatusCode, e.Error)
+	}
+	var entries []Entry
+	if err := json.NewDecoder(res.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	return entries, nil
+}
@@ -0,0 +1,380 @@
+// Package replication replicates a db.Storage from a leader to read-only
+// followers by shipping a write-ahead log (WAL) of the committed
+// transactions.  The Leader wraps the storage of the writer process and
+// records the puts and deletes of each committed transaction as an Entry of
+// the WAL, in the same storage and atomically with the transaction.  A
+// Follower fetches the entries newer than the last applied one from a Source
+// and applies them in order to its own copy of the storage, so that
+// read-only instances (like issuer.ReadOnly) can serve from it.
+//
+// A follower is bootstrapped from a checkpoint of the leader storage (see
+// Leader.Checkpoint), which contains the sequence number of the last entry
+// it includes.  The WAL is served to the followers over HTTP by Handler:
+//
+//	GET /replication/wal?from=<seq>&limit=<n>
+//
+// and fetched with HTTPSource.
+package replication
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+// MaxEntries is the maximum number of entries returned at once by
+// Leader.Entries.
+const MaxEntries = 1000
+
+var (
+	// ErrWALTruncated is used when the entries requested by a follower
+	// have been removed from the WAL with Leader.Truncate.  The follower
+	// must be bootstrapped again from a newer checkpoint.
+	ErrWALTruncated = errors.New("the requested WAL entries have been truncated")
+	// ErrSeqGap is used when a follower receives an entry that doesn't
+	// follow the last applied one.
+	ErrSeqGap = errors.New("the WAL entry doesn't follow the last applied one")
+)
+
+var (
+	dbKeySeq      = []byte("replseq")
+	dbPrefixWAL   = []byte("replwal:")
+	dbKeyWALStart = []byte("replwalstart")
+)
+
+// Op is a put, or delete, of a key of the storage.
+type Op struct {
+	Key    []byte `json:"key"`
+	Value  []byte `json:"value,omitempty"`
+	Delete bool   `json:"delete,omitempty"`
+}
+
+// Entry is a committed transaction, with its operations in order.
+type Entry struct {
+	Seq uint64 `json:"seq"`
+	Ops []Op   `json:"ops"`
+}
+
+// Source provides the WAL entries to a Follower.
+type Source interface {
+	// Entries returns up to limit entries of the WAL starting at the
+	// sequence number from, or ErrWALTruncated if from is no longer in
+	// the WAL.
+	Entries(from uint64, limit int) ([]Entry, error)
+}
+
+func seqBytes(seq uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], seq)
+	return b[:]
+}
+
+func walKey(seq uint64) []byte {
+	return append(append([]byte{}, dbPrefixWAL...), seqBytes(seq)...)
+}
+
+// getSeq returns the uint64 stored at key, or 0 if it's not stored.
+func getSeq(get func([]byte) ([]byte, error), key []byte) (uint64, error) {
+	b, err := get(key)
+	if err == db.ErrNotFound {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(b) != 8 {
+		return 0, errors.New("invalid replication sequence number")
+	}
+	return binary.BigEndian.Uint64(b), nil
+}
+
+// Leader is a db.Storage that records the committed transactions in a WAL.
+// The WAL and the sequence number of its last entry are stored under the
+// keys "replwal:" and "replseq" of the wrapped storage, which must not be
+// used otherwise.
+type Leader struct {
+	sto    db.Storage
+	prefix []byte
+	mutex  *sync.Mutex
+}
+
+// NewLeader returns a Leader that wraps sto.
+func NewLeader(sto db.Storage) *Leader {
+	return &Leader{sto: sto, prefix: []byte{}, mutex: &sync.Mutex{}}
+}
+
+func (l *Leader) key(k []byte) []byte {
+	return append(append([]byte{}, l.prefix...), k...)
+}
+
+// Info returns information about the wrapped storage.
+func (l *Leader) Info() string {
+	return "replication leader " + l.sto.Info()
+}
+
+// WithPrefix returns a Storage for the keys with prefix, whose transactions
+// are recorded in the same WAL.
+func (l *Leader) WithPrefix(prefix []byte) db.Storage {
+	return &Leader{sto: l.sto, prefix: l.key(prefix), mutex: l.mutex}
+}
+
+// Get returns the value of key.
+func (l *Leader) Get(key []byte) ([]byte, error) {
+	return l.sto.Get(l.key(key))
+}
+
+// Iterate calls f with every key and value of the storage.
+func (l *Leader) Iterate(f func([]byte, []byte) (bool, error)) error {
+	return l.sto.WithPrefix(l.prefix).Iterate(f)
+}
+
+// List returns up to limit keys and values of the storage.
+func (l *Leader) List(limit int) ([]db.KV, error) {
+	return l.sto.WithPrefix(l.prefix).List(limit)
+}
+
+// Close closes the wrapped storage.
+func (l *Leader) Close() {
+	l.sto.Close()
+}
+
+// NewTx returns a new transaction that is recorded in the WAL on commit.
+func (l *Leader) NewTx() (db.Tx, error) {
+	tx, err := l.sto.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	return &leaderTx{tx: tx, l: l}, nil
+}
+
+// Checkpoint writes a consistent snapshot of the whole wrapped storage,
+// regardless of the prefix, including the sequence number of the last entry
+// of the WAL, to bootstrap a Follower.  See db.Checkpointer.
+func (l *Leader) Checkpoint(path string) error {
+	checkpointer, ok := l.sto.(db.Checkpointer)
+	if !ok {
+		return errors.New("the leader storage doesn't support checkpoints")
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return checkpointer.Checkpoint(path)
+}
+
+// Seq returns the sequence number of the last entry of the WAL.
+func (l *Leader) Seq() (uint64, error) {
+	return getSeq(l.sto.Get, dbKeySeq)
+}
+
+// Entries returns up to limit (at most MaxEntries) entries of the WAL
+// starting at the sequence number from.  See Source.
+func (l *Leader) Entries(from uint64, limit int) ([]Entry, error) {
+	if limit <= 0 || limit > MaxEntries {
+		limit = MaxEntries
+	}
+	start, err := getSeq(l.sto.Get, dbKeyWALStart)
+	if err != nil {
+		return nil, err
+	}
+	if from < start {
+		return nil, ErrWALTruncated
+	}
+	entries := []Entry{}
+	for seq := from; len(entries) < limit; seq++ {
+		b, err := l.sto.Get(walKey(seq))
+		if err == db.ErrNotFound {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		var entry Entry
+		if err := json.Unmarshal(b, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// Truncate removes the entries of the WAL older than the sequence number
+// before, to bound the storage used by the WAL.  The followers that haven't
+// applied them must be bootstrapped again from a newer checkpoint.
+func (l *Leader) Truncate(before uint64) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if start, err := getSeq(l.sto.Get, dbKeyWALStart); err != nil {
+		return err
+	} else if before <= start {
+		return nil
+	}
+	tx, err := l.sto.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := l.sto.WithPrefix(dbPrefixWAL).Iterate(func(k, _ []byte) (bool, error) {
+		if bytes.Compare(k, seqBytes(before)) >= 0 {
+			return false, nil
+		}
+		tx.Delete(append(append([]byte{}, dbPrefixWAL...), k...))
+		return true, nil
+	}); err != nil {
+		return err
+	}
+	tx.Put(dbKeyWALStart, seqBytes(before))
+	return tx.Commit()
+}
+
+// leaderTx is a db.Tx of a Leader that records its operations.
+type leaderTx struct {
+	tx  db.Tx
+	l   *Leader
+	ops []Op
+}
+
+func (tx *leaderTx) Get(key []byte) ([]byte, error) {
+	return tx.tx.Get(tx.l.key(key))
+}
+
+func (tx *leaderTx) Put(k, v []byte) {
+	key := tx.l.key(k)
+	tx.tx.Put(key, v)
+	tx.ops = append(tx.ops, Op{Key: key, Value: append([]byte{}, v...)})
+}
+
+func (tx *leaderTx) Delete(k []byte) {
+	key := tx.l.key(k)
+	tx.tx.Delete(key)
+	tx.ops = append(tx.ops, Op{Key: key, Delete: true})
+}
+
+func (tx *leaderTx) Add(atx db.Tx) {
+	ltx := atx.(*leaderTx)
+	tx.tx.Add(ltx.tx)
+	tx.ops = append(tx.ops, ltx.ops...)
+}
+
+// Commit commits the transaction together with its WAL entry.
+func (tx *leaderTx) Commit() error {
+	if len(tx.ops) == 0 {
+		return tx.tx.Commit()
+	}
+	tx.l.mutex.Lock()
+	defer tx.l.mutex.Unlock()
+	seq, err := getSeq(tx.tx.Get, dbKeySeq)
+	if err != nil {
+		return err
+	}
+	seq++
+	entryJSON, err := json.Marshal(Entry{Seq: seq, Ops: tx.ops})
+	if err != nil {
+		return err
+	}
+	tx.tx.Put(walKey(seq), entryJSON)
+	tx.tx.Put(dbKeySeq, seqBytes(seq))
+	tx.ops = nil
+	return tx.tx.Commit()
+}
+
+func (tx *leaderTx) Close() {
+	tx.ops = nil
+	tx.tx.Close()
+}
+
+// Follower applies the WAL of a Leader to a copy of its storage.
+type Follower struct {
+	sto   db.Storage
+	src   Source
+	mutex sync.Mutex
+}
+
+// NewFollower returns a Follower that applies the entries of src to sto,
+// which is a checkpoint of the leader storage or a storage with an
+// already applied WAL.
+func NewFollower(sto db.Storage, src Source) *Follower {
+	return &Follower{sto: sto, src: src}
+}
+
+// Seq returns the sequence number of the last applied entry.
+func (f *Follower) Seq() (uint64, error) {
+	return getSeq(f.sto.Get, dbKeySeq)
+}
+
+// Sync applies the entries of the Source newer than the last applied one,
+// each in its own transaction, until there are no more.  It returns the
+// number of applied entries.
+func (f *Follower) Sync() (int, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	applied := 0
+	for {
+		seq, err := f.Seq()
+		if err != nil {
+			return applied, err
+		}
+		entries, err := f.src.Entries(seq+1, MaxEntries)
+		if err != nil {
+			return applied, err
+		}
+		if len(entries) == 0 {
+			return applied, nil
+		}
+		for _, entry := range entries {
+			if entry.Seq != seq+1 {
+				return applied, ErrSeqGap
+			}
+			if err := f.apply(&entry); err != nil {
+				return applied, err
+			}
+			seq = entry.Seq
+			applied++
+		}
+	}
+}
+
+func (f *Follower) apply(entry *Entry) error {
+	tx, err := f.sto.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	for _, op := range entry.Ops {
+		if op.Delete {
+			tx.Delete(op.Key)
+		} else {
+			tx.Put(op.Key, op.Value)
+		}
+	}
+	tx.Put(dbKeySeq, seqBytes(entry.Seq))
+	return tx.Commit()
+}
+
+// Run calls Sync every interval until ctx is done, calling onApplied after
+// each Sync that applied entries, like issuer.ReadOnly.Reload to serve the
+// newly replicated data.  The errors are logged and the next Sync retries.
+func (f *Follower) Run(ctx context.Context, clk clock.Clock, interval time.Duration, onApplied func() error) {
+	ticker := clk.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			applied, err := f.Sync()
+			if err != nil {
+				log.WithError(err).WithField("applied", applied).Error("Follower: sync failed")
+			}
+			if applied != 0 && onApplied != nil {
+				if err := onApplied(); err != nil {
+					log.WithError(err).Error("Follower: onApplied failed")
+				}
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
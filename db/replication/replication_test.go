@@ -0,0 +1,98 @@
+package replication
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func put(t *testing.T, sto db.Storage, k, v string) {
+	tx, err := sto.NewTx()
+	require.Nil(t, err)
+	tx.Put([]byte(k), []byte(v))
+	require.Nil(t, tx.Commit())
+}
+
+func TestReplication(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replication")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	leaderSto, err := db.NewLevelDbStorage(filepath.Join(dir, "leader"), false)
+	require.Nil(t, err)
+	leader := NewLeader(leaderSto)
+	defer leader.Close()
+	claims := leader.WithPrefix([]byte("claims:"))
+	put(t, claims, "a", "1")
+
+	// Bootstrap the follower from a checkpoint
+	checkpointPath := filepath.Join(dir, "follower")
+	require.Nil(t, leader.Checkpoint(checkpointPath))
+	followerSto, err := db.NewLevelDbStorage(checkpointPath, true)
+	require.Nil(t, err)
+	defer followerSto.Close()
+
+	server := httptest.NewServer(Handler(leader))
+	defer server.Close()
+	follower := NewFollower(followerSto, NewHTTPSource(server.URL))
+	seq, err := follower.Seq()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(1), seq)
+
+	put(t, claims, "b", "2")
+	tx, err := claims.NewTx()
+	require.Nil(t, err)
+	tx.Delete([]byte("a"))
+	tx2, err := leader.NewTx()
+	require.Nil(t, err)
+	tx2.Put([]byte("roots:c"), []byte("3"))
+	tx.Add(tx2)
+	require.Nil(t, tx.Commit())
+	// Empty transactions are not recorded
+	tx, err = leader.NewTx()
+	require.Nil(t, err)
+	require.Nil(t, tx.Commit())
+	seq, err = leader.Seq()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(3), seq)
+
+	applied, err := follower.Sync()
+	require.Nil(t, err)
+	assert.Equal(t, 2, applied)
+	v, err := followerSto.Get([]byte("claims:b"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("2"), v)
+	_, err = followerSto.Get([]byte("claims:a"))
+	assert.Equal(t, db.ErrNotFound, err)
+	v, err = followerSto.Get([]byte("roots:c"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("3"), v)
+	applied, err = follower.Sync()
+	require.Nil(t, err)
+	assert.Equal(t, 0, applied)
+
+	// Truncated entries can't be fetched
+	put(t, claims, "d", "4")
+	require.Nil(t, leader.Truncate(4))
+	entries, err := leader.Entries(4, 0)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(entries))
+	_, err = leader.Entries(3, 0)
+	assert.Equal(t, ErrWALTruncated, err)
+	_, err = NewHTTPSource(server.URL).Entries(1, 10)
+	assert.Equal(t, ErrWALTruncated, err)
+	applied, err = follower.Sync()
+	require.Nil(t, err)
+	assert.Equal(t, 1, applied)
+
+	// A stale follower must be bootstrapped again
+	stale := NewFollower(db.NewMemoryStorage(), leader)
+	_, err = stale.Sync()
+	assert.Equal(t, ErrWALTruncated, err)
+}
@@ -21,9 +21,40 @@ type Storage interface {
 	Iterate(func([]byte, []byte) (bool, error)) error
 }
 
+// PrefixInfo is the space used by the keys with Prefix in a Storage.
+type PrefixInfo struct {
+	Prefix string
+	// KeyCount is the number of keys.
+	KeyCount int
+	// Bytes is the total length of the keys and values.
+	Bytes int64
+	// DiskSize is the approximate on-disk size of the keys, or 0 for
+	// the storages that are not persisted.
+	DiskSize int64
+}
+
+// Inspector is implemented by the Storages that can report the space used by
+// their keys.
+type Inspector interface {
+	// PrefixInfo returns the PrefixInfo of each of the prefixes, or, if no
+	// prefix is given, of the keys grouped by the prefix ending at their
+	// first ':' (like "treeclaims:" or "idenstates:").  The keys without
+	// such a prefix are grouped in the "" prefix.
+	PrefixInfo(prefixes ...[]byte) ([]PrefixInfo, error)
+}
+
+// Compacter is implemented by the Storages that can compact their underlying
+// files to reclaim the space of the deleted and overwritten keys.
+type Compacter interface {
+	// Compact compacts the keys with prefix, or all the keys of the
+	// Storage if prefix is empty.
+	Compact(prefix []byte) error
+}
+
 type Tx interface {
 	Get([]byte) ([]byte, error)
 	Put(k, v []byte)
+	Delete(k []byte)
 	Add(Tx)
 	Commit() error
 	Close()
@@ -1,8 +1,11 @@
 package db
 
 import (
+	"crypto/rand"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -163,6 +166,34 @@ func testConcatTx(t *testing.T, sto Storage) {
 	assert.Equal(t, v2, []byte{8, 9})
 }
 
+func testDelete(t *testing.T, sto Storage) {
+	sto1 := sto.WithPrefix([]byte{1})
+	tx, err := sto1.NewTx()
+	assert.Nil(t, err)
+	tx.Put([]byte{1}, []byte{4})
+	tx.Put([]byte{2}, []byte{5})
+	assert.Nil(t, tx.Commit())
+
+	tx, err = sto1.NewTx()
+	assert.Nil(t, err)
+	tx.Delete([]byte{1})
+	_, err = tx.Get([]byte{1})
+	assert.Equal(t, ErrNotFound, err)
+	// Not deleted until commit
+	v, err := sto1.Get([]byte{1})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{4}, v)
+	tx.Delete([]byte{2})
+	tx.Put([]byte{2}, []byte{6})
+	assert.Nil(t, tx.Commit())
+
+	_, err = sto1.Get([]byte{1})
+	assert.Equal(t, ErrNotFound, err)
+	v, err = sto1.Get([]byte{2})
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{6}, v)
+}
+
 func testList(t *testing.T, sto Storage) {
 	sto1 := sto.WithPrefix([]byte{1})
 	r1, err := sto1.List(100)
@@ -197,13 +228,114 @@ func testList(t *testing.T, sto Storage) {
 
 }
 
+func testPrefixInfo(t *testing.T, sto Storage) {
+	tx, err := sto.NewTx()
+	assert.Nil(t, err)
+	tx.Put([]byte("treeclaims:a"), []byte{1, 2, 3})
+	tx.Put([]byte("treeclaims:b"), []byte{4})
+	tx.Put([]byte("idenstates:a"), []byte{5})
+	tx.Put([]byte{0xff, ':'}, []byte{6})
+	assert.Nil(t, tx.Commit())
+
+	infos, err := sto.(Inspector).PrefixInfo()
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(infos))
+	assert.Equal(t, "", infos[0].Prefix)
+	assert.Equal(t, 1, infos[0].KeyCount)
+	assert.Equal(t, int64(3), infos[0].Bytes)
+	assert.Equal(t, "idenstates:", infos[1].Prefix)
+	assert.Equal(t, 1, infos[1].KeyCount)
+	assert.Equal(t, "treeclaims:", infos[2].Prefix)
+	assert.Equal(t, 2, infos[2].KeyCount)
+	assert.Equal(t, int64(28), infos[2].Bytes)
+
+	infos, err = sto.WithPrefix([]byte("tree")).(Inspector).PrefixInfo([]byte("claims:"), []byte("roots:"))
+	assert.Nil(t, err)
+	assert.Equal(t, []PrefixInfo{
+		{Prefix: "claims:", KeyCount: 2, Bytes: 20, DiskSize: infos[0].DiskSize},
+		{Prefix: "roots:", KeyCount: 0, Bytes: 0, DiskSize: infos[1].DiskSize},
+	}, infos)
+}
+
+func TestLevelDbCompact(t *testing.T) {
+	sto := levelDbStorage(t).(*LevelDbStorage)
+	tx, err := sto.NewTx()
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		value := make([]byte, 1024)
+		_, err := rand.Read(value)
+		assert.Nil(t, err)
+		tx.Put([]byte(fmt.Sprintf("cache:%04d", i)), value)
+	}
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, sto.Compact(nil))
+	infos, err := sto.PrefixInfo([]byte("cache:"))
+	assert.Nil(t, err)
+	assert.Equal(t, 1000, infos[0].KeyCount)
+	size := infos[0].DiskSize
+	assert.True(t, size > 0)
+
+	tx, err = sto.NewTx()
+	assert.Nil(t, err)
+	for i := 0; i < 1000; i++ {
+		tx.Delete([]byte(fmt.Sprintf("cache:%04d", i)))
+	}
+	assert.Nil(t, tx.Commit())
+	assert.Nil(t, sto.WithPrefix([]byte("cache:")).(Compacter).Compact(nil))
+	infos, err = sto.PrefixInfo([]byte("cache:"))
+	assert.Nil(t, err)
+	assert.Equal(t, 0, infos[0].KeyCount)
+	assert.True(t, infos[0].DiskSize < size/10)
+	assert.NotContains(t, sto.Info(), "cache:")
+}
+
+func testCheckpoint(t *testing.T, sto Storage) {
+	dir, err := ioutil.TempDir("", "checkpoint")
+	assert.Nil(t, err)
+	rmDirs = append(rmDirs, dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	tx, err := sto.NewTx()
+	assert.Nil(t, err)
+	for i := 0; i < 2*checkpointBatchLen+1; i++ {
+		tx.Put([]byte(fmt.Sprintf("claims:%04d", i)), []byte{byte(i)})
+	}
+	tx.Put([]byte("roots:0"), []byte{1})
+	assert.Nil(t, tx.Commit())
+
+	prefixed := sto.WithPrefix([]byte("claims:"))
+	assert.Nil(t, prefixed.(Checkpointer).Checkpoint(path))
+	assert.Equal(t, ErrCheckpointExists, prefixed.(Checkpointer).Checkpoint(path))
+
+	// Writes after the checkpoint are not in it
+	tx, err = sto.NewTx()
+	assert.Nil(t, err)
+	tx.Put([]byte("claims:9999"), []byte{1})
+	assert.Nil(t, tx.Commit())
+
+	checkpoint, err := NewLevelDbStorage(path, true)
+	assert.Nil(t, err)
+	defer checkpoint.Close()
+	kvs, err := checkpoint.List(0)
+	assert.Nil(t, err)
+	assert.Equal(t, 2*checkpointBatchLen+1, len(kvs))
+	v, err := checkpoint.Get([]byte("0003"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte{3}, v)
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+}
+
 func TestLevelDb(t *testing.T) {
 	testReturnKnownErrIfNotExists(t, levelDbStorage(t))
 	testStorageInsertGet(t, levelDbStorage(t))
 	testStorageWithPrefix(t, levelDbStorage(t))
 	testConcatTx(t, levelDbStorage(t))
+	testDelete(t, levelDbStorage(t))
 	testList(t, levelDbStorage(t))
 	testIterate(t, levelDbStorage(t))
+	testPrefixInfo(t, levelDbStorage(t))
+	testCheckpoint(t, levelDbStorage(t))
 }
 
 func TestMemory(t *testing.T) {
@@ -211,8 +343,11 @@ func TestMemory(t *testing.T) {
 	testStorageInsertGet(t, NewMemoryStorage())
 	testStorageWithPrefix(t, NewMemoryStorage())
 	testConcatTx(t, NewMemoryStorage())
+	testDelete(t, NewMemoryStorage())
 	testList(t, NewMemoryStorage())
 	testIterate(t, NewMemoryStorage())
+	testPrefixInfo(t, NewMemoryStorage())
+	testCheckpoint(t, NewMemoryStorage())
 }
 
 func TestMain(m *testing.M) {
@@ -97,6 +97,21 @@ func (sl *StorageList) Get(tx Tx, key []byte, value interface{}) error {
 	return err
 }
 
+// DeleteByIdx removes the entry at index idx of the StorageList in an open db
+// transaction.  The indexes of the rest of entries and the Length are not
+// modified, and getting a removed entry returns ErrNotFound.
+func (sl *StorageList) DeleteByIdx(tx Tx, idx uint32) error {
+	var idxBytes [4]byte
+	binary.LittleEndian.PutUint32(idxBytes[:], idx)
+	key, err := tx.Get(append(sl.dbPrefixListByIdx, idxBytes[:]...))
+	if err != nil {
+		return err
+	}
+	tx.Delete(append(sl.dbPrefixList, key...))
+	tx.Delete(append(sl.dbPrefixListByIdx, idxBytes[:]...))
+	return nil
+}
+
 // Length returns the number of elements in the StorageList in an open db transaction.
 func (sl *StorageList) Length(tx Tx) (uint32, error) {
 	return sl.length.Get(tx)
@@ -76,4 +76,23 @@ func TestStorageList(t *testing.T) {
 		require.Equal(t, kv.Key, key)
 	}
 	tx.Close()
+
+	tx, err = storage.NewTx()
+	require.Nil(t, err)
+	require.Nil(t, sl.DeleteByIdx(tx, 1))
+	require.Nil(t, tx.Commit())
+
+	tx, err = storage.NewTx()
+	require.Nil(t, err)
+	var value Entry
+	_, err = sl.GetByIdx(tx, 1, &value)
+	require.Equal(t, ErrNotFound, err)
+	err = sl.Get(tx, entries[1].Key, &value)
+	require.Equal(t, ErrNotFound, err)
+	_, err = sl.GetByIdx(tx, 2, &value)
+	require.Nil(t, err)
+	length, err := sl.Length(tx)
+	require.Nil(t, err)
+	require.Equal(t, uint32(len(entries)), length)
+	tx.Close()
 }
@@ -4,13 +4,14 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"sync"
 	"time"
 
+	ethereum "github.com/ethereum/go-ethereum"
 	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/accounts/abi/bind"
 	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
-
-	// "github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/ethclient"
 
@@ -19,58 +20,290 @@ import (
 
 var (
 	ErrAccountNil = fmt.Errorf("Authorized calls can't be made when the account is nil")
+	// ErrAccountNotInKeyStore is used when the account set in a Client2
+	// is not in its keystore.
+	ErrAccountNotInKeyStore = fmt.Errorf("account not found in the keystore")
 )
 
+// TxMeta describes why a transaction is sent by a Client2.
+type TxMeta struct {
+	// Purpose is a short name of the action done by the transaction,
+	// like the Smart Contract method called.
+	Purpose string
+	// Identity is the identity on whose behalf the transaction is sent, if
+	// any.
+	Identity string
+}
+
+// TxRecorder records the transactions sent by a Client2.
+type TxRecorder interface {
+	RecordTx(tx *types.Transaction, meta TxMeta) error
+}
+
 // Client2 is an ethereum client to call Smart Contract methods.
 type Client2 struct {
 	client         *ethclient.Client
 	account        *accounts.Account
 	ks             *ethkeystore.KeyStore
 	ReceiptTimeout time.Duration
+	rw             sync.RWMutex
+	customErrs     map[[4]byte]customError
+	txRecorder     TxRecorder
 }
 
 // NewClient2 creates a Client2 instance.  The account is not mandatory (it can
 // be nil).  If the account is nil, CallAuth will fail with ErrAccountNil.
 func NewClient2(client *ethclient.Client, account *accounts.Account, ks *ethkeystore.KeyStore) *Client2 {
-	return &Client2{client: client, account: account, ks: ks, ReceiptTimeout: 60 * time.Second}
+	return &Client2{client: client, account: account, ks: ks, ReceiptTimeout: 60 * time.Second,
+		customErrs: make(map[[4]byte]customError)}
 }
 
+// RegisterErrors registers the solidity custom errors declared in the JSON
+// ABI of a contract, so that they are decoded in the ContractRevertError
+// reasons.
+func (c *Client2) RegisterErrors(abiJSON string) error {
+	customErrs, err := parseCustomErrors(abiJSON)
+	if err != nil {
+		return err
+	}
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	for selector, customErr := range customErrs {
+		c.customErrs[selector] = customErr
+	}
+	return nil
+}
+
+// SetTxRecorder sets the TxRecorder where every transaction sent by CallAuth
+// is recorded.
+func (c *Client2) SetTxRecorder(txRecorder TxRecorder) {
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	c.txRecorder = txRecorder
+}
+
+// Account returns the account of the authorized calls, or nil if it's not
+// set.
+func (c *Client2) Account() *accounts.Account {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	return c.account
+}
+
+// SetAccount sets the account of the next authorized calls, which must be in
+// the keystore, so that the relay can rotate its account without a restart.
+// The calls in progress keep using the previous account.
+func (c *Client2) SetAccount(address common.Address) error {
+	if c.ks == nil {
+		return ErrAccountNotInKeyStore
+	}
+	account, err := c.ks.Find(accounts.Account{Address: address})
+	if err != nil {
+		return ErrAccountNotInKeyStore
+	}
+	c.rw.Lock()
+	defer c.rw.Unlock()
+	c.account = &account
+	return nil
+}
+
+// revertErr returns a ContractRevertError if data is the revert data of a
+// reverted call.
+func (c *Client2) revertErr(data []byte) (*ContractRevertError, bool) {
+	c.rw.RLock()
+	defer c.rw.RUnlock()
+	reason, ok := unpackRevertReason(data, c.customErrs)
+	if !ok {
+		return nil, false
+	}
+	return &ContractRevertError{Reason: reason}, true
+}
+
+// decodeErr returns a ContractRevertError if err is the error returned by
+// the node for a reverted call, or err otherwise.
+func (c *Client2) decodeErr(err error) error {
+	if reason, ok := revertReasonFromErr(err); ok {
+		return &ContractRevertError{Reason: reason}
+	}
+	return err
+}
+
+// CallOpts are the transaction parameters of an authorized call.  The zero
+// fields take the default values: the pending nonce of the account, the gas
+// price suggested by the node, DefaultGasLimit and no value.  The go-ethereum
+// version used doesn't support EIP-1559 transactions, so the fees are set
+// with the legacy GasPrice.
+type CallOpts struct {
+	// Nonce pins the nonce of the transaction, to replace a pending one
+	// or to send several transactions without waiting.
+	Nonce *big.Int
+	// GasLimit is the gas limit of the transaction, in units.
+	GasLimit uint64
+	// GasPrice is the gas price of the transaction, in wei.
+	GasPrice *big.Int
+	// Value is the ether sent with the transaction, in wei.
+	Value *big.Int
+}
+
+// DefaultGasLimit is the gas limit of the authorized calls whose CallOpts
+// don't set it.
+const DefaultGasLimit = uint64(300000)
+
 // CallAuth performs a Smart Contract method call that requires authorization.
 // This call requires a valid account with Ether that can be spend during the
-// call.
-func (c *Client2) CallAuth(fn func(*ethclient.Client, *bind.TransactOpts) (*types.Transaction, error)) (*types.Transaction, error) {
-	if c.account == nil {
-		return nil, ErrAccountNil
+// call.  If the call reverts, a ContractRevertError is returned.  The
+// transaction parameters can be set with opts.
+func (c *Client2) CallAuth(fn func(*ethclient.Client, *bind.TransactOpts) (*types.Transaction, error),
+	opts ...*CallOpts) (*types.Transaction, error) {
+	return c.CallAuthMeta(TxMeta{}, fn, opts...)
+}
+
+// CallAuthMeta performs a Smart Contract method call that requires
+// authorization like CallAuth, recording the sent transaction with meta in the
+// TxRecorder if it's set.
+func (c *Client2) CallAuthMeta(meta TxMeta, fn func(*ethclient.Client, *bind.TransactOpts) (*types.Transaction, error),
+	opts ...*CallOpts) (*types.Transaction, error) {
+	return c.CallAuthMetaCtx(context.Background(), meta, fn, opts...)
+}
+
+// transactOpts returns the bind.TransactOpts of an authorized call of the
+// account with opts, which may be nil.  The node is only queried for the
+// parameters not set in opts.
+func (c *Client2) transactOpts(ctx context.Context, account *accounts.Account, opts *CallOpts) (*bind.TransactOpts, error) {
+	if opts == nil {
+		opts = &CallOpts{}
 	}
-	nonce, err := c.client.PendingNonceAt(context.Background(), c.account.Address)
+	auth, err := bind.NewKeyStoreTransactor(c.ks, *account)
 	if err != nil {
 		return nil, err
 	}
+	auth.Nonce = opts.Nonce
+	if auth.Nonce == nil {
+		nonce, err := c.client.PendingNonceAt(ctx, account.Address)
+		if err != nil {
+			return nil, err
+		}
+		auth.Nonce = new(big.Int).SetUint64(nonce)
+	}
+	auth.GasPrice = opts.GasPrice
+	if auth.GasPrice == nil {
+		if auth.GasPrice, err = c.client.SuggestGasPrice(ctx); err != nil {
+			return nil, err
+		}
+	}
+	auth.GasLimit = opts.GasLimit
+	if auth.GasLimit == 0 {
+		auth.GasLimit = DefaultGasLimit
+	}
+	auth.Value = opts.Value
+	if auth.Value == nil {
+		auth.Value = big.NewInt(0)
+	}
+	auth.Context = ctx
+	return auth, nil
+}
 
-	gasPrice, err := c.client.SuggestGasPrice(context.Background())
+// CallAuthMetaCtx is CallAuthMeta with a context that cancels the requests to
+// the node.  The context is also set in the bind.TransactOpts passed to fn.
+// Only the first of opts is used.
+func (c *Client2) CallAuthMetaCtx(ctx context.Context, meta TxMeta, fn func(*ethclient.Client, *bind.TransactOpts) (*types.Transaction, error),
+	opts ...*CallOpts) (*types.Transaction, error) {
+	account := c.Account()
+	if account == nil {
+		return nil, ErrAccountNil
+	}
+	var callOpts *CallOpts
+	if len(opts) > 0 {
+		callOpts = opts[0]
+	}
+	auth, err := c.transactOpts(ctx, account, callOpts)
 	if err != nil {
 		return nil, err
 	}
 
-	auth, err := bind.NewKeyStoreTransactor(c.ks, *c.account)
+	tx, err := fn(c.client, auth)
 	if err != nil {
-		return nil, err
+		return nil, c.decodeErr(err)
 	}
-	auth.Nonce = big.NewInt(int64(nonce))
-	auth.Value = big.NewInt(0)     // in wei
-	auth.GasLimit = uint64(300000) // in units
-	auth.GasPrice = gasPrice
-
-	return fn(c.client, auth)
+	c.rw.RLock()
+	txRecorder := c.txRecorder
+	c.rw.RUnlock()
+	if txRecorder != nil {
+		// The transaction is already sent, so a failure to record it
+		// must not be reported as a failure to send it.
+		if err := txRecorder.RecordTx(tx, meta); err != nil {
+			log.WithError(err).WithField("tx", tx.Hash().Hex()).Error("Unable to record transaction")
+		}
+	}
+	return tx, nil
 }
 
-// Call performs a read only Smart Contract method call.
+// Call performs a read only Smart Contract method call.  If the call
+// reverts, a ContractRevertError is returned.
 func (c *Client2) Call(fn func(*ethclient.Client) error) error {
-	return fn(c.client)
+	if err := fn(c.client); err != nil {
+		return c.decodeErr(err)
+	}
+	return nil
+}
+
+// EstimateGas simulates a Smart Contract method call with calldata to the
+// contract at address to, sent from the account, and returns the gas it
+// requires.  The call is first run with eth_call, so that if it reverts a
+// ContractRevertError with the decoded revert reason is returned.  Nothing is
+// sent to the network.
+func (c *Client2) EstimateGas(to common.Address, calldata []byte) (uint64, error) {
+	return c.EstimateGasCtx(context.Background(), to, calldata)
+}
+
+// EstimateGasCtx is EstimateGas with a context that cancels the requests to
+// the node.
+func (c *Client2) EstimateGasCtx(ctx context.Context, to common.Address, calldata []byte) (uint64, error) {
+	account := c.Account()
+	if account == nil {
+		return 0, ErrAccountNil
+	}
+	msg := ethereum.CallMsg{From: account.Address, To: &to, Data: calldata}
+	res, err := c.client.CallContract(ctx, msg, nil)
+	if err != nil {
+		return 0, c.decodeErr(err)
+	}
+	// Old nodes return the revert data as the result of the call.
+	if revertErr, ok := c.revertErr(res); ok {
+		return 0, revertErr
+	}
+	gas, err := c.client.EstimateGas(ctx, msg)
+	if err != nil {
+		return 0, c.decodeErr(err)
+	}
+	return gas, nil
+}
+
+// replayRevert replays the failed transaction tx as a call at the block of
+// its receipt to find out the revert reason.  The call runs on the state
+// after the block, so the reason is a best effort.
+func (c *Client2) replayRevert(tx *types.Transaction, receipt *types.Receipt) (*ContractRevertError, bool) {
+	from, err := types.Sender(types.NewEIP155Signer(tx.ChainId()), tx)
+	if err != nil {
+		account := c.Account()
+		if account == nil {
+			return nil, false
+		}
+		from = account.Address
+	}
+	msg := ethereum.CallMsg{From: from, To: tx.To(), Gas: tx.Gas(), GasPrice: tx.GasPrice(),
+		Value: tx.Value(), Data: tx.Data()}
+	res, err := c.client.CallContract(context.Background(), msg, receipt.BlockNumber)
+	if err != nil {
+		revertErr, ok := c.decodeErr(err).(*ContractRevertError)
+		return revertErr, ok
+	}
+	return c.revertErr(res)
 }
 
 // WaitReceipt will block until a transaction is confirmed.  Internally it
-// polls the state every 200 milliseconds.
+// polls the state every 200 milliseconds.  If the transaction reverted, a
+// ContractRevertError with the reason is returned when it can be found out.
 func (c *Client2) WaitReceipt(tx *types.Transaction) (*types.Receipt, error) {
 	var err error
 	var receipt *types.Receipt
@@ -88,6 +321,9 @@ func (c *Client2) WaitReceipt(tx *types.Transaction) (*types.Receipt, error) {
 
 	if receipt != nil && receipt.Status == types.ReceiptStatusFailed {
 		log.WithField("tx", txid.Hex()).Error("WEB3 Failed transaction receipt")
+		if revertErr, ok := c.replayRevert(tx, receipt); ok {
+			return receipt, revertErr
+		}
 		return receipt, errReceiptStatusFailed
 	}
 
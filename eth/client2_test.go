@@ -0,0 +1,64 @@
+package eth
+
+import (
+	"context"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"testing"
+
+	ethkeystore "github.com/ethereum/go-ethereum/accounts/keystore"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClient2SetAccount(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ks := ethkeystore.NewKeyStore(dir, ethkeystore.LightScryptN, ethkeystore.LightScryptP)
+	account1, err := ks.NewAccount("pass")
+	require.Nil(t, err)
+	account2, err := ks.NewAccount("pass")
+	require.Nil(t, err)
+
+	c := NewClient2(nil, &account1, ks)
+	assert.Equal(t, account1.Address, c.Account().Address)
+	require.Nil(t, c.SetAccount(account2.Address))
+	assert.Equal(t, account2.Address, c.Account().Address)
+	assert.Equal(t, ErrAccountNotInKeyStore, c.SetAccount(common.Address{1}))
+	assert.Equal(t, account2.Address, c.Account().Address)
+
+	assert.Equal(t, ErrAccountNotInKeyStore, NewClient2(nil, nil, nil).SetAccount(account1.Address))
+}
+
+func TestClient2TransactOpts(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	ks := ethkeystore.NewKeyStore(dir, ethkeystore.LightScryptN, ethkeystore.LightScryptP)
+	account, err := ks.NewAccount("pass")
+	require.Nil(t, err)
+	require.Nil(t, ks.Unlock(account, "pass"))
+
+	// With every parameter set the node is not queried, so the client can
+	// be nil.
+	c := NewClient2(nil, &account, ks)
+	ctx := context.Background()
+	opts := &CallOpts{Nonce: big.NewInt(7), GasLimit: 100000, GasPrice: big.NewInt(2000000000), Value: big.NewInt(1)}
+	auth, err := c.transactOpts(ctx, &account, opts)
+	require.Nil(t, err)
+	assert.Equal(t, account.Address, auth.From)
+	assert.Equal(t, big.NewInt(7), auth.Nonce)
+	assert.Equal(t, uint64(100000), auth.GasLimit)
+	assert.Equal(t, big.NewInt(2000000000), auth.GasPrice)
+	assert.Equal(t, big.NewInt(1), auth.Value)
+	assert.Equal(t, ctx, auth.Context)
+
+	opts = &CallOpts{Nonce: big.NewInt(7), GasPrice: big.NewInt(2000000000)}
+	auth, err = c.transactOpts(ctx, &account, opts)
+	require.Nil(t, err)
+	assert.Equal(t, DefaultGasLimit, auth.GasLimit)
+	assert.Equal(t, big.NewInt(0), auth.Value)
+}
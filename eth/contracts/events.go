@@ -0,0 +1,134 @@
+package contracts
+
+// This file is not generated by abigen: it's a thin wrapper over the
+// generated event bindings of state.go, and must be kept when regenerating
+// them.
+
+import (
+	"context"
+	"errors"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/common"
+)
+
+// DefaultBlockSpan is the default number of blocks queried at once by
+// StateEvents, below the limits of the log queries of the public nodes.
+const DefaultBlockSpan = 5000
+
+var (
+	// ErrInvalidBlockRange is used when the first block of a query is after
+	// the last one.
+	ErrInvalidBlockRange = errors.New("the first block of the range is after the last one")
+	// ErrInvalidLimit is used when the limit of events of a query is not
+	// positive.
+	ErrInvalidLimit = errors.New("the limit of events must be positive")
+	// ErrInvalidBlockSpan is used when StateEvents is created with a block
+	// span of 0.
+	ErrInvalidBlockSpan = errors.New("the block span must be positive")
+)
+
+// StateUpdatedQuery is a query of the StateUpdated events in a range of
+// blocks.
+type StateUpdatedQuery struct {
+	// FromBlock and ToBlock are the first and last blocks of the range.
+	FromBlock uint64
+	ToBlock   uint64
+	// FromLogIndex skips the events of FromBlock with a lower log index,
+	// to resume a page that ended in the middle of a block.
+	FromLogIndex uint
+	// Id filters the events of an identity if not nil.  As the id is not
+	// indexed by the contract, the events are filtered in the client.
+	Id *[31]byte
+	// Limit is the maximum number of events of the page.
+	Limit int
+}
+
+// StateUpdatedPage is a page of the StateUpdated events of a query, in the
+// order they were emitted.
+type StateUpdatedPage struct {
+	Events []*StateStateUpdated
+	// Next is the query of the next page, or nil if there are no more
+	// events in the range.
+	Next *StateUpdatedQuery
+}
+
+// StateEvents queries the events of the State contract page by page,
+// splitting the block ranges in queries of at most blockSpan blocks.
+type StateEvents struct {
+	filterer  *StateFilterer
+	blockSpan uint64
+}
+
+// NewStateEvents creates a StateEvents of the State contract at address.
+func NewStateEvents(address common.Address, filterer bind.ContractFilterer, blockSpan uint64) (*StateEvents, error) {
+	if blockSpan == 0 {
+		return nil, ErrInvalidBlockSpan
+	}
+	stateFilterer, err := NewStateFilterer(address, filterer)
+	if err != nil {
+		return nil, err
+	}
+	return &StateEvents{filterer: stateFilterer, blockSpan: blockSpan}, nil
+}
+
+// StateUpdated returns a page of the StateUpdated events of the query.  The
+// events of removed logs (reorganized blocks) are skipped.
+func (e *StateEvents) StateUpdated(ctx context.Context, q StateUpdatedQuery) (*StateUpdatedPage, error) {
+	if q.FromBlock > q.ToBlock {
+		return nil, ErrInvalidBlockRange
+	}
+	if q.Limit <= 0 {
+		return nil, ErrInvalidLimit
+	}
+	page := StateUpdatedPage{Events: []*StateStateUpdated{}}
+	for from := q.FromBlock; ; {
+		to := q.ToBlock
+		if q.ToBlock-from >= e.blockSpan {
+			to = from + e.blockSpan - 1
+		}
+		next, err := e.stateUpdated(ctx, &q, from, to, &page)
+		if err != nil {
+			return nil, err
+		}
+		if next != nil {
+			page.Next = next
+			return &page, nil
+		}
+		if to == q.ToBlock {
+			return &page, nil
+		}
+		from = to + 1
+	}
+}
+
+// stateUpdated appends to page the events of q between the blocks from and
+// to, and returns the query of the next page when the page is full.
+func (e *StateEvents) stateUpdated(ctx context.Context, q *StateUpdatedQuery, from, to uint64,
+	page *StateUpdatedPage) (*StateUpdatedQuery, error) {
+	it, err := e.filterer.FilterStateUpdated(&bind.FilterOpts{Start: from, End: &to, Context: ctx})
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	for it.Next() {
+		ev := it.Event
+		if ev.Raw.Removed {
+			continue
+		}
+		if ev.Raw.BlockNumber == q.FromBlock && ev.Raw.Index < q.FromLogIndex {
+			continue
+		}
+		if q.Id != nil && ev.Id != *q.Id {
+			continue
+		}
+		if len(page.Events) == q.Limit {
+			next := *q
+			next.FromBlock = ev.Raw.BlockNumber
+			next.FromLogIndex = ev.Raw.Index
+			return &next, nil
+		}
+		page.Events = append(page.Events, ev)
+	}
+	return nil, it.Error()
+}
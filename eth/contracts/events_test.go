@@ -0,0 +1,79 @@
+package contracts
+
+import (
+	"context"
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts/abi/bind"
+	"github.com/ethereum/go-ethereum/accounts/abi/bind/backends"
+	"github.com/ethereum/go-ethereum/core"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateEvents(t *testing.T) {
+	key, err := crypto.GenerateKey()
+	require.Nil(t, err)
+	auth := bind.NewKeyedTransactor(key)
+	backend := backends.NewSimulatedBackend(core.GenesisAlloc{
+		auth.From: {Balance: new(big.Int).Lsh(big.NewInt(1), 64)},
+	}, 8000000)
+	address, _, state, err := DeployState(auth, backend)
+	require.Nil(t, err)
+	backend.Commit()
+
+	// Block 2: init the states of ids 1, 2 and 3.  Block 3: update id 1.
+	for i := byte(1); i <= 3; i++ {
+		_, err := state.InitState(auth, [32]byte{i}, [32]byte{}, [31]byte{i}, nil, nil, [32]byte{}, [32]byte{})
+		require.Nil(t, err)
+	}
+	backend.Commit()
+	_, err = state.SetState(auth, [32]byte{4}, [31]byte{1}, nil, nil, [32]byte{}, [32]byte{})
+	require.Nil(t, err)
+	backend.Commit()
+
+	_, err = NewStateEvents(address, backend, 0)
+	assert.Equal(t, ErrInvalidBlockSpan, err)
+	events, err := NewStateEvents(address, backend, 1)
+	require.Nil(t, err)
+	ctx := context.Background()
+	_, err = events.StateUpdated(ctx, StateUpdatedQuery{FromBlock: 2, ToBlock: 1, Limit: 1})
+	assert.Equal(t, ErrInvalidBlockRange, err)
+	_, err = events.StateUpdated(ctx, StateUpdatedQuery{FromBlock: 0, ToBlock: 3})
+	assert.Equal(t, ErrInvalidLimit, err)
+
+	// Pages of 2 events end and resume in the middle of block 2.
+	page, err := events.StateUpdated(ctx, StateUpdatedQuery{FromBlock: 0, ToBlock: 3, Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(page.Events))
+	assert.Equal(t, [31]byte{1}, page.Events[0].Id)
+	assert.Equal(t, [32]byte{1}, page.Events[0].State)
+	assert.Equal(t, uint64(2), page.Events[0].BlockN)
+	assert.Equal(t, [31]byte{2}, page.Events[1].Id)
+	require.NotNil(t, page.Next)
+	assert.Equal(t, uint64(2), page.Next.FromBlock)
+
+	page, err = events.StateUpdated(ctx, *page.Next)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(page.Events))
+	assert.Equal(t, [31]byte{3}, page.Events[0].Id)
+	assert.Equal(t, [31]byte{1}, page.Events[1].Id)
+	assert.Equal(t, [32]byte{4}, page.Events[1].State)
+	assert.Equal(t, uint64(3), page.Events[1].BlockN)
+	assert.Nil(t, page.Next)
+
+	// Events of one identity.
+	page, err = events.StateUpdated(ctx, StateUpdatedQuery{FromBlock: 0, ToBlock: 3, Id: &[31]byte{1}, Limit: 10})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(page.Events))
+	assert.Equal(t, [32]byte{1}, page.Events[0].State)
+	assert.Equal(t, [32]byte{4}, page.Events[1].State)
+	assert.Nil(t, page.Next)
+
+	page, err = events.StateUpdated(ctx, StateUpdatedQuery{FromBlock: 3, ToBlock: 3, Id: &[31]byte{2}, Limit: 10})
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(page.Events))
+	assert.Nil(t, page.Next)
+}
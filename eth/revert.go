@@ -0,0 +1,136 @@
+package eth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/accounts/abi"
+	"github.com/ethereum/go-ethereum/crypto"
+)
+
+// ErrCallReverted is used when a contract call reverts.  Every
+// ContractRevertError matches it with errors.Is.
+var ErrCallReverted = errors.New("contract call reverted")
+
+// ContractRevertError is the error returned when a contract call or
+// transaction reverts.  Reason is the decoded solidity revert reason, or
+// the custom error with its arguments (as in `Name(arg0, arg1)`) when the
+// contract reverts with a custom error registered with RegisterErrors.
+// Reason is empty if the revert data couldn't be decoded.
+type ContractRevertError struct {
+	Reason string
+}
+
+func (e *ContractRevertError) Error() string {
+	if e.Reason == "" {
+		return ErrCallReverted.Error()
+	}
+	return fmt.Sprintf("%v: %v", ErrCallReverted, e.Reason)
+}
+
+// Is allows matching any ContractRevertError with ErrCallReverted.
+func (e *ContractRevertError) Is(target error) bool {
+	return target == ErrCallReverted
+}
+
+// revertSelector is the selector of the Error(string) function, used by
+// solidity to encode the revert reasons.
+var revertSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+// revertErrPrefixes are the prefixes of the errors returned by the nodes
+// when a call reverts, followed by the reason.
+var revertErrPrefixes = []string{
+	"execution reverted",
+	"VM Exception while processing transaction: revert",
+}
+
+// customError is a solidity custom error declared in a contract ABI.
+type customError struct {
+	name   string
+	inputs abi.Arguments
+}
+
+// abiError is the JSON ABI entry of a solidity custom error.
+type abiError struct {
+	Type   string
+	Name   string
+	Inputs []abi.ArgumentMarshaling
+}
+
+// parseCustomErrors parses the custom errors declared in the JSON ABI
+// abiJSON, indexed by selector.
+func parseCustomErrors(abiJSON string) (map[[4]byte]customError, error) {
+	var entries []abiError
+	if err := json.Unmarshal([]byte(abiJSON), &entries); err != nil {
+		return nil, err
+	}
+	errs := make(map[[4]byte]customError)
+	for _, entry := range entries {
+		if entry.Type != "error" {
+			continue
+		}
+		inputs := make(abi.Arguments, len(entry.Inputs))
+		types := make([]string, len(entry.Inputs))
+		for i, input := range entry.Inputs {
+			typ, err := abi.NewType(input.Type, input.Components)
+			if err != nil {
+				return nil, err
+			}
+			inputs[i] = abi.Argument{Name: input.Name, Type: typ}
+			types[i] = typ.String()
+		}
+		var selector [4]byte
+		copy(selector[:], crypto.Keccak256([]byte(entry.Name+"("+strings.Join(types, ",")+")")))
+		errs[selector] = customError{name: entry.Name, inputs: inputs}
+	}
+	return errs, nil
+}
+
+// unpackRevertReason decodes the revert reason from the data returned by a
+// reverted call, either a solidity revert reason or one of the customErrs.
+func unpackRevertReason(data []byte, customErrs map[[4]byte]customError) (string, bool) {
+	if len(data) < len(revertSelector) {
+		return "", false
+	}
+	if bytes.Equal(data[:len(revertSelector)], revertSelector) {
+		typ, err := abi.NewType("string", nil)
+		if err != nil {
+			return "", false
+		}
+		var reason string
+		if err := (abi.Arguments{{Type: typ}}).Unpack(&reason, data[len(revertSelector):]); err != nil {
+			return "", false
+		}
+		return reason, true
+	}
+	var selector [4]byte
+	copy(selector[:], data)
+	customErr, ok := customErrs[selector]
+	if !ok {
+		return "", false
+	}
+	values, err := customErr.inputs.UnpackValues(data[len(selector):])
+	if err != nil {
+		return "", false
+	}
+	args := make([]string, len(values))
+	for i, value := range values {
+		args[i] = fmt.Sprintf("%v", value)
+	}
+	return customErr.name + "(" + strings.Join(args, ", ") + ")", true
+}
+
+// revertReasonFromErr extracts the revert reason from the error returned by
+// a node when a call reverts.
+func revertReasonFromErr(err error) (string, bool) {
+	msg := err.Error()
+	for _, prefix := range revertErrPrefixes {
+		if strings.HasPrefix(msg, prefix) {
+			return strings.TrimSpace(strings.TrimPrefix(strings.TrimPrefix(msg, prefix), ":")), true
+		}
+	}
+	return "", false
+}
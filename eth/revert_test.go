@@ -0,0 +1,83 @@
+package eth
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnpackRevertReason(t *testing.T) {
+	// Revert data of `require(false, "Not enough Ether provided.")`
+	data := common.FromHex("0x08c379a0" +
+		"0000000000000000000000000000000000000000000000000000000000000020" +
+		"000000000000000000000000000000000000000000000000000000000000001a" +
+		"4e6f7420656e6f7567682045746865722070726f76696465642e000000000000")
+	reason, ok := unpackRevertReason(data, nil)
+	assert.True(t, ok)
+	assert.Equal(t, "Not enough Ether provided.", reason)
+
+	_, ok = unpackRevertReason([]byte{}, nil)
+	assert.False(t, ok)
+	_, ok = unpackRevertReason(common.FromHex("0x0000000000000000000000000000000000000000000000000000000000000001"), nil)
+	assert.False(t, ok)
+}
+
+func TestUnpackRevertReasonCustomError(t *testing.T) {
+	customErrs, err := parseCustomErrors(`[
+{"type":"error","name":"InsufficientBalance","inputs":[{"name":"available","type":"uint256"},{"name":"required","type":"uint256"}]},
+{"type":"function","name":"transfer","inputs":[{"name":"to","type":"address"},{"name":"amount","type":"uint256"}],"outputs":[]}
+]`)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(customErrs))
+
+	// Revert data of `revert InsufficientBalance(1, 2)`
+	data := common.FromHex("0xcf479181" +
+		"0000000000000000000000000000000000000000000000000000000000000001" +
+		"0000000000000000000000000000000000000000000000000000000000000002")
+	reason, ok := unpackRevertReason(data, customErrs)
+	assert.True(t, ok)
+	assert.Equal(t, "InsufficientBalance(1, 2)", reason)
+
+	// Unregistered custom errors are not decoded
+	_, ok = unpackRevertReason(data, nil)
+	assert.False(t, ok)
+}
+
+func TestRevertReasonFromErr(t *testing.T) {
+	reason, ok := revertReasonFromErr(errors.New("execution reverted: State already exists"))
+	assert.True(t, ok)
+	assert.Equal(t, "State already exists", reason)
+	reason, ok = revertReasonFromErr(errors.New("VM Exception while processing transaction: revert State already exists"))
+	assert.True(t, ok)
+	assert.Equal(t, "State already exists", reason)
+	reason, ok = revertReasonFromErr(errors.New("execution reverted"))
+	assert.True(t, ok)
+	assert.Equal(t, "", reason)
+	_, ok = revertReasonFromErr(errors.New("connection refused"))
+	assert.False(t, ok)
+
+}
+
+func TestContractRevertError(t *testing.T) {
+	client := NewClient2(nil, nil, nil)
+	err := client.decodeErr(errors.New("execution reverted: State already exists"))
+	assert.Equal(t, &ContractRevertError{Reason: "State already exists"}, err)
+	assert.Equal(t, "contract call reverted: State already exists", err.Error())
+
+	wrapped := fmt.Errorf("Failed setting identity state: %w", err)
+	assert.True(t, errors.Is(wrapped, ErrCallReverted))
+	var revertErr *ContractRevertError
+	require.True(t, errors.As(wrapped, &revertErr))
+	assert.Equal(t, "State already exists", revertErr.Reason)
+
+	err = client.decodeErr(errors.New("execution reverted"))
+	assert.Equal(t, "contract call reverted", err.Error())
+
+	errConn := errors.New("connection refused")
+	assert.Equal(t, errConn, client.decodeErr(errConn))
+	assert.False(t, errors.Is(errConn, ErrCallReverted))
+}
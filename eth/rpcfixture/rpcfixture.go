@@ -0,0 +1,299 @@
+// Package rpcfixture records the JSON-RPC calls made by an eth.Client2 to a
+// node into fixtures, and replays them later, so that the components that
+// interact with the Smart Contracts can be tested against the responses of a
+// real node (reorganized blocks, pending receipts, reverted calls) without a
+// live node nor the simulated backend.
+//
+// A fixture is recorded with a Recorder as the transport of the client
+// connected to a node, and saved as JSON:
+//
+//	rec := rpcfixture.NewRecorder(nil)
+//	client, _ := rpcfixture.Dial("http://localhost:8545", rec)
+//	...
+//	rec.Fixture().Save("testdata/setstate.json")
+//
+// and replayed in the tests with a Replayer:
+//
+//	fixture, _ := rpcfixture.Load("testdata/setstate.json")
+//	client, _ := rpcfixture.Dial("http://fixture", rpcfixture.NewReplayer(fixture))
+package rpcfixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"sync"
+
+	"github.com/ethereum/go-ethereum/ethclient"
+	"github.com/ethereum/go-ethereum/rpc"
+)
+
+// Error is the error of a failed JSON-RPC call.
+type Error struct {
+	Code    int             `json:"code"`
+	Message string          `json:"message"`
+	Data    json.RawMessage `json:"data,omitempty"`
+}
+
+// Call is a JSON-RPC call and its response.
+type Call struct {
+	Method string `json:"method"`
+	// Params are the parameters of the call.  When replaying, a Call
+	// without Params matches the calls of Method with any parameters,
+	// which is useful in handwritten fixtures for the calls whose
+	// parameters aren't deterministic, like signed transactions.
+	Params json.RawMessage `json:"params,omitempty"`
+	// Result is the result of a successful call, and Error the error of a
+	// failed one.
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *Error          `json:"error,omitempty"`
+}
+
+// Fixture is a sequence of JSON-RPC calls in the order they were made.
+type Fixture struct {
+	Calls []Call `json:"calls"`
+}
+
+// Load loads the Fixture saved at path.
+func Load(path string) (*Fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixture Fixture
+	if err := json.Unmarshal(b, &fixture); err != nil {
+		return nil, err
+	}
+	return &fixture, nil
+}
+
+// Save saves the Fixture at path as indented JSON.
+func (f *Fixture) Save(path string) error {
+	b, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(b, '\n'), 0644)
+}
+
+// Dial creates an ethclient.Client connected to url through the transport,
+// typically a Recorder or a Replayer.
+func Dial(url string, transport http.RoundTripper) (*ethclient.Client, error) {
+	c, err := rpc.DialHTTPWithClient(url, &http.Client{Transport: transport})
+	if err != nil {
+		return nil, err
+	}
+	return ethclient.NewClient(c), nil
+}
+
+// message is a JSON-RPC request or response.
+type message struct {
+	Version string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *Error          `json:"error,omitempty"`
+}
+
+// parseMessages parses a single JSON-RPC message or a batch of them.
+func parseMessages(b []byte) ([]message, bool, error) {
+	if b = bytes.TrimSpace(b); len(b) > 0 && b[0] == '[' {
+		var msgs []message
+		err := json.Unmarshal(b, &msgs)
+		return msgs, true, err
+	}
+	var msg message
+	err := json.Unmarshal(b, &msg)
+	return []message{msg}, false, err
+}
+
+// Recorder is an http.RoundTripper that records the JSON-RPC calls sent
+// through it.
+type Recorder struct {
+	transport http.RoundTripper
+	mutex     sync.Mutex
+	calls     []Call
+}
+
+// NewRecorder creates a Recorder that sends the calls through transport, or
+// http.DefaultTransport if it's nil.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	return &Recorder{transport: transport}
+}
+
+// RoundTrip sends the request and records its calls with their responses.
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	res, err := r.transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	resBody, err := readBody(&res.Body)
+	if err != nil {
+		return nil, err
+	}
+	reqs, _, err := parseMessages(reqBody)
+	if err != nil {
+		return res, nil
+	}
+	ress, _, err := parseMessages(resBody)
+	if err != nil {
+		return res, nil
+	}
+	// The responses of a batch may come in any order.
+	byID := make(map[string]message, len(ress))
+	for _, msg := range ress {
+		byID[string(msg.ID)] = msg
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, msg := range reqs {
+		if msg.ID == nil {
+			continue
+		}
+		resMsg := byID[string(msg.ID)]
+		r.calls = append(r.calls, Call{Method: msg.Method, Params: msg.Params,
+			Result: resMsg.Result, Error: resMsg.Error})
+	}
+	return res, nil
+}
+
+// Fixture returns the Fixture of the calls recorded so far.
+func (r *Recorder) Fixture() *Fixture {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return &Fixture{Calls: append([]Call{}, r.calls...)}
+}
+
+// readBody reads the body, replacing it by a copy so that it can be read
+// again.
+func readBody(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil {
+		return nil, nil
+	}
+	b, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return nil, err
+	}
+	*body = ioutil.NopCloser(bytes.NewReader(b))
+	return b, nil
+}
+
+// Replayer is an http.RoundTripper that responds the JSON-RPC calls with the
+// responses of a Fixture.  Each call is responded with the first call of the
+// Fixture not replayed yet with the same method and parameters, so that
+// repeated calls get the responses in the order they were recorded, like a
+// receipt that is pending before being mined.  The calls not in the Fixture
+// fail with an error.
+type Replayer struct {
+	mutex    sync.Mutex
+	calls    []Call
+	replayed []bool
+}
+
+// NewReplayer creates a Replayer of fixture.
+func NewReplayer(fixture *Fixture) *Replayer {
+	return &Replayer{calls: fixture.Calls, replayed: make([]bool, len(fixture.Calls))}
+}
+
+// RoundTrip responds the calls of the request from the Fixture.
+func (r *Replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	reqs, batch, err := parseMessages(reqBody)
+	if err != nil {
+		return nil, err
+	}
+	ress := make([]message, 0, len(reqs))
+	for _, msg := range reqs {
+		res := message{Version: "2.0", ID: msg.ID}
+		if call, ok := r.replay(msg.Method, msg.Params); ok {
+			res.Result, res.Error = call.Result, call.Error
+			if res.Result == nil && res.Error == nil {
+				res.Result = json.RawMessage("null")
+			}
+		} else {
+			res.Error = &Error{Code: -32601, Message: fmt.Sprintf("rpcfixture: no recorded response for %v %s",
+				msg.Method, msg.Params)}
+		}
+		ress = append(ress, res)
+	}
+	var resBody []byte
+	if batch {
+		resBody, err = json.Marshal(ress)
+	} else {
+		resBody, err = json.Marshal(ress[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          ioutil.NopCloser(bytes.NewReader(resBody)),
+		ContentLength: int64(len(resBody)),
+		Request:       req,
+	}, nil
+}
+
+// replay returns the first call not replayed yet of method with params.
+func (r *Replayer) replay(method string, params json.RawMessage) (*Call, bool) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for i := range r.calls {
+		call := &r.calls[i]
+		if r.replayed[i] || call.Method != method {
+			continue
+		}
+		if call.Params != nil && !equalJSON(call.Params, params) {
+			continue
+		}
+		r.replayed[i] = true
+		return call, true
+	}
+	return nil, false
+}
+
+// Pending returns the calls of the Fixture not replayed yet, to check that
+// the test made all the recorded calls.
+func (r *Replayer) Pending() []Call {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	calls := []Call{}
+	for i, call := range r.calls {
+		if !r.replayed[i] {
+			calls = append(calls, call)
+		}
+	}
+	return calls
+}
+
+// equalJSON returns true if a and b encode the same values.
+func equalJSON(a, b json.RawMessage) bool {
+	var va, vb interface{}
+	if err := json.Unmarshal(a, &va); err != nil {
+		return false
+	}
+	if err := json.Unmarshal(b, &vb); err != nil {
+		return false
+	}
+	return reflect.DeepEqual(va, vb)
+}
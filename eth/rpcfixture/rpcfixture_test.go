@@ -0,0 +1,117 @@
+package rpcfixture
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// node is a fake node whose block number goes back after a reorg, and whose
+// receipts are pending the first time they are requested.
+func node(t *testing.T) *httptest.Server {
+	blockNs := []string{"0xa", "0xb", "0x9"}
+	pending := true
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req message
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+		res := message{Version: "2.0", ID: req.ID}
+		switch req.Method {
+		case "eth_blockNumber":
+			res.Result = json.RawMessage(`"` + blockNs[0] + `"`)
+			blockNs = blockNs[1:]
+		case "eth_getTransactionReceipt":
+			res.Result = json.RawMessage("null")
+			if !pending {
+				res.Result = json.RawMessage(`{"status":"0x1","cumulativeGasUsed":"0x5208",` +
+					`"logsBloom":"0x` + zeros(512) + `","logs":[],` +
+					`"transactionHash":"0x0100000000000000000000000000000000000000000000000000000000000000",` +
+					`"gasUsed":"0x5208","blockNumber":"0xb"}`)
+			}
+			pending = false
+		default:
+			res.Error = &Error{Code: -32000, Message: "unsupported"}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		require.Nil(t, json.NewEncoder(w).Encode(res))
+	}))
+}
+
+func zeros(n int) string {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = '0'
+	}
+	return string(b)
+}
+
+func TestRecordReplay(t *testing.T) {
+	srv := node(t)
+	defer srv.Close()
+	ctx := context.Background()
+	txHash := common.Hash{1}
+
+	run := func(url string, transport http.RoundTripper) {
+		rpcClient, err := rpc.DialHTTPWithClient(url, &http.Client{Transport: transport})
+		require.Nil(t, err)
+		for _, blockN := range []uint64{10, 11, 9} {
+			var n hexutil.Uint64
+			require.Nil(t, rpcClient.CallContext(ctx, &n, "eth_blockNumber"))
+			assert.Equal(t, blockN, uint64(n))
+		}
+		client, err := Dial(url, transport)
+		require.Nil(t, err)
+		_, err = client.TransactionReceipt(ctx, txHash)
+		assert.NotNil(t, err)
+		receipt, err := client.TransactionReceipt(ctx, txHash)
+		require.Nil(t, err)
+		assert.Equal(t, uint64(21000), receipt.GasUsed)
+		_, err = client.SuggestGasPrice(ctx)
+		assert.NotNil(t, err)
+	}
+
+	rec := NewRecorder(nil)
+	run(srv.URL, rec)
+	fixture := rec.Fixture()
+	require.Equal(t, 6, len(fixture.Calls))
+	assert.Equal(t, "eth_blockNumber", fixture.Calls[0].Method)
+	assert.Equal(t, "unsupported", fixture.Calls[5].Error.Message)
+
+	dir, err := ioutil.TempDir("", "rpcfixture")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "fixture.json")
+	require.Nil(t, fixture.Save(path))
+	fixture, err = Load(path)
+	require.Nil(t, err)
+
+	// The replay doesn't reach the node.
+	srv.Close()
+	replayer := NewReplayer(fixture)
+	run("http://fixture", replayer)
+	assert.Equal(t, 0, len(replayer.Pending()))
+
+	// The calls not recorded fail.
+	client, err := Dial("http://fixture", replayer)
+	require.Nil(t, err)
+	_, err = client.TransactionReceipt(ctx, txHash)
+	assert.NotNil(t, err)
+
+	// A Call without Params matches any parameters.
+	replayer = NewReplayer(&Fixture{Calls: []Call{{Method: "eth_getTransactionReceipt"}}})
+	client, err = Dial("http://fixture", replayer)
+	require.Nil(t, err)
+	_, err = client.TransactionReceipt(ctx, common.Hash{2})
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(replayer.Pending()))
+}
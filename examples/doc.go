@@ -0,0 +1,18 @@
+// Package examples are runnable applications wiring the components of the
+// library together, against the simulated IdenStates Smart Contract of
+// components/idenpubonchain/sim:
+//
+//   - issuer-server issues claims and serves their credentials.
+//   - holder-wallet-cli requests the credentials to the issuer, keeps them in
+//     a wallet file and presents them.
+//   - verifier-service verifies the presented credentials.
+//
+// A session with the three of them:
+//
+//	go run ./examples/issuer-server &
+//	go run ./examples/verifier-service &
+//	go run ./examples/holder-wallet-cli request alice
+//	go run ./examples/holder-wallet-cli present <hIndex>
+//
+// The test of this package builds and runs them as an end to end test.
+package examples
@@ -0,0 +1,102 @@
+package examples
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// build builds the example app into dir and returns the path of the binary.
+func build(t *testing.T, dir, app string) string {
+	bin := filepath.Join(dir, app)
+	out, err := exec.Command("go", "build", "-o", bin, "./"+app).CombinedOutput()
+	require.Nil(t, err, string(out))
+	return bin
+}
+
+// freeAddr returns a local address that is free to listen at.
+func freeAddr(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer l.Close()
+	return l.Addr().String()
+}
+
+// start starts the server at addr, waits until it accepts connections and
+// returns the function that stops it.
+func start(t *testing.T, addr, bin string, args ...string) func() {
+	cmd := exec.Command(bin, args...)
+	require.Nil(t, cmd.Start())
+	stop := func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := http.Get("http://" + addr); err == nil {
+			return stop
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	stop()
+	t.Fatalf("%v didn't start", bin)
+	return nil
+}
+
+func TestEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds and runs the example apps")
+	}
+	dir, err := ioutil.TempDir("", "examples")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	issuerServer := build(t, dir, "issuer-server")
+	verifierService := build(t, dir, "verifier-service")
+	holderWalletCli := build(t, dir, "holder-wallet-cli")
+
+	issuerAddr, verifierAddr := freeAddr(t), freeAddr(t)
+	issuerURL, verifierURL := "http://"+issuerAddr, "http://"+verifierAddr
+	defer start(t, issuerAddr, issuerServer, "-addr", issuerAddr)()
+	defer start(t, verifierAddr, verifierService, "-addr", verifierAddr, "-chain", issuerURL)()
+
+	wallet := filepath.Join(dir, "wallet.json")
+	holder := func(args ...string) (string, error) {
+		out, err := exec.Command(holderWalletCli, append([]string{"-wallet", wallet}, args...)...).CombinedOutput()
+		return strings.TrimSpace(string(out)), err
+	}
+
+	hIndex1, err := holder("request", "-issuer", issuerURL, "alice")
+	require.Nil(t, err, hIndex1)
+	hIndex2, err := holder("request", "-issuer", issuerURL, "bob")
+	require.Nil(t, err, hIndex2)
+
+	out, err := holder("list")
+	require.Nil(t, err, out)
+	assert.Equal(t, 2, len(strings.Split(out, "\n")))
+
+	out, err = holder("status", "-issuer", issuerURL)
+	require.Nil(t, err, out)
+	assert.Contains(t, out, fmt.Sprintf("%v valid", hIndex1))
+	assert.Contains(t, out, fmt.Sprintf("%v valid", hIndex2))
+
+	// The first credential was issued in an older state, which is still
+	// a valid state of the simulated contract.
+	for _, hIndex := range []string{hIndex1, hIndex2} {
+		out, err = holder("present", "-verifier", verifierURL, hIndex)
+		require.Nil(t, err, out)
+		assert.Equal(t, "valid", out)
+	}
+
+	out, err = holder("present", "-verifier", verifierURL, "unknown")
+	assert.NotNil(t, err)
+	assert.Contains(t, out, "not found in the wallet")
+}
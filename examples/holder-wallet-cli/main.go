@@ -0,0 +1,191 @@
+// holder-wallet-cli keeps the credentials of existence of a holder in a
+// wallet file.  It requests them to issuer-server with the Go client of
+// clients/go, checks their revocation status and presents them to
+// verifier-service.
+//
+// Usage:
+//
+//	holder-wallet-cli [-wallet wallet.json] request -issuer <url> <index>
+//	holder-wallet-cli [-wallet wallet.json] list
+//	holder-wallet-cli [-wallet wallet.json] status -issuer <url>
+//	holder-wallet-cli [-wallet wallet.json] present -verifier <url> <hIndex>
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	client "github.com/iden3/go-iden3-core/clients/go"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// Wallet are the credentials of the holder by the hex HIndex of their claim.
+type Wallet map[string]*proof.CredentialExistence
+
+func loadWallet(path string) (Wallet, error) {
+	wallet := Wallet{}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return wallet, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &wallet); err != nil {
+		return nil, err
+	}
+	return wallet, nil
+}
+
+func (w Wallet) save(path string) error {
+	b, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+// hIndexes returns the sorted hIndexes of the wallet credentials.
+func (w Wallet) hIndexes() []string {
+	hIndexes := make([]string, 0, len(w))
+	for hIndex := range w {
+		hIndexes = append(hIndexes, hIndex)
+	}
+	sort.Strings(hIndexes)
+	return hIndexes
+}
+
+// postJSON posts in to url and decodes the JSON response into out.
+func postJSON(url string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return err
+	}
+	res, err := http.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 1024))
+		return fmt.Errorf("%v: %v", res.Status, strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// request asks issuer-server to issue a claim with index, and stores its
+// credential in the wallet.
+func request(ctx context.Context, wallet Wallet, issuerURL, index string, out io.Writer) error {
+	var claimRes struct {
+		HIndex *merkletree.Hash `json:"hIndex"`
+	}
+	if err := postJSON(strings.TrimSuffix(issuerURL, "/")+"/example/claims",
+		map[string]string{"index": index}, &claimRes); err != nil {
+		return err
+	}
+	if claimRes.HIndex == nil {
+		return errors.New("the issuer didn't return the hIndex")
+	}
+	c := client.New(issuerURL, client.ConfigDefault, nil)
+	credExist, err := c.RefreshCredentialExistence(ctx, claimRes.HIndex)
+	if err != nil {
+		return err
+	}
+	wallet[claimRes.HIndex.Hex()] = credExist
+	fmt.Fprintln(out, claimRes.HIndex.Hex())
+	return nil
+}
+
+// status prints the revocation status of the wallet credentials.
+func status(ctx context.Context, wallet Wallet, issuerURL string, out io.Writer) error {
+	c := client.New(issuerURL, client.ConfigDefault, nil)
+	for _, hIndex := range wallet.hIndexes() {
+		status, err := c.RevocationStatus(ctx, wallet[hIndex].Claim.HIndex())
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(out, hIndex, status)
+	}
+	return nil
+}
+
+// present sends the credential of hIndex to verifier-service and prints the
+// result.
+func present(wallet Wallet, verifierURL, hIndex string, out io.Writer) error {
+	credExist, ok := wallet[hIndex]
+	if !ok {
+		return fmt.Errorf("credential %v not found in the wallet", hIndex)
+	}
+	var res struct {
+		Valid bool   `json:"valid"`
+		Error string `json:"error"`
+	}
+	if err := postJSON(strings.TrimSuffix(verifierURL, "/")+"/verify", credExist, &res); err != nil {
+		return err
+	}
+	if !res.Valid {
+		return fmt.Errorf("the credential is not valid: %v", res.Error)
+	}
+	fmt.Fprintln(out, "valid")
+	return nil
+}
+
+// run runs the command of args with the wallet at walletPath.
+func run(ctx context.Context, walletPath string, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("missing command")
+	}
+	wallet, err := loadWallet(walletPath)
+	if err != nil {
+		return err
+	}
+	cmd := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	issuerURL := cmd.String("issuer", "http://127.0.0.1:8000", "URL of issuer-server")
+	verifierURL := cmd.String("verifier", "http://127.0.0.1:8001", "URL of verifier-service")
+	if err := cmd.Parse(args[1:]); err != nil {
+		return err
+	}
+	switch args[0] {
+	case "request":
+		if cmd.NArg() != 1 {
+			return errors.New("usage: request -issuer <url> <index>")
+		}
+		if err := request(ctx, wallet, *issuerURL, cmd.Arg(0), out); err != nil {
+			return err
+		}
+		return wallet.save(walletPath)
+	case "list":
+		for _, hIndex := range wallet.hIndexes() {
+			fmt.Fprintln(out, hIndex, wallet[hIndex].Id)
+		}
+		return nil
+	case "status":
+		return status(ctx, wallet, *issuerURL, out)
+	case "present":
+		if cmd.NArg() != 1 {
+			return errors.New("usage: present -verifier <url> <hIndex>")
+		}
+		return present(wallet, *verifierURL, cmd.Arg(0), out)
+	default:
+		return fmt.Errorf("unknown command %v", args[0])
+	}
+}
+
+func main() {
+	walletPath := flag.String("wallet", "wallet.json", "path of the wallet file")
+	flag.Parse()
+	if err := run(context.Background(), *walletPath, flag.Args(), os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,135 @@
+// issuer-server runs an issuer over an in memory storage and the simulated
+// IdenStates Smart Contract of components/idenpubonchain/sim, and serves:
+//
+//	POST /example/claims                  issue a claim and publish the state
+//	POST /credentials/existence/refresh   credential of an issued claim
+//	GET  /sim/states/<id>                 states of the simulated contract
+//
+// The issue endpoint takes a JSON ClaimRequest whose Index is the text put
+// in the index slot of a ClaimBasic, and returns a JSON ClaimResponse.
+//
+// Usage:
+//
+//	issuer-server [-addr 127.0.0.1:8000]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/components/credrefresh"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain/sim"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/identity/issuer"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathClaims is the path of the issue endpoint.
+const PathClaims = "/example/claims"
+
+// ClaimRequest is the request of the issue endpoint.
+type ClaimRequest struct {
+	Index string `json:"index"`
+}
+
+// ClaimResponse is the response of the issue endpoint.
+type ClaimResponse struct {
+	Id     string           `json:"id"`
+	HIndex *merkletree.Hash `json:"hIndex"`
+}
+
+// Error is the body of a failed response.
+type Error = httpjson.Error
+
+// newIssuer creates an issuer with a new key in an in memory keystore.
+func newIssuer(idenPubOnChain *sim.Backend) (*issuer.Issuer, error) {
+	pass := []byte("issuer-server")
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	if err != nil {
+		return nil, err
+	}
+	kOp, err := keyStore.NewKey(pass)
+	if err != nil {
+		return nil, err
+	}
+	if err := keyStore.UnlockKey(kOp, pass); err != nil {
+		return nil, err
+	}
+	return issuer.New(issuer.ConfigDefault, kOp, []merkletree.Entrier{}, db.NewMemoryStorage(),
+		keyStore, idenPubOnChain, nil)
+}
+
+// issueHandler issues the requested claims.  As the simulated contract mines
+// each state update right away, the state is published and synced before
+// responding, so that the credential of the claim can be requested next.
+func issueHandler(is *issuer.Issuer) http.HandlerFunc {
+	var mutex sync.Mutex
+	return func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			httpjson.WriteError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		var claimReq ClaimRequest
+		if err := json.NewDecoder(req.Body).Decode(&claimReq); err != nil {
+			httpjson.WriteError(w, http.StatusBadRequest, "invalid request: "+err.Error())
+			return
+		}
+		var index [claims.IndexSlotBytes]byte
+		if claimReq.Index == "" || len(claimReq.Index) > len(index) {
+			httpjson.WriteError(w, http.StatusBadRequest, "the index must have between 1 and 31 bytes")
+			return
+		}
+		copy(index[:], claimReq.Index)
+
+		mutex.Lock()
+		defer mutex.Unlock()
+		claim, err := is.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+			return claims.NewClaimBasic(index, [claims.DataSlotBytes]byte{}, revocationNonce), nil
+		})
+		if err == nil {
+			_, err = is.PublishState()
+		}
+		if err == nil {
+			err = is.SyncIdenStatePublic()
+		}
+		if err != nil {
+			log.WithError(err).Error("Issue claim")
+			httpjson.WriteError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		httpjson.Write(w, http.StatusOK, ClaimResponse{Id: is.ID().String(), HIndex: claim.Entry().HIndex()})
+	}
+}
+
+// newHandler creates an issuer and returns the handler of its endpoints.
+func newHandler() (http.Handler, *issuer.Issuer, error) {
+	backend := sim.New(clock.Real)
+	is, err := newIssuer(backend)
+	if err != nil {
+		return nil, nil, err
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathClaims, issueHandler(is))
+	mux.Handle(credrefresh.PathRefresh, credrefresh.Handler(is))
+	mux.Handle(sim.PathStates, sim.Handler(backend))
+	return mux, is, nil
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8000", "address to listen at")
+	flag.Parse()
+	handler, is, err := newHandler()
+	if err != nil {
+		log.WithError(err).Fatal("Unable to create the issuer")
+	}
+	log.WithField("id", is.ID()).WithField("addr", *addr).Info("Serving the issuer")
+	log.Fatal(http.ListenAndServe(*addr, handler))
+}
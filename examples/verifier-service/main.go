@@ -0,0 +1,68 @@
+// verifier-service verifies the credentials of existence presented by the
+// holders against the identity states of the simulated IdenStates Smart
+// Contract served by issuer-server, and serves:
+//
+//	POST /verify   verify a JSON proof.CredentialExistence
+//
+// The response is a JSON VerifyResponse.
+//
+// Usage:
+//
+//	verifier-service [-addr 127.0.0.1:8001] [-chain http://127.0.0.1:8000]
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"net/http"
+
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain/sim"
+	"github.com/iden3/go-iden3-core/components/verifier"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/internal/httpjson"
+	log "github.com/sirupsen/logrus"
+)
+
+// PathVerify is the path of the verify endpoint.
+const PathVerify = "/verify"
+
+// VerifyResponse is the response of the verify endpoint.
+type VerifyResponse struct {
+	Valid bool   `json:"valid"`
+	Id    string `json:"id,omitempty"`
+	// Error is the reason why the credential is not valid.
+	Error string `json:"error,omitempty"`
+}
+
+// newHandler returns the handler of the verify endpoint of a verifier that
+// reads the identity states from idenPubOnChain.
+func newHandler(idenPubOnChain idenpubonchain.IdenPubOnChainer) http.Handler {
+	v := verifier.New(idenPubOnChain)
+	mux := http.NewServeMux()
+	mux.HandleFunc(PathVerify, func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			httpjson.Write(w, http.StatusMethodNotAllowed, VerifyResponse{Error: "method not allowed"})
+			return
+		}
+		var credExist proof.CredentialExistence
+		if err := json.NewDecoder(req.Body).Decode(&credExist); err != nil {
+			httpjson.Write(w, http.StatusBadRequest, VerifyResponse{Error: "invalid request: " + err.Error()})
+			return
+		}
+		if err := v.VerifyCredentialExistence(&credExist); err != nil {
+			httpjson.Write(w, http.StatusOK, VerifyResponse{Error: err.Error()})
+			return
+		}
+		httpjson.Write(w, http.StatusOK, VerifyResponse{Valid: true, Id: credExist.Id.String()})
+	})
+	return mux
+}
+
+func main() {
+	addr := flag.String("addr", "127.0.0.1:8001", "address to listen at")
+	chain := flag.String("chain", "http://127.0.0.1:8000", "URL of the simulated contract")
+	flag.Parse()
+	log.WithField("addr", *addr).Info("Serving the verifier")
+	log.Fatal(http.ListenAndServe(*addr, newHandler(sim.NewClient(*chain, nil))))
+}
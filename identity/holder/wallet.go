@@ -0,0 +1,251 @@
+package holder
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"golang.org/x/crypto/nacl/secretbox"
+)
+
+var (
+	// ErrCredentialNotFound is used when a credential is not in the Wallet.
+	ErrCredentialNotFound = errors.New("credential not found in the wallet")
+	// ErrCredentialDecrypt is used when a stored credential can't be
+	// decrypted with the key of the Wallet.
+	ErrCredentialDecrypt = errors.New("unable to decrypt the stored credential")
+	// ErrCredentialInvalid is used when a credential lacks the claim or the
+	// issuer.
+	ErrCredentialInvalid = errors.New("the credential has no claim or issuer")
+	// ErrRefreshedCredentialMismatch is used when the refreshed existence
+	// credential is not of the claim and issuer of the stored one.
+	ErrRefreshedCredentialMismatch = errors.New("the refreshed credential doesn't match the stored one")
+)
+
+// ExistenceRefresher refreshes existence credentials against the newest
+// published state of their issuer, satisfied by credrefresh.Client.
+type ExistenceRefresher interface {
+	RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error)
+}
+
+var (
+	// dbPrefixWallet is the prefix of the storage of the Wallet.
+	dbPrefixWallet = []byte("wallet:")
+	// walletKeyInfo is the purpose used to derive the encryption key of
+	// the Wallet from a key of the keystore.
+	walletKeyInfo = []byte("iden3 holder wallet v1")
+)
+
+// CredentialID identifies a credential in the Wallet.  It's the hash of the
+// issuer ID and the claim, so storing the same claim twice overwrites it.
+type CredentialID [sha256.Size]byte
+
+// Credential is a credential received by the holder.
+type Credential struct {
+	// Issuer is the identity that issued the claim.
+	Issuer core.ID
+	// Claim is the raw claim entry.
+	Claim *merkletree.Entry
+	// Schema identifies the type of the claim, for example the URL of its
+	// JSON schema.
+	Schema string
+	// IssuanceDate is the date the credential was received.
+	IssuanceDate time.Time
+	// Existence is the credential of existence of the claim, if any.
+	Existence *proof.CredentialExistence `json:",omitempty"`
+	// Validity is the last credential of validity of the claim, if any.
+	Validity *proof.CredentialValidity `json:",omitempty"`
+}
+
+// ID returns the CredentialID of the credential.
+func (c *Credential) ID() CredentialID {
+	return sha256.Sum256(append(c.Issuer[:], c.Claim.Bytes()...))
+}
+
+// CredentialFilter selects the credentials listed by Wallet.List.  The zero
+// value of each field matches all the credentials.
+type CredentialFilter struct {
+	// Issuer selects the credentials issued by this identity.
+	Issuer *core.ID
+	// Schema selects the credentials with this schema.
+	Schema string
+}
+
+func (f *CredentialFilter) match(c *Credential) bool {
+	if f == nil {
+		return true
+	}
+	if f.Issuer != nil && !f.Issuer.Equal(&c.Issuer) {
+		return false
+	}
+	if f.Schema != "" && f.Schema != c.Schema {
+		return false
+	}
+	return true
+}
+
+// Wallet persists the credentials received by a holder.  Each credential is
+// stored JSON encoded and encrypted (with NaCl secretbox) under its
+// CredentialID, with a key derived from a key of the keystore, so the
+// storage alone doesn't reveal the credentials nor who issued them.
+type Wallet struct {
+	rw      sync.RWMutex
+	storage db.Storage
+	key     [32]byte
+}
+
+// NewWallet creates a Wallet that stores the credentials in storage,
+// encrypted with a key derived from the key kOp of keyStore, which must be
+// unlocked.
+func NewWallet(storage db.Storage, keyStore *keystore.KeyStore, kOp *babyjub.PublicKeyComp) (*Wallet, error) {
+	key, err := keyStore.DeriveKey(kOp, walletKeyInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &Wallet{storage: storage.WithPrefix(dbPrefixWallet), key: *key}, nil
+}
+
+func (w *Wallet) encrypt(c *Credential) ([]byte, error) {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	var nonce [24]byte
+	if _, err := io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return nil, err
+	}
+	return secretbox.Seal(nonce[:], data, &nonce, &w.key), nil
+}
+
+func (w *Wallet) decrypt(box []byte) (*Credential, error) {
+	if len(box) < 24 {
+		return nil, ErrCredentialDecrypt
+	}
+	var nonce [24]byte
+	copy(nonce[:], box[:24])
+	data, ok := secretbox.Open(nil, box[24:], &nonce, &w.key)
+	if !ok {
+		return nil, ErrCredentialDecrypt
+	}
+	var c Credential
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// Put stores the credential c, replacing the stored one with the same
+// CredentialID, and returns its CredentialID.
+func (w *Wallet) Put(c *Credential) (CredentialID, error) {
+	if c.Claim == nil || c.Issuer.Equal(&core.ID{}) {
+		return CredentialID{}, ErrCredentialInvalid
+	}
+	id := c.ID()
+	box, err := w.encrypt(c)
+	if err != nil {
+		return CredentialID{}, err
+	}
+	w.rw.Lock()
+	defer w.rw.Unlock()
+	tx, err := w.storage.NewTx()
+	if err != nil {
+		return CredentialID{}, err
+	}
+	tx.Put(id[:], box)
+	if err := tx.Commit(); err != nil {
+		tx.Close()
+		return CredentialID{}, err
+	}
+	return id, nil
+}
+
+// Get returns the credential with id.
+func (w *Wallet) Get(id CredentialID) (*Credential, error) {
+	w.rw.RLock()
+	defer w.rw.RUnlock()
+	box, err := w.storage.Get(id[:])
+	if err == db.ErrNotFound {
+		return nil, ErrCredentialNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return w.decrypt(box)
+}
+
+// List returns the credentials that match filter (all of them if filter is
+// nil), sorted by IssuanceDate.
+func (w *Wallet) List(filter *CredentialFilter) ([]*Credential, error) {
+	w.rw.RLock()
+	defer w.rw.RUnlock()
+	creds := []*Credential{}
+	if err := w.storage.Iterate(func(_, box []byte) (bool, error) {
+		c, err := w.decrypt(box)
+		if err != nil {
+			return false, err
+		}
+		if filter.match(c) {
+			creds = append(creds, c)
+		}
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(creds, func(i, j int) bool { return creds[i].IssuanceDate.Before(creds[j].IssuanceDate) })
+	return creds, nil
+}
+
+// Delete removes the credential with id.
+func (w *Wallet) Delete(id CredentialID) error {
+	w.rw.Lock()
+	defer w.rw.Unlock()
+	if _, err := w.storage.Get(id[:]); err == db.ErrNotFound {
+		return ErrCredentialNotFound
+	} else if err != nil {
+		return err
+	}
+	tx, err := w.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	tx.Delete(id[:])
+	if err := tx.Commit(); err != nil {
+		tx.Close()
+		return err
+	}
+	return nil
+}
+
+// RefreshExistence requests with r the existence credential of the claim of
+// the stored credential with id in the newest published state of its issuer,
+// and stores it.  The errors of r (like issuer.ErrClaimRevoked) are returned
+// without modifying the stored credential.
+func (w *Wallet) RefreshExistence(r ExistenceRefresher, id CredentialID) (*Credential, error) {
+	c, err := w.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	credExist, err := r.RefreshCredentialExistence(c.Claim.HIndex())
+	if err != nil {
+		return nil, err
+	}
+	if credExist.Claim == nil || !credExist.Claim.Equal(c.Claim) ||
+		credExist.Id == nil || !credExist.Id.Equal(&c.Issuer) {
+		return nil, ErrRefreshedCredentialMismatch
+	}
+	c.Existence = credExist
+	if _, err := w.Put(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
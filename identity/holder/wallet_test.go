@@ -0,0 +1,152 @@
+package holder
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pass = []byte("my passphrase")
+
+func newTestWallet(t *testing.T, storage db.Storage) *Wallet {
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	kOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kOp, pass))
+	w, err := NewWallet(storage, keyStore, kOp)
+	require.Nil(t, err)
+	return w
+}
+
+func newTestID(n byte) core.ID {
+	return core.NewID(core.TypeBJP0, [27]byte{n})
+}
+
+func newTestCredential(issuer byte, schema string, n byte, date time.Time) *Credential {
+	claim := &merkletree.Entry{}
+	claim.Data[1][0] = n
+	return &Credential{
+		Issuer:       newTestID(issuer),
+		Claim:        claim,
+		Schema:       schema,
+		IssuanceDate: date,
+	}
+}
+
+func TestWallet(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	w := newTestWallet(t, storage)
+	date := time.Unix(1600000000, 0).UTC()
+
+	cred1 := newTestCredential(1, "email", 1, date.Add(2*time.Hour))
+	cred1.Existence = &proof.CredentialExistence{
+		Id:            &cred1.Issuer,
+		IdenStateData: proof.IdenStateData{BlockN: 3, BlockTs: 4, IdenState: &merkletree.Hash{5}},
+		Claim:         cred1.Claim,
+		IdPubUrl:      "https://issuer1",
+	}
+	cred2 := newTestCredential(1, "kyc", 2, date.Add(time.Hour))
+	cred3 := newTestCredential(2, "email", 3, date)
+	ids := []CredentialID{}
+	for _, cred := range []*Credential{cred1, cred2, cred3} {
+		id, err := w.Put(cred)
+		require.Nil(t, err)
+		assert.Equal(t, cred.ID(), id)
+		ids = append(ids, id)
+	}
+
+	c, err := w.Get(ids[0])
+	require.Nil(t, err)
+	assert.Equal(t, cred1, c)
+
+	issuer1 := newTestID(1)
+	creds, err := w.List(nil)
+	require.Nil(t, err)
+	assert.Equal(t, []*Credential{cred3, cred2, cred1}, creds)
+	creds, err = w.List(&CredentialFilter{Issuer: &issuer1})
+	require.Nil(t, err)
+	assert.Equal(t, []*Credential{cred2, cred1}, creds)
+	creds, err = w.List(&CredentialFilter{Issuer: &issuer1, Schema: "email"})
+	require.Nil(t, err)
+	assert.Equal(t, []*Credential{cred1}, creds)
+
+	require.Nil(t, w.Delete(ids[0]))
+	_, err = w.Get(ids[0])
+	assert.Equal(t, ErrCredentialNotFound, err)
+	assert.Equal(t, ErrCredentialNotFound, w.Delete(ids[0]))
+	creds, err = w.List(&CredentialFilter{Schema: "email"})
+	require.Nil(t, err)
+	assert.Equal(t, []*Credential{cred3}, creds)
+
+	_, err = w.Put(&Credential{Issuer: issuer1})
+	assert.Equal(t, ErrCredentialInvalid, err)
+}
+
+func TestWalletEncrypted(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	w := newTestWallet(t, storage)
+	cred := newTestCredential(1, "email-schema", 1, time.Unix(1600000000, 0).UTC())
+	id, err := w.Put(cred)
+	require.Nil(t, err)
+
+	// The stored value doesn't contain the credential in clear
+	box, err := storage.WithPrefix(dbPrefixWallet).Get(id[:])
+	require.Nil(t, err)
+	assert.NotContains(t, string(box), "email-schema")
+
+	// A wallet with a different key can't read the credential
+	other := newTestWallet(t, storage)
+	_, err = other.Get(id)
+	assert.Equal(t, ErrCredentialDecrypt, err)
+	_, err = other.List(nil)
+	assert.Equal(t, ErrCredentialDecrypt, err)
+}
+
+type existenceRefresher struct {
+	credExist *proof.CredentialExistence
+	err       error
+}
+
+func (r *existenceRefresher) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	return r.credExist, r.err
+}
+
+func TestWalletRefreshExistence(t *testing.T) {
+	w := newTestWallet(t, db.NewMemoryStorage())
+	cred := newTestCredential(1, "email", 1, time.Unix(1600000000, 0).UTC())
+	id, err := w.Put(cred)
+	require.Nil(t, err)
+
+	credExist := &proof.CredentialExistence{
+		Id:            &cred.Issuer,
+		IdenStateData: proof.IdenStateData{BlockN: 3, BlockTs: 4, IdenState: &merkletree.Hash{5}},
+		Claim:         cred.Claim,
+	}
+	c, err := w.RefreshExistence(&existenceRefresher{credExist: credExist}, id)
+	require.Nil(t, err)
+	assert.Equal(t, credExist, c.Existence)
+	c, err = w.Get(id)
+	require.Nil(t, err)
+	assert.Equal(t, credExist.IdenStateData, c.Existence.IdenStateData)
+
+	errRevoked := fmt.Errorf("revoked")
+	_, err = w.RefreshExistence(&existenceRefresher{err: errRevoked}, id)
+	assert.Equal(t, errRevoked, err)
+
+	other := newTestID(2)
+	_, err = w.RefreshExistence(&existenceRefresher{credExist: &proof.CredentialExistence{
+		Id:    &other,
+		Claim: cred.Claim,
+	}}, id)
+	assert.Equal(t, ErrRefreshedCredentialMismatch, err)
+}
@@ -0,0 +1,49 @@
+package issuer
+
+import (
+	"encoding/binary"
+	"testing"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/stretchr/testify/require"
+)
+
+func benchClaim(i int) *claims.ClaimBasic {
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	binary.BigEndian.PutUint64(indexBytes[:8], uint64(i))
+	return claims.NewClaimBasic(indexBytes, dataBytes, uint32(i))
+}
+
+func BenchmarkIssueClaim(b *testing.B) {
+	issuer, _, _ := newIssuer(b, nil)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := issuer.IssueClaim(benchClaim(i)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkGenCredentialExistence(b *testing.B) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(b, idenPubOnChain)
+	genesisState, _ := issuer.state()
+	n := 1000
+	for i := 0; i < n; i++ {
+		require.Nil(b, issuer.IssueClaim(benchClaim(i)))
+	}
+	_, newState := mockInitState(b, idenPubOnChain, issuer, genesisState)
+	_, err := issuer.PublishState()
+	require.Nil(b, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: newState}, nil).Once()
+	require.Nil(b, issuer.SyncIdenStatePublic())
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := issuer.GenCredentialExistence(benchClaim(i % n)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package issuer
+
+import (
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+)
+
+var (
+	ErrCheckpointNotSupported = fmt.Errorf("the issuer storage doesn't support checkpoints")
+	ErrStorageNotEmpty        = fmt.Errorf("the storage to restore the checkpoint into is not empty")
+)
+
+// Checkpoint writes a consistent snapshot of the Issuer storage in a new
+// leveldb database at path (see db.Checkpointer), while the Issuer keeps
+// running.  The Issuer mutations wait for the checkpoint to be written, so
+// that it never contains a partially applied operation.  The checkpoint can
+// be restored with RestoreFromCheckpoint.
+func (is *Issuer) Checkpoint(path string) error {
+	checkpointer, ok := is.storage.(db.Checkpointer)
+	if !ok {
+		return ErrCheckpointNotSupported
+	}
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	return checkpointer.Checkpoint(path)
+}
+
+// RestoreFromCheckpoint copies the checkpoint at path, written by
+// Issuer.Checkpoint, into the empty storage and loads the Issuer from it
+// like Load.
+func RestoreFromCheckpoint(path string, storage db.Storage, keyStore *keystore.KeyStore,
+	idenPubOnChain idenpubonchain.IdenPubOnChainer, hooks *Hooks) (*Issuer, error) {
+	empty := true
+	if err := storage.Iterate(func([]byte, []byte) (bool, error) {
+		empty = false
+		return false, nil
+	}); err != nil {
+		return nil, err
+	}
+	if !empty {
+		return nil, ErrStorageNotEmpty
+	}
+	checkpoint, err := db.NewLevelDbStorage(path, true)
+	if err != nil {
+		return nil, err
+	}
+	defer checkpoint.Close()
+	tx, err := storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if err := checkpoint.Iterate(func(k, v []byte) (bool, error) {
+		tx.Put(append([]byte{}, k...), append([]byte{}, v...))
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return Load(storage, keyStore, idenPubOnChain, hooks)
+}
@@ -0,0 +1,41 @@
+package issuer
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckpoint(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issuer-checkpoint")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "checkpoint")
+
+	issuer, storage, keyStore := newIssuer(t, nil)
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.claimsTree.AddClaim(claim0))
+	require.Nil(t, issuer.Checkpoint(path))
+
+	// The claims added after the checkpoint are not restored
+	indexBytes[0] = 1
+	claim1 := claims.NewClaimBasic(indexBytes, dataBytes, 1)
+	require.Nil(t, issuer.claimsTree.AddClaim(claim1))
+
+	_, err = RestoreFromCheckpoint(path, storage, keyStore, nil, nil)
+	assert.Equal(t, ErrStorageNotEmpty, err)
+	restored, err := RestoreFromCheckpoint(path, db.NewMemoryStorage(), keyStore, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, issuer.id, restored.id)
+	_, err = restored.ClaimByHIndex(claim0.Entry().HIndex())
+	assert.Nil(t, err)
+	_, err = restored.ClaimByHIndex(claim1.Entry().HIndex())
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,111 @@
+package issuer
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+// DemoPassphrase is the passphrase of the keys stored in the KeyStore of a
+// Demo.
+var DemoPassphrase = []byte("iden3 demo")
+
+// demoClaimEthKeyNonce is the revocation nonce of the genesis
+// ClaimAuthEthKey of a Demo.  The genesis ClaimAuthorizeKSignBabyJub uses
+// the nonce 0.
+const demoClaimEthKeyNonce = 1
+
+// Demo is an Issuer of an identity derived deterministically from a seed,
+// intended for development and testing only: the same seed always produces
+// the same keys, genesis claims and identity ID.  The Issuer is kept in
+// memory, and publishes its state with the IdenPubOnChainer given to
+// NewDemoOnChain, if any.
+type Demo struct {
+	*Issuer
+	// Storage is the in-memory storage of the Issuer.
+	Storage db.Storage
+	// KeyStore is the in-memory key store of the Issuer, with KOp unlocked
+	// and encrypted with DemoPassphrase.
+	KeyStore *keystore.KeyStore
+	// KOp is the operational key of the identity, authorized in the
+	// genesis state with a ClaimAuthorizeKSignBabyJub.
+	KOp babyjub.PrivateKey
+	// EthKey is an ethereum key authorized in the genesis state with a
+	// ClaimAuthEthKey of type EthKeyTypeAuthenticate.
+	EthKey *ecdsa.PrivateKey
+	// ClaimEthKey is the genesis ClaimAuthEthKey of EthKey.
+	ClaimEthKey *claims.ClaimAuthEthKey
+}
+
+// demoKey derives a 32 byte key for the purpose label from seed.
+func demoKey(seed []byte, label string) [32]byte {
+	return sha256.Sum256(append([]byte("iden3 demo "+label+":"), seed...))
+}
+
+// NewDemo creates a Demo Issuer whose keys and genesis claims are derived
+// deterministically from seed, without IdenPubOnChainer.  Never use it with
+// real funds or identities: anyone knowing the seed has the keys.
+func NewDemo(seed []byte) (*Demo, error) {
+	return NewDemoOnChain(seed, nil)
+}
+
+// NewDemoOnChain creates a Demo Issuer like NewDemo that publishes its state
+// with idenPubOnChain, such as the mock of the tests.
+func NewDemoOnChain(seed []byte, idenPubOnChain idenpubonchain.IdenPubOnChainer) (*Demo, error) {
+	kOp := babyjub.PrivateKey(demoKey(seed, "kop"))
+	ethKeyBytes := demoKey(seed, "ethkey")
+	ethKey, err := ethcrypto.ToECDSA(ethKeyBytes[:])
+	if err != nil {
+		return nil, err
+	}
+
+	storage := db.NewMemoryStorage()
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	if err != nil {
+		return nil, err
+	}
+	kOpComp, err := keyStore.ImportKey(kOp, DemoPassphrase)
+	if err != nil {
+		return nil, err
+	}
+	if err := keyStore.UnlockKey(kOpComp, DemoPassphrase); err != nil {
+		return nil, err
+	}
+
+	claimEthKey := claims.NewClaimAuthEthKey(ethcrypto.PubkeyToAddress(ethKey.PublicKey),
+		claims.EthKeyTypeAuthenticate, demoClaimEthKeyNonce)
+	is, err := New(ConfigDefault, kOpComp, []merkletree.Entrier{claimEthKey}, storage, keyStore, idenPubOnChain, nil)
+	if err != nil {
+		return nil, err
+	}
+	// Reserve the nonce used by the genesis ClaimAuthEthKey so that it's
+	// not reused by the claims issued later.
+	tx, err := storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := is.nonceGen.Next(tx); err != nil {
+		tx.Close()
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &Demo{
+		Issuer:      is,
+		Storage:     storage,
+		KeyStore:    keyStore,
+		KOp:         kOp,
+		EthKey:      ethKey,
+		ClaimEthKey: claimEthKey,
+	}, nil
+}
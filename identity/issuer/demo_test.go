@@ -0,0 +1,60 @@
+package issuer
+
+import (
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDemo(t *testing.T) {
+	demo0, err := NewDemo([]byte("seed0"))
+	require.Nil(t, err)
+	// The identity is stable across versions for a given seed
+	assert.Equal(t, "116Y3MVpsCWSWR1ef5aMgCg8AB7jMeTGQb8CXs27xj", demo0.ID().String())
+	assert.Equal(t, "0x107618bD90C32Aec120e118e8B0Fcb1Db2537ef8", demo0.ClaimEthKey.EthKey.Hex())
+	assert.Equal(t, ethcrypto.PubkeyToAddress(demo0.EthKey.PublicKey), demo0.ClaimEthKey.EthKey)
+
+	demo0Again, err := NewDemo([]byte("seed0"))
+	require.Nil(t, err)
+	assert.Equal(t, demo0.ID(), demo0Again.ID())
+	assert.Equal(t, demo0.KOp, demo0Again.KOp)
+
+	demo1, err := NewDemo([]byte("seed1"))
+	require.Nil(t, err)
+	assert.NotEqual(t, demo0.ID(), demo1.ID())
+
+	// The genesis claims are in the claims tree
+	_, err = demo0.ClaimByHIndex(demo0.ClaimEthKey.Entry().HIndex())
+	require.Nil(t, err)
+	kOpPub := demo0.KOp.Public()
+	_, err = demo0.ClaimByHIndex(claims.NewClaimAuthorizeKSignBabyJub(kOpPub, 0).Entry().HIndex())
+	require.Nil(t, err)
+
+	// The Demo can be loaded with an IdenPubOnChainer to issue claims and
+	// publish its state
+	idenPubOnChain := idenpubonchain.New()
+	idenPubOnChain.On("GetState", demo0.ID()).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero}, nil).Once()
+	is, err := Load(demo0.Storage, demo0.KeyStore, idenPubOnChain, nil)
+	require.Nil(t, err)
+	genesisState, _ := is.State()
+
+	// The nonce of the genesis ClaimAuthEthKey is not reused
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	claim, err := is.IssueClaimWithNonce(func(nonce uint32) (merkletree.Entrier, error) {
+		return claims.NewClaimBasic(indexBytes, dataBytes, nonce), nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, uint32(2), claims.GetRevocationNonce(claim.Entry()))
+
+	ethTx, _ := mockInitState(t, idenPubOnChain, is, genesisState)
+	res, err := is.PublishState()
+	require.Nil(t, err)
+	assert.Equal(t, PublishStateSubmitted, res.Status)
+	assert.Equal(t, ethTx, res.EthTx)
+}
@@ -0,0 +1,190 @@
+package issuer
+
+import (
+	"encoding/binary"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// dbPrefixExpiration is the prefix of the expiration records of the
+	// claims: dbPrefixExpiration | hIndex -> little endian unix time.
+	dbPrefixExpiration = []byte("expiration:")
+)
+
+// SetClaimExpiration records the expiration time of the issued claim at
+// hIndex.  Once expired, the claim is revoked by SweepExpired.  It fails with
+// ErrClaimNotFound if the claim has not been issued.
+func (is *Issuer) SetClaimExpiration(hIndex *merkletree.Hash, expiration time.Time) error {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	if _, err := is.claimsTree.GetDataByIndex(hIndex); err == merkletree.ErrEntryIndexNotFound {
+		return ErrClaimNotFound
+	} else if err != nil {
+		return err
+	}
+	return is.putClaimExpiration(hIndex, expiration)
+}
+
+// putClaimExpiration records the expiration time of the claim at hIndex, if
+// it's not zero.  The claims issued with an expiration have it recorded
+// before they are added to the claims tree, so that they can't be issued
+// without it: if the issuance fails afterwards, the record is dropped by
+// SweepExpired.  The caller must hold the write lock.
+func (is *Issuer) putClaimExpiration(hIndex *merkletree.Hash, expiration time.Time) error {
+	if expiration.IsZero() {
+		return nil
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	var v [8]byte
+	binary.LittleEndian.PutUint64(v[:], uint64(expiration.Unix()))
+	tx.Put(append(dbPrefixExpiration, hIndex[:]...), v[:])
+	return tx.Commit()
+}
+
+// IssueClaimWithExpiration issues a claim that expires at expiration, which
+// is recorded together with the issuance.  See IssueClaim and
+// SetClaimExpiration.
+func (is *Issuer) IssueClaimWithExpiration(claim merkletree.Entrier, expiration time.Time) error {
+	return is.issueClaimExpiring(claim, expiration)
+}
+
+// ClaimExpiration returns the expiration time of the issued claim at hIndex,
+// or db.ErrNotFound if the claim doesn't expire or has already been swept.
+func (is *Issuer) ClaimExpiration(hIndex *merkletree.Hash) (time.Time, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	v, err := is.storage.Get(append(dbPrefixExpiration, hIndex[:]...))
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(int64(binary.LittleEndian.Uint64(v)), 0), nil
+}
+
+// SweepExpired revokes the claims whose expiration time is not after now,
+// and removes their expiration record.  Claims already revoked, or not in
+// the claims tree because their issuance failed, are only removed from the
+// records.  If Config.PublishOnExpiration is set and some
+// claim was revoked, the identity state is published.  Returns the number of
+// revoked claims.
+func (is *Issuer) SweepExpired(now time.Time) (int, error) {
+	if is.idenPubOnChain == nil {
+		return 0, ErrIdenPubOnChainNil
+	}
+	events, err := is.sweepExpired(now)
+	for _, event := range events {
+		is.hooks.claimRevoked(event)
+	}
+	if err != nil {
+		return len(events), err
+	}
+	if len(events) != 0 && is.cfg.PublishOnExpiration {
+		if _, err := is.PublishState(); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+func (is *Issuer) sweepExpired(now time.Time) ([]*ClaimRevokedEvent, error) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	var expired []merkletree.Hash
+	if err := is.storage.WithPrefix(dbPrefixExpiration).Iterate(func(k, v []byte) (bool, error) {
+		if int64(binary.LittleEndian.Uint64(v)) <= now.Unix() {
+			var hIndex merkletree.Hash
+			copy(hIndex[:], k)
+			expired = append(expired, hIndex)
+		}
+		return true, nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(expired) == 0 {
+		return nil, nil
+	}
+
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	events := []*ClaimRevokedEvent{}
+	for i := range expired {
+		hIndex := &expired[i]
+		event, err := is.revokeClaim(hIndex)
+		if err == nil {
+			events = append(events, event)
+		} else if err != merkletree.ErrEntryIndexAlreadyExists && err != merkletree.ErrEntryIndexNotFound {
+			return events, err
+		}
+		tx.Delete(append(dbPrefixExpiration, hIndex[:]...))
+	}
+	if err := tx.Commit(); err != nil {
+		return events, err
+	}
+	return events, nil
+}
+
+// ExpirationSweeper calls Issuer.SweepExpired periodically in the background,
+// so that expired claims can't be presented as non-revoked indefinitely.
+type ExpirationSweeper struct {
+	is       *Issuer
+	clock    clock.Clock
+	interval time.Duration
+	stop     chan struct{}
+	wg       *sync.WaitGroup
+	once     *sync.Once
+}
+
+// StartExpirationSweeper starts an ExpirationSweeper that sweeps the expired
+// claims every interval.
+func (is *Issuer) StartExpirationSweeper(interval time.Duration) *ExpirationSweeper {
+	is.rw.RLock()
+	clk := is.clock
+	is.rw.RUnlock()
+	s := &ExpirationSweeper{
+		is:       is,
+		clock:    clk,
+		interval: interval,
+		stop:     make(chan struct{}),
+		wg:       &sync.WaitGroup{},
+		once:     &sync.Once{},
+	}
+	s.wg.Add(1)
+	go s.run()
+	return s
+}
+
+// Stop stops the ExpirationSweeper and waits for it to finish.
+func (s *ExpirationSweeper) Stop() {
+	s.once.Do(func() { close(s.stop) })
+	s.wg.Wait()
+}
+
+func (s *ExpirationSweeper) run() {
+	defer s.wg.Done()
+	ticker := s.clock.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			n, err := s.is.SweepExpired(s.clock.Now())
+			if err != nil {
+				log.WithError(err).WithField("revoked", n).Error("ExpirationSweeper: sweep failed")
+			} else if n != 0 {
+				log.WithField("revoked", n).Info("ExpirationSweeper: expired claims revoked")
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
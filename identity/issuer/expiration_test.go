@@ -0,0 +1,120 @@
+package issuer
+
+import (
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newExpirationClaim(index byte) *claims.ClaimBasic {
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = index
+	return claims.NewClaimBasic(indexBytes, dataBytes, uint32(index))
+}
+
+func claimRevoked(t *testing.T, is *Issuer, claim merkletree.Entrier) bool {
+	data, err := is.claimsTree.GetDataByIndex(claim.Entry().HIndex())
+	require.Nil(t, err)
+	nonce := claims.GetRevocationNonce(&merkletree.Entry{Data: *data})
+	_, err = is.revocationsTree.GetDataByIndex(claims.HIndexLeafRevocationsTree(nonce, claims.RevocationVersionAll))
+	return err == nil
+}
+
+func TestIssuerSweepExpired(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	var revoked []*ClaimRevokedEvent
+	issuer.hooks = &Hooks{OnClaimRevoked: func(ev *ClaimRevokedEvent) { revoked = append(revoked, ev) }}
+
+	now := time.Unix(1600000000, 0)
+	claim0, claim1, claim2 := newExpirationClaim(1), newExpirationClaim(2), newExpirationClaim(3)
+	require.Nil(t, issuer.IssueClaimWithExpiration(claim0, now.Add(-time.Hour)))
+	require.Nil(t, issuer.IssueClaimWithExpiration(claim1, now.Add(time.Hour)))
+	require.Nil(t, issuer.IssueClaim(claim2))
+	assert.Equal(t, ErrClaimNotFound, issuer.SetClaimExpiration(newExpirationClaim(4).Entry().HIndex(), now))
+
+	expiration, err := issuer.ClaimExpiration(claim1.Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, now.Add(time.Hour), expiration)
+	_, err = issuer.ClaimExpiration(claim2.Entry().HIndex())
+	assert.Equal(t, db.ErrNotFound, err)
+
+	n, err := issuer.SweepExpired(now)
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, claimRevoked(t, issuer, claim0))
+	assert.False(t, claimRevoked(t, issuer, claim1))
+	assert.False(t, claimRevoked(t, issuer, claim2))
+	require.Equal(t, 1, len(revoked))
+	assert.Equal(t, claim0.Entry().Data, revoked[0].Claim.Data)
+	_, err = issuer.ClaimExpiration(claim0.Entry().HIndex())
+	assert.Equal(t, db.ErrNotFound, err)
+
+	// Nothing left to sweep until claim1 expires.
+	n, err = issuer.SweepExpired(now)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+
+	// A claim already revoked is only removed from the records.
+	require.Nil(t, issuer.RevokeClaim(claim1))
+	n, err = issuer.SweepExpired(now.Add(time.Hour))
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	_, err = issuer.ClaimExpiration(claim1.Entry().HIndex())
+	assert.Equal(t, db.ErrNotFound, err)
+
+	// The record of a claim whose issuance failed is removed.
+	claim4 := newExpirationClaim(4)
+	require.Nil(t, issuer.putClaimExpiration(claim4.Entry().HIndex(), now))
+	n, err = issuer.SweepExpired(now)
+	require.Nil(t, err)
+	assert.Equal(t, 0, n)
+	_, err = issuer.ClaimExpiration(claim4.Entry().HIndex())
+	assert.Equal(t, db.ErrNotFound, err)
+}
+
+func TestIssuerExpirationSweeperPublish(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.cfg.PublishOnExpiration = true
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	issuer.SetClock(clk)
+	genesisState, _ := issuer.state()
+
+	claim := newExpirationClaim(1)
+	require.Nil(t, issuer.IssueClaimWithExpiration(claim, clk.Now().Add(time.Second)))
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
+	idenPubOnChain.On("InitState", issuer.id, genesisState, mock.Anything, []byte(nil), []byte(nil), mock.Anything).
+		Return(ethTx, nil).Once()
+
+	sweeper := issuer.StartExpirationSweeper(time.Minute)
+	clk.BlockUntil(1)
+	assert.False(t, claimRevoked(t, issuer, claim))
+	clk.Advance(time.Minute)
+	for i := 0; i < 100; i++ {
+		if _, _, pending := issuer.PendingState(); pending {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	sweeper.Stop()
+	sweeper.Stop()
+
+	assert.True(t, claimRevoked(t, issuer, claim))
+	idenStatePending, _, pending := issuer.PendingState()
+	require.True(t, pending)
+	idenState, _ := issuer.State()
+	assert.Equal(t, idenState, idenStatePending)
+	idenPubOnChain.AssertExpectations(t)
+}
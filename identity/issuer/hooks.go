@@ -0,0 +1,84 @@
+package issuer
+
+import (
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// ClaimIssuedEvent is the payload of the Hooks.OnClaimIssued callback.
+type ClaimIssuedEvent struct {
+	Claim *merkletree.Entry
+}
+
+// ClaimRevokedEvent is the payload of the Hooks.OnClaimRevoked callback.
+type ClaimRevokedEvent struct {
+	Claim           *merkletree.Entry
+	RevocationNonce uint32
+}
+
+// StatePublishedEvent is the payload of the Hooks.OnStatePublished callback.
+// EthTx is the transaction that sets the new identity state in the Smart
+// Contract.
+type StatePublishedEvent struct {
+	IdenState          *merkletree.Hash
+	IdenStateTreeRoots IdenStateTreeRoots
+	EthTx              *types.Transaction
+}
+
+// StateSyncedEvent is the payload of the Hooks.OnStateSynced callback.
+type StateSyncedEvent struct {
+	IdenStateData proof.IdenStateData
+}
+
+// Hooks is a set of callbacks that the Issuer calls after each change in its
+// lifecycle, so that the applications embedding it can trigger webhooks,
+// audit logs or notifications.  Any of the callbacks can be nil.  The
+// callbacks are called synchronously once the change has been committed to
+// the storage and the Issuer lock has been released, so they can call the
+// Issuer methods.
+type Hooks struct {
+	// OnClaimIssued is called after a claim is added to the claims tree.
+	OnClaimIssued func(*ClaimIssuedEvent)
+	// OnClaimRevoked is called after a claim is revoked.
+	OnClaimRevoked func(*ClaimRevokedEvent)
+	// OnStatePublished is called after a new identity state has been sent
+	// to the Smart Contract.
+	OnStatePublished func(*StatePublishedEvent)
+	// OnStateSynced is called after the pending identity state is found
+	// in the Smart Contract and becomes the identity state on chain.
+	OnStateSynced func(*StateSyncedEvent)
+	// OnStateReorged is called after a reorg reverted the identity state
+	// on chain, which must be published again (see StateReorgedEvent).
+	OnStateReorged func(*StateReorgedEvent)
+}
+
+func (h *Hooks) claimIssued(ev *ClaimIssuedEvent) {
+	if h != nil && h.OnClaimIssued != nil && ev != nil {
+		h.OnClaimIssued(ev)
+	}
+}
+
+func (h *Hooks) claimRevoked(ev *ClaimRevokedEvent) {
+	if h != nil && h.OnClaimRevoked != nil && ev != nil {
+		h.OnClaimRevoked(ev)
+	}
+}
+
+func (h *Hooks) statePublished(ev *StatePublishedEvent) {
+	if h != nil && h.OnStatePublished != nil && ev != nil {
+		h.OnStatePublished(ev)
+	}
+}
+
+func (h *Hooks) stateSynced(ev *StateSyncedEvent) {
+	if h != nil && h.OnStateSynced != nil && ev != nil {
+		h.OnStateSynced(ev)
+	}
+}
+
+func (h *Hooks) stateReorged(ev *StateReorgedEvent) {
+	if h != nil && h.OnStateReorged != nil && ev != nil {
+		h.OnStateReorged(ev)
+	}
+}
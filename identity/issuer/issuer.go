@@ -1,28 +1,43 @@
 package issuer
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"math"
 	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
 	"github.com/iden3/go-iden3-core/components/idenpubonchain"
 	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/claims"
 	"github.com/iden3/go-iden3-core/core/genesis"
 	"github.com/iden3/go-iden3-core/core/proof"
 	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/db/migrations"
 	"github.com/iden3/go-iden3-core/keystore"
 	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/iden3/go-iden3-core/utils/retry"
+	"github.com/iden3/go-iden3-core/utils/worker"
 
 	"github.com/iden3/go-iden3-crypto/babyjub"
 )
 
 var (
 	ErrIdenPubOnChainNil         = fmt.Errorf("idenPubOnChain is nil")
-	ErrIdenStatePendingNotNil    = fmt.Errorf("Update of the published IdenState is pending")
 	ErrIdenStateOnChainZero      = fmt.Errorf("No IdenState known to be on chain")
 	ErrClaimNotFoundStateOnChain = fmt.Errorf("Claim not found under the on chain identity state")
+	ErrClaimNotFound             = fmt.Errorf("Claim not found in the claims tree")
+	ErrClaimRevoked              = fmt.Errorf("Claim revoked in the on chain identity state")
+	ErrClaimNotFoundGenesis      = fmt.Errorf("Claim not found in the genesis identity state")
+	// Deprecated: ErrIdenStatePendingNotNil is no longer returned:
+	// PublishState reports a pending update with PublishStateAlreadyPending.
+	ErrIdenStatePendingNotNil = fmt.Errorf("Update of the published IdenState is pending")
 )
 
 var (
@@ -30,6 +45,7 @@ var (
 	dbPrefixRevocationTree = []byte("treerevocation:")
 	dbPrefixRootsTree      = []byte("treeroots:")
 	dbPrefixIdenStateList  = []byte("idenstates:")
+	dbPrefixIdenStateRefs  = []byte("idenstaterefs:")
 	dbKeyConfig            = []byte("config")
 	dbKeyKOp               = []byte("kop")
 	dbKeyId                = []byte("id")
@@ -46,13 +62,61 @@ var (
 )
 
 // ConfigDefault is a default configuration for the Issuer.
-var ConfigDefault = Config{MaxLevelsClaimsTree: 140, MaxLevelsRevocationTree: 140, MaxLevelsRootsTree: 140}
+var ConfigDefault = Config{MaxLevelsClaimsTree: 140, MaxLevelsRevocationTree: 140, MaxLevelsRootsTree: 140,
+	SyncRetry: retry.Policy{MaxAttempts: 3, BackoffMin: 1 * time.Second, BackoffMax: 10 * time.Second, Jitter: 0.2}}
+
+// DuplicatePolicy tells what the Issuer does when issuing a claim whose hIndex
+// is already in the claims tree.
+type DuplicatePolicy int
+
+const (
+	// DuplicateReject rejects the claim with ErrClaimAlreadyIssued.
+	DuplicateReject DuplicatePolicy = iota
+	// DuplicateBumpVersion issues the claim with the lowest version higher
+	// than its own that is not yet issued.  As the version is part of the
+	// index, the new version doesn't replace the old one: revoke the old
+	// version to invalidate it.
+	DuplicateBumpVersion
+)
 
 // Config allows configuring the creation of an Issuer.
 type Config struct {
 	MaxLevelsClaimsTree     int
 	MaxLevelsRevocationTree int
 	MaxLevelsRootsTree      int
+	// DuplicatePolicy is the policy applied to claims already issued.
+	DuplicatePolicy DuplicatePolicy
+	// Validators check every claim before it's issued.  They are not
+	// persisted, see SetValidators.
+	Validators []Validator `json:"-"`
+	// Recoverer is the ethereum address that can initiate the recovery of
+	// the operational key, see InitRecovery.  If nil, the recovery is
+	// disabled.
+	Recoverer *common.Address
+	// RecoveryTimelock is the time that must pass between the initiation
+	// of a recovery and its completion, during which the holder of the
+	// operational key can cancel it.  It must not be zero if Recoverer is
+	// set.
+	RecoveryTimelock time.Duration
+	// PublishOnExpiration makes the ExpirationSweeper publish the
+	// identity state after revoking expired claims.
+	PublishOnExpiration bool
+	// SyncRetry is the retry policy of the request of the identity state
+	// to the Smart Contract in SyncIdenStatePublic.  Issuers created
+	// before it was introduced make a single attempt.
+	SyncRetry retry.Policy
+	// PublishPolicy tells when the AutoPublisher publishes the identity
+	// state.
+	PublishPolicy PublishPolicy
+	// Workers configures the background workers of the Issuer.
+	Workers WorkersConfig
+	// ConfirmationDepth is the number of blocks that must be mined after
+	// the block of a pending identity state before SyncIdenStatePublic
+	// takes it as the identity state on chain, so that it's unlikely to
+	// be reverted by a reorg.  If 0, the pending identity state is synced
+	// as soon as it's found in the Smart Contract.  It requires an
+	// IdenPubOnChainer that is an idenpubonchain.BlockNumberer.
+	ConfirmationDepth uint64
 }
 
 // IdenStateTreeRoots is the set of the three roots of each Identity Merkle Tree.
@@ -62,9 +126,10 @@ type IdenStateTreeRoots struct {
 	RootsRoot       *merkletree.Hash
 }
 
-// TODO: Add mutex!
-
-// Issuer is an identity that issues claims
+// Issuer is an identity that issues claims.  All the exported methods are
+// safe for concurrent use: methods that modify the state of the Issuer (the
+// merkle trees, the idenState list and the on chain sync state) take the write
+// lock, while the rest take the read lock.
 type Issuer struct {
 	rw              *sync.RWMutex
 	storage         db.Storage
@@ -74,10 +139,17 @@ type Issuer struct {
 	rootsTree       *merkletree.MerkleTree
 	// idenPubOnChain can be nil if the identity doesn't connect to the blockchain.
 	idenPubOnChain idenpubonchain.IdenPubOnChainer
-	keyStore       *keystore.KeyStore
-	kOpComp        *babyjub.PublicKeyComp
-	nonceGen       *UniqueNonceGen
-	idenStateList  *db.StorageList
+	// idenPubOffChain can be nil if the identity doesn't publish its off
+	// chain public data.
+	idenPubOffChain idenpuboffchainwriter.IdenPubOffChainWriter
+	keyStore        *keystore.KeyStore
+	// kOpComp holds the *babyjub.PublicKeyComp of the operational key.
+	// It's replaced by CompleteRecovery under the write lock, and read
+	// without the lock by SignBinary, which is called with and without
+	// it.
+	kOpComp       atomic.Value
+	nonceGen      *UniqueNonceGen
+	idenStateList *db.StorageList
 	// _idenStateOnChain     *merkletree.Hash
 	// idenStateDataOnChain is the last known identity state checked to be
 	// in the Smart Contract.
@@ -88,7 +160,21 @@ type Issuer struct {
 	_idenStatePending *merkletree.Hash
 	_ethTxSetState    *types.Transaction
 	_ethTxInitState   *types.Transaction
+	_stats            *Stats
 	cfg               Config
+	// hooks can be nil.
+	hooks *Hooks
+	// clock gives the time of the recoveries, the stats and the
+	// ExpirationSweeper.
+	clock clock.Clock
+	// templates are the ClaimTemplates by ID, see SetClaimTemplates.
+	templates map[string]*ClaimTemplate
+	// publishCoster can be nil, see SetPublishCoster.
+	publishCoster PublishCoster
+	// payloadStorage can be nil, see SetPayloadStorage.
+	payloadStorage db.Storage
+	// workers is created by Workers.
+	workers *worker.Manager
 }
 
 //
@@ -131,7 +217,7 @@ func (is *Issuer) loadIdenStatePending() error {
 	return nil
 }
 
-// func (is *Issuer) ethTxSetState() *types.Transaction { return is._ethTxSetState }
+func (is *Issuer) ethTxSetState() *types.Transaction { return is._ethTxSetState }
 
 func (is *Issuer) setEthTxSetState(tx db.Tx, v *types.Transaction) error {
 	is._ethTxSetState = v
@@ -143,7 +229,7 @@ func (is *Issuer) loadEthTxSetState() error {
 	return db.LoadJSON(is.storage, dbKeyEthTxSetState, is._ethTxSetState)
 }
 
-// func (is *Issuer) ethTxInitState() *types.Transaction { return is._ethTxInitState }
+func (is *Issuer) ethTxInitState() *types.Transaction { return is._ethTxInitState }
 
 func (is *Issuer) setEthTxInitState(tx db.Tx, v *types.Transaction) error {
 	is._ethTxInitState = v
@@ -177,8 +263,12 @@ func loadMTs(cfg *Config, storage db.Storage) (*merkletree.MerkleTree, *merkletr
 	return clt, ret, rot, nil
 }
 
-// New creates a new Issuer, creating a new genesis ID and initializes the storages.
-func New(cfg Config, kOpComp *babyjub.PublicKeyComp, extraGenesisClaims []merkletree.Entrier, storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpubonchain.IdenPubOnChainer) (*Issuer, error) {
+// New creates a new Issuer, creating a new genesis ID and initializes the
+// storages.  hooks can be nil.
+func New(cfg Config, kOpComp *babyjub.PublicKeyComp, extraGenesisClaims []merkletree.Entrier, storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpubonchain.IdenPubOnChainer, hooks *Hooks) (*Issuer, error) {
+	if cfg.Recoverer != nil && cfg.RecoveryTimelock <= 0 {
+		return nil, ErrRecoveryTimelockZero
+	}
 	clt, ret, rot, err := loadMTs(&cfg, storage)
 	if err != nil {
 		return nil, err
@@ -217,6 +307,7 @@ func New(cfg Config, kOpComp *babyjub.PublicKeyComp, extraGenesisClaims []merkle
 		return nil, err
 	}
 	tx.Put(dbKeyConfig, cfgJSON)
+	migrations.SetVersion(tx, SchemaVersion)
 
 	idenStateList := db.NewStorageList(dbPrefixIdenStateList)
 
@@ -229,12 +320,14 @@ func New(cfg Config, kOpComp *babyjub.PublicKeyComp, extraGenesisClaims []merkle
 		idenPubOnChain:  idenPubOnChain,
 		// idenStateWriter: idenStateWriter,
 		keyStore:      keyStore,
-		kOpComp:       kOpComp,
 		storage:       storage,
 		nonceGen:      nonceGen,
 		idenStateList: idenStateList,
 		cfg:           cfg,
+		hooks:         hooks,
+		clock:         clock.Real,
 	}
+	is.kOpComp.Store(kOpComp)
 
 	// Initalize the history of idenStates
 	idenState, idenStateTreeRoots := is.state()
@@ -250,6 +343,19 @@ func New(cfg Config, kOpComp *babyjub.PublicKeyComp, extraGenesisClaims []merkle
 	}
 	is.setIdenStatePending(tx, &merkletree.HashZero)
 
+	// Initialize the Stats with the genesis claims and roots.
+	stats, err := is.scanStats()
+	if err != nil {
+		return nil, err
+	}
+	if err := is.setStats(tx, stats); err != nil {
+		return nil, err
+	}
+	// The genesis claims are not changes to publish.
+	if err := is.setPublishMark(tx); err != nil {
+		return nil, err
+	}
+
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
@@ -259,7 +365,27 @@ func New(cfg Config, kOpComp *babyjub.PublicKeyComp, extraGenesisClaims []merkle
 }
 
 // Load creates an Issuer by loading a previously created Issuer (with New).
-func Load(storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpubonchain.IdenPubOnChainer) (*Issuer, error) {
+// The storage is migrated to SchemaVersion first, keeping a backup of the
+// previous version (see migrations.SelfBackup).  hooks can be nil.
+func Load(storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpubonchain.IdenPubOnChainer, hooks *Hooks) (*Issuer, error) {
+	if err := Migrate(storage, migrations.SelfBackup); err != nil {
+		return nil, err
+	}
+	is, err := load(storage, keyStore, idenPubOnChain, hooks)
+	if err != nil {
+		return nil, err
+	}
+	if err := is.SyncIdenStatePublic(); err != nil {
+		if err != ErrIdenPubOnChainNil {
+			return nil, err
+		}
+	}
+	return is, nil
+}
+
+// load creates an Issuer by loading a previously created Issuer from the
+// storage, without syncing the identity state with the Smart Contract.
+func load(storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpubonchain.IdenPubOnChainer, hooks *Hooks) (*Issuer, error) {
 	var cfg Config
 	cfgJSON, err := storage.Get(dbKeyConfig)
 	if err != nil {
@@ -269,7 +395,7 @@ func Load(storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpu
 		return nil, err
 	}
 
-	kOpCompBytes, err := storage.Get(dbKeyConfig)
+	kOpCompBytes, err := storage.Get(dbKeyKOp)
 	if err != nil {
 		return nil, err
 	}
@@ -299,32 +425,40 @@ func Load(storage db.Storage, keyStore *keystore.KeyStore, idenPubOnChain idenpu
 		rootsTree:       rot,
 		idenPubOnChain:  idenPubOnChain,
 		keyStore:        keyStore,
-		kOpComp:         &kOpComp,
 		storage:         storage,
 		nonceGen:        nonceGen,
 		idenStateList:   idenStateList,
 		cfg:             cfg,
+		hooks:           hooks,
+		clock:           clock.Real,
 	}
+	is.kOpComp.Store(&kOpComp)
 
-	if err := is.loadIdenStateDataOnChain(); err != nil {
+	if err := is.loadSyncState(); err != nil {
 		return nil, err
 	}
+	return &is, nil
+}
+
+// loadSyncState loads the persisted identity state sync values from the
+// storage.
+func (is *Issuer) loadSyncState() error {
+	if err := is.loadIdenStateDataOnChain(); err != nil {
+		return err
+	}
 	if err := is.loadIdenStatePending(); err != nil {
-		return nil, err
+		return err
 	}
 	if err := is.loadEthTxInitState(); err != nil {
-		return nil, err
+		return err
 	}
 	if err := is.loadEthTxSetState(); err != nil {
-		return nil, err
+		return err
 	}
-
-	if err := is.SyncIdenStatePublic(); err != nil {
-		if err != ErrIdenPubOnChainNil {
-			return nil, err
-		}
+	if err := is.loadStats(); err != nil {
+		return err
 	}
-	return &is, nil
+	return nil
 }
 
 // state returns the current Identity State and the three merkle tree roots.
@@ -360,26 +494,68 @@ func (is *Issuer) ID() *core.ID {
 // SyncIdenStatePublic updates the IdenStateOnChain and IdenStatePending from
 // the values in the Smart Contract.
 func (is *Issuer) SyncIdenStatePublic() error {
+	return is.SyncIdenStatePublicCtx(context.Background())
+}
+
+// SyncIdenStatePublicCtx is SyncIdenStatePublic with a context that cancels
+// the request to the Smart Contract.
+func (is *Issuer) SyncIdenStatePublicCtx(ctx context.Context) error {
 	if is.idenPubOnChain == nil {
 		return ErrIdenPubOnChainNil
 	}
+	var event *StateSyncedEvent
+	var reorgEvent *StateReorgedEvent
+	defer func() {
+		is.hooks.stateReorged(reorgEvent)
+		is.hooks.stateSynced(event)
+	}()
+	// The lock is released while waiting to retry, so that the retries
+	// don't block the Issuer.
+	return is.cfg.SyncRetry.Do(ctx, func() (err error) {
+		event, reorgEvent, err = is.syncIdenStatePublic(ctx)
+		return err
+	})
+}
+
+// syncIdenStatePublic does a single attempt of SyncIdenStatePublicCtx, and
+// returns the StateSyncedEvent if the sync state was updated, or the
+// StateReorgedEvent if the identity state on chain was reverted.  Only the
+// failures of the requests to the Smart Contract can be retried.
+func (is *Issuer) syncIdenStatePublic(ctx context.Context) (*StateSyncedEvent, *StateReorgedEvent, error) {
 	is.rw.Lock()
 	defer is.rw.Unlock()
-	idenStateData, err := is.idenPubOnChain.GetState(is.id)
+	idenStateData, err := idenpubonchain.GetStateCtx(ctx, is.idenPubOnChain, is.id)
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	if reverted := is.idenStateDataOnChain(); idenStateData.BlockN < reverted.BlockN &&
+		!idenStateData.IdenState.Equals(reverted.IdenState) {
+		// The identity state on chain (and the pending one, if any)
+		// was reverted by a reorg.
+		reorgEvent, err := is.revertIdenStateOnChain(idenStateData)
+		if err != nil {
+			return nil, nil, retry.Permanent(err)
+		}
+		return nil, reorgEvent, nil
 	}
 	if is.idenStatePending().Equals(&merkletree.HashZero) {
 		// If there's no IdenState pending to be set on chain, the
 		// obtained one must be the idenStateOnChain (Zero for genesis
 		// / empty in the smart contract).
 		if idenStateData.IdenState.Equals(is.idenStateOnChain()) {
-			return nil
+			return nil, nil, nil
+		}
+
+		// or, after a reorg, the reverted IdenState mined again.
+		if idenStateLast, reverted, err := is.idenStateReverted(); err != nil {
+			return nil, nil, retry.Permanent(err)
+		} else if reverted && idenStateData.IdenState.Equals(idenStateLast) {
+			return is.setIdenStateSynced(ctx, idenStateData)
 		}
 
-		return fmt.Errorf("Fatal error: Identity State in the Smart Contract (%v)"+
+		return nil, nil, retry.Permanent(fmt.Errorf("Fatal error: Identity State in the Smart Contract (%v)"+
 			" doesn't match the expected OnChain one (%v).",
-			idenStateData.IdenState, is.idenStateOnChain())
+			idenStateData.IdenState, is.idenStateOnChain()))
 	}
 	// If there's an IdenState pending to be set on chain, the
 	// obtained one can be:
@@ -387,47 +563,297 @@ func (is *Issuer) SyncIdenStatePublic() error {
 	// a. the idenStateOnchan (in this case, we still have an
 	// IdenState pending to be set on chain).
 	if idenStateData.IdenState.Equals(is.idenStateOnChain()) {
-		return nil
+		return nil, nil, nil
 	}
 
 	// b. the idenStatePending (in this case, we no longer have an
 	// IdenState pending and it becomes the idenStateOnChain, so we update
-	// the sync state).
+	// the sync state once it's confirmed).
 	if idenStateData.IdenState.Equals(is.idenStatePending()) {
-		tx, err := is.storage.NewTx()
-		if err != nil {
-			return err
-		}
-		is.setIdenStatePending(tx, &merkletree.HashZero)
-		if err := is.setIdenStateDataOnChain(tx, idenStateData); err != nil {
-			return err
-		}
-		if err := tx.Commit(); err != nil {
-			return err
-		}
-		return nil
+		return is.setIdenStateSynced(ctx, idenStateData)
 	}
 
 	// c. Neither the idenStatePending nor the idenStateOnchain
 	// (unexpected result).
-	return fmt.Errorf("Fatal error: Identity State in the Smart Contract (%v)"+
+	return nil, nil, retry.Permanent(fmt.Errorf("Fatal error: Identity State in the Smart Contract (%v)"+
 		" doesn't match the Pending one (%v) nor the OnChain one (%v).",
-		idenStateData.IdenState, is.idenStatePending(), is.idenStateOnChain())
+		idenStateData.IdenState, is.idenStatePending(), is.idenStateOnChain()))
+}
+
+// setIdenStateSynced sets idenStateData, found in the Smart Contract, as the
+// identity state on chain if it has Config.ConfirmationDepth confirmations,
+// and clears the pending one.  The caller must hold the write lock.
+func (is *Issuer) setIdenStateSynced(ctx context.Context, idenStateData *proof.IdenStateData) (*StateSyncedEvent, *StateReorgedEvent, error) {
+	if is.cfg.ConfirmationDepth > 0 {
+		blockN, err := idenpubonchain.BlockNumberCtx(ctx, is.idenPubOnChain)
+		if err == idenpubonchain.ErrBlockNumberUnsupported {
+			return nil, nil, retry.Permanent(err)
+		} else if err != nil {
+			return nil, nil, err
+		}
+		if blockN < idenStateData.BlockN+is.cfg.ConfirmationDepth {
+			return nil, nil, nil
+		}
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, nil, retry.Permanent(err)
+	}
+	defer tx.Close()
+	is.setIdenStatePending(tx, &merkletree.HashZero)
+	if err := is.setIdenStateDataOnChain(tx, idenStateData); err != nil {
+		return nil, nil, retry.Permanent(err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, retry.Permanent(err)
+	}
+	return &StateSyncedEvent{IdenStateData: *idenStateData}, nil, nil
+}
+
+// ClaimByHIndex returns the claim entry found in the current Claims Merkle
+// Tree at the position hIndex.
+func (is *Issuer) ClaimByHIndex(hIndex *merkletree.Hash) (*merkletree.Entry, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	data, err := is.claimsTree.GetDataByIndex(hIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &merkletree.Entry{Data: *data}, nil
+}
+
+// entryClaim is an Entrier of an entry.
+type entryClaim merkletree.Entry
+
+func (e *entryClaim) Entry() *merkletree.Entry { return (*merkletree.Entry)(e) }
+
+// resolveDuplicate applies the DuplicatePolicy to claim.  It returns claim if
+// it's not yet issued, or the claim with the bumped version with
+// DuplicateBumpVersion.  The caller must hold the write lock.
+func (is *Issuer) resolveDuplicate(claim merkletree.Entrier) (merkletree.Entrier, error) {
+	entry := claim.Entry()
+	// The hIndex can only be calculated for entries in the field.
+	if !merkletree.CheckEntryInField(*entry) {
+		return nil, ErrClaimNotInField
+	}
+	bumped := false
+	for {
+		_, err := is.claimsTree.GetDataByIndex(entry.HIndex())
+		if err == merkletree.ErrEntryIndexNotFound {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		claimType, version := claims.GetClaimTypeVersion(entry)
+		if is.cfg.DuplicatePolicy != DuplicateBumpVersion || version == math.MaxUint32 {
+			return nil, ErrClaimAlreadyIssued
+		}
+		// A new Entry, as the hIndex is cached.
+		entry = &merkletree.Entry{Data: entry.Data}
+		claims.SetClaimTypeVersion(entry, claimType, version+1)
+		bumped = true
+	}
+	if !bumped {
+		return claim, nil
+	}
+	if c, err := claims.NewClaimFromEntry(entry); err == nil {
+		return c, nil
+	}
+	return (*entryClaim)(entry), nil
 }
 
-// IssueClaim adds a new claim to the Claims Merkle Tree of the Issuer.  The
-// Identity State is not updated.
+// IssueClaim adds a new claim to the Claims Merkle Tree of the Issuer, after
+// checking it with the configured Validators.  If the claim is already issued,
+// the configured DuplicatePolicy is applied.  The issued claim is passed to
+// the OnClaimIssued hook.  The Identity State is not updated.
 func (is *Issuer) IssueClaim(claim merkletree.Entrier) error {
+	return is.issueClaimExpiring(claim, time.Time{})
+}
+
+// issueClaimExpiring is IssueClaim with the expiration of the claim, if it's
+// not zero.
+func (is *Issuer) issueClaimExpiring(claim merkletree.Entrier, expiration time.Time) error {
+	if is.idenPubOnChain == nil {
+		return ErrIdenPubOnChainNil
+	}
+	var event *ClaimIssuedEvent
+	defer func() { is.hooks.claimIssued(event) }()
 	is.rw.Lock()
 	defer is.rw.Unlock()
+	issued, err := is.issueClaim(claim, expiration)
+	if issued != nil {
+		event = &ClaimIssuedEvent{Claim: issued.Entry()}
+	}
+	return err
+}
+
+// issueClaim issues claim, applying the DuplicatePolicy and the Validators,
+// and records its expiration if it's not zero.  It returns the issued claim
+// once it's in the claims tree, even if the stats can't be updated.  The
+// caller must hold the write lock.
+func (is *Issuer) issueClaim(claim merkletree.Entrier, expiration time.Time) (merkletree.Entrier, error) {
+	claim, err := is.resolveDuplicate(claim)
+	if err != nil {
+		return nil, err
+	}
+	if err := is.validate(claim); err != nil {
+		return nil, err
+	}
+	if err := is.putClaimExpiration(claim.Entry().HIndex(), expiration); err != nil {
+		return nil, err
+	}
+	if err := is.claimsTree.AddClaim(claim); err != nil {
+		return nil, err
+	}
+	return claim, is.commitStats(func(s *Stats) { s.addClaim(claim.Entry()) })
+}
+
+// IssueClaimWithNonce issues the claim returned by newClaim, which is called
+// with a new unique revocation nonce for the claim.  It returns the issued
+// claim, which has a bumped version if the configured DuplicatePolicy is
+// DuplicateBumpVersion and the claim was already issued.  Like IssueClaim, it
+// returns the issued claim once it's in the claims tree, even if the stats
+// can't be updated.
+func (is *Issuer) IssueClaimWithNonce(newClaim func(revocationNonce uint32) (merkletree.Entrier, error)) (merkletree.Entrier, error) {
+	return is.issueClaimWithNonceExpiring(newClaim, time.Time{})
+}
+
+// issueClaimWithNonceExpiring is IssueClaimWithNonce with the expiration of
+// the claim, if it's not zero.
+func (is *Issuer) issueClaimWithNonceExpiring(newClaim func(revocationNonce uint32) (merkletree.Entrier, error),
+	expiration time.Time) (merkletree.Entrier, error) {
 	if is.idenPubOnChain == nil {
-		return ErrIdenPubOnChainNil
+		return nil, ErrIdenPubOnChainNil
 	}
-	err := is.claimsTree.AddClaim(claim)
+	var event *ClaimIssuedEvent
+	defer func() { is.hooks.claimIssued(event) }()
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	nonces, err := is.reserveNonces(1)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	claim, err := is.issueClaimWithNonce(nonces[0], newClaim, expiration)
+	if err != nil {
+		return nil, err
+	}
+	event = &ClaimIssuedEvent{Claim: claim.Entry()}
+	return claim, is.commitStats(func(s *Stats) { s.addClaim(claim.Entry()) })
+}
+
+// reserveNonces commits n new unique revocation nonces before any claim
+// using them is added to the claims tree, which commits each claim right
+// away: if the issuance fails afterwards, the nonces are lost instead of
+// being reused by the next claims, which would be revoked together.  The
+// caller must hold the write lock.
+func (is *Issuer) reserveNonces(n int) ([]uint32, error) {
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	nonces := make([]uint32, n)
+	for i := range nonces {
+		if nonces[i], err = is.nonceGen.Next(tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return nonces, nil
+}
+
+// issueClaimWithNonce issues the claim returned by newClaim with the nonce
+// reserved by reserveNonces, and records its expiration if it's not zero,
+// without updating the stats.  The caller must hold the write lock.
+func (is *Issuer) issueClaimWithNonce(nonce uint32, newClaim func(revocationNonce uint32) (merkletree.Entrier, error),
+	expiration time.Time) (merkletree.Entrier, error) {
+	claim, err := newClaim(nonce)
+	if err != nil {
+		return nil, err
+	}
+	if claim, err = is.resolveDuplicate(claim); err != nil {
+		return nil, err
+	}
+	if err := is.validate(claim); err != nil {
+		return nil, err
+	}
+	if err := is.putClaimExpiration(claim.Entry().HIndex(), expiration); err != nil {
+		return nil, err
+	}
+	if err := is.claimsTree.AddClaim(claim); err != nil {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// IssueClaims issues a batch of claims like IssueClaimWithNonce, taking the
+// lock and committing the nonces and stats once for the whole batch.  The
+// claims that fail don't prevent the rest from being issued: it returns the
+// issued claim and the error of each of newClaims, by position.  The nonces
+// are committed before any claim is added to the claims tree, and if that
+// fails the error is returned and none of the claims is issued.  Otherwise
+// the error is only returned if the stats couldn't be updated, along with the
+// claims issued, which are in the claims tree.
+func (is *Issuer) IssueClaims(newClaims []func(revocationNonce uint32) (merkletree.Entrier, error)) ([]merkletree.Entrier, []error, error) {
+	return is.issueClaimsExpiring(newClaims, nil)
+}
+
+// issueClaimsExpiring is IssueClaims with the expirations of the claims, by
+// position, if expirations is not nil.
+func (is *Issuer) issueClaimsExpiring(newClaims []func(revocationNonce uint32) (merkletree.Entrier, error),
+	expirations []time.Time) ([]merkletree.Entrier, []error, error) {
+	if is.idenPubOnChain == nil {
+		return nil, nil, ErrIdenPubOnChainNil
+	}
+	var events []*ClaimIssuedEvent
+	defer func() {
+		for _, event := range events {
+			is.hooks.claimIssued(event)
+		}
+	}()
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	nonces, err := is.reserveNonces(len(newClaims))
+	if err != nil {
+		return nil, nil, err
+	}
+	issued := make([]merkletree.Entrier, len(newClaims))
+	errs := make([]error, len(newClaims))
+	for i, newClaim := range newClaims {
+		var expiration time.Time
+		if expirations != nil {
+			expiration = expirations[i]
+		}
+		issued[i], errs[i] = is.issueClaimWithNonce(nonces[i], newClaim, expiration)
+	}
+	for _, claim := range issued {
+		if claim != nil {
+			events = append(events, &ClaimIssuedEvent{Claim: claim.Entry()})
+		}
+	}
+	err = is.commitStats(func(s *Stats) {
+		for _, claim := range issued {
+			if claim != nil {
+				s.addClaim(claim.Entry())
+			}
+		}
+	})
+	return issued, errs, err
+}
+
+// IssueClaimDelegate issues a ClaimDelegate that authorizes the identity id
+// to act on behalf of the Issuer for scope.  The delegation can be revoked
+// with RevokeClaim.
+func (is *Issuer) IssueClaimDelegate(id *core.ID, scope claims.DelegateScope) (*claims.ClaimDelegate, error) {
+	claim, err := is.IssueClaimWithNonce(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return claims.NewClaimDelegate(id, scope, revocationNonce), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claims.NewClaimDelegateFromEntry(claim.Entry()), nil
 }
 
 // getIdenStateByIdx gets identity state and identity state tree roots of the
@@ -438,9 +864,11 @@ func (is *Issuer) getIdenStateByIdx(tx db.Tx, idx uint32) (*merkletree.Hash, *Id
 	if err != nil {
 		return nil, nil, err
 	}
-	var idenState merkletree.Hash
-	copy(idenState[:], idenStateBytes)
-	return &idenState, &idenStateTreeRoots, nil
+	idenState, err := merkletree.NewHashFromBytes(idenStateBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	return idenState, &idenStateTreeRoots, nil
 }
 
 // getIdenStateTreeRoots gets the identity state tree roots of the Issuer from
@@ -453,78 +881,262 @@ func (is *Issuer) getIdenStateTreeRoots(tx db.Tx, idenState *merkletree.Hash) (*
 	return &idenStateTreeRoots, nil
 }
 
+// PublishStateStatus is the outcome of a PublishState call.
+type PublishStateStatus int
+
+const (
+	// PublishStateSubmitted means that a transaction to publish a new
+	// identity state has been sent.
+	PublishStateSubmitted PublishStateStatus = iota
+	// PublishStateNoChanges means that the identity state hasn't changed
+	// since the last published one, so nothing was sent.
+	PublishStateNoChanges
+	// PublishStateAlreadyPending means that a previously published
+	// identity state is still pending to be confirmed on chain, so nothing
+	// was sent.
+	PublishStateAlreadyPending
+)
+
+func (s PublishStateStatus) String() string {
+	switch s {
+	case PublishStateSubmitted:
+		return "submitted"
+	case PublishStateNoChanges:
+		return "no-changes"
+	case PublishStateAlreadyPending:
+		return "already-pending"
+	default:
+		return fmt.Sprintf("PublishStateStatus(%d)", int(s))
+	}
+}
+
+// PublishStateResult is the result of a PublishState call.
+type PublishStateResult struct {
+	Status PublishStateStatus
+	// IdenState is the submitted identity state when Status is
+	// PublishStateSubmitted, the pending identity state when Status is
+	// PublishStateAlreadyPending, and the last published identity state
+	// when Status is PublishStateNoChanges.
+	IdenState *merkletree.Hash
+	// EthTx is the transaction of the submitted or pending identity
+	// state.  It's nil when Status is PublishStateNoChanges.
+	EthTx *types.Transaction
+	// IdenPubOffChainErr is the error of the publication of the off chain
+	// public data of the submitted identity state, if any.  The identity
+	// state is submitted on chain regardless.
+	IdenPubOffChainErr error
+}
+
+// SetClock sets the Clock used by the Issuer instead of the system time, so
+// that the time dependent logic can be tested with a clock.Fake.  It must be
+// called before starting an ExpirationSweeper.
+func (is *Issuer) SetClock(clk clock.Clock) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	is.clock = clk
+}
+
+// SetIdenPubOffChainWriter sets the IdenPubOffChainWriter where the off chain
+// public data of each new identity state is published by PublishState.  The
+// writer is created by newWriter from the Issuer merkle trees, which it must
+// only read.
+func (is *Issuer) SetIdenPubOffChainWriter(newWriter func(claimsTree, rootsTree,
+	revocationsTree *merkletree.MerkleTree) (idenpuboffchainwriter.IdenPubOffChainWriter, error)) error {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	w, err := newWriter(is.claimsTree, is.rootsTree, is.revocationsTree)
+	if err != nil {
+		return err
+	}
+	is.idenPubOffChain = w
+	return nil
+}
+
+// publishOffChain publishes the off chain public data of the identity state
+// of ev, if an IdenPubOffChainWriter is set.
+func (is *Issuer) publishOffChain(ev *StatePublishedEvent) error {
+	is.rw.RLock()
+	w := is.idenPubOffChain
+	is.rw.RUnlock()
+	if w == nil {
+		return nil
+	}
+	roots := ev.IdenStateTreeRoots
+	return w.Publish(ev.IdenState, roots.ClaimsRoot, roots.RevocationsRoot, roots.RootsRoot)
+}
+
+// pendingState returns the identity state pending to be confirmed on chain
+// and the transaction that published it.
+func (is *Issuer) pendingState() (*merkletree.Hash, *types.Transaction, bool) {
+	if is.idenStatePending().Equals(&merkletree.HashZero) {
+		return nil, nil, false
+	}
+	if is.idenStateOnChain().Equals(&merkletree.HashZero) {
+		return is.idenStatePending(), is.ethTxInitState(), true
+	}
+	return is.idenStatePending(), is.ethTxSetState(), true
+}
+
+// PendingState returns the identity state that has been published but is not
+// yet confirmed on chain, together with the transaction that published it.
+// The last return value is false if there's no pending identity state.
+func (is *Issuer) PendingState() (*merkletree.Hash, *types.Transaction, bool) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	return is.pendingState()
+}
+
 // PublishState calculates the current Issuer identity state, and if it's
-// different than the last one, it publishes in in the blockchain.
-func (is *Issuer) PublishState() error {
+// different than the last one, it publishes in in the blockchain.  It's
+// idempotent: if there's an identity state pending to be confirmed on chain
+// or the identity state hasn't changed, nothing is published and the
+// returned result tells why.  When a new identity state is submitted, its off
+// chain public data is published with the IdenPubOffChainWriter, if set.
+func (is *Issuer) PublishState() (*PublishStateResult, error) {
+	return is.PublishStateCtx(context.Background())
+}
+
+// PublishStateCtx is PublishState with a context that cancels the requests to
+// the Smart Contract.  The context is checked once the write lock is taken,
+// so that a request that waited too long for it fails without side effects.
+func (is *Issuer) PublishStateCtx(ctx context.Context) (res *PublishStateResult, err error) {
+	var event *StatePublishedEvent
+	defer func() {
+		if event != nil {
+			res.IdenPubOffChainErr = is.publishOffChain(event)
+		}
+		is.hooks.statePublished(event)
+	}()
 	is.rw.Lock()
 	defer is.rw.Unlock()
 	if is.idenPubOnChain == nil {
-		return ErrIdenPubOnChainNil
+		return nil, ErrIdenPubOnChainNil
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-	if !is.idenStatePending().Equals(&merkletree.HashZero) {
-		return ErrIdenStatePendingNotNil
+	if idenStatePending, ethTx, ok := is.pendingState(); ok {
+		return &PublishStateResult{Status: PublishStateAlreadyPending, IdenState: idenStatePending, EthTx: ethTx}, nil
 	}
 	idenState, idenStateTreeRoots := is.state()
 
 	tx, err := is.storage.NewTx()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	defer tx.Close()
 
 	idenStateListLen, err := is.idenStateList.Length(tx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	idenStateLast, _, err := is.getIdenStateByIdx(tx, idenStateListLen-1)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	initState := is.idenStateOnChain().Equals(&merkletree.HashZero)
+	_, reverted, err := is.idenStateReverted()
+	if err != nil {
+		return nil, err
+	}
+	// The transition starts from the last identity state, which is the
+	// one on chain (or the genesis one) unless a reorg reverted it.
+	idenStateFrom := idenStateLast
+	if reverted {
+		if initState {
+			if idenStateFrom, _, err = is.getIdenStateByIdx(tx, 0); err != nil {
+				return nil, err
+			}
+		} else {
+			idenStateFrom = is.idenStateOnChain()
+		}
 	}
 
-	if idenState.Equals(idenStateLast) {
+	if idenState.Equals(idenStateLast) && !reverted {
 		// IdenState hasn't changed, there's no need to do anything!
-		return nil
+		return &PublishStateResult{Status: PublishStateNoChanges, IdenState: idenStateLast}, nil
 	}
 
-	if err := is.idenStateList.Append(tx, idenState[:], &idenStateTreeRoots); err != nil {
-		return err
+	if !idenState.Equals(idenStateLast) {
+		if err := is.idenStateList.Append(tx, idenState[:], &idenStateTreeRoots); err != nil {
+			return nil, err
+		}
 	}
 
 	// Sign [minor] identity transition from last state to new (current) state.
-	sig, err := is.SignBinary(SigPrefixSetState, append(idenStateLast[:], idenState[:]...))
+	sig, err := is.SignBinary(SigPrefixSetState, append(idenStateFrom[:], idenState[:]...))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	if is.idenStateOnChain().Equals(&merkletree.HashZero) {
+	// Simulate the transaction before sending it when supported, so that
+	// a call that would revert fails fast instead of burning gas.
+	if estimator, ok := is.idenPubOnChain.(idenpubonchain.StateGasEstimatorCtx); ok {
+		if initState {
+			_, err = estimator.EstimateInitStateCtx(ctx, is.id, idenStateFrom, idenState, nil, nil, sig)
+		} else {
+			_, err = estimator.EstimateSetStateCtx(ctx, is.id, idenState, nil, nil, sig)
+		}
+		if err != nil {
+			return nil, err
+		}
+	} else if estimator, ok := is.idenPubOnChain.(idenpubonchain.StateGasEstimator); ok {
+		if initState {
+			_, err = estimator.EstimateInitState(is.id, idenStateFrom, idenState, nil, nil, sig)
+		} else {
+			_, err = estimator.EstimateSetState(is.id, idenState, nil, nil, sig)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var ethTx *types.Transaction
+	if initState {
 		// Identity State not present in the Smart Contract. First time
 		// publishing it.
-		ethTx, err := is.idenPubOnChain.InitState(is.id, idenStateLast, idenState, nil, nil, sig)
+		ethTx, err = idenpubonchain.InitStateCtx(ctx, is.idenPubOnChain, is.id, idenStateFrom, idenState, nil, nil, sig)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if err := is.setEthTxInitState(tx, ethTx); err != nil {
-			return err
+			return nil, err
 		}
 	} else {
 		// Identity State already present in the Smart Contract.
 		// Update it.
-		ethTx, err := is.idenPubOnChain.SetState(is.id, idenState, nil, nil, sig)
+		ethTx, err = idenpubonchain.SetStateCtx(ctx, is.idenPubOnChain, is.id, idenState, nil, nil, sig)
 		if err != nil {
-			return err
+			return nil, err
 		}
 
 		if err := is.setEthTxSetState(tx, ethTx); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	is.setIdenStatePending(tx, idenState)
+	if err := is.updateStats(tx, func(s *Stats) {
+		// An identity state published again after a reorg is
+		// already counted.
+		if !idenState.Equals(idenStateLast) {
+			s.PublishedStates++
+		}
+		s.LastPublishTs = is.clock.Now().Unix()
+	}); err != nil {
+		return nil, err
+	}
+	if err := is.setPublishMark(tx); err != nil {
+		return nil, err
+	}
 
 	if err := tx.Commit(); err != nil {
-		return err
+		return nil, err
 	}
-	return nil
+	event = &StatePublishedEvent{IdenState: idenState, IdenStateTreeRoots: idenStateTreeRoots, EthTx: ethTx}
+	return &PublishStateResult{Status: PublishStateSubmitted, IdenState: idenState, EthTx: ethTx}, nil
 }
 
 // RevokeClaim revokes an already issued claim.
@@ -532,18 +1144,30 @@ func (is *Issuer) RevokeClaim(claim merkletree.Entrier) error {
 	if is.idenPubOnChain == nil {
 		return ErrIdenPubOnChainNil
 	}
+	var event *ClaimRevokedEvent
+	defer func() { is.hooks.claimRevoked(event) }()
 	is.rw.Lock()
 	defer is.rw.Unlock()
-	data, err := is.claimsTree.GetDataByIndex(claim.Entry().HIndex())
+	var err error
+	event, err = is.revokeClaim(claim.Entry().HIndex())
+	return err
+}
+
+// revokeClaim adds the revocation nonce of the issued claim at hIndex to the
+// revocations tree.  The caller must hold the write lock.
+func (is *Issuer) revokeClaim(hIndex *merkletree.Hash) (*ClaimRevokedEvent, error) {
+	data, err := is.claimsTree.GetDataByIndex(hIndex)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	nonce := claims.GetRevocationNonce(&merkletree.Entry{Data: *data})
+	entry := &merkletree.Entry{Data: *data}
+	nonce := claims.GetRevocationNonce(entry)
 
-	if err := claims.AddLeafRevocationsTree(is.revocationsTree, nonce, 0xffffffff); err != nil {
-		return err
+	if err := claims.AddLeafRevocationsTree(is.revocationsTree, nonce, claims.RevocationVersionAll); err != nil {
+		return nil, err
 	}
-	return nil
+	event := &ClaimRevokedEvent{Claim: entry, RevocationNonce: nonce}
+	return event, is.commitStats(func(s *Stats) { s.Revocations++ })
 }
 
 // UpdateClaim allows updating the value of an already issued claim.
@@ -561,7 +1185,12 @@ func (is *Issuer) Sign(string) (string, error) {
 
 // Sign signs a binary message by the kOp of the issuer.
 func (is *Issuer) SignBinary(prefix, msg []byte) (*babyjub.SignatureComp, error) {
-	return is.keyStore.SignRaw(is.kOpComp, append(prefix, msg...))
+	return is.keyStore.SignRaw(is.kOp(), append(prefix, msg...))
+}
+
+// kOp returns the compressed public operational key.
+func (is *Issuer) kOp() *babyjub.PublicKeyComp {
+	return is.kOpComp.Load().(*babyjub.PublicKeyComp)
 }
 
 func generateExistenceMTProof(mt *merkletree.MerkleTree, hi, root *merkletree.Hash) (*merkletree.Proof, error) {
@@ -575,36 +1204,368 @@ func generateExistenceMTProof(mt *merkletree.MerkleTree, hi, root *merkletree.Ha
 	return mtp, nil
 }
 
+// idenStateTrees is a read-only snapshot of the three Identity Merkle Trees at
+// a specific identity state.
+type idenStateTrees struct {
+	claimsTree      *merkletree.MerkleTree
+	revocationsTree *merkletree.MerkleTree
+	rootsTree       *merkletree.MerkleTree
+}
+
+// snapshotTrees returns a read-only snapshot of the Issuer merkle trees at the
+// tree roots stored for the identity state idenState.  The snapshot is not
+// affected by the claims issued or revoked afterwards.
+func (is *Issuer) snapshotTrees(tx db.Tx, idenState *merkletree.Hash) (*idenStateTrees, error) {
+	idenStateTreeRoots, err := is.getIdenStateTreeRoots(tx, idenState)
+	if err != nil {
+		return nil, err
+	}
+	if !core.IdenState(idenStateTreeRoots.ClaimsRoot, idenStateTreeRoots.RevocationsRoot,
+		idenStateTreeRoots.RootsRoot).Equals(idenState) {
+		return nil, fmt.Errorf("stored tree roots don't match the identity state %v", idenState)
+	}
+	var trees idenStateTrees
+	if trees.claimsTree, err = is.claimsTree.Snapshot(idenStateTreeRoots.ClaimsRoot); err != nil {
+		return nil, err
+	}
+	if trees.revocationsTree, err = is.revocationsTree.Snapshot(idenStateTreeRoots.RevocationsRoot); err != nil {
+		return nil, err
+	}
+	if trees.rootsTree, err = is.rootsTree.Snapshot(idenStateTreeRoots.RootsRoot); err != nil {
+		return nil, err
+	}
+	return &trees, nil
+}
+
 // GenCredentialExistence generates an existence credential (claim + proof of
 // existence) of an issued claim.  The result contains all data necessary to
 // validate the credential against the Identity State found in the blockchain.
-// For now, there are no genesis credentials.
+// The credential is always generated from a snapshot of the trees at the
+// last identity state found on chain, so it stays consistent with that state
+// even if new claims are issued or a new state is being published.  The
+// identity state is recorded as referenced by a credential so that it's not
+// removed by CompactStateHistory.
+// For credentials of the genesis claims that don't depend on the identity
+// state on chain, see GenCredentialExistenceGenesis.
 func (is *Issuer) GenCredentialExistence(claim merkletree.Entrier) (*proof.CredentialExistence, error) {
+	return is.GenCredentialExistenceCtx(context.Background(), claim)
+}
+
+// GenCredentialExistenceCtx is GenCredentialExistence with a context that is
+// checked every time a lock is taken, so that a request that waited too long
+// for it fails early.
+func (is *Issuer) GenCredentialExistenceCtx(ctx context.Context, claim merkletree.Entrier) (*proof.CredentialExistence, error) {
+	is.rw.RLock()
+	credExist, referenced, err := is.genCredentialExistenceCtx(ctx, claim)
+	is.rw.RUnlock()
+	if err != nil || referenced {
+		return credExist, err
+	}
+
+	// First credential generated for this identity state: take the write
+	// lock to record the reference.
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	credExist, _, err = is.genCredentialExistenceCtx(ctx, claim)
+	if err != nil {
+		return nil, err
+	}
 	tx, err := is.storage.NewTx()
 	if err != nil {
 		return nil, err
 	}
-	is.rw.RLock()
-	defer is.rw.RUnlock()
+	defer tx.Close()
+	tx.Put(append(dbPrefixIdenStateRefs, credExist.IdenStateData.IdenState[:]...), []byte{1})
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return credExist, nil
+}
+
+// genCredentialExistenceCtx generates an existence credential of an issued
+// claim and returns whether the identity state of the credential was already
+// referenced by a previous credential.  It fails if ctx is done.
+func (is *Issuer) genCredentialExistenceCtx(ctx context.Context, claim merkletree.Entrier) (*proof.CredentialExistence, bool, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, false, err
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Close()
 	idenStateData := is.idenStateDataOnChain()
 	if idenStateData.IdenState.Equals(&merkletree.HashZero) {
-		return nil, ErrIdenStateOnChainZero
+		return nil, false, ErrIdenStateOnChainZero
+	}
+	trees, err := is.snapshotTrees(tx, idenStateData.IdenState)
+	if err != nil {
+		return nil, false, err
+	}
+	mtpExist, err := generateExistenceMTProof(trees.claimsTree, claim.Entry().HIndex(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	referenced, err := is.idenStateReferenced(tx, idenStateData.IdenState)
+	if err != nil {
+		return nil, false, err
+	}
+	idPubUrl, err := is.publicDataURL()
+	if err != nil {
+		return nil, false, err
+	}
+	return &proof.CredentialExistence{
+		Id:              is.id,
+		IdenStateData:   *idenStateData,
+		MtpClaim:        mtpExist,
+		Claim:           claim.Entry(),
+		RevocationsRoot: trees.revocationsTree.RootKey(),
+		RootsRoot:       trees.rootsTree.RootKey(),
+		IdPubUrl:        idPubUrl,
+	}, referenced, nil
+}
+
+// GenCredentialExistenceGenesis generates an existence credential of a claim
+// added at the identity genesis (the kOp claim or the extraGenesisClaims of
+// New).  The credential is anchored at the genesis identity state, which is
+// encoded in the identity ID, so it's valid without any identity state
+// published on chain (see proof.CredentialExistence.IsGenesis).  It fails
+// with ErrClaimNotFoundGenesis if the claim was not added at genesis.
+func (is *Issuer) GenCredentialExistenceGenesis(claim merkletree.Entrier) (*proof.CredentialExistence, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	// Index 0 is the genesis state, which is never removed from the list.
+	genesisState, _, err := is.getIdenStateByIdx(tx, 0)
+	if err != nil {
+		return nil, err
+	}
+	trees, err := is.snapshotTrees(tx, genesisState)
+	if err != nil {
+		return nil, err
 	}
-	idenStateTreeRoots, err := is.getIdenStateTreeRoots(tx, idenStateData.IdenState)
+	mtpExist, err := trees.claimsTree.GenerateProof(claim.Entry().HIndex(), nil)
 	if err != nil {
 		return nil, err
 	}
-	mtpExist, err := generateExistenceMTProof(is.claimsTree, claim.Entry().HIndex(), idenStateTreeRoots.ClaimsRoot)
+	if !mtpExist.Existence {
+		return nil, ErrClaimNotFoundGenesis
+	}
+	idPubUrl, err := is.publicDataURL()
 	if err != nil {
 		return nil, err
 	}
 	return &proof.CredentialExistence{
 		Id:              is.id,
-		IdenStateData:   *idenStateData,
+		IdenStateData:   proof.IdenStateData{IdenState: genesisState},
 		MtpClaim:        mtpExist,
 		Claim:           claim.Entry(),
-		RevocationsRoot: idenStateTreeRoots.RevocationsRoot,
-		RootsRoot:       idenStateTreeRoots.RootsRoot,
-		IdPubUrl:        "http://TODO",
+		RevocationsRoot: trees.revocationsTree.RootKey(),
+		RootsRoot:       trees.rootsTree.RootKey(),
+		IdPubUrl:        idPubUrl,
 	}, nil
 }
+
+// GenProofClaim generates a ProofClaim of an issued claim at the last identity
+// state found on chain, like GenCredentialExistence, with all the components
+// available: the proof of non-revocation, the proof of the claims root in the
+// roots tree (only in the genesis state, as the following claims roots are
+// not added to the roots tree), and the signature of the identity state by
+// the kOp.  It fails with ErrClaimRevoked if the claim is revoked in that
+// state.
+func (is *Issuer) GenProofClaim(claim merkletree.Entrier) (*proof.ProofClaim, error) {
+	credExist, err := is.GenCredentialExistence(claim)
+	if err != nil {
+		return nil, err
+	}
+	proofClaim, err := proof.NewProofClaimFromCredentialExistence(credExist)
+	if err != nil {
+		return nil, err
+	}
+
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	trees, err := is.snapshotTrees(tx, credExist.IdenStateData.IdenState)
+	if err != nil {
+		return nil, err
+	}
+	nonce := claims.GetRevocationNonce(credExist.Claim)
+	revLeaf := claims.NewLeafRevocationsTree(nonce, claims.RevocationVersionAll).Entry()
+	if proofClaim.MtpNotRevoked, err = trees.revocationsTree.GenerateProof(revLeaf.HIndex(), nil); err != nil {
+		return nil, err
+	}
+	if proofClaim.MtpNotRevoked.Existence {
+		return nil, ErrClaimRevoked
+	}
+	rootsLeaf := claims.NewLeafRootsTree(*proofClaim.ClaimsRoot).Entry()
+	mtpClaimsRoot, err := trees.rootsTree.GenerateProof(rootsLeaf.HIndex(), nil)
+	if err != nil {
+		return nil, err
+	}
+	if mtpClaimsRoot.Existence {
+		proofClaim.MtpClaimsRoot = mtpClaimsRoot
+	}
+	msg, err := proofClaim.SigMsg()
+	if err != nil {
+		return nil, err
+	}
+	if proofClaim.Signature, err = is.SignBinary(proof.SigPrefixProofClaim, msg); err != nil {
+		return nil, err
+	}
+	return proofClaim, nil
+}
+
+// entrier is a merkletree.Entrier of a raw claim entry.
+type entrier struct {
+	entry *merkletree.Entry
+}
+
+func (e entrier) Entry() *merkletree.Entry { return e.entry }
+
+// RefreshCredentialExistence generates an existence credential of the claim
+// at hIndex in the last identity state found on chain, so that the holder of
+// a credential of an older state can update it.  It fails with
+// ErrClaimNotFound if the claim has not been issued,
+// ErrClaimNotFoundStateOnChain if it has been issued but not yet published,
+// and ErrClaimRevoked if it's revoked in the last identity state on chain.
+func (is *Issuer) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	return is.RefreshCredentialExistenceCtx(context.Background(), hIndex)
+}
+
+// RefreshCredentialExistenceCtx is RefreshCredentialExistence with a context
+// like the one of GenCredentialExistenceCtx.
+func (is *Issuer) RefreshCredentialExistenceCtx(ctx context.Context, hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	is.rw.RLock()
+	claim, err := is.claimOnChain(hIndex)
+	is.rw.RUnlock()
+	if err != nil {
+		return nil, err
+	}
+	return is.GenCredentialExistenceCtx(ctx, entrier{claim})
+}
+
+// claimOnChain returns the claim at hIndex in the last identity state on
+// chain, checking that it's not revoked.  See RefreshCredentialExistence.
+func (is *Issuer) claimOnChain(hIndex *merkletree.Hash) (*merkletree.Entry, error) {
+	if _, err := is.claimsTree.GetDataByIndex(hIndex); err == merkletree.ErrEntryIndexNotFound {
+		return nil, ErrClaimNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	idenStateData := is.idenStateDataOnChain()
+	if idenStateData.IdenState.Equals(&merkletree.HashZero) {
+		return nil, ErrClaimNotFoundStateOnChain
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	trees, err := is.snapshotTrees(tx, idenStateData.IdenState)
+	if err != nil {
+		return nil, err
+	}
+	data, err := trees.claimsTree.GetDataByIndex(hIndex)
+	if err == merkletree.ErrEntryIndexNotFound {
+		return nil, ErrClaimNotFoundStateOnChain
+	} else if err != nil {
+		return nil, err
+	}
+	claim := &merkletree.Entry{Data: *data}
+	nonce := claims.GetRevocationNonce(claim)
+	revLeaf := claims.NewLeafRevocationsTree(nonce, claims.RevocationVersionAll).Entry()
+	if _, err := trees.revocationsTree.GetDataByIndex(revLeaf.HIndex()); err == nil {
+		return nil, ErrClaimRevoked
+	} else if err != merkletree.ErrEntryIndexNotFound {
+		return nil, err
+	}
+	return claim, nil
+}
+
+// idenStateReferenced returns true if the identity state has been used to
+// generate a credential.
+func (is *Issuer) idenStateReferenced(tx db.Tx, idenState *merkletree.Hash) (bool, error) {
+	if _, err := tx.Get(append(dbPrefixIdenStateRefs, idenState[:]...)); err == db.ErrNotFound {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// CompactStateHistory removes the old entries of the identity state list to
+// bound the storage used by long-lived issuers, keeping the last keepLast
+// entries (at least one).  The genesis state, the state on chain, the pending state and the
+// states referenced by generated credentials are always kept.  Only the list
+// entries (the tree roots of each state) are removed: the tree nodes are shared
+// between states and stay in the storage.  Returns the number of removed
+// entries.
+func (is *Issuer) CompactStateHistory(keepLast uint32) (uint32, error) {
+	return is.CompactStateHistoryProgress(keepLast, nil)
+}
+
+// CompactStateHistoryProgress is like CompactStateHistory, calling progress
+// (if not nil) with the number of visited entries of the identity state list
+// out of the entries to visit.
+func (is *Issuer) CompactStateHistoryProgress(keepLast uint32, progress func(done, total int)) (uint32, error) {
+	if progress == nil {
+		progress = func(int, int) {}
+	}
+	if keepLast == 0 {
+		keepLast = 1
+	}
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	idenStateListLen, err := is.idenStateList.Length(tx)
+	if err != nil {
+		return 0, err
+	}
+	removed := uint32(0)
+	total := 0
+	if idenStateListLen > keepLast+1 {
+		total = int(idenStateListLen - keepLast - 1)
+	}
+	// Index 0 is the genesis state.
+	for idx := uint32(1); idx+keepLast < idenStateListLen; idx++ {
+		progress(int(idx)-1, total)
+		idenState, _, err := is.getIdenStateByIdx(tx, idx)
+		if err == db.ErrNotFound {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+		if idenState.Equals(is.idenStateOnChain()) || idenState.Equals(is.idenStatePending()) {
+			continue
+		}
+		referenced, err := is.idenStateReferenced(tx, idenState)
+		if err != nil {
+			return 0, err
+		}
+		if referenced {
+			continue
+		}
+		if err := is.idenStateList.DeleteByIdx(tx, idx); err != nil {
+			return 0, err
+		}
+		removed++
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	progress(total, total)
+	return removed, nil
+}
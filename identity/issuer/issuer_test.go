@@ -1,23 +1,42 @@
 package issuer
 
 import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/ethereum/go-ethereum/common"
 	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/idenpuboffchainwriter"
 	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
 	"github.com/iden3/go-iden3-core/core"
 	"github.com/iden3/go-iden3-core/core/claims"
 	"github.com/iden3/go-iden3-core/core/proof"
 	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/db/replication"
+	"github.com/iden3/go-iden3-core/eth"
 	"github.com/iden3/go-iden3-core/keystore"
 	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/retry"
+	"github.com/iden3/go-iden3-crypto/babyjub"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 )
 
 var pass = []byte("my passphrase")
 
-func newIssuer(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock) (*Issuer, db.Storage, *keystore.KeyStore) {
+func newIssuer(t testing.TB, idenPubOnChain *idenpubonchain.IdenPubOnChainMock) (*Issuer, db.Storage, *keystore.KeyStore) {
+	return newIssuerWithHooks(t, idenPubOnChain, nil)
+}
+
+func newIssuerWithHooks(t testing.TB, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, hooks *Hooks) (*Issuer, db.Storage, *keystore.KeyStore) {
 	cfg := ConfigDefault
 	storage := db.NewMemoryStorage()
 	ksStorage := keystore.MemStorage([]byte{})
@@ -27,7 +46,7 @@ func newIssuer(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock)
 	require.Nil(t, err)
 	err = keyStore.UnlockKey(kOp, pass)
 	require.Nil(t, err)
-	issuer, err := New(cfg, kOp, []merkletree.Entrier{}, storage, keyStore, idenPubOnChain)
+	issuer, err := New(cfg, kOp, []merkletree.Entrier{}, storage, keyStore, idenPubOnChain, hooks)
 	require.Nil(t, err)
 	return issuer, storage, keyStore
 }
@@ -35,11 +54,12 @@ func newIssuer(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock)
 func TestNewLoadIssuer(t *testing.T) {
 	issuer, storage, keyStore := newIssuer(t, nil)
 
-	issuerLoad, err := Load(storage, keyStore, nil)
+	issuerLoad, err := Load(storage, keyStore, nil, nil)
 	require.Nil(t, err)
 
 	assert.Equal(t, issuer.cfg, issuerLoad.cfg)
 	assert.Equal(t, issuer.id, issuerLoad.id)
+	assert.Equal(t, issuer.kOp(), issuerLoad.kOp())
 }
 
 func TestIssuerGenesis(t *testing.T) {
@@ -61,22 +81,22 @@ func TestIssuerFull(t *testing.T) {
 	assert.Equal(t, core.IdGenesisFromIdenState(idenState), issuer.ID())
 }
 
-func mockInitState(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, issuer *Issuer, genesisState *merkletree.Hash) (*types.Transaction, *merkletree.Hash) {
-	var ethTx types.Transaction
+func mockInitState(t testing.TB, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, issuer *Issuer, genesisState *merkletree.Hash) (*types.Transaction, *merkletree.Hash) {
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
 	newState, _ := issuer.state()
 	sig, err := issuer.SignBinary(SigPrefixSetState, append(genesisState[:], newState[:]...))
 	require.Nil(t, err)
-	idenPubOnChain.On("InitState", issuer.id, genesisState, newState, []byte(nil), []byte(nil), sig).Return(&ethTx, nil).Once()
-	return &ethTx, newState
+	idenPubOnChain.On("InitState", issuer.id, genesisState, newState, []byte(nil), []byte(nil), sig).Return(ethTx, nil).Once()
+	return ethTx, newState
 }
 
 func mockSetState(t *testing.T, idenPubOnChain *idenpubonchain.IdenPubOnChainMock, issuer *Issuer, oldState *merkletree.Hash) (*types.Transaction, *merkletree.Hash) {
-	var ethTx types.Transaction
+	ethTx := types.NewTransaction(0, common.Address{}, big.NewInt(0), 0, big.NewInt(0), nil)
 	newState, _ := issuer.state()
 	sig, err := issuer.SignBinary(SigPrefixSetState, append(oldState[:], newState[:]...))
 	require.Nil(t, err)
-	idenPubOnChain.On("SetState", issuer.id, newState, []byte(nil), []byte(nil), sig).Return(&ethTx, nil).Once()
-	return &ethTx, newState
+	idenPubOnChain.On("SetState", issuer.id, newState, []byte(nil), []byte(nil), sig).Return(ethTx, nil).Once()
+	return ethTx, newState
 }
 
 func TestIssuerPublish(t *testing.T) {
@@ -97,8 +117,12 @@ func TestIssuerPublish(t *testing.T) {
 	assert.Equal(t, idenStateLast, genesisState)
 
 	// If state hasn't changed, PublisState does nothing
-	err = issuer.PublishState()
+	res, err := issuer.PublishState()
 	require.Nil(t, err)
+	assert.Equal(t, PublishStateNoChanges, res.Status)
+	assert.Equal(t, genesisState, res.IdenState)
+	_, _, pending := issuer.PendingState()
+	assert.False(t, pending)
 
 	//
 	// State Init
@@ -108,13 +132,27 @@ func TestIssuerPublish(t *testing.T) {
 	err = issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0))
 	require.Nil(t, err)
 
-	_, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	ethTx, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
 
 	// Publishing state for the first time
-	err = issuer.PublishState()
+	res, err = issuer.PublishState()
 	require.Nil(t, err)
+	assert.Equal(t, PublishStateSubmitted, res.Status)
+	assert.Equal(t, newState, res.IdenState)
+	assert.Equal(t, ethTx, res.EthTx)
 	assert.Equal(t, &merkletree.HashZero, issuer.idenStateOnChain())
 	assert.Equal(t, newState, issuer.idenStatePending())
+	pendingState, pendingTx, pending := issuer.PendingState()
+	assert.True(t, pending)
+	assert.Equal(t, newState, pendingState)
+	assert.Equal(t, ethTx, pendingTx)
+
+	// Publishing again while pending doesn't send a new transaction
+	res, err = issuer.PublishState()
+	require.Nil(t, err)
+	assert.Equal(t, PublishStateAlreadyPending, res.Status)
+	assert.Equal(t, newState, res.IdenState)
+	assert.Equal(t, ethTx, res.EthTx)
 
 	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero}, nil).Once()
 
@@ -142,11 +180,16 @@ func TestIssuerPublish(t *testing.T) {
 	require.Nil(t, err)
 
 	oldState := newState
-	_, newState = mockSetState(t, idenPubOnChain, issuer, oldState)
+	ethTx, newState = mockSetState(t, idenPubOnChain, issuer, oldState)
 
 	// Publishing state update
-	err = issuer.PublishState()
+	res, err = issuer.PublishState()
 	require.Nil(t, err)
+	assert.Equal(t, PublishStateSubmitted, res.Status)
+	pendingState, pendingTx, pending = issuer.PendingState()
+	assert.True(t, pending)
+	assert.Equal(t, newState, pendingState)
+	assert.Equal(t, ethTx, pendingTx)
 	assert.Equal(t, oldState, issuer.idenStateOnChain())
 	assert.Equal(t, newState, issuer.idenStatePending())
 
@@ -165,6 +208,8 @@ func TestIssuerPublish(t *testing.T) {
 	require.Nil(t, err)
 	assert.Equal(t, newState, issuer.idenStateOnChain())
 	assert.Equal(t, &merkletree.HashZero, issuer.idenStatePending())
+	_, _, pending = issuer.PendingState()
+	assert.False(t, pending)
 }
 
 func TestIssuerCredential(t *testing.T) {
@@ -185,7 +230,7 @@ func TestIssuerCredential(t *testing.T) {
 	assert.Equal(t, ErrIdenStateOnChainZero, err)
 
 	_, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
-	err = issuer.PublishState()
+	_, err = issuer.PublishState()
 	require.Nil(t, err)
 
 	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: newState}, nil).Once()
@@ -209,3 +254,621 @@ func TestIssuerCredential(t *testing.T) {
 	_, err = issuer.GenCredentialExistence(claim1)
 	assert.Equal(t, ErrClaimNotFoundStateOnChain, err)
 }
+
+func TestIssuerRefreshCredentialExistence(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.IssueClaim(claim0))
+
+	_, err := issuer.RefreshCredentialExistence(claim0.Entry().HIndex())
+	assert.Equal(t, ErrClaimNotFoundStateOnChain, err)
+	_, err = issuer.RefreshCredentialExistence(&merkletree.Hash{1})
+	assert.Equal(t, ErrClaimNotFound, err)
+
+	_, state1 := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: state1}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+
+	credExist, err := issuer.RefreshCredentialExistence(claim0.Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, state1, credExist.IdenStateData.IdenState)
+	assert.Equal(t, claim0.Entry().Data, credExist.Claim.Data)
+
+	// Once the revocation is published the credential can't be refreshed
+	require.Nil(t, issuer.RevokeClaim(claim0))
+	credExist, err = issuer.RefreshCredentialExistence(claim0.Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, state1, credExist.IdenStateData.IdenState)
+	_, state2 := mockSetState(t, idenPubOnChain, issuer, state1)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: state2}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	_, err = issuer.RefreshCredentialExistence(claim0.Entry().HIndex())
+	assert.Equal(t, ErrClaimRevoked, err)
+}
+
+func TestIssuerGenProofClaim(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.IssueClaim(claim0))
+
+	_, state1 := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err := issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: state1}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+
+	proofClaim, err := issuer.GenProofClaim(claim0)
+	require.Nil(t, err)
+	verified, err := proofClaim.Verify()
+	require.Nil(t, err)
+	// The claims root of a state after genesis is not in the roots tree.
+	assert.Equal(t, proof.ProofClaimClaimsTree|proof.ProofClaimNonRevocation|proof.ProofClaimState, verified)
+	assert.Equal(t, state1, proofClaim.IdenStateData.IdenState)
+	assert.False(t, proofClaim.IsGenesis())
+	require.Nil(t, proofClaim.VerifySignature(issuer.kOp()))
+	credExist, err := proofClaim.CredentialExistence()
+	require.Nil(t, err)
+	assert.Equal(t, claim0.Entry().Data, credExist.Claim.Data)
+
+	require.Nil(t, issuer.RevokeClaim(claim0))
+	_, state2 := mockSetState(t, idenPubOnChain, issuer, state1)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: state2}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	_, err = issuer.GenProofClaim(claim0)
+	assert.Equal(t, ErrClaimRevoked, err)
+}
+
+func TestIssuerCredentialDuringPublish(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	err := issuer.IssueClaim(claim0)
+	require.Nil(t, err)
+
+	_, state1 := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: state1}, nil).Once()
+	err = issuer.SyncIdenStatePublic()
+	require.Nil(t, err)
+
+	// Publish a new state and keep issuing claims while it's pending
+	indexBytes[0] = 0x43
+	err = issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 1))
+	require.Nil(t, err)
+	mockSetState(t, idenPubOnChain, issuer, state1)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	indexBytes[0] = 0x44
+	err = issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 2))
+	require.Nil(t, err)
+
+	credExist, err := issuer.GenCredentialExistence(claim0)
+	require.Nil(t, err)
+	assert.Equal(t, state1, credExist.IdenStateData.IdenState)
+	claimsRoot, err := merkletree.RootFromProof(credExist.MtpClaim, claim0.Entry().HIndex(), claim0.Entry().HValue())
+	require.Nil(t, err)
+	assert.Equal(t, state1, core.IdenState(claimsRoot, credExist.RevocationsRoot, credExist.RootsRoot))
+}
+
+func TestIssuerCompactStateHistory(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	states := []*merkletree.Hash{genesisState}
+	var claim1 *claims.ClaimBasic
+	for i := 0; i < 5; i++ {
+		indexBytes[0] = byte(i)
+		claim := claims.NewClaimBasic(indexBytes, dataBytes, uint32(i))
+		require.Nil(t, issuer.IssueClaim(claim))
+		var newState *merkletree.Hash
+		if i == 0 {
+			_, newState = mockInitState(t, idenPubOnChain, issuer, states[i])
+		} else {
+			_, newState = mockSetState(t, idenPubOnChain, issuer, states[i])
+		}
+		res, err := issuer.PublishState()
+		require.Nil(t, err)
+		require.Equal(t, PublishStateSubmitted, res.Status)
+		idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: newState}, nil).Once()
+		require.Nil(t, issuer.SyncIdenStatePublic())
+		states = append(states, newState)
+		if i == 1 {
+			claim1 = claim
+			_, err := issuer.GenCredentialExistence(claim1)
+			require.Nil(t, err)
+		}
+	}
+
+	// states[2] is referenced by a credential, states[5] is on chain
+	var done, total int
+	removed, err := issuer.CompactStateHistoryProgress(1, func(d, t int) { done, total = d, t })
+	require.Nil(t, err)
+	assert.Equal(t, uint32(3), removed)
+	assert.Equal(t, 4, total)
+	assert.Equal(t, total, done)
+
+	tx, err := issuer.storage.NewTx()
+	require.Nil(t, err)
+	defer tx.Close()
+	for i, state := range states {
+		_, err := issuer.getIdenStateTreeRoots(tx, state)
+		switch i {
+		case 0, 2, 5:
+			assert.Nil(t, err)
+		default:
+			assert.Equal(t, db.ErrNotFound, err)
+		}
+	}
+
+	removed, err = issuer.CompactStateHistory(0)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(0), removed)
+
+	// The issuer keeps working after the compaction
+	_, err = issuer.GenCredentialExistence(claim1)
+	require.Nil(t, err)
+	indexBytes[0] = 0x81
+	require.Nil(t, issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 10)))
+	mockSetState(t, idenPubOnChain, issuer, states[5])
+	res, err := issuer.PublishState()
+	require.Nil(t, err)
+	require.Equal(t, PublishStateSubmitted, res.Status)
+}
+
+func TestIssuerReadOnly(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, storage, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	err := issuer.IssueClaim(claim0)
+	require.Nil(t, err)
+
+	_, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: newState}, nil).Once()
+	err = issuer.SyncIdenStatePublic()
+	require.Nil(t, err)
+
+	ro, err := LoadReadOnly(storage, nil)
+	require.Nil(t, err)
+	assert.Equal(t, issuer.ID(), ro.ID())
+	assert.Equal(t, newState, ro.StateDataOnChain().IdenState)
+
+	credExist, err := issuer.GenCredentialExistence(claim0)
+	require.Nil(t, err)
+	credExistRO, err := ro.GenCredentialExistence(claim0)
+	require.Nil(t, err)
+	assert.Equal(t, credExist, credExistRO)
+
+	entry, err := ro.ClaimByHIndex(claim0.Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, claim0.Entry().Data, entry.Data)
+
+	// The view doesn't see the changes of the writer until Reload
+	indexBytes[0] = 0x81
+	claim1 := claims.NewClaimBasic(indexBytes, dataBytes, 1)
+	err = issuer.IssueClaim(claim1)
+	require.Nil(t, err)
+	_, err = ro.ClaimByHIndex(claim1.Entry().HIndex())
+	assert.Equal(t, merkletree.ErrEntryIndexNotFound, err)
+
+	err = ro.Reload()
+	require.Nil(t, err)
+	state, _ := issuer.State()
+	stateRO, _ := ro.State()
+	assert.Equal(t, state, stateRO)
+	_, err = ro.ClaimByHIndex(claim1.Entry().HIndex())
+	assert.Nil(t, err)
+}
+
+func TestIssuerReadOnlyReplica(t *testing.T) {
+	dir, err := ioutil.TempDir("", "issuer-replica")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	kOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kOp, pass))
+	leader := replication.NewLeader(db.NewMemoryStorage())
+	issuer, err := New(ConfigDefault, kOp, []merkletree.Entrier{}, leader.WithPrefix([]byte("issuer:")), keyStore, nil, nil)
+	require.Nil(t, err)
+
+	path := filepath.Join(dir, "follower")
+	require.Nil(t, leader.Checkpoint(path))
+	followerStorage, err := db.NewLevelDbStorage(path, true)
+	require.Nil(t, err)
+	defer followerStorage.Close()
+	follower := replication.NewFollower(followerStorage, leader)
+	ro, err := LoadReadOnly(followerStorage.WithPrefix([]byte("issuer:")), nil)
+	require.Nil(t, err)
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.claimsTree.AddClaim(claim0))
+	applied, err := follower.Sync()
+	require.Nil(t, err)
+	assert.Equal(t, 1, applied)
+	require.Nil(t, ro.Reload())
+	state, _ := issuer.State()
+	stateRO, _ := ro.State()
+	assert.Equal(t, state, stateRO)
+	_, err = ro.ClaimByHIndex(claim0.Entry().HIndex())
+	assert.Nil(t, err)
+}
+
+func TestIssuerHooks(t *testing.T) {
+	var issuer *Issuer
+	events := []interface{}{}
+	hooks := &Hooks{
+		OnClaimIssued: func(ev *ClaimIssuedEvent) {
+			// The lock is released when the hooks are called
+			issuer.State()
+			events = append(events, ev)
+		},
+		OnClaimRevoked:   func(ev *ClaimRevokedEvent) { events = append(events, ev) },
+		OnStatePublished: func(ev *StatePublishedEvent) { events = append(events, ev) },
+		OnStateSynced:    func(ev *StateSyncedEvent) { events = append(events, ev) },
+	}
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ = newIssuerWithHooks(t, idenPubOnChain, hooks)
+	genesisState, _ := issuer.state()
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 7)
+	require.Nil(t, issuer.IssueClaim(claim0))
+	// Errors don't trigger hooks
+	require.NotNil(t, issuer.IssueClaim(claim0))
+	require.Nil(t, issuer.RevokeClaim(claim0))
+
+	ethTx, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	res, err := issuer.PublishState()
+	require.Nil(t, err)
+	require.Equal(t, PublishStateSubmitted, res.Status)
+	// Already synced state doesn't trigger hooks
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: newState, BlockN: 3}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+
+	_, roots := issuer.State()
+	assert.Equal(t, []interface{}{
+		&ClaimIssuedEvent{Claim: claim0.Entry()},
+		&ClaimRevokedEvent{Claim: claim0.Entry(), RevocationNonce: 7},
+		&StatePublishedEvent{IdenState: newState, IdenStateTreeRoots: roots, EthTx: ethTx},
+		&StateSyncedEvent{IdenStateData: proof.IdenStateData{IdenState: newState, BlockN: 3}},
+	}, events)
+}
+
+func TestIssuerIssueClaims(t *testing.T) {
+	var issued []*ClaimIssuedEvent
+	hooks := &Hooks{OnClaimIssued: func(ev *ClaimIssuedEvent) { issued = append(issued, ev) }}
+	issuer, _, _ := newIssuerWithHooks(t, idenpubonchain.New(), hooks)
+	stats := issuer.Stats()
+
+	errNewClaim := errors.New("invalid row")
+	newClaim := func(b byte) func(uint32) (merkletree.Entrier, error) {
+		return func(nonce uint32) (merkletree.Entrier, error) {
+			indexBytes := [claims.IndexSlotBytes]byte{b}
+			return claims.NewClaimBasic(indexBytes, [claims.DataSlotBytes]byte{}, nonce), nil
+		}
+	}
+	issuedClaims, errs, err := issuer.IssueClaims([]func(uint32) (merkletree.Entrier, error){
+		newClaim(1),
+		func(uint32) (merkletree.Entrier, error) { return nil, errNewClaim },
+		newClaim(2),
+		newClaim(1),
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []error{nil, errNewClaim, nil, ErrClaimAlreadyIssued}, errs)
+	require.NotNil(t, issuedClaims[0])
+	assert.Nil(t, issuedClaims[1])
+	require.NotNil(t, issuedClaims[2])
+	assert.Nil(t, issuedClaims[3])
+	for _, claim := range []merkletree.Entrier{issuedClaims[0], issuedClaims[2]} {
+		_, err := issuer.ClaimByHIndex(claim.Entry().HIndex())
+		require.Nil(t, err)
+	}
+	assert.NotEqual(t, issuedClaims[0].(*claims.ClaimBasic).RevocationNonce, issuedClaims[2].(*claims.ClaimBasic).RevocationNonce)
+	assert.Equal(t, stats.Claims+2, issuer.Stats().Claims)
+	assert.Equal(t, []*ClaimIssuedEvent{{Claim: issuedClaims[0].Entry()}, {Claim: issuedClaims[2].Entry()}}, issued)
+
+	// The nonces of the whole batch are committed, so they aren't reused.
+	next, err := issuer.IssueClaimWithNonce(newClaim(3))
+	require.Nil(t, err)
+	assert.Equal(t, issuedClaims[2].(*claims.ClaimBasic).RevocationNonce+2, next.(*claims.ClaimBasic).RevocationNonce)
+}
+
+func TestIssuerIdenPubOffChain(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+
+	var w *idenpuboffchainwriter.IdenPubOffChainWriteHttp
+	require.Nil(t, issuer.SetIdenPubOffChainWriter(func(claimsTree, rootsTree,
+		revocationsTree *merkletree.MerkleTree) (idenpuboffchainwriter.IdenPubOffChainWriter, error) {
+		var err error
+		w, err = idenpuboffchainwriter.NewIdenPubOffChainWriteHttp(&idenpuboffchainwriter.ConfigDefault,
+			db.NewMemoryStorage(), claimsTree, rootsTree, revocationsTree)
+		return w, err
+	}))
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	require.Nil(t, issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0)))
+	_, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	res, err := issuer.PublishState()
+	require.Nil(t, err)
+	require.Equal(t, PublishStateSubmitted, res.Status)
+	require.Nil(t, res.IdenPubOffChainErr)
+
+	_, roots := issuer.State()
+	publicData, err := w.GetPublicData(nil)
+	require.Nil(t, err)
+	assert.Equal(t, *newState, publicData.IdenState)
+	assert.Equal(t, *roots.RootsRoot, publicData.RootsTreeRoot)
+	assert.Equal(t, *roots.RevocationsRoot, publicData.RevocationsTreeRoot)
+}
+
+func TestIssuerConcurrent(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+
+	var ethTx types.Transaction
+	idenPubOnChain.On("InitState", issuer.id, mock.Anything, mock.Anything, []byte(nil), []byte(nil), mock.Anything).
+		Return(&ethTx, nil).Once()
+
+	n := 16
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			defer wg.Done()
+			indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+			indexBytes[0] = byte(i)
+			err := issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, uint32(i)))
+			assert.Nil(t, err)
+			issuer.State()
+			issuer.StateDataOnChain()
+		}(i)
+	}
+	wg.Wait()
+
+	results := make(chan *PublishStateResult, 2)
+	wg.Add(2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			defer wg.Done()
+			res, err := issuer.PublishState()
+			assert.Nil(t, err)
+			results <- res
+		}()
+	}
+	wg.Wait()
+	close(results)
+	var submitted, pending int
+	for res := range results {
+		switch res.Status {
+		case PublishStateSubmitted:
+			submitted++
+		case PublishStateAlreadyPending:
+			pending++
+		default:
+			t.Fatal(res.Status)
+		}
+	}
+	assert.Equal(t, 1, submitted)
+	assert.Equal(t, 1, pending)
+
+	tx, err := issuer.storage.NewTx()
+	require.Nil(t, err)
+	idenStateListLen, err := issuer.idenStateList.Length(tx)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(2), idenStateListLen)
+	idenStateLast, _, err := issuer.getIdenStateByIdx(tx, idenStateListLen-1)
+	require.Nil(t, err)
+	assert.Equal(t, idenStateLast, issuer.idenStatePending())
+	idenPubOnChain.AssertExpectations(t)
+}
+
+// idenPubOnChainEstimator is an IdenPubOnChainMock that simulates every state
+// update as reverted.
+type idenPubOnChainEstimator struct {
+	*idenpubonchain.IdenPubOnChainMock
+}
+
+func (e *idenPubOnChainEstimator) EstimateSetState(id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error) {
+	return 0, &eth.ContractRevertError{Reason: "invalid signature"}
+}
+
+func (e *idenPubOnChainEstimator) EstimateInitState(id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (uint64, error) {
+	return 0, &eth.ContractRevertError{Reason: "invalid signature"}
+}
+
+func TestIssuerPublishEstimateReverted(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	storage := db.NewMemoryStorage()
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	kOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kOp, pass))
+	issuer, err := New(ConfigDefault, kOp, []merkletree.Entrier{}, storage, keyStore,
+		&idenPubOnChainEstimator{idenPubOnChain}, nil)
+	require.Nil(t, err)
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	require.Nil(t, issuer.IssueClaim(claims.NewClaimBasic(indexBytes, dataBytes, 0)))
+
+	// The transaction is not sent and the state is not pending
+	_, err = issuer.PublishState()
+	var revertErr *eth.ContractRevertError
+	require.True(t, errors.As(err, &revertErr))
+	assert.Equal(t, "invalid signature", revertErr.Reason)
+	_, _, ok := issuer.PendingState()
+	assert.False(t, ok)
+	idenPubOnChain.AssertExpectations(t)
+}
+
+// idenPubOnChainCtx is an IdenPubOnChainMock whose calls fail like the
+// requests to a node when the context is done.
+type idenPubOnChainCtx struct {
+	*idenpubonchain.IdenPubOnChainMock
+}
+
+func (m *idenPubOnChainCtx) GetStateCtx(ctx context.Context, id *core.ID) (*proof.IdenStateData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetState(id)
+}
+
+func (m *idenPubOnChainCtx) GetStateByBlockCtx(ctx context.Context, id *core.ID, blockN uint64) (*proof.IdenStateData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetStateByBlock(id, blockN)
+}
+
+func (m *idenPubOnChainCtx) GetStateByTimeCtx(ctx context.Context, id *core.ID, blockTimestamp int64) (*proof.IdenStateData, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.GetStateByTime(id, blockTimestamp)
+}
+
+func (m *idenPubOnChainCtx) SetStateCtx(ctx context.Context, id *core.ID, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.SetState(id, newState, kOpProof, stateTransitionProof, signature)
+}
+
+func (m *idenPubOnChainCtx) InitStateCtx(ctx context.Context, id *core.ID, genesisState *merkletree.Hash, newState *merkletree.Hash, kOpProof []byte, stateTransitionProof []byte, signature *babyjub.SignatureComp) (*types.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return m.InitState(id, genesisState, newState, kOpProof, stateTransitionProof, signature)
+}
+
+func TestIssuerCtx(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.idenPubOnChain = &idenPubOnChainCtx{idenPubOnChain}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	genesisState, _ := issuer.state()
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	claim := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.IssueClaim(claim))
+
+	// Nothing is done with a canceled context.
+	_, err := issuer.PublishStateCtx(ctx)
+	assert.Equal(t, context.Canceled, err)
+	_, _, pending := issuer.PendingState()
+	assert.False(t, pending)
+	assert.Equal(t, context.Canceled, issuer.SyncIdenStatePublicCtx(ctx))
+
+	ethTx, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	res, err := issuer.PublishStateCtx(context.Background())
+	require.Nil(t, err)
+	assert.Equal(t, PublishStateSubmitted, res.Status)
+	assert.Equal(t, ethTx, res.EthTx)
+
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: newState}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublicCtx(context.Background()))
+	_, err = issuer.GenCredentialExistenceCtx(ctx, claim)
+	assert.Equal(t, context.Canceled, err)
+	_, err = issuer.RefreshCredentialExistenceCtx(ctx, claim.Entry().HIndex())
+	assert.Equal(t, context.Canceled, err)
+	_, err = issuer.GenCredentialExistenceCtx(context.Background(), claim)
+	assert.Nil(t, err)
+	idenPubOnChain.AssertExpectations(t)
+}
+
+func TestIssuerSyncRetry(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.cfg.SyncRetry = retry.Policy{MaxAttempts: 2, BackoffMin: time.Millisecond, BackoffMax: time.Millisecond}
+
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero},
+		errors.New("unavailable")).Once()
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero},
+		nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+
+	// An unexpected identity state is not retried.
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: &merkletree.Hash{1}},
+		nil).Once()
+	assert.NotNil(t, issuer.SyncIdenStatePublic())
+	idenPubOnChain.AssertExpectations(t)
+}
+
+func TestIssuerCredentialGenesis(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	kOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kOp, pass))
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	indexBytes[0] = 0x42
+	claimGenesis := claims.NewClaimBasic(indexBytes, dataBytes, 1234)
+	issuer, err := New(ConfigDefault, kOp, []merkletree.Entrier{claimGenesis}, storage, keyStore, idenpubonchain.New(), nil)
+	require.Nil(t, err)
+	genesisState, _ := issuer.state()
+
+	// Claims issued after genesis don't have genesis credentials.
+	indexBytes[0] = 0x81
+	claim1 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.IssueClaim(claim1))
+	_, err = issuer.GenCredentialExistenceGenesis(claim1)
+	assert.Equal(t, ErrClaimNotFoundGenesis, err)
+
+	credExist, err := issuer.GenCredentialExistenceGenesis(claimGenesis)
+	require.Nil(t, err)
+	assert.Equal(t, genesisState, credExist.IdenStateData.IdenState)
+	assert.True(t, credExist.IsGenesis())
+	claimsRoot, err := merkletree.RootFromProof(credExist.MtpClaim, claimGenesis.Entry().HIndex(), claimGenesis.Entry().HValue())
+	require.Nil(t, err)
+	assert.Equal(t, genesisState, core.IdenState(claimsRoot, credExist.RevocationsRoot, credExist.RootsRoot))
+
+	kOpPub, err := kOp.Decompress()
+	require.Nil(t, err)
+	claimKOp := claims.NewClaimAuthorizeKSignBabyJub(kOpPub, 0)
+	credKOp, err := issuer.GenCredentialExistenceGenesis(claimKOp)
+	require.Nil(t, err)
+	assert.True(t, credKOp.IsGenesis())
+}
@@ -0,0 +1,187 @@
+package issuer
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/db/replication"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/poseidon"
+)
+
+var (
+	ErrPayloadNotFound          = fmt.Errorf("Claim payload not found")
+	ErrPayloadNotCommitted      = fmt.Errorf("The claim doesn't commit to the payload hash")
+	ErrPayloadStorageNil        = fmt.Errorf("No claim payload storage set, see SetPayloadStorage")
+	ErrPayloadStorageReplicated = fmt.Errorf("The claim payload storage can't be replicated")
+)
+
+// ClaimPayloadSaltLen is the length of the salt of a ClaimPayload.
+const ClaimPayloadSaltLen = 16
+
+// ClaimPayload is the data of a claim, like personal data, that is not stored
+// in the claims tree: the claim only contains its Hash, and the payload is
+// kept in a separate storage (see SetPayloadStorage) from where it can be
+// erased with ForgetClaimData without changing the tree.  The random Salt prevents
+// guessing the data of an erased payload from its Hash.
+type ClaimPayload struct {
+	Data []byte
+	Salt [ClaimPayloadSaltLen]byte
+}
+
+// NewClaimPayload creates a ClaimPayload of data with a random salt.
+func NewClaimPayload(data []byte) (*ClaimPayload, error) {
+	p := ClaimPayload{Data: append([]byte{}, data...)}
+	if _, err := rand.Read(p.Salt[:]); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Hash returns the hash of the salted payload, to be set in an element of the
+// claim.
+func (p *ClaimPayload) Hash() (merkletree.ElemBytes, error) {
+	h, err := poseidon.HashBytes(append(p.Salt[:], p.Data...))
+	if err != nil {
+		return merkletree.ElemBytes{}, err
+	}
+	return merkletree.NewElemBytesFromBigInt(h)
+}
+
+// SetPayloadStorage sets the storage of the claim payloads, which is
+// required by IssueClaimWithPayload.  It's not persisted in the storage, so
+// it must be set again after Load.
+//
+// The payloads are not kept in the Issuer storage because the copies of it
+// would keep the erased payloads: the WAL of a replication.Leader records the
+// value of every put, and the checkpoints (see Issuer.Checkpoint) copy the
+// whole storage.  So the payload storage must be a separate storage that is
+// neither replicated, which is rejected with ErrPayloadStorageReplicated, nor
+// backed up with the Issuer storage.  Erased payloads may remain in the files
+// of the storage until they are compacted (see db.Compacter).
+func (is *Issuer) SetPayloadStorage(storage db.Storage) error {
+	if _, ok := storage.(*replication.Leader); ok {
+		return ErrPayloadStorageReplicated
+	}
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	is.payloadStorage = storage
+	return nil
+}
+
+// payloads returns the storage of the claim payloads, or
+// ErrPayloadStorageNil if it's not set.  The caller must hold the lock.
+func (is *Issuer) payloads() (db.Storage, error) {
+	if is.payloadStorage == nil {
+		return nil, ErrPayloadStorageNil
+	}
+	return is.payloadStorage, nil
+}
+
+// IssueClaimWithPayload issues claim like IssueClaim, and stores its payload,
+// whose Hash must be an element of the claim.  It returns the issued claim,
+// which has a bumped version if the configured DuplicatePolicy is
+// DuplicateBumpVersion and the claim was already issued.
+func (is *Issuer) IssueClaimWithPayload(claim merkletree.Entrier, payload *ClaimPayload) (merkletree.Entrier, error) {
+	if is.idenPubOnChain == nil {
+		return nil, ErrIdenPubOnChainNil
+	}
+	h, err := payload.Hash()
+	if err != nil {
+		return nil, err
+	}
+	committed := false
+	for _, e := range claim.Entry().Data {
+		if e == h {
+			committed = true
+			break
+		}
+	}
+	if !committed {
+		return nil, ErrPayloadNotCommitted
+	}
+	var event *ClaimIssuedEvent
+	defer func() { is.hooks.claimIssued(event) }()
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	payloads, err := is.payloads()
+	if err != nil {
+		return nil, err
+	}
+	issued, issueErr := is.issueClaim(claim, time.Time{})
+	if issued == nil {
+		return nil, issueErr
+	}
+	event = &ClaimIssuedEvent{Claim: issued.Entry()}
+	tx, err := payloads.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if err := db.StoreJSON(tx, issued.Entry().HIndex()[:], payload); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return issued, issueErr
+}
+
+// ClaimPayload returns the payload of the issued claim at hIndex, or
+// ErrPayloadNotFound if it has no payload or it has been erased.
+func (is *Issuer) ClaimPayload(hIndex *merkletree.Hash) (*ClaimPayload, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	payloads, err := is.payloads()
+	if err != nil {
+		return nil, err
+	}
+	b, err := payloads.Get(hIndex[:])
+	if err == db.ErrNotFound {
+		return nil, ErrPayloadNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var payload ClaimPayload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, err
+	}
+	return &payload, nil
+}
+
+// ForgetClaimData revokes the issued claim at hIndex, if it's not revoked
+// yet, and erases its payload, so that its data can't be recovered while the
+// claims tree stays the same.  The Identity State is not updated.  Without a
+// payload storage no claim has a payload, so it only revokes the claim.
+func (is *Issuer) ForgetClaimData(hIndex *merkletree.Hash) error {
+	if is.idenPubOnChain == nil {
+		return ErrIdenPubOnChainNil
+	}
+	var event *ClaimRevokedEvent
+	defer func() { is.hooks.claimRevoked(event) }()
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	var err error
+	event, err = is.revokeClaim(hIndex)
+	if err == merkletree.ErrEntryIndexNotFound {
+		return ErrClaimNotFound
+	} else if err != nil && err != merkletree.ErrEntryIndexAlreadyExists {
+		return err
+	}
+	payloads, err := is.payloads()
+	if err == ErrPayloadStorageNil {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	tx, err := payloads.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	tx.Delete(hIndex[:])
+	return tx.Commit()
+}
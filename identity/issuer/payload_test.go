@@ -0,0 +1,76 @@
+package issuer
+
+import (
+	"testing"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/db/replication"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newPayloadClaim returns a basic claim with the hash of payload in the
+// value.  The hash is a whole element, which doesn't fit a data slot.
+func newPayloadClaim(t *testing.T, index byte, payload *ClaimPayload) merkletree.Entrier {
+	h, err := payload.Hash()
+	require.Nil(t, err)
+	entry := newExpirationClaim(index).Entry()
+	entry.Data[6] = h
+	return (*entryClaim)(entry)
+}
+
+func TestIssuerClaimPayload(t *testing.T) {
+	issuer, storage, _ := newIssuer(t, idenpubonchain.New())
+	payload, err := NewClaimPayload([]byte("John Doe, 1970-01-01"))
+	require.Nil(t, err)
+	_, err = issuer.IssueClaimWithPayload(newPayloadClaim(t, 1, payload), payload)
+	assert.Equal(t, ErrPayloadStorageNil, err)
+	// The replicated storages would keep the erased payloads in the WAL.
+	assert.Equal(t, ErrPayloadStorageReplicated,
+		issuer.SetPayloadStorage(replication.NewLeader(storage).WithPrefix([]byte("payload:"))))
+	payloads := db.NewMemoryStorage()
+	require.Nil(t, issuer.SetPayloadStorage(payloads))
+
+	payload2, err := NewClaimPayload(payload.Data)
+	require.Nil(t, err)
+	// The salt hides the data.
+	h, err := payload.Hash()
+	require.Nil(t, err)
+	h2, err := payload2.Hash()
+	require.Nil(t, err)
+	assert.NotEqual(t, h, h2)
+
+	_, err = issuer.IssueClaimWithPayload(newExpirationClaim(1), payload)
+	assert.Equal(t, ErrPayloadNotCommitted, err)
+
+	claim := newPayloadClaim(t, 1, payload)
+	issued, err := issuer.IssueClaimWithPayload(claim, payload)
+	require.Nil(t, err)
+	hIndex := issued.Entry().HIndex()
+	stored, err := issuer.ClaimPayload(hIndex)
+	require.Nil(t, err)
+	assert.Equal(t, payload, stored)
+	_, err = payloads.Get(hIndex[:])
+	require.Nil(t, err)
+
+	rootBefore := issuer.claimsTree.RootKey()
+	require.Nil(t, issuer.ForgetClaimData(hIndex))
+	_, err = issuer.ClaimPayload(hIndex)
+	assert.Equal(t, ErrPayloadNotFound, err)
+	_, err = payloads.Get(hIndex[:])
+	assert.Equal(t, db.ErrNotFound, err)
+	// The claim stays in the tree, revoked.
+	assert.Equal(t, rootBefore, issuer.claimsTree.RootKey())
+	assert.True(t, claimRevoked(t, issuer, claim))
+	// Forgetting again is a no-op.
+	require.Nil(t, issuer.ForgetClaimData(hIndex))
+
+	// Claims without payload can be forgotten too.
+	claim2 := newExpirationClaim(2)
+	require.Nil(t, issuer.IssueClaim(claim2))
+	require.Nil(t, issuer.ForgetClaimData(claim2.Entry().HIndex()))
+	assert.True(t, claimRevoked(t, issuer, claim2))
+	assert.Equal(t, ErrClaimNotFound, issuer.ForgetClaimData(newExpirationClaim(3).Entry().HIndex()))
+}
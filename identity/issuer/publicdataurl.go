@@ -0,0 +1,76 @@
+package issuer
+
+import (
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/db"
+)
+
+var (
+	ErrPublicDataURLUnsupported = fmt.Errorf("idenPubOnChain doesn't support the public data URL registry")
+)
+
+var (
+	SigPrefixSetPublicDataURL = []byte("setpublicdataurl:")
+)
+
+var dbKeyPublicDataURL = []byte("publicdataurl")
+
+// publicDataURL returns the off chain public data URL registered on chain, or
+// "" if it hasn't been registered.  The caller must hold the lock.
+func (is *Issuer) publicDataURL() (string, error) {
+	url, err := is.storage.Get(dbKeyPublicDataURL)
+	if err == db.ErrNotFound {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	return string(url), nil
+}
+
+// PublicDataURL returns the off chain public data URL registered on chain
+// with SetPublicDataURL, or "" if it hasn't been registered.  It's the
+// IdPubUrl of the generated credentials.
+func (is *Issuer) PublicDataURL() (string, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	return is.publicDataURL()
+}
+
+// SetPublicDataURL registers on chain the URL (or IPFS CID, as ipfs://<cid>)
+// where the off chain public data of the identity is published, so that the
+// verifiers can find it from the ID (see
+// idenpuboffchainreader.IdenPubOffChainReadHttp).  The idenPubOnChain must
+// implement idenpubonchain.PublicDataURLRegistry.  The URL is signed by the
+// kOp, bound to the identity.
+func (is *Issuer) SetPublicDataURL(url string) (*types.Transaction, error) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	if is.idenPubOnChain == nil {
+		return nil, ErrIdenPubOnChainNil
+	}
+	registry, ok := is.idenPubOnChain.(idenpubonchain.PublicDataURLRegistry)
+	if !ok {
+		return nil, ErrPublicDataURLUnsupported
+	}
+	sig, err := is.SignBinary(SigPrefixSetPublicDataURL, append(is.id[:], url...))
+	if err != nil {
+		return nil, err
+	}
+	ethTx, err := registry.SetPublicDataURL(is.id, url, nil, sig)
+	if err != nil {
+		return nil, err
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	tx.Put(dbKeyPublicDataURL, []byte(url))
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return ethTx, nil
+}
@@ -0,0 +1,54 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/ethereum/go-ethereum/core/types"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerSetPublicDataURL(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, storage, keyStore := newIssuer(t, idenPubOnChain)
+
+	url, err := issuer.PublicDataURL()
+	require.Nil(t, err)
+	assert.Equal(t, "", url)
+
+	const publicDataURL = "ipfs://QmW2WQi7j6c7UgJTarActp7tDNikE4B2qXtFCfLPdsgaTQ"
+	var sig *babyjub.SignatureComp
+	idenPubOnChain.On("SetPublicDataURL", issuer.id, publicDataURL, []byte(nil), mock.Anything).
+		Run(func(args mock.Arguments) { sig = args.Get(3).(*babyjub.SignatureComp) }).
+		Return(&types.Transaction{}, nil).Once()
+	_, err = issuer.SetPublicDataURL(publicDataURL)
+	require.Nil(t, err)
+	idenPubOnChain.AssertExpectations(t)
+
+	// The signature binds the URL to the identity
+	ok, err := keystore.VerifySignatureRaw(issuer.kOp(), sig,
+		append(append(append([]byte{}, SigPrefixSetPublicDataURL...), issuer.id[:]...), publicDataURL...))
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	// The URL is persisted and used in the credentials
+	issuerLoad, err := Load(storage, keyStore, nil, nil)
+	require.Nil(t, err)
+	url, err = issuerLoad.PublicDataURL()
+	require.Nil(t, err)
+	assert.Equal(t, publicDataURL, url)
+	kOpPub, err := issuer.kOp().Decompress()
+	require.Nil(t, err)
+	credExist, err := issuerLoad.GenCredentialExistenceGenesis(claims.NewClaimAuthorizeKSignBabyJub(kOpPub, 0))
+	require.Nil(t, err)
+	assert.Equal(t, publicDataURL, credExist.IdPubUrl)
+
+	issuer.idenPubOnChain = nil
+	_, err = issuer.SetPublicDataURL(publicDataURL)
+	assert.Equal(t, ErrIdenPubOnChainNil, err)
+}
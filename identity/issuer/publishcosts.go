@@ -0,0 +1,40 @@
+package issuer
+
+import (
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/components/txjournal"
+)
+
+var (
+	ErrPublishCosterNil = fmt.Errorf("publishCoster is nil")
+)
+
+// PublishCoster gives the costs of the transactions sent on behalf of an
+// identity, satisfied by txjournal.Journal.
+type PublishCoster interface {
+	Costs(identity string, purposes ...string) (*txjournal.Costs, error)
+}
+
+// SetPublishCoster sets the PublishCoster that gives the costs of the
+// identity state publications, usually the txjournal.Journal recording the
+// transactions of the eth.Client2 of the IdenPubOnChain.
+func (is *Issuer) SetPublishCoster(publishCoster PublishCoster) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	is.publishCoster = publishCoster
+}
+
+// PublishCosts returns the gas and ether spent by the transactions that
+// published the identity states of the Issuer in the Smart Contract
+// (InitState and SetState).
+func (is *Issuer) PublishCosts() (*txjournal.Costs, error) {
+	is.rw.RLock()
+	publishCoster := is.publishCoster
+	is.rw.RUnlock()
+	if publishCoster == nil {
+		return nil, ErrPublishCosterNil
+	}
+	return publishCoster.Costs(is.id.String(), idenpubonchain.TxPurposeInitState, idenpubonchain.TxPurposeSetState)
+}
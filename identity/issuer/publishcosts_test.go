@@ -0,0 +1,40 @@
+package issuer
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain"
+	idenpubonchainmock "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/components/txjournal"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/eth"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerPublishCosts(t *testing.T) {
+	issuer, _, _ := newIssuer(t, idenpubonchainmock.New())
+	_, err := issuer.PublishCosts()
+	assert.Equal(t, ErrPublishCosterNil, err)
+
+	journal := txjournal.New(db.NewMemoryStorage())
+	issuer.SetPublishCoster(journal)
+	id := issuer.ID().String()
+	for nonce, meta := range []eth.TxMeta{
+		{Purpose: idenpubonchain.TxPurposeInitState, Identity: id},
+		{Purpose: idenpubonchain.TxPurposeSetState, Identity: id},
+		{Purpose: idenpubonchain.TxPurposeSetPublicDataURL, Identity: id},
+		{Purpose: idenpubonchain.TxPurposeSetState, Identity: "other"},
+	} {
+		tx := types.NewTransaction(uint64(nonce), common.Address{}, big.NewInt(0), 300000, big.NewInt(2), nil)
+		require.Nil(t, journal.RecordTx(tx, meta))
+		require.Nil(t, journal.MarkMined(tx.Hash(), txjournal.TxStatusConfirmed, "", 1000))
+	}
+
+	costs, err := issuer.PublishCosts()
+	require.Nil(t, err)
+	assert.Equal(t, &txjournal.Costs{Txs: 2, GasUsed: 2000, Wei: big.NewInt(4000)}, costs)
+}
@@ -0,0 +1,183 @@
+package issuer
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// dbKeyPublishMark stores the publishMark of the last identity state
+	// submission.
+	dbKeyPublishMark = []byte("publishmark")
+)
+
+// PublishPolicy tells when the AutoPublisher publishes the identity state, to
+// batch the changes of the issuers that issue continuously into fewer
+// transactions.  The identity state is published when MinChanges claims
+// and revocations have accumulated since the last publication, or when
+// MaxDelay has elapsed since it and there is some change.  The zero
+// PublishPolicy publishes on every change.
+type PublishPolicy struct {
+	// MinChanges is the number of new claims and revocations that
+	// triggers a publication.  0 is the same as 1.
+	MinChanges uint64
+	// MaxDelay is the time since the last publication that triggers the
+	// publication of any change.  If 0, the changes wait for MinChanges.
+	MaxDelay time.Duration
+}
+
+// publishMark are the number of claims and revocations at the last identity
+// state submission.
+type publishMark struct {
+	Claims      uint64
+	Revocations uint64
+}
+
+func (is *Issuer) setPublishMark(tx db.Tx) error {
+	return db.StoreJSON(tx, dbKeyPublishMark, &publishMark{
+		Claims:      is._stats.Claims,
+		Revocations: is._stats.Revocations,
+	})
+}
+
+// loadPublishMark loads the publishMark.  The Issuers that published before it
+// was introduced have none, so all their claims and revocations count as
+// changes.
+func (is *Issuer) loadPublishMark() (*publishMark, error) {
+	var mark publishMark
+	b, err := is.storage.Get(dbKeyPublishMark)
+	if err == db.ErrNotFound {
+		return &mark, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &mark); err != nil {
+		return nil, err
+	}
+	return &mark, nil
+}
+
+// UnpublishedChanges returns the number of claims issued and revoked since
+// the last identity state submission.
+func (is *Issuer) UnpublishedChanges() (uint64, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	return is.unpublishedChanges()
+}
+
+func (is *Issuer) unpublishedChanges() (uint64, error) {
+	mark, err := is.loadPublishMark()
+	if err != nil {
+		return 0, err
+	}
+	var changes uint64
+	if is._stats.Claims > mark.Claims {
+		changes += is._stats.Claims - mark.Claims
+	}
+	if is._stats.Revocations > mark.Revocations {
+		changes += is._stats.Revocations - mark.Revocations
+	}
+	return changes, nil
+}
+
+// PublishDue returns true if the Config.PublishPolicy requires publishing the
+// identity state at now, or if a reorg reverted the last published one.  An
+// identity state pending to be confirmed is not taken into account.
+func (is *Issuer) PublishDue(now time.Time) (bool, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	if _, reverted, err := is.idenStateReverted(); err != nil || reverted {
+		return reverted, err
+	}
+	changes, err := is.unpublishedChanges()
+	if err != nil {
+		return false, err
+	}
+	if changes == 0 {
+		return false, nil
+	}
+	policy := is.cfg.PublishPolicy
+	if changes >= policy.MinChanges {
+		return true, nil
+	}
+	if policy.MaxDelay == 0 {
+		return false, nil
+	}
+	// Without a known last publication, the changes have waited enough.
+	lastPublishTs := is._stats.LastPublishTs
+	return lastPublishTs == 0 || now.Sub(time.Unix(lastPublishTs, 0)) >= policy.MaxDelay, nil
+}
+
+// PublishStateIfDue publishes the identity state with PublishState if the
+// Config.PublishPolicy requires it at now.  It returns nil if nothing was
+// due.
+func (is *Issuer) PublishStateIfDue(now time.Time) (*PublishStateResult, error) {
+	due, err := is.PublishDue(now)
+	if err != nil || !due {
+		return nil, err
+	}
+	return is.PublishState()
+}
+
+// AutoPublisher calls Issuer.PublishStateIfDue periodically in the background,
+// so that the identity state is published following the
+// Config.PublishPolicy.  The submitted identity states must still be
+// confirmed with Issuer.SyncIdenStatePublic before the next ones can be
+// published.
+type AutoPublisher struct {
+	is       *Issuer
+	clock    clock.Clock
+	interval time.Duration
+	stop     chan struct{}
+	wg       *sync.WaitGroup
+	once     *sync.Once
+}
+
+// StartAutoPublisher starts an AutoPublisher that evaluates the
+// Config.PublishPolicy every interval.
+func (is *Issuer) StartAutoPublisher(interval time.Duration) *AutoPublisher {
+	is.rw.RLock()
+	clk := is.clock
+	is.rw.RUnlock()
+	p := &AutoPublisher{
+		is:       is,
+		clock:    clk,
+		interval: interval,
+		stop:     make(chan struct{}),
+		wg:       &sync.WaitGroup{},
+		once:     &sync.Once{},
+	}
+	p.wg.Add(1)
+	go p.run()
+	return p
+}
+
+// Stop stops the AutoPublisher and waits for it to finish.
+func (p *AutoPublisher) Stop() {
+	p.once.Do(func() { close(p.stop) })
+	p.wg.Wait()
+}
+
+func (p *AutoPublisher) run() {
+	defer p.wg.Done()
+	ticker := p.clock.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C():
+			res, err := p.is.PublishStateIfDue(p.clock.Now())
+			if err != nil {
+				log.WithError(err).Error("AutoPublisher: publish failed")
+			} else if res != nil && res.Status == PublishStateSubmitted {
+				log.WithField("idenState", res.IdenState.Hex()).Info("AutoPublisher: identity state submitted")
+			}
+		case <-p.stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package issuer
+
+import (
+	"testing"
+	"time"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerPublishPolicy(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.cfg.PublishPolicy = PublishPolicy{MinChanges: 3, MaxDelay: time.Hour}
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	issuer.SetClock(clk)
+	genesisState, _ := issuer.state()
+
+	// The genesis claims are not changes.
+	changes, err := issuer.UnpublishedChanges()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), changes)
+	due, err := issuer.PublishDue(clk.Now())
+	require.Nil(t, err)
+	assert.False(t, due)
+
+	claim0, claim1 := newExpirationClaim(1), newExpirationClaim(2)
+	require.Nil(t, issuer.IssueClaim(claim0))
+	require.Nil(t, issuer.IssueClaim(claim1))
+	// Without a previous publication, any change is due after MaxDelay.
+	due, err = issuer.PublishDue(clk.Now())
+	require.Nil(t, err)
+	assert.True(t, due)
+
+	mockInitState(t, idenPubOnChain, issuer, genesisState)
+	res, err := issuer.PublishStateIfDue(clk.Now())
+	require.Nil(t, err)
+	assert.Equal(t, PublishStateSubmitted, res.Status)
+	changes, err = issuer.UnpublishedChanges()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(0), changes)
+	res, err = issuer.PublishStateIfDue(clk.Now())
+	require.Nil(t, err)
+	assert.Nil(t, res)
+
+	// Below MinChanges and MaxDelay nothing is due.
+	require.Nil(t, issuer.RevokeClaim(claim0))
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(3)))
+	changes, err = issuer.UnpublishedChanges()
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), changes)
+	clk.Advance(time.Hour - time.Second)
+	due, err = issuer.PublishDue(clk.Now())
+	require.Nil(t, err)
+	assert.False(t, due)
+	// MaxDelay elapsed.
+	due, err = issuer.PublishDue(clk.Now().Add(time.Second))
+	require.Nil(t, err)
+	assert.True(t, due)
+	// MinChanges accumulated.
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(4)))
+	due, err = issuer.PublishDue(clk.Now())
+	require.Nil(t, err)
+	assert.True(t, due)
+
+	// Without MaxDelay the changes wait for MinChanges.
+	issuer.cfg.PublishPolicy = PublishPolicy{MinChanges: 4}
+	due, err = issuer.PublishDue(clk.Now().Add(24 * time.Hour))
+	require.Nil(t, err)
+	assert.False(t, due)
+
+	// The zero policy publishes on every change.
+	issuer.cfg.PublishPolicy = PublishPolicy{}
+	due, err = issuer.PublishDue(clk.Now())
+	require.Nil(t, err)
+	assert.True(t, due)
+}
+
+func TestIssuerAutoPublisher(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.cfg.PublishPolicy = PublishPolicy{MinChanges: 2}
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	issuer.SetClock(clk)
+	genesisState, _ := issuer.state()
+
+	publisher := issuer.StartAutoPublisher(time.Minute)
+	clk.BlockUntil(1)
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(1)))
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(2)))
+	mockInitState(t, idenPubOnChain, issuer, genesisState)
+	clk.Advance(time.Minute)
+	for i := 0; i < 100; i++ {
+		if _, _, pending := issuer.PendingState(); pending {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	publisher.Stop()
+	publisher.Stop()
+
+	idenStatePending, _, pending := issuer.PendingState()
+	require.True(t, pending)
+	idenState, _ := issuer.State()
+	assert.Equal(t, idenState, idenStatePending)
+	idenPubOnChain.AssertExpectations(t)
+}
@@ -0,0 +1,114 @@
+package issuer
+
+import (
+	"context"
+
+	"github.com/iden3/go-iden3-core/components/idenpubonchain"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/db/migrations"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+// ReadOnly is a view of an Issuer that only exposes non-mutating operations.
+// It doesn't require a key store, so it can be used by frontends to serve
+// credentials from a replica of the Issuer storage (see package
+// db/replication) while a single writer process issues claims and publishes
+// the identity state.
+type ReadOnly struct {
+	is *Issuer
+}
+
+// LoadReadOnly creates a ReadOnly Issuer by loading a previously created
+// Issuer (with New) from the storage.  The storage is never written, so it
+// must have been migrated by the writer Issuer: it fails with
+// migrations.ErrVersionOutdated otherwise.
+func LoadReadOnly(storage db.Storage, idenPubOnChain idenpubonchain.IdenPubOnChainer) (*ReadOnly, error) {
+	if err := migrations.Check(storage, schemaMigrations); err != nil {
+		return nil, err
+	}
+	is, err := load(storage, nil, idenPubOnChain, nil)
+	if err != nil {
+		return nil, err
+	}
+	return &ReadOnly{is: is}, nil
+}
+
+// Reload updates the view with the latest values written in the storage by
+// the writer Issuer.
+func (ro *ReadOnly) Reload() error {
+	ro.is.rw.Lock()
+	defer ro.is.rw.Unlock()
+	clt, ret, rot, err := loadMTs(&ro.is.cfg, ro.is.storage)
+	if err != nil {
+		return err
+	}
+	ro.is.claimsTree, ro.is.revocationsTree, ro.is.rootsTree = clt, ret, rot
+	return ro.is.loadSyncState()
+}
+
+// ID returns the Issuer ID (Identity ID).
+func (ro *ReadOnly) ID() *core.ID {
+	return ro.is.ID()
+}
+
+// State calculates and returns the current Identity State and the three merkle tree roots.
+func (ro *ReadOnly) State() (*merkletree.Hash, IdenStateTreeRoots) {
+	return ro.is.State()
+}
+
+// StateDataOnChain returns the last known IdentityState Data known to be on chain.
+func (ro *ReadOnly) StateDataOnChain() *proof.IdenStateData {
+	return ro.is.StateDataOnChain()
+}
+
+// Stats returns the current Stats of the Issuer.
+func (ro *ReadOnly) Stats() *Stats {
+	return ro.is.Stats()
+}
+
+// ClaimByHIndex returns the claim entry found in the current Claims Merkle
+// Tree at the position hIndex.
+func (ro *ReadOnly) ClaimByHIndex(hIndex *merkletree.Hash) (*merkletree.Entry, error) {
+	return ro.is.ClaimByHIndex(hIndex)
+}
+
+// GenCredentialExistence generates an existence credential (claim + proof of
+// existence) of an issued claim.  See Issuer.GenCredentialExistence.  As the
+// storage is never written, the identity state of the credential is not
+// recorded as referenced, so it can be removed by the writer with
+// CompactStateHistory once it's no longer the state on chain.
+func (ro *ReadOnly) GenCredentialExistence(claim merkletree.Entrier) (*proof.CredentialExistence, error) {
+	return ro.GenCredentialExistenceCtx(context.Background(), claim)
+}
+
+// GenCredentialExistenceCtx is GenCredentialExistence with a context.  See
+// Issuer.GenCredentialExistenceCtx.
+func (ro *ReadOnly) GenCredentialExistenceCtx(ctx context.Context, claim merkletree.Entrier) (*proof.CredentialExistence, error) {
+	ro.is.rw.RLock()
+	defer ro.is.rw.RUnlock()
+	credExist, _, err := ro.is.genCredentialExistenceCtx(ctx, claim)
+	return credExist, err
+}
+
+// RefreshCredentialExistence generates an existence credential of the claim
+// at hIndex in the last identity state found on chain.  See
+// Issuer.RefreshCredentialExistence.  Like GenCredentialExistence, the
+// identity state of the credential is not recorded as referenced.
+func (ro *ReadOnly) RefreshCredentialExistence(hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	return ro.RefreshCredentialExistenceCtx(context.Background(), hIndex)
+}
+
+// RefreshCredentialExistenceCtx is RefreshCredentialExistence with a
+// context.  See Issuer.GenCredentialExistenceCtx.
+func (ro *ReadOnly) RefreshCredentialExistenceCtx(ctx context.Context, hIndex *merkletree.Hash) (*proof.CredentialExistence, error) {
+	ro.is.rw.RLock()
+	defer ro.is.rw.RUnlock()
+	claim, err := ro.is.claimOnChain(hIndex)
+	if err != nil {
+		return nil, err
+	}
+	credExist, _, err := ro.is.genCredentialExistenceCtx(ctx, entrier{claim})
+	return credExist, err
+}
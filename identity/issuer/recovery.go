@@ -0,0 +1,276 @@
+package issuer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+)
+
+var (
+	ErrRecovererNotSet      = fmt.Errorf("No recoverer configured")
+	ErrRecoveryPending      = fmt.Errorf("A key recovery is already pending")
+	ErrNoRecoveryPending    = fmt.Errorf("No key recovery pending")
+	ErrRecoveryTimelock     = fmt.Errorf("The key recovery timelock has not expired")
+	ErrInvalidRecoverySig   = fmt.Errorf("The key recovery is not signed by the recoverer")
+	ErrRecoveryKOpUnchanged = fmt.Errorf("The recovered key is the current kOp")
+	ErrRecoveryTimelockZero = fmt.Errorf("The key recovery requires a non-zero timelock")
+	ErrInvalidCancelSig     = fmt.Errorf("The key recovery cancellation is not signed by the kOp")
+)
+
+var (
+	dbKeyRecovery      = []byte("recovery")
+	dbKeyRecoveryNonce = []byte("recoverynonce")
+)
+
+var (
+	SigPrefixRecovery       = []byte("recovery:")
+	SigPrefixRecoveryCancel = []byte("recoverycancel:")
+)
+
+// Recovery is a pending recovery of the operational key of the Issuer.
+type Recovery struct {
+	// NewKOp is the operational key that replaces the current one when
+	// the recovery is completed.
+	NewKOp babyjub.PublicKeyComp
+	// NotBefore is the unix time after which the recovery can be
+	// completed.
+	NotBefore int64
+	// Nonce is the recovery nonce signed by the recoverer.
+	Nonce uint32
+}
+
+// RecoveryMsg returns the message that the recoverer signs (as an EIP-191
+// ethereum message) to initiate the recovery of the operational key of the
+// Issuer to newKOp.  nonce must be the RecoveryNonce of the Issuer, so that
+// the signature can't be replayed after the recovery is completed or
+// cancelled.
+func (is *Issuer) RecoveryMsg(newKOp *babyjub.PublicKeyComp, nonce uint32) []byte {
+	var nonceBytes [4]byte
+	binary.BigEndian.PutUint32(nonceBytes[:], nonce)
+	msg := append([]byte{}, SigPrefixRecovery...)
+	msg = append(msg, is.id[:]...)
+	msg = append(msg, newKOp[:]...)
+	return append(msg, nonceBytes[:]...)
+}
+
+// CancelRecoveryMsg returns the message that the holder of the current
+// operational key signs (see SignBinary, with the prefix
+// SigPrefixRecoveryCancel) to cancel recovery.
+func (is *Issuer) CancelRecoveryMsg(recovery *Recovery) []byte {
+	var nonceBytes [4]byte
+	binary.BigEndian.PutUint32(nonceBytes[:], recovery.Nonce)
+	msg := append([]byte{}, is.id[:]...)
+	msg = append(msg, recovery.NewKOp[:]...)
+	return append(msg, nonceBytes[:]...)
+}
+
+func (is *Issuer) recoveryNonce(tx db.Tx) (uint32, error) {
+	nonce, err := db.NewStorageValue(dbKeyRecoveryNonce).Get(tx)
+	if err == db.ErrNotFound {
+		return 0, nil
+	}
+	return nonce, err
+}
+
+func (is *Issuer) recovery(tx db.Tx) (*Recovery, error) {
+	recoveryJSON, err := tx.Get(dbKeyRecovery)
+	if err == db.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var recovery Recovery
+	if err := json.Unmarshal(recoveryJSON, &recovery); err != nil {
+		return nil, err
+	}
+	return &recovery, nil
+}
+
+// RecoveryNonce returns the nonce that the recoverer must sign in the next
+// recovery.
+func (is *Issuer) RecoveryNonce() (uint32, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	return is.recoveryNonce(tx)
+}
+
+// PendingRecovery returns the pending recovery of the operational key, or nil
+// if there is none.
+func (is *Issuer) PendingRecovery() (*Recovery, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	return is.recovery(tx)
+}
+
+// InitRecovery initiates the recovery of the operational key of the Issuer to
+// newKOp, with sig being the signature of RecoveryMsg by the configured
+// Recoverer.  The recovery can be completed with CompleteRecovery once the
+// configured RecoveryTimelock has passed, and until then the holder of the
+// current operational key can cancel it with CancelRecovery.
+func (is *Issuer) InitRecovery(newKOp *babyjub.PublicKeyComp, sig *crypto.SignatureEthMsg) (*Recovery, error) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	if is.cfg.Recoverer == nil {
+		return nil, ErrRecovererNotSet
+	}
+	if is.cfg.RecoveryTimelock <= 0 {
+		return nil, ErrRecoveryTimelockZero
+	}
+	if *newKOp == *is.kOp() {
+		return nil, ErrRecoveryKOpUnchanged
+	}
+	if _, err := newKOp.Decompress(); err != nil {
+		return nil, err
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if recovery, err := is.recovery(tx); err != nil {
+		return nil, err
+	} else if recovery != nil {
+		return nil, ErrRecoveryPending
+	}
+	nonce, err := is.recoveryNonce(tx)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := crypto.RecoverAddrEthMsg(sig, is.RecoveryMsg(newKOp, nonce))
+	if err != nil {
+		return nil, err
+	}
+	if addr != *is.cfg.Recoverer {
+		return nil, ErrInvalidRecoverySig
+	}
+
+	recovery := Recovery{
+		NewKOp:    *newKOp,
+		NotBefore: is.clock.Now().Add(is.cfg.RecoveryTimelock).Unix(),
+		Nonce:     nonce,
+	}
+	recoveryJSON, err := json.Marshal(recovery)
+	if err != nil {
+		return nil, err
+	}
+	tx.Put(dbKeyRecovery, recoveryJSON)
+	db.NewStorageValue(dbKeyRecoveryNonce).Set(tx, nonce+1)
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return &recovery, nil
+}
+
+// CancelRecovery cancels the pending recovery of the operational key, with
+// sig being the signature of its CancelRecoveryMsg by the current operational
+// key, so that only its holder can cancel a recovery.
+func (is *Issuer) CancelRecovery(sig *babyjub.SignatureComp) error {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	recovery, err := is.recovery(tx)
+	if err != nil {
+		return err
+	} else if recovery == nil {
+		return ErrNoRecoveryPending
+	}
+	msg := append(append([]byte{}, SigPrefixRecoveryCancel...), is.CancelRecoveryMsg(recovery)...)
+	if ok, err := keystore.VerifySignatureRaw(is.kOp(), sig, msg); err != nil {
+		return err
+	} else if !ok {
+		return ErrInvalidCancelSig
+	}
+	tx.Delete(dbKeyRecovery)
+	return tx.Commit()
+}
+
+// CompleteRecovery completes the pending recovery of the operational key
+// after its timelock: it issues a ClaimAuthorizeKSignBabyJub of the new
+// operational key, revokes the one of the current key, and signs with the new
+// key from then on, so the new key must be in the KeyStore.  The Identity
+// State is not updated.
+func (is *Issuer) CompleteRecovery() error {
+	if is.idenPubOnChain == nil {
+		return ErrIdenPubOnChainNil
+	}
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	recovery, err := is.recovery(tx)
+	if err != nil {
+		return err
+	} else if recovery == nil {
+		return ErrNoRecoveryPending
+	}
+	if is.clock.Now().Unix() < recovery.NotBefore {
+		return ErrRecoveryTimelock
+	}
+
+	kOp, err := is.kOp().Decompress()
+	if err != nil {
+		return err
+	}
+	data, err := is.claimsTree.GetDataByIndex(claims.NewClaimAuthorizeKSignBabyJub(kOp, 0).Entry().HIndex())
+	if err != nil {
+		return err
+	}
+	kOpRevocationNonce := claims.GetRevocationNonce(&merkletree.Entry{Data: *data})
+
+	newKOp, err := recovery.NewKOp.Decompress()
+	if err != nil {
+		return err
+	}
+	nonces, err := is.reserveNonces(1)
+	if err != nil {
+		return err
+	}
+	// The trees commit right away, so a previous call may have failed
+	// after adding the claim of the new kOp or revoking the current one:
+	// the recovery is completed anyway.
+	claimKOp := claims.NewClaimAuthorizeKSignBabyJub(newKOp, nonces[0])
+	if err := is.claimsTree.AddClaim(claimKOp); err != nil && err != merkletree.ErrEntryIndexAlreadyExists {
+		return err
+	}
+	if err := claims.AddLeafRevocationsTree(is.revocationsTree, kOpRevocationNonce,
+		claims.RevocationVersionAll); err != nil && err != merkletree.ErrEntryIndexAlreadyExists {
+		return err
+	}
+	if err := is.updateStats(tx, func(s *Stats) {
+		s.addClaim(claimKOp.Entry())
+		s.Revocations++
+	}); err != nil {
+		return err
+	}
+	tx.Put(dbKeyKOp, recovery.NewKOp[:])
+	tx.Delete(dbKeyRecovery)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	is.kOpComp.Store(&recovery.NewKOp)
+	return nil
+}
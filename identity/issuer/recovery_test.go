@@ -0,0 +1,123 @@
+package issuer
+
+import (
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/crypto"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/keystore"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerRecovery(t *testing.T) {
+	recovererKey, err := ethcrypto.HexToECDSA("da7079f082a1ced80c5dee3bf00752fd67f75321a637e5d5073ce1489af062d8")
+	require.Nil(t, err)
+	recoverer := ethcrypto.PubkeyToAddress(recovererKey.PublicKey)
+	sign := func(msg []byte) *crypto.SignatureEthMsg {
+		hash := crypto.EthHash(msg)
+		sig, err := ethcrypto.Sign(hash[:], recovererKey)
+		require.Nil(t, err)
+		sig[64] += 27
+		var sigEthMsg crypto.SignatureEthMsg
+		copy(sigEthMsg[:], sig)
+		return &sigEthMsg
+	}
+
+	cfg := ConfigDefault
+	cfg.Recoverer = &recoverer
+	storage := db.NewMemoryStorage()
+	ksStorage := keystore.MemStorage([]byte{})
+	keyStore, err := keystore.NewKeyStore(&ksStorage, keystore.LightKeyStoreParams)
+	require.Nil(t, err)
+	kOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(kOp, pass))
+	newKOp, err := keyStore.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, keyStore.UnlockKey(newKOp, pass))
+	idenPubOnChain := idenpubonchain.New()
+	_, err = New(cfg, kOp, []merkletree.Entrier{}, storage, keyStore, idenPubOnChain, nil)
+	assert.Equal(t, ErrRecoveryTimelockZero, err)
+	cfg.RecoveryTimelock = time.Hour
+	is, err := New(cfg, kOp, []merkletree.Entrier{}, storage, keyStore, idenPubOnChain, nil)
+	require.Nil(t, err)
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	is.SetClock(clk)
+
+	// Signature by another key
+	_, err = is.InitRecovery(newKOp, sign([]byte("other")))
+	assert.Equal(t, ErrInvalidRecoverySig, err)
+
+	recovery, err := is.InitRecovery(newKOp, sign(is.RecoveryMsg(newKOp, 0)))
+	require.Nil(t, err)
+	assert.Equal(t, *newKOp, recovery.NewKOp)
+	pending, err := is.PendingRecovery()
+	require.Nil(t, err)
+	assert.Equal(t, recovery, pending)
+	_, err = is.InitRecovery(newKOp, sign(is.RecoveryMsg(newKOp, 1)))
+	assert.Equal(t, ErrRecoveryPending, err)
+	assert.Equal(t, ErrRecoveryTimelock, is.CompleteRecovery())
+
+	// Only the kOp can cancel the recovery
+	cancelSig, err := keyStore.SignRaw(newKOp, append(append([]byte{}, SigPrefixRecoveryCancel...),
+		is.CancelRecoveryMsg(recovery)...))
+	require.Nil(t, err)
+	assert.Equal(t, ErrInvalidCancelSig, is.CancelRecovery(cancelSig))
+	cancelSig, err = is.SignBinary(SigPrefixRecoveryCancel, is.CancelRecoveryMsg(recovery))
+	require.Nil(t, err)
+
+	// A cancelled recovery can't be replayed
+	require.Nil(t, is.CancelRecovery(cancelSig))
+	assert.Equal(t, ErrNoRecoveryPending, is.CancelRecovery(cancelSig))
+	_, err = is.InitRecovery(newKOp, sign(is.RecoveryMsg(newKOp, 0)))
+	assert.Equal(t, ErrInvalidRecoverySig, err)
+
+	_, err = is.InitRecovery(newKOp, sign(is.RecoveryMsg(newKOp, 1)))
+	require.Nil(t, err)
+	clk.Advance(time.Hour - time.Second)
+	assert.Equal(t, ErrRecoveryTimelock, is.CompleteRecovery())
+	clk.Advance(time.Second)
+
+	// A call interrupted after adding the claim of the new kOp, which the
+	// claims tree commits right away, doesn't prevent the recovery.
+	newKOpPk, err := newKOp.Decompress()
+	require.Nil(t, err)
+	nonces, err := is.reserveNonces(1)
+	require.Nil(t, err)
+	require.Nil(t, is.claimsTree.AddClaim(claims.NewClaimAuthorizeKSignBabyJub(newKOpPk, nonces[0])))
+	require.Nil(t, is.CompleteRecovery())
+	assert.Equal(t, newKOp, is.kOp())
+	pending, err = is.PendingRecovery()
+	require.Nil(t, err)
+	assert.Nil(t, pending)
+
+	// The new kOp is authorized and the old one revoked
+	claimNewKOp, err := is.ClaimByHIndex(claims.NewClaimAuthorizeKSignBabyJub(newKOpPk, 0).Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, nonces[0], claims.GetRevocationNonce(claimNewKOp))
+	kOpPk, err := kOp.Decompress()
+	require.Nil(t, err)
+	claimKOp, err := is.ClaimByHIndex(claims.NewClaimAuthorizeKSignBabyJub(kOpPk, 0).Entry().HIndex())
+	require.Nil(t, err)
+	_, err = is.revocationsTree.GetDataByIndex(claims.HIndexLeafRevocationsTree(
+		claims.GetRevocationNonce(claimKOp), claims.RevocationVersionAll))
+	assert.Nil(t, err)
+
+	sig, err := is.SignBinary([]byte("prefix:"), []byte("msg"))
+	require.Nil(t, err)
+	ok, err := keystore.VerifySignatureRaw(newKOp, sig, []byte("prefix:msg"))
+	require.Nil(t, err)
+	assert.True(t, ok)
+
+	isLoad, err := load(storage, keyStore, idenPubOnChain, nil)
+	require.Nil(t, err)
+	assert.Equal(t, newKOp, isLoad.kOp())
+	assert.Equal(t, is.cfg.Recoverer, isLoad.cfg.Recoverer)
+}
@@ -0,0 +1,91 @@
+package issuer
+
+import (
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	log "github.com/sirupsen/logrus"
+)
+
+// A chain reorg can revert the identity states already synced by
+// SyncIdenStatePublic: the Smart Contract then returns an older identity
+// state than the one on chain.  When SyncIdenStatePublic finds it, it
+// recovers as follows:
+//
+//  1. The identity state on chain is reverted to the one in the Smart
+//     Contract, and the pending one, if any, is dropped, as its transition
+//     starts from a reverted state.
+//  2. The Hooks.OnStateReorged callback is called with a StateReorgedEvent.
+//  3. The next PublishState publishes the current identity state again, as
+//     a transition from the reverted identity state on chain, even if it
+//     hasn't changed since the last publication.  PublishDue returns true
+//     until then, so that the AutoPublisher does it.
+//
+// If the reverted transaction is mined again before the identity state is
+// published again, SyncIdenStatePublic takes it as the identity state on
+// chain.  Config.ConfirmationDepth makes the reorgs of the synced states
+// unlikely in the first place.
+
+// StateReorgedEvent is the payload of the Hooks.OnStateReorged callback.
+type StateReorgedEvent struct {
+	// Reverted is the identity state on chain reverted by the reorg.
+	Reverted proof.IdenStateData
+	// RevertedPending is the pending identity state dropped, if any.
+	RevertedPending *merkletree.Hash
+	// IdenStateData is the identity state in the Smart Contract after the
+	// reorg, which becomes the identity state on chain.
+	IdenStateData proof.IdenStateData
+}
+
+// revertIdenStateOnChain sets idenStateData as the identity state on chain
+// after a reorg reverted the previous one, and drops the pending one.  The
+// caller must hold the write lock.
+func (is *Issuer) revertIdenStateOnChain(idenStateData *proof.IdenStateData) (*StateReorgedEvent, error) {
+	event := &StateReorgedEvent{Reverted: *is.idenStateDataOnChain(), IdenStateData: *idenStateData}
+	if idenStatePending := is.idenStatePending(); !idenStatePending.Equals(&merkletree.HashZero) {
+		event.RevertedPending = idenStatePending
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	is.setIdenStatePending(tx, &merkletree.HashZero)
+	if err := is.setIdenStateDataOnChain(tx, idenStateData); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	log.WithField("reverted", event.Reverted.IdenState.Hex()).
+		WithField("idenState", idenStateData.IdenState.Hex()).
+		Warn("Identity state on chain reverted by a reorg")
+	return event, nil
+}
+
+// idenStateReverted returns the last published identity state, and true if
+// it's neither on chain nor pending because a reorg reverted it, so that it
+// must be published again.  The caller must hold the lock.
+func (is *Issuer) idenStateReverted() (*merkletree.Hash, bool, error) {
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Close()
+	idenStateListLen, err := is.idenStateList.Length(tx)
+	if err != nil {
+		return nil, false, err
+	}
+	idenStateLast, _, err := is.getIdenStateByIdx(tx, idenStateListLen-1)
+	if err != nil {
+		return nil, false, err
+	}
+	if !is.idenStatePending().Equals(&merkletree.HashZero) {
+		return idenStateLast, false, nil
+	}
+	// Before the first publication on chain, the last identity state is
+	// the genesis one.
+	if is.idenStateOnChain().Equals(&merkletree.HashZero) {
+		return idenStateLast, idenStateListLen > 1, nil
+	}
+	return idenStateLast, !idenStateLast.Equals(is.idenStateOnChain()), nil
+}
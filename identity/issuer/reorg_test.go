@@ -0,0 +1,126 @@
+package issuer
+
+import (
+	"testing"
+	"time"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/proof"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerConfirmationDepth(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.cfg.ConfirmationDepth = 2
+	genesisState, _ := issuer.state()
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(1)))
+
+	_, newState := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err := issuer.PublishState()
+	require.Nil(t, err)
+
+	// Found at block 10, but with a single confirmation.
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{BlockN: 10, IdenState: newState}, nil).Once()
+	idenPubOnChain.On("BlockNumber").Return(uint64(11), nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	assert.Equal(t, newState, issuer.idenStatePending())
+	assert.Equal(t, &merkletree.HashZero, issuer.idenStateOnChain())
+
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{BlockN: 10, IdenState: newState}, nil).Once()
+	idenPubOnChain.On("BlockNumber").Return(uint64(12), nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	assert.Equal(t, &merkletree.HashZero, issuer.idenStatePending())
+	assert.Equal(t, newState, issuer.idenStateOnChain())
+	idenPubOnChain.AssertExpectations(t)
+}
+
+func TestIssuerReorg(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	var reorgs []*StateReorgedEvent
+	issuer, _, _ := newIssuerWithHooks(t, idenPubOnChain, &Hooks{
+		OnStateReorged: func(ev *StateReorgedEvent) { reorgs = append(reorgs, ev) },
+	})
+	genesisState, _ := issuer.state()
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(1)))
+
+	_, state1 := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err := issuer.PublishState()
+	require.Nil(t, err)
+	state1Data := &proof.IdenStateData{BlockN: 10, IdenState: state1}
+	idenPubOnChain.On("GetState", issuer.id).Return(state1Data, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(2)))
+	_, state2 := mockSetState(t, idenPubOnChain, issuer, state1)
+	_, err = issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{BlockN: 20, IdenState: state2}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	assert.Equal(t, state2, issuer.idenStateOnChain())
+	due, err := issuer.PublishDue(time.Now())
+	require.Nil(t, err)
+	assert.False(t, due)
+	publishedStates := issuer.Stats().PublishedStates
+
+	// The block 20 is reorganized: the Smart Contract is back to state1.
+	idenPubOnChain.On("GetState", issuer.id).Return(state1Data, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	require.Equal(t, 1, len(reorgs))
+	assert.Equal(t, state2, reorgs[0].Reverted.IdenState)
+	assert.Nil(t, reorgs[0].RevertedPending)
+	assert.Equal(t, *state1Data, reorgs[0].IdenStateData)
+	assert.Equal(t, state1, issuer.idenStateOnChain())
+	assert.Equal(t, &merkletree.HashZero, issuer.idenStatePending())
+	due, err = issuer.PublishDue(time.Now())
+	require.Nil(t, err)
+	assert.True(t, due)
+
+	// state2 is published again as a transition from state1.
+	_, newState := mockSetState(t, idenPubOnChain, issuer, state1)
+	assert.Equal(t, state2, newState)
+	res, err := issuer.PublishState()
+	require.Nil(t, err)
+	assert.Equal(t, PublishStateSubmitted, res.Status)
+	assert.Equal(t, state2, res.IdenState)
+	assert.Equal(t, publishedStates, issuer.Stats().PublishedStates)
+
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{BlockN: 21, IdenState: state2}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	assert.Equal(t, state2, issuer.idenStateOnChain())
+	res, err = issuer.PublishState()
+	require.Nil(t, err)
+	assert.Equal(t, PublishStateNoChanges, res.Status)
+	idenPubOnChain.AssertExpectations(t)
+}
+
+func TestIssuerReorgMinedAgain(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	genesisState, _ := issuer.state()
+	require.Nil(t, issuer.IssueClaim(newExpirationClaim(1)))
+
+	// The first publication is reverted, and the transaction is mined
+	// again before the identity state is published again.
+	_, state1 := mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err := issuer.PublishState()
+	require.Nil(t, err)
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{BlockN: 10, IdenState: state1}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{IdenState: &merkletree.HashZero}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	assert.Equal(t, &merkletree.HashZero, issuer.idenStateOnChain())
+	due, err := issuer.PublishDue(time.Now())
+	require.Nil(t, err)
+	assert.True(t, due)
+
+	idenPubOnChain.On("GetState", issuer.id).Return(&proof.IdenStateData{BlockN: 11, IdenState: state1}, nil).Once()
+	require.Nil(t, issuer.SyncIdenStatePublic())
+	assert.Equal(t, state1, issuer.idenStateOnChain())
+	due, err = issuer.PublishDue(time.Now())
+	require.Nil(t, err)
+	assert.False(t, due)
+	idenPubOnChain.AssertExpectations(t)
+}
@@ -0,0 +1,139 @@
+package issuer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	ErrReservationNotFound = fmt.Errorf("Claim reservation not found or already issued")
+	ErrReservationMismatch = fmt.Errorf("The claim doesn't fill the reservation")
+)
+
+var (
+	// dbPrefixReservation is the prefix of the claim reservations:
+	// dbPrefixReservation | big endian revocation nonce -> Reservation.
+	dbPrefixReservation = []byte("reservation:")
+)
+
+// Reservation is a slot reserved for a claim that is not final yet, so that
+// the claim can be referenced before it's issued.  The claim that fills the
+// reservation must have its ClaimType and RevocationNonce.
+type Reservation struct {
+	ClaimType       claims.ClaimType
+	RevocationNonce uint32
+	// Reference identifies the claim before it's issued: the ID of the
+	// issuer and the revocation nonce, which is unique per issuer.
+	Reference string
+	CreatedTs int64
+}
+
+func reservationKey(nonce uint32) []byte {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], nonce)
+	return append(append([]byte{}, dbPrefixReservation...), b[:]...)
+}
+
+// ReserveClaimSlot reserves a new unique revocation nonce for a claim of
+// claimType that will be issued later with IssueReserved.
+func (is *Issuer) ReserveClaimSlot(claimType *claims.ClaimType) (*Reservation, error) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	nonce, err := is.nonceGen.Next(tx)
+	if err != nil {
+		return nil, err
+	}
+	reservation := &Reservation{
+		ClaimType:       *claimType,
+		RevocationNonce: nonce,
+		Reference:       is.id.String() + ":" + strconv.FormatUint(uint64(nonce), 10),
+		CreatedTs:       is.clock.Now().Unix(),
+	}
+	if err := db.StoreJSON(tx, reservationKey(nonce), reservation); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return reservation, nil
+}
+
+// Reservation returns the pending reservation of the revocation nonce, or
+// ErrReservationNotFound.
+func (is *Issuer) Reservation(revocationNonce uint32) (*Reservation, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	return is.reservation(revocationNonce)
+}
+
+func (is *Issuer) reservation(revocationNonce uint32) (*Reservation, error) {
+	b, err := is.storage.Get(reservationKey(revocationNonce))
+	if err == db.ErrNotFound {
+		return nil, ErrReservationNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	var reservation Reservation
+	if err := json.Unmarshal(b, &reservation); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// IssueReserved issues claim like IssueClaim, filling the pending
+// reservation, which can't be filled again.  The claim must have the
+// ClaimType and RevocationNonce of the reservation, or ErrReservationMismatch
+// is returned.  It returns the issued claim, which has a bumped version if
+// the configured DuplicatePolicy is DuplicateBumpVersion and the claim was
+// already issued.
+func (is *Issuer) IssueReserved(reservation *Reservation, claim merkletree.Entrier) (merkletree.Entrier, error) {
+	if is.idenPubOnChain == nil {
+		return nil, ErrIdenPubOnChainNil
+	}
+	var event *ClaimIssuedEvent
+	defer func() { is.hooks.claimIssued(event) }()
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	stored, err := is.reservation(reservation.RevocationNonce)
+	if err != nil {
+		return nil, err
+	}
+	claimType, _ := claims.GetClaimTypeVersion(claim.Entry())
+	if claimType != stored.ClaimType || claims.GetRevocationNonce(claim.Entry()) != stored.RevocationNonce {
+		return nil, ErrReservationMismatch
+	}
+	if claim, err = is.resolveDuplicate(claim); err != nil {
+		return nil, err
+	}
+	if err := is.validate(claim); err != nil {
+		return nil, err
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Close()
+	if err := is.claimsTree.AddClaim(claim); err != nil {
+		return nil, err
+	}
+	tx.Delete(reservationKey(stored.RevocationNonce))
+	if err := is.updateStats(tx, func(s *Stats) { s.addClaim(claim.Entry()) }); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	event = &ClaimIssuedEvent{Claim: claim.Entry()}
+	return claim, nil
+}
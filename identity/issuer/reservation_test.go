@@ -0,0 +1,59 @@
+package issuer
+
+import (
+	"testing"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-crypto/babyjub"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerReserveClaimSlot(t *testing.T) {
+	issuer, storage, keyStore := newIssuer(t, idenpubonchain.New())
+
+	reservation, err := issuer.ReserveClaimSlot(claims.ClaimTypeBasic)
+	require.Nil(t, err)
+	assert.Equal(t, *claims.ClaimTypeBasic, reservation.ClaimType)
+	assert.Equal(t, issuer.ID().String()+":1", reservation.Reference)
+	reservation2, err := issuer.ReserveClaimSlot(claims.ClaimTypeBasic)
+	require.Nil(t, err)
+	assert.NotEqual(t, reservation.RevocationNonce, reservation2.RevocationNonce)
+
+	// The reservations are persisted.
+	issuerLoad, err := Load(storage, keyStore, nil, nil)
+	require.Nil(t, err)
+	stored, err := issuerLoad.Reservation(reservation.RevocationNonce)
+	require.Nil(t, err)
+	assert.Equal(t, reservation, stored)
+
+	var index [claims.IndexSlotBytes]byte
+	var data [claims.DataSlotBytes]byte
+	index[0] = 1
+	// The claim must have the nonce and the type of the reservation.
+	_, err = issuer.IssueReserved(reservation, claims.NewClaimBasic(index, data, reservation2.RevocationNonce))
+	assert.Equal(t, ErrReservationMismatch, err)
+	_, err = issuer.IssueReserved(reservation, newClaimOtherType(reservation.RevocationNonce))
+	assert.Equal(t, ErrReservationMismatch, err)
+
+	claim := claims.NewClaimBasic(index, data, reservation.RevocationNonce)
+	issued, err := issuer.IssueReserved(reservation, claim)
+	require.Nil(t, err)
+	assert.Equal(t, claim.Entry().HIndex(), issued.Entry().HIndex())
+	_, err = issuer.claimsTree.GetDataByIndex(claim.Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, uint64(2), issuer.Stats().Claims)
+
+	// A reservation is filled once.
+	index[0] = 2
+	_, err = issuer.IssueReserved(reservation, claims.NewClaimBasic(index, data, reservation.RevocationNonce))
+	assert.Equal(t, ErrReservationNotFound, err)
+	_, err = issuer.Reservation(reservation.RevocationNonce)
+	assert.Equal(t, ErrReservationNotFound, err)
+}
+
+func newClaimOtherType(nonce uint32) *claims.ClaimAuthorizeKSignBabyJub {
+	sk := babyjub.NewRandPrivKey()
+	return claims.NewClaimAuthorizeKSignBabyJub(sk.Public(), nonce)
+}
@@ -0,0 +1,43 @@
+package issuer
+
+import (
+	"encoding/json"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/db/migrations"
+)
+
+// schemaMigrations are the migrations of the Issuer storage layout, run by
+// Load.  The storages created by New have the latest schema version.
+var schemaMigrations = []migrations.Migration{
+	{
+		Version:     1,
+		Description: "store the metadata of the merkle trees",
+		Migrate: func(storage db.Storage) error {
+			var cfg Config
+			cfgJSON, err := storage.Get(dbKeyConfig)
+			if err != nil {
+				return err
+			}
+			if err := json.Unmarshal(cfgJSON, &cfg); err != nil {
+				return err
+			}
+			// Opening the trees with the configured maxLevels
+			// stores their metadata.
+			_, _, _, err = loadMTs(&cfg, storage)
+			return err
+		},
+	},
+}
+
+// SchemaVersion is the version of the Issuer storage layout.
+var SchemaVersion = migrations.Latest(schemaMigrations)
+
+// Migrate upgrades the layout of the Issuer storage to SchemaVersion, calling
+// backup before the first pending migration.  Load migrates the storage with
+// migrations.SelfBackup, so Migrate is only needed to backup the storage
+// elsewhere.
+func Migrate(storage db.Storage, backup migrations.Backup) error {
+	_, err := migrations.Run(storage, schemaMigrations, backup)
+	return err
+}
@@ -0,0 +1,58 @@
+package issuer
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db/migrations"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadMigrate(t *testing.T) {
+	issuer, storage, keyStore := newIssuer(t, nil)
+	version, err := migrations.Version(storage)
+	require.Nil(t, err)
+	assert.Equal(t, SchemaVersion, version)
+
+	// Turn the storage into one created before the schema version and
+	// the tree metadata were persisted.
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	tx.Delete([]byte("schemaversion"))
+	for _, prefix := range [][]byte{dbPrefixClaimsTree, dbPrefixRevocationTree, dbPrefixRootsTree} {
+		tx.Delete(append(append([]byte{}, prefix...), []byte("metadata")...))
+	}
+	require.Nil(t, tx.Commit())
+	_, err = merkletree.ReadMetadata(storage.WithPrefix(dbPrefixClaimsTree))
+	require.Equal(t, merkletree.ErrMetadataNotFound, err)
+
+	_, err = LoadReadOnly(storage, nil)
+	assert.Equal(t, migrations.ErrVersionOutdated, err)
+
+	issuerLoad, err := Load(storage, keyStore, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, issuer.id, issuerLoad.id)
+	version, err = migrations.Version(storage)
+	require.Nil(t, err)
+	assert.Equal(t, SchemaVersion, version)
+	md, err := merkletree.ReadMetadata(storage.WithPrefix(dbPrefixClaimsTree))
+	require.Nil(t, err)
+	assert.Equal(t, issuer.cfg.MaxLevelsClaimsTree, md.MaxLevels)
+
+	// The previous version is backed up
+	_, err = storage.Get([]byte("backup:v0:" + string(dbKeyId)))
+	assert.Nil(t, err)
+	_, err = LoadReadOnly(storage, nil)
+	assert.Nil(t, err)
+}
+
+func TestLoadSchemaTooNew(t *testing.T) {
+	_, storage, keyStore := newIssuer(t, nil)
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	migrations.SetVersion(tx, SchemaVersion+1)
+	require.Nil(t, tx.Commit())
+	_, err = Load(storage, keyStore, nil, nil)
+	assert.Equal(t, migrations.ErrVersionTooNew, err)
+}
@@ -0,0 +1,156 @@
+package issuer
+
+import (
+	"encoding/json"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	dbKeyStats = []byte("stats")
+)
+
+// Stats are the counters of the Issuer activity, for dashboards and metrics.
+// They are updated incrementally on every change, so getting them is cheap.
+// The number of leaves of each tree gives its size.
+type Stats struct {
+	// Claims is the number of leaves of the claims tree: the issued
+	// claims, including the genesis ones.
+	Claims uint64 `json:"claims"`
+	// ClaimsByType is the number of Claims by hex encoded claim type.
+	ClaimsByType map[string]uint64 `json:"claimsByType"`
+	// Revocations is the number of leaves of the revocations tree.
+	Revocations uint64 `json:"revocations"`
+	// Roots is the number of leaves of the roots tree.
+	Roots uint64 `json:"roots"`
+	// PublishedStates is the number of identity states submitted to the
+	// Smart Contract.
+	PublishedStates uint64 `json:"publishedStates"`
+	// LastPublishTs is the unix time of the last identity state
+	// submission, or 0 if no state has been published.
+	LastPublishTs int64 `json:"lastPublishTs"`
+}
+
+func (s *Stats) copy() *Stats {
+	c := *s
+	c.ClaimsByType = make(map[string]uint64, len(s.ClaimsByType))
+	for k, v := range s.ClaimsByType {
+		c.ClaimsByType[k] = v
+	}
+	return &c
+}
+
+// addClaim counts the claim e.
+func (s *Stats) addClaim(e *merkletree.Entry) {
+	claimType, _ := claims.GetClaimTypeVersion(e)
+	s.Claims++
+	s.ClaimsByType[common3.HexEncode(claimType[:])]++
+}
+
+// Stats returns the current Stats of the Issuer.
+func (is *Issuer) Stats() *Stats {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	return is._stats.copy()
+}
+
+func (is *Issuer) setStats(tx db.Tx, v *Stats) error {
+	is._stats = v
+	return db.StoreJSON(tx, dbKeyStats, v)
+}
+
+// updateStats applies f to a copy of the stats and stores it in tx.  The
+// caller must hold the write lock.
+func (is *Issuer) updateStats(tx db.Tx, f func(*Stats)) error {
+	stats := is._stats.copy()
+	f(stats)
+	return is.setStats(tx, stats)
+}
+
+// commitStats applies f to the stats in a new transaction, for the changes
+// that are only stored in the merkle trees.  The caller must hold the write
+// lock.
+func (is *Issuer) commitStats(f func(*Stats)) error {
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	if err := is.updateStats(tx, f); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// loadStats loads the stored stats.  The stats of Issuers created before
+// they were introduced are computed once from the trees and the idenState
+// list, without storing them so that the ReadOnly Issuer doesn't write; the
+// last publish time is unknown in that case.
+func (is *Issuer) loadStats() error {
+	statsJSON, err := is.storage.Get(dbKeyStats)
+	if err == db.ErrNotFound {
+		return is.rebuildStats()
+	} else if err != nil {
+		return err
+	}
+	stats := &Stats{}
+	if err := json.Unmarshal(statsJSON, stats); err != nil {
+		return err
+	}
+	if stats.ClaimsByType == nil {
+		stats.ClaimsByType = make(map[string]uint64)
+	}
+	is._stats = stats
+	return nil
+}
+
+func (is *Issuer) rebuildStats() error {
+	stats, err := is.scanStats()
+	if err != nil {
+		return err
+	}
+	tx, err := is.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	idenStateListLen, err := is.idenStateList.Length(tx)
+	if err != nil {
+		return err
+	}
+	// The first state of the list is the genesis one, and compacted
+	// states are not counted.
+	if idenStateListLen > 0 {
+		stats.PublishedStates = uint64(idenStateListLen - 1)
+	}
+	is._stats = stats
+	return nil
+}
+
+// scanStats counts the leaves of the trees.
+func (is *Issuer) scanStats() (*Stats, error) {
+	stats := &Stats{ClaimsByType: make(map[string]uint64)}
+	if err := is.claimsTree.Walk(nil, func(n *merkletree.Node) {
+		if n.Type == merkletree.NodeTypeLeaf {
+			stats.addClaim(n.Entry)
+		}
+	}); err != nil {
+		return nil, err
+	}
+	for _, t := range []struct {
+		mt    *merkletree.MerkleTree
+		count *uint64
+	}{{is.revocationsTree, &stats.Revocations}, {is.rootsTree, &stats.Roots}} {
+		if err := t.mt.Walk(nil, func(n *merkletree.Node) {
+			if n.Type == merkletree.NodeTypeLeaf {
+				*t.count++
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return stats, nil
+}
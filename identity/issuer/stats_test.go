@@ -0,0 +1,76 @@
+package issuer
+
+import (
+	"testing"
+	"time"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerStats(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, storage, keyStore := newIssuer(t, idenPubOnChain)
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	issuer.SetClock(clk)
+	typeBasic := common3.HexEncode(claims.ClaimTypeBasic[:])
+	typeKSign := common3.HexEncode(claims.ClaimTypeAuthorizeKSignBabyJub[:])
+
+	stats := issuer.Stats()
+	assert.Equal(t, &Stats{
+		Claims:       1,
+		ClaimsByType: map[string]uint64{typeKSign: 1},
+		Roots:        1,
+	}, stats)
+
+	genesisState, _ := issuer.state()
+	claim0, claim1 := newExpirationClaim(1), newExpirationClaim(2)
+	require.Nil(t, issuer.IssueClaim(claim0))
+	require.Nil(t, issuer.IssueClaim(claim1))
+	require.Nil(t, issuer.RevokeClaim(claim0))
+	// The returned Stats are a copy.
+	stats.ClaimsByType[typeBasic] = 10
+
+	mockInitState(t, idenPubOnChain, issuer, genesisState)
+	_, err := issuer.PublishState()
+	require.Nil(t, err)
+
+	stats = issuer.Stats()
+	assert.Equal(t, uint64(3), stats.Claims)
+	assert.Equal(t, map[string]uint64{typeKSign: 1, typeBasic: 2}, stats.ClaimsByType)
+	assert.Equal(t, uint64(1), stats.Revocations)
+	assert.Equal(t, uint64(1), stats.Roots)
+	assert.Equal(t, uint64(1), stats.PublishedStates)
+	assert.Equal(t, clk.Now().Unix(), stats.LastPublishTs)
+
+	// The incremental Stats match the ones computed from the trees.
+	scanned, err := issuer.scanStats()
+	require.Nil(t, err)
+	assert.Equal(t, stats.Claims, scanned.Claims)
+	assert.Equal(t, stats.ClaimsByType, scanned.ClaimsByType)
+	assert.Equal(t, stats.Revocations, scanned.Revocations)
+	assert.Equal(t, stats.Roots, scanned.Roots)
+
+	issuerLoad, err := Load(storage, keyStore, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, stats, issuerLoad.Stats())
+
+	// Storages without stored Stats get them computed at load.
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	tx.Delete(dbKeyStats)
+	require.Nil(t, tx.Commit())
+	_, err = storage.Get(dbKeyStats)
+	require.Equal(t, db.ErrNotFound, err)
+	ro, err := LoadReadOnly(storage, nil)
+	require.Nil(t, err)
+	stats.LastPublishTs = 0
+	assert.Equal(t, stats, ro.Stats())
+	_, err = storage.Get(dbKeyStats)
+	assert.Equal(t, db.ErrNotFound, err)
+}
@@ -0,0 +1,386 @@
+package issuer
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"time"
+
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	ErrTemplateNotFound     = fmt.Errorf("Claim template not found")
+	ErrTemplateInvalid      = fmt.Errorf("Invalid claim template")
+	ErrTemplateData         = fmt.Errorf("Invalid claim template data")
+	ErrTemplateSubjectUnset = fmt.Errorf("The claim template requires a subject")
+)
+
+// Encodings of the values of the fields of a ClaimTemplate.
+const (
+	// FieldString stores a string of up to 31 bytes (see
+	// claims.SlotFromString).
+	FieldString = "string"
+	// FieldHash stores the hash of a string of any length (see
+	// claims.HashString).
+	FieldHash = "hash"
+	// FieldInt stores a non-negative integer, given as a JSON number or a
+	// decimal string (see claims.SlotFromBigInt).
+	FieldInt = "int"
+	// FieldBool stores a boolean as 0 or 1, given as a JSON boolean or a
+	// string like "true" (see strconv.ParseBool).
+	FieldBool = "bool"
+	// FieldTime stores a time, given as an RFC 3339 string or unix
+	// seconds (see claims.SlotFromTime).
+	FieldTime = "time"
+)
+
+// templateSlots are the claim elements where the fields and the subject of a
+// ClaimTemplate can be stored, by name.  The first element of the index holds
+// the claim type and version, and the first element of the data holds the
+// revocation nonce.
+var templateSlots = map[string]int{
+	"index:0": 1, "index:1": 2, "index:2": 3,
+	"data:0": 5, "data:1": 6, "data:2": 7,
+}
+
+// TemplateField maps a field of the JSON data of an issuance to a slot of the
+// claim.
+type TemplateField struct {
+	// Name is the key of the field in the data.
+	Name string `json:"name"`
+	// Slot is the claim element of the value: "index:0" to "index:2" or
+	// "data:0" to "data:2".  The fields in the index slots identify the
+	// claim, while the fields in the data slots can be updated.
+	Slot string `json:"slot"`
+	// Encoding is the encoding of the value: FieldString, FieldHash,
+	// FieldInt, FieldBool or FieldTime.
+	Encoding string `json:"encoding"`
+	// Optional allows the field to be missing from the data, in which case
+	// the slot is left empty.
+	Optional bool `json:"optional,omitempty"`
+}
+
+// TemplateExpiration is the expiration policy of the claims issued from a
+// ClaimTemplate.  If both TTL and Field are set, the earliest expiration
+// applies.
+type TemplateExpiration struct {
+	// TTL is the lifetime of the claims since their issuance, like
+	// "8760h".
+	TTL string `json:"ttl,omitempty"`
+	// Field is the name of a FieldTime field of the data with the
+	// expiration.
+	Field string `json:"field,omitempty"`
+}
+
+// ClaimTemplate defines how to build a claim from the JSON data of an
+// issuance, so that issuers can offer typical credentials from their
+// configuration without writing Go code for each one.  For example:
+//
+//	{
+//	  "id": "membership",
+//	  "claimType": "acme.membership",
+//	  "subject": "index:0",
+//	  "fields": [
+//	    {"name": "level", "slot": "index:1", "encoding": "string"},
+//	    {"name": "since", "slot": "data:0", "encoding": "time"}
+//	  ],
+//	  "expiration": {"ttl": "8760h"}
+//	}
+//
+// The claims have the type claims.NewClaimType(ClaimType), and hold the
+// revocation nonce in the first data element like the rest of the claims.
+type ClaimTemplate struct {
+	// ID is the identifier of the template used in IssueFromTemplate.
+	ID string `json:"id"`
+	// ClaimType is the name of the claim type.
+	ClaimType string `json:"claimType"`
+	// Version is the version of the claims.
+	Version uint32 `json:"version,omitempty"`
+	// Subject is the slot of the identity the claim is about, or empty if
+	// the claim is not bound to an identity.
+	Subject string `json:"subject,omitempty"`
+	// Fields are the values of the claim taken from the data.
+	Fields []TemplateField `json:"fields"`
+	// Expiration is the expiration policy, or nil if the claims don't
+	// expire.
+	Expiration *TemplateExpiration `json:"expiration,omitempty"`
+	// ttl is the parsed Expiration.TTL, set by Validate.
+	ttl time.Duration
+}
+
+// Validate checks that the template is well formed: the slots and encodings
+// are known, and each slot is used at most once.
+func (t *ClaimTemplate) Validate() error {
+	if t.ID == "" || t.ClaimType == "" {
+		return fmt.Errorf("%w: id and claimType are required", ErrTemplateInvalid)
+	}
+	used := map[string]bool{}
+	useSlot := func(slot string) error {
+		if _, ok := templateSlots[slot]; !ok {
+			return fmt.Errorf("%w %v: unknown slot %q", ErrTemplateInvalid, t.ID, slot)
+		} else if used[slot] {
+			return fmt.Errorf("%w %v: slot %q used twice", ErrTemplateInvalid, t.ID, slot)
+		}
+		used[slot] = true
+		return nil
+	}
+	if t.Subject != "" {
+		if err := useSlot(t.Subject); err != nil {
+			return err
+		}
+	}
+	fields := map[string]*TemplateField{}
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		if f.Name == "" {
+			return fmt.Errorf("%w %v: field %d without name", ErrTemplateInvalid, t.ID, i)
+		}
+		switch f.Encoding {
+		case FieldString, FieldHash, FieldInt, FieldBool, FieldTime:
+		default:
+			return fmt.Errorf("%w %v: unknown encoding %q", ErrTemplateInvalid, t.ID, f.Encoding)
+		}
+		if err := useSlot(f.Slot); err != nil {
+			return err
+		}
+		fields[f.Name] = f
+	}
+	t.ttl = 0
+	if t.Expiration != nil {
+		if t.Expiration.TTL != "" {
+			ttl, err := time.ParseDuration(t.Expiration.TTL)
+			if err != nil || ttl <= 0 {
+				return fmt.Errorf("%w %v: invalid expiration ttl %q", ErrTemplateInvalid, t.ID, t.Expiration.TTL)
+			}
+			t.ttl = ttl
+		}
+		if t.Expiration.Field != "" {
+			if f, ok := fields[t.Expiration.Field]; !ok || f.Encoding != FieldTime {
+				return fmt.Errorf("%w %v: expiration field %q is not a time field",
+					ErrTemplateInvalid, t.ID, t.Expiration.Field)
+			}
+		}
+	}
+	return nil
+}
+
+// ParseClaimTemplates parses and validates a JSON array of ClaimTemplates.
+func ParseClaimTemplates(templatesJSON []byte) ([]*ClaimTemplate, error) {
+	var templates []*ClaimTemplate
+	if err := json.Unmarshal(templatesJSON, &templates); err != nil {
+		return nil, err
+	}
+	for _, t := range templates {
+		if err := t.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return templates, nil
+}
+
+// encodeField encodes the value of the field f in the data of an issuance.
+func encodeField(f *TemplateField, v interface{}) (claims.Slot, error) {
+	switch f.Encoding {
+	case FieldString:
+		if s, ok := v.(string); ok {
+			return claims.SlotFromString(s)
+		}
+	case FieldHash:
+		if s, ok := v.(string); ok {
+			return claims.Slot(claims.HashString(s)), nil
+		}
+	case FieldInt:
+		var n *big.Int
+		switch v := v.(type) {
+		case float64:
+			if v == float64(int64(v)) {
+				n = big.NewInt(int64(v))
+			}
+		case json.Number:
+			n, _ = new(big.Int).SetString(string(v), 10)
+		case string:
+			n, _ = new(big.Int).SetString(v, 10)
+		}
+		if n != nil {
+			return claims.SlotFromBigInt(n)
+		}
+	case FieldBool:
+		if s, ok := v.(string); ok {
+			if b, err := strconv.ParseBool(s); err == nil {
+				v = b
+			}
+		}
+		if b, ok := v.(bool); ok {
+			var slot claims.Slot
+			if b {
+				slot[0] = 1
+			}
+			return slot, nil
+		}
+	case FieldTime:
+		if t, err := parseTime(v); err == nil {
+			return claims.SlotFromTime(t)
+		}
+	}
+	return claims.Slot{}, fmt.Errorf("%w: field %v is not a valid %v", ErrTemplateData, f.Name, f.Encoding)
+}
+
+func parseTime(v interface{}) (time.Time, error) {
+	switch v := v.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339, v); err == nil {
+			return t, nil
+		}
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0), nil
+	case float64:
+		return time.Unix(int64(v), 0), nil
+	case json.Number:
+		n, err := v.Int64()
+		if err != nil {
+			return time.Time{}, err
+		}
+		return time.Unix(n, 0), nil
+	}
+	return time.Time{}, ErrTemplateData
+}
+
+// NewClaim builds the claim of the template for subject with the values in
+// data.  subject can be nil if the template doesn't have a Subject.
+func (t *ClaimTemplate) NewClaim(subject *core.ID, data map[string]interface{}, revocationNonce uint32) (merkletree.Entrier, error) {
+	e := &merkletree.Entry{}
+	claims.SetClaimTypeVersion(e, *claims.NewClaimType(t.ClaimType), t.Version)
+	binary.BigEndian.PutUint32(e.Data[4][:4], revocationNonce)
+	if t.Subject != "" {
+		if subject == nil {
+			return nil, ErrTemplateSubjectUnset
+		}
+		copy(e.Data[templateSlots[t.Subject]][:], subject[:])
+	}
+	for i := range t.Fields {
+		f := &t.Fields[i]
+		v, ok := data[f.Name]
+		if !ok || v == nil {
+			if f.Optional {
+				continue
+			}
+			return nil, fmt.Errorf("%w: missing field %v", ErrTemplateData, f.Name)
+		}
+		slot, err := encodeField(f, v)
+		if err != nil {
+			return nil, err
+		}
+		e.Data[templateSlots[f.Slot]] = slot.ElemBytes()
+	}
+	return (*entryClaim)(e), nil
+}
+
+// expiration returns the expiration of a claim of the template issued at now
+// with data, or the zero time if it doesn't expire.
+func (t *ClaimTemplate) expiration(now time.Time, data map[string]interface{}) time.Time {
+	var expiration time.Time
+	if t.ttl != 0 {
+		expiration = now.Add(t.ttl)
+	}
+	if t.Expiration != nil && t.Expiration.Field != "" {
+		if v, ok := data[t.Expiration.Field]; ok && v != nil {
+			if fieldExpiration, err := parseTime(v); err == nil &&
+				(expiration.IsZero() || fieldExpiration.Before(expiration)) {
+				expiration = fieldExpiration
+			}
+		}
+	}
+	return expiration
+}
+
+// SetClaimTemplates replaces the ClaimTemplates of the Issuer, after
+// validating them.  Like the Validators, the templates are not persisted in
+// the storage, so they must be set again after Load, usually from the
+// configuration with ParseClaimTemplates.
+func (is *Issuer) SetClaimTemplates(templates ...*ClaimTemplate) error {
+	byID := make(map[string]*ClaimTemplate, len(templates))
+	for _, t := range templates {
+		if err := t.Validate(); err != nil {
+			return err
+		}
+		if _, ok := byID[t.ID]; ok {
+			return fmt.Errorf("%w: duplicate id %v", ErrTemplateInvalid, t.ID)
+		}
+		byID[t.ID] = t
+	}
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	is.templates = byID
+	return nil
+}
+
+// ClaimTemplate returns the ClaimTemplate with templateID, or
+// ErrTemplateNotFound.
+func (is *Issuer) ClaimTemplate(templateID string) (*ClaimTemplate, error) {
+	is.rw.RLock()
+	defer is.rw.RUnlock()
+	t, ok := is.templates[templateID]
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+	return t, nil
+}
+
+// IssueFromTemplate builds with the ClaimTemplate templateID a claim about
+// subject with the values in data, which usually comes from a JSON request,
+// and issues it (see IssueClaimWithNonce).  If the template has an expiration
+// policy, the expiration of the claim is recorded together with the issuance
+// (see IssueClaimWithExpiration).  It returns the issued claim.
+func (is *Issuer) IssueFromTemplate(templateID string, subject *core.ID, data map[string]interface{}) (merkletree.Entrier, error) {
+	is.rw.RLock()
+	t, ok := is.templates[templateID]
+	now := is.clock.Now()
+	is.rw.RUnlock()
+	if !ok {
+		return nil, ErrTemplateNotFound
+	}
+	return is.issueClaimWithNonceExpiring(func(revocationNonce uint32) (merkletree.Entrier, error) {
+		return t.NewClaim(subject, data, revocationNonce)
+	}, t.expiration(now, data))
+}
+
+// TemplateData is the data of a claim issued from a ClaimTemplate.
+type TemplateData struct {
+	// Subject is the identity the claim is about, or nil.
+	Subject *core.ID
+	// Data are the values of the fields of the template.
+	Data map[string]interface{}
+}
+
+// IssueFromTemplateBatch issues a batch of claims from the ClaimTemplate
+// templateID with IssueClaims, recording their expirations together with the
+// issuance.  It returns the issued claim and the error of each element of
+// batch, by position, or an error if the template doesn't exist or the batch
+// couldn't be issued (see IssueClaims).
+func (is *Issuer) IssueFromTemplateBatch(templateID string, batch []TemplateData) ([]merkletree.Entrier, []error, error) {
+	is.rw.RLock()
+	t, ok := is.templates[templateID]
+	now := is.clock.Now()
+	is.rw.RUnlock()
+	if !ok {
+		return nil, nil, ErrTemplateNotFound
+	}
+	newClaims := make([]func(uint32) (merkletree.Entrier, error), len(batch))
+	expirations := make([]time.Time, len(batch))
+	for i := range batch {
+		d := &batch[i]
+		newClaims[i] = func(revocationNonce uint32) (merkletree.Entrier, error) {
+			return t.NewClaim(d.Subject, d.Data, revocationNonce)
+		}
+		expirations[i] = t.expiration(now, d.Data)
+	}
+	return is.issueClaimsExpiring(newClaims, expirations)
+}
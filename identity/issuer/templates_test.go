@@ -0,0 +1,147 @@
+package issuer
+
+import (
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testClaimTemplates = `[
+  {
+    "id": "membership",
+    "claimType": "acme.membership",
+    "subject": "index:0",
+    "fields": [
+      {"name": "level", "slot": "index:1", "encoding": "string"},
+      {"name": "email", "slot": "index:2", "encoding": "hash"},
+      {"name": "points", "slot": "data:0", "encoding": "int"},
+      {"name": "validUntil", "slot": "data:1", "encoding": "time", "optional": true},
+      {"name": "active", "slot": "data:2", "encoding": "bool"}
+    ],
+    "expiration": {"ttl": "720h", "field": "validUntil"}
+  },
+  {
+    "id": "event",
+    "claimType": "acme.event",
+    "fields": [{"name": "name", "slot": "index:0", "encoding": "string"}]
+  }
+]`
+
+func TestParseClaimTemplates(t *testing.T) {
+	templates, err := ParseClaimTemplates([]byte(testClaimTemplates))
+	require.Nil(t, err)
+	require.Equal(t, 2, len(templates))
+	assert.Equal(t, 720*time.Hour, templates[0].ttl)
+
+	for _, invalid := range []string{
+		`[{"claimType": "t", "fields": []}]`,
+		`[{"id": "a", "claimType": "t", "subject": "index:3"}]`,
+		`[{"id": "a", "claimType": "t", "subject": "index:0", "fields": [{"name": "f", "slot": "index:0", "encoding": "int"}]}]`,
+		`[{"id": "a", "claimType": "t", "fields": [{"name": "f", "slot": "data:0", "encoding": "float"}]}]`,
+		`[{"id": "a", "claimType": "t", "fields": [], "expiration": {"ttl": "1 year"}}]`,
+		`[{"id": "a", "claimType": "t", "fields": [{"name": "f", "slot": "data:0", "encoding": "int"}], "expiration": {"field": "f"}}]`,
+	} {
+		_, err := ParseClaimTemplates([]byte(invalid))
+		assert.True(t, errors.Is(err, ErrTemplateInvalid), invalid)
+	}
+}
+
+func TestIssueFromTemplate(t *testing.T) {
+	issuer, _, _ := newIssuer(t, idenpubonchain.New())
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	issuer.SetClock(clk)
+	templates, err := ParseClaimTemplates([]byte(testClaimTemplates))
+	require.Nil(t, err)
+	require.Nil(t, issuer.SetClaimTemplates(templates...))
+	subject := core.NewID(core.TypeBJP0, [27]byte{1})
+
+	data := map[string]interface{}{"level": "gold", "email": "alice@example.com", "points": float64(1500),
+		"active": true}
+	claim, err := issuer.IssueFromTemplate("membership", &subject, data)
+	require.Nil(t, err)
+	e := claim.Entry()
+	claimType, _ := claims.GetClaimTypeVersion(e)
+	assert.Equal(t, *claims.NewClaimType("acme.membership"), claimType)
+	assert.Equal(t, subject[:], e.Data[1][:len(subject)])
+	level, err := claims.StringFromSlot(claims.SlotFromElemBytes(e.Data[2]))
+	require.Nil(t, err)
+	assert.Equal(t, "gold", level)
+	assert.Equal(t, claims.Slot(claims.HashString("alice@example.com")), claims.SlotFromElemBytes(e.Data[3]))
+	assert.Equal(t, big.NewInt(1500), claims.BigIntFromSlot(claims.SlotFromElemBytes(e.Data[5])))
+	assert.Equal(t, claims.Slot{}, claims.SlotFromElemBytes(e.Data[6]))
+	assert.Equal(t, byte(1), e.Data[7][0])
+	_, err = issuer.ClaimByHIndex(e.HIndex())
+	require.Nil(t, err)
+	expiration, err := issuer.ClaimExpiration(e.HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, clk.Now().Add(720*time.Hour), expiration)
+
+	// The expiration of the data comes before the TTL
+	data["level"], data["validUntil"] = "silver", "2020-10-01T00:00:00Z"
+	claim, err = issuer.IssueFromTemplate("membership", &subject, data)
+	require.Nil(t, err)
+	expiration, err = issuer.ClaimExpiration(claim.Entry().HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, time.Date(2020, 10, 1, 0, 0, 0, 0, time.UTC).Unix(), expiration.Unix())
+
+	// Without subject binding nor expiration
+	claim, err = issuer.IssueFromTemplate("event", nil, map[string]interface{}{"name": "devcon"})
+	require.Nil(t, err)
+	_, err = issuer.ClaimExpiration(claim.Entry().HIndex())
+	assert.NotNil(t, err)
+
+	_, err = issuer.IssueFromTemplate("unknown", &subject, data)
+	assert.Equal(t, ErrTemplateNotFound, err)
+	_, err = issuer.IssueFromTemplate("membership", nil, data)
+	assert.Equal(t, ErrTemplateSubjectUnset, err)
+	for _, invalid := range []map[string]interface{}{
+		{"level": "bronze", "email": "alice@example.com", "active": true},
+		{"level": "bronze", "email": "alice@example.com", "points": -1.0, "active": true},
+		{"level": "bronze", "email": "alice@example.com", "points": 1.5, "active": true},
+		{"level": "bronze", "email": "alice@example.com", "points": 1.0, "active": "yes"},
+		{"level": "bronze", "email": "alice@example.com", "points": 1.0, "active": true, "validUntil": "tomorrow"},
+	} {
+		_, err = issuer.IssueFromTemplate("membership", &subject, invalid)
+		assert.NotNil(t, err, invalid)
+	}
+
+	assert.True(t, errors.Is(issuer.SetClaimTemplates(templates[0], templates[0]), ErrTemplateInvalid))
+}
+
+func TestIssueFromTemplateBatch(t *testing.T) {
+	issuer, _, _ := newIssuer(t, idenpubonchain.New())
+	templates, err := ParseClaimTemplates([]byte(testClaimTemplates))
+	require.Nil(t, err)
+	require.Nil(t, issuer.SetClaimTemplates(templates...))
+	subject := core.NewID(core.TypeBJP0, [27]byte{1})
+
+	_, _, err = issuer.IssueFromTemplateBatch("unknown", nil)
+	assert.Equal(t, ErrTemplateNotFound, err)
+
+	issued, errs, err := issuer.IssueFromTemplateBatch("membership", []TemplateData{
+		{Subject: &subject, Data: map[string]interface{}{"level": "gold", "email": "a@example.com",
+			"points": "10", "active": "true"}},
+		{Subject: nil, Data: map[string]interface{}{"level": "gold", "email": "b@example.com",
+			"points": "10", "active": "true"}},
+		{Subject: &subject, Data: map[string]interface{}{"level": "silver", "email": "c@example.com",
+			"points": "10", "active": "false"}},
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []error{nil, ErrTemplateSubjectUnset, nil}, errs)
+	assert.Nil(t, issued[1])
+	for _, i := range []int{0, 2} {
+		require.NotNil(t, issued[i])
+		_, err := issuer.ClaimExpiration(issued[i].Entry().HIndex())
+		require.Nil(t, err)
+	}
+	assert.Equal(t, byte(1), issued[0].Entry().Data[7][0])
+	assert.Equal(t, byte(0), issued[2].Entry().Data[7][0])
+}
@@ -0,0 +1,109 @@
+package issuer
+
+import (
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+)
+
+var (
+	ErrClaimNotInField      = fmt.Errorf("Claim elements not in the finite field")
+	ErrClaimAlreadyIssued   = fmt.Errorf("Claim with the same hIndex already issued")
+	ErrClaimTypeNotAccepted = fmt.Errorf("Claim type not accepted by the issuer")
+)
+
+// ClaimGetter gives access to the claims already issued.
+type ClaimGetter interface {
+	// ClaimByHIndex returns the claim entry found in the current Claims
+	// Merkle Tree at the position hIndex, or merkletree.ErrEntryIndexNotFound.
+	ClaimByHIndex(hIndex *merkletree.Hash) (*merkletree.Entry, error)
+}
+
+// Validator checks a claim before it's issued, and returns an error if the
+// claim must be rejected.  issued gives access to the claims already issued.
+// Validators are called with the Issuer lock held, so they must not call the
+// Issuer methods.
+type Validator func(claim merkletree.Entrier, issued ClaimGetter) error
+
+// issuedClaims is the ClaimGetter passed to the Validators, which reads the
+// claims tree without taking the Issuer lock.
+type issuedClaims struct {
+	is *Issuer
+}
+
+func (c issuedClaims) ClaimByHIndex(hIndex *merkletree.Hash) (*merkletree.Entry, error) {
+	data, err := c.is.claimsTree.GetDataByIndex(hIndex)
+	if err != nil {
+		return nil, err
+	}
+	return &merkletree.Entry{Data: *data}, nil
+}
+
+// validate runs the configured Validators on claim.  The caller must hold
+// the write lock.
+func (is *Issuer) validate(claim merkletree.Entrier) error {
+	for _, validator := range is.cfg.Validators {
+		if err := validator(claim, issuedClaims{is}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetValidators replaces the Validators of the Issuer.  Validators are not
+// persisted in the storage, so they must be set again after Load.
+func (is *Issuer) SetValidators(validators ...Validator) {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	is.cfg.Validators = validators
+}
+
+// ValidateEntryInField rejects the claims with elements that are not in the
+// finite field, which can't be added to the merkle tree.
+func ValidateEntryInField(claim merkletree.Entrier, _ ClaimGetter) error {
+	if !merkletree.CheckEntryInField(*claim.Entry()) {
+		return ErrClaimNotInField
+	}
+	return nil
+}
+
+// ValidateNoDuplicateHIndex rejects the claims whose hIndex is already in the
+// claims tree.
+func ValidateNoDuplicateHIndex(claim merkletree.Entrier, issued ClaimGetter) error {
+	_, err := issued.ClaimByHIndex(claim.Entry().HIndex())
+	if err == nil {
+		return ErrClaimAlreadyIssued
+	} else if err != merkletree.ErrEntryIndexNotFound {
+		return err
+	}
+	return nil
+}
+
+// ValidateClaimTypes returns a Validator that only accepts the claims of the
+// claimTypes.
+func ValidateClaimTypes(claimTypes ...*claims.ClaimType) Validator {
+	return func(claim merkletree.Entrier, _ ClaimGetter) error {
+		claimType, _ := claims.GetClaimTypeVersion(claim.Entry())
+		for _, t := range claimTypes {
+			if claimType == *t {
+				return nil
+			}
+		}
+		return ErrClaimTypeNotAccepted
+	}
+}
+
+// ValidateSchemas returns a Validator that checks each claim with the
+// Validator of its claim type in schemas.  Claims of types without a
+// Validator in schemas are accepted.
+func ValidateSchemas(schemas map[claims.ClaimType]Validator) Validator {
+	return func(claim merkletree.Entrier, issued ClaimGetter) error {
+		claimType, _ := claims.GetClaimTypeVersion(claim.Entry())
+		validator, ok := schemas[claimType]
+		if !ok {
+			return nil
+		}
+		return validator(claim, issued)
+	}
+}
@@ -0,0 +1,92 @@
+package issuer
+
+import (
+	"fmt"
+	"testing"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/core/claims"
+	"github.com/iden3/go-iden3-core/merkletree"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerValidators(t *testing.T) {
+	issuer, _, _ := newIssuer(t, idenpubonchain.New())
+
+	errData0 := fmt.Errorf("first byte of the data slot must be set")
+	issuer.SetValidators(
+		ValidateEntryInField,
+		ValidateNoDuplicateHIndex,
+		ValidateClaimTypes(claims.ClaimTypeBasic),
+		ValidateSchemas(map[claims.ClaimType]Validator{
+			*claims.ClaimTypeBasic: func(claim merkletree.Entrier, _ ClaimGetter) error {
+				if claims.NewClaimBasicFromEntry(claim.Entry()).DataSlot[0] == 0 {
+					return errData0
+				}
+				return nil
+			},
+		}),
+	)
+
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	dataBytes[0] = 0x01
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.IssueClaim(claim0))
+	assert.Equal(t, ErrClaimAlreadyIssued, issuer.IssueClaim(claim0))
+
+	// Element out of the field
+	claim1 := claims.NewClaimBasic(indexBytes, dataBytes, 1)
+	entry := claim1.Entry()
+	entry.Data[1] = merkletree.ElemBytes{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff,
+		0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}
+	assert.Equal(t, ErrClaimNotInField, issuer.IssueClaim((*entryClaim)(entry)))
+
+	// Schema rule of ClaimBasic
+	indexBytes[0] = 0x42
+	_, err := issuer.IssueClaimWithNonce(func(nonce uint32) (merkletree.Entrier, error) {
+		return claims.NewClaimBasic(indexBytes, [claims.DataSlotBytes]byte{}, nonce), nil
+	})
+	assert.Equal(t, errData0, err)
+
+	// Claim type not accepted
+	_, err = issuer.IssueClaimDelegate(issuer.ID(), claims.DelegateScopeIssueClaims)
+	assert.Equal(t, ErrClaimTypeNotAccepted, err)
+
+	issuer.SetValidators()
+	assert.Equal(t, ErrClaimAlreadyIssued, issuer.IssueClaim(claim0))
+}
+
+func TestIssuerDuplicatePolicy(t *testing.T) {
+	issuer, _, _ := newIssuer(t, idenpubonchain.New())
+	indexBytes, dataBytes := [claims.IndexSlotBytes]byte{}, [claims.DataSlotBytes]byte{}
+	claim0 := claims.NewClaimBasic(indexBytes, dataBytes, 0)
+	require.Nil(t, issuer.IssueClaim(claim0))
+	assert.Equal(t, ErrClaimAlreadyIssued, issuer.IssueClaim(claim0))
+
+	issuer.cfg.DuplicatePolicy = DuplicateBumpVersion
+	require.Nil(t, issuer.IssueClaim(claim0))
+	claim1 := *claim0
+	claim1.Version = 1
+	_, err := issuer.ClaimByHIndex(claim1.Entry().HIndex())
+	require.Nil(t, err)
+
+	claim, err := issuer.IssueClaimWithNonce(func(nonce uint32) (merkletree.Entrier, error) {
+		return claims.NewClaimBasic(indexBytes, dataBytes, nonce), nil
+	})
+	require.Nil(t, err)
+	claimBasic, ok := claim.(*claims.ClaimBasic)
+	require.True(t, ok)
+	assert.Equal(t, uint32(2), claimBasic.Version)
+	_, err = issuer.ClaimByHIndex(claim.Entry().HIndex())
+	require.Nil(t, err)
+
+	// Delegations are issued with a new nonce, so the revocation nonce
+	// changes with the version.
+	claimDelegate, err := issuer.IssueClaimDelegate(issuer.ID(), claims.DelegateScopeIssueClaims)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(0), claimDelegate.Version)
+	claimDelegate, err = issuer.IssueClaimDelegate(issuer.ID(), claims.DelegateScopeIssueClaims)
+	require.Nil(t, err)
+	assert.Equal(t, uint32(1), claimDelegate.Version)
+}
@@ -0,0 +1,62 @@
+package issuer
+
+import (
+	"context"
+	"time"
+
+	"github.com/iden3/go-iden3-core/utils/worker"
+	log "github.com/sirupsen/logrus"
+)
+
+// Names of the workers of the Issuer in its worker.Manager.
+const (
+	WorkerExpirationSweeper = "expirationsweeper"
+	WorkerAutoPublisher     = "autopublisher"
+)
+
+// WorkersConfig configures the background workers of the Issuer, see
+// Issuer.Workers.  A zero interval disables the worker.
+type WorkersConfig struct {
+	// ExpirationSweepInterval is the interval of the sweeps of the
+	// expired claims, see SweepExpired.
+	ExpirationSweepInterval time.Duration
+	// AutoPublishInterval is the interval of the evaluations of the
+	// PublishPolicy, see PublishStateIfDue.
+	AutoPublishInterval time.Duration
+}
+
+// Workers returns the worker.Manager of the background workers of the Issuer,
+// with the ones enabled in Config.Workers.  Embedders can add their own
+// workers, like the delivery of a notifier.Notifier, and control the
+// lifecycle of all of them with Start and Stop.  The workers use the Clock of
+// the Issuer at the first call.
+func (is *Issuer) Workers() *worker.Manager {
+	is.rw.Lock()
+	defer is.rw.Unlock()
+	if is.workers != nil {
+		return is.workers
+	}
+	clk := is.clock
+	m := worker.NewManager(clk)
+	cfg := is.cfg.Workers
+	if cfg.ExpirationSweepInterval > 0 {
+		// The names are unique in a new Manager.
+		_ = m.Add(WorkerExpirationSweeper, worker.Every(clk, cfg.ExpirationSweepInterval,
+			func(ctx context.Context) error {
+				n, err := is.SweepExpired(clk.Now())
+				if err == nil && n != 0 {
+					log.WithField("revoked", n).Info("Expired claims revoked")
+				}
+				return err
+			}))
+	}
+	if cfg.AutoPublishInterval > 0 {
+		_ = m.Add(WorkerAutoPublisher, worker.Every(clk, cfg.AutoPublishInterval,
+			func(ctx context.Context) error {
+				_, err := is.PublishStateIfDue(clk.Now())
+				return err
+			}))
+	}
+	is.workers = m
+	return m
+}
@@ -0,0 +1,52 @@
+package issuer
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	idenpubonchain "github.com/iden3/go-iden3-core/components/idenpubonchain/mock"
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuerWorkers(t *testing.T) {
+	idenPubOnChain := idenpubonchain.New()
+	issuer, _, _ := newIssuer(t, idenPubOnChain)
+	issuer.cfg.Workers = WorkersConfig{ExpirationSweepInterval: time.Minute, AutoPublishInterval: time.Hour}
+	issuer.cfg.PublishPolicy = PublishPolicy{MinChanges: 1}
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	issuer.SetClock(clk)
+	genesisState, _ := issuer.state()
+
+	workers := issuer.Workers()
+	assert.Equal(t, workers, issuer.Workers())
+	status := workers.Status()
+	assert.Equal(t, 2, len(status))
+	assert.Contains(t, status, WorkerExpirationSweeper)
+	assert.Contains(t, status, WorkerAutoPublisher)
+
+	claim := newExpirationClaim(1)
+	require.Nil(t, issuer.IssueClaimWithExpiration(claim, clk.Now().Add(time.Minute)))
+	require.Nil(t, workers.Start(context.Background()))
+	clk.BlockUntil(2)
+	clk.Advance(time.Minute)
+	for i := 0; i < 100 && workers.Status()[WorkerExpirationSweeper].Runs == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, claimRevoked(t, issuer, claim))
+
+	mockInitState(t, idenPubOnChain, issuer, genesisState)
+	clk.Advance(time.Hour - time.Minute)
+	for i := 0; i < 100 && workers.Status()[WorkerAutoPublisher].Runs == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Nil(t, workers.Check(context.Background()))
+	_, _, pending := issuer.PendingState()
+	assert.True(t, pending)
+
+	workers.Stop()
+	assert.False(t, workers.Status()[WorkerAutoPublisher].Running)
+	assert.NotNil(t, workers.Check(context.Background()))
+}
@@ -0,0 +1,29 @@
+// Package httpjson contains the helpers shared by the HTTP handlers of the
+// components to write their JSON responses.
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Error is the body of a failed response.
+type Error struct {
+	Error string `json:"error"`
+}
+
+// Write writes v encoded in JSON as the body of a response with status.
+func Write(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.WithError(err).Warn("Unable to write http response")
+	}
+}
+
+// WriteError writes an Error with msg as the body of a response with status.
+func WriteError(w http.ResponseWriter, status int, msg string) {
+	Write(w, status, Error{Error: msg})
+}
@@ -0,0 +1,21 @@
+package httpjson
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteError(t *testing.T) {
+	w := httptest.NewRecorder()
+	WriteError(w, http.StatusBadRequest, "invalid request")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var e Error
+	require.Nil(t, json.Unmarshal(w.Body.Bytes(), &e))
+	assert.Equal(t, Error{Error: "invalid request"}, e)
+}
@@ -1,7 +1,10 @@
 package keystore
 
 import (
+	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"errors"
 	"time"
 
@@ -13,6 +16,7 @@ import (
 	"math/big"
 	"os"
 	"runtime"
+	"sort"
 	"sync"
 
 	"github.com/gofrs/flock"
@@ -131,8 +135,14 @@ func DecryptData(encData *EncryptedData, pass []byte) ([]byte, error) {
 	return data, nil
 }
 
+// StoredKey is an encrypted secret key with an optional human-readable label.
+type StoredKey struct {
+	EncryptedData
+	Label string `json:",omitempty"`
+}
+
 // KeysStored is the datastructure of stored keys in the storage.
-type KeysStored map[babyjub.PublicKeyComp]EncryptedData
+type KeysStored map[babyjub.PublicKeyComp]StoredKey
 
 // Storage is an interface for a storage container.
 type Storage interface {
@@ -142,10 +152,13 @@ type Storage interface {
 	Unlock() error
 }
 
-// FileStorage is a storage backed by a file.
+// FileStorage is a storage backed by a file.  Access from other processes is
+// guarded by an advisory lock on a .lock file next to it, and access from
+// several goroutines by a mutex.
 type FileStorage struct {
 	path string
 	lock *flock.Flock
+	rw   sync.RWMutex
 }
 
 // NewFileStorage returns a new FileStorage backed by a file in path.
@@ -155,25 +168,51 @@ func NewFileStorage(path string) *FileStorage {
 
 // Read reads the file contents.
 func (fs *FileStorage) Read() ([]byte, error) {
+	fs.rw.RLock()
+	defer fs.rw.RUnlock()
 	return ioutil.ReadFile(fs.path)
 }
 
-// Write writes the data to the file.
+// Write replaces the file contents with data.  The data is first written to
+// a temporary file which is then renamed over the storage file, so that a
+// crash in the middle of a write never leaves a truncated key store behind.
 func (fs *FileStorage) Write(data []byte) error {
-	return ioutil.WriteFile(fs.path, data, 0600)
+	fs.rw.Lock()
+	defer fs.rw.Unlock()
+	tmpPath := fs.path + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, fs.path)
 }
 
-// TryLocks the storage file with a .lock file.
+// TryLock tries to acquire an exclusive OS-level lock on the storage file's
+// .lock file without blocking.  It returns false if another process (or
+// another FileStorage on the same path) holds the lock.
 func (fs *FileStorage) TryLock() (bool, error) {
 	return fs.lock.TryLock()
 }
 
-// Unlocks the storage file and removes the .lock file.
+// Unlock releases the lock on the storage file.  The .lock file is left in
+// place: removing it would let a process that opened the old file and a
+// process that creates a new one both believe they hold the lock.
 func (fs *FileStorage) Unlock() error {
-	if err := fs.lock.Unlock(); err != nil {
-		return err
-	}
-	return os.Remove(fs.path + ".lock")
+	return fs.lock.Unlock()
 }
 
 // MemStorage is a storage backed by a slice.
@@ -202,9 +241,13 @@ type KeyStore struct {
 	params        KeyStoreParams
 	encryptedKeys KeysStored
 	cache         map[babyjub.PublicKeyComp]*babyjub.PrivateKey
+	closed        bool
 	rw            sync.RWMutex
 }
 
+// ErrKeyStoreClosed is returned when using a KeyStore after Close.
+var ErrKeyStoreClosed = errors.New("KeyStore is closed")
+
 // NewKeyStore creates a new key store or opens it if it already exists.
 func NewKeyStore(storage Storage, params KeyStoreParams) (*KeyStore, error) {
 	if ok, err := storage.TryLock(); err != nil {
@@ -225,7 +268,7 @@ func NewKeyStore(storage Storage, params KeyStoreParams) (*KeyStore, error) {
 	}
 	var encryptedKeys KeysStored
 	if len(encryptedKeysJSON) == 0 {
-		encryptedKeys = make(map[babyjub.PublicKeyComp]EncryptedData)
+		encryptedKeys = make(KeysStored)
 	} else {
 		if err := json.Unmarshal(encryptedKeysJSON, &encryptedKeys); err != nil {
 			if secondErr := storage.Unlock(); secondErr != nil {
@@ -249,10 +292,19 @@ func NewKeyStore(storage Storage, params KeyStoreParams) (*KeyStore, error) {
 	return ks, nil
 }
 
+// Close clears the unlocked secret keys from memory and releases the lock on
+// the storage.  It is safe to call Close more than once.
 func (ks *KeyStore) Close() {
-	zero := [32]byte{}
-	for _, sk := range ks.cache {
-		copy(sk[:], zero[:])
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if ks.closed {
+		return
+	}
+	ks.closed = true
+	runtime.SetFinalizer(ks, nil)
+	for pk, sk := range ks.cache {
+		wipe(sk[:])
+		delete(ks.cache, pk)
 	}
 	err := ks.storage.Unlock()
 	if err != nil {
@@ -262,7 +314,7 @@ func (ks *KeyStore) Close() {
 	}
 }
 
-// Keys returns the compressed public keys of the key storage.
+// Keys returns the compressed public keys of the key storage, sorted.
 func (ks *KeyStore) Keys() []babyjub.PublicKeyComp {
 	ks.rw.RLock()
 	defer ks.rw.RUnlock()
@@ -270,9 +322,99 @@ func (ks *KeyStore) Keys() []babyjub.PublicKeyComp {
 	for pk := range ks.encryptedKeys {
 		keys = append(keys, pk)
 	}
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
 	return keys
 }
 
+// Label returns the label of the key corresponding to the public key pk.
+func (ks *KeyStore) Label(pk *babyjub.PublicKeyComp) (string, error) {
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	storedKey, ok := ks.encryptedKeys[*pk]
+	if !ok {
+		return "", fmt.Errorf("Public key not found in the key store")
+	}
+	return storedKey.Label, nil
+}
+
+// SetLabel sets the label of the key corresponding to the public key pk and
+// persists it in the storage.
+func (ks *KeyStore) SetLabel(pk *babyjub.PublicKeyComp, label string) error {
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if ks.closed {
+		return ErrKeyStoreClosed
+	}
+	storedKey, ok := ks.encryptedKeys[*pk]
+	if !ok {
+		return fmt.Errorf("Public key not found in the key store")
+	}
+	prev := storedKey
+	storedKey.Label = label
+	ks.encryptedKeys[*pk] = storedKey
+	if err := ks.persist(); err != nil {
+		ks.encryptedKeys[*pk] = prev
+		return err
+	}
+	return nil
+}
+
+// DeleteKey removes the key corresponding to the public key pk from the key
+// store.  pass must decrypt the key, to avoid deleting keys by mistake.  If
+// the key was unlocked, its secret key is wiped from memory.
+func (ks *KeyStore) DeleteKey(pk *babyjub.PublicKeyComp, pass []byte) error {
+	ks.rw.RLock()
+	storedKey, ok := ks.encryptedKeys[*pk]
+	ks.rw.RUnlock()
+	if !ok {
+		return fmt.Errorf("Public key not found in the key store")
+	}
+	skBuf, err := DecryptData(&storedKey.EncryptedData, pass)
+	if err != nil {
+		return err
+	}
+	wipe(skBuf)
+
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if ks.closed {
+		return ErrKeyStoreClosed
+	}
+	prev, ok := ks.encryptedKeys[*pk]
+	if !ok {
+		return fmt.Errorf("Public key not found in the key store")
+	}
+	delete(ks.encryptedKeys, *pk)
+	if err := ks.persist(); err != nil {
+		ks.encryptedKeys[*pk] = prev
+		return err
+	}
+	if sk, ok := ks.cache[*pk]; ok {
+		wipe(sk[:])
+		delete(ks.cache, *pk)
+	}
+	return nil
+}
+
+// persist writes the encrypted keys to the storage.  The caller must hold the
+// write lock.
+func (ks *KeyStore) persist() error {
+	encryptedKeysJSON, err := json.Marshal(ks.encryptedKeys)
+	if err != nil {
+		return err
+	}
+	return ks.storage.Write(encryptedKeysJSON)
+}
+
+// wipe overwrites buf with zeros.
+func wipe(buf []byte) {
+	for i := range buf {
+		buf[i] = 0
+	}
+}
+
 // NewKey creates a new key in the key store encrypted with pass.
 func (ks *KeyStore) NewKey(pass []byte) (*babyjub.PublicKeyComp, error) {
 	sk := babyjub.NewRandPrivKey()
@@ -281,51 +423,85 @@ func (ks *KeyStore) NewKey(pass []byte) (*babyjub.PublicKeyComp, error) {
 
 // ImportKey imports a secret key into the storage and encrypts it with pass.
 func (ks *KeyStore) ImportKey(sk babyjub.PrivateKey, pass []byte) (*babyjub.PublicKeyComp, error) {
-	ks.rw.Lock()
-	defer ks.rw.Unlock()
+	// The key derivation is slow, so do it before taking the lock.
 	encryptedKey, err := EncryptData(sk[:], pass, ks.params.ScryptN, ks.params.ScryptP)
 	if err != nil {
 		return nil, err
 	}
 	pk := sk.Public()
 	pubComp := pk.Compress()
-	ks.encryptedKeys[pubComp] = *encryptedKey
-	encryptedKeysJSON, err := json.Marshal(ks.encryptedKeys)
-	if err != nil {
-		return nil, err
+
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if ks.closed {
+		return nil, ErrKeyStoreClosed
 	}
-	if err := ks.storage.Write(encryptedKeysJSON); err != nil {
+	// Re-importing a key keeps its label.
+	prev, existed := ks.encryptedKeys[pubComp]
+	ks.encryptedKeys[pubComp] = StoredKey{EncryptedData: *encryptedKey, Label: prev.Label}
+	if err := ks.persist(); err != nil {
+		// Keep the in memory keys consistent with the storage.
+		if existed {
+			ks.encryptedKeys[pubComp] = prev
+		} else {
+			delete(ks.encryptedKeys, pubComp)
+		}
 		return nil, err
 	}
 	return &pubComp, nil
 }
 
+// ExportKey decrypts and returns a copy of the secret key corresponding to
+// the public key pk.  The key is also left unlocked in the cache.
 func (ks *KeyStore) ExportKey(pk *babyjub.PublicKeyComp, pass []byte) (*babyjub.PrivateKey, error) {
 	if err := ks.UnlockKey(pk, pass); err != nil {
 		return nil, err
 	}
-	return ks.cache[*pk], nil
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	cached, ok := ks.cache[*pk]
+	if !ok {
+		return nil, ErrKeyStoreClosed
+	}
+	sk := *cached
+	return &sk, nil
 }
 
 // UnlockKey decrypts the key corresponding to the public key pk and loads it
 // into the cache.
 func (ks *KeyStore) UnlockKey(pk *babyjub.PublicKeyComp, pass []byte) error {
-	ks.rw.Lock()
-	defer ks.rw.Unlock()
-	encryptedKey, ok := ks.encryptedKeys[*pk]
+	ks.rw.RLock()
+	storedKey, ok := ks.encryptedKeys[*pk]
+	ks.rw.RUnlock()
 	if !ok {
 		return fmt.Errorf("Public key not found in the key store")
 	}
-	skBuf, err := DecryptData(&encryptedKey, pass)
+	// The key derivation is slow, so do it without holding the lock.
+	skBuf, err := DecryptData(&storedKey.EncryptedData, pass)
 	if err != nil {
 		return err
 	}
 	var sk babyjub.PrivateKey
 	copy(sk[:], skBuf)
+	wipe(skBuf)
+	ks.rw.Lock()
+	defer ks.rw.Unlock()
+	if ks.closed {
+		return ErrKeyStoreClosed
+	}
 	ks.cache[*pk] = &sk
 	return nil
 }
 
+// Unlocked returns true if the key corresponding to the public key pk is
+// unlocked, so that it can sign.
+func (ks *KeyStore) Unlocked(pk *babyjub.PublicKeyComp) bool {
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	_, ok := ks.cache[*pk]
+	return ok
+}
+
 // SignElem uses the key corresponding to the public key pk to sign the field
 // element msg.
 func (ks *KeyStore) SignElem(pk *babyjub.PublicKeyComp, msg *big.Int) (*babyjub.SignatureComp, error) {
@@ -361,6 +537,24 @@ func (ks *KeyStore) SignRaw(pk *babyjub.PublicKeyComp, msg []byte) (*babyjub.Sig
 	return ks.SignElem(pk, h)
 }
 
+// DeriveKey derives a symmetric key for the purpose described by info from
+// the key corresponding to the public key pk, which must be unlocked.  The
+// same key and info always derive the same symmetric key, which can't be
+// computed without the secret key.
+func (ks *KeyStore) DeriveKey(pk *babyjub.PublicKeyComp, info []byte) (*[32]byte, error) {
+	ks.rw.RLock()
+	defer ks.rw.RUnlock()
+	sk, ok := ks.cache[*pk]
+	if !ok {
+		return nil, fmt.Errorf("Public key not found in the cache.  Is it unlocked?")
+	}
+	mac := hmac.New(sha256.New, sk[:])
+	mac.Write(info)
+	var key [32]byte
+	copy(key[:], mac.Sum(nil))
+	return &key, nil
+}
+
 // VerifySignatureElem verifies that the signature sigComp of the field element
 // msg was signed with the public key pkComp.
 func VerifySignatureElem(pkComp *babyjub.PublicKeyComp, msg *big.Int, sigComp *babyjub.SignatureComp) (bool, error) {
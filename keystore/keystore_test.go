@@ -3,10 +3,17 @@ package keystore
 import (
 	"encoding/hex"
 	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
 	"testing"
 
 	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/iden3/go-iden3-crypto/babyjub"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestEncryptDecrypt(t *testing.T) {
@@ -77,3 +84,123 @@ func TestSignVerify(t *testing.T) {
 	assert.Equal(t, nil, err)
 	assert.Equal(t, false, ok)
 }
+
+func TestDeriveKey(t *testing.T) {
+	pass := []byte("my passphrase")
+	storage := MemStorage([]byte{})
+	ks, err := NewKeyStore(&storage, LightKeyStoreParams)
+	require.Nil(t, err)
+	pk, err := ks.NewKey(pass)
+	require.Nil(t, err)
+
+	_, err = ks.DeriveKey(pk, []byte("wallet"))
+	assert.NotNil(t, err)
+
+	require.Nil(t, ks.UnlockKey(pk, pass))
+	key1, err := ks.DeriveKey(pk, []byte("wallet"))
+	require.Nil(t, err)
+	key2, err := ks.DeriveKey(pk, []byte("wallet"))
+	require.Nil(t, err)
+	assert.Equal(t, key1, key2)
+	key3, err := ks.DeriveKey(pk, []byte("other"))
+	require.Nil(t, err)
+	assert.NotEqual(t, key1, key3)
+}
+
+func TestFileStorageLock(t *testing.T) {
+	dir, err := ioutil.TempDir("", "keystore")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "keystore.json")
+
+	pass := []byte("my passphrase")
+	ks, err := NewKeyStore(NewFileStorage(path), LightKeyStoreParams)
+	require.Nil(t, err)
+	pk, err := ks.NewKey(pass)
+	require.Nil(t, err)
+
+	// A second key store on the same file can't be opened while the
+	// first one holds the lock.
+	_, err = NewKeyStore(NewFileStorage(path), LightKeyStoreParams)
+	assert.NotNil(t, err)
+
+	ks.Close()
+	ks.Close()
+	_, err = ks.NewKey(pass)
+	assert.Equal(t, ErrKeyStoreClosed, err)
+
+	ks1, err := NewKeyStore(NewFileStorage(path), LightKeyStoreParams)
+	require.Nil(t, err)
+	defer ks1.Close()
+	assert.Equal(t, ks.Keys(), ks1.Keys())
+	require.Nil(t, ks1.UnlockKey(pk, pass))
+}
+
+func TestKeyStoreConcurrent(t *testing.T) {
+	pass := []byte("my passphrase")
+	storage := MemStorage([]byte{})
+	ks, err := NewKeyStore(&storage, LightKeyStoreParams)
+	require.Nil(t, err)
+	pk, err := ks.NewKey(pass)
+	require.Nil(t, err)
+	require.Nil(t, ks.UnlockKey(pk, pass))
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := ks.NewKey(pass)
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, _, err := ks.Sign(pk, PrefixMinorUpdate, []byte("msg"))
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 5, len(ks.Keys()))
+}
+
+func TestKeyStoreLabelDelete(t *testing.T) {
+	pass := []byte("my passphrase")
+	storage := MemStorage([]byte{})
+	ks, err := NewKeyStore(&storage, LightKeyStoreParams)
+	require.Nil(t, err)
+	pk1, err := ks.NewKey(pass)
+	require.Nil(t, err)
+	pk2, err := ks.NewKey(pass)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(ks.Keys()))
+
+	label, err := ks.Label(pk1)
+	require.Nil(t, err)
+	assert.Equal(t, "", label)
+	require.Nil(t, ks.SetLabel(pk1, "issuer operational"))
+
+	// Labels are persisted in the storage
+	ks1, err := NewKeyStore(&storage, LightKeyStoreParams)
+	require.Nil(t, err)
+	label, err = ks1.Label(pk1)
+	require.Nil(t, err)
+	assert.Equal(t, "issuer operational", label)
+
+	// Deleting requires the right passphrase
+	require.Nil(t, ks.UnlockKey(pk2, pass))
+	assert.NotNil(t, ks.DeleteKey(pk2, []byte("wrong")))
+	require.Nil(t, ks.DeleteKey(pk2, pass))
+	assert.Equal(t, []babyjub.PublicKeyComp{*pk1}, ks.Keys())
+	_, err = ks.SignElem(pk2, big.NewInt(1))
+	assert.NotNil(t, err)
+	assert.NotNil(t, ks.DeleteKey(pk2, pass))
+
+	ks2, err := NewKeyStore(&storage, LightKeyStoreParams)
+	require.Nil(t, err)
+	assert.Equal(t, []babyjub.PublicKeyComp{*pk1}, ks2.Keys())
+}
@@ -0,0 +1,186 @@
+package merkletree
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// benchLeaves returns the number of leaves of the benchmark trees, set as a
+// comma separated list in the MERKLETREE_BENCH_LEAVES environment variable
+// (1000 by default).  Setting up the trees is dominated by the hashing of the
+// nodes, so the trees of 1e5 and 1e6 leaves take hours with Poseidon.
+func benchLeaves(b *testing.B) []int {
+	env := os.Getenv("MERKLETREE_BENCH_LEAVES")
+	if env == "" {
+		return []int{1000}
+	}
+	leaves := []int{}
+	for _, s := range strings.Split(env, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil {
+			b.Fatal(err)
+		}
+		leaves = append(leaves, n)
+	}
+	return leaves
+}
+
+var benchLevels = []int{40, 140}
+
+// benchTreesCache holds the benchmark trees by levels and leaves, so that
+// they are only set up once.
+var benchTreesCache = map[[2]int]*MerkleTree{}
+
+// benchEntry returns the entry i of the benchmark trees.
+func benchEntry(i int) *Entry {
+	var e Entry
+	binary.LittleEndian.PutUint64(e.Data[0][:8], uint64(i))
+	binary.LittleEndian.PutUint64(e.Data[IndexLen][:8], uint64(i))
+	return &e
+}
+
+// benchTree returns a tree with maxLevels filled with n leaves.  The tree is
+// shared between benchmarks, so it must not be modified.
+func benchTree(b *testing.B, maxLevels, n int) *MerkleTree {
+	if mt, ok := benchTreesCache[[2]int{maxLevels, n}]; ok {
+		return mt
+	}
+	mt, err := NewMerkleTree(db.NewMemoryStorage(), maxLevels)
+	if err != nil {
+		b.Fatal(err)
+	}
+	entries := make([]*Entry, n)
+	for i := range entries {
+		entries[i] = benchEntry(i)
+	}
+	// Add all the entries in a single transaction to speed up the set up
+	tx, err := mt.storage.NewTx()
+	if err != nil {
+		b.Fatal(err)
+	}
+	mt.Lock()
+	defer mt.Unlock()
+	if err := mt.importEntries(tx, entries, nil, nil); err != nil {
+		b.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	benchTreesCache[[2]int{maxLevels, n}] = mt
+	return mt
+}
+
+// cloneBenchTree returns a writable copy of the benchmark tree mt.
+func cloneBenchTree(b *testing.B, mt *MerkleTree) *MerkleTree {
+	storage := db.NewMemoryStorage()
+	tx, err := storage.NewTx()
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := mt.Storage().Iterate(func(k, v []byte) (bool, error) {
+		tx.Put(k, v)
+		return true, nil
+	}); err != nil {
+		b.Fatal(err)
+	}
+	if err := tx.Commit(); err != nil {
+		b.Fatal(err)
+	}
+	clone, err := NewMerkleTree(storage, mt.MaxLevels())
+	if err != nil {
+		b.Fatal(err)
+	}
+	return clone
+}
+
+// benchTrees runs f as a sub-benchmark for each combination of levels and
+// leaves.
+func benchTrees(b *testing.B, f func(b *testing.B, mt *MerkleTree, n int)) {
+	for _, levels := range benchLevels {
+		for _, n := range benchLeaves(b) {
+			b.Run(fmt.Sprintf("levels=%d/leaves=%d", levels, n), func(b *testing.B) {
+				mt := benchTree(b, levels, n)
+				b.ResetTimer()
+				f(b, mt, n)
+			})
+		}
+	}
+}
+
+func BenchmarkTreeAdd(b *testing.B) {
+	benchTrees(b, func(b *testing.B, mt *MerkleTree, n int) {
+		b.StopTimer()
+		mt = cloneBenchTree(b, mt)
+		b.StartTimer()
+		for i := 0; i < b.N; i++ {
+			if err := mt.AddEntry(benchEntry(n + i)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkTreeGenerateProof(b *testing.B) {
+	benchTrees(b, func(b *testing.B, mt *MerkleTree, n int) {
+		for i := 0; i < b.N; i++ {
+			if _, err := mt.GenerateProof(benchEntry(i%n).HIndex(), nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkTreeVerifyProof(b *testing.B) {
+	benchTrees(b, func(b *testing.B, mt *MerkleTree, n int) {
+		e := benchEntry(0)
+		proof, err := mt.GenerateProof(e.HIndex(), nil)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if !VerifyProof(mt.RootKey(), proof, e.HIndex(), e.HValue()) {
+				b.Fatal("invalid proof")
+			}
+		}
+	})
+}
+
+func BenchmarkTreeDump(b *testing.B) {
+	benchTrees(b, func(b *testing.B, mt *MerkleTree, n int) {
+		for i := 0; i < b.N; i++ {
+			if err := mt.DumpTree(ioutil.Discard, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEntryHIndex(b *testing.B) {
+	e := benchEntry(1)
+	b.Run("cached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			e.HIndex()
+		}
+	})
+	b.Run("uncached", func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			HashElems(e.Index()...)
+		}
+	})
+}
+
+func BenchmarkEntryHIndexModified(b *testing.B) {
+	e := benchEntry(1)
+	for i := 0; i < b.N; i++ {
+		e.Data[0][8] = byte(i)
+		e.HIndex()
+	}
+}
@@ -0,0 +1,88 @@
+package merkletree
+
+import (
+	"errors"
+	"math/big"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	cryptoConstants "github.com/iden3/go-iden3-crypto/constants"
+	cryptoUtils "github.com/iden3/go-iden3-crypto/utils"
+)
+
+// The elements of the Finite Field, ElemBytes and Hash, are ElemBytesLen
+// bytes in little-endian: the first byte is the least significant one.  This
+// is the encoding of the stored nodes and of the serialized proofs, and the
+// hex strings (Hex, MarshalText) encode the same bytes, so they are
+// little-endian too.  The *big.Int are the integer value of the element.
+// The conversions of this file are strict: they fail on inputs of the wrong
+// length or out of the Finite Field, instead of truncating them.
+
+var (
+	// ErrInvalidElemLen is used when the bytes of an element don't have
+	// ElemBytesLen bytes.
+	ErrInvalidElemLen = errors.New("the element must have 32 bytes")
+	// ErrElemNotInField is used when an element is negative or not lower
+	// than the Finite Field order.
+	ErrElemNotInField = errors.New("the element is not inside the Finite Field")
+)
+
+// inField returns true if b is inside the Finite Field.
+func inField(b *big.Int) bool {
+	return b.Sign() >= 0 && cryptoUtils.CheckBigIntInField(b, cryptoConstants.Q)
+}
+
+// BigInt returns the integer value of the element.
+func (e *ElemBytes) BigInt() *big.Int {
+	return new(big.Int).SetBytes(SwapEndianness(e[:]))
+}
+
+// NewElemBytesFromBigInt returns the element of the integer b, which must be
+// inside the Finite Field.
+func NewElemBytesFromBigInt(b *big.Int) (ElemBytes, error) {
+	var e ElemBytes
+	if !inField(b) {
+		return e, ErrElemNotInField
+	}
+	copy(e[:], SwapEndianness(b.Bytes()))
+	return e, nil
+}
+
+// BigInt returns the integer value of the Hash.
+func (h *Hash) BigInt() *big.Int {
+	return (*ElemBytes)(h).BigInt()
+}
+
+// NewHashFromBigInt returns the Hash of the integer b, which must be inside
+// the Finite Field.
+func NewHashFromBigInt(b *big.Int) (*Hash, error) {
+	e, err := NewElemBytesFromBigInt(b)
+	if err != nil {
+		return nil, err
+	}
+	h := Hash(e)
+	return &h, nil
+}
+
+// NewHashFromBytes returns the Hash of the ElemBytesLen little-endian bytes
+// b, which must be inside the Finite Field.
+func NewHashFromBytes(b []byte) (*Hash, error) {
+	if len(b) != ElemBytesLen {
+		return nil, ErrInvalidElemLen
+	}
+	var h Hash
+	copy(h[:], b)
+	if !h.InField() {
+		return nil, ErrElemNotInField
+	}
+	return &h, nil
+}
+
+// NewHashFromHex returns the Hash of the hex string s, with or without the
+// 0x prefix, of its little-endian bytes, as encoded by Hex.
+func NewHashFromHex(s string) (*Hash, error) {
+	b, err := common3.HexDecode(s)
+	if err != nil {
+		return nil, err
+	}
+	return NewHashFromBytes(b)
+}
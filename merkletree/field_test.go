@@ -0,0 +1,49 @@
+package merkletree
+
+import (
+	"math/big"
+	"strings"
+	"testing"
+
+	cryptoConstants "github.com/iden3/go-iden3-crypto/constants"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFieldConversions(t *testing.T) {
+	// The elements are little-endian.
+	h, err := NewHashFromBigInt(big.NewInt(0x0102))
+	require.Nil(t, err)
+	assert.Equal(t, &Hash{0x02, 0x01}, h)
+	assert.Equal(t, big.NewInt(0x0102), h.BigInt())
+	assert.Equal(t, "0x0201000000000000000000000000000000000000000000000000000000000000", h.Hex())
+
+	h2, err := NewHashFromHex(strings.TrimPrefix(h.Hex(), "0x"))
+	require.Nil(t, err)
+	assert.Equal(t, h, h2)
+	h2, err = NewHashFromBytes(h[:])
+	require.Nil(t, err)
+	assert.Equal(t, h, h2)
+	assert.Equal(t, *h, HexStringToHash(h.Hex()))
+
+	e, err := NewElemBytesFromBigInt(big.NewInt(0x0102))
+	require.Nil(t, err)
+	assert.Equal(t, ElemBytes(*h), e)
+	assert.Equal(t, big.NewInt(0x0102), e.BigInt())
+
+	// Strict length and field validation.
+	_, err = NewHashFromBytes(h[:31])
+	assert.Equal(t, ErrInvalidElemLen, err)
+	_, err = NewHashFromHex(h.Hex() + "00")
+	assert.Equal(t, ErrInvalidElemLen, err)
+	_, err = NewHashFromHex("zz")
+	assert.NotNil(t, err)
+	_, err = NewHashFromBigInt(cryptoConstants.Q)
+	assert.Equal(t, ErrElemNotInField, err)
+	_, err = NewHashFromBigInt(big.NewInt(-1))
+	assert.Equal(t, ErrElemNotInField, err)
+	q := BigIntToHash(cryptoConstants.Q)
+	_, err = NewHashFromBytes(q[:])
+	assert.Equal(t, ErrElemNotInField, err)
+	assert.Panics(t, func() { HexStringToHash(q.Hex()) })
+}
@@ -0,0 +1,298 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+)
+
+// The fixtures are deterministic trees with their root and proofs, shared
+// with the implementations of other languages and with the circuits, so that
+// a discrepancy between them is found by the tests instead of at proving
+// time.  The proofs are given both serialized and as the input signals of
+// the SMTVerifier circuit of circomlib, which follows the same conventions
+// as this package:
+//
+//   - A leaf is H(hIndex, hValue, 1), a middle node is H(childL, childR) and
+//     an empty node is 0, with H being Poseidon.
+//   - The leaves are at the first level where their path is unique, and the
+//     siblings are given from the root, padded with 0 up to the levels of
+//     the tree.
+//   - A proof of non-existence gives the leaf found in the path (oldKey,
+//     oldValue), or isOld0 = 1 if the path ends in an empty node.
+//
+// The exception is the path of a key: circomlib takes the bit n of the key
+// (from the least significant one) at the level n, while this tree takes the
+// bit n of the bytes of the key read as a big-endian integer.  The circuits
+// that verify the proofs of this tree must reorder the bits of the key with
+// CircomPathBit, and the keys can't be given already reordered because the
+// leaf is hashed with the same key signal.  testVectors/circom has such a
+// circuit, built from the templates of circomlib, and the script that checks
+// the fixtures with it, run by `make circom-fixtures`.
+
+var (
+	// ErrInvalidFixture is used when a fixture doesn't match the tree built
+	// from its entries, or its proofs aren't valid.
+	ErrInvalidFixture = errors.New("the merkle tree fixture is invalid")
+)
+
+// Fixture is a tree built from Entries, with its Root and the Proofs of
+// existence of the entries and of non-existence of other hIndexes.
+type Fixture struct {
+	Name      string          `json:"name"`
+	MaxLevels int             `json:"maxLevels"`
+	Entries   []*Entry        `json:"entries"`
+	Root      *Hash           `json:"root"`
+	Proofs    []*FixtureProof `json:"proofs"`
+}
+
+// FixtureProof is a proof of a Fixture.  HValue is only set in the proofs of
+// existence.
+type FixtureProof struct {
+	HIndex *Hash         `json:"hIndex"`
+	HValue *Hash         `json:"hValue,omitempty"`
+	Proof  *Proof        `json:"proof"`
+	Circom *CircomInputs `json:"circom"`
+}
+
+// CircomInputs are the input signals of the SMTVerifier circuit of circomlib
+// for a proof, as decimal strings like in the input.json of snarkjs.  Fnc is
+// 0 for a proof of existence and 1 for a proof of non-existence.
+type CircomInputs struct {
+	Enabled  string   `json:"enabled"`
+	Fnc      string   `json:"fnc"`
+	Root     string   `json:"root"`
+	Siblings []string `json:"siblings"`
+	OldKey   string   `json:"oldKey"`
+	OldValue string   `json:"oldValue"`
+	IsOld0   string   `json:"isOld0"`
+	Key      string   `json:"key"`
+	Value    string   `json:"value"`
+}
+
+// CircomPathBit returns the index of the bit of the key, as output by the
+// Num2Bits of circomlib, that selects the child at level in this tree.
+func CircomPathBit(level int) int {
+	return (ElemBytesLen-1-level/8)*8 + level%8
+}
+
+// NewCircomInputs returns the input signals of the SMTVerifier circuit with
+// levels for the proof of hIndex under root.  hValue is ignored in a proof
+// of non-existence.
+func NewCircomInputs(root *Hash, proof *Proof, hIndex, hValue *Hash, levels int) (*CircomInputs, error) {
+	if int(proof.depth) > levels {
+		return nil, ErrReachedMaxLevel
+	}
+	ci := &CircomInputs{
+		Enabled:  "1",
+		Fnc:      "0",
+		Root:     root.BigInt().String(),
+		Siblings: make([]string, levels),
+		OldKey:   "0",
+		OldValue: "0",
+		IsOld0:   "0",
+		Key:      hIndex.BigInt().String(),
+		Value:    "0",
+	}
+	for i := range ci.Siblings {
+		ci.Siblings[i] = "0"
+	}
+	for i, sibling := range proof.AllSiblings() {
+		ci.Siblings[i] = sibling.BigInt().String()
+	}
+	if proof.Existence {
+		ci.Value = hValue.BigInt().String()
+		return ci, nil
+	}
+	ci.Fnc = "1"
+	if oldKey, oldValue, ok := proof.NodeAux(); ok {
+		ci.OldKey, ci.OldValue = oldKey.BigInt().String(), oldValue.BigInt().String()
+	} else {
+		ci.IsOld0 = "1"
+	}
+	return ci, nil
+}
+
+// parseSignal parses a decimal input signal into a Hash.
+func parseSignal(s string) (*Hash, error) {
+	b, ok := new(big.Int).SetString(s, 10)
+	if !ok {
+		return nil, fmt.Errorf("%w: invalid signal %q", ErrInvalidFixture, s)
+	}
+	return NewHashFromBigInt(b)
+}
+
+// RootFromCircomInputs calculates the root like the SMTVerifier circuit of
+// circomlib from its input signals, with the path of the key reordered by
+// CircomPathBit.
+func RootFromCircomInputs(ci *CircomInputs) (*Hash, error) {
+	siblings := make([]*Hash, len(ci.Siblings))
+	for i, s := range ci.Siblings {
+		sibling, err := parseSignal(s)
+		if err != nil {
+			return nil, err
+		}
+		siblings[i] = sibling
+	}
+	signals := make([]*Hash, 4)
+	for i, s := range []string{ci.OldKey, ci.OldValue, ci.Key, ci.Value} {
+		signal, err := parseSignal(s)
+		if err != nil {
+			return nil, err
+		}
+		signals[i] = signal
+	}
+	oldKey, oldValue, key, value := signals[0], signals[1], signals[2], signals[3]
+
+	// The leaf is at the level after the last non-empty sibling, and the
+	// last sibling must be empty.
+	if len(siblings) == 0 || !siblings[len(siblings)-1].Equals(&HashZero) {
+		return nil, fmt.Errorf("%w: the last sibling is not empty", ErrInvalidFixture)
+	}
+	levIns := 0
+	for i, sibling := range siblings {
+		if !sibling.Equals(&HashZero) {
+			levIns = i + 1
+		}
+	}
+	var mid *Hash
+	switch {
+	case ci.Fnc == "0":
+		mid = LeafKey(key, value)
+	case ci.Fnc == "1" && ci.IsOld0 == "1":
+		mid = &HashZero
+	case ci.Fnc == "1" && ci.IsOld0 == "0":
+		if oldKey.Equals(key) {
+			return nil, fmt.Errorf("%w: oldKey equal to key", ErrInvalidFixture)
+		}
+		mid = LeafKey(oldKey, oldValue)
+	default:
+		return nil, fmt.Errorf("%w: invalid fnc or isOld0", ErrInvalidFixture)
+	}
+	keyInt := key.BigInt()
+	for lvl := levIns - 1; lvl >= 0; lvl-- {
+		if keyInt.Bit(CircomPathBit(lvl)) == 1 {
+			mid = HashElems(ElemBytes(*siblings[lvl]), ElemBytes(*mid))
+		} else {
+			mid = HashElems(ElemBytes(*mid), ElemBytes(*siblings[lvl]))
+		}
+	}
+	return mid, nil
+}
+
+// NewFixture builds a tree with maxLevels from entries and returns the
+// Fixture with the proofs of existence of the entries, followed by the
+// proofs of non-existence of nonExistent.
+func NewFixture(name string, maxLevels int, entries []*Entry, nonExistent []*Hash) (*Fixture, error) {
+	mt, err := NewMerkleTreeInMemory(maxLevels)
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		if err := mt.AddEntry(e); err != nil {
+			return nil, err
+		}
+	}
+	f := &Fixture{Name: name, MaxLevels: maxLevels, Entries: entries, Root: mt.RootKey()}
+	addProof := func(hIndex, hValue *Hash) error {
+		proof, err := mt.GenerateProof(hIndex, nil)
+		if err != nil {
+			return err
+		}
+		circom, err := NewCircomInputs(f.Root, proof, hIndex, hValue, maxLevels)
+		if err != nil {
+			return err
+		}
+		f.Proofs = append(f.Proofs, &FixtureProof{HIndex: hIndex, HValue: hValue, Proof: proof, Circom: circom})
+		return nil
+	}
+	for _, e := range entries {
+		if err := addProof(e.HIndex(), e.HValue()); err != nil {
+			return nil, err
+		}
+	}
+	for _, hIndex := range nonExistent {
+		if err := addProof(hIndex, nil); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Verify checks that the tree built from the entries of the fixture has its
+// root and proofs, and that the proofs and the circomlib input signals are
+// valid.
+func (f *Fixture) Verify() error {
+	var nonExistent []*Hash
+	for _, p := range f.Proofs {
+		if p.Proof == nil || p.Circom == nil || p.HIndex == nil {
+			return fmt.Errorf("%w: %v: incomplete proof", ErrInvalidFixture, f.Name)
+		}
+		if !p.Proof.Existence {
+			nonExistent = append(nonExistent, p.HIndex)
+		}
+	}
+	f2, err := NewFixture(f.Name, f.MaxLevels, f.Entries, nonExistent)
+	if err != nil {
+		return err
+	}
+	if f.Root == nil || !f.Root.Equals(f2.Root) {
+		return fmt.Errorf("%w: %v: %v", ErrInvalidFixture, f.Name, ErrRootMismatch)
+	}
+	if len(f.Proofs) != len(f2.Proofs) {
+		return fmt.Errorf("%w: %v: the proofs of existence don't match the entries", ErrInvalidFixture, f.Name)
+	}
+	for i, p := range f.Proofs {
+		p2 := f2.Proofs[i]
+		if !p.HIndex.Equals(p2.HIndex) || !bytes.Equal(p.Proof.Bytes(), p2.Proof.Bytes()) {
+			return fmt.Errorf("%w: %v: proof %d doesn't match the tree", ErrInvalidFixture, f.Name, i)
+		}
+		hValue := p.HValue
+		if hValue == nil {
+			hValue = &HashZero
+		}
+		if !VerifyProof(f.Root, p.Proof, p.HIndex, hValue) {
+			return fmt.Errorf("%w: %v: proof %d doesn't verify", ErrInvalidFixture, f.Name, i)
+		}
+		circom, err := json.Marshal(p.Circom)
+		if err != nil {
+			return err
+		}
+		circom2, err := json.Marshal(p2.Circom)
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(circom, circom2) {
+			return fmt.Errorf("%w: %v: circom inputs %d don't match the proof", ErrInvalidFixture, f.Name, i)
+		}
+		root, err := RootFromCircomInputs(p.Circom)
+		if err != nil {
+			return err
+		}
+		if !root.Equals(f.Root) {
+			return fmt.Errorf("%w: %v: circom inputs %d don't verify", ErrInvalidFixture, f.Name, i)
+		}
+	}
+	return nil
+}
+
+// LoadFixtures reads the fixtures in the JSON file path and verifies them.
+func LoadFixtures(path string) ([]*Fixture, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var fixtures []*Fixture
+	if err := json.Unmarshal(b, &fixtures); err != nil {
+		return nil, err
+	}
+	for _, f := range fixtures {
+		if err := f.Verify(); err != nil {
+			return nil, err
+		}
+	}
+	return fixtures, nil
+}
@@ -0,0 +1,133 @@
+package merkletree
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var fixturesPath = path.Join("testVectors", "fixtures.json")
+
+func newFixtureEntries(from, n int64) []*Entry {
+	entries := make([]*Entry, n)
+	for i := range entries {
+		v := from + int64(i)
+		e := NewEntryFromInts(0, 0, 0, v, 0, 0, v*2, v*3)
+		entries[i] = &e
+	}
+	return entries
+}
+
+func newFixtureHIndexes(from, n int64) []*Hash {
+	hIndexes := make([]*Hash, n)
+	for i, e := range newFixtureEntries(from, n) {
+		hIndexes[i] = e.HIndex()
+	}
+	return hIndexes
+}
+
+func newFixtures(t *testing.T) []*Fixture {
+	var fixtures []*Fixture
+	for _, tc := range []struct {
+		name        string
+		entries     []*Entry
+		nonExistent []*Hash
+	}{
+		{"empty", newFixtureEntries(1, 0), newFixtureHIndexes(100, 2)},
+		{"single", newFixtureEntries(1, 1), newFixtureHIndexes(100, 2)},
+		{"two", newFixtureEntries(1, 2), newFixtureHIndexes(100, 4)},
+		{"sixteen", newFixtureEntries(1, 16), newFixtureHIndexes(100, 8)},
+	} {
+		f, err := NewFixture(tc.name, 32, tc.entries, tc.nonExistent)
+		require.Nil(t, err)
+		fixtures = append(fixtures, f)
+	}
+	return fixtures
+}
+
+func TestFixtures(t *testing.T) {
+	fixtures := newFixtures(t)
+	b, err := json.MarshalIndent(fixtures, "", "  ")
+	require.Nil(t, err)
+	if generateTest {
+		require.Nil(t, ioutil.WriteFile(fixturesPath, append(b, '\n'), 0644))
+	}
+	fixturesJSON, err := ioutil.ReadFile(fixturesPath)
+	require.Nil(t, err)
+	assert.JSONEq(t, string(fixturesJSON), string(b))
+
+	loaded, err := LoadFixtures(fixturesPath)
+	require.Nil(t, err)
+	require.Equal(t, len(fixtures), len(loaded))
+	var existence, nonExistence, nodeAux int
+	for _, f := range loaded {
+		for _, p := range f.Proofs {
+			if p.Proof.Existence {
+				existence++
+			} else if _, _, ok := p.Proof.NodeAux(); ok {
+				nodeAux++
+			} else {
+				nonExistence++
+			}
+		}
+	}
+	// The fixtures cover every kind of proof.
+	assert.NotZero(t, existence)
+	assert.NotZero(t, nonExistence)
+	assert.NotZero(t, nodeAux)
+}
+
+func TestFixtureVerify(t *testing.T) {
+	f := newFixtures(t)[3]
+	require.Nil(t, f.Verify())
+
+	tamper := func(modify func(f *Fixture)) error {
+		var f2 Fixture
+		b, err := json.Marshal(f)
+		require.Nil(t, err)
+		require.Nil(t, json.Unmarshal(b, &f2))
+		modify(&f2)
+		return f2.Verify()
+	}
+	err := tamper(func(f *Fixture) { f.Root = &Hash{1} })
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	err = tamper(func(f *Fixture) { f.Entries = f.Entries[1:] })
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	err = tamper(func(f *Fixture) { f.Proofs[0].Circom.Value = "1" })
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	err = tamper(func(f *Fixture) { f.Proofs[0].Circom.Siblings[0] = "x" })
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+}
+
+func TestRootFromCircomInputs(t *testing.T) {
+	for _, f := range newFixtures(t) {
+		for _, p := range f.Proofs {
+			root, err := RootFromCircomInputs(p.Circom)
+			require.Nil(t, err)
+			assert.Equal(t, f.Root, root)
+		}
+	}
+
+	// CircomPathBit reorders the bits of the key into the path of the tree,
+	// which is not the one of circomlib.
+	f := newFixtures(t)[3]
+	for lvl := 0; lvl < f.MaxLevels; lvl++ {
+		assert.Equal(t, getPath(f.MaxLevels, f.Proofs[0].HIndex)[lvl],
+			f.Proofs[0].HIndex.BigInt().Bit(CircomPathBit(lvl)) == 1)
+	}
+	assert.NotEqual(t, 0, CircomPathBit(0))
+
+	ci := *f.Proofs[0].Circom
+	ci.Siblings = append(ci.Siblings[:len(ci.Siblings)-1], "1")
+	_, err := RootFromCircomInputs(&ci)
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+	ci = *f.Proofs[len(f.Proofs)-1].Circom
+	ci.IsOld0, ci.OldKey = "0", ci.Key
+	_, err = RootFromCircomInputs(&ci)
+	assert.True(t, errors.Is(err, ErrInvalidFixture))
+}
@@ -0,0 +1,143 @@
+package merkletree
+
+import (
+	"crypto/sha256"
+	"errors"
+	"sync"
+)
+
+var (
+	// ErrHasherUnknown is used when the hasher persisted in the storage of
+	// a MerkleTree is not registered.
+	ErrHasherUnknown = errors.New("the hasher of the merkle tree is not registered")
+	// ErrHasherMismatch is used when a MerkleTree is opened with a hasher
+	// different than the one it was created with.
+	ErrHasherMismatch = errors.New("the hasher doesn't match the one of the merkle tree")
+)
+
+// Hasher is the hash function used by a MerkleTree to compute the hIndex and
+// hValue of the entries and the keys of the nodes.  The result must be inside
+// the Finite Field.
+type Hasher interface {
+	// Name identifies the hash function and its parameters.  It's
+	// persisted in the storage of the MerkleTree, so it must not change.
+	Name() string
+	// HashElems hashes the elements.
+	HashElems(elems ...ElemBytes) *Hash
+}
+
+// PoseidonHasher is the Hasher of the Poseidon hash function, used by
+// default, suited for the trees whose proofs are verified in circuits.
+type PoseidonHasher struct{}
+
+// Name returns "poseidon".
+func (PoseidonHasher) Name() string { return "poseidon" }
+
+// HashElems performs a Poseidon hash over the elements.
+func (PoseidonHasher) HashElems(elems ...ElemBytes) *Hash { return HashElems(elems...) }
+
+// Sha256Hasher is a Hasher of SHA-256 with the most significant byte
+// cleared to fit in the Finite Field.  It's faster than Poseidon, suited for
+// trees whose proofs are never verified in circuits.
+type Sha256Hasher struct{}
+
+// Name returns "sha256".
+func (Sha256Hasher) Name() string { return "sha256" }
+
+// HashElems performs a SHA-256 hash over the concatenation of the elements.
+func (Sha256Hasher) HashElems(elems ...ElemBytes) *Hash {
+	var h Hash = sha256.Sum256(ElemsBytesToBytes(elems))
+	h[ElemBytesLen-1] = 0
+	return &h
+}
+
+var (
+	// HasherDefault is the Hasher used by the MerkleTrees created without
+	// an explicit one, and by the trees created before the hasher was
+	// persisted.
+	HasherDefault Hasher = PoseidonHasher{}
+
+	hashersLock sync.RWMutex
+	hashers     = map[string]Hasher{}
+)
+
+func init() {
+	RegisterHasher(PoseidonHasher{})
+	RegisterHasher(Sha256Hasher{})
+}
+
+// RegisterHasher registers h so that the MerkleTrees created with it can be
+// opened without passing it explicitly.
+func RegisterHasher(h Hasher) {
+	hashersLock.Lock()
+	defer hashersLock.Unlock()
+	hashers[h.Name()] = h
+}
+
+// HasherByName returns the registered Hasher with name.
+func HasherByName(name string) (Hasher, error) {
+	hashersLock.RLock()
+	defer hashersLock.RUnlock()
+	h, ok := hashers[name]
+	if !ok {
+		return nil, ErrHasherUnknown
+	}
+	return h, nil
+}
+
+// isHasherDefault returns true if h is the hasher used by the cached hashes of
+// Entry and Node.
+func isHasherDefault(h Hasher) bool {
+	return h == nil || h.Name() == (PoseidonHasher{}).Name()
+}
+
+// entryHIndex returns the hIndex of e computed with h.
+func entryHIndex(h Hasher, e *Entry) *Hash {
+	if isHasherDefault(h) {
+		return e.HIndex()
+	}
+	return h.HashElems(e.Index()...)
+}
+
+// entryHValue returns the hValue of e computed with h.
+func entryHValue(h Hasher, e *Entry) *Hash {
+	if isHasherDefault(h) {
+		return e.HValue()
+	}
+	return h.HashElems(e.Value()...)
+}
+
+// leafKey returns the key of a leaf node computed with h (see LeafKey).
+func leafKey(h Hasher, hIndex, hValue *Hash) *Hash {
+	if isHasherDefault(h) {
+		return LeafKey(hIndex, hValue)
+	}
+	return h.HashElems(ElemBytes(*hIndex), ElemBytes(*hValue), ElemBytes{1})
+}
+
+// nodeKey returns the key of n computed with h (see Node.Key).
+func nodeKey(h Hasher, n *Node) *Hash {
+	if isHasherDefault(h) {
+		return n.Key()
+	}
+	switch n.Type {
+	case NodeTypeMiddle:
+		return h.HashElems(ElemBytes(*n.ChildL), ElemBytes(*n.ChildR))
+	case NodeTypeLeaf:
+		return leafKey(h, entryHIndex(h, n.Entry), entryHValue(h, n.Entry))
+	default:
+		return &HashZero
+	}
+}
+
+// EntryHIndex returns the hIndex of e computed with the MT hash function, to
+// be used in GetDataByIndex and GenerateProof.
+func (mt *MerkleTree) EntryHIndex(e *Entry) *Hash {
+	return entryHIndex(mt.hasher, e)
+}
+
+// EntryHValue returns the hValue of e computed with the MT hash function, to
+// be used in VerifyProofWithHasher.
+func (mt *MerkleTree) EntryHValue(e *Entry) *Hash {
+	return entryHValue(mt.hasher, e)
+}
@@ -0,0 +1,105 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hasherTest struct{ Sha256Hasher }
+
+func (hasherTest) Name() string { return "test" }
+
+func TestHasher(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	mt, err := NewMerkleTreeWithHasher(storage, 140, Sha256Hasher{})
+	require.Nil(t, err)
+	mtPoseidon, err := NewMerkleTree(db.NewMemoryStorage(), 140)
+	require.Nil(t, err)
+	assert.Equal(t, HasherDefault, mtPoseidon.Hasher())
+
+	entries := []Entry{}
+	for i := int64(0); i < 8; i++ {
+		e := NewEntryFromInts(i, 0, 0, 0, i, 0, 0, 0)
+		entries = append(entries, e)
+		require.Nil(t, mt.AddEntry(&e))
+		require.Nil(t, mtPoseidon.AddEntry(&e))
+	}
+	assert.NotEqual(t, mt.RootKey(), mtPoseidon.RootKey())
+
+	e := entries[3]
+	hIndex, hValue := mt.EntryHIndex(&e), mt.EntryHValue(&e)
+	assert.NotEqual(t, e.HIndex(), hIndex)
+	data, err := mt.GetDataByIndex(hIndex)
+	require.Nil(t, err)
+	assert.Equal(t, e.Data, *data)
+	proof, err := mt.GenerateProof(hIndex, nil)
+	require.Nil(t, err)
+	assert.True(t, proof.Existence)
+	assert.True(t, VerifyProofWithHasher(mt.Hasher(), mt.RootKey(), proof, hIndex, hValue))
+	assert.False(t, VerifyProof(mt.RootKey(), proof, hIndex, hValue))
+
+	// Non-existence proof
+	e = NewEntryFromInts(100, 0, 0, 0, 0, 0, 0, 0)
+	hIndex, hValue = mt.EntryHIndex(&e), mt.EntryHValue(&e)
+	proof, err = mt.GenerateProof(hIndex, nil)
+	require.Nil(t, err)
+	assert.False(t, proof.Existence)
+	assert.True(t, VerifyProofWithHasher(mt.Hasher(), mt.RootKey(), proof, hIndex, hValue))
+
+	// The snapshots use the same hasher
+	snapshot, err := mt.Snapshot(mt.RootKey())
+	require.Nil(t, err)
+	assert.Equal(t, mt.Hasher(), snapshot.Hasher())
+
+	// The hasher is persisted in the storage
+	mt2, err := NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	assert.Equal(t, Sha256Hasher{}, mt2.Hasher())
+	assert.Equal(t, mt.RootKey(), mt2.RootKey())
+	_, err = NewMerkleTreeWithHasher(storage, 140, PoseidonHasher{})
+	assert.Equal(t, ErrHasherMismatch, err)
+}
+
+func TestHasherLegacyTree(t *testing.T) {
+	// A tree without the persisted hasher uses the HasherDefault
+	storage := db.NewMemoryStorage()
+	mt, err := NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	e := NewEntryFromInts(1, 0, 0, 0, 0, 0, 0, 0)
+	require.Nil(t, mt.AddEntry(&e))
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	tx.Delete(hasherNodeValue)
+	tx.Delete(metadataNodeValue)
+	require.Nil(t, tx.Commit())
+
+	_, err = NewMerkleTreeWithHasher(storage, 140, Sha256Hasher{})
+	assert.Equal(t, ErrHasherMismatch, err)
+	mt2, err := NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	assert.Equal(t, HasherDefault, mt2.Hasher())
+	assert.Equal(t, mt.RootKey(), mt2.RootKey())
+	_, _, err = mt2.dbGet(hasherNodeValue)
+	assert.Nil(t, err)
+	md, err := ReadMetadata(storage)
+	require.Nil(t, err)
+	assert.Equal(t, &Metadata{Version: MetadataVersion, MaxLevels: 140, Hasher: HasherDefault.Name()}, md)
+}
+
+func TestHasherUnknown(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	mt, err := NewMerkleTreeWithHasher(storage, 140, hasherTest{})
+	require.Nil(t, err)
+	e := NewEntryFromInts(1, 0, 0, 0, 0, 0, 0, 0)
+	require.Nil(t, mt.AddEntry(&e))
+
+	_, err = NewMerkleTree(storage, 140)
+	assert.Equal(t, ErrHasherUnknown, err)
+	RegisterHasher(hasherTest{})
+	mt2, err := NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	assert.Equal(t, mt.RootKey(), mt2.RootKey())
+}
@@ -0,0 +1,361 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// The mapped tree format is a dump of the nodes of a MerkleTree that can be
+// memory-mapped and queried in place.  All the integers are big-endian.
+//
+//	header:
+//	  magic       [8]byte  "iden3mmt"
+//	  version     uint8
+//	  maxLevels   uint32
+//	  hasherLen   uint8
+//	  hasher      [hasherLen]byte
+//	  rootKey     [32]byte
+//	  nodes       uint64
+//	index:  nodes entries sorted by key
+//	  key         [32]byte
+//	  offset      uint64   (relative to the start of the values)
+//	  length      uint32
+//	values: the node values, concatenated
+const (
+	mappedVersion       = 1
+	mappedIndexEntryLen = ElemBytesLen + 8 + 4
+)
+
+var mappedMagic = []byte("iden3mmt")
+
+var (
+	// ErrMappedTreeInvalid is used when the data of a mapped tree is
+	// malformed.
+	ErrMappedTreeInvalid = errors.New("invalid mapped tree")
+	// ErrMappedTreeClosed is used when a mapped tree is read after its
+	// storage has been closed.
+	ErrMappedTreeClosed = errors.New("mapped tree is closed")
+)
+
+// DumpMappedTree writes all the nodes of the tree with the given rootKey in
+// the mapped tree format, to be loaded with OpenMappedTree.  If rootKey is
+// nil, the current root of the MerkleTree is used.
+func (mt *MerkleTree) DumpMappedTree(w io.Writer, rootKey *Hash) error {
+	if rootKey == nil {
+		rootKey = mt.RootKey()
+	}
+	kvs := []db.KV{}
+	if err := mt.Walk(rootKey, func(n *Node) {
+		if n.Type != NodeTypeEmpty {
+			kvs = append(kvs, db.KV{K: nodeKey(mt.hasher, n)[:], V: n.Value()})
+		}
+	}); err != nil {
+		return err
+	}
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i].K, kvs[j].K) < 0 })
+
+	hasherName := mt.hasher.Name()
+	if len(hasherName) > 0xff {
+		return ErrMappedTreeInvalid
+	}
+	header := make([]byte, 0, len(mappedMagic)+1+4+1+len(hasherName)+ElemBytesLen+8)
+	header = append(header, mappedMagic...)
+	header = append(header, mappedVersion)
+	header = appendUint32(header, uint32(mt.maxLevels))
+	header = append(header, byte(len(hasherName)))
+	header = append(header, hasherName...)
+	header = append(header, rootKey[:]...)
+	header = appendUint64(header, uint64(len(kvs)))
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	index := make([]byte, 0, len(kvs)*mappedIndexEntryLen)
+	offset := uint64(0)
+	for _, kv := range kvs {
+		index = append(index, kv.K...)
+		index = appendUint64(index, offset)
+		index = appendUint32(index, uint32(len(kv.V)))
+		offset += uint64(len(kv.V))
+	}
+	if _, err := w.Write(index); err != nil {
+		return err
+	}
+	for _, kv := range kvs {
+		if _, err := w.Write(kv.V); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+	return append(b, buf[:]...)
+}
+
+// mappedTree is the data of a mapped tree shared by the mappedStorages of
+// the same tree.
+type mappedTree struct {
+	rw        sync.RWMutex
+	data      []byte
+	index     []byte
+	values    []byte
+	nodes     int
+	maxLevels int
+	hasher    []byte
+	rootKey   Hash
+	// unmap releases data.  It's nil when data is not mapped.
+	unmap func([]byte) error
+}
+
+// parseMappedTree parses the header of the mapped tree in data.
+func parseMappedTree(data []byte) (*mappedTree, error) {
+	m := &mappedTree{data: data}
+	b := data
+	if len(b) < len(mappedMagic)+1+4+1 || !bytes.Equal(b[:len(mappedMagic)], mappedMagic) {
+		return nil, ErrMappedTreeInvalid
+	}
+	b = b[len(mappedMagic):]
+	if b[0] != mappedVersion {
+		return nil, ErrMappedTreeInvalid
+	}
+	m.maxLevels = int(binary.BigEndian.Uint32(b[1:5]))
+	hasherLen := int(b[5])
+	b = b[6:]
+	if len(b) < hasherLen+ElemBytesLen+8 {
+		return nil, ErrMappedTreeInvalid
+	}
+	m.hasher = b[:hasherLen]
+	copy(m.rootKey[:], b[hasherLen:hasherLen+ElemBytesLen])
+	nodes := binary.BigEndian.Uint64(b[hasherLen+ElemBytesLen:])
+	b = b[hasherLen+ElemBytesLen+8:]
+	if nodes > uint64(len(b)/mappedIndexEntryLen) {
+		return nil, ErrMappedTreeInvalid
+	}
+	m.nodes = int(nodes)
+	m.index = b[:m.nodes*mappedIndexEntryLen]
+	m.values = b[m.nodes*mappedIndexEntryLen:]
+	// The values are written in the order of the index, so the last one
+	// must end at the end of the data.
+	if m.nodes > 0 {
+		entry := m.index[len(m.index)-mappedIndexEntryLen+ElemBytesLen:]
+		end := binary.BigEndian.Uint64(entry[:8]) + uint64(binary.BigEndian.Uint32(entry[8:]))
+		if end != uint64(len(m.values)) {
+			return nil, ErrMappedTreeInvalid
+		}
+	} else if len(m.values) != 0 {
+		return nil, ErrMappedTreeInvalid
+	}
+	return m, nil
+}
+
+// indexKey returns the key of the i-th entry of the index.
+func (m *mappedTree) indexKey(i int) []byte {
+	return m.index[i*mappedIndexEntryLen : i*mappedIndexEntryLen+ElemBytesLen]
+}
+
+// getNode does a binary search of the node with key k in the index, and
+// returns its value.  It must be called with the read lock held.
+func (m *mappedTree) getNode(k []byte) ([]byte, error) {
+	i := sort.Search(m.nodes, func(i int) bool { return bytes.Compare(m.indexKey(i), k) >= 0 })
+	if i == m.nodes || !bytes.Equal(m.indexKey(i), k) {
+		return nil, db.ErrNotFound
+	}
+	return m.value(i)
+}
+
+// value returns the value of the i-th entry of the index.  It must be called
+// with the read lock held.
+func (m *mappedTree) value(i int) ([]byte, error) {
+	entry := m.index[i*mappedIndexEntryLen+ElemBytesLen : (i+1)*mappedIndexEntryLen]
+	offset := binary.BigEndian.Uint64(entry[:8])
+	length := uint64(binary.BigEndian.Uint32(entry[8:]))
+	if offset > uint64(len(m.values)) || length > uint64(len(m.values))-offset {
+		return nil, ErrMappedTreeInvalid
+	}
+	return m.values[offset : offset+length : offset+length], nil
+}
+
+// get returns a copy of the value of k, so that it stays valid after the
+// tree is unmapped.
+func (m *mappedTree) get(k []byte) ([]byte, error) {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	if m.data == nil {
+		return nil, ErrMappedTreeClosed
+	}
+	switch {
+	case bytes.Equal(k, rootNodeValue):
+		return append([]byte{byte(DBEntryTypeRoot)}, m.rootKey[:]...), nil
+	case bytes.Equal(k, hasherNodeValue):
+		return append([]byte{byte(DBEntryTypeHasher)}, m.hasher...), nil
+	case bytes.Equal(k, metadataNodeValue):
+		md := Metadata{Version: MetadataVersion, MaxLevels: m.maxLevels, Hasher: string(m.hasher)}
+		return append([]byte{byte(DBEntryTypeMetadata)}, md.Bytes()...), nil
+	case len(k) != ElemBytesLen:
+		return nil, db.ErrNotFound
+	}
+	v, err := m.getNode(k)
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte{}, v...), nil
+}
+
+// close releases the data of the tree.  Unmapping only fails if the data
+// was not mapped, so the error is ignored.
+func (m *mappedTree) close() {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	if m.data == nil {
+		return
+	}
+	data := m.data
+	m.data, m.index, m.values, m.hasher = nil, nil, nil, nil
+	if m.unmap != nil {
+		m.unmap(data)
+	}
+}
+
+// mappedStorage is a read-only db.Storage that serves the nodes of a mapped
+// tree directly from its data, without loading them into a database.
+type mappedStorage struct {
+	prefix []byte
+	m      *mappedTree
+}
+
+func (s *mappedStorage) key(k []byte) []byte {
+	if len(s.prefix) == 0 {
+		return k
+	}
+	return append(append([]byte{}, s.prefix...), k...)
+}
+
+func (s *mappedStorage) Info() string {
+	return "mapped merkletree"
+}
+
+func (s *mappedStorage) WithPrefix(prefix []byte) db.Storage {
+	return &mappedStorage{prefix: append(append([]byte{}, s.prefix...), prefix...), m: s.m}
+}
+
+// NewTx fails with ErrNotWritable, as a mapped tree is read-only.
+func (s *mappedStorage) NewTx() (db.Tx, error) {
+	return nil, ErrNotWritable
+}
+
+func (s *mappedStorage) Get(k []byte) ([]byte, error) {
+	return s.m.get(s.key(k))
+}
+
+// Iterate iterates over the nodes of the tree in key order.
+func (s *mappedStorage) Iterate(f func([]byte, []byte) (bool, error)) error {
+	s.m.rw.RLock()
+	defer s.m.rw.RUnlock()
+	if s.m.data == nil {
+		return ErrMappedTreeClosed
+	}
+	for i := 0; i < s.m.nodes; i++ {
+		k := s.m.indexKey(i)
+		if !bytes.HasPrefix(k, s.prefix) {
+			continue
+		}
+		v, err := s.m.value(i)
+		if err != nil {
+			return err
+		}
+		if cont, err := f(k[len(s.prefix):], v); err != nil {
+			return err
+		} else if !cont {
+			break
+		}
+	}
+	return nil
+}
+
+func (s *mappedStorage) List(limit int) ([]db.KV, error) {
+	ret := []db.KV{}
+	err := s.Iterate(func(k []byte, v []byte) (bool, error) {
+		ret = append(ret, db.KV{K: append([]byte{}, k...), V: append([]byte{}, v...)})
+		if len(ret) == limit {
+			return false, nil
+		}
+		return true, nil
+	})
+	return ret, err
+}
+
+// Close unmaps the tree.  Any later read fails with ErrMappedTreeClosed.
+func (s *mappedStorage) Close() {
+	s.m.close()
+}
+
+// NewMappedTree returns a read-only MerkleTree that serves the nodes
+// directly from data, dumped with DumpMappedTree.  Looking up a node is a
+// binary search over the index, so no node is loaded in advance.
+func NewMappedTree(data []byte) (*MerkleTree, error) {
+	m, err := parseMappedTree(data)
+	if err != nil {
+		return nil, err
+	}
+	return newMappedMerkleTree(m)
+}
+
+// OpenMappedTree memory-maps the file at path, dumped with DumpMappedTree,
+// and returns a read-only MerkleTree that serves the nodes from the mapping.
+// It's suited for verifiers that load the trees of many issuers: the nodes
+// are read from the file on demand and cached by the operating system.  The
+// mapping is released by closing the Storage of the tree.
+func OpenMappedTree(path string) (*MerkleTree, error) {
+	data, unmap, err := mmapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	m, err := parseMappedTree(data)
+	if err != nil {
+		if unmap != nil {
+			unmap(data)
+		}
+		return nil, err
+	}
+	m.unmap = unmap
+	mt, err := newMappedMerkleTree(m)
+	if err != nil {
+		m.close()
+		return nil, err
+	}
+	return mt, nil
+}
+
+func newMappedMerkleTree(m *mappedTree) (*MerkleTree, error) {
+	hasher, err := HasherByName(string(m.hasher))
+	if err != nil {
+		return nil, err
+	}
+	mt := &MerkleTree{
+		storage:   &mappedStorage{prefix: []byte{}, m: m},
+		maxLevels: m.maxLevels,
+		writable:  false,
+		hasher:    hasher,
+	}
+	rootKey := m.rootKey
+	mt.rootKey = &rootKey
+	if _, err := mt.GetNode(mt.rootKey); err != nil {
+		return nil, ErrMappedTreeInvalid
+	}
+	return mt, nil
+}
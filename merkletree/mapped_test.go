@@ -0,0 +1,121 @@
+package merkletree
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMappedTestEntry(i int) *Entry {
+	var indexSlot [800 / 8]byte
+	var dataSlot [960 / 8]byte
+	copy(indexSlot[:], strconv.Itoa(i))
+	return newClaimBasicEntry(indexSlot, dataSlot)
+}
+
+func TestMappedTree(t *testing.T) {
+	mt := newTestingMerkle(t, 140)
+	defer mt.Storage().Close()
+	for i := 0; i < 64; i++ {
+		require.Nil(t, mt.AddEntry(newMappedTestEntry(i)))
+	}
+
+	dir, err := ioutil.TempDir("", "mappedtree")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tree.mmt")
+	f, err := os.Create(path)
+	require.Nil(t, err)
+	require.Nil(t, mt.DumpMappedTree(f, nil))
+	require.Nil(t, f.Close())
+
+	mmt, err := OpenMappedTree(path)
+	require.Nil(t, err)
+	assert.Equal(t, mt.RootKey(), mmt.RootKey())
+	assert.Equal(t, mt.MaxLevels(), mmt.MaxLevels())
+	assert.Equal(t, mt.Hasher(), mmt.Hasher())
+
+	// The mapped tree produces the same proofs as the original one
+	for _, i := range []int{0, 7, 63, 64} {
+		e := newMappedTestEntry(i)
+		proof, err := mt.GenerateProof(e.HIndex(), nil)
+		require.Nil(t, err)
+		mproof, err := mmt.GenerateProof(e.HIndex(), nil)
+		require.Nil(t, err)
+		assert.Equal(t, proof.Bytes(), mproof.Bytes())
+		assert.Equal(t, i < 64, mproof.Existence)
+		assert.True(t, VerifyProof(mmt.RootKey(), mproof, e.HIndex(), e.HValue()))
+	}
+	e := newMappedTestEntry(7)
+	data, err := mmt.GetDataByIndex(e.HIndex())
+	require.Nil(t, err)
+	assert.Equal(t, e.Data, *data)
+
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, mt.DumpTree(w, nil))
+	mw := bytes.NewBuffer(nil)
+	require.Nil(t, mmt.DumpTree(mw, nil))
+	assert.Equal(t, w.Bytes(), mw.Bytes())
+
+	assert.Equal(t, ErrNotWritable, mmt.AddEntry(newMappedTestEntry(64)))
+
+	mmt.Storage().Close()
+	_, err = mmt.GenerateProof(e.HIndex(), nil)
+	assert.Equal(t, ErrMappedTreeClosed, err)
+}
+
+func TestMappedTreeHasher(t *testing.T) {
+	mt, err := NewMerkleTreeWithHasher(db.NewMemoryStorage(), 40, Sha256Hasher{})
+	require.Nil(t, err)
+	for i := 0; i < 16; i++ {
+		require.Nil(t, mt.AddEntry(newMappedTestEntry(i)))
+	}
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, mt.DumpMappedTree(w, nil))
+
+	mmt, err := NewMappedTree(w.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, mt.RootKey(), mmt.RootKey())
+	assert.Equal(t, Sha256Hasher{}, mmt.Hasher())
+	e := newMappedTestEntry(3)
+	proof, err := mmt.GenerateProof(mmt.EntryHIndex(e), nil)
+	require.Nil(t, err)
+	assert.True(t, VerifyProofWithHasher(mmt.Hasher(), mmt.RootKey(), proof, mmt.EntryHIndex(e), mmt.EntryHValue(e)))
+}
+
+func TestMappedTreeEmpty(t *testing.T) {
+	mt := newTestingMerkle(t, 140)
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, mt.DumpMappedTree(w, nil))
+	mmt, err := NewMappedTree(w.Bytes())
+	require.Nil(t, err)
+	assert.Equal(t, &HashZero, mmt.RootKey())
+	proof, err := mmt.GenerateProof(newMappedTestEntry(0).HIndex(), nil)
+	require.Nil(t, err)
+	assert.False(t, proof.Existence)
+}
+
+func TestMappedTreeInvalid(t *testing.T) {
+	mt := newTestingMerkle(t, 140)
+	for i := 0; i < 4; i++ {
+		require.Nil(t, mt.AddEntry(newMappedTestEntry(i)))
+	}
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, mt.DumpMappedTree(w, nil))
+	dump := w.Bytes()
+
+	_, err := NewMappedTree(dump[:4])
+	assert.Equal(t, ErrMappedTreeInvalid, err)
+	_, err = NewMappedTree(append([]byte("iden3xxx"), dump[8:]...))
+	assert.Equal(t, ErrMappedTreeInvalid, err)
+	// Truncated data
+	_, err = NewMappedTree(dump[:len(dump)-1])
+	assert.Equal(t, ErrMappedTreeInvalid, err)
+}
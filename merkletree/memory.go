@@ -0,0 +1,251 @@
+package merkletree
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// arenaSlabLen is the size of each slab of the arena where the node values
+// are stored.
+const arenaSlabLen = 1 << 20
+
+// arenaRef is the location of a value in the arena.
+type arenaRef struct {
+	slab uint32
+	off  uint32
+	len  uint32
+}
+
+// arena stores the values of a memStorage.  Values are appended to large
+// slabs, so that adding a node doesn't require an allocation, and are never
+// modified once written: a Put of an existing key writes a new value and
+// the old one is left unreferenced.
+type arena struct {
+	rw    sync.RWMutex
+	slabs [][]byte
+	// nodes contains the values of the keys of length ElemBytesLen (the
+	// node keys), indexed directly by the key.
+	nodes map[Hash]arenaRef
+	// others contains the values of the rest of the keys.
+	others map[string][]byte
+}
+
+func (a *arena) alloc(v []byte) arenaRef {
+	if len(v) > arenaSlabLen {
+		a.slabs = append(a.slabs, append([]byte{}, v...))
+		return arenaRef{slab: uint32(len(a.slabs) - 1), off: 0, len: uint32(len(v))}
+	}
+	last := len(a.slabs) - 1
+	if last < 0 || len(a.slabs[last])+len(v) > cap(a.slabs[last]) {
+		a.slabs = append(a.slabs, make([]byte, 0, arenaSlabLen))
+		last++
+	}
+	off := len(a.slabs[last])
+	a.slabs[last] = append(a.slabs[last], v...)
+	return arenaRef{slab: uint32(last), off: uint32(off), len: uint32(len(v))}
+}
+
+func (a *arena) value(ref arenaRef) []byte {
+	end := ref.off + ref.len
+	return a.slabs[ref.slab][ref.off:end:end]
+}
+
+// get must be called with the read lock held.
+func (a *arena) get(k []byte) ([]byte, bool) {
+	if len(k) == ElemBytesLen {
+		var h Hash
+		copy(h[:], k)
+		ref, ok := a.nodes[h]
+		if !ok {
+			return nil, false
+		}
+		return a.value(ref), true
+	}
+	v, ok := a.others[string(k)]
+	return v, ok
+}
+
+// put must be called with the write lock held.
+func (a *arena) put(k, v []byte) {
+	if len(k) == ElemBytesLen {
+		var h Hash
+		copy(h[:], k)
+		a.nodes[h] = a.alloc(v)
+		return
+	}
+	a.others[string(k)] = append([]byte{}, v...)
+}
+
+// delete must be called with the write lock held.
+func (a *arena) delete(k []byte) {
+	if len(k) == ElemBytesLen {
+		var h Hash
+		copy(h[:], k)
+		delete(a.nodes, h)
+		return
+	}
+	delete(a.others, string(k))
+}
+
+// memStorage is a db.Storage purpose-built to hold a merkle tree in memory.
+// Node keys are used directly as map keys and node values are stored in an
+// arena, avoiding the key hashing and per value allocations of
+// db.MemoryStorage.  It's intended for trees that are built once (for example
+// imported from a dump) and then mostly read.
+type memStorage struct {
+	prefix []byte
+	a      *arena
+}
+
+// memStorageTx is a transaction of a memStorage.
+type memStorageTx struct {
+	s   *memStorage
+	kv  map[string][]byte
+	del map[string]struct{}
+}
+
+// newMemStorage creates a new empty memStorage.
+func newMemStorage() *memStorage {
+	return &memStorage{
+		prefix: []byte{},
+		a: &arena{
+			nodes:  make(map[Hash]arenaRef),
+			others: make(map[string][]byte),
+		},
+	}
+}
+
+// NewMerkleTreeInMemory creates a new Merkle Tree with maxLevels backed by a
+// storage optimized for holding the tree in memory.  It's suited for
+// services that rebuild trees from dumps (see ImportTree) and then only need
+// fast reads, like proof servers and verifiers.
+func NewMerkleTreeInMemory(maxLevels int) (*MerkleTree, error) {
+	return NewMerkleTree(newMemStorage(), maxLevels)
+}
+
+func (m *memStorage) key(k []byte) []byte {
+	if len(m.prefix) == 0 {
+		return k
+	}
+	return append(append([]byte{}, m.prefix...), k...)
+}
+
+func (m *memStorage) Info() string {
+	return "in-memory merkletree"
+}
+
+func (m *memStorage) WithPrefix(prefix []byte) db.Storage {
+	return &memStorage{prefix: append(append([]byte{}, m.prefix...), prefix...), a: m.a}
+}
+
+func (m *memStorage) NewTx() (db.Tx, error) {
+	return &memStorageTx{s: m, kv: make(map[string][]byte), del: make(map[string]struct{})}, nil
+}
+
+func (m *memStorage) Get(k []byte) ([]byte, error) {
+	m.a.rw.RLock()
+	defer m.a.rw.RUnlock()
+	if v, ok := m.a.get(m.key(k)); ok {
+		return v, nil
+	}
+	return nil, db.ErrNotFound
+}
+
+func (m *memStorage) Iterate(f func([]byte, []byte) (bool, error)) error {
+	kvs := []db.KV{}
+	m.a.rw.RLock()
+	for h, ref := range m.a.nodes {
+		if bytes.HasPrefix(h[:], m.prefix) {
+			k := h
+			kvs = append(kvs, db.KV{K: k[len(m.prefix):], V: m.a.value(ref)})
+		}
+	}
+	for k, v := range m.a.others {
+		if bytes.HasPrefix([]byte(k), m.prefix) {
+			kvs = append(kvs, db.KV{K: []byte(k)[len(m.prefix):], V: v})
+		}
+	}
+	m.a.rw.RUnlock()
+	sort.Slice(kvs, func(i, j int) bool { return bytes.Compare(kvs[i].K, kvs[j].K) < 0 })
+
+	for _, kv := range kvs {
+		if cont, err := f(kv.K, kv.V); err != nil {
+			return err
+		} else if !cont {
+			break
+		}
+	}
+	return nil
+}
+
+func (m *memStorage) List(limit int) ([]db.KV, error) {
+	ret := []db.KV{}
+	err := m.Iterate(func(k []byte, v []byte) (bool, error) {
+		ret = append(ret, db.KV{K: append([]byte{}, k...), V: append([]byte{}, v...)})
+		if len(ret) == limit {
+			return false, nil
+		}
+		return true, nil
+	})
+	return ret, err
+}
+
+func (m *memStorage) Close() {
+}
+
+func (tx *memStorageTx) Get(k []byte) ([]byte, error) {
+	key := string(tx.s.key(k))
+	if _, ok := tx.del[key]; ok {
+		return nil, db.ErrNotFound
+	}
+	if v, ok := tx.kv[key]; ok {
+		return v, nil
+	}
+	return tx.s.Get(k)
+}
+
+func (tx *memStorageTx) Put(k, v []byte) {
+	key := string(tx.s.key(k))
+	delete(tx.del, key)
+	tx.kv[key] = v
+}
+
+func (tx *memStorageTx) Delete(k []byte) {
+	key := string(tx.s.key(k))
+	delete(tx.kv, key)
+	tx.del[key] = struct{}{}
+}
+
+func (tx *memStorageTx) Add(atx db.Tx) {
+	mtx := atx.(*memStorageTx)
+	for k := range mtx.del {
+		delete(tx.kv, k)
+		tx.del[k] = struct{}{}
+	}
+	for k, v := range mtx.kv {
+		delete(tx.del, k)
+		tx.kv[k] = v
+	}
+}
+
+func (tx *memStorageTx) Commit() error {
+	tx.s.a.rw.Lock()
+	defer tx.s.a.rw.Unlock()
+	for k := range tx.del {
+		tx.s.a.delete([]byte(k))
+	}
+	for k, v := range tx.kv {
+		tx.s.a.put([]byte(k), v)
+	}
+	tx.kv = nil
+	tx.del = nil
+	return nil
+}
+
+func (tx *memStorageTx) Close() {
+	tx.kv = nil
+	tx.del = nil
+}
@@ -0,0 +1,98 @@
+package merkletree
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemStorage(t *testing.T) {
+	s := newMemStorage()
+	node := bytes.Repeat([]byte{1}, ElemBytesLen)
+	tx, err := s.NewTx()
+	require.Nil(t, err)
+	tx.Put(node, []byte("node"))
+	tx.Put([]byte("other"), []byte("value"))
+	_, err = s.Get(node)
+	assert.Equal(t, db.ErrNotFound, err)
+	v, err := tx.Get(node)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("node"), v)
+	require.Nil(t, tx.Commit())
+
+	v, err = s.Get(node)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("node"), v)
+	v, err = s.Get([]byte("other"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("value"), v)
+
+	sp := s.WithPrefix([]byte("p:"))
+	tx, err = sp.NewTx()
+	require.Nil(t, err)
+	tx.Put(node, []byte("prefixed"))
+	tx.Delete([]byte("other"))
+	require.Nil(t, tx.Commit())
+	v, err = sp.Get(node)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("prefixed"), v)
+	v, err = s.Get(node)
+	require.Nil(t, err)
+	assert.Equal(t, []byte("node"), v)
+
+	kvs, err := sp.List(10)
+	require.Nil(t, err)
+	assert.Equal(t, []db.KV{{K: node, V: []byte("prefixed")}}, kvs)
+
+	tx, err = s.NewTx()
+	require.Nil(t, err)
+	tx.Delete(node)
+	require.Nil(t, tx.Commit())
+	_, err = s.Get(node)
+	assert.Equal(t, db.ErrNotFound, err)
+	kvs, err = s.List(10)
+	require.Nil(t, err)
+	assert.Equal(t, 2, len(kvs))
+}
+
+func TestMerkleTreeInMemory(t *testing.T) {
+	mt := newTestingMerkle(t, 140)
+	defer mt.Storage().Close()
+	for i := 0; i < 64; i++ {
+		var indexSlot [800 / 8]byte
+		var dataSlot [960 / 8]byte
+		copy(indexSlot[:], strconv.Itoa(i))
+		e := newClaimBasicEntry(indexSlot, dataSlot)
+		require.Nil(t, mt.AddEntry(e))
+	}
+	w := bytes.NewBuffer(nil)
+	require.Nil(t, mt.DumpTree(w, nil))
+	dump := w.Bytes()
+
+	imt, err := NewMerkleTreeInMemory(140)
+	require.Nil(t, err)
+	require.Nil(t, imt.ImportTree(bytes.NewReader(dump)))
+	assert.Equal(t, mt.RootKey(), imt.RootKey())
+
+	w = bytes.NewBuffer(nil)
+	require.Nil(t, imt.DumpTree(w, nil))
+	assert.Equal(t, dump, w.Bytes())
+
+	var indexSlot [800 / 8]byte
+	var dataSlot [960 / 8]byte
+	copy(indexSlot[:], strconv.Itoa(7))
+	e := newClaimBasicEntry(indexSlot, dataSlot)
+	proof, err := imt.GenerateProof(e.HIndex(), nil)
+	require.Nil(t, err)
+	assert.True(t, proof.Existence)
+	assert.True(t, VerifyProof(imt.RootKey(), proof, e.HIndex(), e.HValue()))
+
+	// The in-memory tree is writable
+	copy(indexSlot[:], "new")
+	require.Nil(t, imt.AddEntry(newClaimBasicEntry(indexSlot, dataSlot)))
+	assert.NotEqual(t, mt.RootKey(), imt.RootKey())
+}
@@ -10,6 +10,7 @@ import (
 	"io"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	common3 "github.com/iden3/go-iden3-core/common"
 	"github.com/iden3/go-iden3-core/db"
@@ -111,6 +112,19 @@ var (
 	ErrEntryIndexAlreadyExists = errors.New("the entry index already exists in the tree")
 	// ErrNotWritable is used when the MerkleTree is not writable and a write function is called
 	ErrNotWritable = errors.New("Merkle Tree not writable")
+	// ErrRootMismatch is used when the root obtained after importing a
+	// dump doesn't match the expected root.
+	ErrRootMismatch = errors.New("the resulting root doesn't match the expected root")
+	// ErrInvalidTreeDump is used when a dump imported with ImportTree
+	// contains an invalid entry or lacks the root.
+	ErrInvalidTreeDump = errors.New("the tree dump is invalid")
+	// ErrNodeEncodingUnknown is used when a node or a tree is encoded
+	// with a NodeEncoding unsupported by this version.
+	ErrNodeEncodingUnknown = errors.New("unsupported node encoding")
+	// ErrEntryNotInField is used when an entry added to the tree has
+	// elements out of the Finite Field, which produce states that can't be
+	// proved in the circuits.
+	ErrEntryNotInField = errors.New("the entry elements are not inside the Finite Field")
 
 	// HashZero is a hash value of zeros, and is the key of an empty node.
 	HashZero = Hash{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}
@@ -118,23 +132,61 @@ var (
 	ElemBytesOne = ElemBytes{0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1}
 	// rootNodeVValue is the Key used to store the current Root in the database
 	rootNodeValue = []byte("currentroot")
+	// hasherNodeValue is the Key used to store the name of the Hasher in the database
+	hasherNodeValue = []byte("hasher")
+	// metadataNodeValue is the Key used to store the Metadata of the tree in the database
+	metadataNodeValue = []byte("metadata")
+	// nodeEncodingNodeValue is the Key used to store the NodeEncoding of the tree in the database
+	nodeEncodingNodeValue = []byte("nodeencoding")
 )
 
-// Entry is the generic type that is stored in the MT.  The entry should not be
-// modified after creating because the cached hIndex and hValue won't be
-// updated.
+// Entry is the generic type that is stored in the MT.  The hIndex and hValue
+// of the entry are cached, keyed by the elements they were computed from, so
+// that they are only recomputed when the Data is modified.  The cache is safe
+// for concurrent use.
 type Entry struct {
 	Data Data
-	// hIndex is a cache used to avoid recalculating hIndex
-	hIndex *Hash
-	// hValue is a cache used to avoid recalculating hValue
-	hValue *Hash
+	// hIndex is a cache of the hIndex, holding a *hashCache.
+	hIndex atomic.Value
+	// hValue is a cache of the hValue, holding a *hashCache.
+	hValue atomic.Value
 }
 
 type Entrier interface {
 	Entry() *Entry
 }
 
+// hashCache is the hash of the elements key.  The Index and the Value of an
+// Entry have the same number of elements.
+type hashCache struct {
+	key  [IndexLen]ElemBytes
+	hash *Hash
+}
+
+// cachedHashElems returns the hash of elems, which is computed and stored in
+// cache unless it holds the hash of the same elems.
+func cachedHashElems(cache *atomic.Value, elems []ElemBytes) *Hash {
+	if c, ok := cache.Load().(*hashCache); ok && elemsEqual(c.key[:], elems) {
+		return c.hash
+	}
+	c := &hashCache{hash: HashElems(elems...)}
+	copy(c.key[:], elems)
+	cache.Store(c)
+	return c.hash
+}
+
+func elemsEqual(a, b []ElemBytes) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
 func (e *Entry) Index() []ElemBytes {
 	return e.Data[:IndexLen]
 }
@@ -144,20 +196,16 @@ func (e *Entry) Value() []ElemBytes {
 }
 
 // HIndex calculates the hash of the Index of the entry, used to find the path
-// from the root to the leaf in the MT.
+// from the root to the leaf in the MT.  The returned Hash must not be
+// modified.
 func (e *Entry) HIndex() *Hash {
-	if e.hIndex == nil { // Cache the hIndex.
-		//e.hIndex = HashElems(e.Index()[:]...)
-		e.hIndex = HashElems(e.Index()...)
-	}
-	return e.hIndex
+	return cachedHashElems(&e.hIndex, e.Index())
 }
 
+// HValue calculates the hash of the Value of the entry.  The returned Hash
+// must not be modified.
 func (e *Entry) HValue() *Hash {
-	if e.hValue == nil { // Cache the hValue.
-		e.hValue = HashElems(e.Value()...)
-	}
-	return e.hValue
+	return cachedHashElems(&e.hValue, e.Value())
 }
 
 func (e *Entry) Bytes() []byte {
@@ -182,7 +230,7 @@ func (e *Entry) Clone() *Entry {
 	return &Entry{Data: *data}
 }
 
-//MerkleTree is the struct with the main elements of the Merkle Tree
+// MerkleTree is the struct with the main elements of the Merkle Tree
 type MerkleTree struct {
 	sync.RWMutex
 	// storage is the backend database.
@@ -193,32 +241,143 @@ type MerkleTree struct {
 	maxLevels int
 	// writable indicates if the Merkle Tree allows to write or only to read
 	writable bool
+	// hasher is the hash function of the Merkle Tree
+	hasher Hasher
+	// nodeEncoding is the encoding of the nodes written to the storage
+	nodeEncoding NodeEncoding
 }
 
-// NewMerkleTree generates a new Merkle Tree
+// NewMerkleTree generates a new Merkle Tree.  A new tree uses the
+// HasherDefault, and an existing tree the Hasher it was created with.  The
+// maxLevels of an existing tree is loaded from its Metadata if maxLevels is
+// 0.
 func NewMerkleTree(storage db.Storage, maxLevels int) (*MerkleTree, error) {
-	mt := MerkleTree{storage: storage, maxLevels: maxLevels, writable: true}
+	return NewMerkleTreeWithHasher(storage, maxLevels, nil)
+}
+
+// NewMerkleTreeWithHasher generates a new Merkle Tree that uses hasher.  The
+// parameters of a new tree are persisted in the storage as its Metadata, and
+// the ones of an existing tree are validated against it: if hasher is nil an
+// existing tree uses the registered Hasher it was created with, and if
+// maxLevels is 0 the maxLevels it was created with.  Opening an existing
+// tree with a different hasher fails with ErrHasherMismatch, and with a
+// different maxLevels with ErrMaxLevelsMismatch.  The trees created before
+// the Metadata was persisted get it stored on open, which requires a
+// maxLevels.
+func NewMerkleTreeWithHasher(storage db.Storage, maxLevels int, hasher Hasher) (*MerkleTree, error) {
+	mt := MerkleTree{storage: storage, writable: true}
+	md, err := ReadMetadata(storage)
+	if err != nil && err != ErrMetadataNotFound {
+		return nil, err
+	}
+	storeMetadata := err == ErrMetadataNotFound
+	if !storeMetadata {
+		if maxLevels == 0 {
+			maxLevels = md.MaxLevels
+		} else if maxLevels != md.MaxLevels {
+			return nil, ErrMaxLevelsMismatch
+		}
+		if hasher == nil {
+			if hasher, err = HasherByName(md.Hasher); err != nil {
+				return nil, err
+			}
+		} else if hasher.Name() != md.Hasher {
+			return nil, ErrHasherMismatch
+		}
+	} else if hasher, err = mt.legacyHasher(hasher); err != nil {
+		return nil, err
+	}
+	if maxLevels <= 0 {
+		return nil, ErrMaxLevelsUnknown
+	}
+	mt.maxLevels = maxLevels
+	mt.hasher = hasher
 	_, gettedRoot, err := mt.dbGet(rootNodeValue)
-	if err != nil {
+	if err != nil && err != db.ErrNotFound {
+		return nil, err
+	}
+	if gettedRoot == nil {
+		mt.nodeEncoding = NodeEncodingLast
+	} else if mt.nodeEncoding, err = mt.loadNodeEncoding(); err != nil {
+		return nil, err
+	}
+	if gettedRoot == nil || storeMetadata {
 		tx, err := mt.storage.NewTx()
 		if err != nil {
 			return nil, err
 		}
-		nodeRoot := NewNodeEmpty()
-		k, _ := nodeRoot.Key(), nodeRoot.Value()
-		mt.rootKey = k
-		mt.dbInsert(tx, rootNodeValue, DBEntryTypeRoot, mt.rootKey[:])
+		if storeMetadata {
+			md := Metadata{Version: MetadataVersion, MaxLevels: maxLevels, Hasher: hasher.Name()}
+			mt.dbInsert(tx, metadataNodeValue, DBEntryTypeMetadata, md.Bytes())
+			// The hasher is also stored on its own for the
+			// readers that predate the Metadata.
+			mt.dbInsert(tx, hasherNodeValue, DBEntryTypeHasher, []byte(hasher.Name()))
+		}
+		if gettedRoot == nil {
+			nodeRoot := NewNodeEmpty()
+			gettedRoot = nodeKey(mt.hasher, nodeRoot)[:]
+			mt.dbInsert(tx, rootNodeValue, DBEntryTypeRoot, gettedRoot)
+			mt.dbInsert(tx, nodeEncodingNodeValue, DBEntryTypeNodeEncoding, []byte{byte(mt.nodeEncoding)})
+		}
 		if err = tx.Commit(); err != nil {
 			tx.Close()
 			return nil, err
 		}
-		return &mt, nil
 	}
 	mt.rootKey = &Hash{}
 	copy(mt.rootKey[:], gettedRoot)
 	return &mt, nil
 }
 
+// loadNodeEncoding returns the NodeEncoding of an existing tree, which is the
+// NodeEncodingV0 for the trees created before it was persisted.
+func (mt *MerkleTree) loadNodeEncoding() (NodeEncoding, error) {
+	_, b, err := mt.dbGet(nodeEncodingNodeValue)
+	if err == db.ErrNotFound {
+		return NodeEncodingV0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	if len(b) != 1 || NodeEncoding(b[0]) > NodeEncodingLast {
+		return 0, ErrNodeEncodingUnknown
+	}
+	return NodeEncoding(b[0]), nil
+}
+
+// legacyHasher returns the Hasher of a tree without Metadata: the one stored
+// on its own, or the HasherDefault for the trees created before the hasher
+// was persisted.  A new tree uses hasher, or the HasherDefault if it's nil.
+func (mt *MerkleTree) legacyHasher(hasher Hasher) (Hasher, error) {
+	_, hasherName, err := mt.dbGet(hasherNodeValue)
+	if err == nil {
+		if hasher == nil {
+			return HasherByName(string(hasherName))
+		} else if hasher.Name() != string(hasherName) {
+			return nil, ErrHasherMismatch
+		}
+		return hasher, nil
+	} else if err != db.ErrNotFound {
+		return nil, err
+	}
+	if _, _, err := mt.dbGet(rootNodeValue); err == nil {
+		if hasher != nil && hasher.Name() != HasherDefault.Name() {
+			return nil, ErrHasherMismatch
+		}
+		return HasherDefault, nil
+	} else if err != db.ErrNotFound {
+		return nil, err
+	}
+	if hasher == nil {
+		return HasherDefault, nil
+	}
+	return hasher, nil
+}
+
+// Hasher returns the MT hash function
+func (mt *MerkleTree) Hasher() Hasher {
+	return mt.hasher
+}
+
 func (mt *MerkleTree) Snapshot(rootKey *Hash) (*MerkleTree, error) {
 	mt.RLock()
 	defer mt.RUnlock()
@@ -226,7 +385,8 @@ func (mt *MerkleTree) Snapshot(rootKey *Hash) (*MerkleTree, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &MerkleTree{storage: mt.storage, maxLevels: mt.maxLevels, rootKey: rootKey, writable: false}, nil
+	return &MerkleTree{storage: mt.storage, maxLevels: mt.maxLevels, rootKey: rootKey, writable: false, hasher: mt.hasher,
+		nodeEncoding: mt.nodeEncoding}, nil
 }
 
 // Storage returns the MT storage
@@ -260,7 +420,7 @@ func (mt *MerkleTree) GetDataByIndex(hIndex *Hash) (*Data, error) {
 		case NodeTypeEmpty:
 			return nil, ErrEntryIndexNotFound
 		case NodeTypeLeaf:
-			if bytes.Equal(hIndex[:], n.Entry.HIndex()[:]) {
+			if bytes.Equal(hIndex[:], entryHIndex(mt.hasher, n.Entry)[:]) {
 				return &n.Entry.Data, nil
 			} else {
 				return nil, ErrEntryIndexNotFound
@@ -300,9 +460,9 @@ func (mt *MerkleTree) pushLeaf(tx db.Tx, newLeaf *Node, oldLeaf *Node,
 		return mt.addNode(tx, newNodeMiddle)
 	} else {
 		if pathNewLeaf[lvl] {
-			newNodeMiddle = NewNodeMiddle(oldLeaf.Key(), newLeaf.Key())
+			newNodeMiddle = NewNodeMiddle(nodeKey(mt.hasher, oldLeaf), nodeKey(mt.hasher, newLeaf))
 		} else {
-			newNodeMiddle = NewNodeMiddle(newLeaf.Key(), oldLeaf.Key())
+			newNodeMiddle = NewNodeMiddle(nodeKey(mt.hasher, newLeaf), nodeKey(mt.hasher, oldLeaf))
 		}
 		// We can add newLeaf now.  We don't need to add oldLeaf because it's already in the tree.
 		_, err := mt.addNode(tx, newLeaf)
@@ -321,7 +481,7 @@ func (mt *MerkleTree) addLeaf(tx db.Tx, newLeaf *Node, key *Hash,
 	if lvl > mt.maxLevels-1 {
 		return nil, ErrReachedMaxLevel
 	}
-	n, err := mt.GetNode(key)
+	n, err := mt.getNodeTx(tx, key)
 	if err != nil {
 		return nil, err
 	}
@@ -331,9 +491,9 @@ func (mt *MerkleTree) addLeaf(tx db.Tx, newLeaf *Node, key *Hash,
 		return mt.addNode(tx, newLeaf)
 	case NodeTypeLeaf:
 		// TODO: delete old node n???  Make this optional???
-		hIndex := n.Entry.HIndex()
+		hIndex := entryHIndex(mt.hasher, n.Entry)
 		// Check if leaf node found contains the leaf node we are trying to add
-		if bytes.Equal(hIndex[:], newLeaf.Entry.HIndex()[:]) {
+		if bytes.Equal(hIndex[:], entryHIndex(mt.hasher, newLeaf.Entry)[:]) {
 			return nil, ErrEntryIndexAlreadyExists
 		}
 		pathOldLeaf := getPath(mt.maxLevels, hIndex)
@@ -371,15 +531,28 @@ func (mt *MerkleTree) AddClaim(e Entrier) error {
 	return mt.AddEntry(e.Entry())
 }
 
-// AddEntry adds the Entry to the MerkleTree
+// AddEntry adds the Entry to the MerkleTree.  The entries with elements out
+// of the Finite Field are rejected with ErrEntryNotInField.
 func (mt *MerkleTree) AddEntry(e *Entry) error {
+	// verfy that the ElemBytes are valid and fit inside the field.
+	if !CheckEntryInField(*e) {
+		return ErrEntryNotInField
+	}
+	return mt.AddEntryUnsafe(e)
+}
+
+// AddEntryUnsafe adds the Entry to the MerkleTree without checking that its
+// elements are inside the Finite Field.  It's an escape hatch for the trees
+// whose proofs are never verified in circuits, and whose Hasher accepts such
+// elements, like Sha256Hasher.  The Poseidon hasher doesn't, so the trees
+// with the default Hasher still reject them with ErrEntryNotInField.
+func (mt *MerkleTree) AddEntryUnsafe(e *Entry) error {
 	// verify that the MerkleTree is writable
 	if !mt.writable {
 		return ErrNotWritable
 	}
-	// verfy that the ElemBytes are valid and fit inside the mimc7 field.
-	if !CheckEntryInField(*e) {
-		return errors.New("Elements not inside the Finite Field over R")
+	if isHasherDefault(mt.hasher) && !CheckEntryInField(*e) {
+		return ErrEntryNotInField
 	}
 	tx, err := mt.storage.NewTx()
 	if err != nil {
@@ -397,8 +570,15 @@ func (mt *MerkleTree) AddEntry(e *Entry) error {
 		mt.Unlock()
 	}()
 
+	err = mt.addEntry(tx, e)
+	return err
+}
+
+// addEntry adds the Entry to the MerkleTree in the open transaction tx and
+// updates the root.  The caller must hold the write lock.
+func (mt *MerkleTree) addEntry(tx db.Tx, e *Entry) error {
 	newNodeLeaf := NewNodeLeaf(e)
-	hIndex := e.HIndex()
+	hIndex := entryHIndex(mt.hasher, e)
 	path := getPath(mt.maxLevels, hIndex)
 
 	newRootKey, err := mt.addLeaf(tx, newNodeLeaf, mt.rootKey, 0, path)
@@ -448,6 +628,28 @@ func (mt *MerkleTree) Walk(rootKey *Hash, f func(*Node)) error {
 	return err
 }
 
+// CountLeafs returns the number of leaves in the storage of the MerkleTree,
+// including the ones only reachable from previous roots, decoding the nodes of
+// any NodeEncoding.
+func (mt *MerkleTree) CountLeafs() (int, error) {
+	count := 0
+	err := mt.storage.Iterate(func(k, v []byte) (bool, error) {
+		// The entries that are not nodes have shorter keys.
+		if len(k) != ElemBytesLen {
+			return true, nil
+		}
+		n, err := NewNodeFromBytes(v)
+		if err != nil {
+			return false, fmt.Errorf("node %x: %w", k, err)
+		}
+		if n.Type == NodeTypeLeaf {
+			count++
+		}
+		return true, nil
+	})
+	return count, err
+}
+
 // GraphViz uses Walk function to generate a string GraphViz representation of the
 // tree and writes it to w
 func (mt *MerkleTree) GraphViz(w io.Writer, rootKey *Hash) error {
@@ -459,7 +661,7 @@ node [fontname=Monospace,fontsize=10,shape=box]
 		switch n.Type {
 		case NodeTypeEmpty:
 		case NodeTypeLeaf:
-			fmt.Fprintf(w, "\"%v\" [style=filled];\n", n.Key())
+			fmt.Fprintf(w, "\"%v\" [style=filled];\n", nodeKey(mt.hasher, n))
 		case NodeTypeMiddle:
 			lr := [2]string{n.ChildL.String(), n.ChildR.String()}
 			for i := range lr {
@@ -469,7 +671,7 @@ node [fontname=Monospace,fontsize=10,shape=box]
 					cnt++
 				}
 			}
-			fmt.Fprintf(w, "\"%v\" -> {\"%v\" \"%v\"}\n", n.Key(), lr[0], lr[1])
+			fmt.Fprintf(w, "\"%v\" -> {\"%v\" \"%v\"}\n", nodeKey(mt.hasher, n), lr[0], lr[1])
 		default:
 		}
 	})
@@ -490,7 +692,7 @@ func (mt *MerkleTree) DumpTree(w io.Writer, rootKey *Hash) error {
 	var errS error
 	err := mt.Walk(rootKey, func(n *Node) {
 		if n.Type != NodeTypeEmpty {
-			err := serializeKV(w, n.Key().Bytes(), n.Value())
+			err := serializeKV(w, nodeKey(mt.hasher, n).Bytes(), n.Value())
 			if err != nil {
 				errS = err
 			}
@@ -511,6 +713,44 @@ func (mt *MerkleTree) DumpTree(w io.Writer, rootKey *Hash) error {
 	return err
 }
 
+// DumpTreeDelta outputs, in the DumpTree format, the nodes of the tree at
+// toRootKey that are not in the tree at fromRootKey, followed by toRootKey.
+// Importing the output with ImportTree into a tree that contains the tree at
+// fromRootKey results in the tree at toRootKey.  If a root key is nil, the
+// current root is used.
+func (mt *MerkleTree) DumpTreeDelta(w io.Writer, fromRootKey, toRootKey *Hash) error {
+	from := make(map[Hash]struct{})
+	if err := mt.Walk(fromRootKey, func(n *Node) {
+		if n.Type != NodeTypeEmpty {
+			from[*nodeKey(mt.hasher, n)] = struct{}{}
+		}
+	}); err != nil {
+		return err
+	}
+	var errS error
+	err := mt.Walk(toRootKey, func(n *Node) {
+		if n.Type == NodeTypeEmpty || errS != nil {
+			return
+		}
+		k := nodeKey(mt.hasher, n)
+		if _, ok := from[*k]; ok {
+			return
+		}
+		errS = serializeKV(w, k.Bytes(), n.Value())
+	})
+	if err != nil {
+		return err
+	}
+	if errS != nil {
+		return errS
+	}
+
+	if toRootKey == nil {
+		toRootKey = mt.RootKey()
+	}
+	return serializeKV(w, rootNodeValue, toRootKey.Bytes())
+}
+
 func checkKVLen(kLen, vLen int) error {
 	if kLen > 0xff {
 		return fmt.Errorf("len(k) %d > 0xff", kLen)
@@ -564,43 +804,68 @@ func deserializeKV(r io.Reader) ([]byte, []byte, error) {
 	return kv[:kLen], kv[kLen:], nil
 }
 
-// ImportTree imports the tree from the output from the DumpTree function
+// ImportTree imports the tree from the output from the DumpTree function.
+// Every entry is checked before it's stored: it must be the root or a valid
+// middle or leaf node with elements inside the Finite Field, so that a dump
+// from an untrusted source can't place arbitrary values in the storage.  The
+// node keys are not checked against the hashes of the nodes, which would be
+// as costly as rebuilding the tree.  The nodes are stored in a single
+// transaction, so on any error the MerkleTree is left unmodified.
 func (mt *MerkleTree) ImportTree(i io.Reader) error {
+	if !mt.writable {
+		return ErrNotWritable
+	}
 	tx, err := mt.storage.NewTx()
 	if err != nil {
 		return err
 	}
 	mt.Lock()
-	defer func() {
-		if err == nil {
-			if err := tx.Commit(); err != nil {
-				tx.Close()
-			}
-		} else {
-			tx.Close()
-		}
-		mt.Unlock()
-	}()
+	defer mt.Unlock()
+	rootKey, err := mt.importTree(tx, i)
+	if err != nil {
+		tx.Close()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		tx.Close()
+		return err
+	}
+	mt.rootKey = rootKey
+	return nil
+}
 
+func (mt *MerkleTree) importTree(tx db.Tx, i io.Reader) (*Hash, error) {
+	var rootKey *Hash
 	r := bufio.NewReader(i)
-	for {
+	for n := 0; ; n++ {
 		k, v, err := deserializeKV(r)
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			return err
+			return nil, err
+		}
+		if bytes.Equal(k, rootNodeValue) {
+			if len(v) != ElemBytesLen {
+				return nil, fmt.Errorf("entry %d: %w", n, ErrInvalidTreeDump)
+			}
+			rootKey = &Hash{}
+			copy(rootKey[:], v)
+			continue
+		}
+		node, err := NewNodeFromBytes(v)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d: %w", n, err)
 		}
-		tx.Put(k, v)
+		if len(k) != ElemBytesLen || node.Type == NodeTypeEmpty || !node.inField() {
+			return nil, fmt.Errorf("entry %d: %w", n, ErrInvalidTreeDump)
+		}
+		tx.Put(k, node.Encode(mt.nodeEncoding))
 	}
-
-	v, err := tx.Get(rootNodeValue)
-	if err != nil {
-		return err
+	if rootKey == nil {
+		return nil, fmt.Errorf("missing root: %w", ErrInvalidTreeDump)
 	}
-	mt.rootKey = &Hash{}
-	copy(mt.rootKey[:], v)
-
-	return nil
+	mt.dbInsert(tx, rootNodeValue, DBEntryTypeRoot, rootKey[:])
+	return rootKey, nil
 }
 
 // DumpClaimsIoWriter uses Walk function to get all the Claims of the tree and write
@@ -627,26 +892,28 @@ func (mt *MerkleTree) DumpClaims(rootKey *Hash) ([]string, error) {
 	return dumpedClaims, err
 }
 
+// parseDumpedClaim parses a claim in hex from the DumpClaims function.
+func parseDumpedClaim(c string) (*Entry, error) {
+	c = strings.TrimPrefix(c, "0x")
+	if len(c) != 2*ElemBytesLen*DataLen { // 2*ElemBytesLen because is in Hexadecimal string, so each byte is represented by 2 char
+		return nil, fmt.Errorf("hex length different than %d", 2*ElemBytesLen*DataLen)
+	}
+	var dataBytes [ElemBytesLen * DataLen]byte
+	if err := common3.HexDecodeInto(dataBytes[:], []byte(c)); err != nil {
+		return nil, err
+	}
+	return &Entry{Data: *NewDataFromBytes(dataBytes)}, nil
+}
+
 // ImportClaims parses and adds the dumped list of claims in hex from the
 // DumpClaims function.
 func (mt *MerkleTree) ImportDumpedClaims(dumpedClaims []string) error {
 	for _, c := range dumpedClaims {
-		c = strings.TrimPrefix(c, "0x")
-		if len(c) != 2*ElemBytesLen*DataLen { // 2*ElemBytesLen because is in Hexadecimal string, so each byte is represented by 2 char
-			return fmt.Errorf("hex length different than %d", 2*ElemBytesLen*DataLen)
-		}
-		var err error
-		var e Entry
-		var d Data
-		var dataBytes [ElemBytesLen * DataLen]byte
-		err = common3.HexDecodeInto(dataBytes[:], []byte(c))
+		e, err := parseDumpedClaim(c)
 		if err != nil {
 			return err
 		}
-		d = *NewDataFromBytes(dataBytes)
-		e.Data = d
-
-		err = mt.AddEntry(&e)
+		err = mt.AddEntry(e)
 		if err != nil {
 			return err
 		}
@@ -654,6 +921,67 @@ func (mt *MerkleTree) ImportDumpedClaims(dumpedClaims []string) error {
 	return nil
 }
 
+// ImportDumpedLeafs parses and adds the dumped list of leaf entries in hex
+// from the DumpClaims function, validating every entry before adding it.
+// progress, if not nil, is called after each leaf is added with the number of
+// leafs added so far and the total.  If expectedRoot is not nil, the root
+// obtained after adding all the leafs must match it.  The leafs are added in
+// a single transaction, so on any error (including ErrRootMismatch) the
+// MerkleTree is left unmodified.  This allows safely importing dumps from
+// untrusted sources.
+func (mt *MerkleTree) ImportDumpedLeafs(dumpedLeafs []string, expectedRoot *Hash,
+	progress func(n, total int)) error {
+	if !mt.writable {
+		return ErrNotWritable
+	}
+	entries := make([]*Entry, len(dumpedLeafs))
+	for i, c := range dumpedLeafs {
+		e, err := parseDumpedClaim(c)
+		if err != nil {
+			return fmt.Errorf("leaf %d: %w", i, err)
+		}
+		if !CheckEntryInField(*e) {
+			return fmt.Errorf("leaf %d: %w", i, ErrEntryNotInField)
+		}
+		entries[i] = e
+	}
+
+	tx, err := mt.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	mt.Lock()
+	defer mt.Unlock()
+	oldRootKey := mt.rootKey
+	if err := mt.importEntries(tx, entries, expectedRoot, progress); err != nil {
+		mt.rootKey = oldRootKey
+		tx.Close()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		mt.rootKey = oldRootKey
+		tx.Close()
+		return err
+	}
+	return nil
+}
+
+func (mt *MerkleTree) importEntries(tx db.Tx, entries []*Entry, expectedRoot *Hash,
+	progress func(n, total int)) error {
+	for i, e := range entries {
+		if err := mt.addEntry(tx, e); err != nil {
+			return fmt.Errorf("leaf %d: %w", i, err)
+		}
+		if progress != nil {
+			progress(i+1, len(entries))
+		}
+	}
+	if expectedRoot != nil && !mt.rootKey.Equals(expectedRoot) {
+		return ErrRootMismatch
+	}
+	return nil
+}
+
 // nodeAux contains the auxiliary node used in a non-existence proof.
 type nodeAux struct {
 	//key    *Hash
@@ -688,6 +1016,9 @@ func NewProofFromBytes(bs []byte) (*Proof, error) {
 		p.Existence = true
 	}
 	p.depth = uint(bs[1])
+	if p.depth > uint(len(p.notempties))*8 {
+		return nil, ErrInvalidProofBytes
+	}
 	copy(p.notempties[:], bs[proofFlagsLen:ElemBytesLen])
 	siblingBytes := bs[ElemBytesLen:]
 	sibIdx := 0
@@ -698,19 +1029,27 @@ func NewProofFromBytes(bs []byte) (*Proof, error) {
 			}
 			var sib Hash
 			copy(sib[:], siblingBytes[sibIdx*ElemBytesLen:(sibIdx+1)*ElemBytesLen])
+			if !sib.InField() {
+				return nil, ErrInvalidProofBytes
+			}
 			p.Siblings = append(p.Siblings, &sib)
 			sibIdx++
 		}
 	}
 
+	nodeAuxBytes := siblingBytes[len(p.Siblings)*ElemBytesLen:]
 	if !p.Existence && ((bs[0] & 0x02) != 0) {
 		p.nodeAux = &nodeAux{hIndex: &Hash{}, hValue: &Hash{}}
-		nodeAuxBytes := siblingBytes[len(p.Siblings)*ElemBytesLen:]
 		if len(nodeAuxBytes) != 2*ElemBytesLen {
 			return nil, ErrInvalidProofBytes
 		}
 		copy(p.nodeAux.hIndex[:], nodeAuxBytes[:ElemBytesLen])
 		copy(p.nodeAux.hValue[:], nodeAuxBytes[ElemBytesLen:2*ElemBytesLen])
+		if !p.nodeAux.hIndex.InField() || !p.nodeAux.hValue.InField() {
+			return nil, ErrInvalidProofBytes
+		}
+	} else if len(nodeAuxBytes) != 0 {
+		return nil, ErrInvalidProofBytes
 	}
 	return p, nil
 }
@@ -740,6 +1079,32 @@ func (p *Proof) Bytes() []byte {
 	return bs
 }
 
+// AllSiblings returns the depth siblings of the proof, from the root to the
+// leaf, with HashZero in place of the empty ones, as used by the verifiers
+// that don't decode the notempties bitmap (like the smart contracts).
+func (p *Proof) AllSiblings() []*Hash {
+	siblings := make([]*Hash, p.depth)
+	sibIdx := 0
+	for lvl := uint(0); lvl < p.depth; lvl++ {
+		if testBitBigEndian(p.notempties[:], lvl) {
+			siblings[lvl] = p.Siblings[sibIdx]
+			sibIdx++
+		} else {
+			siblings[lvl] = &HashZero
+		}
+	}
+	return siblings
+}
+
+// NodeAux returns the hIndex and hValue of the leaf found in the path of a
+// proof of non-existence, or false if the path ends in an empty node.
+func (p *Proof) NodeAux() (hIndex, hValue *Hash, ok bool) {
+	if p.nodeAux == nil {
+		return nil, nil, false
+	}
+	return p.nodeAux.hIndex, p.nodeAux.hValue, true
+}
+
 func (p *Proof) MarshalJSON() ([]byte, error) {
 	return json.Marshal(common3.HexEncode(p.Bytes()))
 }
@@ -809,12 +1174,12 @@ func (mt *MerkleTree) GenerateProof(hIndex *Hash, rootKey *Hash) (*Proof, error)
 		case NodeTypeEmpty:
 			return p, nil
 		case NodeTypeLeaf:
-			if bytes.Equal(hIndex[:], n.Entry.HIndex()[:]) {
+			if bytes.Equal(hIndex[:], entryHIndex(mt.hasher, n.Entry)[:]) {
 				p.Existence = true
 				return p, nil
 			} else {
 				// We found a leaf whose entry didn't match hIndex
-				p.nodeAux = &nodeAux{hIndex: n.Entry.HIndex(), hValue: n.Entry.HValue()}
+				p.nodeAux = &nodeAux{hIndex: entryHIndex(mt.hasher, n.Entry), hValue: entryHValue(mt.hasher, n.Entry)}
 				return p, nil
 			}
 		case NodeTypeMiddle:
@@ -838,7 +1203,13 @@ func (mt *MerkleTree) GenerateProof(hIndex *Hash, rootKey *Hash) (*Proof, error)
 
 // VerifyProof verifies the Merkle Proof for the entry and root.
 func VerifyProof(rootKey *Hash, proof *Proof, hIndex, hValue *Hash) bool {
-	rootFromProof, err := RootFromProof(proof, hIndex, hValue)
+	return VerifyProofWithHasher(HasherDefault, rootKey, proof, hIndex, hValue)
+}
+
+// VerifyProofWithHasher verifies the Merkle Proof for the entry and root of
+// a tree that uses hasher.
+func VerifyProofWithHasher(hasher Hasher, rootKey *Hash, proof *Proof, hIndex, hValue *Hash) bool {
+	rootFromProof, err := RootFromProofWithHasher(hasher, proof, hIndex, hValue)
 	if err != nil {
 		return false
 	}
@@ -849,10 +1220,16 @@ func VerifyProof(rootKey *Hash, proof *Proof, hIndex, hValue *Hash) bool {
 // siblings are the ones in the proof with the claim hashing to hIndex and
 // hValue.
 func RootFromProof(proof *Proof, hIndex, hValue *Hash) (*Hash, error) {
+	return RootFromProofWithHasher(HasherDefault, proof, hIndex, hValue)
+}
+
+// RootFromProofWithHasher calculates the root like RootFromProof for a tree
+// that uses hasher.
+func RootFromProofWithHasher(hasher Hasher, proof *Proof, hIndex, hValue *Hash) (*Hash, error) {
 	sibIdx := len(proof.Siblings) - 1
 	var midKey *Hash
 	if proof.Existence {
-		midKey = LeafKey(hIndex, hValue)
+		midKey = leafKey(hasher, hIndex, hValue)
 	} else {
 		if proof.nodeAux == nil {
 			midKey = &HashZero
@@ -860,7 +1237,7 @@ func RootFromProof(proof *Proof, hIndex, hValue *Hash) (*Hash, error) {
 			if bytes.Equal(hIndex[:], proof.nodeAux.hIndex[:]) {
 				return nil, fmt.Errorf("Non-existence proof being checked against hIndex equal to nodeAux")
 			}
-			midKey = LeafKey(proof.nodeAux.hIndex, proof.nodeAux.hValue)
+			midKey = leafKey(hasher, proof.nodeAux.hIndex, proof.nodeAux.hValue)
 		}
 	}
 	path := getPath(int(proof.depth), hIndex)
@@ -873,9 +1250,9 @@ func RootFromProof(proof *Proof, hIndex, hValue *Hash) (*Hash, error) {
 			siblingKey = &HashZero
 		}
 		if path[lvl] {
-			midKey = NewNodeMiddle(siblingKey, midKey).Key()
+			midKey = nodeKey(hasher, NewNodeMiddle(siblingKey, midKey))
 		} else {
-			midKey = NewNodeMiddle(midKey, siblingKey).Key()
+			midKey = nodeKey(hasher, NewNodeMiddle(midKey, siblingKey))
 		}
 	}
 	return midKey, nil
@@ -894,6 +1271,19 @@ func (mt *MerkleTree) GetNode(key *Hash) (*Node, error) {
 	return NewNodeFromBytes(nBytes)
 }
 
+// getNodeTx gets a node by key from the MT, including the nodes added in the
+// open transaction tx.
+func (mt *MerkleTree) getNodeTx(tx db.Tx, key *Hash) (*Node, error) {
+	if bytes.Equal(key[:], HashZero[:]) {
+		return NewNodeEmpty(), nil
+	}
+	nBytes, err := tx.Get(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return NewNodeFromBytes(nBytes)
+}
+
 // addNode adds a node into the MT.  Empty nodes are not stored in the tree;
 // they are all the same and assumed to always exist.
 func (mt *MerkleTree) addNode(tx db.Tx, n *Node) (*Hash, error) {
@@ -902,9 +1292,9 @@ func (mt *MerkleTree) addNode(tx db.Tx, n *Node) (*Hash, error) {
 		return nil, ErrNotWritable
 	}
 	if n.Type == NodeTypeEmpty {
-		return n.Key(), nil
+		return nodeKey(mt.hasher, n), nil
 	}
-	k, v := n.Key(), n.Value()
+	k, v := nodeKey(mt.hasher, n), n.Encode(mt.nodeEncoding)
 	// Check that the node key doesn't already exist
 	if _, err := tx.Get(k[:]); err == nil {
 		return nil, ErrNodeKeyAlreadyExists
@@ -941,13 +1331,12 @@ func (mt *MerkleTree) dbInsert(tx db.Tx, k []byte, t NodeType, data []byte) {
 	tx.Put(k, v)
 }
 
-// HexStringToHash decodes a hex string into a Hash.
+// HexStringToHash decodes a hex string into a Hash, and panics if it's not a
+// valid Hash.  See NewHashFromHex.
 func HexStringToHash(s string) Hash {
-	b, err := common3.HexDecode(s)
+	h, err := NewHashFromHex(s)
 	if err != nil {
 		panic(err)
 	}
-	var b32 [ElemBytesLen]byte
-	copy(b32[:], b[:32])
-	return Hash(ElemBytes(b32))
+	return *h
 }
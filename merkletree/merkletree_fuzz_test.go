@@ -0,0 +1,71 @@
+//go:build go1.18
+// +build go1.18
+
+package merkletree
+
+import (
+	"bytes"
+	"testing"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzNewProofFromBytes(f *testing.F) {
+	mt := newTestingMerkle(f, 140)
+	defer mt.Storage().Close()
+	for i := 0; i < 8; i++ {
+		e := NewEntryFromInts(int64(i), 0, 0, 0, int64(i), 0, 0, 0)
+		require.Nil(f, mt.AddEntry(&e))
+	}
+	for _, i := range []int64{0, 3, 42} {
+		e := NewEntryFromInts(i, 0, 0, 0, 0, 0, 0, 0)
+		proof, err := mt.GenerateProof(e.HIndex(), nil)
+		require.Nil(f, err)
+		f.Add(proof.Bytes())
+	}
+	// depth beyond the notempties bitmap
+	f.Add(append([]byte{0x00, 0xff}, make([]byte, ElemBytesLen-proofFlagsLen)...))
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		proof, err := NewProofFromBytes(bs)
+		if err != nil {
+			return
+		}
+		proof2, err := NewProofFromBytes(proof.Bytes())
+		require.Nil(t, err)
+		assert.Equal(t, proof, proof2)
+		var proof3 Proof
+		require.Nil(t, proof3.UnmarshalJSON([]byte(`"`+common3.HexEncode(bs)+`"`)))
+		assert.Equal(t, proof, &proof3)
+		// The siblings are inside the Finite Field, so the root can be
+		// computed.  Deep proofs are skipped to keep the fuzzing fast.
+		if proof.depth <= 8 {
+			_, _ = RootFromProof(proof, &HashZero, &HashZero)
+		}
+	})
+}
+
+func FuzzImportTree(f *testing.F) {
+	mt := newTestingMerkle(f, 140)
+	defer mt.Storage().Close()
+	for i := 0; i < 4; i++ {
+		e := NewEntryFromInts(int64(i), 0, 0, 0, int64(i), 0, 0, 0)
+		require.Nil(f, mt.AddEntry(&e))
+	}
+	dump := bytes.NewBufferString("")
+	require.Nil(f, mt.DumpTree(dump, nil))
+	f.Add(dump.Bytes())
+	f.Add([]byte{})
+	f.Fuzz(func(t *testing.T, bs []byte) {
+		imt := newTestingMerkle(t, 140)
+		defer imt.Storage().Close()
+		if err := imt.ImportTree(bytes.NewReader(bs)); err != nil {
+			return
+		}
+		// The imported nodes are consistent with their keys, so walking
+		// the tree either succeeds or fails with an error.
+		_ = imt.Walk(nil, func(*Node) {})
+		_, _ = imt.GenerateProof(&HashZero, nil)
+	})
+}
@@ -3,9 +3,11 @@ package merkletree
 import (
 	"bytes"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
+	"sync"
 
 	//"strconv"
 	"testing"
@@ -20,6 +22,7 @@ import (
 	cryptoConstants "github.com/iden3/go-iden3-crypto/constants"
 	cryptoUtils "github.com/iden3/go-iden3-crypto/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 var debug = false
@@ -85,6 +88,35 @@ func TestEntry(t *testing.T) {
 	testgen.CheckTestValue(t, "TestEntry0", hex.EncodeToString(e.HIndex()[:]))
 }
 
+func TestEntryHashCache(t *testing.T) {
+	e := benchEntry(1)
+	hIndex, hValue := e.HIndex(), e.HValue()
+	assert.Equal(t, HashElems(e.Index()...), hIndex)
+	assert.Equal(t, HashElems(e.Value()...), hValue)
+	assert.True(t, hIndex == e.HIndex())
+
+	// Modifying the Data invalidates the cached hash of the modified part.
+	e.Data[IndexLen+1][0] = 1
+	assert.True(t, hIndex == e.HIndex())
+	assert.Equal(t, HashElems(e.Value()...), e.HValue())
+	assert.NotEqual(t, hValue, e.HValue())
+	e.Data[1][0] = 1
+	assert.Equal(t, HashElems(e.Index()...), e.HIndex())
+	assert.NotEqual(t, hIndex, e.HIndex())
+
+	// The cache is safe for concurrent use.
+	e = benchEntry(2)
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.Equal(t, HashElems(e.Index()...), e.HIndex())
+		}()
+	}
+	wg.Wait()
+}
+
 func TestData(t *testing.T) {
 	in := interfaceToInt64Array(testgen.GetTestValue("EntryInts0"))
 	data := IntArrayToData(in)
@@ -104,6 +136,28 @@ func TestAddEntry1(t *testing.T) {
 	testgen.CheckTestValue(t, "TestAddEntry1", mt.RootKey().Hex())
 }
 
+func TestAddEntryNotInField(t *testing.T) {
+	e := benchEntry(1)
+	for i := range e.Data[1] {
+		e.Data[1][i] = 0xff
+	}
+	require.False(t, CheckEntryInField(*e))
+
+	mt := newTestingMerkle(t, 140)
+	assert.Equal(t, ErrEntryNotInField, mt.AddEntry(e))
+	assert.Equal(t, ErrEntryNotInField, mt.AddEntryUnsafe(e))
+	assert.Equal(t, &HashZero, mt.RootKey())
+
+	// A tree whose hasher accepts the elements can add it unsafely.
+	mt, err := NewMerkleTreeWithHasher(db.NewMemoryStorage(), 140, Sha256Hasher{})
+	require.Nil(t, err)
+	assert.Equal(t, ErrEntryNotInField, mt.AddEntry(e))
+	require.Nil(t, mt.AddEntryUnsafe(e))
+	data, err := mt.GetDataByIndex(mt.EntryHIndex(e))
+	require.Nil(t, err)
+	assert.Equal(t, e.Data, *data)
+}
+
 func TestAddEntry2(t *testing.T) {
 	mt := newTestingMerkle(t, 140)
 	defer mt.Storage().Close()
@@ -744,6 +798,52 @@ func TestMTWalkDumpClaimsAndImportDumpedClaims(t *testing.T) {
 	assert.Equal(t, mt.RootKey().Hex(), mt2.RootKey().Hex())
 }
 
+func TestImportDumpedLeafs(t *testing.T) {
+	mt := newTestingMerkle(t, 140)
+	defer mt.Storage().Close()
+	dumpedLeafs := interfaceToStringArray(testgen.GetTestValue("DumpedClaims"))
+	require.Nil(t, mt.ImportDumpedClaims(dumpedLeafs))
+	expectedRoot := mt.RootKey()
+
+	mt2 := newTestingMerkle(t, 140)
+	defer mt2.Storage().Close()
+	var progress [][2]int
+	err := mt2.ImportDumpedLeafs(dumpedLeafs, expectedRoot, func(n, total int) {
+		progress = append(progress, [2]int{n, total})
+	})
+	require.Nil(t, err)
+	assert.Equal(t, expectedRoot, mt2.RootKey())
+	require.Equal(t, len(dumpedLeafs), len(progress))
+	assert.Equal(t, [2]int{len(dumpedLeafs), len(dumpedLeafs)}, progress[len(progress)-1])
+
+	// A root mismatch leaves the tree unmodified
+	mt3 := newTestingMerkle(t, 140)
+	defer mt3.Storage().Close()
+	emptyRoot := mt3.RootKey()
+	err = mt3.ImportDumpedLeafs(dumpedLeafs, &HashZero, nil)
+	assert.Equal(t, ErrRootMismatch, err)
+	assert.Equal(t, emptyRoot, mt3.RootKey())
+	_, err = mt3.GetNode(expectedRoot)
+	assert.Equal(t, db.ErrNotFound, err)
+
+	// A repeated leaf aborts the import
+	err = mt3.ImportDumpedLeafs(append(dumpedLeafs, dumpedLeafs[0]), nil, nil)
+	assert.True(t, errors.Is(err, ErrEntryIndexAlreadyExists))
+	assert.Equal(t, emptyRoot, mt3.RootKey())
+
+	// Leafs not in the field are rejected
+	var e Entry
+	for i := range e.Data {
+		copy(e.Data[i][:], bytes.Repeat([]byte{0xff}, ElemBytesLen))
+	}
+	err = mt3.ImportDumpedLeafs([]string{common3.HexEncode(e.Bytes())}, nil, nil)
+	assert.NotNil(t, err)
+	assert.Equal(t, emptyRoot, mt3.RootKey())
+
+	require.Nil(t, mt3.ImportDumpedLeafs(dumpedLeafs, nil, nil))
+	assert.Equal(t, expectedRoot, mt3.RootKey())
+}
+
 func TestAddRepeatedClaim(t *testing.T) {
 	mt := newTestingMerkle(t, 140)
 	defer mt.Storage().Close()
@@ -872,3 +972,45 @@ func TestMain(m *testing.M) {
 	}
 	os.Exit(result)
 }
+
+func TestDumpTreeDelta(t *testing.T) {
+	mt := newTestingMerkle(t, 140)
+	defer mt.Storage().Close()
+	for i := 0; i < 16; i++ {
+		e := NewEntryFromInts(int64(i), 0, 0, 0, 0, 0, 0, 0)
+		require.Nil(t, mt.AddEntry(&e))
+	}
+	fromRoot := mt.RootKey()
+	full := bytes.NewBufferString("")
+	require.Nil(t, mt.DumpTree(full, fromRoot))
+	for i := 16; i < 20; i++ {
+		e := NewEntryFromInts(int64(i), 0, 0, 0, 0, 0, 0, 0)
+		require.Nil(t, mt.AddEntry(&e))
+	}
+
+	delta := bytes.NewBufferString("")
+	require.Nil(t, mt.DumpTreeDelta(delta, fromRoot, nil))
+	fullNew := bytes.NewBufferString("")
+	require.Nil(t, mt.DumpTree(fullNew, nil))
+	assert.True(t, delta.Len() < fullNew.Len())
+
+	mt2 := newTestingMerkle(t, 140)
+	defer mt2.Storage().Close()
+	require.Nil(t, mt2.ImportTree(full))
+	assert.Equal(t, fromRoot, mt2.RootKey())
+	require.Nil(t, mt2.ImportTree(delta))
+	assert.Equal(t, mt.RootKey(), mt2.RootKey())
+	for i := 0; i < 20; i++ {
+		e := NewEntryFromInts(int64(i), 0, 0, 0, 0, 0, 0, 0)
+		_, err := mt2.GetDataByIndex(e.HIndex())
+		assert.Nil(t, err)
+	}
+
+	// A delta between equal roots only contains the root.
+	empty := bytes.NewBufferString("")
+	require.Nil(t, mt.DumpTreeDelta(empty, nil, nil))
+	mt3 := newTestingMerkle(t, 140)
+	defer mt3.Storage().Close()
+	require.Nil(t, mt3.ImportTree(empty))
+	assert.Equal(t, mt.RootKey(), mt3.RootKey())
+}
@@ -0,0 +1,92 @@
+package merkletree
+
+import (
+	"encoding/binary"
+	"errors"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// MetadataVersion is the version of the Metadata format written by this
+// package.
+const MetadataVersion = 1
+
+var (
+	// ErrMetadataNotFound is used when the storage has no Metadata,
+	// because it's empty or it has a tree created before the Metadata
+	// was persisted.
+	ErrMetadataNotFound = errors.New("the merkle tree metadata is not in the storage")
+	// ErrMetadataInvalid is used when the Metadata in the storage is
+	// malformed.
+	ErrMetadataInvalid = errors.New("the merkle tree metadata is invalid")
+	// ErrMetadataVersion is used when the Metadata in the storage was
+	// written by a newer, unsupported, version.
+	ErrMetadataVersion = errors.New("unsupported merkle tree metadata version")
+	// ErrMaxLevelsMismatch is used when a MerkleTree is opened with a
+	// maxLevels different than the one it was created with.
+	ErrMaxLevelsMismatch = errors.New("the maxLevels doesn't match the one of the merkle tree")
+	// ErrMaxLevelsUnknown is used when a MerkleTree without Metadata is
+	// opened without a maxLevels.
+	ErrMaxLevelsUnknown = errors.New("the maxLevels of the merkle tree is unknown")
+)
+
+// Metadata are the parameters of a MerkleTree, persisted in its storage on
+// creation.  Its binary encoding, stored after the DBEntryTypeMetadata byte,
+// is:
+//
+//	version     uint8
+//	maxLevels   uint32 (big-endian)
+//	hasher      the Hasher name
+type Metadata struct {
+	Version   uint8
+	MaxLevels int
+	// Hasher is the name of the Hasher.
+	Hasher string
+}
+
+// Bytes returns the binary encoding of the Metadata.
+func (md *Metadata) Bytes() []byte {
+	b := make([]byte, 5, 5+len(md.Hasher))
+	b[0] = md.Version
+	binary.BigEndian.PutUint32(b[1:5], uint32(md.MaxLevels))
+	return append(b, md.Hasher...)
+}
+
+// NewMetadataFromBytes parses the binary encoding of a Metadata.
+func NewMetadataFromBytes(b []byte) (*Metadata, error) {
+	if len(b) < 6 {
+		return nil, ErrMetadataInvalid
+	}
+	if b[0] > MetadataVersion {
+		return nil, ErrMetadataVersion
+	}
+	md := Metadata{
+		Version:   b[0],
+		MaxLevels: int(binary.BigEndian.Uint32(b[1:5])),
+		Hasher:    string(b[5:]),
+	}
+	if md.MaxLevels == 0 {
+		return nil, ErrMetadataInvalid
+	}
+	return &md, nil
+}
+
+// ReadMetadata reads the Metadata of the MerkleTree in storage, so that the
+// tree parameters can be inspected without opening it.
+func ReadMetadata(storage db.Storage) (*Metadata, error) {
+	v, err := storage.Get(metadataNodeValue)
+	if err == db.ErrNotFound {
+		return nil, ErrMetadataNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	if len(v) < 1 || NodeType(v[0]) != DBEntryTypeMetadata {
+		return nil, ErrMetadataInvalid
+	}
+	return NewMetadataFromBytes(v[1:])
+}
+
+// Metadata returns the parameters of the MerkleTree.
+func (mt *MerkleTree) Metadata() *Metadata {
+	return &Metadata{Version: MetadataVersion, MaxLevels: mt.maxLevels, Hasher: mt.hasher.Name()}
+}
@@ -0,0 +1,56 @@
+package merkletree
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadata(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	_, err := ReadMetadata(storage)
+	assert.Equal(t, ErrMetadataNotFound, err)
+	_, err = NewMerkleTree(storage, 0)
+	assert.Equal(t, ErrMaxLevelsUnknown, err)
+
+	mt, err := NewMerkleTreeWithHasher(storage, 40, Sha256Hasher{})
+	require.Nil(t, err)
+	e := NewEntryFromInts(1, 0, 0, 0, 0, 0, 0, 0)
+	require.Nil(t, mt.AddEntry(&e))
+	md, err := ReadMetadata(storage)
+	require.Nil(t, err)
+	assert.Equal(t, &Metadata{Version: MetadataVersion, MaxLevels: 40, Hasher: "sha256"}, md)
+	assert.Equal(t, md, mt.Metadata())
+
+	// The parameters of an existing tree are loaded from the metadata
+	mt2, err := NewMerkleTree(storage, 0)
+	require.Nil(t, err)
+	assert.Equal(t, 40, mt2.MaxLevels())
+	assert.Equal(t, Sha256Hasher{}, mt2.Hasher())
+	assert.Equal(t, mt.RootKey(), mt2.RootKey())
+	_, err = NewMerkleTree(storage, 140)
+	assert.Equal(t, ErrMaxLevelsMismatch, err)
+
+	// A mapped tree serves its metadata
+	var buf bytes.Buffer
+	require.Nil(t, mt.DumpMappedTree(&buf, nil))
+	mapped, err := NewMappedTree(buf.Bytes())
+	require.Nil(t, err)
+	md, err = ReadMetadata(mapped.Storage())
+	require.Nil(t, err)
+	assert.Equal(t, 40, md.MaxLevels)
+
+	_, err = NewMetadataFromBytes([]byte{MetadataVersion + 1, 0, 0, 0, 40, 'p'})
+	assert.Equal(t, ErrMetadataVersion, err)
+	_, err = NewMetadataFromBytes([]byte{MetadataVersion, 0, 0, 0, 0, 'p'})
+	assert.Equal(t, ErrMetadataInvalid, err)
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	tx.Put(metadataNodeValue, []byte{byte(DBEntryTypeRoot), 1})
+	require.Nil(t, tx.Commit())
+	_, err = NewMerkleTree(storage, 40)
+	assert.Equal(t, ErrMetadataInvalid, err)
+}
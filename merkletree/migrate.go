@@ -0,0 +1,87 @@
+package merkletree
+
+import (
+	"fmt"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+// migrateBatchLen is the number of nodes rewritten in each transaction by
+// MigrateNodeEncoding.
+const migrateBatchLen = 1024
+
+// NodeEncoding returns the encoding of the nodes written by the MerkleTree.
+func (mt *MerkleTree) NodeEncoding() NodeEncoding {
+	return mt.nodeEncoding
+}
+
+// MigrateNodeEncoding rewrites in place all the nodes in the storage of the
+// MerkleTree with the encoding enc, which is used by the nodes added
+// afterwards, and returns the number of nodes rewritten.  The keys of the
+// nodes don't depend on their encoding, so the roots and the proofs don't
+// change, and as the nodes of every encoding are read, an interrupted
+// migration can be run again.  The storage must not be written by other
+// MerkleTrees during the migration.
+func (mt *MerkleTree) MigrateNodeEncoding(enc NodeEncoding) (int, error) {
+	mt.Lock()
+	defer mt.Unlock()
+	if !mt.writable {
+		return 0, ErrNotWritable
+	}
+	if enc > NodeEncodingLast {
+		return 0, ErrNodeEncodingUnknown
+	}
+	count := 0
+	var tx db.Tx
+	commit := func() error {
+		if tx == nil {
+			return nil
+		}
+		err := tx.Commit()
+		tx.Close()
+		tx = nil
+		return err
+	}
+	defer func() {
+		if tx != nil {
+			tx.Close()
+		}
+	}()
+	if err := mt.storage.Iterate(func(k, v []byte) (bool, error) {
+		// The entries that are not nodes have shorter keys.
+		if len(k) != ElemBytesLen {
+			return true, nil
+		}
+		n, err := NewNodeFromBytes(v)
+		if err != nil {
+			return false, fmt.Errorf("node %x: %w", k, err)
+		}
+		if tx == nil {
+			if tx, err = mt.storage.NewTx(); err != nil {
+				return false, err
+			}
+		}
+		tx.Put(append([]byte{}, k...), n.Encode(enc))
+		count++
+		if count%migrateBatchLen == 0 {
+			if err := commit(); err != nil {
+				return false, err
+			}
+		}
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+	if tx == nil {
+		var err error
+		if tx, err = mt.storage.NewTx(); err != nil {
+			return 0, err
+		}
+	}
+	mt.dbInsert(tx, nodeEncodingNodeValue, DBEntryTypeNodeEncoding, []byte{byte(enc)})
+	if err := commit(); err != nil {
+		return 0, err
+	}
+	mt.nodeEncoding = enc
+	return count, nil
+}
@@ -0,0 +1,101 @@
+package merkletree
+
+import (
+	"testing"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNodeEncoding(t *testing.T) {
+	e := NewEntryFromInts(1, 2, 3, 4, 5, 6, 7, 8)
+	nodes := []*Node{
+		NewNodeLeaf(&e),
+		NewNodeMiddle(&Hash{1}, &Hash{2}),
+		NewNodeMiddle(&HashZero, &Hash{2}),
+		NewNodeMiddle(&Hash{1}, &HashZero),
+	}
+	for _, n := range nodes {
+		for _, enc := range []NodeEncoding{NodeEncodingV0, NodeEncodingV1} {
+			n2, err := NewNodeFromBytes(n.Encode(enc))
+			require.Nil(t, err)
+			assert.Equal(t, n.Key(), n2.Key())
+			assert.Equal(t, n.Value(), n2.Value())
+		}
+	}
+	assert.Equal(t, nodes[1].Value(), nodes[1].Encode(NodeEncodingV0))
+	// The empty children are not written.
+	assert.Equal(t, len(nodes[1].Encode(NodeEncodingV1))-ElemBytesLen, len(nodes[2].Encode(NodeEncodingV1)))
+
+	_, err := NewNodeFromBytes([]byte{0x80 | 7, byte(NodeTypeMiddle), 0})
+	assert.Equal(t, ErrNodeEncodingUnknown, err)
+	_, err = NewNodeFromBytes([]byte{0x81, byte(NodeTypeMiddle), nodeFlagEmptyL, 1})
+	assert.Equal(t, ErrNodeDataBadSize, err)
+	_, err = NewNodeFromBytes([]byte{0x81, byte(NodeTypeMiddle), nodeFlagEmptyL | nodeFlagEmptyR | 4})
+	assert.Equal(t, ErrNodeDataBadSize, err)
+}
+
+func TestMigrateNodeEncoding(t *testing.T) {
+	storage := db.NewMemoryStorage()
+	mt, err := NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	assert.Equal(t, NodeEncodingLast, mt.NodeEncoding())
+
+	// A tree created before the NodeEncoding was persisted.
+	_, err = mt.MigrateNodeEncoding(NodeEncodingV0)
+	require.Nil(t, err)
+	tx, err := storage.NewTx()
+	require.Nil(t, err)
+	tx.Delete(nodeEncodingNodeValue)
+	require.Nil(t, tx.Commit())
+	mt, err = NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	assert.Equal(t, NodeEncodingV0, mt.NodeEncoding())
+	for i := 0; i < 16; i++ {
+		e := NewEntryFromInts(int64(i), 0, 0, 0, 0, 0, 0, 0)
+		require.Nil(t, mt.AddEntry(&e))
+	}
+	root := mt.RootKey()
+	nodes := 0
+	require.Nil(t, storage.Iterate(func(k, v []byte) (bool, error) {
+		if len(k) == ElemBytesLen {
+			assert.True(t, v[0]&nodeEncodingVersioned == 0)
+			nodes++
+		}
+		return true, nil
+	}))
+	leafs, err := mt.CountLeafs()
+	require.Nil(t, err)
+	assert.Equal(t, 16, leafs)
+
+	n, err := mt.MigrateNodeEncoding(NodeEncodingV1)
+	require.Nil(t, err)
+	assert.Equal(t, nodes, n)
+	leafs, err = mt.CountLeafs()
+	require.Nil(t, err)
+	assert.Equal(t, 16, leafs)
+	assert.Equal(t, NodeEncodingV1, mt.NodeEncoding())
+	require.Nil(t, storage.Iterate(func(k, v []byte) (bool, error) {
+		if len(k) == ElemBytesLen {
+			assert.Equal(t, byte(0x81), v[0])
+		}
+		return true, nil
+	}))
+
+	// The migrated tree has the same root and proofs, and is reopened
+	// with the new encoding.
+	mt, err = NewMerkleTree(storage, 140)
+	require.Nil(t, err)
+	assert.Equal(t, NodeEncodingV1, mt.NodeEncoding())
+	assert.Equal(t, root, mt.RootKey())
+	e := NewEntryFromInts(3, 0, 0, 0, 0, 0, 0, 0)
+	proof, err := mt.GenerateProof(e.HIndex(), nil)
+	require.Nil(t, err)
+	assert.True(t, VerifyProof(root, proof, e.HIndex(), e.HValue()))
+	e = NewEntryFromInts(16, 0, 0, 0, 0, 0, 0, 0)
+	require.Nil(t, mt.AddEntry(&e))
+
+	_, err = mt.MigrateNodeEncoding(NodeEncodingLast + 1)
+	assert.Equal(t, ErrNodeEncodingUnknown, err)
+}
@@ -0,0 +1,16 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd && !solaris
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd,!solaris
+
+package merkletree
+
+import "io/ioutil"
+
+// mmapFile reads the file at path in memory, as memory-mapping is not
+// supported in this platform.
+func mmapFile(path string) ([]byte, func([]byte) error, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, nil, nil
+}
@@ -0,0 +1,267 @@
+package merkletree
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+
+	common3 "github.com/iden3/go-iden3-core/common"
+)
+
+var (
+	// ErrDuplicateHIndex is used when a multiproof is requested or verified
+	// with the same hIndex more than once.
+	ErrDuplicateHIndex = errors.New("duplicate hIndex in the multiproof")
+	// ErrInvalidMultiProof is used when a multiproof doesn't match the
+	// leaves it's verified against.
+	ErrInvalidMultiProof = errors.New("the multiproof is invalid")
+)
+
+// MultiProof is a proof of existence of several entries of a Merkle Tree
+// under the same root.  The siblings shared by the paths of the entries are
+// encoded once, and the siblings that are nodes of the paths themselves are
+// not encoded, as they are calculated from the entries.
+type MultiProof struct {
+	// Depths are the depths of the leaves of the entries, in the order of
+	// the hIndexes used to generate the proof.
+	Depths []uint `json:"depths"`
+	// Slots is the number of siblings needed to calculate the root, which
+	// are visited in depth first order (left before right).
+	Slots uint `json:"slots"`
+	// NotEmpties is a bitmap of the non-empty siblings of the Slots.
+	NotEmpties []byte `json:"-"`
+	// Siblings is the list of non-empty siblings.
+	Siblings []*Hash `json:"siblings"`
+}
+
+type multiProofJSON struct {
+	Depths     []uint  `json:"depths"`
+	Slots      uint    `json:"slots"`
+	NotEmpties string  `json:"notEmpties"`
+	Siblings   []*Hash `json:"siblings"`
+}
+
+func (mp *MultiProof) MarshalJSON() ([]byte, error) {
+	return json.Marshal(multiProofJSON{
+		Depths:     mp.Depths,
+		Slots:      mp.Slots,
+		NotEmpties: common3.HexEncode(mp.NotEmpties),
+		Siblings:   mp.Siblings,
+	})
+}
+
+func (mp *MultiProof) UnmarshalJSON(bs []byte) error {
+	var v multiProofJSON
+	if err := json.Unmarshal(bs, &v); err != nil {
+		return err
+	}
+	notEmpties, err := common3.HexDecode(v.NotEmpties)
+	if err != nil {
+		return err
+	}
+	*mp = MultiProof{Depths: v.Depths, Slots: v.Slots, NotEmpties: notEmpties, Siblings: v.Siblings}
+	return nil
+}
+
+// multiProofLeaf is an entry of a MultiProof and its path.
+type multiProofLeaf struct {
+	idx    int
+	hIndex *Hash
+	hValue *Hash
+	path   []bool
+}
+
+// splitLeaves splits leaves by their path bit at lvl.
+func splitLeaves(leaves []multiProofLeaf, lvl int) (left, right []multiProofLeaf) {
+	for _, l := range leaves {
+		if l.path[lvl] {
+			right = append(right, l)
+		} else {
+			left = append(left, l)
+		}
+	}
+	return left, right
+}
+
+func newMultiProofLeaves(numLevels int, hIndexes, hValues []*Hash) ([]multiProofLeaf, error) {
+	leaves := make([]multiProofLeaf, len(hIndexes))
+	for i, hIndex := range hIndexes {
+		for _, l := range leaves[:i] {
+			if bytes.Equal(l.hIndex[:], hIndex[:]) {
+				return nil, ErrDuplicateHIndex
+			}
+		}
+		leaves[i] = multiProofLeaf{idx: i, hIndex: hIndex, path: getPath(numLevels, hIndex)}
+		if hValues != nil {
+			leaves[i].hValue = hValues[i]
+		}
+	}
+	return leaves, nil
+}
+
+// GenerateMultiProof generates a proof of existence of the entries of all the
+// hIndexes for a Merkle Tree given the root.  If the rootKey is nil, the
+// current merkletree root is used.  If some entry is not in the tree,
+// ErrEntryIndexNotFound is returned.
+func (mt *MerkleTree) GenerateMultiProof(hIndexes []*Hash, rootKey *Hash) (*MultiProof, error) {
+	leaves, err := newMultiProofLeaves(mt.maxLevels, hIndexes, nil)
+	if err != nil {
+		return nil, err
+	}
+	if rootKey == nil {
+		rootKey = mt.RootKey()
+	}
+	mp := &MultiProof{Depths: make([]uint, len(hIndexes)), Siblings: []*Hash{}}
+	if len(leaves) == 0 {
+		return mp, nil
+	}
+	if err := mt.generateMultiProof(mp, rootKey, 0, leaves); err != nil {
+		return nil, err
+	}
+	return mp, nil
+}
+
+func (mt *MerkleTree) generateMultiProof(mp *MultiProof, key *Hash, lvl int, leaves []multiProofLeaf) error {
+	if len(leaves) == 0 {
+		mp.addSibling(key)
+		return nil
+	}
+	if lvl >= mt.maxLevels {
+		return ErrEntryIndexNotFound
+	}
+	n, err := mt.GetNode(key)
+	if err != nil {
+		return err
+	}
+	switch n.Type {
+	case NodeTypeEmpty:
+		return ErrEntryIndexNotFound
+	case NodeTypeLeaf:
+		if len(leaves) != 1 || !bytes.Equal(leaves[0].hIndex[:], entryHIndex(mt.hasher, n.Entry)[:]) {
+			return ErrEntryIndexNotFound
+		}
+		mp.Depths[leaves[0].idx] = uint(lvl)
+		return nil
+	case NodeTypeMiddle:
+		left, right := splitLeaves(leaves, lvl)
+		if err := mt.generateMultiProof(mp, n.ChildL, lvl+1, left); err != nil {
+			return err
+		}
+		return mt.generateMultiProof(mp, n.ChildR, lvl+1, right)
+	default:
+		return ErrInvalidNodeFound
+	}
+}
+
+// addSibling appends a sibling slot to the MultiProof.
+func (mp *MultiProof) addSibling(key *Hash) {
+	if mp.Slots%8 == 0 {
+		mp.NotEmpties = append(mp.NotEmpties, 0)
+	}
+	if !bytes.Equal(key[:], HashZero[:]) {
+		setBit(mp.NotEmpties, mp.Slots)
+		mp.Siblings = append(mp.Siblings, key)
+	}
+	mp.Slots++
+}
+
+// VerifyMultiProof verifies the MultiProof for the entries and root.  The
+// hIndexes and hValues of the entries must be in the same order used to
+// generate the proof.
+func VerifyMultiProof(rootKey *Hash, mp *MultiProof, hIndexes, hValues []*Hash) bool {
+	return VerifyMultiProofWithHasher(HasherDefault, rootKey, mp, hIndexes, hValues)
+}
+
+// VerifyMultiProofWithHasher verifies the MultiProof for the entries and
+// root of a tree that uses hasher.
+func VerifyMultiProofWithHasher(hasher Hasher, rootKey *Hash, mp *MultiProof, hIndexes, hValues []*Hash) bool {
+	root, err := RootFromMultiProofWithHasher(hasher, mp, hIndexes, hValues)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(rootKey[:], root[:])
+}
+
+// RootFromMultiProof calculates the root that would correspond to a tree
+// with the entries of hIndexes and hValues and the siblings of the proof.
+func RootFromMultiProof(mp *MultiProof, hIndexes, hValues []*Hash) (*Hash, error) {
+	return RootFromMultiProofWithHasher(HasherDefault, mp, hIndexes, hValues)
+}
+
+// multiProofReader consumes the siblings of a MultiProof in order.
+type multiProofReader struct {
+	mp     *MultiProof
+	slot   uint
+	sibIdx int
+}
+
+func (r *multiProofReader) next() (*Hash, error) {
+	if r.slot >= r.mp.Slots || int(r.slot/8) >= len(r.mp.NotEmpties) {
+		return nil, ErrInvalidMultiProof
+	}
+	notEmpty := testBit(r.mp.NotEmpties, r.slot)
+	r.slot++
+	if !notEmpty {
+		return &HashZero, nil
+	}
+	if r.sibIdx >= len(r.mp.Siblings) {
+		return nil, ErrInvalidMultiProof
+	}
+	r.sibIdx++
+	return r.mp.Siblings[r.sibIdx-1], nil
+}
+
+// RootFromMultiProofWithHasher calculates the root like RootFromMultiProof
+// for a tree that uses hasher.
+func RootFromMultiProofWithHasher(hasher Hasher, mp *MultiProof, hIndexes, hValues []*Hash) (*Hash, error) {
+	if len(hIndexes) == 0 || len(hIndexes) != len(hValues) || len(hIndexes) != len(mp.Depths) {
+		return nil, ErrInvalidMultiProof
+	}
+	maxDepth := uint(0)
+	for _, depth := range mp.Depths {
+		if depth > maxDepth {
+			maxDepth = depth
+		}
+	}
+	if maxDepth >= ElemBytesLen*8 {
+		return nil, ErrInvalidMultiProof
+	}
+	leaves, err := newMultiProofLeaves(int(maxDepth), hIndexes, hValues)
+	if err != nil {
+		return nil, err
+	}
+	r := &multiProofReader{mp: mp}
+	root, err := rootFromMultiProof(hasher, r, mp.Depths, 0, leaves)
+	if err != nil {
+		return nil, err
+	}
+	if r.slot != mp.Slots || r.sibIdx != len(mp.Siblings) {
+		return nil, ErrInvalidMultiProof
+	}
+	return root, nil
+}
+
+func rootFromMultiProof(hasher Hasher, r *multiProofReader, depths []uint, lvl uint, leaves []multiProofLeaf) (*Hash, error) {
+	if len(leaves) == 0 {
+		return r.next()
+	}
+	if len(leaves) == 1 && depths[leaves[0].idx] == lvl {
+		return leafKey(hasher, leaves[0].hIndex, leaves[0].hValue), nil
+	}
+	for _, l := range leaves {
+		// A leaf can't be in the path of another leaf.
+		if depths[l.idx] <= lvl {
+			return nil, ErrInvalidMultiProof
+		}
+	}
+	left, right := splitLeaves(leaves, int(lvl))
+	keyL, err := rootFromMultiProof(hasher, r, depths, lvl+1, left)
+	if err != nil {
+		return nil, err
+	}
+	keyR, err := rootFromMultiProof(hasher, r, depths, lvl+1, right)
+	if err != nil {
+		return nil, err
+	}
+	return nodeKey(hasher, NewNodeMiddle(keyL, keyR)), nil
+}
@@ -0,0 +1,119 @@
+package merkletree
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newMultiProofTestingMerkle(t *testing.T, n int) (*MerkleTree, []Entry) {
+	mt := newTestingMerkle(t, 140)
+	entries := make([]Entry, n)
+	for i := range entries {
+		entries[i] = NewEntryFromInts(int64(i), 0, 0, 0, int64(i*2), 0, 0, 0)
+		require.Nil(t, mt.AddEntry(&entries[i]))
+	}
+	return mt, entries
+}
+
+func multiProofHashes(entries ...Entry) ([]*Hash, []*Hash) {
+	hIndexes, hValues := make([]*Hash, len(entries)), make([]*Hash, len(entries))
+	for i := range entries {
+		hIndexes[i], hValues[i] = entries[i].HIndex(), entries[i].HValue()
+	}
+	return hIndexes, hValues
+}
+
+func TestMultiProof(t *testing.T) {
+	mt, entries := newMultiProofTestingMerkle(t, 64)
+	defer mt.Storage().Close()
+
+	for _, subset := range [][]Entry{
+		entries[4:5],
+		{entries[7], entries[3], entries[60]},
+		entries[10:30],
+		entries,
+	} {
+		hIndexes, hValues := multiProofHashes(subset...)
+		mp, err := mt.GenerateMultiProof(hIndexes, nil)
+		require.Nil(t, err)
+		assert.True(t, VerifyMultiProof(mt.RootKey(), mp, hIndexes, hValues))
+
+		// The shared siblings are encoded only once.
+		siblings := 0
+		for i := range subset {
+			p, err := mt.GenerateProof(hIndexes[i], nil)
+			require.Nil(t, err)
+			assert.Equal(t, p.depth, mp.Depths[i])
+			siblings += len(p.Siblings)
+		}
+		assert.True(t, len(mp.Siblings) <= siblings)
+		if len(subset) > 1 {
+			assert.True(t, len(mp.Siblings) < siblings)
+		}
+
+		mpJSON, err := json.Marshal(mp)
+		require.Nil(t, err)
+		var mp2 MultiProof
+		require.Nil(t, json.Unmarshal(mpJSON, &mp2))
+		assert.Equal(t, *mp, mp2)
+		assert.True(t, VerifyMultiProof(mt.RootKey(), &mp2, hIndexes, hValues))
+	}
+}
+
+func TestMultiProofInvalid(t *testing.T) {
+	mt, entries := newMultiProofTestingMerkle(t, 16)
+	defer mt.Storage().Close()
+
+	hIndexes, hValues := multiProofHashes(entries[1], entries[5], entries[9])
+	mp, err := mt.GenerateMultiProof(hIndexes, nil)
+	require.Nil(t, err)
+	require.True(t, VerifyMultiProof(mt.RootKey(), mp, hIndexes, hValues))
+
+	// Wrong value
+	hValuesBad := []*Hash{hValues[0], hValues[2], hValues[2]}
+	assert.False(t, VerifyMultiProof(mt.RootKey(), mp, hIndexes, hValuesBad))
+	// Wrong order
+	assert.False(t, VerifyMultiProof(mt.RootKey(), mp,
+		[]*Hash{hIndexes[1], hIndexes[0], hIndexes[2]}, []*Hash{hValues[1], hValues[0], hValues[2]}))
+	// Missing entry
+	assert.False(t, VerifyMultiProof(mt.RootKey(), mp, hIndexes[:2], hValues[:2]))
+	// Wrong root
+	assert.False(t, VerifyMultiProof(&Hash{1}, mp, hIndexes, hValues))
+	// Truncated siblings
+	mpBad := *mp
+	mpBad.Siblings = mp.Siblings[:len(mp.Siblings)-1]
+	_, err = RootFromMultiProof(&mpBad, hIndexes, hValues)
+	assert.Equal(t, ErrInvalidMultiProof, err)
+	// Extra siblings
+	mpBad.Siblings = append(append([]*Hash{}, mp.Siblings...), &Hash{1})
+	_, err = RootFromMultiProof(&mpBad, hIndexes, hValues)
+	assert.Equal(t, ErrInvalidMultiProof, err)
+	// Depth out of range
+	mpBad = *mp
+	mpBad.Depths = []uint{1000, mp.Depths[1], mp.Depths[2]}
+	_, err = RootFromMultiProof(&mpBad, hIndexes, hValues)
+	assert.Equal(t, ErrInvalidMultiProof, err)
+
+	_, err = mt.GenerateMultiProof([]*Hash{hIndexes[0], hIndexes[0]}, nil)
+	assert.Equal(t, ErrDuplicateHIndex, err)
+	notFound := NewEntryFromInts(1000, 0, 0, 0, 0, 0, 0, 0)
+	_, err = mt.GenerateMultiProof([]*Hash{hIndexes[0], notFound.HIndex()}, nil)
+	assert.Equal(t, ErrEntryIndexNotFound, err)
+}
+
+func TestMultiProofOldRoot(t *testing.T) {
+	mt, entries := newMultiProofTestingMerkle(t, 8)
+	defer mt.Storage().Close()
+	oldRoot := mt.RootKey()
+	e := NewEntryFromInts(100, 0, 0, 0, 0, 0, 0, 0)
+	require.Nil(t, mt.AddEntry(&e))
+
+	hIndexes, hValues := multiProofHashes(entries[0], entries[7])
+	mp, err := mt.GenerateMultiProof(hIndexes, oldRoot)
+	require.Nil(t, err)
+	assert.True(t, VerifyMultiProof(oldRoot, mp, hIndexes, hValues))
+	assert.False(t, VerifyMultiProof(mt.RootKey(), mp, hIndexes, hValues))
+}
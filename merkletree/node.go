@@ -18,6 +18,48 @@ const (
 
 	// DBEntryTypeRoot indicates the type of a DB entry that indicates the current Root of a MerkleTree
 	DBEntryTypeRoot NodeType = 3
+	// DBEntryTypeHasher indicates the type of a DB entry that indicates the Hasher of a MerkleTree
+	DBEntryTypeHasher NodeType = 4
+	// DBEntryTypeMetadata indicates the type of a DB entry that indicates the Metadata of a MerkleTree
+	DBEntryTypeMetadata NodeType = 5
+	// DBEntryTypeNodeEncoding indicates the type of a DB entry that indicates the NodeEncoding of a MerkleTree
+	DBEntryTypeNodeEncoding NodeType = 6
+)
+
+// NodeEncoding is the version of the encoding of the nodes stored in the
+// database.  The nodes of every version are decoded by NewNodeFromBytes, so
+// that a tree can be migrated in place (see MerkleTree.MigrateNodeEncoding).
+// The dumps of the trees (DumpTree, DumpMappedTree) are always encoded with
+// Node.Value, the NodeEncodingV0, for the readers that predate the versions.
+type NodeEncoding uint8
+
+const (
+	// NodeEncodingV0 is the original encoding, without a version byte:
+	//
+	//	middle: Type || ChildL || ChildR
+	//	leaf:   Type || Data...
+	NodeEncodingV0 NodeEncoding = 0
+	// NodeEncodingV1 starts with a version byte, and doesn't write the
+	// empty children of the middle nodes:
+	//
+	//	middle: 0x81 || Type || flags || [ChildL] || [ChildR]
+	//	leaf:   0x81 || Type || Data...
+	//
+	// where the bits 0 and 1 of flags are set when ChildL and ChildR are
+	// empty and not written.
+	NodeEncodingV1 NodeEncoding = 1
+
+	// NodeEncodingLast is the last NodeEncoding, used by the new trees.
+	NodeEncodingLast = NodeEncodingV1
+)
+
+// nodeEncodingVersioned is set in the first byte of the versioned encodings,
+// which can't be a NodeType of the NodeEncodingV0.
+const nodeEncodingVersioned = 0x80
+
+const (
+	nodeFlagEmptyL = 1 << 0
+	nodeFlagEmptyR = 1 << 1
 )
 
 // Node is the struct that represents a node in the MT. The node should not be
@@ -50,11 +92,24 @@ func NewNodeEmpty() *Node {
 	return &Node{Type: NodeTypeEmpty}
 }
 
-// NewNodeFromBytes creates a new node by parsing the input []byte.
+// NewNodeFromBytes creates a new node by parsing the input []byte, encoded
+// with any NodeEncoding.
 func NewNodeFromBytes(b []byte) (*Node, error) {
 	if len(b) < 1 {
 		return nil, ErrNodeDataBadSize
 	}
+	if b[0]&nodeEncodingVersioned == 0 {
+		return newNodeFromBytesV0(b)
+	}
+	switch NodeEncoding(b[0] &^ nodeEncodingVersioned) {
+	case NodeEncodingV1:
+		return newNodeFromBytesV1(b[1:])
+	default:
+		return nil, ErrNodeEncodingUnknown
+	}
+}
+
+func newNodeFromBytesV0(b []byte) (*Node, error) {
 	n := Node{Type: NodeType(b[0])}
 	b = b[1:]
 	switch n.Type {
@@ -66,12 +121,55 @@ func NewNodeFromBytes(b []byte) (*Node, error) {
 		copy(n.ChildL[:], b[:ElemBytesLen])
 		copy(n.ChildR[:], b[ElemBytesLen:ElemBytesLen*2])
 	case NodeTypeLeaf:
-		if len(b) != DataLen*ElemBytesLen {
+		if err := n.setEntryBytes(b); err != nil {
+			return nil, err
+		}
+	case NodeTypeEmpty:
+		break
+	default:
+		return nil, ErrInvalidNodeFound
+	}
+	return &n, nil
+}
+
+func newNodeFromBytesV1(b []byte) (*Node, error) {
+	if len(b) < 1 {
+		return nil, ErrNodeDataBadSize
+	}
+	n := Node{Type: NodeType(b[0])}
+	b = b[1:]
+	switch n.Type {
+	case NodeTypeMiddle:
+		if len(b) < 1 {
+			return nil, ErrNodeDataBadSize
+		}
+		flags := b[0]
+		b = b[1:]
+		child := func(empty bool) (*Hash, error) {
+			if empty {
+				return &Hash{}, nil
+			}
+			if len(b) < ElemBytesLen {
+				return nil, ErrNodeDataBadSize
+			}
+			h := &Hash{}
+			copy(h[:], b[:ElemBytesLen])
+			b = b[ElemBytesLen:]
+			return h, nil
+		}
+		var err error
+		if n.ChildL, err = child(flags&nodeFlagEmptyL != 0); err != nil {
+			return nil, err
+		}
+		if n.ChildR, err = child(flags&nodeFlagEmptyR != 0); err != nil {
+			return nil, err
+		}
+		if len(b) != 0 || flags&^(nodeFlagEmptyL|nodeFlagEmptyR) != 0 {
 			return nil, ErrNodeDataBadSize
 		}
-		n.Entry = &Entry{}
-		for i := 0; i < DataLen; i++ {
-			copy(n.Entry.Data[i][:], b[i*ElemBytesLen:(i+1)*ElemBytesLen])
+	case NodeTypeLeaf:
+		if err := n.setEntryBytes(b); err != nil {
+			return nil, err
 		}
 	case NodeTypeEmpty:
 		break
@@ -81,6 +179,31 @@ func NewNodeFromBytes(b []byte) (*Node, error) {
 	return &n, nil
 }
 
+// setEntryBytes sets the Entry of a leaf node from its Data bytes.
+func (n *Node) setEntryBytes(b []byte) error {
+	if len(b) != DataLen*ElemBytesLen {
+		return ErrNodeDataBadSize
+	}
+	n.Entry = &Entry{}
+	for i := 0; i < DataLen; i++ {
+		copy(n.Entry.Data[i][:], b[i*ElemBytesLen:(i+1)*ElemBytesLen])
+	}
+	return nil
+}
+
+// inField returns true if all the elements of the node are inside the Finite
+// Field, so that its key can be computed.
+func (n *Node) inField() bool {
+	switch n.Type {
+	case NodeTypeMiddle:
+		return n.ChildL.InField() && n.ChildR.InField()
+	case NodeTypeLeaf:
+		return CheckEntryInField(*n.Entry)
+	default:
+		return true
+	}
+}
+
 // LeafKey computes the key of a leaf node given the hIndex and hValue of the
 // entry of the leaf.
 func LeafKey(hIndex, hValue *Hash) *Hash {
@@ -122,6 +245,36 @@ func (n *Node) Value() []byte {
 	}
 }
 
+// Encode returns the value of the node with the encoding enc.  The
+// NodeEncodingV0 is the Value of the node.
+func (n *Node) Encode(enc NodeEncoding) []byte {
+	if enc == NodeEncodingV0 {
+		return n.Value()
+	}
+	header := []byte{nodeEncodingVersioned | byte(enc), byte(n.Type)}
+	switch n.Type {
+	case NodeTypeMiddle:
+		var flags byte
+		b := append(header, 0)
+		if n.ChildL.Equals(&HashZero) {
+			flags |= nodeFlagEmptyL
+		} else {
+			b = append(b, n.ChildL[:]...)
+		}
+		if n.ChildR.Equals(&HashZero) {
+			flags |= nodeFlagEmptyR
+		} else {
+			b = append(b, n.ChildR[:]...)
+		}
+		b[len(header)] = flags
+		return b
+	case NodeTypeLeaf:
+		return append(header, ElemsBytesToBytes(n.Entry.Data[:])...)
+	default:
+		return []byte{}
+	}
+}
+
 // String outputs a string representation of a node (different for each type).
 func (n *Node) String() string {
 	switch n.Type {
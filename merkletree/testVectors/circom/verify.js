@@ -0,0 +1,81 @@
+/*
+    Checks that the proofs of ../fixtures.json are accepted by the
+    TreeSMTVerifier circuit of smtverifier.circom, and that a proof with a
+    tampered root is rejected.
+
+    Usage: npm install && node verify.js
+*/
+
+const fs = require("fs");
+const path = require("path");
+const compiler = require("circom");
+const snarkjs = require("snarkjs");
+
+function inputs(ci) {
+    const bigInt = snarkjs.bigInt;
+    return {
+        enabled: bigInt(ci.enabled),
+        fnc: bigInt(ci.fnc),
+        root: bigInt(ci.root),
+        siblings: ci.siblings.map((s) => bigInt(s)),
+        oldKey: bigInt(ci.oldKey),
+        oldValue: bigInt(ci.oldValue),
+        isOld0: bigInt(ci.isOld0),
+        key: bigInt(ci.key),
+        value: bigInt(ci.value),
+    };
+}
+
+function accepts(circuit, input) {
+    try {
+        const w = circuit.calculateWitness(input);
+        return circuit.checkWitness(w);
+    } catch (err) {
+        return false;
+    }
+}
+
+async function circuitFor(maxLevels) {
+    const main = path.join(__dirname, `main${maxLevels}.circom`);
+    fs.writeFileSync(main,
+        "include \"smtverifier.circom\";\n\n" +
+        `component main = TreeSMTVerifier(${maxLevels});\n`);
+    try {
+        return new snarkjs.Circuit(await compiler(main));
+    } finally {
+        fs.unlinkSync(main);
+    }
+}
+
+async function run() {
+    const fixtures = JSON.parse(fs.readFileSync(path.join(__dirname, "..", "fixtures.json"), "utf8"));
+    const circuits = {};
+    let failed = 0;
+    for (const f of fixtures) {
+        if (!circuits[f.maxLevels]) {
+            circuits[f.maxLevels] = await circuitFor(f.maxLevels);
+        }
+        const circuit = circuits[f.maxLevels];
+        f.proofs.forEach((p, i) => {
+            if (!accepts(circuit, inputs(p.circom))) {
+                console.error(`${f.name}: proof ${i} is rejected`);
+                failed++;
+            }
+            const tampered = inputs(p.circom);
+            tampered.root = tampered.root.add(snarkjs.bigInt(1));
+            if (accepts(circuit, tampered)) {
+                console.error(`${f.name}: proof ${i} is accepted with a tampered root`);
+                failed++;
+            }
+        });
+        console.log(`${f.name}: ${f.proofs.length} proofs checked`);
+    }
+    if (failed > 0) {
+        throw new Error(`${failed} checks failed`);
+    }
+}
+
+run().catch((err) => {
+    console.error(err);
+    process.exit(1);
+});
@@ -49,8 +49,15 @@ func SwapEndianness(b []byte) []byte {
 	return o
 }
 
+// ElemBytesToBigInt returns the integer value of the little-endian elem.
 func ElemBytesToBigInt(elem ElemBytes) *big.Int {
-	return big.NewInt(0).SetBytes(SwapEndianness(elem[:]))
+	return elem.BigInt()
+}
+
+// InField returns true if the Hash is inside the Finite Field, so that it can
+// be hashed.
+func (h *Hash) InField() bool {
+	return inField(h.BigInt())
 }
 
 func (h1 *Hash) Equals(h2 *Hash) bool {
@@ -65,7 +72,10 @@ func ElemBytesToBigInts(elems ...ElemBytes) []*big.Int {
 	return ints
 }
 
-// BigIntToHash converts a *big.Int to a Hash.
+// BigIntToHash converts a *big.Int to a little-endian Hash.  The bytes of e
+// beyond ElemBytesLen are dropped, so it must only be used with values known
+// to be inside the Finite Field, like hash results; NewHashFromBigInt
+// validates the value instead.
 func BigIntToHash(e *big.Int) (h Hash) {
 	bs := SwapEndianness(e.Bytes())
 	copy(h[:], bs)
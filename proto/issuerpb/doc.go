@@ -0,0 +1,6 @@
+// Package issuerpb contains the protobuf messages and the gRPC service of
+// proto/issuer.proto, generated by protoc-gen-go with the grpc plugin.  Run
+// `make proto` (or go generate) after modifying the proto file.
+package issuerpb
+
+//go:generate protoc -I .. --go_out=plugins=grpc,paths=source_relative:. ../issuer.proto
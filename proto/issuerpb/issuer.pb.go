@@ -0,0 +1,1511 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// source: issuer.proto
+
+package issuerpb
+
+import (
+	context "context"
+	fmt "fmt"
+	proto "github.com/golang/protobuf/proto"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+	math "math"
+)
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ = proto.Marshal
+var _ = fmt.Errorf
+var _ = math.Inf
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the proto package it is being compiled against.
+// A compilation error at this line likely means your copy of the
+// proto package needs to be updated.
+const _ = proto.ProtoPackageIsVersion3 // please upgrade the proto package
+
+type PublishStateResponse_Status int32
+
+const (
+	// SUBMITTED means that a transaction to publish a new identity state
+	// has been sent.
+	PublishStateResponse_SUBMITTED PublishStateResponse_Status = 0
+	// NO_CHANGES means that the identity state hasn't changed since the
+	// last published one.
+	PublishStateResponse_NO_CHANGES PublishStateResponse_Status = 1
+	// ALREADY_PENDING means that a previously published identity state is
+	// still pending to be confirmed on chain.
+	PublishStateResponse_ALREADY_PENDING PublishStateResponse_Status = 2
+)
+
+var PublishStateResponse_Status_name = map[int32]string{
+	0: "SUBMITTED",
+	1: "NO_CHANGES",
+	2: "ALREADY_PENDING",
+}
+
+var PublishStateResponse_Status_value = map[string]int32{
+	"SUBMITTED":       0,
+	"NO_CHANGES":      1,
+	"ALREADY_PENDING": 2,
+}
+
+func (x PublishStateResponse_Status) String() string {
+	return proto.EnumName(PublishStateResponse_Status_name, int32(x))
+}
+
+func (PublishStateResponse_Status) EnumDescriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{9, 0}
+}
+
+type IssueClaimRequest struct {
+	// claim is the 128 byte claim entry.
+	Claim                []byte   `protobuf:"bytes,1,opt,name=claim,proto3" json:"claim,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IssueClaimRequest) Reset()         { *m = IssueClaimRequest{} }
+func (m *IssueClaimRequest) String() string { return proto.CompactTextString(m) }
+func (*IssueClaimRequest) ProtoMessage()    {}
+func (*IssueClaimRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{0}
+}
+
+func (m *IssueClaimRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IssueClaimRequest.Unmarshal(m, b)
+}
+func (m *IssueClaimRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IssueClaimRequest.Marshal(b, m, deterministic)
+}
+func (m *IssueClaimRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IssueClaimRequest.Merge(m, src)
+}
+func (m *IssueClaimRequest) XXX_Size() int {
+	return xxx_messageInfo_IssueClaimRequest.Size(m)
+}
+func (m *IssueClaimRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_IssueClaimRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IssueClaimRequest proto.InternalMessageInfo
+
+func (m *IssueClaimRequest) GetClaim() []byte {
+	if m != nil {
+		return m.Claim
+	}
+	return nil
+}
+
+type IssueClaimResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IssueClaimResponse) Reset()         { *m = IssueClaimResponse{} }
+func (m *IssueClaimResponse) String() string { return proto.CompactTextString(m) }
+func (*IssueClaimResponse) ProtoMessage()    {}
+func (*IssueClaimResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{1}
+}
+
+func (m *IssueClaimResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IssueClaimResponse.Unmarshal(m, b)
+}
+func (m *IssueClaimResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IssueClaimResponse.Marshal(b, m, deterministic)
+}
+func (m *IssueClaimResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IssueClaimResponse.Merge(m, src)
+}
+func (m *IssueClaimResponse) XXX_Size() int {
+	return xxx_messageInfo_IssueClaimResponse.Size(m)
+}
+func (m *IssueClaimResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_IssueClaimResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IssueClaimResponse proto.InternalMessageInfo
+
+type RevokeClaimRequest struct {
+	// claim is the 128 byte claim entry.
+	Claim                []byte   `protobuf:"bytes,1,opt,name=claim,proto3" json:"claim,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeClaimRequest) Reset()         { *m = RevokeClaimRequest{} }
+func (m *RevokeClaimRequest) String() string { return proto.CompactTextString(m) }
+func (*RevokeClaimRequest) ProtoMessage()    {}
+func (*RevokeClaimRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{2}
+}
+
+func (m *RevokeClaimRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeClaimRequest.Unmarshal(m, b)
+}
+func (m *RevokeClaimRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeClaimRequest.Marshal(b, m, deterministic)
+}
+func (m *RevokeClaimRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeClaimRequest.Merge(m, src)
+}
+func (m *RevokeClaimRequest) XXX_Size() int {
+	return xxx_messageInfo_RevokeClaimRequest.Size(m)
+}
+func (m *RevokeClaimRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeClaimRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeClaimRequest proto.InternalMessageInfo
+
+func (m *RevokeClaimRequest) GetClaim() []byte {
+	if m != nil {
+		return m.Claim
+	}
+	return nil
+}
+
+type RevokeClaimResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *RevokeClaimResponse) Reset()         { *m = RevokeClaimResponse{} }
+func (m *RevokeClaimResponse) String() string { return proto.CompactTextString(m) }
+func (*RevokeClaimResponse) ProtoMessage()    {}
+func (*RevokeClaimResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{3}
+}
+
+func (m *RevokeClaimResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_RevokeClaimResponse.Unmarshal(m, b)
+}
+func (m *RevokeClaimResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_RevokeClaimResponse.Marshal(b, m, deterministic)
+}
+func (m *RevokeClaimResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RevokeClaimResponse.Merge(m, src)
+}
+func (m *RevokeClaimResponse) XXX_Size() int {
+	return xxx_messageInfo_RevokeClaimResponse.Size(m)
+}
+func (m *RevokeClaimResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_RevokeClaimResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RevokeClaimResponse proto.InternalMessageInfo
+
+type GetCredentialRequest struct {
+	// claim is the 128 byte claim entry.
+	Claim                []byte   `protobuf:"bytes,1,opt,name=claim,proto3" json:"claim,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetCredentialRequest) Reset()         { *m = GetCredentialRequest{} }
+func (m *GetCredentialRequest) String() string { return proto.CompactTextString(m) }
+func (*GetCredentialRequest) ProtoMessage()    {}
+func (*GetCredentialRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{4}
+}
+
+func (m *GetCredentialRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCredentialRequest.Unmarshal(m, b)
+}
+func (m *GetCredentialRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCredentialRequest.Marshal(b, m, deterministic)
+}
+func (m *GetCredentialRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCredentialRequest.Merge(m, src)
+}
+func (m *GetCredentialRequest) XXX_Size() int {
+	return xxx_messageInfo_GetCredentialRequest.Size(m)
+}
+func (m *GetCredentialRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCredentialRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCredentialRequest proto.InternalMessageInfo
+
+func (m *GetCredentialRequest) GetClaim() []byte {
+	if m != nil {
+		return m.Claim
+	}
+	return nil
+}
+
+type IdenStateData struct {
+	BlockN               uint64   `protobuf:"varint,1,opt,name=block_n,json=blockN,proto3" json:"block_n,omitempty"`
+	BlockTs              int64    `protobuf:"varint,2,opt,name=block_ts,json=blockTs,proto3" json:"block_ts,omitempty"`
+	IdenState            []byte   `protobuf:"bytes,3,opt,name=iden_state,json=idenState,proto3" json:"iden_state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *IdenStateData) Reset()         { *m = IdenStateData{} }
+func (m *IdenStateData) String() string { return proto.CompactTextString(m) }
+func (*IdenStateData) ProtoMessage()    {}
+func (*IdenStateData) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{5}
+}
+
+func (m *IdenStateData) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_IdenStateData.Unmarshal(m, b)
+}
+func (m *IdenStateData) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_IdenStateData.Marshal(b, m, deterministic)
+}
+func (m *IdenStateData) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_IdenStateData.Merge(m, src)
+}
+func (m *IdenStateData) XXX_Size() int {
+	return xxx_messageInfo_IdenStateData.Size(m)
+}
+func (m *IdenStateData) XXX_DiscardUnknown() {
+	xxx_messageInfo_IdenStateData.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_IdenStateData proto.InternalMessageInfo
+
+func (m *IdenStateData) GetBlockN() uint64 {
+	if m != nil {
+		return m.BlockN
+	}
+	return 0
+}
+
+func (m *IdenStateData) GetBlockTs() int64 {
+	if m != nil {
+		return m.BlockTs
+	}
+	return 0
+}
+
+func (m *IdenStateData) GetIdenState() []byte {
+	if m != nil {
+		return m.IdenState
+	}
+	return nil
+}
+
+type CredentialExistence struct {
+	Id                   []byte         `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	IdenStateData        *IdenStateData `protobuf:"bytes,2,opt,name=iden_state_data,json=idenStateData,proto3" json:"iden_state_data,omitempty"`
+	MtpClaim             []byte         `protobuf:"bytes,3,opt,name=mtp_claim,json=mtpClaim,proto3" json:"mtp_claim,omitempty"`
+	Claim                []byte         `protobuf:"bytes,4,opt,name=claim,proto3" json:"claim,omitempty"`
+	RevocationsRoot      []byte         `protobuf:"bytes,5,opt,name=revocations_root,json=revocationsRoot,proto3" json:"revocations_root,omitempty"`
+	RootsRoot            []byte         `protobuf:"bytes,6,opt,name=roots_root,json=rootsRoot,proto3" json:"roots_root,omitempty"`
+	IdPubUrl             string         `protobuf:"bytes,7,opt,name=id_pub_url,json=idPubUrl,proto3" json:"id_pub_url,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}       `json:"-"`
+	XXX_unrecognized     []byte         `json:"-"`
+	XXX_sizecache        int32          `json:"-"`
+}
+
+func (m *CredentialExistence) Reset()         { *m = CredentialExistence{} }
+func (m *CredentialExistence) String() string { return proto.CompactTextString(m) }
+func (*CredentialExistence) ProtoMessage()    {}
+func (*CredentialExistence) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{6}
+}
+
+func (m *CredentialExistence) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CredentialExistence.Unmarshal(m, b)
+}
+func (m *CredentialExistence) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CredentialExistence.Marshal(b, m, deterministic)
+}
+func (m *CredentialExistence) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CredentialExistence.Merge(m, src)
+}
+func (m *CredentialExistence) XXX_Size() int {
+	return xxx_messageInfo_CredentialExistence.Size(m)
+}
+func (m *CredentialExistence) XXX_DiscardUnknown() {
+	xxx_messageInfo_CredentialExistence.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CredentialExistence proto.InternalMessageInfo
+
+func (m *CredentialExistence) GetId() []byte {
+	if m != nil {
+		return m.Id
+	}
+	return nil
+}
+
+func (m *CredentialExistence) GetIdenStateData() *IdenStateData {
+	if m != nil {
+		return m.IdenStateData
+	}
+	return nil
+}
+
+func (m *CredentialExistence) GetMtpClaim() []byte {
+	if m != nil {
+		return m.MtpClaim
+	}
+	return nil
+}
+
+func (m *CredentialExistence) GetClaim() []byte {
+	if m != nil {
+		return m.Claim
+	}
+	return nil
+}
+
+func (m *CredentialExistence) GetRevocationsRoot() []byte {
+	if m != nil {
+		return m.RevocationsRoot
+	}
+	return nil
+}
+
+func (m *CredentialExistence) GetRootsRoot() []byte {
+	if m != nil {
+		return m.RootsRoot
+	}
+	return nil
+}
+
+func (m *CredentialExistence) GetIdPubUrl() string {
+	if m != nil {
+		return m.IdPubUrl
+	}
+	return ""
+}
+
+type GetCredentialResponse struct {
+	Credential           *CredentialExistence `protobuf:"bytes,1,opt,name=credential,proto3" json:"credential,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}             `json:"-"`
+	XXX_unrecognized     []byte               `json:"-"`
+	XXX_sizecache        int32                `json:"-"`
+}
+
+func (m *GetCredentialResponse) Reset()         { *m = GetCredentialResponse{} }
+func (m *GetCredentialResponse) String() string { return proto.CompactTextString(m) }
+func (*GetCredentialResponse) ProtoMessage()    {}
+func (*GetCredentialResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{7}
+}
+
+func (m *GetCredentialResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetCredentialResponse.Unmarshal(m, b)
+}
+func (m *GetCredentialResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetCredentialResponse.Marshal(b, m, deterministic)
+}
+func (m *GetCredentialResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetCredentialResponse.Merge(m, src)
+}
+func (m *GetCredentialResponse) XXX_Size() int {
+	return xxx_messageInfo_GetCredentialResponse.Size(m)
+}
+func (m *GetCredentialResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetCredentialResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetCredentialResponse proto.InternalMessageInfo
+
+func (m *GetCredentialResponse) GetCredential() *CredentialExistence {
+	if m != nil {
+		return m.Credential
+	}
+	return nil
+}
+
+type PublishStateRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PublishStateRequest) Reset()         { *m = PublishStateRequest{} }
+func (m *PublishStateRequest) String() string { return proto.CompactTextString(m) }
+func (*PublishStateRequest) ProtoMessage()    {}
+func (*PublishStateRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{8}
+}
+
+func (m *PublishStateRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PublishStateRequest.Unmarshal(m, b)
+}
+func (m *PublishStateRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PublishStateRequest.Marshal(b, m, deterministic)
+}
+func (m *PublishStateRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PublishStateRequest.Merge(m, src)
+}
+func (m *PublishStateRequest) XXX_Size() int {
+	return xxx_messageInfo_PublishStateRequest.Size(m)
+}
+func (m *PublishStateRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_PublishStateRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PublishStateRequest proto.InternalMessageInfo
+
+type PublishStateResponse struct {
+	Status PublishStateResponse_Status `protobuf:"varint,1,opt,name=status,proto3,enum=issuer.PublishStateResponse_Status" json:"status,omitempty"`
+	// iden_state is the submitted, pending or last published identity state
+	// depending on the status.
+	IdenState []byte `protobuf:"bytes,2,opt,name=iden_state,json=idenState,proto3" json:"iden_state,omitempty"`
+	// eth_tx_hash is the hash of the transaction of the submitted or pending
+	// identity state.  Empty if the status is NO_CHANGES.
+	EthTxHash            []byte   `protobuf:"bytes,3,opt,name=eth_tx_hash,json=ethTxHash,proto3" json:"eth_tx_hash,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PublishStateResponse) Reset()         { *m = PublishStateResponse{} }
+func (m *PublishStateResponse) String() string { return proto.CompactTextString(m) }
+func (*PublishStateResponse) ProtoMessage()    {}
+func (*PublishStateResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{9}
+}
+
+func (m *PublishStateResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PublishStateResponse.Unmarshal(m, b)
+}
+func (m *PublishStateResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PublishStateResponse.Marshal(b, m, deterministic)
+}
+func (m *PublishStateResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PublishStateResponse.Merge(m, src)
+}
+func (m *PublishStateResponse) XXX_Size() int {
+	return xxx_messageInfo_PublishStateResponse.Size(m)
+}
+func (m *PublishStateResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_PublishStateResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PublishStateResponse proto.InternalMessageInfo
+
+func (m *PublishStateResponse) GetStatus() PublishStateResponse_Status {
+	if m != nil {
+		return m.Status
+	}
+	return PublishStateResponse_SUBMITTED
+}
+
+func (m *PublishStateResponse) GetIdenState() []byte {
+	if m != nil {
+		return m.IdenState
+	}
+	return nil
+}
+
+func (m *PublishStateResponse) GetEthTxHash() []byte {
+	if m != nil {
+		return m.EthTxHash
+	}
+	return nil
+}
+
+type GetPublicDataRequest struct {
+	// iden_state is the identity state of the requested public data.  If
+	// empty, the last public data is returned.
+	IdenState            []byte   `protobuf:"bytes,1,opt,name=iden_state,json=idenState,proto3" json:"iden_state,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetPublicDataRequest) Reset()         { *m = GetPublicDataRequest{} }
+func (m *GetPublicDataRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPublicDataRequest) ProtoMessage()    {}
+func (*GetPublicDataRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{10}
+}
+
+func (m *GetPublicDataRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetPublicDataRequest.Unmarshal(m, b)
+}
+func (m *GetPublicDataRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetPublicDataRequest.Marshal(b, m, deterministic)
+}
+func (m *GetPublicDataRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetPublicDataRequest.Merge(m, src)
+}
+func (m *GetPublicDataRequest) XXX_Size() int {
+	return xxx_messageInfo_GetPublicDataRequest.Size(m)
+}
+func (m *GetPublicDataRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetPublicDataRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetPublicDataRequest proto.InternalMessageInfo
+
+func (m *GetPublicDataRequest) GetIdenState() []byte {
+	if m != nil {
+		return m.IdenState
+	}
+	return nil
+}
+
+type PublicDataChunk struct {
+	IdenState            []byte   `protobuf:"bytes,1,opt,name=iden_state,json=idenState,proto3" json:"iden_state,omitempty"`
+	ClaimsTreeRoot       []byte   `protobuf:"bytes,2,opt,name=claims_tree_root,json=claimsTreeRoot,proto3" json:"claims_tree_root,omitempty"`
+	RevocationsTreeRoot  []byte   `protobuf:"bytes,3,opt,name=revocations_tree_root,json=revocationsTreeRoot,proto3" json:"revocations_tree_root,omitempty"`
+	RootsTreeRoot        []byte   `protobuf:"bytes,4,opt,name=roots_tree_root,json=rootsTreeRoot,proto3" json:"roots_tree_root,omitempty"`
+	RootsTree            []byte   `protobuf:"bytes,5,opt,name=roots_tree,json=rootsTree,proto3" json:"roots_tree,omitempty"`
+	RevocationsTree      []byte   `protobuf:"bytes,6,opt,name=revocations_tree,json=revocationsTree,proto3" json:"revocations_tree,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *PublicDataChunk) Reset()         { *m = PublicDataChunk{} }
+func (m *PublicDataChunk) String() string { return proto.CompactTextString(m) }
+func (*PublicDataChunk) ProtoMessage()    {}
+func (*PublicDataChunk) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{11}
+}
+
+func (m *PublicDataChunk) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_PublicDataChunk.Unmarshal(m, b)
+}
+func (m *PublicDataChunk) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_PublicDataChunk.Marshal(b, m, deterministic)
+}
+func (m *PublicDataChunk) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_PublicDataChunk.Merge(m, src)
+}
+func (m *PublicDataChunk) XXX_Size() int {
+	return xxx_messageInfo_PublicDataChunk.Size(m)
+}
+func (m *PublicDataChunk) XXX_DiscardUnknown() {
+	xxx_messageInfo_PublicDataChunk.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_PublicDataChunk proto.InternalMessageInfo
+
+func (m *PublicDataChunk) GetIdenState() []byte {
+	if m != nil {
+		return m.IdenState
+	}
+	return nil
+}
+
+func (m *PublicDataChunk) GetClaimsTreeRoot() []byte {
+	if m != nil {
+		return m.ClaimsTreeRoot
+	}
+	return nil
+}
+
+func (m *PublicDataChunk) GetRevocationsTreeRoot() []byte {
+	if m != nil {
+		return m.RevocationsTreeRoot
+	}
+	return nil
+}
+
+func (m *PublicDataChunk) GetRootsTreeRoot() []byte {
+	if m != nil {
+		return m.RootsTreeRoot
+	}
+	return nil
+}
+
+func (m *PublicDataChunk) GetRootsTree() []byte {
+	if m != nil {
+		return m.RootsTree
+	}
+	return nil
+}
+
+func (m *PublicDataChunk) GetRevocationsTree() []byte {
+	if m != nil {
+		return m.RevocationsTree
+	}
+	return nil
+}
+
+type Recovery struct {
+	// new_kop is the compressed operational key set by the recovery.
+	NewKop []byte `protobuf:"bytes,1,opt,name=new_kop,json=newKop,proto3" json:"new_kop,omitempty"`
+	// not_before is the unix time after which the recovery can be
+	// completed.
+	NotBefore int64 `protobuf:"varint,2,opt,name=not_before,json=notBefore,proto3" json:"not_before,omitempty"`
+	// nonce is the recovery nonce signed by the recoverer.
+	Nonce                uint32   `protobuf:"varint,3,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *Recovery) Reset()         { *m = Recovery{} }
+func (m *Recovery) String() string { return proto.CompactTextString(m) }
+func (*Recovery) ProtoMessage()    {}
+func (*Recovery) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{12}
+}
+
+func (m *Recovery) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_Recovery.Unmarshal(m, b)
+}
+func (m *Recovery) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_Recovery.Marshal(b, m, deterministic)
+}
+func (m *Recovery) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_Recovery.Merge(m, src)
+}
+func (m *Recovery) XXX_Size() int {
+	return xxx_messageInfo_Recovery.Size(m)
+}
+func (m *Recovery) XXX_DiscardUnknown() {
+	xxx_messageInfo_Recovery.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_Recovery proto.InternalMessageInfo
+
+func (m *Recovery) GetNewKop() []byte {
+	if m != nil {
+		return m.NewKop
+	}
+	return nil
+}
+
+func (m *Recovery) GetNotBefore() int64 {
+	if m != nil {
+		return m.NotBefore
+	}
+	return 0
+}
+
+func (m *Recovery) GetNonce() uint32 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+type GetRecoveryRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetRecoveryRequest) Reset()         { *m = GetRecoveryRequest{} }
+func (m *GetRecoveryRequest) String() string { return proto.CompactTextString(m) }
+func (*GetRecoveryRequest) ProtoMessage()    {}
+func (*GetRecoveryRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{13}
+}
+
+func (m *GetRecoveryRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRecoveryRequest.Unmarshal(m, b)
+}
+func (m *GetRecoveryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRecoveryRequest.Marshal(b, m, deterministic)
+}
+func (m *GetRecoveryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRecoveryRequest.Merge(m, src)
+}
+func (m *GetRecoveryRequest) XXX_Size() int {
+	return xxx_messageInfo_GetRecoveryRequest.Size(m)
+}
+func (m *GetRecoveryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRecoveryRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRecoveryRequest proto.InternalMessageInfo
+
+type GetRecoveryResponse struct {
+	// recovery is the pending recovery, unset if there is none.
+	Recovery *Recovery `protobuf:"bytes,1,opt,name=recovery,proto3" json:"recovery,omitempty"`
+	// nonce is the nonce that the recoverer must sign in the next recovery.
+	Nonce                uint32   `protobuf:"varint,2,opt,name=nonce,proto3" json:"nonce,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *GetRecoveryResponse) Reset()         { *m = GetRecoveryResponse{} }
+func (m *GetRecoveryResponse) String() string { return proto.CompactTextString(m) }
+func (*GetRecoveryResponse) ProtoMessage()    {}
+func (*GetRecoveryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{14}
+}
+
+func (m *GetRecoveryResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_GetRecoveryResponse.Unmarshal(m, b)
+}
+func (m *GetRecoveryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_GetRecoveryResponse.Marshal(b, m, deterministic)
+}
+func (m *GetRecoveryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_GetRecoveryResponse.Merge(m, src)
+}
+func (m *GetRecoveryResponse) XXX_Size() int {
+	return xxx_messageInfo_GetRecoveryResponse.Size(m)
+}
+func (m *GetRecoveryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_GetRecoveryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_GetRecoveryResponse proto.InternalMessageInfo
+
+func (m *GetRecoveryResponse) GetRecovery() *Recovery {
+	if m != nil {
+		return m.Recovery
+	}
+	return nil
+}
+
+func (m *GetRecoveryResponse) GetNonce() uint32 {
+	if m != nil {
+		return m.Nonce
+	}
+	return 0
+}
+
+type InitRecoveryRequest struct {
+	// new_kop is the compressed operational key set by the recovery.
+	NewKop []byte `protobuf:"bytes,1,opt,name=new_kop,json=newKop,proto3" json:"new_kop,omitempty"`
+	// signature is the 65 byte EIP-191 signature by the recoverer of the
+	// recovery message (see issuer.Issuer.RecoveryMsg).
+	Signature            []byte   `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *InitRecoveryRequest) Reset()         { *m = InitRecoveryRequest{} }
+func (m *InitRecoveryRequest) String() string { return proto.CompactTextString(m) }
+func (*InitRecoveryRequest) ProtoMessage()    {}
+func (*InitRecoveryRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{15}
+}
+
+func (m *InitRecoveryRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InitRecoveryRequest.Unmarshal(m, b)
+}
+func (m *InitRecoveryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InitRecoveryRequest.Marshal(b, m, deterministic)
+}
+func (m *InitRecoveryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InitRecoveryRequest.Merge(m, src)
+}
+func (m *InitRecoveryRequest) XXX_Size() int {
+	return xxx_messageInfo_InitRecoveryRequest.Size(m)
+}
+func (m *InitRecoveryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_InitRecoveryRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InitRecoveryRequest proto.InternalMessageInfo
+
+func (m *InitRecoveryRequest) GetNewKop() []byte {
+	if m != nil {
+		return m.NewKop
+	}
+	return nil
+}
+
+func (m *InitRecoveryRequest) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type InitRecoveryResponse struct {
+	Recovery             *Recovery `protobuf:"bytes,1,opt,name=recovery,proto3" json:"recovery,omitempty"`
+	XXX_NoUnkeyedLiteral struct{}  `json:"-"`
+	XXX_unrecognized     []byte    `json:"-"`
+	XXX_sizecache        int32     `json:"-"`
+}
+
+func (m *InitRecoveryResponse) Reset()         { *m = InitRecoveryResponse{} }
+func (m *InitRecoveryResponse) String() string { return proto.CompactTextString(m) }
+func (*InitRecoveryResponse) ProtoMessage()    {}
+func (*InitRecoveryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{16}
+}
+
+func (m *InitRecoveryResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_InitRecoveryResponse.Unmarshal(m, b)
+}
+func (m *InitRecoveryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_InitRecoveryResponse.Marshal(b, m, deterministic)
+}
+func (m *InitRecoveryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_InitRecoveryResponse.Merge(m, src)
+}
+func (m *InitRecoveryResponse) XXX_Size() int {
+	return xxx_messageInfo_InitRecoveryResponse.Size(m)
+}
+func (m *InitRecoveryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_InitRecoveryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_InitRecoveryResponse proto.InternalMessageInfo
+
+func (m *InitRecoveryResponse) GetRecovery() *Recovery {
+	if m != nil {
+		return m.Recovery
+	}
+	return nil
+}
+
+type CancelRecoveryRequest struct {
+	// signature is the 64 byte signature by the operational key of the
+	// cancellation message (see issuer.Issuer.CancelRecoveryMsg).
+	Signature            []byte   `protobuf:"bytes,1,opt,name=signature,proto3" json:"signature,omitempty"`
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CancelRecoveryRequest) Reset()         { *m = CancelRecoveryRequest{} }
+func (m *CancelRecoveryRequest) String() string { return proto.CompactTextString(m) }
+func (*CancelRecoveryRequest) ProtoMessage()    {}
+func (*CancelRecoveryRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{17}
+}
+
+func (m *CancelRecoveryRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CancelRecoveryRequest.Unmarshal(m, b)
+}
+func (m *CancelRecoveryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CancelRecoveryRequest.Marshal(b, m, deterministic)
+}
+func (m *CancelRecoveryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelRecoveryRequest.Merge(m, src)
+}
+func (m *CancelRecoveryRequest) XXX_Size() int {
+	return xxx_messageInfo_CancelRecoveryRequest.Size(m)
+}
+func (m *CancelRecoveryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelRecoveryRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelRecoveryRequest proto.InternalMessageInfo
+
+func (m *CancelRecoveryRequest) GetSignature() []byte {
+	if m != nil {
+		return m.Signature
+	}
+	return nil
+}
+
+type CancelRecoveryResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CancelRecoveryResponse) Reset()         { *m = CancelRecoveryResponse{} }
+func (m *CancelRecoveryResponse) String() string { return proto.CompactTextString(m) }
+func (*CancelRecoveryResponse) ProtoMessage()    {}
+func (*CancelRecoveryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{18}
+}
+
+func (m *CancelRecoveryResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CancelRecoveryResponse.Unmarshal(m, b)
+}
+func (m *CancelRecoveryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CancelRecoveryResponse.Marshal(b, m, deterministic)
+}
+func (m *CancelRecoveryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CancelRecoveryResponse.Merge(m, src)
+}
+func (m *CancelRecoveryResponse) XXX_Size() int {
+	return xxx_messageInfo_CancelRecoveryResponse.Size(m)
+}
+func (m *CancelRecoveryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CancelRecoveryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CancelRecoveryResponse proto.InternalMessageInfo
+
+type CompleteRecoveryRequest struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CompleteRecoveryRequest) Reset()         { *m = CompleteRecoveryRequest{} }
+func (m *CompleteRecoveryRequest) String() string { return proto.CompactTextString(m) }
+func (*CompleteRecoveryRequest) ProtoMessage()    {}
+func (*CompleteRecoveryRequest) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{19}
+}
+
+func (m *CompleteRecoveryRequest) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CompleteRecoveryRequest.Unmarshal(m, b)
+}
+func (m *CompleteRecoveryRequest) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CompleteRecoveryRequest.Marshal(b, m, deterministic)
+}
+func (m *CompleteRecoveryRequest) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CompleteRecoveryRequest.Merge(m, src)
+}
+func (m *CompleteRecoveryRequest) XXX_Size() int {
+	return xxx_messageInfo_CompleteRecoveryRequest.Size(m)
+}
+func (m *CompleteRecoveryRequest) XXX_DiscardUnknown() {
+	xxx_messageInfo_CompleteRecoveryRequest.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CompleteRecoveryRequest proto.InternalMessageInfo
+
+type CompleteRecoveryResponse struct {
+	XXX_NoUnkeyedLiteral struct{} `json:"-"`
+	XXX_unrecognized     []byte   `json:"-"`
+	XXX_sizecache        int32    `json:"-"`
+}
+
+func (m *CompleteRecoveryResponse) Reset()         { *m = CompleteRecoveryResponse{} }
+func (m *CompleteRecoveryResponse) String() string { return proto.CompactTextString(m) }
+func (*CompleteRecoveryResponse) ProtoMessage()    {}
+func (*CompleteRecoveryResponse) Descriptor() ([]byte, []int) {
+	return fileDescriptor_8e8d83c9b614651f, []int{20}
+}
+
+func (m *CompleteRecoveryResponse) XXX_Unmarshal(b []byte) error {
+	return xxx_messageInfo_CompleteRecoveryResponse.Unmarshal(m, b)
+}
+func (m *CompleteRecoveryResponse) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	return xxx_messageInfo_CompleteRecoveryResponse.Marshal(b, m, deterministic)
+}
+func (m *CompleteRecoveryResponse) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_CompleteRecoveryResponse.Merge(m, src)
+}
+func (m *CompleteRecoveryResponse) XXX_Size() int {
+	return xxx_messageInfo_CompleteRecoveryResponse.Size(m)
+}
+func (m *CompleteRecoveryResponse) XXX_DiscardUnknown() {
+	xxx_messageInfo_CompleteRecoveryResponse.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_CompleteRecoveryResponse proto.InternalMessageInfo
+
+func init() {
+	proto.RegisterEnum("issuer.PublishStateResponse_Status", PublishStateResponse_Status_name, PublishStateResponse_Status_value)
+	proto.RegisterType((*IssueClaimRequest)(nil), "issuer.IssueClaimRequest")
+	proto.RegisterType((*IssueClaimResponse)(nil), "issuer.IssueClaimResponse")
+	proto.RegisterType((*RevokeClaimRequest)(nil), "issuer.RevokeClaimRequest")
+	proto.RegisterType((*RevokeClaimResponse)(nil), "issuer.RevokeClaimResponse")
+	proto.RegisterType((*GetCredentialRequest)(nil), "issuer.GetCredentialRequest")
+	proto.RegisterType((*IdenStateData)(nil), "issuer.IdenStateData")
+	proto.RegisterType((*CredentialExistence)(nil), "issuer.CredentialExistence")
+	proto.RegisterType((*GetCredentialResponse)(nil), "issuer.GetCredentialResponse")
+	proto.RegisterType((*PublishStateRequest)(nil), "issuer.PublishStateRequest")
+	proto.RegisterType((*PublishStateResponse)(nil), "issuer.PublishStateResponse")
+	proto.RegisterType((*GetPublicDataRequest)(nil), "issuer.GetPublicDataRequest")
+	proto.RegisterType((*PublicDataChunk)(nil), "issuer.PublicDataChunk")
+	proto.RegisterType((*Recovery)(nil), "issuer.Recovery")
+	proto.RegisterType((*GetRecoveryRequest)(nil), "issuer.GetRecoveryRequest")
+	proto.RegisterType((*GetRecoveryResponse)(nil), "issuer.GetRecoveryResponse")
+	proto.RegisterType((*InitRecoveryRequest)(nil), "issuer.InitRecoveryRequest")
+	proto.RegisterType((*InitRecoveryResponse)(nil), "issuer.InitRecoveryResponse")
+	proto.RegisterType((*CancelRecoveryRequest)(nil), "issuer.CancelRecoveryRequest")
+	proto.RegisterType((*CancelRecoveryResponse)(nil), "issuer.CancelRecoveryResponse")
+	proto.RegisterType((*CompleteRecoveryRequest)(nil), "issuer.CompleteRecoveryRequest")
+	proto.RegisterType((*CompleteRecoveryResponse)(nil), "issuer.CompleteRecoveryResponse")
+}
+
+func init() { proto.RegisterFile("issuer.proto", fileDescriptor_8e8d83c9b614651f) }
+
+var fileDescriptor_8e8d83c9b614651f = []byte{
+	// 928 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0x9c, 0x56, 0x5b, 0x73, 0xdb, 0x44,
+	0x14, 0x8e, 0xdc, 0x46, 0xb5, 0x4f, 0xe2, 0x0b, 0x6b, 0x9b, 0x38, 0xb2, 0x5b, 0x32, 0x62, 0x86,
+	0x49, 0x99, 0x26, 0x66, 0xdc, 0xe9, 0x13, 0xf0, 0x90, 0xd8, 0x9e, 0xc4, 0xb4, 0x98, 0xa0, 0xb8,
+	0x03, 0xe5, 0x45, 0x23, 0xc9, 0x4b, 0xac, 0x89, 0xbd, 0x2b, 0xa4, 0x55, 0x1a, 0xde, 0xf8, 0x73,
+	0xbc, 0xf3, 0x8f, 0x60, 0x76, 0x57, 0xf7, 0xc8, 0x81, 0xe1, 0x4d, 0x7b, 0xce, 0xb7, 0xdf, 0xb9,
+	0xec, 0xb7, 0x67, 0x05, 0xfb, 0x6e, 0x10, 0x84, 0xd8, 0x3f, 0xf5, 0x7c, 0xca, 0x28, 0x52, 0xe5,
+	0x4a, 0x7f, 0x09, 0x9f, 0xcc, 0xf8, 0xd7, 0x78, 0x6d, 0xb9, 0x1b, 0x03, 0xff, 0x16, 0xe2, 0x80,
+	0xa1, 0x0e, 0xec, 0x3a, 0x7c, 0xdd, 0x53, 0x8e, 0x94, 0xe3, 0x7d, 0x43, 0x2e, 0xf4, 0x0e, 0xa0,
+	0x2c, 0x34, 0xf0, 0x28, 0x09, 0xb0, 0xfe, 0x25, 0x20, 0x03, 0xdf, 0xd1, 0xdb, 0xff, 0xc2, 0xd0,
+	0x85, 0x76, 0x0e, 0x1b, 0x51, 0xbc, 0x82, 0xce, 0x05, 0x66, 0x63, 0x1f, 0x2f, 0x31, 0x61, 0xae,
+	0xb5, 0x7e, 0x9c, 0xc4, 0x86, 0xfa, 0x6c, 0x89, 0xc9, 0x35, 0xb3, 0x18, 0x9e, 0x58, 0xcc, 0x42,
+	0x07, 0xf0, 0xcc, 0x5e, 0x53, 0xe7, 0xd6, 0x24, 0x02, 0xf8, 0xd4, 0x50, 0xc5, 0x72, 0x8e, 0x0e,
+	0xa1, 0x2a, 0x1d, 0x2c, 0xe8, 0x55, 0x8e, 0x94, 0xe3, 0x27, 0x86, 0x04, 0x2e, 0x02, 0xf4, 0x1c,
+	0xc0, 0x5d, 0x62, 0x62, 0x06, 0x9c, 0xa5, 0xf7, 0x44, 0xf0, 0xd7, 0xdc, 0x98, 0x56, 0xff, 0x5b,
+	0x81, 0x76, 0x9a, 0xcf, 0xf4, 0xde, 0x0d, 0x18, 0x26, 0x0e, 0x46, 0x0d, 0xa8, 0xb8, 0xcb, 0x28,
+	0x9d, 0x8a, 0xbb, 0x44, 0xdf, 0x42, 0x33, 0xa5, 0x31, 0x97, 0x16, 0xb3, 0x44, 0xa0, 0xbd, 0x51,
+	0xf7, 0x34, 0xea, 0x76, 0x2e, 0x55, 0xa3, 0xee, 0xe6, 0x32, 0xef, 0x43, 0x6d, 0xc3, 0x3c, 0x53,
+	0x16, 0x29, 0x93, 0xa8, 0x6e, 0x98, 0x27, 0xba, 0x93, 0x56, 0xff, 0x34, 0x53, 0x3d, 0x7a, 0x09,
+	0x2d, 0x1f, 0xdf, 0x51, 0xc7, 0x62, 0x2e, 0x25, 0x81, 0xe9, 0x53, 0xca, 0x7a, 0xbb, 0x02, 0xd0,
+	0xcc, 0xd8, 0x0d, 0x4a, 0x19, 0xaf, 0x91, 0xbb, 0x23, 0x90, 0x2a, 0x6b, 0x14, 0x16, 0xe1, 0x1e,
+	0xf0, 0x16, 0x98, 0x5e, 0x68, 0x9b, 0xa1, 0xbf, 0xee, 0x3d, 0x3b, 0x52, 0x8e, 0x6b, 0x46, 0xd5,
+	0x5d, 0x5e, 0x85, 0xf6, 0x7b, 0x7f, 0xad, 0x2f, 0xa0, 0x5b, 0x38, 0x13, 0x79, 0x58, 0xe8, 0x6b,
+	0x00, 0x27, 0xb1, 0x8a, 0x56, 0xec, 0x8d, 0xfa, 0x71, 0xb5, 0x25, 0x3d, 0x33, 0x32, 0x70, 0x2e,
+	0x80, 0xab, 0xd0, 0x5e, 0xbb, 0xc1, 0x4a, 0x34, 0x21, 0x3a, 0x68, 0xfd, 0x2f, 0x05, 0x3a, 0x79,
+	0x7b, 0x12, 0x4c, 0xe5, 0xad, 0x0d, 0x03, 0x11, 0xa8, 0x31, 0xfa, 0x3c, 0x0e, 0x54, 0x86, 0x3e,
+	0xbd, 0x16, 0x50, 0x23, 0xda, 0x52, 0x38, 0xe3, 0x4a, 0xe1, 0x8c, 0xd1, 0x0b, 0xd8, 0xc3, 0x6c,
+	0x65, 0xb2, 0x7b, 0x73, 0x65, 0x05, 0xab, 0x58, 0x03, 0x98, 0xad, 0x16, 0xf7, 0x97, 0x56, 0xb0,
+	0xd2, 0xbf, 0x01, 0x55, 0x12, 0xa2, 0x3a, 0xd4, 0xae, 0xdf, 0x9f, 0x7f, 0x3f, 0x5b, 0x2c, 0xa6,
+	0x93, 0xd6, 0x0e, 0x6a, 0x00, 0xcc, 0x7f, 0x30, 0xc7, 0x97, 0x67, 0xf3, 0x8b, 0xe9, 0x75, 0x4b,
+	0x41, 0x6d, 0x68, 0x9e, 0xbd, 0x33, 0xa6, 0x67, 0x93, 0x0f, 0xe6, 0xd5, 0x74, 0x3e, 0x99, 0xcd,
+	0x2f, 0x5a, 0x15, 0xfd, 0x8d, 0xd0, 0xb4, 0x48, 0xd3, 0x11, 0x47, 0x1f, 0x69, 0x3a, 0x9f, 0x94,
+	0x52, 0x14, 0xde, 0x1f, 0x15, 0x68, 0xa6, 0x9b, 0xc6, 0xab, 0x90, 0xdc, 0xfe, 0xcb, 0x16, 0x74,
+	0x0c, 0x2d, 0x21, 0x8d, 0xc0, 0x64, 0x3e, 0xc6, 0xf2, 0xb0, 0x65, 0xb1, 0x0d, 0x69, 0x5f, 0xf8,
+	0x18, 0x8b, 0x13, 0x1f, 0x41, 0x37, 0xab, 0x9d, 0x14, 0x2e, 0x6b, 0x6f, 0x67, 0x9c, 0xc9, 0x9e,
+	0x2f, 0xa0, 0x29, 0x45, 0x94, 0xa2, 0xa5, 0x1e, 0xeb, 0xc2, 0x9c, 0xe0, 0x12, 0xb1, 0x71, 0x5c,
+	0x6f, 0x37, 0x23, 0x36, 0x0e, 0x29, 0xca, 0x56, 0x80, 0xd4, 0x07, 0xb2, 0xe5, 0x50, 0xfd, 0x67,
+	0xa8, 0x1a, 0xd8, 0xa1, 0x77, 0xd8, 0xff, 0x9d, 0x5f, 0x6d, 0x82, 0x3f, 0x9a, 0xb7, 0xd4, 0x8b,
+	0xea, 0x56, 0x09, 0xfe, 0xf8, 0x96, 0x7a, 0x3c, 0x1c, 0xa1, 0xcc, 0xb4, 0xf1, 0xaf, 0xd4, 0xc7,
+	0xd1, 0xe5, 0xae, 0x11, 0xca, 0xce, 0x85, 0x81, 0xdf, 0x1d, 0x42, 0x89, 0x23, 0x6f, 0x76, 0xdd,
+	0x90, 0x0b, 0x3e, 0xc0, 0x2e, 0x30, 0x8b, 0xc9, 0x63, 0xf1, 0x7d, 0x80, 0x76, 0xce, 0x1a, 0x49,
+	0xef, 0x15, 0x54, 0xfd, 0xc8, 0x16, 0xa9, 0xbc, 0x15, 0x8b, 0x2f, 0xc1, 0x26, 0x88, 0x34, 0x60,
+	0x25, 0x1b, 0xf0, 0x1d, 0xb4, 0x67, 0xc4, 0x2d, 0x46, 0xdc, 0x5e, 0xd5, 0x00, 0x6a, 0x81, 0x7b,
+	0x43, 0x2c, 0x16, 0xfa, 0x89, 0x60, 0x13, 0x83, 0x3e, 0x81, 0x4e, 0x9e, 0xed, 0xff, 0x64, 0xaa,
+	0xbf, 0x81, 0xee, 0xd8, 0x22, 0x0e, 0x5e, 0x17, 0xb3, 0xca, 0x05, 0x57, 0x8a, 0xc1, 0x7b, 0xf0,
+	0x69, 0x71, 0x5b, 0x34, 0xbd, 0x0f, 0xe1, 0x60, 0x4c, 0x37, 0xde, 0x1a, 0x33, 0x5c, 0x6c, 0xad,
+	0x06, 0xbd, 0x87, 0x2e, 0xb9, 0x6d, 0xf4, 0xe7, 0x2e, 0xa8, 0xe2, 0x39, 0xf1, 0xd1, 0x14, 0x20,
+	0x7d, 0x58, 0xd0, 0x61, 0x32, 0x3a, 0x8b, 0xef, 0x92, 0xa6, 0x95, 0xb9, 0xa2, 0x34, 0x76, 0xd0,
+	0x25, 0xec, 0x65, 0x5e, 0x17, 0xa4, 0xa5, 0x4d, 0x28, 0x3e, 0x4f, 0x5a, 0xbf, 0xd4, 0x97, 0x30,
+	0xcd, 0xa1, 0x9e, 0x1b, 0x7e, 0x68, 0x10, 0xe3, 0xcb, 0xde, 0x29, 0xed, 0xf9, 0x16, 0x6f, 0xc2,
+	0xf7, 0x16, 0xf6, 0xb3, 0x03, 0x0b, 0xf5, 0xcb, 0xc7, 0x98, 0x64, 0x1b, 0x3c, 0x36, 0xe3, 0xf4,
+	0x1d, 0xf4, 0x9d, 0x48, 0x2e, 0x1d, 0x12, 0xb9, 0xe4, 0x1e, 0x0c, 0x1c, 0xed, 0x20, 0x47, 0x97,
+	0x8e, 0x15, 0x7d, 0xe7, 0x2b, 0x85, 0xb7, 0x2c, 0xa3, 0xfd, 0xb4, 0x65, 0x0f, 0xaf, 0x89, 0xd6,
+	0x2f, 0xf5, 0x65, 0x4b, 0xcc, 0x8a, 0x33, 0x2d, 0xb1, 0xe4, 0x02, 0x68, 0x83, 0x72, 0x67, 0x42,
+	0xf6, 0x23, 0x34, 0xf2, 0x62, 0x43, 0x49, 0x8b, 0x4b, 0xb5, 0xab, 0xbd, 0xd8, 0xe6, 0x4e, 0x28,
+	0x7f, 0x82, 0x56, 0x51, 0x8a, 0xe8, 0xb3, 0x64, 0x57, 0xb9, 0x7e, 0xb5, 0xa3, 0xed, 0x80, 0x98,
+	0xf8, 0x7c, 0xf8, 0xcb, 0xc9, 0x8d, 0xcb, 0x56, 0xa1, 0x7d, 0xea, 0xd0, 0xcd, 0x90, 0x8f, 0xe5,
+	0xd7, 0xc3, 0x1b, 0x7a, 0x22, 0x3e, 0x4e, 0x1c, 0xea, 0xe3, 0xa1, 0xf8, 0xe1, 0x1a, 0x4a, 0x26,
+	0xcf, 0xb6, 0x55, 0xb1, 0x7e, 0xfd, 0xcf, 0x00, 0x13, 0x1e, 0xfe, 0x5e, 0x90, 0x09, 0x00, 0x00,
+}
+
+// Reference imports to suppress errors if they are not otherwise used.
+var _ context.Context
+var _ grpc.ClientConn
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+const _ = grpc.SupportPackageIsVersion4
+
+// IssuerClient is the client API for Issuer service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://godoc.org/google.golang.org/grpc#ClientConn.NewStream.
+type IssuerClient interface {
+	// IssueClaim adds a claim to the claims tree of the Issuer.
+	IssueClaim(ctx context.Context, in *IssueClaimRequest, opts ...grpc.CallOption) (*IssueClaimResponse, error)
+	// RevokeClaim revokes an issued claim.
+	RevokeClaim(ctx context.Context, in *RevokeClaimRequest, opts ...grpc.CallOption) (*RevokeClaimResponse, error)
+	// GetCredential returns the existence credential of an issued claim
+	// under the last identity state found on chain.
+	GetCredential(ctx context.Context, in *GetCredentialRequest, opts ...grpc.CallOption) (*GetCredentialResponse, error)
+	// PublishState publishes the current identity state on chain.
+	PublishState(ctx context.Context, in *PublishStateRequest, opts ...grpc.CallOption) (*PublishStateResponse, error)
+	// GetPublicData streams the off chain public data of the identity.  The
+	// first message contains the roots, and the following ones contain
+	// chunks of the roots tree and the revocations tree dumps.
+	GetPublicData(ctx context.Context, in *GetPublicDataRequest, opts ...grpc.CallOption) (Issuer_GetPublicDataClient, error)
+	// GetRecovery returns the pending recovery of the operational key, and
+	// the nonce that the recoverer must sign in the next recovery.
+	GetRecovery(ctx context.Context, in *GetRecoveryRequest, opts ...grpc.CallOption) (*GetRecoveryResponse, error)
+	// InitRecovery initiates the recovery of the operational key, signed by
+	// the recoverer.
+	InitRecovery(ctx context.Context, in *InitRecoveryRequest, opts ...grpc.CallOption) (*InitRecoveryResponse, error)
+	// CancelRecovery cancels the pending recovery of the operational key,
+	// signed by the current operational key.
+	CancelRecovery(ctx context.Context, in *CancelRecoveryRequest, opts ...grpc.CallOption) (*CancelRecoveryResponse, error)
+	// CompleteRecovery completes the pending recovery of the operational key
+	// after its timelock.
+	CompleteRecovery(ctx context.Context, in *CompleteRecoveryRequest, opts ...grpc.CallOption) (*CompleteRecoveryResponse, error)
+}
+
+type issuerClient struct {
+	cc *grpc.ClientConn
+}
+
+func NewIssuerClient(cc *grpc.ClientConn) IssuerClient {
+	return &issuerClient{cc}
+}
+
+func (c *issuerClient) IssueClaim(ctx context.Context, in *IssueClaimRequest, opts ...grpc.CallOption) (*IssueClaimResponse, error) {
+	out := new(IssueClaimResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/IssueClaim", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) RevokeClaim(ctx context.Context, in *RevokeClaimRequest, opts ...grpc.CallOption) (*RevokeClaimResponse, error) {
+	out := new(RevokeClaimResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/RevokeClaim", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) GetCredential(ctx context.Context, in *GetCredentialRequest, opts ...grpc.CallOption) (*GetCredentialResponse, error) {
+	out := new(GetCredentialResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/GetCredential", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) PublishState(ctx context.Context, in *PublishStateRequest, opts ...grpc.CallOption) (*PublishStateResponse, error) {
+	out := new(PublishStateResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/PublishState", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) GetPublicData(ctx context.Context, in *GetPublicDataRequest, opts ...grpc.CallOption) (Issuer_GetPublicDataClient, error) {
+	stream, err := c.cc.NewStream(ctx, &_Issuer_serviceDesc.Streams[0], "/issuer.Issuer/GetPublicData", opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &issuerGetPublicDataClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Issuer_GetPublicDataClient interface {
+	Recv() (*PublicDataChunk, error)
+	grpc.ClientStream
+}
+
+type issuerGetPublicDataClient struct {
+	grpc.ClientStream
+}
+
+func (x *issuerGetPublicDataClient) Recv() (*PublicDataChunk, error) {
+	m := new(PublicDataChunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *issuerClient) GetRecovery(ctx context.Context, in *GetRecoveryRequest, opts ...grpc.CallOption) (*GetRecoveryResponse, error) {
+	out := new(GetRecoveryResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/GetRecovery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) InitRecovery(ctx context.Context, in *InitRecoveryRequest, opts ...grpc.CallOption) (*InitRecoveryResponse, error) {
+	out := new(InitRecoveryResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/InitRecovery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) CancelRecovery(ctx context.Context, in *CancelRecoveryRequest, opts ...grpc.CallOption) (*CancelRecoveryResponse, error) {
+	out := new(CancelRecoveryResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/CancelRecovery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *issuerClient) CompleteRecovery(ctx context.Context, in *CompleteRecoveryRequest, opts ...grpc.CallOption) (*CompleteRecoveryResponse, error) {
+	out := new(CompleteRecoveryResponse)
+	err := c.cc.Invoke(ctx, "/issuer.Issuer/CompleteRecovery", in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// IssuerServer is the server API for Issuer service.
+type IssuerServer interface {
+	// IssueClaim adds a claim to the claims tree of the Issuer.
+	IssueClaim(context.Context, *IssueClaimRequest) (*IssueClaimResponse, error)
+	// RevokeClaim revokes an issued claim.
+	RevokeClaim(context.Context, *RevokeClaimRequest) (*RevokeClaimResponse, error)
+	// GetCredential returns the existence credential of an issued claim
+	// under the last identity state found on chain.
+	GetCredential(context.Context, *GetCredentialRequest) (*GetCredentialResponse, error)
+	// PublishState publishes the current identity state on chain.
+	PublishState(context.Context, *PublishStateRequest) (*PublishStateResponse, error)
+	// GetPublicData streams the off chain public data of the identity.  The
+	// first message contains the roots, and the following ones contain
+	// chunks of the roots tree and the revocations tree dumps.
+	GetPublicData(*GetPublicDataRequest, Issuer_GetPublicDataServer) error
+	// GetRecovery returns the pending recovery of the operational key, and
+	// the nonce that the recoverer must sign in the next recovery.
+	GetRecovery(context.Context, *GetRecoveryRequest) (*GetRecoveryResponse, error)
+	// InitRecovery initiates the recovery of the operational key, signed by
+	// the recoverer.
+	InitRecovery(context.Context, *InitRecoveryRequest) (*InitRecoveryResponse, error)
+	// CancelRecovery cancels the pending recovery of the operational key,
+	// signed by the current operational key.
+	CancelRecovery(context.Context, *CancelRecoveryRequest) (*CancelRecoveryResponse, error)
+	// CompleteRecovery completes the pending recovery of the operational key
+	// after its timelock.
+	CompleteRecovery(context.Context, *CompleteRecoveryRequest) (*CompleteRecoveryResponse, error)
+}
+
+// UnimplementedIssuerServer can be embedded to have forward compatible implementations.
+type UnimplementedIssuerServer struct {
+}
+
+func (*UnimplementedIssuerServer) IssueClaim(ctx context.Context, req *IssueClaimRequest) (*IssueClaimResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method IssueClaim not implemented")
+}
+func (*UnimplementedIssuerServer) RevokeClaim(ctx context.Context, req *RevokeClaimRequest) (*RevokeClaimResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RevokeClaim not implemented")
+}
+func (*UnimplementedIssuerServer) GetCredential(ctx context.Context, req *GetCredentialRequest) (*GetCredentialResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetCredential not implemented")
+}
+func (*UnimplementedIssuerServer) PublishState(ctx context.Context, req *PublishStateRequest) (*PublishStateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method PublishState not implemented")
+}
+func (*UnimplementedIssuerServer) GetPublicData(req *GetPublicDataRequest, srv Issuer_GetPublicDataServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetPublicData not implemented")
+}
+func (*UnimplementedIssuerServer) GetRecovery(ctx context.Context, req *GetRecoveryRequest) (*GetRecoveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetRecovery not implemented")
+}
+func (*UnimplementedIssuerServer) InitRecovery(ctx context.Context, req *InitRecoveryRequest) (*InitRecoveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method InitRecovery not implemented")
+}
+func (*UnimplementedIssuerServer) CancelRecovery(ctx context.Context, req *CancelRecoveryRequest) (*CancelRecoveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CancelRecovery not implemented")
+}
+func (*UnimplementedIssuerServer) CompleteRecovery(ctx context.Context, req *CompleteRecoveryRequest) (*CompleteRecoveryResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CompleteRecovery not implemented")
+}
+
+func RegisterIssuerServer(s *grpc.Server, srv IssuerServer) {
+	s.RegisterService(&_Issuer_serviceDesc, srv)
+}
+
+func _Issuer_IssueClaim_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IssueClaimRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).IssueClaim(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/IssueClaim",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).IssueClaim(ctx, req.(*IssueClaimRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_RevokeClaim_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RevokeClaimRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).RevokeClaim(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/RevokeClaim",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).RevokeClaim(ctx, req.(*RevokeClaimRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_GetCredential_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetCredentialRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).GetCredential(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/GetCredential",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).GetCredential(ctx, req.(*GetCredentialRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_PublishState_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(PublishStateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).PublishState(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/PublishState",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).PublishState(ctx, req.(*PublishStateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_GetPublicData_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetPublicDataRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(IssuerServer).GetPublicData(m, &issuerGetPublicDataServer{stream})
+}
+
+type Issuer_GetPublicDataServer interface {
+	Send(*PublicDataChunk) error
+	grpc.ServerStream
+}
+
+type issuerGetPublicDataServer struct {
+	grpc.ServerStream
+}
+
+func (x *issuerGetPublicDataServer) Send(m *PublicDataChunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Issuer_GetRecovery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetRecoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).GetRecovery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/GetRecovery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).GetRecovery(ctx, req.(*GetRecoveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_InitRecovery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(InitRecoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).InitRecovery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/InitRecovery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).InitRecovery(ctx, req.(*InitRecoveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_CancelRecovery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRecoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).CancelRecovery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/CancelRecovery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).CancelRecovery(ctx, req.(*CancelRecoveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Issuer_CompleteRecovery_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CompleteRecoveryRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(IssuerServer).CompleteRecovery(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: "/issuer.Issuer/CompleteRecovery",
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(IssuerServer).CompleteRecovery(ctx, req.(*CompleteRecoveryRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+var _Issuer_serviceDesc = grpc.ServiceDesc{
+	ServiceName: "issuer.Issuer",
+	HandlerType: (*IssuerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "IssueClaim",
+			Handler:    _Issuer_IssueClaim_Handler,
+		},
+		{
+			MethodName: "RevokeClaim",
+			Handler:    _Issuer_RevokeClaim_Handler,
+		},
+		{
+			MethodName: "GetCredential",
+			Handler:    _Issuer_GetCredential_Handler,
+		},
+		{
+			MethodName: "PublishState",
+			Handler:    _Issuer_PublishState_Handler,
+		},
+		{
+			MethodName: "GetRecovery",
+			Handler:    _Issuer_GetRecovery_Handler,
+		},
+		{
+			MethodName: "InitRecovery",
+			Handler:    _Issuer_InitRecovery_Handler,
+		},
+		{
+			MethodName: "CancelRecovery",
+			Handler:    _Issuer_CancelRecovery_Handler,
+		},
+		{
+			MethodName: "CompleteRecovery",
+			Handler:    _Issuer_CompleteRecovery_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "GetPublicData",
+			Handler:       _Issuer_GetPublicData_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "issuer.proto",
+}
@@ -75,6 +75,26 @@ func SetTestValue(key string, value interface{}) {
 	}
 }
 
+// State is the state of the testgen framework: the test vector in use and
+// its data.
+type State struct {
+	generate bool
+	fileName string
+	testData TestData
+}
+
+// SaveState returns the current State, so that a test can use its own test
+// vector and then restore with RestoreState the one shared by the tests of
+// the package.
+func SaveState() State {
+	return State{generate: generate, fileName: fileName, testData: testData}
+}
+
+// RestoreState restores the State returned by SaveState.
+func RestoreState(s State) {
+	generate, fileName, testData = s.generate, s.fileName, s.testData
+}
+
 // StopTest will write the testVectors to the corresponding file if generate is
 // true.
 func StopTest() error {
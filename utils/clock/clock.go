@@ -0,0 +1,174 @@
+// Package clock abstracts the wall clock, so that the time dependent logic
+// can be tested deterministically with a Fake clock.
+package clock
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// Clock gives the current time and timers.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+	// After returns a channel that receives the current time once d has
+	// elapsed.
+	After(d time.Duration) <-chan time.Time
+	// NewTicker returns a Ticker that ticks every d.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks at intervals.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+	// Stop turns off the Ticker.
+	Stop()
+}
+
+// Real is the Clock of the system time.
+var Real Clock = realClock{}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+func (realClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+func (realClock) NewTicker(d time.Duration) Ticker { return realTicker{time.NewTicker(d)} }
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// waiter is an After channel or a Ticker of a Fake clock.
+type waiter struct {
+	at time.Time
+	// period is 0 for the After channels.
+	period time.Duration
+	c      chan time.Time
+}
+
+// Fake is a Clock whose time only changes with Set and Advance, which fire
+// the After channels and Tickers that are due.  Like the real ones, the
+// Tickers drop the ticks when the receiver is not ready.
+type Fake struct {
+	mutex   sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*waiter
+}
+
+// NewFake returns a new Fake clock set at now.
+func NewFake(now time.Time) *Fake {
+	f := &Fake{now: now}
+	f.cond = sync.NewCond(&f.mutex)
+	return f
+}
+
+// Now returns the time of the Fake clock.
+func (f *Fake) Now() time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	return f.now
+}
+
+// After returns a channel that receives the time once the Fake clock has
+// advanced d.
+func (f *Fake) After(d time.Duration) <-chan time.Time {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	w := &waiter{at: f.now.Add(d), c: make(chan time.Time, 1)}
+	if d <= 0 {
+		w.c <- f.now
+		return w.c
+	}
+	f.add(w)
+	return w.c
+}
+
+// NewTicker returns a Ticker that ticks every time the Fake clock advances d.
+func (f *Fake) NewTicker(d time.Duration) Ticker {
+	if d <= 0 {
+		panic("non-positive interval for NewTicker")
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	w := &waiter{at: f.now.Add(d), period: d, c: make(chan time.Time, 1)}
+	f.add(w)
+	return &fakeTicker{f: f, w: w}
+}
+
+func (f *Fake) add(w *waiter) {
+	f.waiters = append(f.waiters, w)
+	f.cond.Broadcast()
+}
+
+func (f *Fake) remove(w *waiter) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for i, fw := range f.waiters {
+		if fw == w {
+			f.waiters = append(f.waiters[:i], f.waiters[i+1:]...)
+			return
+		}
+	}
+}
+
+// Advance moves the Fake clock forward d.
+func (f *Fake) Advance(d time.Duration) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.set(f.now.Add(d))
+}
+
+// Set moves the Fake clock to t.
+func (f *Fake) Set(t time.Time) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.set(t)
+}
+
+// set fires the waiters that are due at t, in order.
+func (f *Fake) set(t time.Time) {
+	for {
+		sort.SliceStable(f.waiters, func(i, j int) bool { return f.waiters[i].at.Before(f.waiters[j].at) })
+		if len(f.waiters) == 0 || f.waiters[0].at.After(t) {
+			break
+		}
+		w := f.waiters[0]
+		f.now = w.at
+		select {
+		case w.c <- w.at:
+		default:
+		}
+		if w.period == 0 {
+			f.waiters = f.waiters[1:]
+		} else {
+			w.at = w.at.Add(w.period)
+		}
+	}
+	f.now = t
+}
+
+// BlockUntil waits until there are n pending After channels and Tickers, so
+// that a test can advance the clock once a goroutine is waiting on it.
+func (f *Fake) BlockUntil(n int) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	for len(f.waiters) < n {
+		f.cond.Wait()
+	}
+}
+
+type fakeTicker struct {
+	f *Fake
+	w *waiter
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.w.c }
+
+func (t *fakeTicker) Stop() { t.f.remove(t.w) }
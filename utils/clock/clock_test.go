@@ -0,0 +1,64 @@
+package clock
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func received(c <-chan time.Time) (time.Time, bool) {
+	select {
+	case t := <-c:
+		return t, true
+	default:
+		return time.Time{}, false
+	}
+}
+
+func TestFake(t *testing.T) {
+	start := time.Unix(1600000000, 0)
+	f := NewFake(start)
+	assert.Equal(t, start, f.Now())
+
+	after := f.After(time.Minute)
+	ticker := f.NewTicker(20 * time.Second)
+	f.BlockUntil(2)
+
+	f.Advance(30 * time.Second)
+	assert.Equal(t, start.Add(30*time.Second), f.Now())
+	_, ok := received(after)
+	assert.False(t, ok)
+	tick, ok := received(ticker.C())
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(20*time.Second), tick)
+
+	// The ticks are dropped while the receiver is not ready.
+	f.Advance(30 * time.Second)
+	tick, ok = received(ticker.C())
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(40*time.Second), tick)
+	_, ok = received(ticker.C())
+	assert.False(t, ok)
+	tick, ok = received(after)
+	assert.True(t, ok)
+	assert.Equal(t, start.Add(time.Minute), tick)
+
+	ticker.Stop()
+	f.Set(start.Add(time.Hour))
+	_, ok = received(ticker.C())
+	assert.False(t, ok)
+	assert.Equal(t, start.Add(time.Hour), f.Now())
+
+	_, ok = received(f.After(0))
+	assert.True(t, ok)
+}
+
+func TestReal(t *testing.T) {
+	before := time.Now()
+	assert.False(t, Real.Now().Before(before))
+	<-Real.After(time.Millisecond)
+	ticker := Real.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	<-ticker.C()
+}
@@ -0,0 +1,162 @@
+// Package noncestore implements a persistent store of single use nonces with
+// an expiration, shared by the components that issue challenges to be
+// signed.
+package noncestore
+
+import (
+	"encoding/binary"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+)
+
+var (
+	// ErrUnknown is returned when a nonce was not issued, or has been
+	// pruned.
+	ErrUnknown = errors.New("nonce unknown")
+	// ErrUsed is returned when a nonce has already been used.
+	ErrUsed = errors.New("nonce already used")
+	// ErrExpired is returned when a nonce has expired.
+	ErrExpired = errors.New("nonce expired")
+	// ErrFull is returned when a nonce is issued while the limit of
+	// nonces in the store is reached.
+	ErrFull = errors.New("too many nonces")
+)
+
+// entryLen is the length of the value of a nonce: the expiration as a unix
+// timestamp in big endian followed by the used flag.
+const entryLen = 9
+
+// Store keeps in a storage the issued nonces with their expiration, so that
+// each one is used once and before it expires.  The used nonces are kept until
+// they expire to tell them apart from the unknown ones, and the expired nonces
+// are deleted by Prune.
+type Store struct {
+	storage db.Storage
+	// mutex serializes the Uses, so that a nonce can't be used twice
+	// concurrently, and protects the count of nonces.
+	mutex sync.Mutex
+	// max is the limit of nonces in storage, and count their number,
+	// counted at the first Issue.
+	max     int
+	count   int
+	counted bool
+}
+
+// New creates a new Store that keeps the nonces in storage.
+func New(storage db.Storage) *Store {
+	return &Store{storage: storage}
+}
+
+// SetMax limits the nonces in the store to max, after which Issue returns
+// ErrFull until some are pruned.  0 means no limit.
+func (s *Store) SetMax(max int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.max = max
+}
+
+func (s *Store) put(nonce []byte, expiration time.Time, used bool) error {
+	var v [entryLen]byte
+	binary.BigEndian.PutUint64(v[:8], uint64(expiration.Unix()))
+	if used {
+		v[8] = 1
+	}
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return err
+	}
+	defer tx.Close()
+	tx.Put(nonce, v[:])
+	return tx.Commit()
+}
+
+// Issue records the nonce, which expires at expiration.
+func (s *Store) Issue(nonce []byte, expiration time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if !s.counted {
+		if err := s.storage.Iterate(func(k, v []byte) (bool, error) {
+			s.count++
+			return true, nil
+		}); err != nil {
+			return err
+		}
+		s.counted = true
+	}
+	if s.max > 0 && s.count >= s.max {
+		return ErrFull
+	}
+	if err := s.put(nonce, expiration, false); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// parseEntry returns the expiration and the used flag of the value of a
+// nonce.
+func parseEntry(v []byte) (time.Time, bool) {
+	if len(v) != entryLen {
+		return time.Time{}, true
+	}
+	return time.Unix(int64(binary.BigEndian.Uint64(v[:8])), 0), v[8] == 1
+}
+
+// Use marks the nonce as used and returns its expiration.  It returns
+// ErrUnknown if the nonce was not issued, ErrUsed if it was already used and
+// ErrExpired if it has expired at now.
+func (s *Store) Use(nonce []byte, now time.Time) (time.Time, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	v, err := s.storage.Get(nonce)
+	if err == db.ErrNotFound {
+		return time.Time{}, ErrUnknown
+	} else if err != nil {
+		return time.Time{}, err
+	}
+	expiration, used := parseEntry(v)
+	if used {
+		return expiration, ErrUsed
+	}
+	if !now.Before(expiration) {
+		return expiration, ErrExpired
+	}
+	return expiration, s.put(nonce, expiration, true)
+}
+
+// Prune deletes the nonces expired at now, which can't be used anymore, and
+// returns how many were deleted.
+func (s *Store) Prune(now time.Time) (int, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var expired [][]byte
+	if err := s.storage.Iterate(func(nonce, v []byte) (bool, error) {
+		if expiration, _ := parseEntry(v); !now.Before(expiration) {
+			expired = append(expired, append([]byte{}, nonce...))
+		}
+		return true, nil
+	}); err != nil {
+		return 0, err
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	tx, err := s.storage.NewTx()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Close()
+	for _, nonce := range expired {
+		tx.Delete(nonce)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	if s.counted {
+		s.count -= len(expired)
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,52 @@
+package noncestore
+
+import (
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/db"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore(t *testing.T) {
+	now := time.Unix(1000, 0)
+	store := New(db.NewMemoryStorage())
+	require.Nil(t, store.Issue([]byte("a"), now.Add(time.Minute)))
+	require.Nil(t, store.Issue([]byte("b"), now.Add(time.Hour)))
+
+	_, err := store.Use([]byte("c"), now)
+	assert.Equal(t, ErrUnknown, err)
+	expiration, err := store.Use([]byte("a"), now)
+	require.Nil(t, err)
+	assert.Equal(t, now.Add(time.Minute), expiration)
+	_, err = store.Use([]byte("a"), now)
+	assert.Equal(t, ErrUsed, err)
+	_, err = store.Use([]byte("b"), now.Add(time.Hour))
+	assert.Equal(t, ErrExpired, err)
+
+	n, err := store.Prune(now.Add(time.Minute))
+	require.Nil(t, err)
+	assert.Equal(t, 1, n)
+	_, err = store.Use([]byte("a"), now)
+	assert.Equal(t, ErrUnknown, err)
+	_, err = store.Use([]byte("b"), now)
+	assert.Nil(t, err)
+}
+
+func TestStoreMax(t *testing.T) {
+	now := time.Unix(1000, 0)
+	storage := db.NewMemoryStorage()
+	require.Nil(t, New(storage).Issue([]byte("a"), now.Add(time.Minute)))
+
+	// The nonces in storage are counted.
+	store := New(storage)
+	store.SetMax(2)
+	require.Nil(t, store.Issue([]byte("b"), now.Add(time.Hour)))
+	assert.Equal(t, ErrFull, store.Issue([]byte("c"), now.Add(time.Hour)))
+
+	_, err := store.Prune(now.Add(time.Minute))
+	require.Nil(t, err)
+	require.Nil(t, store.Issue([]byte("c"), now.Add(time.Hour)))
+	assert.Equal(t, ErrFull, store.Issue([]byte("d"), now.Add(time.Hour)))
+}
@@ -0,0 +1,136 @@
+// Package retry implements a retry policy with exponential backoff and
+// jitter, shared by the components that call the network.
+package retry
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// PolicyDefault is a default retry Policy.
+var PolicyDefault = Policy{
+	MaxAttempts: 5,
+	BackoffMin:  1 * time.Second,
+	BackoffMax:  1 * time.Minute,
+	Jitter:      0.2,
+}
+
+// Policy tells how a failed call is retried.  The zero Policy makes a single
+// attempt.
+type Policy struct {
+	// MaxAttempts is the maximum number of attempts, including the first
+	// one.  Values lower than 1 mean a single attempt.
+	MaxAttempts int
+	// BackoffMin is the waiting time after the first failed attempt.  It's
+	// doubled after each failed attempt up to BackoffMax.
+	BackoffMin time.Duration
+	BackoffMax time.Duration
+	// Jitter is the fraction of each waiting time that is randomized, from
+	// 0 to 1, so that the retries of many clients don't happen at the
+	// same time: a waiting time d becomes a random one between
+	// d*(1-Jitter) and d.
+	Jitter float64
+	// Retryable tells whether an error must be retried.  If nil, all the
+	// errors are retried except the ones marked with Permanent.
+	Retryable func(err error) bool `json:"-"`
+}
+
+// permanentError is an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as an error that must not be retried.  Do returns the
+// unmarked err.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// IsPermanent returns true if err has been marked with Permanent.
+func IsPermanent(err error) bool {
+	var permanent *permanentError
+	return errors.As(err, &permanent)
+}
+
+// RetryableStatus returns true for the HTTP status codes of the failed
+// responses that can succeed if the request is retried: 408, 429 and 5xx.
+func RetryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests ||
+		code >= 500
+}
+
+// retryable classifies err with the Policy.
+func (p *Policy) retryable(err error) bool {
+	if IsPermanent(err) {
+		return false
+	}
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return true
+}
+
+// Backoff returns the waiting time after failed failed attempts, without
+// jitter.
+func (p *Policy) Backoff(failed int) time.Duration {
+	backoff := p.BackoffMin
+	for i := 1; i < failed && backoff < p.BackoffMax; i++ {
+		backoff *= 2
+	}
+	if backoff > p.BackoffMax {
+		backoff = p.BackoffMax
+	}
+	return backoff
+}
+
+// jitter randomizes d with the Policy Jitter.
+func (p *Policy) jitter(d time.Duration) time.Duration {
+	if p.Jitter <= 0 || d <= 0 {
+		return d
+	}
+	jitter := p.Jitter
+	if jitter > 1 {
+		jitter = 1
+	}
+	return d - time.Duration(rand.Float64()*jitter*float64(d))
+}
+
+// Do calls fn until it succeeds, it fails with an error that must not be
+// retried, the maximum number of attempts is reached or ctx is done.  It
+// returns nil, the error of the last attempt (unmarked if it was marked with
+// Permanent), or the error of ctx if it's done while waiting to retry.
+func (p *Policy) Do(ctx context.Context, fn func() error) error {
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !p.retryable(err) {
+			var permanent *permanentError
+			if errors.As(err, &permanent) {
+				return permanent.err
+			}
+			return err
+		}
+		if attempt >= p.MaxAttempts {
+			return err
+		}
+		timer := time.NewTimer(p.jitter(p.Backoff(attempt)))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
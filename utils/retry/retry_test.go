@@ -0,0 +1,86 @@
+package retry
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var errTest = fmt.Errorf("unavailable")
+
+func TestBackoff(t *testing.T) {
+	p := Policy{BackoffMin: time.Second, BackoffMax: 5 * time.Second}
+	assert.Equal(t, time.Second, p.Backoff(1))
+	assert.Equal(t, 2*time.Second, p.Backoff(2))
+	assert.Equal(t, 4*time.Second, p.Backoff(3))
+	assert.Equal(t, 5*time.Second, p.Backoff(4))
+	assert.Equal(t, 5*time.Second, p.Backoff(100))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		d := p.jitter(time.Second)
+		assert.True(t, d > 500*time.Millisecond && d <= time.Second)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{MaxAttempts: 3, BackoffMin: time.Millisecond, BackoffMax: time.Millisecond}
+	attempts := 0
+	err := p.Do(context.Background(), func() error {
+		attempts++
+		if attempts < 2 {
+			return errTest
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 2, attempts)
+
+	attempts = 0
+	err = p.Do(context.Background(), func() error { attempts++; return errTest })
+	assert.Equal(t, errTest, err)
+	assert.Equal(t, 3, attempts)
+
+	// Permanent errors are not retried.
+	attempts = 0
+	err = p.Do(context.Background(), func() error { attempts++; return Permanent(errTest) })
+	assert.Equal(t, errTest, err)
+	assert.Equal(t, 1, attempts)
+
+	// Neither the errors classified as not retryable.
+	p.Retryable = func(err error) bool { return err != errTest }
+	attempts = 0
+	err = p.Do(context.Background(), func() error { attempts++; return errTest })
+	assert.Equal(t, errTest, err)
+	assert.Equal(t, 1, attempts)
+
+	// The zero Policy makes a single attempt.
+	attempts = 0
+	err = (&Policy{}).Do(context.Background(), func() error { attempts++; return errTest })
+	assert.Equal(t, errTest, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestDoCanceled(t *testing.T) {
+	p := Policy{MaxAttempts: 3, BackoffMin: time.Hour, BackoffMax: time.Hour}
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	err := p.Do(ctx, func() error {
+		attempts++
+		cancel()
+		return errTest
+	})
+	assert.Equal(t, context.Canceled, err)
+	assert.Equal(t, 1, attempts)
+}
+
+func TestRetryableStatus(t *testing.T) {
+	assert.True(t, RetryableStatus(http.StatusServiceUnavailable))
+	assert.True(t, RetryableStatus(http.StatusTooManyRequests))
+	assert.False(t, RetryableStatus(http.StatusNotFound))
+	assert.False(t, RetryableStatus(http.StatusBadRequest))
+}
@@ -0,0 +1,199 @@
+// Package worker manages the lifecycle of the background workers of a
+// service, like the expiration sweeper and the auto publisher of an Issuer or
+// the webhook delivery of a Notifier, so that embedders start and stop them
+// uniformly and can report their health.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/iden3/go-iden3-core/utils/clock"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrWorkerExists is used when a worker is added with the name of
+	// another one.
+	ErrWorkerExists = errors.New("a worker with the same name already exists")
+	// ErrStarted is used when a started Manager is started again.
+	ErrStarted = errors.New("the workers are already started")
+)
+
+// Worker is a task that runs in the background until ctx is done.  It
+// reports the result of each unit of work with report, which is used for the
+// health of the worker.
+type Worker func(ctx context.Context, report func(err error))
+
+// Every returns a Worker that calls f every interval of clk.
+func Every(clk clock.Clock, interval time.Duration, f func(ctx context.Context) error) Worker {
+	return func(ctx context.Context, report func(err error)) {
+		ticker := clk.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				report(f(ctx))
+			case <-ctx.Done():
+				return
+			}
+		}
+	}
+}
+
+// StartStop returns a Worker of a component with its own Start and Stop
+// methods, like notifier.Notifier: start is called when the Worker runs,
+// reporting its result, and stop when ctx is done.
+func StartStop(start func() error, stop func()) Worker {
+	return func(ctx context.Context, report func(err error)) {
+		err := start()
+		report(err)
+		if err != nil {
+			return
+		}
+		<-ctx.Done()
+		stop()
+	}
+}
+
+// Status is the health of a worker.
+type Status struct {
+	// Running is true while the worker is running.
+	Running bool `json:"running"`
+	// Runs and Failures are the number of reported units of work, and of
+	// the failed ones.
+	Runs     uint64 `json:"runs"`
+	Failures uint64 `json:"failures"`
+	// LastRunTs is the unix time of the last report, and LastError its
+	// error, if it failed.
+	LastRunTs int64  `json:"lastRunTs"`
+	LastError string `json:"lastError,omitempty"`
+}
+
+type worker struct {
+	w      Worker
+	status Status
+}
+
+// Manager runs a set of named workers.  Workers can be added before or after
+// the Manager is started.
+type Manager struct {
+	rw      sync.RWMutex
+	clock   clock.Clock
+	workers map[string]*worker
+	ctx     context.Context
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+// NewManager creates a Manager without workers, that timestamps the reports
+// with clk.
+func NewManager(clk clock.Clock) *Manager {
+	return &Manager{clock: clk, workers: make(map[string]*worker)}
+}
+
+// Add adds the worker w with name, starting it if the Manager is started.
+func (m *Manager) Add(name string, w Worker) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	if _, ok := m.workers[name]; ok {
+		return ErrWorkerExists
+	}
+	wk := &worker{w: w}
+	m.workers[name] = wk
+	if m.ctx != nil {
+		m.start(name, wk)
+	}
+	return nil
+}
+
+// Start starts all the workers, which run until ctx is done or Stop is
+// called.
+func (m *Manager) Start(ctx context.Context) error {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	if m.ctx != nil {
+		return ErrStarted
+	}
+	m.ctx, m.cancel = context.WithCancel(ctx)
+	for name, wk := range m.workers {
+		m.start(name, wk)
+	}
+	return nil
+}
+
+// start runs the worker wk.  The caller must hold the write lock.
+func (m *Manager) start(name string, wk *worker) {
+	wk.status.Running = true
+	m.wg.Add(1)
+	go func(ctx context.Context) {
+		defer m.wg.Done()
+		wk.w(ctx, func(err error) { m.report(name, wk, err) })
+		m.rw.Lock()
+		wk.status.Running = false
+		m.rw.Unlock()
+	}(m.ctx)
+}
+
+func (m *Manager) report(name string, wk *worker, err error) {
+	m.rw.Lock()
+	defer m.rw.Unlock()
+	wk.status.Runs++
+	wk.status.LastRunTs = m.clock.Now().Unix()
+	wk.status.LastError = ""
+	if err != nil {
+		wk.status.Failures++
+		wk.status.LastError = err.Error()
+		log.WithError(err).WithField("worker", name).Error("Worker failed")
+	}
+}
+
+// Stop stops all the workers and waits for them to finish.  The Manager can
+// be started again afterwards.
+func (m *Manager) Stop() {
+	m.rw.Lock()
+	if m.ctx == nil {
+		m.rw.Unlock()
+		return
+	}
+	m.cancel()
+	m.ctx, m.cancel = nil, nil
+	m.rw.Unlock()
+	m.wg.Wait()
+}
+
+// Status returns the Status of each worker by name.
+func (m *Manager) Status() map[string]Status {
+	m.rw.RLock()
+	defer m.rw.RUnlock()
+	status := make(map[string]Status, len(m.workers))
+	for name, wk := range m.workers {
+		status[name] = wk.status
+	}
+	return status
+}
+
+// Check fails if a worker is not running or its last report failed.  It can
+// be registered as a health.Check.
+func (m *Manager) Check(ctx context.Context) error {
+	status := m.Status()
+	names := make([]string, 0, len(status))
+	for name := range status {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		s := status[name]
+		if !s.Running {
+			return fmt.Errorf("worker %v is not running", name)
+		}
+		if s.LastError != "" {
+			return fmt.Errorf("worker %v failed: %v", name, s.LastError)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,80 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/iden3/go-iden3-core/utils/clock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// waitRuns waits until the worker name has reported runs times.
+func waitRuns(t *testing.T, m *Manager, name string, runs uint64) Status {
+	for i := 0; i < 100; i++ {
+		if s := m.Status()[name]; s.Runs >= runs {
+			return s
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	require.FailNow(t, "timeout waiting for the worker "+name)
+	return Status{}
+}
+
+func TestManager(t *testing.T) {
+	clk := clock.NewFake(time.Unix(1600000000, 0))
+	m := NewManager(clk)
+	errFail := errors.New("unavailable")
+	fail := true
+	require.Nil(t, m.Add("periodic", Every(clk, time.Minute, func(ctx context.Context) error {
+		if fail {
+			return errFail
+		}
+		return nil
+	})))
+	assert.Equal(t, ErrWorkerExists, m.Add("periodic", Every(clk, time.Minute, nil)))
+	assert.Equal(t, map[string]Status{"periodic": {}}, m.Status())
+	assert.NotNil(t, m.Check(context.Background()))
+
+	require.Nil(t, m.Start(context.Background()))
+	assert.Equal(t, ErrStarted, m.Start(context.Background()))
+	clk.BlockUntil(1)
+	clk.Advance(time.Minute)
+	s := waitRuns(t, m, "periodic", 1)
+	assert.Equal(t, Status{Running: true, Runs: 1, Failures: 1, LastRunTs: clk.Now().Unix(),
+		LastError: errFail.Error()}, s)
+	assert.EqualError(t, m.Check(context.Background()), "worker periodic failed: unavailable")
+
+	// Workers added while started are started too.
+	started, stopped := make(chan struct{}), false
+	require.Nil(t, m.Add("startstop", StartStop(func() error {
+		close(started)
+		return nil
+	}, func() { stopped = true })))
+	<-started
+	waitRuns(t, m, "startstop", 1)
+
+	fail = false
+	clk.Advance(time.Minute)
+	waitRuns(t, m, "periodic", 2)
+	assert.Nil(t, m.Check(context.Background()))
+
+	m.Stop()
+	m.Stop()
+	assert.True(t, stopped)
+	status := m.Status()
+	assert.False(t, status["periodic"].Running)
+	assert.False(t, status["startstop"].Running)
+	assert.Equal(t, uint64(1), status["periodic"].Failures)
+
+	// A failed start stops the worker.
+	m = NewManager(clk)
+	require.Nil(t, m.Add("startstop", StartStop(func() error { return errFail }, func() {})))
+	require.Nil(t, m.Start(context.Background()))
+	s = waitRuns(t, m, "startstop", 1)
+	assert.Equal(t, errFail.Error(), s.LastError)
+	m.Stop()
+	assert.False(t, m.Status()["startstop"].Running)
+}